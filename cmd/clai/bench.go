@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/tools"
+)
+
+// benchResult is how a model did with the benchmark prompt
+type benchResult struct {
+	Model      string
+	FirstToken time.Duration // until the first text or reasoning arrived
+	Total      time.Duration
+	InTokens   int  // including the system prompt
+	OutTokens  int  // including the reasoning
+	Estimated  bool // the provider didn't give the token counts
+	Cost       float64
+	Finish     string
+	Response   string
+	Err        error
+}
+
+func newBenchCommand(ctx context.Context) *cobra.Command {
+	benchCmd := &cobra.Command{
+		Use:   "bench [prompt]",
+		Short: "Compare how several models answer the same prompt",
+		Long: `Send the same prompt to each of the --models in turn and print a table
+comparing how long they took, how many tokens they used and what it cost,
+followed by their responses.  Useful for picking a default model.
+
+The prompt is read from --prompt-file ("-" for stdin) or taken from the
+arguments.  Tool calls are shown in the responses but never run, use
+--no-tools to not offer the tools at all.  Token counts are estimates.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			models, _ := cmd.Flags().GetStringSlice("models")
+			promptFile, _ := cmd.Flags().GetString("prompt-file")
+			noTools, _ := cmd.Flags().GetBool("no-tools")
+			timeout, _ := cmd.Flags().GetDuration("timeout")
+
+			if len(models) == 0 {
+				return fmt.Errorf("--models is required")
+			}
+
+			prompt, err := benchPrompt(promptFile, args)
+			if err != nil {
+				return err
+			}
+
+			cfg, _, warnings, closer, err := setupSession()
+			if err != nil {
+				return err
+			}
+			defer closer()
+
+			for _, err := range warnings {
+				fmt.Fprintln(os.Stderr, "WARNING:", err)
+			}
+
+			var results []benchResult
+			for _, model := range models {
+				fmt.Fprintf(os.Stderr, "Running %s...\n", model)
+
+				mctx, cancel := context.WithTimeout(ctx, timeout)
+				res := runBench(mctx, cfg, model, prompt, noTools)
+				cancel()
+
+				results = append(results, res)
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+			}
+
+			printBench(os.Stdout, results)
+			return nil
+		},
+	}
+
+	benchCmd.Flags().StringSlice("models", nil, "comma separated models to compare")
+	benchCmd.Flags().String("prompt-file", "", "file to read the prompt from, - for stdin")
+	benchCmd.Flags().Bool("no-tools", false, "don't offer the tools to the models")
+	benchCmd.Flags().Duration("timeout", 5*time.Minute, "max time to wait for each model")
+
+	return benchCmd
+}
+
+// benchPrompt reads the prompt from the file, or joins the args if there
+// is no file
+func benchPrompt(file string, args []string) (string, error) {
+	var prompt string
+	switch file {
+	case "":
+		prompt = strings.Join(args, " ")
+	case "-":
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", err
+		}
+		prompt = string(data)
+	default:
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read prompt: %w", err)
+		}
+		prompt = string(data)
+	}
+
+	if strings.TrimSpace(prompt) == "" {
+		return "", fmt.Errorf("no prompt given, use --prompt-file or give it as an argument")
+	}
+	return prompt, nil
+}
+
+// runBench sends the prompt to the model and times the response, errors are
+// kept in the result so the other models still get run
+func runBench(ctx context.Context, cfg *config.Config, model, prompt string, noTools bool) benchResult {
+	mcfg := *cfg
+	mcfg.Model = model
+	mcfg.Fallbacks = nil // each model is timed on its own
+	res := benchResult{Model: model}
+
+	client, err := ai.NewClient(&mcfg)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	if !noTools {
+		client.SetTools(tools.NewRegistry(&mcfg).Tools())
+	}
+
+	start := time.Now()
+	stream, err := client.StreamMessage(ctx, []ai.Message{{Role: "user", Content: prompt}})
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	var response strings.Builder
+	var reasoning int
+	var usage *ai.Usage
+	for chunk := range stream {
+		switch chunk.Type() {
+		case ai.ChunkMessage, ai.ChunkThink:
+			if res.FirstToken == 0 {
+				res.FirstToken = time.Since(start)
+			}
+			if chunk.Type() == ai.ChunkThink {
+				reasoning += len(chunk.Content)
+				break
+			}
+			response.WriteString(chunk.Content)
+		case ai.ChunkToolCall:
+			fmt.Fprintf(&response, "\n[tool call %s %s]\n", chunk.ToolCall.Name, chunk.ToolCall.Input)
+		case ai.ChunkFinish:
+			res.Finish = chunk.Content
+		case ai.ChunkError:
+			res.Err = fmt.Errorf("%s", chunk.Content)
+		case ai.ChunkUsage:
+			usage = chunk.Usage
+		}
+	}
+	res.Total = time.Since(start)
+
+	if res.Err == nil && ctx.Err() != nil {
+		res.Err = ctx.Err()
+	}
+
+	res.Response = strings.TrimSpace(response.String())
+	if usage != nil {
+		res.InTokens, res.OutTokens, res.Estimated = usage.PromptTokens, usage.CompletionTokens, usage.Estimated
+	} else {
+		res.InTokens = ai.EstimateTokens(mcfg.SystemPrompt) + ai.EstimateTokens(prompt)
+		res.OutTokens = ai.EstimateTokens(res.Response) + ai.EstimateTokensLen(reasoning)
+		res.Estimated = true
+	}
+	res.Cost = ai.LookupModel(&mcfg, model).Cost(res.InTokens, res.OutTokens)
+	return res
+}
+
+// printBench writes the comparison table followed by each response
+func printBench(w io.Writer, results []benchResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODEL\tFIRST TOKEN\tTOTAL\tTOKENS IN\tTOKENS OUT\tTOKENS/S\tCOST\tFINISH")
+	for _, r := range results {
+		finish := r.Finish
+		if r.Err != nil {
+			finish = "error"
+		}
+
+		var rate string
+		if secs := r.Total.Seconds(); secs > 0 && r.OutTokens > 0 {
+			rate = fmt.Sprintf("%.1f", float64(r.OutTokens)/secs)
+		}
+
+		approx := ""
+		if r.Estimated {
+			approx = "~"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s%d\t%s%d\t%s\t$%.4f\t%s\n",
+			r.Model,
+			r.FirstToken.Round(time.Millisecond),
+			r.Total.Round(time.Millisecond),
+			approx, r.InTokens, approx, r.OutTokens, rate, r.Cost, finish)
+	}
+	tw.Flush()
+
+	for _, r := range results {
+		fmt.Fprintf(w, "\n=== %s ===\n", r.Model)
+		if r.Err != nil {
+			fmt.Fprintln(w, "Error:", r.Err)
+		}
+		if r.Response != "" {
+			fmt.Fprintln(w, r.Response)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/penguinpowernz/clai/config"
+)
+
+func newConfigCommand() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Work with the config file",
+	}
+
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "validate",
+		Short: "Check the config file for mistakes",
+		Long: `Check the config file for keys that clai doesn't know, which are otherwise
+ignored, and for values of the wrong type or that aren't allowed.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fn := viper.ConfigFileUsed()
+			problems, err := config.CheckFile(fn)
+			if err != nil {
+				return err
+			}
+
+			found, fatal := len(problems), false
+			for _, p := range problems {
+				fmt.Printf("%s:%d: %s: %s\n", fn, p.Line, p.Key, p.Message)
+				fatal = fatal || !p.Unknown
+			}
+
+			// the values are only checked once they can all be read
+			if !fatal {
+				if _, err := config.Load(); err != nil {
+					fmt.Printf("%s: %s\n", fn, err)
+					found++
+				}
+			}
+
+			if found > 0 {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("%s has %d mistake(s)", fn, found)
+			}
+
+			fmt.Println(fn, "is valid")
+			return nil
+		},
+	})
+
+	return configCmd
+}
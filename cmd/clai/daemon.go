@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/chat"
+	"github.com/penguinpowernz/clai/internal/daemon"
+	"github.com/penguinpowernz/clai/internal/ui"
+)
+
+// daemonStartTimeout is how long a daemon started in the background has to
+// start listening
+const daemonStartTimeout = 10 * time.Second
+
+// daemonQuitTime is how long the attached UIs have to quit when the daemon
+// stops, before they are killed
+const daemonQuitTime = 2 * time.Second
+
+func newDaemonCommand(ctx context.Context) *cobra.Command {
+	daemonCmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Run a session in the background for terminals to attach to",
+		Long: `Run a new session in the current directory that terminals can attach to with
+clai attach.  The session, the connection to the provider and the loaded model
+stay warm between attaches, and several terminals can attach to the same
+session at once.  Each one sees the whole conversation, the first to attach
+drives the session and the others are read-only until the driver hands it
+over to them.
+
+Ctrl+C in an attached terminal detaches it, /exit ends the session for all of
+them and stops the daemon.  Use --detach to start it in the background.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, _ := cmd.Flags().GetString("id")
+			if id == "" {
+				id = generateSessionID()
+			}
+
+			if detach, _ := cmd.Flags().GetBool("detach"); detach {
+				cfg, err := config.Load()
+				if err != nil {
+					return fmt.Errorf("failed to load config: %w", err)
+				}
+				if err := startDaemon(cmd, cfg, id); err != nil {
+					return err
+				}
+				fmt.Printf("Started session %s, attach to it with: clai attach %s\n", id, id)
+				return nil
+			}
+
+			return runDaemon(ctx, id)
+		},
+	}
+
+	daemonCmd.Flags().BoolP("detach", "d", false, "run the daemon in the background")
+	daemonCmd.Flags().String("id", "", "ID of the new session")
+	daemonCmd.Flags().MarkHidden("id")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the sessions running in the daemon",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			running, err := daemon.Running(cfg.StateDir)
+			if err != nil {
+				return err
+			}
+
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tSTARTED\tATTACHED\tDRIVER\tDIR\tTITLE")
+			for _, info := range running {
+				title := info.Title
+				if title == "" {
+					title = "-"
+				}
+				driver := info.Driver
+				if driver == "" {
+					driver = "-"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\n", info.ID, info.Started.Format(time.DateTime), info.Clients, driver, info.Dir, title)
+			}
+			return tw.Flush()
+		},
+	}
+
+	stopCmd := &cobra.Command{
+		Use:   "stop <session>",
+		Short: "End a session running in the daemon",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			if _, err := daemon.Query(daemon.Socket(cfg.StateDir, args[0]), daemon.OpStop); err != nil {
+				return fmt.Errorf("session %s isn't running, see clai daemon list", args[0])
+			}
+			fmt.Println("Stopped session", args[0])
+			return nil
+		},
+	}
+
+	daemonCmd.AddCommand(listCmd, stopCmd)
+	return daemonCmd
+}
+
+func newAttachCommand(ctx context.Context) *cobra.Command {
+	attachCmd := &cobra.Command{
+		Use:   "attach [session]",
+		Short: "Attach the terminal to a session running in the daemon",
+		Long: `Attach the terminal to a session running in the daemon (see clai daemon).
+Without a session the only one running is attached to, or if none are running
+a new one is started in the background in the current directory.
+
+If another terminal is already driving the session this one is read-only,
+press ENTER to ask the driver to hand it over.  If they don't answer within
+30 seconds it is handed over anyway.  Ctrl+C detaches the terminal and leaves
+the session running.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			var id string
+			if len(args) > 0 {
+				id = args[0]
+				if _, err := daemon.Query(daemon.Socket(cfg.StateDir, id), daemon.OpInfo); err != nil {
+					return fmt.Errorf("session %s isn't running, see clai daemon list", id)
+				}
+			} else {
+				running, err := daemon.Running(cfg.StateDir)
+				if err != nil {
+					return err
+				}
+
+				switch len(running) {
+				case 0:
+					id = generateSessionID()
+					if err := startDaemon(cmd, cfg, id); err != nil {
+						return err
+					}
+				case 1:
+					id = running[0].ID
+				default:
+					var ids []string
+					for _, info := range running {
+						ids = append(ids, info.ID)
+					}
+					return fmt.Errorf("%d sessions are running, say which one to attach to: %s", len(running), strings.Join(ids, ", "))
+				}
+			}
+
+			name, _ := cmd.Flags().GetString("name")
+			if err := daemon.Attach(daemon.Socket(cfg.StateDir, id), name, os.Stdin, os.Stdout); err != nil {
+				return err
+			}
+			fmt.Println("Detached from session", id)
+			return nil
+		},
+	}
+
+	attachCmd.Flags().String("name", userName(), "who the other terminals attached to the session are told you are")
+	return attachCmd
+}
+
+// userName is who is attaching to the session by default
+func userName() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// startDaemon starts a daemon for the session in the background with the
+// same global flags, and waits for it to start listening
+func startDaemon(cmd *cobra.Command, cfg *config.Config, id string) error {
+	bin, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	args := []string{"daemon", "--id", id}
+	cmd.InheritedFlags().Visit(func(f *pflag.Flag) {
+		args = append(args, "--"+f.Name+"="+f.Value.String())
+	})
+
+	// what the daemon says before it starts listening is kept for when it
+	// fails to start, the rest goes to its log
+	if err := os.MkdirAll(daemon.Dir(cfg.StateDir), 0700); err != nil {
+		return err
+	}
+	outFile := daemon.Socket(cfg.StateDir, id) + ".out"
+	out, err := os.Create(outFile)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(outFile)
+	defer out.Close()
+
+	daemonCmd := exec.Command(bin, args...)
+	daemonCmd.Stdout = out
+	daemonCmd.Stderr = out
+	daemonCmd.SysProcAttr = detachedProcess()
+	if err := daemonCmd.Start(); err != nil {
+		return err
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- daemonCmd.Wait() }()
+
+	sock := daemon.Socket(cfg.StateDir, id)
+	deadline := time.After(daemonStartTimeout)
+	for {
+		if _, err := daemon.Query(sock, daemon.OpInfo); err == nil {
+			return nil
+		}
+
+		select {
+		case err := <-exited:
+			said, _ := os.ReadFile(outFile)
+			return fmt.Errorf("the daemon exited (%v): %s", err, strings.TrimSpace(string(said)))
+		case <-deadline:
+			return fmt.Errorf("the daemon didn't start listening on %s within %s", sock, daemonStartTimeout)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// runDaemon runs the session until it is stopped, with a UI for each
+// terminal that attaches
+func runDaemon(ctx context.Context, id string) error {
+	cfg, session, warnings, closer, err := setupSessionID(id)
+	if err != nil {
+		return err
+	}
+	defer closer()
+	cfg.FollowUps = false
+
+	for _, err := range warnings {
+		fmt.Fprintln(os.Stderr, "WARNING:", err)
+	}
+
+	srv, err := daemon.Listen(daemon.Socket(cfg.StateDir, id))
+	if err != nil {
+		return err
+	}
+	defer srv.Close()
+
+	serveCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	uiCtx, kill := context.WithCancel(ctx)
+	defer kill()
+
+	d := &daemonSession{id: id, ctx: uiCtx, kill: kill, stop: stop, cfg: cfg, session: session, started: time.Now()}
+	d.hub = daemon.NewHub(d.end)
+	session.AddObserver(d.hub)
+	d.hub.AddObserver(session)
+
+	go session.InteractiveMode(ctx)
+	go session.CheckHealth(ctx)
+
+	fmt.Printf("Running session %s in %s, attach to it with: clai attach %s\n", id, session.WorkingDir(), id)
+	return srv.Serve(serveCtx, d)
+}
+
+// daemonSession is the session the daemon runs, each attached terminal gets
+// its own chat UI
+type daemonSession struct {
+	id      string             // what the socket is named after, the session's ID changes when it is branched
+	ctx     context.Context    // the UIs are killed when it is done
+	kill    context.CancelFunc // kills the UIs
+	stop    context.CancelFunc // stops accepting terminals
+	cfg     *config.Config
+	session *chat.Session
+	hub     *daemon.Hub
+	started time.Time
+}
+
+func (d *daemonSession) Info() daemon.Info {
+	title, _ := d.session.Title()
+	return daemon.Info{
+		ID:      d.id,
+		Dir:     d.session.WorkingDir(),
+		Title:   title,
+		Clients: d.hub.Clients(),
+		Driver:  d.hub.Driver(),
+		Started: d.started,
+	}
+}
+
+// Stop tells the UIs to quit and stops the daemon
+func (d *daemonSession) Stop() {
+	d.hub.Broadcast(ui.EventExit{})
+	d.end()
+}
+
+// end stops the daemon once the session has ended, the UIs get a moment to
+// quit before they are killed
+func (d *daemonSession) end() {
+	d.stop()
+	time.AfterFunc(daemonQuitTime, d.kill)
+}
+
+func (d *daemonSession) Attach(t *daemon.Terminal) error {
+	// the styles are shared by all the UIs, so the last terminal to attach
+	// picks the colors
+	if !d.cfg.NoColor {
+		lipgloss.SetColorProfile(t.ColorProfile())
+		lipgloss.SetHasDarkBackground(t.Dark)
+	}
+
+	cm := ui.NewChatModel(d.ctx, d.cfg)
+	if h, err := d.session.History().Load(); err == nil {
+		cm.SetTranscript(h.UI)
+	}
+	cm.SetHistory(d.session.History())
+	cm.SetOutput(t)
+
+	c := d.hub.Join(t.User)
+	defer c.Leave()
+	c.AddObserver(cm)
+	cm.AddObserver(c)
+
+	p := tea.NewProgram(cm,
+		tea.WithContext(d.ctx),
+		tea.WithInput(t),
+		tea.WithOutput(t),
+		tea.WithAltScreen(),
+		tea.WithMouseCellMotion(),
+		tea.WithoutSignalHandler(),
+	)
+
+	go func() {
+		p.Send(tea.WindowSizeMsg{Width: t.Width, Height: t.Height})
+		p.Send(ui.EventAttached(d.id))
+		for size := range t.Sizes() {
+			p.Send(tea.WindowSizeMsg{Width: size.Width, Height: size.Height})
+		}
+		// the terminal went away without quitting
+		p.Kill()
+	}()
+
+	if _, err := p.Run(); err != nil && !errors.Is(err, tea.ErrProgramKilled) {
+		return err
+	}
+	return nil
+}
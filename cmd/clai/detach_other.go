@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "syscall"
+
+// detachedProcess is nothing special where there are no sessions to leave
+func detachedProcess() *syscall.SysProcAttr {
+	return nil
+}
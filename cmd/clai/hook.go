@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// hookMarker identifies hooks that were installed by clai, so they can be
+// safely replaced or removed
+const hookMarker = "# installed by clai hook install"
+
+// hookNames are the git hooks that clai installs
+var hookNames = []string{"prepare-commit-msg", "pre-push"}
+
+const commitMsgPrompt = `Write a git commit message for the staged changes below.  Use a short summary line
+of no more than 72 characters, then a blank line and a brief description of the
+changes if they need it.  Reply with only the commit message, no code fences or
+commentary.`
+
+const reviewPrompt = `Quickly review the changes below that are about to be pushed.  Point out any
+obvious bugs, leftover debugging code, secrets or typos in a few short bullet
+points.  If there is nothing worth mentioning just reply "LGTM".`
+
+// zeroSHA is what git uses for a ref that doesn't exist
+const zeroSHA = "0000000000000000000000000000000000000000"
+
+func newHookCommand(ctx context.Context) *cobra.Command {
+	hookCmd := &cobra.Command{
+		Use:   "hook",
+		Short: "Manage the git hooks that use clai",
+		Long: `Install git hooks that use clai to write commit messages (prepare-commit-msg)
+and to review changes before they are pushed (pre-push).
+
+The hooks can be disabled in a repo with:
+
+  git config clai.hooks false               # disable all clai hooks
+  git config clai.hook.pre-push false       # disable a single hook`,
+	}
+
+	installCmd := &cobra.Command{
+		Use:   "install",
+		Short: "Install the clai git hooks in the current repo",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			force, _ := cmd.Flags().GetBool("force")
+
+			dir, err := hooksDir()
+			if err != nil {
+				return err
+			}
+
+			bin, err := claiPath()
+			if err != nil {
+				return err
+			}
+
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+
+			for _, name := range hookNames {
+				fn := filepath.Join(dir, name)
+				if !force && hookExists(fn) && !isClaiHook(fn) {
+					return fmt.Errorf("%s already exists, use --force to replace it", fn)
+				}
+
+				script := fmt.Sprintf("#!/bin/sh\n%s\nexec %q hook run %s \"$@\"\n", hookMarker, bin, name)
+				if err := os.WriteFile(fn, []byte(script), 0755); err != nil {
+					return err
+				}
+				fmt.Println("Installed", fn)
+			}
+
+			return nil
+		},
+	}
+	installCmd.Flags().Bool("force", false, "replace existing hooks that weren't installed by clai")
+
+	uninstallCmd := &cobra.Command{
+		Use:   "uninstall",
+		Short: "Remove the clai git hooks from the current repo",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir, err := hooksDir()
+			if err != nil {
+				return err
+			}
+
+			for _, name := range hookNames {
+				fn := filepath.Join(dir, name)
+				if !isClaiHook(fn) {
+					continue
+				}
+				if err := os.Remove(fn); err != nil {
+					return err
+				}
+				fmt.Println("Removed", fn)
+			}
+
+			return nil
+		},
+	}
+
+	runCmd := &cobra.Command{
+		Use:    "run <hook> [args...]",
+		Short:  "Run a git hook, this is called by the installed hooks",
+		Hidden: true,
+		Args:   cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if !hookEnabled(name) {
+				return nil
+			}
+
+			// never stop the commit or push because the AI had a problem
+			var err error
+			switch name {
+			case "prepare-commit-msg":
+				err = prepareCommitMsg(ctx, args[1:])
+			case "pre-push":
+				err = prePush(ctx)
+			default:
+				err = fmt.Errorf("unknown hook %q", name)
+			}
+
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "clai:", err)
+			}
+			return nil
+		},
+	}
+
+	hookCmd.AddCommand(installCmd, uninstallCmd, runCmd)
+	return hookCmd
+}
+
+// prepareCommitMsg writes a commit message for the staged changes into the
+// message file, unless the user already gave a message
+func prepareCommitMsg(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("prepare-commit-msg: no message file given")
+	}
+
+	// the source is set when the message came from -m, a template, a merge etc
+	if len(args) > 1 && args[1] != "" {
+		return nil
+	}
+
+	diff, err := git("diff", "--cached")
+	if err != nil || strings.TrimSpace(diff) == "" {
+		return err
+	}
+
+	msg, err := hookOneShot(ctx, commitMsgPrompt, diff)
+	if err != nil {
+		return err
+	}
+
+	// keep the comments git put in the file
+	existing, _ := os.ReadFile(args[0])
+	return os.WriteFile(args[0], append([]byte(strings.TrimSpace(msg)+"\n"), existing...), 0644)
+}
+
+// prePush prints a review of the commits being pushed, git gives the refs
+// being pushed on stdin
+func prePush(ctx context.Context) error {
+	var diffs []string
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		// <local ref> <local sha> <remote ref> <remote sha>
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 4 || fields[1] == zeroSHA {
+			continue // deleting a ref
+		}
+
+		rng := fields[3] + ".." + fields[1]
+		if fields[3] == zeroSHA {
+			// a new branch, review what isn't on the remote already
+			rng = fields[1] + " --not --remotes"
+		}
+
+		diff, err := git(append([]string{"log", "-p", "--reverse"}, strings.Fields(rng)...)...)
+		if err != nil {
+			return err
+		}
+		diffs = append(diffs, diff)
+	}
+
+	diff := strings.Join(diffs, "\n")
+	if strings.TrimSpace(diff) == "" {
+		return nil
+	}
+
+	review, err := hookOneShot(ctx, reviewPrompt, diff)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "clai review:")
+	fmt.Fprintln(os.Stderr, strings.TrimSpace(review))
+	return nil
+}
+
+// hookOneShot sends the prompt and diff to the AI in one-shot mode and
+// returns the response, the diff is truncated to the max file size
+func hookOneShot(ctx context.Context, prompt, diff string) (string, error) {
+	cfg, session, _, closer, err := setupSession()
+	if err != nil {
+		return "", err
+	}
+	defer closer()
+	cfg.FollowUps = false
+
+	if cfg.MaxFileSize > 0 && int64(len(diff)) > cfg.MaxFileSize {
+		diff = diff[:cfg.MaxFileSize] + "\n[diff truncated]"
+	}
+
+	out := bytes.NewBuffer(nil)
+	err = oneShot(ctx, session, prompt+"\n\n```diff\n"+diff+"\n```", out)
+	return out.String(), err
+}
+
+// hookEnabled checks the git config to see if the hook has been disabled
+func hookEnabled(name string) bool {
+	for _, key := range []string{"clai.hooks", "clai.hook." + name} {
+		if val, _ := git("config", "--bool", key); strings.TrimSpace(val) == "false" {
+			return false
+		}
+	}
+	return true
+}
+
+func hooksDir() (string, error) {
+	dir, err := git("rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", fmt.Errorf("not in a git repo: %w", err)
+	}
+	return filepath.Abs(strings.TrimSpace(dir))
+}
+
+// claiPath finds the clai binary for the hooks to call, preferring the one in
+// the PATH so that the hooks survive upgrades
+func claiPath() (string, error) {
+	if bin, err := exec.LookPath("clai"); err == nil {
+		return bin, nil
+	}
+	return os.Executable()
+}
+
+func hookExists(fn string) bool {
+	_, err := os.Stat(fn)
+	return err == nil
+}
+
+func isClaiHook(fn string) bool {
+	data, err := os.ReadFile(fn)
+	return err == nil && bytes.Contains(data, []byte(hookMarker))
+}
+
+func git(args ...string) (string, error) {
+	out, err := exec.Command("git", args...).Output()
+	return string(out), err
+}
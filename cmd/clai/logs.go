@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/history"
+	"github.com/penguinpowernz/clai/internal/logs"
+)
+
+// logPath is the file that clai logs to
+func logPath(cfg *config.Config) string {
+	return filepath.Join(cfg.StateDir, "clai.log")
+}
+
+// sessionLogPath is the file that the log of a single session is written to
+func sessionLogPath(cfg *config.Config, sessionID string) string {
+	return filepath.Join(cfg.StateDir, "logs", sessionID+".log")
+}
+
+// openSessionLog creates the log of the session
+func openSessionLog(cfg *config.Config, sessionID string) (*logs.File, error) {
+	fn := sessionLogPath(cfg, sessionID)
+	if err := os.MkdirAll(filepath.Dir(fn), 0700); err != nil {
+		return nil, err
+	}
+	return logs.Open(fn, cfg.LogMaxSize, cfg.LogKeep)
+}
+
+func newLogsCommand(ctx context.Context) *cobra.Command {
+	logsCmd := &cobra.Command{
+		Use:   "logs",
+		Short: "Show or clear the clai log",
+	}
+
+	tailCmd := &cobra.Command{
+		Use:   "tail",
+		Short: "Print the end of the log",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			n, _ := cmd.Flags().GetInt("lines")
+			follow, _ := cmd.Flags().GetBool("follow")
+			sessionID, _ := cmd.Flags().GetString("session")
+
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			fn := logPath(cfg)
+			if sessionID != "" {
+				fn = sessionLogPath(cfg, sessionID)
+				// the history says where it is, in case the state_dir changed
+				if h, err := history.NewStore(cfg.SessionDir, sessionID).Load(); err == nil && h.Log != "" {
+					fn = h.Log
+				}
+			}
+
+			lines, err := logs.Tail(fn, n)
+			if err != nil && !(follow && os.IsNotExist(err)) {
+				return err
+			}
+			for _, line := range lines {
+				fmt.Println(line)
+			}
+
+			if follow {
+				return followLog(ctx, fn)
+			}
+			return nil
+		},
+	}
+	tailCmd.Flags().IntP("lines", "n", 50, "how many lines to print")
+	tailCmd.Flags().BoolP("follow", "f", false, "keep printing lines as they are written")
+	tailCmd.Flags().StringP("session", "s", "", "print the log of just this session (see clai sessions)")
+
+	clearCmd := &cobra.Command{
+		Use:   "clear",
+		Short: "Remove the logs, including those of the sessions and the API debug logs",
+		Long: `Remove the log and the rotated logs, along with the logs of each session and
+the API debug logs written by --debug-api.  They can have whole prompts and
+responses in them.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			sessions, _ := filepath.Glob(filepath.Join(cfg.StateDir, "logs", "*.log"))
+			removed := 0
+			for _, fn := range append([]string{logPath(cfg)}, sessions...) {
+				n, err := logs.Clear(fn)
+				removed += n
+				if err != nil {
+					return err
+				}
+			}
+
+			debug, _ := filepath.Glob(filepath.Join(cfg.StateDir, "debug", "*.log"))
+			for _, fn := range debug {
+				if err := os.Remove(fn); err != nil {
+					return err
+				}
+				removed++
+			}
+
+			fmt.Printf("Removed %d log(s) from %s\n", removed, cfg.StateDir)
+			return nil
+		},
+	}
+
+	logsCmd.AddCommand(tailCmd, clearCmd)
+	return logsCmd
+}
+
+// followLog prints what is added to the log until interrupted, starting over
+// when the log is rotated or cleared
+func followLog(ctx context.Context, fn string) error {
+	var offset int64
+	if fi, err := os.Stat(fn); err == nil {
+		offset = fi.Size()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(500 * time.Millisecond):
+		}
+
+		f, err := os.Open(fn)
+		if os.IsNotExist(err) {
+			offset = 0
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if fi, err := f.Stat(); err == nil && fi.Size() < offset {
+			offset = 0
+		}
+		if _, err := f.Seek(offset, io.SeekStart); err == nil {
+			n, _ := io.Copy(os.Stdout, f)
+			offset += n
+		}
+		f.Close()
+	}
+}
@@ -3,23 +3,36 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"strings"
 	"syscall"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/penguinpowernz/clai/config"
 	"github.com/penguinpowernz/clai/internal/ai"
 	"github.com/penguinpowernz/clai/internal/chat"
+	"github.com/penguinpowernz/clai/internal/commands"
+	"github.com/penguinpowernz/clai/internal/editor"
 	"github.com/penguinpowernz/clai/internal/history"
+	"github.com/penguinpowernz/clai/internal/i18n"
+	"github.com/penguinpowernz/clai/internal/logs"
+	"github.com/penguinpowernz/clai/internal/metrics"
+	"github.com/penguinpowernz/clai/internal/notify"
+	"github.com/penguinpowernz/clai/internal/prompt"
+	"github.com/penguinpowernz/clai/internal/tools"
 	"github.com/penguinpowernz/clai/internal/ui"
+	"github.com/penguinpowernz/clai/internal/usage"
 )
 
 var (
@@ -61,115 +74,465 @@ Helps you write, refactor, and debug code through conversational AI.
 
 Run without arguments to enter interactive mode, or provide a message to send immediately.`,
 		Version: version,
+		// anything that isn't a subcommand is a one-shot message
+		Args: cobra.ArbitraryArgs,
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			return initConfig()
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg, err := config.Load()
+			cfg, session, warnings, closer, err := setupSession()
 			if err != nil {
-				return fmt.Errorf("failed to load config: %w", err)
+				return err
 			}
+			defer closer()
 
-			if err := os.MkdirAll(cfg.SessionDir, 0755); err != nil {
-				return fmt.Errorf("failed to create session directory: %w", err)
-			}
+			// a message on the command line is answered without the chat UI
+			if len(args) > 0 {
+				cfg.FollowUps = false
+				for _, err := range warnings {
+					fmt.Fprintln(os.Stderr, "WARNING:", err)
+				}
 
-			f, err := os.OpenFile(filepath.Join(cfg.SessionDir, "clai.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-			if err != nil {
-				return fmt.Errorf("failed to open log file: %w", err)
+				// show the request without sending it
+				if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+					payload, tokens, err := session.Inspect(ctx, oneShotPrompt(args))
+					if err != nil {
+						return err
+					}
+					fmt.Println(string(payload))
+					fmt.Fprintln(os.Stderr, tokens)
+					return nil
+				}
+
+				start := time.Now()
+				err := oneShot(ctx, session, oneShotPrompt(args), os.Stdout)
+				notifyWebhook(cfg, session, strings.Join(args, " "), time.Since(start), err)
+				return err
 			}
-			defer f.Close()
-			log.SetOutput(f)
 
-			aiClient, err := ai.NewClient(cfg)
+			socket, _ := cmd.Flags().GetString("listen")
+
+			metricsAddr, _ := cmd.Flags().GetString("metrics")
+			closeMetrics, err := serveMetrics(metricsAddr)
 			if err != nil {
-				return fmt.Errorf("failed to create AI client: %w", err)
+				return err
 			}
+			defer closeMetrics()
+
+			// a line based UI that works with screen readers
+			if cfg.Accessible {
+				ui.SetNoColor(true)
+				a := ui.NewAccessible(os.Stdin, os.Stdout)
+				for _, err := range warnings {
+					fmt.Println("WARNING:", err)
+				}
+
+				session.AddObserver(a)
+				a.AddObserver(session)
+				go session.InteractiveMode(ctx)
+				go session.CheckHealth(ctx)
+
+				closeEditor, err := serveEditor(socket, cfg, session, a.Inject)
+				if err != nil {
+					return err
+				}
+				defer closeEditor()
 
-			sessionID := generateSessionID()
-			history.SetSessionID(sessionID)
-			history.SetConfig(*cfg)
+				if err := a.Run(ctx); err != nil {
+					return err
+				}
+
+				fmt.Println("Ended chat session", session.ID())
+				return nil
+			}
 
 			cm := ui.NewChatModel(ctx, cfg)
-			session := chat.NewSession(cfg, aiClient, sessionID)
+			cm.SetHistory(session.History())
+			cm.SetDraftFile(filepath.Join(cfg.StateDir, "draft"))
+			for _, err := range warnings {
+				cm.AddSystemMessage("WARNING: " + err.Error())
+			}
+
 			session.AddObserver(cm)
 			cm.AddObserver(session)
 
 			// Enter interactive mode
 			go session.InteractiveMode(ctx)
+			go session.CheckHealth(ctx)
 			p := tea.NewProgram(cm, tea.WithMouseCellMotion(), tea.WithAltScreen())
+
+			closeEditor, err := serveEditor(socket, cfg, session, func(prompt string) {
+				p.Send(ui.EventInjectPrompt(prompt))
+			})
+			if err != nil {
+				return err
+			}
+			defer closeEditor()
+
 			if _, err := p.Run(); err != nil {
 				return fmt.Errorf("error running interactive mode: %w", err)
 			}
 
-			fmt.Println("Ended chat session", sessionID)
+			fmt.Println("Ended chat session", session.ID())
 
 			return nil
 		},
 	}
 
 	// Global flags
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "~/.clai.yml", "config file")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default $XDG_CONFIG_HOME/clai/config.yml)")
 	rootCmd.PersistentFlags().String("model", "", "AI model to use (e.g., gpt-oss:latest)")
 	rootCmd.PersistentFlags().String("provider", "", "AI provider (ollama, openai)")
+	rootCmd.PersistentFlags().String("persona", "", "persona from the config to add to the system prompt")
 	rootCmd.PersistentFlags().String("session", "", "The session ID to load history from")
 	rootCmd.PersistentFlags().Bool("verbose", false, "verbose output")
+	rootCmd.PersistentFlags().Bool("no-color", false, "turn off colors, also set by the NO_COLOR env var")
+	rootCmd.PersistentFlags().Bool("accessible", false, "use a plain line based UI that works with screen readers")
+	rootCmd.PersistentFlags().Bool("debug-api", false, "write the full API requests and responses to a debug file in the state dir")
+	rootCmd.PersistentFlags().Int("seed", 0, "seed for providers that take one, so responses can be reproduced")
 
 	// Chat-specific flags
 	rootCmd.Flags().StringSliceP("files", "f", []string{}, "files to include in context")
+	rootCmd.Flags().String("listen", "", "serve the editor JSON-RPC API on this unix socket")
+	rootCmd.Flags().String("metrics", "", "serve Prometheus metrics on this address, e.g. :9090")
+	rootCmd.Flags().Bool("dry-run", false, "print the request a one-shot message would send, without sending it")
 
 	// Bind flags to viper
 	viper.BindPFlag("model", rootCmd.PersistentFlags().Lookup("model"))
 	viper.BindPFlag("provider", rootCmd.PersistentFlags().Lookup("provider"))
+	viper.BindPFlag("persona", rootCmd.PersistentFlags().Lookup("persona"))
 	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
 	viper.BindPFlag("no_color", rootCmd.PersistentFlags().Lookup("no-color"))
+	viper.BindPFlag("accessible", rootCmd.PersistentFlags().Lookup("accessible"))
+	viper.BindPFlag("debug_api", rootCmd.PersistentFlags().Lookup("debug-api"))
+	viper.BindPFlag("seed", rootCmd.PersistentFlags().Lookup("seed"))
+
+	rootCmd.AddCommand(newWatchCommand(ctx), newHookCommand(ctx), newBenchCommand(ctx), newSessionsCommand(), newUsageCommand(), newConfigCommand(), newLogsCommand(ctx), newPacksCommand(ctx), newScheduleCommand(ctx), newDaemonCommand(ctx), newAttachCommand(ctx))
 
 	return rootCmd
 }
 
-func initConfig() error {
-	if cfgFile != "" {
-		cfgFile = strings.Replace(cfgFile, "~", os.Getenv("HOME"), 1)
-		viper.SetConfigFile(cfgFile)
+// setupSession loads the config, sets up logging and plugins and creates a new
+// chat session.  Any errors from loading plugins, and mistakes in the config,
+// are returned so they can be shown to the user, the closer must be called
+// when the session is done.
+func setupSession() (*config.Config, *chat.Session, []error, func(), error) {
+	return setupSessionID(generateSessionID())
+}
+
+// setupSessionID is setupSession with the ID of the session given, the
+// daemon tells it to clai attach before the session is set up
+func setupSessionID(sessionID string) (*config.Config, *chat.Session, []error, func(), error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	ui.SetNoColor(cfg.NoColor)
+
+	// a read-only home (e.g. in a container) doesn't stop the chat, it just
+	// isn't kept once it's over
+	writable := map[string]bool{}
+	var readOnly []string
+	var readOnlyErr error
+	for _, dir := range []string{cfg.SessionDir, cfg.DataDir, cfg.StateDir} {
+		if _, checked := writable[dir]; checked {
+			continue
+		}
+		err := checkWritable(dir)
+		if err != nil {
+			readOnly = append(readOnly, dir)
+			readOnlyErr = err
+		}
+		writable[dir] = err == nil
+	}
+	if !writable[cfg.SessionDir] {
+		cfg.SaveHistory = false
+	}
+
+	// the tokenizer downloads its data on first use
+	if os.Getenv("TIKTOKEN_CACHE_DIR") == "" {
+		os.Setenv("TIKTOKEN_CACHE_DIR", filepath.Join(cfg.CacheDir, "tiktoken"))
+	}
+
+	closer := func() {}
+	if writable[cfg.StateDir] {
+		w, closeLogs, err := openLogs(cfg, sessionID)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		log.SetOutput(w)
+		closer = closeLogs
 	} else {
-		home, err := os.UserHomeDir()
+		log.SetOutput(io.Discard)
+	}
+
+	if err := i18n.LoadDir(cfg.LocaleDir); err != nil {
+		log.Println("[i18n] failed to load translations:", err)
+	}
+	log.Println("[i18n] using locale", i18n.SetLocale(i18n.Detect(cfg.Locale)))
+
+	if cfg.DebugAPI {
+		df, err := openDebugLog(cfg, sessionID)
 		if err != nil {
-			return err
+			closer()
+			return nil, nil, nil, nil, err
+		}
+		ai.SetDebugLog(df)
+		closeLogs := closer
+		closer = func() { df.Close(); closeLogs() }
+	}
+
+	aiClient, err := ai.NewClient(cfg)
+	if err != nil {
+		closer()
+		return nil, nil, nil, nil, fmt.Errorf("failed to create AI client: %w", err)
+	}
+
+	wd, _ := os.Getwd()
+	usageLog := usage.NewLog(usagePath(cfg), sessionID, wd)
+	budget := newBudget(cfg)
+	tally := &usage.Tally{}
+	ai.SetUsageFunc(func(model string, prompt, completion int, estimated bool) {
+		cost := ai.LookupModel(cfg, model).Cost(prompt, completion)
+		budget.Add(cost)
+		stats.Tokens(model, prompt, completion)
+
+		rec := usage.Record{
+			Model:      model,
+			Prompt:     prompt,
+			Completion: completion,
+			Cost:       cost,
+			Estimated:  estimated,
 		}
+		tally.Add(rec)
 
-		// Search for config in home directory
-		viper.AddConfigPath(home)
-		viper.SetConfigType("yaml")
-		viper.SetConfigName(".clai")
+		if !writable[cfg.DataDir] {
+			return
+		}
+		if err := usageLog.Add(rec); err != nil {
+			log.Println("[main] failed to record usage:", err)
+		}
+	})
+
+	ai.SetRequestFunc(func(model string, took time.Duration, err error) {
+		stats.Request(cfg.Provider, model, took, err)
+	})
+	tools.SetRunFunc(stats.ToolRun)
 
-		// Also check XDG config directory
-		configDir, err := os.UserConfigDir()
-		if err == nil {
-			viper.AddConfigPath(configDir + "/clai")
+	registry := tools.NewRegistry(cfg)
+	warnings := registry.RegisterPlugins()
+	warnings = append(warnings, commands.DefaultRegistry.RegisterPluginCommands(registry.PluginCommands())...)
+	loaded, errs := loadPacks(cfg, wd)
+	warnings = append(warnings, errs...)
+	warnings = append(warnings, loadProject(cfg, wd)...)
+	warnings = append(warnings, checkToolNames(cfg, registry)...)
+	// the persona can come from a pack so it is only checked once they are loaded
+	if _, ok := cfg.Personas[cfg.Persona]; cfg.Persona != "" && !ok {
+		closer()
+		return nil, nil, nil, nil, fmt.Errorf("persona %q is not in personas or the project's packs", cfg.Persona)
+	}
+	for _, p := range cfg.Warnings {
+		warnings = append(warnings, fmt.Errorf("config %s", p))
+	}
+	if len(readOnly) > 0 {
+		var lost []string
+		for _, l := range []struct {
+			dir, what string
+		}{{cfg.SessionDir, "history"}, {cfg.DataDir, "usage"}, {cfg.StateDir, "logs"}} {
+			if !writable[l.dir] {
+				lost = append(lost, l.what)
+			}
 		}
+		warnings = append(warnings, fmt.Errorf("can't write to %s, the %s of this session won't be kept: %w", strings.Join(readOnly, ", "), strings.Join(lost, ", "), readOnlyErr))
+	}
+	for _, err := range warnings {
+		log.Println("[main]", err)
 	}
 
-	// Read environment variables
-	viper.SetEnvPrefix("CLAI")
-	viper.AutomaticEnv()
+	session := chat.NewSession(cfg, aiClient, sessionID)
+	session.SetToolRegistry(registry)
+	session.SetBudget(budget)
+	session.SetTally(tally)
+	var layers []prompt.Layer
+	for _, l := range loaded {
+		layers = append(layers, l.Layer())
+	}
+	session.SetPackPrompts(layers)
+	store := history.NewStore(cfg.SessionDir, sessionID)
+	store.SetLog(sessionLogPath(cfg, sessionID))
+	session.SetHistory(store)
+	session.SetRecentModels(filepath.Join(cfg.StateDir, "recent_models"))
+	return cfg, session, warnings, closer, nil
+}
 
-	if viper.ConfigFileUsed() == "" {
-		viper.SetConfigFile(filepath.Join(os.Getenv("HOME"), ".clai.yml"))
-		if err := viper.SafeWriteConfig(); err != nil {
-			return fmt.Errorf("failed to write new config: %w", err)
+// checkToolNames warns about the tools in enabled_tools and disabled_tools
+// that don't exist, a typo would leave a tool enabled
+func checkToolNames(cfg *config.Config, registry *tools.Registry) []error {
+	names := tools.GetNames(registry.All())
+	var errs []error
+	for _, l := range []struct {
+		key   string
+		names []string
+	}{{"enabled_tools", cfg.EnabledTools}, {"disabled_tools", cfg.DisabledTools}} {
+		for _, name := range l.names {
+			if !slices.Contains(names, name) {
+				errs = append(errs, fmt.Errorf("%s has %q, there is no tool called that", l.key, name))
+			}
 		}
 	}
+	return errs
+}
+
+// checkWritable creates the dir if needed and checks that files can be
+// written in it
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".clai-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// openLogs opens clai.log, which everything is logged to, and a log of its
+// own for the session so a single run can be looked at
+func openLogs(cfg *config.Config, sessionID string) (io.Writer, func(), error) {
+	f, err := logs.Open(logPath(cfg), cfg.LogMaxSize, cfg.LogKeep)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	sf, err := openSessionLog(cfg, sessionID)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("failed to open session log: %w", err)
+	}
+	return io.MultiWriter(f, sf), func() { sf.Close(); f.Close() }, nil
+}
+
+// openDebugLog creates the file that the API requests and responses of the
+// session are written to, it holds the whole conversation so only the user
+// can read it
+func openDebugLog(cfg *config.Config, sessionID string) (*os.File, error) {
+	dir := filepath.Join(cfg.StateDir, "debug")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create debug directory: %w", err)
+	}
+
+	fn := filepath.Join(dir, sessionID+".log")
+	df, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open debug log: %w", err)
+	}
+
+	log.Println("[main] writing API requests and responses to", fn)
+	return df, nil
+}
+
+func initConfig() error {
+	// a config given with --config has to exist, the default one is
+	// created when a setting is first saved
+	explicit := cfgFile != ""
+	if !explicit {
+		cfgFile = config.DefaultFile()
+	}
+	cfgFile = strings.Replace(cfgFile, "~", os.Getenv("HOME"), 1)
+	viper.SetConfigFile(cfgFile)
+
+	// Read environment variables
+	viper.SetEnvPrefix("CLAI")
+	viper.AutomaticEnv()
 
-	// Read config file (ignore not found errors)
 	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+		if explicit || !os.IsNotExist(err) {
 			return err
 		}
+		// so settings can be saved, if the home is read-only that fails
+		// when one is saved instead
+		os.MkdirAll(filepath.Dir(cfgFile), 0755)
 	}
 
 	return nil
 }
 
+// stats are the metrics of the session, they are only served with --metrics
+var stats = metrics.New()
+
+// serveMetrics serves the metrics at /metrics in the background if an
+// address was given, the returned func stops it
+func serveMetrics(addr string) (func(), error) {
+	if addr == "" {
+		return func() {}, nil
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start metrics server: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", stats.Handler())
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go srv.Serve(ln)
+	log.Println("[main] serving metrics on", ln.Addr())
+
+	return func() { srv.Close() }, nil
+}
+
+// serveEditor starts the editor server in the background if a socket was
+// given, the returned func stops it
+func serveEditor(socket string, cfg *config.Config, session *chat.Session, send func(prompt string)) (func(), error) {
+	if socket == "" {
+		return func() {}, nil
+	}
+
+	srv, err := editor.Listen(socket, cfg, session, send)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start editor server: %w", err)
+	}
+	go srv.Serve()
+	return func() { srv.Close() }, nil
+}
+
+// oneShot sends the prompt to the session and writes the response to w, tools
+// that are not in permitted_tools are denied
+func oneShot(ctx context.Context, session *chat.Session, prompt string, w io.Writer) error {
+	h := ui.NewHeadless(w, os.Stderr)
+	session.AddObserver(h)
+	h.AddObserver(session)
+
+	go session.InteractiveMode(ctx)
+	return h.Run(ctx, prompt)
+}
+
+// notifyWebhook posts a summary of a headless run to the webhook_url, if
+// there is one
+func notifyWebhook(cfg *config.Config, session *chat.Session, task string, took time.Duration, err error) {
+	if cfg.WebhookURL == "" {
+		return
+	}
+	summary := notify.NewSummary(session.ID(), task, session.FilesChanged(), session.Cost(), took, err)
+	if err := notify.Send(cfg.WebhookURL, summary); err != nil {
+		fmt.Fprintln(os.Stderr, "WARNING: failed to send the summary to the webhook:", err)
+	}
+}
+
+// oneShotPrompt builds the prompt from the command line, anything piped to
+// stdin is added to the end of it
+func oneShotPrompt(args []string) string {
+	prompt := strings.Join(args, " ")
+
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice == 0 {
+		if data, err := io.ReadAll(os.Stdin); err == nil && len(data) > 0 {
+			prompt += "\n\n```\n" + strings.TrimRight(string(data), "\n") + "\n```"
+		}
+	}
+
+	return prompt
+}
+
 func generateSessionID() string {
-	return uuid.New().String()[:6]
+	return history.NewID()
 }
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/commands"
+	"github.com/penguinpowernz/clai/internal/packs"
+)
+
+func newPacksCommand(ctx context.Context) *cobra.Command {
+	packsCmd := &cobra.Command{
+		Use:   "packs",
+		Short: "Manage the team's command, persona and prompt packs",
+		Long: `Packs are git repos of slash commands, personas and prompts that a team
+shares, they are listed in the project's .clai.yml:
+
+  packs:
+    - url: https://github.com/acme/clai-pack.git
+      ref: v1.2.0        # tag, branch or commit to pin to
+
+They are only fetched with "clai packs update", so everyone gets the same
+version until the ref is changed.`,
+	}
+
+	packsCmd.AddCommand(&cobra.Command{
+		Use:   "update",
+		Short: "Fetch the packs and check out the refs they are pinned to",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			wd, _ := os.Getwd()
+			fn, project, err := packs.FindProject(wd)
+			if err != nil {
+				return err
+			}
+			if fn == "" {
+				return fmt.Errorf("there is no %s in this project", packs.ProjectFile)
+			}
+
+			for _, p := range project.Packs {
+				commit, err := packs.Update(ctx, cfg.DataDir, p)
+				if err != nil {
+					return fmt.Errorf("failed to update %s: %w", p.URL, err)
+				}
+				fmt.Printf("%s at %s\n", p.URL, commit)
+			}
+			return nil
+		},
+	})
+
+	return packsCmd
+}
+
+// loadPacks adds the commands and personas from the project's packs, the
+// personas in the config win over those from packs
+func loadPacks(cfg *config.Config, wd string) ([]packs.Loaded, []error) {
+	_, project, err := packs.FindProject(wd)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	loaded, errs := packs.Load(cfg.DataDir, project.Packs)
+	for _, l := range loaded {
+		errs = append(errs, commands.DefaultRegistry.RegisterPackCommands(l.Commands)...)
+
+		for name, text := range l.Personas {
+			if _, ok := cfg.Personas[name]; ok {
+				continue
+			}
+			if cfg.Personas == nil {
+				cfg.Personas = map[string]string{}
+			}
+			cfg.Personas[name] = text
+		}
+	}
+	return loaded, errs
+}
+
+// loadProject applies the settings of the project's .clai.yml, it can only
+// take tools away from those in the config.  It warns when the file lets the
+// tools see sensitive files, as that came from the project and not the user.
+func loadProject(cfg *config.Config, wd string) []error {
+	// loadPacks has already warned if the file can't be read
+	fn, project, err := packs.FindProject(wd)
+	if err != nil {
+		return nil
+	}
+
+	cfg.EnabledTools, cfg.DisabledTools = project.Tools(cfg.EnabledTools, cfg.DisabledTools)
+	cfg.AllowSensitive = project.AllowSensitive
+	if !cfg.AllowSensitive {
+		return nil
+	}
+
+	log.Println("[packs] allow_sensitive is turned on by", fn)
+	return []error{fmt.Errorf("%s turns on allow_sensitive, the tools can read .env files, keys and credentials", fn)}
+}
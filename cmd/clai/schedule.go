@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/schedule"
+)
+
+// schedulesPath is the file the schedules are kept in
+func schedulesPath(cfg *config.Config) string {
+	return filepath.Join(cfg.DataDir, "schedules.json")
+}
+
+func newScheduleCommand(ctx context.Context) *cobra.Command {
+	scheduleCmd := &cobra.Command{
+		Use:   "schedule <prompt> --cron <expr>",
+		Short: "Run a prompt headless on a schedule",
+		Long: `Run a prompt headless on a cron schedule, in the current directory.  The runs
+are started by an entry in your crontab, or a systemd user timer with --systemd,
+that calls "clai schedule run <name>".  Each run is a new session kept in the
+schedule_dir under the name of the schedule, with the response next to it in a
+.md file.  Tools that are not in permitted_tools are denied.
+
+  clai schedule "summarize yesterday's commits" --cron "0 9 * * *"`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			expr, _ := cmd.Flags().GetString("cron")
+			name, _ := cmd.Flags().GetString("name")
+			systemd, _ := cmd.Flags().GetBool("systemd")
+
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			wd, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+
+			prompt := strings.Join(args, " ")
+			s := schedule.Schedule{Name: name, Prompt: prompt, Cron: expr, Dir: wd, Timer: schedule.Cron}
+			if s.Name == "" {
+				s.Name = schedule.Name(prompt)
+			}
+			if systemd {
+				s.Timer = schedule.Systemd
+			}
+			if err := s.Validate(); err != nil {
+				return err
+			}
+
+			list, err := schedule.Load(schedulesPath(cfg))
+			if err != nil {
+				return err
+			}
+			if i := schedule.Find(list, s.Name); i >= 0 {
+				// the old timer might be the other kind
+				if err := schedule.Uninstall(list[i]); err != nil {
+					return err
+				}
+				list = append(list[:i], list[i+1:]...)
+			}
+
+			bin, err := claiPath()
+			if err != nil {
+				return err
+			}
+			if err := schedule.Install(s, bin); err != nil {
+				return err
+			}
+
+			if err := schedule.Save(schedulesPath(cfg), append(list, s)); err != nil {
+				return err
+			}
+			fmt.Printf("Scheduled %s (%s) with %s, the sessions will be kept in %s\n", s.Name, s.Cron, s.Timer, filepath.Join(cfg.ScheduleDir, s.Name))
+			return nil
+		},
+	}
+
+	scheduleCmd.Flags().String("cron", "", `when to run, as a cron expression like "0 9 * * *" or @daily`)
+	scheduleCmd.Flags().String("name", "", "name of the schedule, made from the prompt by default")
+	scheduleCmd.Flags().Bool("systemd", false, "use a systemd user timer instead of the crontab")
+	scheduleCmd.MarkFlagRequired("cron")
+
+	scheduleCmd.AddCommand(newScheduleListCommand(), newScheduleRemoveCommand(), newScheduleRunCommand(ctx))
+	return scheduleCmd
+}
+
+func newScheduleListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the scheduled prompts",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			list, err := schedule.Load(schedulesPath(cfg))
+			if err != nil {
+				return err
+			}
+
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "NAME\tCRON\tTIMER\tDIR\tPROMPT")
+			for _, s := range list {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", s.Name, s.Cron, s.Timer, s.Dir, s.Prompt)
+			}
+			return tw.Flush()
+		},
+	}
+}
+
+func newScheduleRemoveCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:     "remove <name>",
+		Aliases: []string{"rm"},
+		Short:   "Remove a scheduled prompt, the sessions of its runs are kept",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			list, err := schedule.Load(schedulesPath(cfg))
+			if err != nil {
+				return err
+			}
+
+			i := schedule.Find(list, args[0])
+			if i < 0 {
+				return fmt.Errorf("there is no schedule named %s", args[0])
+			}
+			if err := schedule.Uninstall(list[i]); err != nil {
+				return err
+			}
+			if err := schedule.Save(schedulesPath(cfg), append(list[:i], list[i+1:]...)); err != nil {
+				return err
+			}
+			fmt.Println("Removed", args[0])
+			return nil
+		},
+	}
+}
+
+func newScheduleRunCommand(ctx context.Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "run <name>",
+		Short: "Run a scheduled prompt now, this is what the timer calls",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			list, err := schedule.Load(schedulesPath(cfg))
+			if err != nil {
+				return err
+			}
+			i := schedule.Find(list, args[0])
+			if i < 0 {
+				return fmt.Errorf("there is no schedule named %s", args[0])
+			}
+			s := list[i]
+
+			if err := os.Chdir(s.Dir); err != nil {
+				return err
+			}
+
+			// the runs are kept apart from the interactive sessions
+			dir := filepath.Join(cfg.ScheduleDir, s.Name)
+			viper.Set("session_dir", dir)
+
+			cfg, session, warnings, closer, err := setupSession()
+			if err != nil {
+				return err
+			}
+			defer closer()
+
+			cfg.FollowUps = false
+			for _, err := range warnings {
+				fmt.Fprintln(os.Stderr, "WARNING:", err)
+			}
+
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+			f, err := os.Create(filepath.Join(dir, session.ID()+".md"))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			start := time.Now()
+			err = oneShot(ctx, session, s.Prompt, io.MultiWriter(f, os.Stdout))
+			notifyWebhook(cfg, session, s.Prompt, time.Since(start), err)
+			return err
+		},
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/history"
+	"github.com/penguinpowernz/clai/internal/training"
+)
+
+func newSessionsCommand() *cobra.Command {
+	sessionsCmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "List the saved chat sessions",
+		Long: `List the sessions saved in the session_dir, the most recent first, with the
+title and summary they were given after the first few exchanges.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			summaries, _ := cmd.Flags().GetBool("summary")
+
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			entries, err := history.ListDir(cfg.SessionDir)
+			if err != nil {
+				return err
+			}
+
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tMODIFIED\tMESSAGES\tTITLE")
+			for _, e := range entries {
+				title := e.Title
+				if title == "" {
+					title = "-"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", e.ID, e.Modified.Format(time.DateTime), e.Messages, title)
+				if summaries && e.Summary != "" {
+					for _, line := range strings.Split(e.Summary, "\n") {
+						fmt.Fprintf(tw, "\t\t\t  %s\n", line)
+					}
+				}
+			}
+			return tw.Flush()
+		},
+	}
+
+	sessionsCmd.Flags().BoolP("summary", "s", false, "show the summary of each session")
+	sessionsCmd.AddCommand(newSessionsExportCommand(), newSessionsTreeCommand())
+	return sessionsCmd
+}
+
+func newSessionsTreeCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "tree [session-id]",
+		Short: "Show the sessions as a tree of the branches made with /branch",
+		Long: `Show the saved sessions as a tree, each branch made with /branch under the
+session it was branched from, with the name it was given and its title.  With
+a session only the tree it is in is shown.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			entries, err := history.ListDir(cfg.SessionDir)
+			if err != nil {
+				return err
+			}
+
+			roots := history.Tree(entries)
+			if len(args) > 0 {
+				family := history.Family(roots, args[0])
+				if family == nil {
+					return fmt.Errorf("no session %s, see clai sessions", args[0])
+				}
+				roots = []*history.Node{family}
+			}
+
+			for _, root := range roots {
+				root.Walk(func(n *history.Node, prefix string) {
+					line := fmt.Sprintf("%s%s  %s", prefix, n.ID, n.Modified.Format(time.DateTime))
+					if label := n.Label(); label != "" {
+						line += "  " + label
+					}
+					fmt.Println(line)
+				})
+			}
+			return nil
+		},
+	}
+}
+
+func newSessionsExportCommand() *cobra.Command {
+	exportCmd := &cobra.Command{
+		Use:   "export [session-id...]",
+		Short: "Export sessions as fine-tuning data",
+		Long: `Write the sessions as JSONL fine-tuning data to stdout, one conversation per
+line, all of the saved sessions if none are given.  Sessions without a
+response are skipped.
+
+Formats:
+  jsonl-chat  the OpenAI chat format, tool calls are tool_calls on the
+              assistant message and their output is a tool message
+  sharegpt    the ShareGPT format, tool calls are function_call turns and
+              their output is an observation`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, _ := cmd.Flags().GetString("format")
+			var opts training.Options
+			opts.System, _ = cmd.Flags().GetString("system")
+			opts.StripPII, _ = cmd.Flags().GetBool("strip-pii")
+			if !slices.Contains(training.Formats, format) {
+				return fmt.Errorf("unknown format %q, use one of %s", format, strings.Join(training.Formats, ", "))
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			ids := args
+			if len(ids) == 0 {
+				entries, err := history.ListDir(cfg.SessionDir)
+				if err != nil {
+					return err
+				}
+				for _, e := range entries {
+					ids = append(ids, e.ID)
+				}
+			}
+
+			exported := 0
+			for _, id := range ids {
+				store := history.NewStore(cfg.SessionDir, id)
+				if _, err := os.Stat(store.Path()); err != nil {
+					return fmt.Errorf("no session %s: %w", id, err)
+				}
+				h, err := store.Load()
+				if err != nil {
+					return fmt.Errorf("failed to read session %s: %w", id, err)
+				}
+
+				line, ok, err := training.Convert(h.Context, format, opts)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					continue
+				}
+				fmt.Println(string(line))
+				exported++
+			}
+
+			fmt.Fprintf(os.Stderr, "Exported %d of %d sessions\n", exported, len(ids))
+			return nil
+		},
+	}
+
+	exportCmd.Flags().String("format", "jsonl-chat", "the format to write, one of "+strings.Join(training.Formats, ", "))
+	exportCmd.Flags().String("system", "", "a system prompt to start each conversation with")
+	exportCmd.Flags().Bool("strip-pii", false, "replace emails, IP addresses, phone numbers, secrets and user names in home dirs")
+	return exportCmd
+}
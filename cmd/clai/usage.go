@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/usage"
+)
+
+// usagePath is the file the usage of every request is recorded in
+func usagePath(cfg *config.Config) string {
+	return filepath.Join(cfg.DataDir, "usage.jsonl")
+}
+
+// newBudget creates the budget for the session, counting what has already
+// been spent today against the daily limit and the admin's caps
+func newBudget(cfg *config.Config) *usage.Budget {
+	var today float64
+	if cfg.MaxDailyCost > 0 {
+		y, m, d := time.Now().Date()
+		records, err := usage.Read(usagePath(cfg), time.Date(y, m, d, 0, 0, 0, 0, time.Local))
+		if err != nil {
+			log.Println("[main] failed to read today's usage:", err)
+		}
+		today = usage.SpentToday(records)
+	}
+	b := usage.NewBudget(cfg.MaxSessionCost, cfg.MaxDailyCost, today)
+	b.Cap(cfg.CapSessionCost, cfg.CapDailyCost)
+	return b
+}
+
+func newUsageCommand() *cobra.Command {
+	usageCmd := &cobra.Command{
+		Use:   "usage",
+		Short: "Show the tokens used and what they cost",
+		Long: `Show the tokens used by the requests to the AI and their estimated cost,
+totalled by day, model and the directory clai was started in.  Token counts
+marked with a ~ are estimates, as the provider didn't say how many were used.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sinceFlag, _ := cmd.Flags().GetString("since")
+			asCSV, _ := cmd.Flags().GetBool("csv")
+
+			since, err := usage.ParseSince(sinceFlag, time.Now())
+			if err != nil {
+				return err
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			records, err := usage.Read(usagePath(cfg), since)
+			if err != nil {
+				return err
+			}
+
+			rows := usage.Aggregate(records)
+			if asCSV {
+				return writeUsageCSV(os.Stdout, rows)
+			}
+
+			if len(rows) == 0 {
+				fmt.Println("No usage since", since.Format(time.DateOnly))
+				return nil
+			}
+			writeUsageTable(os.Stdout, rows)
+			return nil
+		},
+	}
+
+	usageCmd.Flags().String("since", "7d", "how far back to go, e.g. 24h, 7d, 2w or 2024-01-31")
+	usageCmd.Flags().Bool("csv", false, "write the report as CSV")
+	return usageCmd
+}
+
+func writeUsageTable(w io.Writer, rows []usage.Row) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DAY\tMODEL\tDIR\tREQUESTS\tPROMPT\tCOMPLETION\tCOST")
+
+	var total usage.Row
+	for _, r := range rows {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\t$%.4f\n", r.Day, r.Model, r.Dir, r.Requests, tokens(r.Prompt, r.Estimated), tokens(r.Completion, r.Estimated), r.Cost)
+
+		total.Requests += r.Requests
+		total.Prompt += r.Prompt
+		total.Completion += r.Completion
+		total.Cost += r.Cost
+		total.Estimated = total.Estimated || r.Estimated
+	}
+
+	fmt.Fprintf(tw, "TOTAL\t\t\t%d\t%s\t%s\t$%.4f\n", total.Requests, tokens(total.Prompt, total.Estimated), tokens(total.Completion, total.Estimated), total.Cost)
+	tw.Flush()
+}
+
+// tokens shows the token count, marking it if it is an estimate
+func tokens(n int, estimated bool) string {
+	if estimated {
+		return "~" + strconv.Itoa(n)
+	}
+	return strconv.Itoa(n)
+}
+
+func writeUsageCSV(w io.Writer, rows []usage.Row) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"day", "model", "dir", "requests", "prompt_tokens", "completion_tokens", "cost_usd", "estimated"})
+	for _, r := range rows {
+		cw.Write([]string{
+			r.Day, r.Model, r.Dir,
+			strconv.Itoa(r.Requests),
+			strconv.Itoa(r.Prompt),
+			strconv.Itoa(r.Completion),
+			strconv.FormatFloat(r.Cost, 'f', 6, 64),
+			strconv.FormatBool(r.Estimated),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/cobra"
+
+	"github.com/penguinpowernz/clai/internal/ui"
+	"github.com/penguinpowernz/clai/internal/watch"
+)
+
+func newWatchCommand(ctx context.Context) *cobra.Command {
+	watchCmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Send a prompt to the AI whenever files change",
+		Long: `Watch the current directory for file changes and send the --on-change prompt
+to the AI along with the list of changed files.  Files matching the exclude
+patterns or outside the scope are ignored.
+
+By default the AI runs headless and its responses are printed to stdout, tools
+that are not in permitted_tools are denied.  Use --interactive to inject the
+prompt into the interactive chat instead.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			onChange, _ := cmd.Flags().GetString("on-change")
+			debounce, _ := cmd.Flags().GetDuration("debounce")
+			interactive, _ := cmd.Flags().GetBool("interactive")
+
+			if strings.TrimSpace(onChange) == "" {
+				return fmt.Errorf("--on-change is required")
+			}
+
+			cfg, session, warnings, closer, err := setupSession()
+			if err != nil {
+				return err
+			}
+			defer closer()
+
+			wd, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+
+			w, err := watch.New(*cfg, wd, debounce)
+			if err != nil {
+				return fmt.Errorf("failed to watch %s: %w", wd, err)
+			}
+			defer w.Close()
+
+			go session.InteractiveMode(ctx)
+
+			if !interactive {
+				cfg.FollowUps = false
+				for _, err := range warnings {
+					fmt.Fprintln(os.Stderr, "WARNING:", err)
+				}
+
+				h := ui.NewHeadless(os.Stdout, os.Stderr)
+				session.AddObserver(h)
+				h.AddObserver(session)
+
+				fmt.Fprintln(os.Stderr, "Watching", wd, "for changes...")
+				return w.Run(ctx, func(files []string) {
+					fmt.Printf("\n>>> %d files changed\n", len(files))
+					if err := h.Run(ctx, changePrompt(onChange, files)); err != nil {
+						fmt.Fprintln(os.Stderr, "Error:", err)
+					}
+				})
+			}
+
+			cm := ui.NewChatModel(ctx, cfg)
+			cm.SetHistory(session.History())
+			cm.SetDraftFile(filepath.Join(cfg.StateDir, "draft"))
+			for _, err := range warnings {
+				cm.AddSystemMessage("WARNING: " + err.Error())
+			}
+			cm.AddSystemMessage("Watching for changes, the AI will be prompted with: " + onChange)
+
+			session.AddObserver(cm)
+			cm.AddObserver(session)
+
+			p := tea.NewProgram(cm, tea.WithMouseCellMotion(), tea.WithAltScreen())
+
+			wctx, stop := context.WithCancel(ctx)
+			defer stop()
+			go w.Run(wctx, func(files []string) {
+				p.Send(ui.EventInjectPrompt(changePrompt(onChange, files)))
+			})
+
+			if _, err := p.Run(); err != nil {
+				return fmt.Errorf("error running interactive mode: %w", err)
+			}
+
+			fmt.Println("Ended chat session", session.ID())
+			return nil
+		},
+	}
+
+	watchCmd.Flags().String("on-change", "", "the prompt to send to the AI when files change")
+	watchCmd.Flags().Duration("debounce", 500*time.Millisecond, "how long to wait for changes to settle before prompting")
+	watchCmd.Flags().BoolP("interactive", "i", false, "inject the prompt into the interactive chat instead of running headless")
+
+	return watchCmd
+}
+
+// changePrompt adds the list of changed files to the prompt
+func changePrompt(prompt string, files []string) string {
+	return prompt + "\n\nThese files changed:\n- " + strings.Join(files, "\n- ")
+}
@@ -1,9 +1,15 @@
 package config
 
 import (
+	"cmp"
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/ghodss/yaml"
 	"github.com/spf13/viper"
@@ -11,55 +17,391 @@ import (
 
 // Config holds all application configuration
 type Config struct {
+	ConfigVersion int `mapstructure:"config_version"` // Layout of the config file, older ones are upgraded when loaded
+
 	// AI Provider settings
-	Provider string `mapstructure:"provider"` // "openai", "ollama", "custom"
-	Model    string `mapstructure:"model"`
-	APIKey   string `mapstructure:"api_key"`
-	BaseURL  string `mapstructure:"base_url"` // Custom API endpoint (for ollama, local models, etc.)
+	Provider   string `mapstructure:"provider"` // "openai", "anthropic", "azure", "ollama", "custom"
+	Model      string `mapstructure:"model"`
+	CheapModel string `mapstructure:"cheap_model"` // Answers trivial prompts and background requests, empty to use model for everything
+	APIKey     string `mapstructure:"api_key"`
+	BaseURL    string `mapstructure:"base_url"`    // Custom API endpoint (for ollama, local models, etc.)
+	Deployment string `mapstructure:"deployment"`  // Azure deployment that serves model, defaults to the model name
+	APIVersion string `mapstructure:"api_version"` // Azure API version
+
+	Fallbacks       []Fallback    `mapstructure:"fallbacks"`        // Providers to switch to in order when the one before fails
+	FailoverTimeout time.Duration `mapstructure:"failover_timeout"` // How long a provider has to start answering before the next is tried, 0 to wait
 
 	// Prompt settings
-	SystemPrompt string `mapstructure:"system_prompt"` // Custom system prompt
+	SystemPrompt  string            `mapstructure:"system_prompt"`  // Custom system prompt, replaces the built-in one
+	ProjectPrompt string            `mapstructure:"project_prompt"` // File in the project that is added to the system prompt, empty for none
+	Persona       string            `mapstructure:"persona"`        // Name of the persona to add to the system prompt
+	Personas      map[string]string `mapstructure:"personas"`       // Extra instructions for the system prompt, keyed by name
+	Style         Style             `mapstructure:"style"`          // How the responses are written, added to the system prompt
 
 	// Behavior settings
-	AutoApply    bool    `mapstructure:"auto_apply"`    // Auto-apply code changes
-	ShowThinking bool    `mapstructure:"show_thinking"` // Show thinking indicator
-	ContextFiles int     `mapstructure:"context_files"` // Max files to include
-	MaxTokens    int     `mapstructure:"max_tokens"`    // Max tokens per request
-	Temperature  float64 `mapstructure:"temperature"`   // Model temperature
+	AutoApply    bool          `mapstructure:"auto_apply"`    // Auto-apply code changes
+	ShowThinking bool          `mapstructure:"show_thinking"` // Show thinking indicator
+	FollowUps    bool          `mapstructure:"follow_ups"`    // Suggest follow-up prompts after each response
+	AutoTitle    bool          `mapstructure:"auto_title"`    // Give the session a title and summary after the first few exchanges
+	ShowMetadata bool          `mapstructure:"show_metadata"` // Show the time, model and tokens of each message
+	ThinkTags    []string      `mapstructure:"think_tags"`    // Tags that models wrap their reasoning in, empty to leave it in the response
+	BannedOutput []string      `mapstructure:"banned_output"` // Patterns that stop the response when the model writes them
+	PostProcess  []string      `mapstructure:"post_process"`  // Steps applied to responses before they are shown and saved
+	Replacements []Replacement `mapstructure:"replacements"`  // Regex replacements made to responses by the replace step
+	ContextFiles int           `mapstructure:"context_files"` // Max files to include
+	MaxTokens    int           `mapstructure:"max_tokens"`    // Max tokens per request
+	AutoContinue int           `mapstructure:"auto_continue"` // Times to continue a response cut off at max_tokens without asking
+	StallTimeout time.Duration `mapstructure:"stall_timeout"` // Stop a response if the provider sends nothing for this long, 0 to wait forever
+	WarmUp       bool          `mapstructure:"warm_up"`       // Load the model when the session starts (Ollama)
+	KeepAlive    string        `mapstructure:"keep_alive"`    // How long Ollama keeps the model loaded after a request, e.g. 30m
+	NumCtx       int           `mapstructure:"num_ctx"`       // Context window Ollama loads models with, 0 for Ollama's default
+	StreamUsage  bool          `mapstructure:"stream_usage"`  // Ask OpenAI compatible servers for the token counts at the end of the stream, always done for OpenAI
+	Temperature  float64       `mapstructure:"temperature"`   // Model temperature
+	Constrain    Constrain     `mapstructure:"constrain"`     // Hold the responses to JSON, a JSON schema or a grammar (Ollama, llama.cpp)
+	Retry        Retry         `mapstructure:"retry"`         // Retrying requests the provider failed with a 429, 5xx or dropped connection
+
+	// Reproducible responses, for debugging prompt changes
+	Seed          int  `mapstructure:"seed"`          // Passed to providers that take one, 0 for none
+	Deterministic bool `mapstructure:"deterministic"` // Temperature 0 and the seed (or DeterministicSeed), /deterministic turns it on and off
+
+	// Spending limits in USD, 0 for none
+	MaxSessionCost float64 `mapstructure:"max_session_cost"` // Paid requests need approval once a session has cost this much
+	MaxDailyCost   float64 `mapstructure:"max_daily_cost"`   // Paid requests need approval once this much has been spent today
 
 	// UI settings
-	Verbose bool   `mapstructure:"verbose"` // Verbose logging
-	Editor  string `mapstructure:"editor"`  // Preferred editor
+	Verbose    bool   `mapstructure:"verbose"`      // Verbose logging
+	LogMaxSize int64  `mapstructure:"log_max_size"` // Bytes the log can grow to before it is rotated, 0 to never rotate
+	LogKeep    int    `mapstructure:"log_keep"`     // Rotated logs to keep
+	DebugAPI   bool   `mapstructure:"debug_api"`    // Log the full API requests and responses to a file per session
+	Editor     string `mapstructure:"editor"`       // Preferred editor
+	WrapWidth  int    `mapstructure:"wrap_width"`   // Max width of the transcript, 0 for the full terminal width
+	PasteLines int    `mapstructure:"paste_lines"`  // Pastes longer than this are attached to the prompt instead of going in it, 0 to never
+	NoColor    bool   `mapstructure:"no_color"`     // Turn off colors
+	Accessible bool   `mapstructure:"accessible"`   // Plain line based UI for screen readers
+	Locale     string `mapstructure:"locale"`       // Language of the UI, empty to use $LANG
+	LocaleDir  string `mapstructure:"locale_dir"`   // Where to load extra translations from, defaults to the locales in the config dir
 
 	// File handling
 	ExcludePatterns []string `mapstructure:"exclude_patterns"` // Files/dirs to exclude
-	IncludeHidden   bool     `mapstructure:"include_hidde n"`  // Include hidden files
+	IncludeHidden   bool     `mapstructure:"include_hidden"`   // Show dotfiles and dot directories to list_files, find, grep, search_files and /add
 	MaxFileSize     int64    `mapstructure:"max_file_size"`    // Max file size in bytes
+	MaxFileTokens   int      `mapstructure:"max_file_tokens"`  // Files bigger than this are shown as an outline, 0 to always show them whole
+	SuggestFiles    string   `mapstructure:"suggest_files"`    // Files the prompt looks to be about: "ask" to add them, "auto" to add the best one or "off"
 	PermittedTools  []string `mapstructure:"permitted_tools"`  // Tools to allow
+	EnabledTools    []string `mapstructure:"enabled_tools"`    // The only tools the AI is told about, nil for all
+	DisabledTools   []string `mapstructure:"disabled_tools"`   // Tools the AI is never told about
+	ParallelTools   bool     `mapstructure:"parallel_tools"`   // Run the tool calls from a response at the same time once they are allowed
+	Scope           []string `mapstructure:"scope"`            // Only these directories are visible, empty for everything
+
+	// Sandbox settings
+	Sandbox      string `mapstructure:"sandbox"`       // Run tools in a container: "docker", "podman" or "" for none
+	SandboxImage string `mapstructure:"sandbox_image"` // Container image to run tools in
+
+	// Directories, empty for the XDG defaults
+	DataDir  string `mapstructure:"data_dir"`  // Sessions, usage and plugins ($XDG_DATA_HOME/clai)
+	StateDir string `mapstructure:"state_dir"` // Logs, debug logs and recent models ($XDG_STATE_HOME/clai)
+	CacheDir string `mapstructure:"cache_dir"` // Downloaded data like the tokenizer files ($XDG_CACHE_HOME/clai)
 
 	// Session settings
-	SessionDir     string `mapstructure:"session_dir"`      // Where to store sessions
+	SessionDir     string `mapstructure:"session_dir"`      // Where to store sessions, defaults to the sessions in the data_dir
+	ScheduleDir    string `mapstructure:"schedule_dir"`     // Where scheduled runs store their sessions, defaults to scheduled in the data_dir
 	SaveHistory    bool   `mapstructure:"save_history"`     // Save conversation history
 	MaxHistorySize int    `mapstructure:"max_history_size"` // Max messages to keep
 
-	PluginDir string `mapstructure:"plugin_dir"`
+	PluginDir    string       `mapstructure:"plugin_dir"`    // Defaults to the plugins in the data_dir
+	PluginPrefix string       `mapstructure:"plugin_prefix"` // Prefix added to plugin tool names to avoid collisions
+	PluginLimits PluginLimits `mapstructure:"plugin_limits"` // Sandbox settings for running plugins
+
+	// Model overrides, keyed by model name
+	Models map[string]ModelSpec `mapstructure:"models"`
+
+	// Ways to read the streamed responses of servers that don't quite speak
+	// the OpenAI API, keyed by provider
+	Adapters map[string]Adapter `mapstructure:"adapters"`
+
+	Forge Forge `mapstructure:"forge"` // Access to the GitHub/GitLab API for fetching issues
+
+	Share Share `mapstructure:"share"` // Where /share makes the session available
+
+	WebhookURL string `mapstructure:"webhook_url"` // Posted a summary when a one-shot run finishes or fails, Slack compatible
+
+	Warnings       []Problem `mapstructure:"-" json:"-"` // Unknown keys found in the config file when it was loaded
+	AllowedTools   []string  `mapstructure:"-" json:"-"` // The only tools that can be used, set by the policy, nil for all
+	AllowSensitive bool      `mapstructure:"-" json:"-"` // Let the tools see .env, keys and credentials, set by the project's .clai.yml
+
+	// The admin's spending caps in USD from the policy, unlike the user's
+	// own limits they can't be approved past, 0 for none
+	CapSessionCost float64 `mapstructure:"-" json:"-"`
+	CapDailyCost   float64 `mapstructure:"-" json:"-"`
+}
+
+// Fallback is a provider to switch to when the ones before it fail, the
+// rest of the settings are the same as the main provider's
+type Fallback struct {
+	Provider   string `mapstructure:"provider"`
+	Model      string `mapstructure:"model"`
+	APIKey     string `mapstructure:"api_key"`     // Defaults to the provider's environment variable
+	BaseURL    string `mapstructure:"base_url"`    // Defaults to the provider's usual endpoint
+	Deployment string `mapstructure:"deployment"`  // Azure deployment, defaults to the model name
+	APIVersion string `mapstructure:"api_version"` // Azure API version, defaults to the main one
+}
+
+// ForFallback returns a copy of the config that talks to the fallback
+// instead of the main provider
+func (c *Config) ForFallback(f Fallback) *Config {
+	fc := *c
+	fc.Provider, fc.Model, fc.APIKey, fc.BaseURL, fc.Deployment = f.Provider, f.Model, f.APIKey, f.BaseURL, f.Deployment
+	fc.CheapModel = ""
+	fc.Fallbacks = nil
+	if f.APIVersion != "" {
+		fc.APIVersion = f.APIVersion
+	}
+	if fc.APIKey == "" {
+		fc.APIKey = envAPIKey(fc.Provider)
+	}
+	if fc.BaseURL == "" {
+		fc.BaseURL = getDefaultBaseURL(fc.Provider)
+	}
+	return &fc
+}
+
+// Forge holds the settings for talking to the API of the git forge that the
+// repo is hosted on, tokens are optional for public repos
+type Forge struct {
+	GitHubToken string   `mapstructure:"github_token" json:"-"` // Falls back to $GITHUB_TOKEN, $GH_TOKEN or `gh auth token`
+	GitLabToken string   `mapstructure:"gitlab_token" json:"-"` // Falls back to $GITLAB_TOKEN
+	GitLabHosts []string `mapstructure:"gitlab_hosts"`          // Self hosted GitLab servers, gitlab.com is always known
+}
+
+// Style is how the responses should be written, it is turned into a layer of
+// the system prompt and can be changed for the session with /style
+type Style struct {
+	Language     string `mapstructure:"language"`      // Always answer in this language, e.g. Japanese
+	NoEmoji      bool   `mapstructure:"no_emoji"`      // Don't use emoji
+	Concise      bool   `mapstructure:"concise"`       // Keep the answers short
+	CodeComments bool   `mapstructure:"code_comments"` // Always comment the code that is written
+}
+
+// Prompt returns the instructions for the style, empty if nothing is set
+func (s Style) Prompt() string {
+	var lines []string
+	if s.Language != "" {
+		lines = append(lines, fmt.Sprintf("- Always answer in %s, whatever language the question is asked in. Code, identifiers and commands stay as they are.", s.Language))
+	}
+	if s.NoEmoji {
+		lines = append(lines, "- Don't use emoji.")
+	}
+	if s.Concise {
+		lines = append(lines, "- Be concise: answer in as few words as needed, skip the preamble and summaries.")
+	}
+	if s.CodeComments {
+		lines = append(lines, "- Always include comments in the code you write, explaining what it does and why.")
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "How to write your responses:\n" + strings.Join(lines, "\n")
+}
+
+// DeterministicSeed is the seed sent in deterministic mode when none is set
+const DeterministicSeed = 42
+
+// Sampling returns the temperature and seed to send with requests, nil leaves
+// them to the provider.  A temperature of 0 is left to the provider too,
+// except in deterministic mode.
+func (c *Config) Sampling() (temperature *float64, seed *int) {
+	t, s := c.Temperature, c.Seed
+	if c.Deterministic {
+		t = 0
+		temperature = &t
+		if s == 0 {
+			s = DeterministicSeed
+		}
+	} else if t != 0 {
+		temperature = &t
+	}
+
+	if s != 0 {
+		seed = &s
+	}
+	return temperature, seed
+}
+
+// Constrain holds the output of the model to a format, for getting results
+// a program can read from small local models.  Ollama takes JSON and JSON
+// schemas, llama.cpp's server also takes grammars.  /constrain changes it
+// for the session.
+type Constrain struct {
+	JSON    bool   `mapstructure:"json"`    // Any JSON
+	Schema  string `mapstructure:"schema"`  // JSON matching this schema, or the file it is in
+	Grammar string `mapstructure:"grammar"` // Text matching this GBNF grammar, or the file it is in
+}
+
+// IsSet returns true if the output is held to a format
+func (c Constrain) IsSet() bool {
+	return c.JSON || c.Schema != "" || c.Grammar != ""
+}
+
+func (c Constrain) String() string {
+	describe := func(what, value string) string {
+		if c.inline(value) {
+			return what + " given"
+		}
+		return what + " in " + value
+	}
+
+	switch {
+	case c.Schema != "":
+		return "JSON matching the " + describe("schema", c.Schema)
+	case c.JSON:
+		return "any JSON"
+	case c.Grammar != "":
+		return "text matching the " + describe("grammar", c.Grammar)
+	}
+	return "off"
+}
+
+// inline returns true if the schema or grammar is given rather than the file
+// it is in
+func (c Constrain) inline(value string) bool {
+	return strings.HasPrefix(strings.TrimSpace(value), "{") || strings.Contains(value, "::=")
+}
+
+// Load returns the schema and grammar, reading them from their files when
+// they aren't given inline, relative paths are from dir
+func (c Constrain) Load(dir string) (schema json.RawMessage, grammar string, err error) {
+	read := func(value string) (string, error) {
+		if value == "" || c.inline(value) {
+			return value, nil
+		}
+		fn := expandHome(value)
+		if !filepath.IsAbs(fn) {
+			fn = filepath.Join(dir, fn)
+		}
+		data, err := os.ReadFile(fn)
+		return string(data), err
+	}
+
+	text, err := read(c.Schema)
+	if err != nil {
+		return nil, "", fmt.Errorf("reading the schema: %w", err)
+	}
+	if text != "" {
+		if !json.Valid([]byte(text)) {
+			return nil, "", fmt.Errorf("the schema isn't valid JSON")
+		}
+		schema = json.RawMessage(text)
+	}
+
+	grammar, err = read(c.Grammar)
+	if err != nil {
+		return nil, "", fmt.Errorf("reading the grammar: %w", err)
+	}
+	return schema, grammar, nil
+}
+
+// Check returns an error if the provider can't hold the responses to the
+// format, only llama.cpp's server takes grammars and the OpenAI API refuses
+// requests with one
+func (c Constrain) Check(provider string) error {
+	if c.Grammar != "" && (provider == "openai" || provider == "azure") {
+		return fmt.Errorf("the %s provider doesn't take grammars, only llama.cpp's server with the custom provider does, use a schema instead", provider)
+	}
+	return nil
+}
+
+// Share says how /share lets other people see the session, it is served live
+// unless a paste service is given
+type Share struct {
+	Addr     string `mapstructure:"addr"`      // Address to serve the session on, e.g. :8080, defaults to any free port
+	PasteURL string `mapstructure:"paste_url"` // Paste service to send the session to instead, e.g. https://paste.rs
+}
+
+// PostProcessSteps are the steps that post_process can have, in the order
+// they are described in the docs
+var PostProcessSteps = []string{"strip_think", "fences", "relative_paths", "replace"}
+
+// Replacement replaces the text in responses that matches Pattern, with $1
+// and the like for the groups in it
+type Replacement struct {
+	Pattern string `mapstructure:"pattern"`
+	With    string `mapstructure:"with"`
+}
+
+// Adapter reads the responses of an OpenAI compatible server that puts the
+// content somewhere else or escapes it.  The paths are dotted JSON paths into
+// each streamed chunk, like choices.0.delta.text, empty for the usual place.
+type Adapter struct {
+	Data           []Replacement `mapstructure:"data"`            // Made to each chunk before it is parsed, e.g. to unwrap it
+	Content        string        `mapstructure:"content"`         // Path to the text of the response
+	Reasoning      string        `mapstructure:"reasoning"`       // Path to the reasoning
+	FinishReason   string        `mapstructure:"finish_reason"`   // Path to why the response stopped
+	ContentReplace []Replacement `mapstructure:"content_replace"` // Made to the text of each chunk, e.g. to unescape it
+}
+
+// Retry says how requests that failed in a way that might go away are tried
+// again, the delay doubles after each try up to MaxBackoff
+type Retry struct {
+	MaxAttempts int           `mapstructure:"max_attempts"` // Tries including the first, 1 to never retry
+	Backoff     time.Duration `mapstructure:"backoff"`      // Delay before the first retry
+	MaxBackoff  time.Duration `mapstructure:"max_backoff"`  // Longest delay between tries
+	Jitter      float64       `mapstructure:"jitter"`       // Fraction of the delay it can be randomly moved by, 0 to 1
+}
+
+// PluginLimits restricts what plugin executables can do, a zero value means
+// no limit
+type PluginLimits struct {
+	Timeout     time.Duration `mapstructure:"timeout"`      // Max wall clock time per call
+	CPUSeconds  int           `mapstructure:"cpu_seconds"`  // Max CPU time per call (rlimit)
+	MemoryMB    int           `mapstructure:"memory_mb"`    // Max virtual memory (rlimit)
+	Env         []string      `mapstructure:"env"`          // Extra environment variables to pass through
+	DenyNetwork bool          `mapstructure:"deny_network"` // Run plugins in a new network namespace (Linux)
+}
+
+// ModelSpec overrides the built-in capabilities of a model, unset fields
+// fall back to the built-in values
+type ModelSpec struct {
+	ContextWindow int            `mapstructure:"context_window"` // Max tokens the model can see
+	Tools         *bool          `mapstructure:"tools"`          // Supports function calling
+	Vision        *bool          `mapstructure:"vision"`         // Supports image input
+	InputPrice    *float64       `mapstructure:"input_price"`    // USD per million prompt tokens
+	OutputPrice   *float64       `mapstructure:"output_price"`   // USD per million completion tokens
+	Stop          []string       `mapstructure:"stop"`           // Sequences that end the response
+	Options       map[string]any `mapstructure:"options"`        // Passed to Ollama with each request, e.g. top_k or num_gpu
 }
 
 func Default() *Config {
 	return &Config{
 		// Defaults
-		Provider:     "ollama",
-		Model:        "gpt-oss:latest",
-		APIKey:       "",
-		BaseURL:      "", // Will be set based on provider if empty
-		SystemPrompt: getDefaultSystemPrompt(),
-		AutoApply:    false,
-		ContextFiles: 5,
-		MaxTokens:    4096,
-		Temperature:  0.7,
+		ConfigVersion: Version,
+		Provider:      "ollama",
+		Model:         "gpt-oss:latest",
+		APIKey:        "",
+		BaseURL:       "", // Will be set based on provider if empty
+		APIVersion:    "2024-10-21",
+		SystemPrompt:  DefaultSystemPrompt(),
+		ProjectPrompt: "CLAI.md",
+		AutoApply:     false,
+		ContextFiles:  5,
+		MaxTokens:     4096,
+		StallTimeout:  2 * time.Minute,
+		Temperature:   0.7,
+		Retry: Retry{
+			MaxAttempts: 3,
+			Backoff:     time.Second,
+			MaxBackoff:  30 * time.Second,
+			Jitter:      0.2,
+		},
 		Verbose:      false,
+		LogMaxSize:   5 * 1024 * 1024,
+		LogKeep:      3,
 		ShowThinking: true,
+		FollowUps:    true,
+		AutoTitle:    true,
+		ThinkTags:    []string{"think", "reasoning", "thought"},
 		Editor:       getDefaultEditor(),
+		WrapWidth:    120,
+		PasteLines:   10,
 		ExcludePatterns: []string{
 			"node_modules/",
 			".git/",
@@ -71,11 +413,17 @@ func Default() *Config {
 		},
 		IncludeHidden:  false,
 		MaxFileSize:    1024 * 1024, // 1MB
-		SessionDir:     "~/.clai",
+		MaxFileTokens:  4000,
+		SuggestFiles:   "ask",
+		DataDir:        defaultDataDir(),
+		StateDir:       defaultStateDir(),
+		CacheDir:       defaultCacheDir(),
 		SaveHistory:    true,
 		MaxHistorySize: 100,
 		PermittedTools: []string{"list_files", "search_file"},
-		PluginDir:      "~/.clai/plugins",
+		PluginLimits: PluginLimits{
+			Timeout: time.Minute,
+		},
 	}
 }
 
@@ -83,19 +431,66 @@ func Default() *Config {
 func Load() (*Config, error) {
 	cfg := Default()
 
+	// old layouts of the config are upgraded, and viper skips over keys it
+	// doesn't know and gives vague errors for the wrong types, so check the
+	// file first
+	if fn := viper.ConfigFileUsed(); fn != "" {
+		notes, err := Migrate(fn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upgrade config: %w", err)
+		}
+		if len(notes) > 0 {
+			cfg.Warnings = append(cfg.Warnings, notes...)
+			if err := viper.ReadInConfig(); err != nil {
+				return nil, err
+			}
+		}
+
+		problems, err := CheckFile(fn)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read config: %w", err)
+		}
+
+		var errs []string
+		for _, p := range problems {
+			if p.Unknown {
+				cfg.Warnings = append(cfg.Warnings, p)
+				continue
+			}
+			errs = append(errs, p.String())
+		}
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("%s has mistakes:\n  %s", fn, strings.Join(errs, "\n  "))
+		}
+	}
+
 	// Unmarshal viper config into struct
 	if err := viper.Unmarshal(cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
-	// Replace ~ with home directory
-	cfg.SessionDir = strings.Replace(cfg.SessionDir, "~", os.Getenv("HOME"), 1)
+	// Replace ~ with home directory and fill in the dirs that weren't set,
+	// then move the files over from an old install
+	cfg.resolveDirs()
+	cfg.Warnings = append(cfg.Warnings, cfg.moveLegacyFiles()...)
+
+	// Scope directories are relative to where clai is started
+	wd, _ := os.Getwd()
+	for i, dir := range cfg.Scope {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(wd, dir)
+		}
+		cfg.Scope[i] = filepath.Clean(dir)
+	}
+
+	// https://no-color.org
+	if os.Getenv("NO_COLOR") != "" {
+		cfg.NoColor = true
+	}
 
 	// Load API key from environment if not in config
 	if cfg.APIKey == "" {
-		if cfg.APIKey == "" && cfg.Provider == "openai" {
-			cfg.APIKey = os.Getenv("OPENAI_API_KEY")
-		}
+		cfg.APIKey = envAPIKey(cfg.Provider)
 	}
 
 	// Set default base URLs if not specified
@@ -108,6 +503,16 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
+	// the policy of a shared machine wins over what the user asked for
+	policy, err := LoadPolicy(PolicyFile, currentUser())
+	if err != nil {
+		return nil, err
+	}
+	if policy != nil {
+		wd, _ := os.Getwd()
+		cfg.ApplyPolicy(*policy, wd)
+	}
+
 	return cfg, nil
 }
 
@@ -115,11 +520,23 @@ func Load() (*Config, error) {
 func (c *Config) Validate() error {
 	// API key not required for local models like Ollama
 	if c.APIKey == "" && c.Provider != "ollama" && c.Provider != "custom" {
+		switch c.Provider {
+		case "anthropic":
+			return fmt.Errorf("API key not found. Set ANTHROPIC_API_KEY environment variable")
+		case "azure":
+			return fmt.Errorf("API key not found. Set AZURE_OPENAI_API_KEY environment variable")
+		}
 		return fmt.Errorf("API key not found. Set OPENAI_API_KEY environment variable")
 	}
 
-	if c.Provider != "openai" && c.Provider != "ollama" && c.Provider != "custom" {
-		return fmt.Errorf("invalid provider: %s (must be 'openai', 'ollama', or 'custom')", c.Provider)
+	switch c.Provider {
+	case "openai", "anthropic", "azure", "ollama", "custom":
+	default:
+		return fmt.Errorf("invalid provider: %s (must be 'openai', 'anthropic', 'azure', 'ollama', or 'custom')", c.Provider)
+	}
+
+	if c.Provider == "azure" && c.APIVersion == "" {
+		return fmt.Errorf("api_version not specified")
 	}
 
 	if c.Model == "" {
@@ -130,17 +547,110 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("base_url not specified")
 	}
 
+	for i, f := range c.Fallbacks {
+		if err := c.ForFallback(f).Validate(); err != nil {
+			return fmt.Errorf("fallbacks[%d]: %w", i, err)
+		}
+	}
+
+	if c.FailoverTimeout < 0 {
+		return fmt.Errorf("failover_timeout must be >= 0")
+	}
+
 	if c.ContextFiles < 0 {
 		return fmt.Errorf("context_files must be >= 0")
 	}
 
+	if c.NumCtx < 0 {
+		return fmt.Errorf("num_ctx must be >= 0")
+	}
+
+	if c.MaxFileTokens < 0 {
+		return fmt.Errorf("max_file_tokens must be >= 0")
+	}
+
+	if !slices.Contains([]string{"ask", "auto", "off"}, c.SuggestFiles) {
+		return fmt.Errorf("invalid suggest_files: %s (must be 'ask', 'auto' or 'off')", c.SuggestFiles)
+	}
+
+	if c.WrapWidth < 0 {
+		return fmt.Errorf("wrap_width must be >= 0")
+	}
+
+	if c.PasteLines < 0 {
+		return fmt.Errorf("paste_lines must be >= 0")
+	}
+
+	if c.StallTimeout < 0 {
+		return fmt.Errorf("stall_timeout must be >= 0")
+	}
+
+	if c.LogMaxSize < 0 || c.LogKeep < 0 {
+		return fmt.Errorf("log_max_size and log_keep must be >= 0")
+	}
+
+	if c.AutoContinue < 0 {
+		return fmt.Errorf("auto_continue must be >= 0")
+	}
+
+	if c.Retry.MaxAttempts < 0 || c.Retry.Backoff < 0 || c.Retry.MaxBackoff < 0 {
+		return fmt.Errorf("retry.max_attempts, retry.backoff and retry.max_backoff must be >= 0")
+	}
+
+	if c.Retry.Jitter < 0 || c.Retry.Jitter > 1 {
+		return fmt.Errorf("retry.jitter must be between 0 and 1")
+	}
+
+	if c.MaxSessionCost < 0 || c.MaxDailyCost < 0 {
+		return fmt.Errorf("max_session_cost and max_daily_cost must be >= 0")
+	}
+
+	for _, p := range c.BannedOutput {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("invalid banned_output pattern %q: %w", p, err)
+		}
+	}
+
+	for _, step := range c.PostProcess {
+		if !slices.Contains(PostProcessSteps, step) {
+			return fmt.Errorf("invalid post_process step: %s (must be one of %s)", step, strings.Join(PostProcessSteps, ", "))
+		}
+	}
+
+	for _, r := range c.Replacements {
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return fmt.Errorf("invalid replacements pattern %q: %w", r.Pattern, err)
+		}
+	}
+
+	for provider, a := range c.Adapters {
+		for _, r := range slices.Concat(a.Data, a.ContentReplace) {
+			if _, err := regexp.Compile(r.Pattern); err != nil {
+				return fmt.Errorf("invalid pattern %q in adapters.%s: %w", r.Pattern, provider, err)
+			}
+		}
+	}
+
 	if c.MaxTokens < 1 {
 		return fmt.Errorf("max_tokens must be > 0")
 	}
 
+	if c.Sandbox != "" && c.Sandbox != "docker" && c.Sandbox != "podman" {
+		return fmt.Errorf("invalid sandbox: %s (must be 'docker', 'podman' or empty)", c.Sandbox)
+	}
+
 	return nil
 }
 
+// ToolEnabled returns false if enabled_tools or disabled_tools keep the tool
+// from the AI
+func (c *Config) ToolEnabled(name string) bool {
+	if c.EnabledTools != nil && !slices.Contains(c.EnabledTools, name) {
+		return false
+	}
+	return !slices.Contains(c.DisabledTools, name)
+}
+
 func (c *Config) String() string {
 	data, _ := yaml.Marshal(c)
 	return string(data)
@@ -156,12 +666,7 @@ func (c *Config) Set(v string, value interface{}) {
 
 // Initialize creates a default config file
 func Initialize() error {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return err
-	}
-
-	configPath := home + "/.clai.yaml"
+	configPath := filepath.Join(ConfigDir(), "config.yml")
 
 	// Check if config already exists
 	if _, err := os.Stat(configPath); err == nil {
@@ -170,36 +675,105 @@ func Initialize() error {
 
 	// Create default config
 	defaultConfig := `# AI Code Assistant Configuration
+config_version: %d # Layout of this file, older ones are upgraded when loaded
 
-# AI Provider (openai, ollama, or custom)
+# AI Provider (anthropic, openai, azure, ollama, or custom)
 provider: ollama
 model: gpt-oss:latest
+# cheap_model: qwen3:4b  # Answers short questions and follow-up suggestions, model is used for code
 
 # Base URL for API endpoint (optional - defaults set per provider)
 # For Ollama: http://localhost:11434/
 # For custom OpenAI-compatible APIs
 # base_url: http://localhost:11434/
 
+# Azure OpenAI, base_url is the endpoint of the resource like https://my-resource.openai.azure.com
+# deployment: my-gpt-4o   # The deployment that serves model, defaults to the model name
+# api_version: 2024-10-21
+
 # API Key (or use environment variable)
 # Not required for Ollama or local models
 # api_key: your-api-key-here
 
+# Providers to switch to, in order, when the one before fails or doesn't start
+# answering within failover_timeout
+# fallbacks:
+#   - provider: openai
+#     model: gpt-4o-mini   # api_key and base_url default to the provider's usual ones
+# failover_timeout: 30s
+
 # System Prompt (optional - uses default if not set)
 # Customize the AI's behavior and personality
 # system_prompt: |
 #   You are an expert coding assistant...
 
+# Added to the system prompt after the one above
+# project_prompt: CLAI.md  # Found in the working dir or above it, up to the root of the repo
+# persona: reviewer        # One of the personas below, also set by --persona
+# personas:
+#   reviewer: Review the code for bugs and style problems, don't rewrite it.
+# style:                   # How the responses are written, change it for the session with /style
+#   language: Japanese     # Always answer in this language
+#   no_emoji: true
+#   concise: true
+#   code_comments: true
+
 # Behavior
 auto_apply: false      # Automatically apply code changes
 show_thinking: true    # Show thinking animation
+follow_ups: true       # Suggest follow-up prompts after each response
+auto_title: true       # Give the session a title and summary after the first few exchanges
+show_metadata: false   # Show the time, model and tokens of each message
+think_tags:            # Tags that models wrap their reasoning in, [] to leave it in the response
+  - think
+  - reasoning
+  - thought
+# banned_output:       # Stop the response when the model writes one of these regexes
+#   - '<tool_call>'
+# post_process:        # Applied to responses before they are shown and saved, in the order given
+#   - strip_think      # Remove reasoning left in the response, like a </think> with no <think>
+#   - fences           # Turn ~~~ fences into backtick ones and tidy the language after them
+#   - relative_paths   # Make paths in the working dir relative to it
+#   - replace          # Make the replacements below
+# replacements:
+#   - pattern: '\bcolour\b'
+#     with: color
 context_files: 5       # Max files to include in context
 max_tokens: 4096       # Max tokens per request
+auto_continue: 0       # Times to continue a response cut off at max_tokens without asking, 0 to use /continue
+stall_timeout: 2m      # Stop a response if the provider sends nothing for this long, 0 to wait forever
+warm_up: false         # Load the model when the session starts (Ollama)
+# keep_alive: 30m      # How long Ollama keeps the model loaded after a request
+# num_ctx: 16384       # Context window Ollama loads models with, the conversation is trimmed to fit it
+# stream_usage: true   # Ask OpenAI compatible servers (azure, custom) for the token counts, OpenAI is always asked
 temperature: 0.7       # Model temperature (0.0 - 1.0)
+# seed: 42             # Passed to providers that take one (OpenAI, Ollama, llama.cpp) so responses can be reproduced
+deterministic: false   # Temperature 0 and the seed (42 if there isn't one), /deterministic turns it on and off
+# constrain:           # Hold the responses to a format, change it for the session with /constrain
+#   json: true         # Any JSON (Ollama, llama.cpp)
+#   schema: out.json   # JSON matching this schema, inline or the file it is in (Ollama, llama.cpp)
+#   grammar: out.gbnf  # Text matching this GBNF grammar, inline or the file it is in (llama.cpp)
+max_session_cost: 0    # USD a session can spend before paid requests need approval, 0 for no limit
+max_daily_cost: 0      # USD that can be spent in a day before paid requests need approval, 0 for no limit
+retry:                 # Try requests again when the provider answers 429 or 5xx, or drops the connection
+  max_attempts: 3      # Tries including the first, 1 to never retry
+  backoff: 1s          # Delay before the first retry, it doubles after each one
+  max_backoff: 30s     # Longest delay between tries
+  jitter: 0.2          # Move the delay by up to this fraction of it at random
 
 
 # UI
 verbose: false         # Verbose logging
+log_max_size: 5242880  # Bytes the log can grow to before it is rotated (5MB), 0 to never rotate
+log_keep: 3            # Rotated logs to keep
+debug_api: false       # Log the full API requests and responses, or use --debug-api
 editor: vim            # Preferred editor
+wrap_width: 120        # Max width of the transcript, 0 for the full terminal width
+paste_lines: 10        # Pastes longer than this are attached to the prompt instead of going in it, 0 to never
+no_color: false        # Turn off colors, or use --no-color or set NO_COLOR
+accessible: false      # Plain line based UI for screen readers, or use --accessible
+# locale: de           # Language of the UI (en, de, es), defaults to $LANG
+# locale_dir: ~/.config/clai/locales # Extra translations, as <locale>.json files
 
 # File handling
 exclude_patterns:
@@ -211,19 +785,81 @@ exclude_patterns:
   - dist/
   - build/
 
-include_hidden: false  # Include hidden files
+include_hidden: false  # List, search and /add dotfiles and dot directories (they can still be read by path)
 max_file_size: 1048576 # Max file size in bytes (1MB)
+max_file_tokens: 4000  # Files bigger than this are shown as an outline, the AI reads the parts it needs
+suggest_files: ask     # Ask to add the files a prompt looks to be about, "auto" adds the best match, "off" to not look
+# scope:               # Only let the AI see these directories (for monorepos)
+#   - services/api
+#   - libs/auth
 
 # Session
 permitted_tools: # Permitted tools
 	- list_files
 	- search_file
-session_dir: .clai   # Where to store session data
+# enabled_tools: [list_files, read_file, grep] # Only tell the AI about these tools
+# disabled_tools: [write_file]                 # Never tell the AI about these tools
+parallel_tools: false  # Run the tool calls from a response at the same time instead of one by one
+# session_dir: ~/.local/share/clai/sessions # Where to store session data
+# schedule_dir: ~/.local/share/clai/scheduled # Where the sessions of scheduled runs are stored
 save_history: true     # Save conversation history
 max_history_size: 100  # Max messages to keep in history
+
+# Directories (defaults follow $XDG_DATA_HOME, $XDG_STATE_HOME and $XDG_CACHE_HOME)
+# data_dir: ~/.local/share/clai   # Sessions, usage and plugins
+# state_dir: ~/.local/state/clai  # Logs and recent models
+# cache_dir: ~/.cache/clai        # Tokenizer files
+
+# Tool sandbox
+# sandbox: docker       # Run tools in a container (docker or podman) with only the working dir mounted
+# sandbox_image: alpine:latest
+
+# Plugin sandbox
+plugin_limits:
+  timeout: 1m          # Max time a plugin call can take
+  cpu_seconds: 0       # Max CPU seconds per call (0 = unlimited)
+  memory_mb: 0         # Max memory per call (0 = unlimited)
+  deny_network: false  # Run plugins without network access (Linux, needs unshare)
+  env: []              # Extra environment variables to pass to plugins
+
+# Report one-shot runs (clai "<task>") when they finish or fail
+# webhook_url: https://hooks.slack.com/services/...
+
+# Sharing the session with /share
+# share:
+#   addr: ":0"         # Address to serve the session on, any free port by default
+#   paste_url: ""      # Send a snapshot to a paste service instead, e.g. https://paste.rs
+
+# Model overrides (optional - built-in values are used otherwise)
+# models:
+#   qwen3:
+#     context_window: 40960
+#     tools: true
+#     vision: false
+#     input_price: 0     # USD per million prompt tokens
+#     output_price: 0    # USD per million completion tokens
+#     stop: ["<|im_end|>"] # Sequences that end the response
+#     options:           # Passed to Ollama with each request
+#       top_k: 20
+
+# Reading servers that don't quite speak the OpenAI API (optional)
+# adapters:
+#   custom:
+#     data:              # Regex replacements made to each streamed chunk before it is parsed
+#       - pattern: '^\{"result":(.*)\}$'
+#         with: '$1'
+#     content: choices.0.delta.text # Where the text is in the chunk
+#     reasoning: choices.0.delta.thinking
+#     finish_reason: choices.0.stop_reason
+#     content_replace:   # Regex replacements made to the text of each chunk
+#       - pattern: '\x1b\[[0-9;]*m'
+#         with: ''
 `
 
-	if err := os.WriteFile(configPath, []byte(defaultConfig), 0644); err != nil {
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(configPath, []byte(fmt.Sprintf(defaultConfig, Version)), 0644); err != nil {
 		return fmt.Errorf("failed to write config: %w", err)
 	}
 
@@ -239,6 +875,10 @@ func Display(cfg *Config) error {
 	fmt.Printf("  Provider: %s\n", cfg.Provider)
 	fmt.Printf("  Model: %s\n", cfg.Model)
 	fmt.Printf("  Base URL: %s\n", cfg.BaseURL)
+	if cfg.Provider == "azure" {
+		fmt.Printf("  Deployment: %s\n", cmp.Or(cfg.Deployment, cfg.Model))
+		fmt.Printf("  API Version: %s\n", cfg.APIVersion)
+	}
 	if cfg.APIKey != "" {
 		fmt.Printf("  API Key: %s\n", maskAPIKey(cfg.APIKey))
 	} else {
@@ -263,6 +903,20 @@ func Set(key, value string) error {
 	return viper.WriteConfig()
 }
 
+// SetValues updates configuration values that aren't strings, like lists
+func SetValues(values map[string]any) error {
+	for key, value := range values {
+		viper.Set(key, value)
+	}
+	return viper.WriteConfig()
+}
+
+// List returns a list as the config file has it, without the changes made by
+// the project or the policy
+func List(key string) []string {
+	return viper.GetStringSlice(key)
+}
+
 func getDefaultEditor() string {
 	if editor := os.Getenv("EDITOR"); editor != "" {
 		return editor
@@ -277,11 +931,29 @@ func maskAPIKey(key string) string {
 	return key[:4] + "..." + key[len(key)-4:]
 }
 
+// envAPIKey returns the API key for the provider from its environment
+// variable
+func envAPIKey(provider string) string {
+	switch provider {
+	case "openai":
+		return os.Getenv("OPENAI_API_KEY")
+	case "anthropic":
+		return os.Getenv("ANTHROPIC_API_KEY")
+	case "azure":
+		return os.Getenv("AZURE_OPENAI_API_KEY")
+	}
+	return ""
+}
+
 // getDefaultBaseURL returns the default base URL for a provider
 func getDefaultBaseURL(provider string) string {
 	switch provider {
 	case "openai":
 		return "https://api.openai.com/v1"
+	case "anthropic":
+		return "https://api.anthropic.com"
+	case "azure":
+		return os.Getenv("AZURE_OPENAI_ENDPOINT")
 	case "ollama":
 		return "http://localhost:11434/v1"
 	case "custom":
@@ -291,8 +963,8 @@ func getDefaultBaseURL(provider string) string {
 	}
 }
 
-// getDefaultSystemPrompt returns the default system prompt
-func getDefaultSystemPrompt() string {
+// DefaultSystemPrompt returns the built-in system prompt
+func DefaultSystemPrompt() string {
 	return `You are an expert coding assistant helping developers write, debug, and improve code.
 
 Key responsibilities:
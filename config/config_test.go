@@ -0,0 +1,61 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConstrainLoad(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "out.json"), []byte(`{"type":"object"}`), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "out.gbnf"), []byte(`root ::= "yes" | "no"`), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "bad.json"), []byte(`{"type":`), 0644))
+
+	schema, grammar, err := Constrain{Schema: "out.json", Grammar: "out.gbnf"}.Load(dir)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":"object"}`, string(schema))
+	assert.Equal(t, `root ::= "yes" | "no"`, grammar)
+
+	schema, grammar, err = Constrain{Schema: `{"type":"array"}`, Grammar: `root ::= "x"`}.Load(dir)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":"array"}`, string(schema))
+	assert.Equal(t, `root ::= "x"`, grammar)
+
+	_, _, err = Constrain{Schema: "bad.json"}.Load(dir)
+	assert.Error(t, err)
+	_, _, err = Constrain{Grammar: "missing.gbnf"}.Load(dir)
+	assert.Error(t, err)
+
+	assert.Equal(t, "off", Constrain{}.String())
+	assert.Equal(t, "JSON matching the schema in out.json", Constrain{Schema: "out.json"}.String())
+	assert.Equal(t, "text matching the grammar given", Constrain{Grammar: `root ::= "x"`}.String())
+
+	assert.NoError(t, Constrain{Grammar: "out.gbnf"}.Check("custom"))
+	assert.NoError(t, Constrain{Schema: "out.json"}.Check("openai"))
+	assert.EqualError(t, Constrain{Grammar: "out.gbnf"}.Check("azure"), "the azure provider doesn't take grammars, only llama.cpp's server with the custom provider does, use a schema instead")
+}
+
+func TestSampling(t *testing.T) {
+	c := Default()
+	temperature, seed := c.Sampling()
+	assert.Equal(t, 0.7, *temperature)
+	assert.Nil(t, seed)
+
+	// 0 leaves it to the provider
+	c.Temperature, c.Seed = 0, 7
+	temperature, seed = c.Sampling()
+	assert.Nil(t, temperature)
+	assert.Equal(t, 7, *seed)
+
+	c.Temperature, c.Seed, c.Deterministic = 0.7, 0, true
+	temperature, seed = c.Sampling()
+	assert.Equal(t, 0.0, *temperature)
+	assert.Equal(t, DeterministicSeed, *seed)
+
+	c.Seed = 7
+	_, seed = c.Sampling()
+	assert.Equal(t, 7, *seed)
+}
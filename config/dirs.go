@@ -0,0 +1,149 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// the files are kept in the XDG base directories, see
+// https://specifications.freedesktop.org/basedir-spec/latest/
+
+// xdgDir returns the clai dir in the base dir named by env, or in the fallback
+// under the home dir when it isn't set (relative paths are ignored as per the
+// spec)
+func xdgDir(env, fallback string) string {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "clai")
+	}
+	return filepath.Join(os.Getenv("HOME"), fallback, "clai")
+}
+
+// ConfigDir is where the config file and translations are kept
+func ConfigDir() string { return xdgDir("XDG_CONFIG_HOME", ".config") }
+
+func defaultDataDir() string  { return xdgDir("XDG_DATA_HOME", filepath.Join(".local", "share")) }
+func defaultStateDir() string { return xdgDir("XDG_STATE_HOME", filepath.Join(".local", "state")) }
+func defaultCacheDir() string { return xdgDir("XDG_CACHE_HOME", ".cache") }
+
+// LegacyDir is where clai kept everything before it used the XDG dirs
+func LegacyDir() string {
+	return filepath.Join(os.Getenv("HOME"), ".clai")
+}
+
+// DefaultFile is the config file used when one isn't given, the old
+// ~/.clai.yml is still used when it exists
+func DefaultFile() string {
+	legacy := filepath.Join(os.Getenv("HOME"), ".clai.yml")
+	if _, err := os.Stat(legacy); err == nil {
+		return legacy
+	}
+	return filepath.Join(ConfigDir(), "config.yml")
+}
+
+// File is the config file in use, or the one that would be used if it
+// doesn't exist yet
+func File() string {
+	if fn := viper.ConfigFileUsed(); fn != "" {
+		return fn
+	}
+	return DefaultFile()
+}
+
+// expandHome replaces a leading ~ with the home dir
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		return os.Getenv("HOME") + path[1:]
+	}
+	return path
+}
+
+// resolveDirs fills in the dirs that weren't set from the data, state and
+// cache dirs
+func (c *Config) resolveDirs() {
+	c.DataDir = expandHome(c.DataDir)
+	c.StateDir = expandHome(c.StateDir)
+	c.CacheDir = expandHome(c.CacheDir)
+
+	if c.SessionDir == "" {
+		c.SessionDir = filepath.Join(c.DataDir, "sessions")
+	}
+	if c.ScheduleDir == "" {
+		c.ScheduleDir = filepath.Join(c.DataDir, "scheduled")
+	}
+	if c.PluginDir == "" {
+		c.PluginDir = filepath.Join(c.DataDir, "plugins")
+	}
+	if c.LocaleDir == "" {
+		c.LocaleDir = filepath.Join(ConfigDir(), "locales")
+	}
+
+	c.SessionDir = expandHome(c.SessionDir)
+	c.PluginDir = expandHome(c.PluginDir)
+	c.LocaleDir = expandHome(c.LocaleDir)
+}
+
+// moveLegacyFiles moves the files from an old install in ~/.clai to where they
+// are kept now, anything already in the new place is left alone.  It returns
+// a note for each kind of file that was moved, or couldn't be.
+func (c *Config) moveLegacyFiles() []Problem {
+	legacy := LegacyDir()
+	if fi, err := os.Stat(legacy); err != nil || !fi.IsDir() {
+		return nil
+	}
+
+	sessions, _ := filepath.Glob(filepath.Join(legacy, "*.yml"))
+	moves := []struct {
+		key   string
+		files []string
+		dest  string
+		whole bool // the dest is the file itself rather than the dir it goes in
+	}{
+		{"session_dir", sessions, c.SessionDir, false},
+		{"data_dir", []string{filepath.Join(legacy, "usage.jsonl")}, c.DataDir, false},
+		{"state_dir", []string{filepath.Join(legacy, "clai.log"), filepath.Join(legacy, "recent_models"), filepath.Join(legacy, "debug")}, c.StateDir, false},
+		{"plugin_dir", []string{filepath.Join(legacy, "plugins")}, c.PluginDir, true},
+		{"locale_dir", []string{filepath.Join(legacy, "locales")}, c.LocaleDir, true},
+	}
+
+	var notes []Problem
+	for _, m := range moves {
+		moved := 0
+		for _, src := range m.files {
+			dest := m.dest
+			if !m.whole {
+				dest = filepath.Join(m.dest, filepath.Base(src))
+			}
+			if filepath.Clean(src) == filepath.Clean(dest) {
+				continue
+			}
+			if _, err := os.Stat(src); err != nil {
+				continue
+			}
+			if _, err := os.Stat(dest); err == nil {
+				continue
+			}
+
+			err := os.MkdirAll(filepath.Dir(dest), 0755)
+			if err == nil {
+				err = os.Rename(src, dest)
+			}
+			if err != nil {
+				notes = append(notes, Problem{Key: m.key, Message: fmt.Sprintf("failed to move %s to %s, move it by hand: %s", src, dest, err)})
+				continue
+			}
+			moved++
+		}
+
+		if moved > 0 {
+			notes = append(notes, Problem{Key: m.key, Message: fmt.Sprintf("moved %d file(s) from %s to %s", moved, legacy, m.dest)})
+		}
+	}
+
+	// only removed once it's empty
+	os.Remove(legacy)
+	return notes
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDirs(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, "state"))
+	t.Setenv("XDG_CONFIG_HOME", "relative/is/ignored")
+
+	cfg := Default()
+	cfg.PluginDir = "~/my-plugins"
+	cfg.resolveDirs()
+
+	assert.Equal(t, filepath.Join(home, ".local/share/clai/sessions"), cfg.SessionDir)
+	assert.Equal(t, filepath.Join(home, "state/clai"), cfg.StateDir)
+	assert.Equal(t, filepath.Join(home, "my-plugins"), cfg.PluginDir)
+	assert.Equal(t, filepath.Join(home, ".config/clai/locales"), cfg.LocaleDir)
+	assert.Equal(t, filepath.Join(home, ".config/clai/config.yml"), DefaultFile())
+
+	legacy := filepath.Join(home, ".clai")
+	assert.NoError(t, os.MkdirAll(filepath.Join(legacy, "plugins"), 0755))
+	for _, fn := range []string{"abc.yml", "usage.jsonl", "clai.log", "plugins/tool"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(legacy, fn), []byte(fn), 0644))
+	}
+
+	notes := cfg.moveLegacyFiles()
+	assert.Len(t, notes, 4)
+
+	for _, fn := range []string{
+		filepath.Join(cfg.SessionDir, "abc.yml"),
+		filepath.Join(cfg.DataDir, "usage.jsonl"),
+		filepath.Join(cfg.StateDir, "clai.log"),
+		filepath.Join(cfg.PluginDir, "tool"),
+	} {
+		assert.FileExists(t, fn)
+	}
+	assert.NoDirExists(t, legacy)
+
+	// the old config file is still used while it's there
+	assert.NoError(t, os.WriteFile(filepath.Join(home, ".clai.yml"), nil, 0644))
+	assert.Equal(t, filepath.Join(home, ".clai.yml"), DefaultFile())
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Version is the layout of the config file used by this version of clai,
+// bump it when adding a migration
+const Version = 1
+
+// migration upgrades the config file to its version, it returns a note for
+// each change that was made, and warnings for what it couldn't change
+type migration struct {
+	version int
+	apply   func(root *yaml.Node) (changes, warnings []Problem)
+}
+
+var migrations = []migration{
+	{1, func(root *yaml.Node) ([]Problem, []Problem) {
+		return renameKey(root, "include_hidde n", "include_hidden")
+	}},
+}
+
+// deprecated keys still work but will be removed in a future version, the
+// value says what to do instead
+var deprecated = map[string]string{}
+
+// Migrate upgrades the config file to the current Version, the old file is
+// kept next to it with a .bak extension.  It returns what was changed, and
+// the deprecated keys that are in it.
+func Migrate(fn string) ([]Problem, error) {
+	data, err := os.ReadFile(fn)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	root := doc.Content[0]
+
+	version := 0
+	k, v := findKey(root, "config_version")
+	if v != nil {
+		version, _ = strconv.Atoi(v.Value)
+	}
+	if version > Version {
+		return []Problem{{Line: k.Line, Key: "config_version", Message: fmt.Sprintf("the config is for a newer version of clai (%d, this one knows %d), some settings may be ignored", version, Version)}}, nil
+	}
+
+	var notes, warnings []Problem
+	for _, m := range migrations {
+		if m.version > version {
+			changes, warns := m.apply(root)
+			notes = append(notes, changes...)
+			warnings = append(warnings, warns...)
+		}
+	}
+
+	// only files that were changed are rewritten
+	if len(notes) > 0 {
+		setKey(root, "config_version", strconv.Itoa(Version))
+		if err := writeUpgraded(fn, data, &doc); err != nil {
+			return nil, err
+		}
+	}
+	notes = append(notes, warnings...)
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if instead, ok := deprecated[root.Content[i].Value]; ok {
+			notes = append(notes, Problem{Line: root.Content[i].Line, Key: root.Content[i].Value, Message: "deprecated, " + instead})
+		}
+	}
+
+	return notes, nil
+}
+
+// writeUpgraded saves the upgraded config, keeping the old one as a backup
+func writeUpgraded(fn string, old []byte, doc *yaml.Node) error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(fn+".bak", old, 0600); err != nil {
+		return fmt.Errorf("failed to back up the config: %w", err)
+	}
+	return os.WriteFile(fn, buf.Bytes(), 0644)
+}
+
+// findKey returns the key and value nodes of the key in the mapping
+func findKey(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// setKey sets the value of a key in the mapping, adding it at the top if it
+// isn't there
+func setKey(mapping *yaml.Node, key, value string) {
+	if _, v := findKey(mapping, key); v != nil {
+		v.Value = value
+		return
+	}
+
+	k := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+	v := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: value}
+	mapping.Content = append([]*yaml.Node{k, v}, mapping.Content...)
+}
+
+// renameKey moves the value of a key to its new name, unless the new name is
+// already set in which case it is left alone and a warning is returned
+func renameKey(mapping *yaml.Node, from, to string) (changes, warnings []Problem) {
+	k, _ := findKey(mapping, from)
+	if k == nil {
+		return nil, nil
+	}
+
+	if existing, _ := findKey(mapping, to); existing != nil {
+		return nil, []Problem{{Line: k.Line, Key: from, Message: fmt.Sprintf("was renamed to %s, which is already set so this is ignored", to)}}
+	}
+
+	k.Value = to
+	return []Problem{{Line: k.Line, Key: from, Message: fmt.Sprintf("was renamed to %s, the config has been upgraded", to)}}, nil
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrate(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "clai.yml")
+	old := "# my config\nmodel: qwen3\ninclude_hidde n: true # dotfiles too\n"
+	assert.NoError(t, os.WriteFile(fn, []byte(old), 0644))
+
+	notes, err := Migrate(fn)
+	assert.NoError(t, err)
+	assert.Len(t, notes, 1)
+	assert.Equal(t, 3, notes[0].Line)
+
+	data, _ := os.ReadFile(fn)
+	assert.Equal(t, "config_version: 1\n# my config\nmodel: qwen3\ninclude_hidden: true # dotfiles too\n", string(data))
+
+	backup, _ := os.ReadFile(fn + ".bak")
+	assert.Equal(t, old, string(backup))
+
+	problems, err := CheckFile(fn)
+	assert.NoError(t, err)
+	assert.Empty(t, problems)
+
+	// nothing left to do
+	notes, err = Migrate(fn)
+	assert.NoError(t, err)
+	assert.Empty(t, notes)
+}
+
+func TestMigrateConflict(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "clai.yml")
+	old := "include_hidden: false\ninclude_hidde n: true\n"
+	assert.NoError(t, os.WriteFile(fn, []byte(old), 0644))
+
+	notes, err := Migrate(fn)
+	assert.NoError(t, err)
+	assert.Len(t, notes, 1)
+	assert.Equal(t, 2, notes[0].Line)
+
+	// nothing was changed, so the file isn't rewritten
+	data, _ := os.ReadFile(fn)
+	assert.Equal(t, old, string(data))
+	assert.NoFileExists(t, fn+".bak")
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// PolicyFile is where the admin of a shared machine sets what each user can
+// do, it overrides the user's own config
+var PolicyFile = "/etc/clai/policy.yml"
+
+// Policy limits what a user can do, zero values don't limit anything
+type Policy struct {
+	Tools          []string `json:"tools,omitempty"`            // The only tools that can be used, plugin tools included
+	Scope          []string `json:"scope,omitempty"`            // Absolute dirs that the tools are kept to
+	MaxSessionCost float64  `json:"max_session_cost,omitempty"` // Caps max_session_cost, /budget ok can't go past it
+	MaxDailyCost   float64  `json:"max_daily_cost,omitempty"`   // Caps max_daily_cost, /budget ok can't go past it
+}
+
+// Policies is the policy file, users that aren't listed get the default.
+// Callers are matched by their OS user, there are no API keys to tell them
+// apart by as the daemon and editor server only listen on unix sockets.
+type Policies struct {
+	Default *Policy           `json:"default,omitempty"`
+	Users   map[string]Policy `json:"users,omitempty"`
+}
+
+// LoadPolicy returns the policy for the user from the file, nil if there is
+// no file or it has no policy for them
+func LoadPolicy(fn, username string) (*Policy, error) {
+	data, err := os.ReadFile(fn)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the policy: %w", err)
+	}
+
+	var ps Policies
+	if err := yaml.Unmarshal(data, &ps); err != nil {
+		return nil, fmt.Errorf("failed to read the policy %s: %w", fn, err)
+	}
+
+	if p, ok := ps.Users[username]; ok {
+		return &p, nil
+	}
+	return ps.Default, nil
+}
+
+// currentUser is who the policy is looked up for
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// ApplyPolicy limits the config to what the policy allows, workingDir is
+// what relative dirs in the scope are relative to
+func (c *Config) ApplyPolicy(p Policy, workingDir string) {
+	if p.Tools != nil {
+		c.AllowedTools = p.Tools
+		c.PermittedTools = slices.DeleteFunc(c.PermittedTools, func(name string) bool {
+			return !slices.Contains(p.Tools, name)
+		})
+	}
+
+	// the user can narrow the scope further but not widen it
+	if len(p.Scope) > 0 {
+		narrower := len(c.Scope) > 0
+		for _, s := range c.Scope {
+			if !filepath.IsAbs(s) {
+				s = filepath.Join(workingDir, s)
+			}
+			if !inAny(filepath.Clean(s), p.Scope) {
+				narrower = false
+			}
+		}
+		if !narrower {
+			c.Scope = p.Scope
+		}
+	}
+
+	// the user's limits are lowered to the caps so they are warned before
+	// reaching them, but only the user's own can be approved past
+	c.MaxSessionCost = capCost(c.MaxSessionCost, p.MaxSessionCost)
+	c.MaxDailyCost = capCost(c.MaxDailyCost, p.MaxDailyCost)
+	c.CapSessionCost, c.CapDailyCost = p.MaxSessionCost, p.MaxDailyCost
+}
+
+func inAny(dir string, dirs []string) bool {
+	for _, d := range dirs {
+		d = filepath.Clean(d)
+		if dir == d || strings.HasPrefix(dir, d+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// capCost returns the lower of the limits, 0 is no limit
+func capCost(limit, max float64) float64 {
+	if max > 0 && (limit == 0 || limit > max) {
+		return max
+	}
+	return limit
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadPolicy(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "policy.yml")
+	assert.NoError(t, os.WriteFile(fn, []byte(`
+default:
+  tools: [list_files, read_file]
+  max_daily_cost: 1
+users:
+  alice:
+    max_daily_cost: 20
+`), 0644))
+
+	p, err := LoadPolicy(fn, "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, &Policy{MaxDailyCost: 20}, p)
+
+	p, err = LoadPolicy(fn, "bob")
+	assert.NoError(t, err)
+	assert.Equal(t, &Policy{Tools: []string{"list_files", "read_file"}, MaxDailyCost: 1}, p)
+
+	p, err = LoadPolicy(filepath.Join(t.TempDir(), "missing.yml"), "bob")
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+}
+
+func TestApplyPolicy(t *testing.T) {
+	p := Policy{
+		Tools:          []string{"list_files", "read_file"},
+		Scope:          []string{"/srv/app"},
+		MaxSessionCost: 2,
+	}
+
+	cfg := &Config{PermittedTools: []string{"list_files", "write_file"}, Scope: []string{"/srv"}, MaxSessionCost: 10}
+	cfg.ApplyPolicy(p, "/srv/app")
+	assert.Equal(t, []string{"list_files", "read_file"}, cfg.AllowedTools)
+	assert.Equal(t, []string{"list_files"}, cfg.PermittedTools)
+	assert.Equal(t, []string{"/srv/app"}, cfg.Scope, "the scope can't be widened")
+	assert.Equal(t, 2.0, cfg.MaxSessionCost)
+	assert.Equal(t, 2.0, cfg.CapSessionCost)
+	assert.Zero(t, cfg.CapDailyCost)
+
+	// but it can be narrowed, and a lower budget is kept
+	cfg = &Config{Scope: []string{"api"}, MaxSessionCost: 1}
+	cfg.ApplyPolicy(p, "/srv/app")
+	assert.Equal(t, []string{"api"}, cfg.Scope)
+	assert.Equal(t, 1.0, cfg.MaxSessionCost)
+}
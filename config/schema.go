@@ -0,0 +1,225 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Problem is something wrong with a key in the config file
+type Problem struct {
+	Line    int    // 0 when it isn't about a line in the file
+	Key     string // the path to the key, e.g. plugin_limits.timeout
+	Message string
+	Unknown bool // the key isn't used by clai, it is ignored rather than being an error
+}
+
+func (p Problem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", p.Key, p.Message)
+	}
+	return fmt.Sprintf("line %d: %s: %s", p.Line, p.Key, p.Message)
+}
+
+// CheckFile reports the keys in the config file that clai doesn't know, and
+// the values that have the wrong type
+func CheckFile(fn string) ([]Problem, error) {
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	return Check(data)
+}
+
+// Check is CheckFile for the contents of a config file
+func Check(data []byte) ([]Problem, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	var problems []Problem
+	checkNode(doc.Content[0], reflect.TypeOf(Config{}), "", &problems)
+	return problems, nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// checkNode checks the node can be decoded into the type t
+func checkNode(node *yaml.Node, t reflect.Type, path string, problems *[]Problem) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if node.Tag == "!!null" {
+		return
+	}
+
+	wrong := func(want string) {
+		*problems = append(*problems, Problem{Line: node.Line, Key: path, Message: "should be " + want})
+	}
+
+	switch {
+	case t.Kind() == reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			wrong("a section of settings")
+			return
+		}
+		fields := structKeys(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, val := node.Content[i], node.Content[i+1]
+			// viper ignores the case of the keys
+			ft, ok := fields[strings.ToLower(key.Value)]
+			if !ok {
+				*problems = append(*problems, unknownKey(key, join(path, key.Value), fields))
+				continue
+			}
+			checkNode(val, ft, join(path, key.Value), problems)
+		}
+
+	case t.Kind() == reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			wrong("a section of settings")
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			checkNode(node.Content[i+1], t.Elem(), join(path, node.Content[i].Value), problems)
+		}
+
+	case t.Kind() == reflect.Interface:
+		// anything goes, like the options passed on to Ollama
+
+	case t.Kind() == reflect.Slice:
+		// a single value is turned into a list when the config is loaded
+		if node.Kind == yaml.ScalarNode {
+			checkNode(node, t.Elem(), path, problems)
+			return
+		}
+		if node.Kind != yaml.SequenceNode {
+			wrong("a list")
+			return
+		}
+		for _, item := range node.Content {
+			checkNode(item, t.Elem(), path, problems)
+		}
+
+	case node.Kind != yaml.ScalarNode:
+		wrong(typeName(t))
+
+	default:
+		v := reflect.New(t).Interface()
+		err := node.Decode(v)
+		if err != nil && node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+			// quoted numbers and bools are fine when the config is loaded
+			err = yaml.Unmarshal([]byte(node.Value), v)
+		}
+		if err != nil {
+			wrong(typeName(t))
+		}
+	}
+}
+
+func typeName(t reflect.Type) string {
+	switch {
+	case t == durationType:
+		return "a duration like 30s or 5m"
+	case t.Kind() == reflect.Bool:
+		return "true or false"
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return "a whole number"
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return "a number"
+	}
+	return "text"
+}
+
+// Keys lists the keys that can be set in the config file, the keys in
+// sections are joined to the name of the section with a dot
+func Keys() []string {
+	var keys []string
+	var walk func(t reflect.Type, path string)
+	walk = func(t reflect.Type, path string) {
+		for key, ft := range structKeys(t) {
+			if ft.Kind() == reflect.Struct {
+				walk(ft, join(path, key))
+				continue
+			}
+			keys = append(keys, join(path, key))
+		}
+	}
+	walk(reflect.TypeOf(Config{}), "")
+
+	sort.Strings(keys)
+	return keys
+}
+
+// structKeys maps the config keys of the struct to the types of the fields
+func structKeys(t reflect.Type) map[string]reflect.Type {
+	keys := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := f.Tag.Get("mapstructure")
+		if key == "" || key == "-" {
+			continue
+		}
+		keys[key] = f.Type
+	}
+	return keys
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// unknownKey reports a key that isn't used, suggesting the closest known
+// keys in case it is a typo
+func unknownKey(key *yaml.Node, path string, known map[string]reflect.Type) Problem {
+	p := Problem{Line: key.Line, Key: path, Message: "unknown key, it is ignored", Unknown: true}
+
+	var suggest []string
+	for k := range known {
+		if d := distance(strings.ToLower(key.Value), k); d <= max(2, len(k)/4) {
+			suggest = append(suggest, k)
+		}
+	}
+	sort.Strings(suggest)
+	if len(suggest) > 0 {
+		p.Message += ", did you mean " + strings.Join(suggest, " or ") + "?"
+	}
+	return p
+}
+
+// distance is the number of single letter edits that turn a into b
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	problems, err := Check([]byte(`model: qwen3
+Max_Tokens: 100
+include_hidde n: true
+stall_timeout: soon
+wrap_width: "80"
+exclude_patterns: vendor
+plugin_limits:
+  timout: 5s
+models:
+  qwen3:
+    tools: maybe
+`))
+	assert.NoError(t, err)
+
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.String())
+	}
+	assert.Equal(t, []string{
+		"line 3: include_hidde n: unknown key, it is ignored, did you mean include_hidden?",
+		"line 4: stall_timeout: should be a duration like 30s or 5m",
+		"line 8: plugin_limits.timout: unknown key, it is ignored, did you mean timeout?",
+		"line 11: models.qwen3.tools: should be true or false",
+	}, got)
+}
+
+// the example config in the README should only use keys that exist
+func TestCheckReadme(t *testing.T) {
+	data, err := os.ReadFile("../README.md")
+	assert.NoError(t, err)
+
+	_, block, _ := strings.Cut(string(data), "```yml\n")
+	block, _, _ = strings.Cut(block, "```")
+
+	problems, err := Check([]byte(block))
+	assert.NoError(t, err)
+	assert.Empty(t, problems)
+}
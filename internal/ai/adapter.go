@@ -0,0 +1,153 @@
+package ai
+
+import (
+	"encoding/json"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/penguinpowernz/clai/config"
+)
+
+// replacement is a compiled config.Replacement
+type replacement struct {
+	re   *regexp.Regexp
+	with string
+}
+
+func compileReplacements(rr []config.Replacement) []replacement {
+	var out []replacement
+	for _, r := range rr {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			log.Printf("[client] ignoring adapter pattern %q: %s", r.Pattern, err)
+			continue
+		}
+		out = append(out, replacement{re: re, with: r.With})
+	}
+	return out
+}
+
+func replaceAll(text string, rr []replacement) string {
+	for _, r := range rr {
+		text = r.re.ReplaceAllString(text, r.with)
+	}
+	return text
+}
+
+// responseAdapter reads the chunks of servers that don't put the response
+// where the OpenAI API does, see config.Adapter
+type responseAdapter struct {
+	data           []replacement
+	content        []string
+	reasoning      []string
+	finishReason   []string
+	contentReplace []replacement
+}
+
+// newResponseAdapter returns the adapter for the provider, or nil if it
+// doesn't have one
+func newResponseAdapter(cfg *config.Config) *responseAdapter {
+	a, ok := cfg.Adapters[cfg.Provider]
+	if !ok {
+		return nil
+	}
+
+	return &responseAdapter{
+		data:           compileReplacements(a.Data),
+		content:        splitPath(a.Content),
+		reasoning:      splitPath(a.Reasoning),
+		finishReason:   splitPath(a.FinishReason),
+		contentReplace: compileReplacements(a.ContentReplace),
+	}
+}
+
+// Data makes the data replacements to the chunk before it is parsed
+func (a *responseAdapter) Data(data string) string {
+	if a == nil {
+		return data
+	}
+	return replaceAll(data, a.data)
+}
+
+// Chunk fills in the parsed chunk from the paths, and makes the content
+// replacements to its text
+func (a *responseAdapter) Chunk(data string, chunk *openAIStreamChunk) {
+	if a == nil {
+		return
+	}
+
+	if a.content != nil || a.reasoning != nil || a.finishReason != nil {
+		var doc any
+		if err := json.Unmarshal([]byte(data), &doc); err != nil {
+			return
+		}
+		if len(chunk.Choices) == 0 {
+			chunk.Choices = make([]openAIStreamChoice, 1)
+		}
+
+		choice := &chunk.Choices[0]
+		if a.content != nil {
+			choice.Delta.Content = lookupString(doc, a.content)
+		}
+		if a.reasoning != nil {
+			choice.Delta.Reasoning = lookupString(doc, a.reasoning)
+		}
+		if a.finishReason != nil {
+			choice.FinishReason = lookupString(doc, a.finishReason)
+		}
+	}
+
+	if len(chunk.Choices) > 0 {
+		chunk.Choices[0].Delta.Content = replaceAll(chunk.Choices[0].Delta.Content, a.contentReplace)
+	}
+}
+
+// Content makes the content replacements to the text of a whole response
+func (a *responseAdapter) Content(text string) string {
+	if a == nil {
+		return text
+	}
+	return replaceAll(text, a.contentReplace)
+}
+
+// splitPath splits a JSON path like choices.0.delta.text or
+// $.choices[0].delta.text into its keys, nil if it is empty
+func splitPath(path string) []string {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// lookupString finds the value at the path in the decoded JSON, anything
+// that isn't a string is given as its JSON, and nothing if it isn't there
+func lookupString(doc any, path []string) string {
+	for _, key := range path {
+		switch v := doc.(type) {
+		case map[string]any:
+			doc = v[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return ""
+			}
+			doc = v[i]
+		default:
+			return ""
+		}
+	}
+
+	switch v := doc.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
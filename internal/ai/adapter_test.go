@@ -0,0 +1,64 @@
+package ai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseAdapter(t *testing.T) {
+	events := []string{
+		`{"result":{"choices":[{"delta":{"text":"Hello\u001b[1m"}}]}}`,
+		`{"result":{"choices":[{"delta":{"thinking":"hmm","text":" world"}}]}}`,
+		`{"result":{"choices":[{"delta":{},"stop_reason":"stop"}]}}`,
+		`{"result":[DONE]}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data: " + strings.Join(events, "\n\ndata: ") + "\n\n"))
+	}))
+	defer srv.Close()
+
+	cfg := config.Default()
+	cfg.Provider, cfg.BaseURL, cfg.Model = "custom", srv.URL, "odd"
+	cfg.Adapters = map[string]config.Adapter{"custom": {
+		Data:           []config.Replacement{{Pattern: `^\{"result":(.*)\}$`, With: "$1"}},
+		Content:        "$.choices[0].delta.text",
+		Reasoning:      "choices.0.delta.thinking",
+		FinishReason:   "choices.0.stop_reason",
+		ContentReplace: []config.Replacement{{Pattern: `\x1b\[[0-9;]*m`}},
+	}}
+	c, err := NewClient(cfg)
+	assert.NoError(t, err)
+
+	stream, err := c.StreamMessage(context.Background(), []Message{{Role: "user", Content: "hi"}})
+	assert.NoError(t, err)
+
+	var chunks []MessageChunk
+	for chunk := range stream {
+		if chunk.Type() != ChunkUsage {
+			chunks = append(chunks, chunk)
+		}
+	}
+
+	assert.Equal(t, []MessageChunk{
+		NewChunk(ChunkMessage, "Hello"),
+		NewChunk(ChunkMessage, " world"),
+		NewChunk(ChunkThink, "hmm"),
+		NewChunk(ChunkFinish, "stop"),
+	}, chunks)
+}
+
+func TestLookupString(t *testing.T) {
+	doc := map[string]any{"a": []any{map[string]any{"b": "text", "n": 1.5}}}
+	assert.Equal(t, "text", lookupString(doc, splitPath("a.0.b")))
+	assert.Equal(t, "text", lookupString(doc, splitPath("$.a[0].b")))
+	assert.Equal(t, "1.5", lookupString(doc, splitPath("a.0.n")))
+	assert.Equal(t, "", lookupString(doc, splitPath("a.1.b")))
+	assert.Equal(t, "", lookupString(doc, splitPath("a.0.b.c")))
+	assert.Nil(t, splitPath("$"))
+}
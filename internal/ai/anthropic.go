@@ -0,0 +1,611 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/tools"
+)
+
+// anthropicVersion is the version of the Messages API the client speaks
+const anthropicVersion = "2023-06-01"
+
+// toolDenied is the result given for a tool call that wasn't run, the API
+// won't take a tool call without a result
+const toolDenied = "The user didn't allow the tool to be run."
+
+// anthropicStopReasons maps the reasons Anthropic gives for stopping to the
+// OpenAI ones the rest of clai knows
+var anthropicStopReasons = map[string]string{
+	"end_turn":      "stop",
+	"stop_sequence": "stop",
+	"max_tokens":    "length",
+	"tool_use":      "tool_calls",
+}
+
+type AnthropicClient struct {
+	config     *config.Config
+	httpClient *http.Client
+	baseURL    string
+	apiKey     string
+	model      *string // pointer to model name in the config to allow us to change it for this session
+
+	toolsMu sync.Mutex // the tools can change between requests
+	tools   []tools.Tool
+}
+
+func NewAnthropicClient(cfg *config.Config) (*AnthropicClient, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("API key is required for provider: %s", cfg.Provider)
+	}
+
+	// the paths have the version in them already
+	baseURL := strings.TrimSuffix(strings.TrimSuffix(cfg.BaseURL, "/"), "/v1")
+
+	return &AnthropicClient{
+		config:     cfg,
+		httpClient: newHTTPClient(cfg),
+		baseURL:    baseURL,
+		apiKey:     cfg.APIKey,
+		model:      &cfg.Model,
+	}, nil
+}
+
+func (c *AnthropicClient) SendMessage(ctx context.Context, messages []Message) (*Response, error) {
+	reqBody := c.newRequest(ctx, messages, false)
+
+	start := time.Now()
+	resp, err := c.post(ctx, reqBody)
+	if err != nil {
+		reportRequest(reqBody.Model, start, err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result anthropicResponse
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	reportRequest(reqBody.Model, start, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	res := &Response{
+		TokensUsed:   result.Usage.InputTokens + result.Usage.OutputTokens,
+		FinishReason: anthropicStopReason(result.StopReason),
+	}
+	for _, block := range result.Content {
+		switch block.Type {
+		case "text":
+			res.Content += block.Text
+		case "tool_use":
+			res.ToolUses = append(res.ToolUses, ToolUse{ID: block.ID, Name: block.Name, Input: block.Input})
+		}
+	}
+	reportAnthropicUsage(reqBody, &result.Usage, len(res.Content))
+
+	return res, nil
+}
+
+func (c *AnthropicClient) StreamMessage(ctx context.Context, messages []Message) (<-chan MessageChunk, error) {
+	reqBody := c.newRequest(ctx, messages, true)
+
+	start := time.Now()
+	resp, err := c.post(ctx, reqBody)
+	if err != nil {
+		reportRequest(reqBody.Model, start, err)
+		return nil, err
+	}
+
+	streamChan := make(chan MessageChunk, streamBuffer)
+
+	go func() {
+		defer close(streamChan)
+		defer resp.Body.Close()
+
+		send := func(chunk MessageChunk) bool {
+			select {
+			case streamChan <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// a stream that was stopped early is still paid for, the usage is
+		// always the last chunk
+		var usage anthropicUsage
+		var generated int
+		defer func() { send(NewUsageChunk(reportAnthropicUsage(reqBody, &usage, generated))) }()
+
+		var streamErr error
+		defer func() { reportRequest(reqBody.Model, start, streamErr) }()
+
+		// the tool calls being streamed, by the index of their block
+		calls := map[int]*ToolCall{}
+		inputs := map[int]*strings.Builder{}
+
+		reader := newSSEReader(resp.Body)
+		for {
+			ev, err := reader.Next()
+			if err != nil {
+				if err != io.EOF && ctx.Err() == nil {
+					log.Println("[client] stream read error:", err)
+					streamErr = err
+					send(NewChunk(ChunkError, err.Error()))
+				}
+				return
+			}
+
+			var event anthropicStreamEvent
+			if err := json.Unmarshal([]byte(ev.Data), &event); err != nil {
+				log.Printf("[client] Failed to parse event: %v\n", err)
+				continue
+			}
+
+			switch event.Type {
+			case "message_start":
+				usage.InputTokens = event.Message.Usage.InputTokens
+				usage.CacheCreationInputTokens = event.Message.Usage.CacheCreationInputTokens
+				usage.CacheReadInputTokens = event.Message.Usage.CacheReadInputTokens
+				usage.OutputTokens = event.Message.Usage.OutputTokens
+
+			case "content_block_start":
+				if event.ContentBlock.Type == "tool_use" {
+					calls[event.Index] = &ToolCall{ID: event.ContentBlock.ID, Name: event.ContentBlock.Name}
+					inputs[event.Index] = &strings.Builder{}
+					generated += len(event.ContentBlock.Name)
+				}
+
+			case "content_block_delta":
+				delta := event.Delta
+				switch delta.Type {
+				case "text_delta":
+					generated += len(delta.Text)
+					if !send(NewChunk(ChunkMessage, delta.Text)) {
+						return
+					}
+				case "thinking_delta":
+					generated += len(delta.Thinking)
+					if !send(NewChunk(ChunkThink, delta.Thinking)) {
+						return
+					}
+				case "input_json_delta":
+					generated += len(delta.PartialJSON)
+					if in, ok := inputs[event.Index]; ok {
+						in.WriteString(delta.PartialJSON)
+					}
+				}
+
+			case "content_block_stop":
+				call, ok := calls[event.Index]
+				if !ok {
+					continue
+				}
+				call.Input = json.RawMessage(toolInput(inputs[event.Index].String()))
+				delete(calls, event.Index)
+				log.Printf("[client] tool call %s %s", call.Name, call.Input)
+				if !send(NewToolCallChunk(call)) {
+					return
+				}
+
+			case "message_delta":
+				if event.Usage != nil {
+					usage.OutputTokens = event.Usage.OutputTokens
+				}
+				if event.Delta.StopReason != "" {
+					if !send(NewChunk(ChunkFinish, anthropicStopReason(event.Delta.StopReason))) {
+						return
+					}
+				}
+
+			case "message_stop":
+				return
+
+			case "error":
+				msg := ev.Data
+				if event.Error != nil && event.Error.Message != "" {
+					msg = event.Error.Message
+				}
+				log.Println("[client] error in stream:", msg)
+				streamErr = errors.New(msg)
+				send(NewChunk(ChunkError, msg))
+				return
+			}
+		}
+	}()
+
+	return streamChan, nil
+}
+
+// post sends the request to the Messages API, the response is only returned
+// if it was a success
+func (c *AnthropicClient) post(ctx context.Context, reqBody anthropicRequest) (*http.Response, error) {
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	log.Println("[client] sending request: ", string(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/v1/messages", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	c.setHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, apiError(resp.StatusCode, body, reqBody.Model)
+	}
+
+	return resp, nil
+}
+
+func (c *AnthropicClient) setHeaders(req *http.Request) {
+	req.Header.Set("X-Api-Key", c.apiKey)
+	req.Header.Set("Anthropic-Version", anthropicVersion)
+}
+
+// newRequest builds the request for the messages, the system prompt goes in
+// its own field rather than being one of the messages
+func (c *AnthropicClient) newRequest(ctx context.Context, messages []Message, stream bool) anthropicRequest {
+	model := c.modelFor(ctx)
+
+	system := c.config.SystemPrompt
+	if prompt, ok := SystemPromptFrom(ctx); ok {
+		system = prompt
+	}
+
+	if _, ok := ConstraintFrom(ctx); ok {
+		log.Println("[client] Anthropic doesn't take constraints on the output, sending the request without it")
+	}
+
+	c.toolsMu.Lock()
+	defer c.toolsMu.Unlock()
+
+	reqBody := anthropicRequest{
+		Model:         model,
+		System:        system,
+		Messages:      convertToAnthropicMessages(messages),
+		MaxTokens:     c.config.MaxTokens,
+		Stream:        stream,
+		StopSequences: LookupModel(c.config, model).Stop,
+	}
+
+	// Anthropic doesn't take a seed
+	reqBody.Temperature, _ = c.config.Sampling()
+
+	for _, tool := range c.tools {
+		if tool.Function == nil {
+			continue
+		}
+		reqBody.Tools = append(reqBody.Tools, anthropicTool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: tool.Function.Parameters,
+		})
+	}
+
+	return reqBody
+}
+
+// Inspect returns the body of the streaming request that would be sent for
+// the messages
+func (c *AnthropicClient) Inspect(ctx context.Context, messages []Message) ([]byte, error) {
+	return json.MarshalIndent(c.newRequest(ctx, messages, true), "", "  ")
+}
+
+func (c *AnthropicClient) modelFor(ctx context.Context) string {
+	if model, ok := ModelFrom(ctx); ok {
+		return model
+	}
+	return *c.model
+}
+
+func (c *AnthropicClient) SetTools(tools []tools.Tool) {
+	c.toolsMu.Lock()
+	defer c.toolsMu.Unlock()
+	c.tools = tools
+}
+
+func (c *AnthropicClient) ListModels() []string {
+	details, err := c.ListModelDetails()
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var models []string
+	for _, model := range details {
+		models = append(models, model.Name)
+	}
+
+	return models
+}
+
+// ListModelDetails returns the models the API key can use, Anthropic doesn't
+// say how big they are
+func (c *AnthropicClient) ListModelDetails() ([]ModelDetails, error) {
+	return c.listModels(context.Background())
+}
+
+func (c *AnthropicClient) listModels(ctx context.Context) ([]ModelDetails, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/v1/models?limit=1000", nil)
+	if err != nil {
+		return nil, err
+	}
+	c.setHeaders(req)
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return nil, fmt.Errorf("listing models: %w", apiError(res.StatusCode, body, ""))
+	}
+
+	var modelsRes struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&modelsRes); err != nil {
+		return nil, fmt.Errorf("listing models: %w", err)
+	}
+
+	var models []ModelDetails
+	for _, model := range modelsRes.Data {
+		models = append(models, ModelDetails{Name: model.ID})
+	}
+
+	return models, nil
+}
+
+// Ping lists the models to check the API can be reached with the key, the
+// model isn't checked as aliases like claude-sonnet-4-0 aren't in the list
+func (c *AnthropicClient) Ping(ctx context.Context) error {
+	_, err := c.listModels(ctx)
+	return err
+}
+
+func (c *AnthropicClient) GetModelInfo() ModelInfo {
+	return ModelInfo{
+		Name:              *c.model,
+		Provider:          c.config.Provider,
+		MaxTokens:         c.config.MaxTokens,
+		SupportsStreaming: true,
+		ModelCapabilities: LookupModel(c.config, *c.model),
+	}
+}
+
+func anthropicStopReason(reason string) string {
+	if r, ok := anthropicStopReasons[reason]; ok {
+		return r
+	}
+	return reason
+}
+
+// toolInput is the input of a tool call, which has to be an object even when
+// the model streamed nothing for it
+func toolInput(s string) string {
+	if !strings.HasPrefix(strings.TrimSpace(s), "{") || !json.Valid([]byte(s)) {
+		return "{}"
+	}
+	return s
+}
+
+// convertToAnthropicMessages turns the messages into the blocks of the
+// Messages API.  Tool calls become tool_use blocks and their results
+// tool_result blocks, the roles have to alternate so messages in a row from
+// the same role are merged, and every tool call has to have a result so the
+// ones the user denied are given one.
+func convertToAnthropicMessages(messages []Message) []anthropicMessage {
+	var result []anthropicMessage
+	calls := map[string]bool{} // the tool calls waiting for a result
+
+	add := func(role string, block anthropicBlock) {
+		if n := len(result); n > 0 && result[n-1].Role == role {
+			result[n-1].Content = append(result[n-1].Content, block)
+			return
+		}
+		result = append(result, anthropicMessage{Role: role, Content: []anthropicBlock{block}})
+	}
+
+	for _, msg := range messages {
+		switch {
+		case msg.Role == "assistant" && msg.IsToolCall():
+			for _, tc := range msg.Calls() {
+				input, err := json.Marshal(tc.Input)
+				if err != nil {
+					input = nil
+				}
+				calls[tc.ID] = true
+				add("assistant", anthropicBlock{
+					Type:  "tool_use",
+					ID:    tc.ID,
+					Name:  tc.Name,
+					Input: json.RawMessage(toolInput(string(input))),
+				})
+			}
+
+		case msg.Role != "assistant" && msg.ToolCallID != "" && calls[msg.ToolCallID]:
+			delete(calls, msg.ToolCallID)
+			add("user", anthropicBlock{
+				Type:      "tool_result",
+				ToolUseID: msg.ToolCallID,
+				Content:   msg.Content,
+				IsError:   msg.Role == "user", // the tool wasn't found
+			})
+
+		case strings.TrimSpace(msg.Content) == "":
+			// the API turns down empty text blocks
+
+		default:
+			role := "user"
+			if msg.Role == "assistant" {
+				role = "assistant"
+			}
+			add(role, anthropicBlock{Type: "text", Text: msg.Content})
+		}
+	}
+
+	return answerToolCalls(result)
+}
+
+// answerToolCalls gives a result to the tool calls that weren't answered in
+// the message after them, and puts the results before any text as the API
+// wants them first
+func answerToolCalls(messages []anthropicMessage) []anthropicMessage {
+	for i := 0; i < len(messages); i++ {
+		if messages[i].Role != "assistant" {
+			continue
+		}
+
+		var ids []string
+		for _, block := range messages[i].Content {
+			if block.Type == "tool_use" {
+				ids = append(ids, block.ID)
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		if i+1 == len(messages) || messages[i+1].Role != "user" {
+			messages = append(messages[:i+1], append([]anthropicMessage{{Role: "user"}}, messages[i+1:]...)...)
+		}
+		next := &messages[i+1]
+
+		answered := map[string]bool{}
+		var results, rest []anthropicBlock
+		for _, block := range next.Content {
+			if block.Type == "tool_result" {
+				answered[block.ToolUseID] = true
+				results = append(results, block)
+			} else {
+				rest = append(rest, block)
+			}
+		}
+		for _, id := range ids {
+			if !answered[id] {
+				results = append(results, anthropicBlock{Type: "tool_result", ToolUseID: id, Content: toolDenied, IsError: true})
+			}
+		}
+		next.Content = append(results, rest...)
+	}
+
+	return messages
+}
+
+// reportAnthropicUsage passes on the usage Anthropic gave, or an estimate
+// from the request and the number of bytes generated if it gave none
+func reportAnthropicUsage(req anthropicRequest, usage *anthropicUsage, generated int) Usage {
+	prompt := usage.InputTokens + usage.CacheCreationInputTokens + usage.CacheReadInputTokens
+	if prompt+usage.OutputTokens > 0 {
+		return Usage{PromptTokens: prompt, CompletionTokens: usage.OutputTokens}.report(req.Model)
+	}
+
+	prompt = EstimateTokens(req.System)
+	for _, msg := range req.Messages {
+		for _, block := range msg.Content {
+			prompt += EstimateTokens(block.Text + block.Content + string(block.Input))
+		}
+	}
+	return Usage{PromptTokens: prompt, CompletionTokens: EstimateTokensLen(generated), Estimated: true}.report(req.Model)
+}
+
+// Anthropic API types
+type anthropicRequest struct {
+	Model         string             `json:"model"`
+	System        string             `json:"system,omitempty"`
+	Messages      []anthropicMessage `json:"messages"`
+	MaxTokens     int                `json:"max_tokens"`
+	Temperature   *float64           `json:"temperature,omitempty"`
+	Stream        bool               `json:"stream"`
+	Tools         []anthropicTool    `json:"tools,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	InputSchema *tools.JSONSchema `json:"input_schema"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicBlock struct {
+	Type string `json:"type"`
+
+	// text
+	Text string `json:"text,omitempty"`
+
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// tool_result
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+	IsError   bool   `json:"is_error,omitempty"`
+}
+
+type anthropicResponse struct {
+	ID         string           `json:"id"`
+	Model      string           `json:"model"`
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      anthropicUsage   `json:"usage"`
+}
+
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+}
+
+type anthropicStreamEvent struct {
+	Type  string `json:"type"`
+	Index int    `json:"index"`
+
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+
+	ContentBlock anthropicBlock `json:"content_block"`
+
+	Delta struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		Thinking    string `json:"thinking"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+
+	Usage *anthropicUsage `json:"usage"`
+	Error *openAIError    `json:"error"`
+}
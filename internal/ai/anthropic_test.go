@@ -0,0 +1,100 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnthropicStream(t *testing.T) {
+	var got anthropicRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/messages", r.URL.Path)
+		assert.Equal(t, "sk-test", r.Header.Get("X-Api-Key"))
+		assert.Equal(t, anthropicVersion, r.Header.Get("Anthropic-Version"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+
+		w.Write([]byte("event: message_start\n" +
+			`data: {"type":"message_start","message":{"usage":{"input_tokens":12,"output_tokens":1}}}` + "\n\n" +
+			"event: content_block_delta\n" +
+			`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Looking"}}` + "\n\n" +
+			"event: content_block_start\n" +
+			`data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"read_file","input":{}}}` + "\n\n" +
+			"event: content_block_delta\n" +
+			`data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"path\":"}}` + "\n\n" +
+			"event: content_block_delta\n" +
+			`data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"main.go\"}"}}` + "\n\n" +
+			"event: content_block_stop\n" +
+			`data: {"type":"content_block_stop","index":1}` + "\n\n" +
+			"event: message_delta\n" +
+			`data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":30}}` + "\n\n" +
+			"event: message_stop\n" +
+			`data: {"type":"message_stop"}` + "\n\n"))
+	}))
+	defer srv.Close()
+
+	cfg := &config.Config{Provider: "anthropic", Model: "claude-sonnet-4-0", APIKey: "sk-test", BaseURL: srv.URL + "/v1", MaxTokens: 1024, SystemPrompt: "be brief"}
+	c, err := NewAnthropicClient(cfg)
+	assert.NoError(t, err)
+	c.SetTools([]tools.Tool{{Type: "function", Function: &tools.FunctionSchema{Name: "read_file", Parameters: &tools.JSONSchema{Type: "object"}}}})
+
+	stream, err := c.StreamMessage(context.Background(), []Message{{Role: "user", Content: "what's in main.go?"}})
+	assert.NoError(t, err)
+
+	var chunks []MessageChunk
+	for chunk := range stream {
+		chunks = append(chunks, chunk)
+	}
+
+	assert.Equal(t, []MessageChunk{
+		NewChunk(ChunkMessage, "Looking"),
+		NewToolCallChunk(&ToolCall{ID: "toolu_1", Name: "read_file", Input: json.RawMessage(`{"path":"main.go"}`)}),
+		NewChunk(ChunkFinish, "tool_calls"),
+		NewUsageChunk(Usage{PromptTokens: 12, CompletionTokens: 30}),
+	}, chunks)
+
+	assert.Equal(t, "be brief", got.System)
+	assert.Equal(t, 1024, got.MaxTokens)
+	assert.Equal(t, "read_file", got.Tools[0].Name)
+	assert.Equal(t, "object", got.Tools[0].InputSchema.Type)
+}
+
+func TestConvertToAnthropicMessages(t *testing.T) {
+	messages := convertToAnthropicMessages([]Message{
+		{Role: "user", Content: "fix it"},
+		{Role: "assistant", Content: "Sure"},
+		{Role: "assistant", Content: "Request to use tool", ToolCallID: "a", ToolCall: &ToolUse{ID: "a", Name: "read_file", Input: json.RawMessage(`{"path":"x"}`)}},
+		{Role: "assistant", Content: "Request to use tool", ToolCallID: "b", ToolCall: &ToolUse{ID: "b", Name: "nope"}},
+		{Role: "tool", Content: "package x", ToolCallID: "a"},
+		{Role: "user", Content: "Tool not found", ToolCallID: "b"},
+		{Role: "assistant", Content: "Request to use tool", ToolCallID: "c", ToolCall: &ToolUse{ID: "c", Name: "bash", Input: map[string]any{"command": "rm -rf /"}}},
+		{Role: "user", Content: "no"},
+	})
+
+	assert.Equal(t, []anthropicMessage{
+		{Role: "user", Content: []anthropicBlock{{Type: "text", Text: "fix it"}}},
+		{Role: "assistant", Content: []anthropicBlock{
+			{Type: "text", Text: "Sure"},
+			{Type: "tool_use", ID: "a", Name: "read_file", Input: json.RawMessage(`{"path":"x"}`)},
+			{Type: "tool_use", ID: "b", Name: "nope", Input: json.RawMessage(`{}`)},
+		}},
+		{Role: "user", Content: []anthropicBlock{
+			{Type: "tool_result", ToolUseID: "a", Content: "package x"},
+			{Type: "tool_result", ToolUseID: "b", Content: "Tool not found", IsError: true},
+		}},
+		{Role: "assistant", Content: []anthropicBlock{
+			{Type: "tool_use", ID: "c", Name: "bash", Input: json.RawMessage(`{"command":"rm -rf /"}`)},
+		}},
+		// the denied call is given a result before the next prompt
+		{Role: "user", Content: []anthropicBlock{
+			{Type: "tool_result", ToolUseID: "c", Content: toolDenied, IsError: true},
+			{Type: "text", Text: "no"},
+		}},
+	}, messages)
+}
@@ -0,0 +1,76 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// errNoAzureModels is returned when asked for the models on Azure, the
+// deployments can only be listed with the management API
+var errNoAzureModels = errors.New("Azure OpenAI can't list the deployments, set model or deployment in the config")
+
+// isAzure returns true if the client talks to Azure OpenAI, which serves
+// models from deployments at their own URLs
+func (c *OpenAIClient) isAzure() bool {
+	return c.config.Provider == "azure"
+}
+
+// chatURL returns the URL of the chat completions endpoint for the model
+func (c *OpenAIClient) chatURL(model string) string {
+	if !c.isAzure() {
+		return c.baseURL + "/v1/chat/completions"
+	}
+	return c.azureURL("/openai/deployments/" + url.PathEscape(c.deployment(model)) + "/chat/completions")
+}
+
+// azureURL adds the API version Azure wants on every request to the path
+func (c *OpenAIClient) azureURL(path string) string {
+	return c.baseURL + path + "?api-version=" + url.QueryEscape(c.config.APIVersion)
+}
+
+// deployment returns the Azure deployment that serves the model, the one in
+// the config serves the configured model and any other model is taken to be
+// the name of a deployment
+func (c *OpenAIClient) deployment(model string) string {
+	if model == *c.model && c.config.Deployment != "" {
+		return c.config.Deployment
+	}
+	return model
+}
+
+// setAuth sets the header with the API key, Azure has its own one
+func (c *OpenAIClient) setAuth(req *http.Request) {
+	switch {
+	case c.isAzure():
+		req.Header.Set("Api-Key", c.apiKey)
+	case c.apiKey != "":
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+}
+
+// pingAzure checks the resource can be reached with the key by listing the
+// models it has, the deployment isn't checked as they can't be listed
+func (c *OpenAIClient) pingAzure(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.azureURL("/openai/models"), nil)
+	if err != nil {
+		return err
+	}
+	c.setAuth(req)
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("API error (status %d): %s", res.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package ai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAzure(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		assert.Equal(t, "2024-10-21", r.URL.Query().Get("api-version"))
+		assert.Equal(t, "secret", r.Header.Get("Api-Key"))
+		assert.Empty(t, r.Header.Get("Authorization"))
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}]}`))
+	}))
+	defer srv.Close()
+
+	cfg := config.Default()
+	cfg.Provider, cfg.APIKey, cfg.BaseURL = "azure", "secret", srv.URL+"/openai/"
+	cfg.Model, cfg.Deployment = "gpt-4o", "prod-4o"
+	c, err := NewClient(cfg)
+	assert.NoError(t, err)
+
+	res, err := c.SendMessage(context.Background(), []Message{{Role: "user", Content: "hello"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "hi", res.Content)
+
+	// other models are the names of their deployments
+	_, err = c.SendMessage(WithModel(context.Background(), "cheap-4o-mini"), []Message{{Role: "user", Content: "hello"}})
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.(HealthChecker).Ping(context.Background()))
+	assert.Equal(t, []string{
+		"/openai/deployments/prod-4o/chat/completions",
+		"/openai/deployments/cheap-4o-mini/chat/completions",
+		"/openai/models",
+	}, paths)
+
+	_, err = c.ListModelDetails()
+	assert.ErrorIs(t, err, errNoAzureModels)
+}
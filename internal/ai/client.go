@@ -1,19 +1,33 @@
 package ai
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/penguinpowernz/clai/config"
 )
 
-// NewClient creates a new AI client based on the provider configuration
+// NewClient creates a new AI client based on the provider configuration,
+// with the fallbacks behind it if there are any
 func NewClient(cfg *config.Config) (Provider, error) {
+	if len(cfg.Fallbacks) > 0 {
+		return NewFailoverClient(cfg)
+	}
+	return newClient(cfg)
+}
+
+// newClient creates the client for the provider in the configuration
+func newClient(cfg *config.Config) (Provider, error) {
 	switch cfg.Provider {
 	case "openai":
 		return NewOpenAIClient(cfg)
-	case "ollama":
-		// Ollama uses OpenAI-compatible API
+	case "anthropic":
+		return NewAnthropicClient(cfg)
+	case "azure":
+		// Azure OpenAI is the OpenAI API served from deployments
 		return NewOpenAIClient(cfg)
+	case "ollama":
+		return NewOllamaClient(cfg)
 	case "custom":
 		// Custom providers assumed to be OpenAI-compatible
 		return NewOpenAIClient(cfg)
@@ -21,3 +35,32 @@ func NewClient(cfg *config.Config) (Provider, error) {
 		return nil, fmt.Errorf("unsupported provider: %s", cfg.Provider)
 	}
 }
+
+type modelKey struct{}
+
+// WithModel makes the requests sent with the context use the model instead
+// of the one in the config, for answering some prompts with another model
+func WithModel(ctx context.Context, model string) context.Context {
+	return context.WithValue(ctx, modelKey{}, model)
+}
+
+// ModelFrom returns the model set on the context by WithModel, if any
+func ModelFrom(ctx context.Context) (string, bool) {
+	model, ok := ctx.Value(modelKey{}).(string)
+	return model, ok && model != ""
+}
+
+type systemKey struct{}
+
+// WithSystemPrompt makes the requests sent with the context use the system
+// prompt instead of the one in the config, an empty prompt sends none
+func WithSystemPrompt(ctx context.Context, prompt string) context.Context {
+	return context.WithValue(ctx, systemKey{}, prompt)
+}
+
+// SystemPromptFrom returns the system prompt set on the context by
+// WithSystemPrompt, if any
+func SystemPromptFrom(ctx context.Context) (string, bool) {
+	prompt, ok := ctx.Value(systemKey{}).(string)
+	return prompt, ok
+}
@@ -0,0 +1,53 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Constraint holds the output of the model to a format, only local servers
+// take them: Ollama takes JSON and schemas, llama.cpp grammars as well
+type Constraint struct {
+	JSON    bool            // any JSON
+	Schema  json.RawMessage // JSON matching the schema
+	Grammar string          // text matching the GBNF grammar
+}
+
+type constraintKey struct{}
+
+// WithConstraint holds the responses to the requests sent with the context
+// to the format
+func WithConstraint(ctx context.Context, c Constraint) context.Context {
+	return context.WithValue(ctx, constraintKey{}, c)
+}
+
+// ConstraintFrom returns the constraint set on the context by
+// WithConstraint, if any
+func ConstraintFrom(ctx context.Context) (Constraint, bool) {
+	c, ok := ctx.Value(constraintKey{}).(Constraint)
+	return c, ok
+}
+
+// ollamaFormat returns the format field for the constraint, Ollama doesn't
+// take grammars
+func (c Constraint) ollamaFormat() json.RawMessage {
+	switch {
+	case c.Schema != nil:
+		return c.Schema
+	case c.JSON:
+		return json.RawMessage(`"json"`)
+	}
+	return nil
+}
+
+// openAIResponseFormat returns the response_format field for the
+// constraint, llama.cpp's server takes the same ones as OpenAI
+func (c Constraint) openAIResponseFormat() *openAIResponseFormat {
+	switch {
+	case c.Schema != nil:
+		return &openAIResponseFormat{Type: "json_schema", JSONSchema: &openAIJSONSchema{Name: "response", Schema: c.Schema}}
+	case c.JSON:
+		return &openAIResponseFormat{Type: "json_object"}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConstraint(t *testing.T) {
+	schema := json.RawMessage(`{"type":"object","properties":{"name":{"type":"string"}}}`)
+	messages := []Message{{Role: "user", Content: "name a colour"}}
+
+	request := func(provider string, c Constraint) map[string]any {
+		cfg := config.Default()
+		cfg.Provider, cfg.BaseURL, cfg.Model = provider, "http://localhost:1/v1", "qwen3:8b"
+		cfg.APIKey = "key"
+		client, err := NewClient(cfg)
+		assert.NoError(t, err)
+
+		body, err := client.(Inspector).Inspect(WithConstraint(context.Background(), c), messages)
+		assert.NoError(t, err)
+		var got map[string]any
+		assert.NoError(t, json.Unmarshal(body, &got))
+		return got
+	}
+
+	got := request("ollama", Constraint{JSON: true})
+	assert.Equal(t, "json", got["format"])
+
+	got = request("ollama", Constraint{Schema: schema, Grammar: "root ::= \"x\""})
+	assert.Equal(t, "object", got["format"].(map[string]any)["type"])
+	assert.NotContains(t, got, "grammar", "Ollama doesn't take grammars")
+
+	got = request("custom", Constraint{JSON: true})
+	assert.Equal(t, map[string]any{"type": "json_object"}, got["response_format"])
+
+	got = request("custom", Constraint{Schema: schema})
+	format := got["response_format"].(map[string]any)
+	assert.Equal(t, "json_schema", format["type"])
+	assert.Equal(t, "object", format["json_schema"].(map[string]any)["schema"].(map[string]any)["type"])
+
+	got = request("custom", Constraint{Grammar: "root ::= \"x\""})
+	assert.Equal(t, "root ::= \"x\"", got["grammar"])
+	assert.NotContains(t, got, "response_format")
+
+	got = request("openai", Constraint{Grammar: "root ::= \"x\""})
+	assert.NotContains(t, got, "grammar", "the OpenAI API refuses unknown fields")
+
+	got = request("custom", Constraint{})
+	assert.NotContains(t, got, "response_format")
+	assert.NotContains(t, got, "grammar")
+}
@@ -0,0 +1,171 @@
+package ai
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/penguinpowernz/clai/config"
+)
+
+var debugLog io.Writer
+
+// SetDebugLog makes the clients created after it write every request and
+// response to w in full, for diagnosing servers that don't quite speak the
+// OpenAI API.  Secrets in the headers and the API key are masked.
+func SetDebugLog(w io.Writer) {
+	debugLog = w
+}
+
+// newHTTPClient creates the client for talking to the provider, logging to
+// the debug log if there is one and retrying the requests that failed in a
+// way that might go away
+func newHTTPClient(cfg *config.Config) *http.Client {
+	transport := http.DefaultTransport
+	if debugLog != nil {
+		transport = newDebugTransport(debugLog, transport, cfg.APIKey)
+	}
+	if cfg.Retry.MaxAttempts > 1 {
+		transport = &retryTransport{next: transport, retry: cfg.Retry}
+	}
+	return &http.Client{Transport: transport}
+}
+
+// secretHeaders are masked in the debug log
+var secretHeaders = []string{"Authorization", "Api-Key", "X-Api-Key", "Private-Token", "Cookie", "Set-Cookie"}
+
+// debugTransport writes the requests and responses going through it to the
+// writer, the response body is written as it is read so streams can be seen
+// as they arrive.  Requests are numbered so concurrent ones can be told apart.
+type debugTransport struct {
+	next    http.RoundTripper
+	secrets []string
+	count   atomic.Int64
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newDebugTransport(w io.Writer, next http.RoundTripper, secrets ...string) *debugTransport {
+	t := &debugTransport{next: next, w: w}
+	for _, s := range secrets {
+		if s != "" {
+			t.secrets = append(t.secrets, s)
+		}
+	}
+	return t
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	n := t.count.Add(1)
+	start := time.Now()
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "#%d --> %s %s %s\n", n, start.Format(time.RFC3339), req.Method, req.URL)
+	t.writeHeaders(&b, n, req.Header)
+	fmt.Fprintf(&b, "#%d --> %s\n", n, body)
+	t.write(b.String())
+
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.write(fmt.Sprintf("#%d <-- error after %s: %v\n", n, time.Since(start).Round(time.Millisecond), err))
+		return nil, err
+	}
+
+	b.Reset()
+	fmt.Fprintf(&b, "#%d <-- %s after %s\n", n, res.Status, time.Since(start).Round(time.Millisecond))
+	t.writeHeaders(&b, n, res.Header)
+	t.write(b.String())
+
+	res.Body = &debugBody{ReadCloser: res.Body, t: t, n: n, start: start}
+	return res, nil
+}
+
+func (t *debugTransport) writeHeaders(b *strings.Builder, n int64, h http.Header) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	for _, k := range keys {
+		v := strings.Join(h[k], ", ")
+		if slices.ContainsFunc(secretHeaders, func(s string) bool { return strings.EqualFold(s, k) }) {
+			v = maskSecret(v)
+		}
+		fmt.Fprintf(b, "#%d     %s: %s\n", n, k, v)
+	}
+}
+
+// write writes to the log with the secrets masked
+func (t *debugTransport) write(s string) {
+	for _, secret := range t.secrets {
+		s = strings.ReplaceAll(s, secret, maskSecret(secret))
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	io.WriteString(t.w, s)
+}
+
+// debugBody writes the response body to the log as it is read
+type debugBody struct {
+	io.ReadCloser
+	t     *debugTransport
+	n     int64
+	start time.Time
+	size  int
+	done  bool
+}
+
+func (d *debugBody) Read(p []byte) (int, error) {
+	n, err := d.ReadCloser.Read(p)
+	if n > 0 {
+		d.size += n
+		d.t.write(prefixLines(fmt.Sprintf("#%d <-- ", d.n), string(p[:n])))
+	}
+	if err != nil && !d.done {
+		d.done = true
+		end := "end of body"
+		if err != io.EOF {
+			end = "body read error: " + err.Error()
+		}
+		d.t.write(fmt.Sprintf("#%d <-- %s, %d bytes in %s\n", d.n, end, d.size, time.Since(d.start).Round(time.Millisecond)))
+	}
+	return n, err
+}
+
+// prefixLines puts the prefix in front of every line, so the lines of
+// concurrent responses can be told apart
+func prefixLines(prefix, s string) string {
+	s = strings.TrimSuffix(s, "\n")
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix) + "\n"
+}
+
+// maskSecret keeps the start and end of the secret so it can be recognised
+func maskSecret(s string) string {
+	if scheme, token, ok := strings.Cut(s, " "); ok && strings.EqualFold(scheme, "bearer") {
+		return scheme + " " + maskSecret(token)
+	}
+	if len(s) < 12 {
+		return "****"
+	}
+	return s[:4] + "..." + s[len(s)-4:]
+}
@@ -0,0 +1,50 @@
+package ai
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, `{"model":"x","key":"sk-secret-key-1234"}`, string(body), "the body is passed on")
+		fmt.Fprint(w, "data: one\n\ndata: two\n\n")
+	}))
+	defer srv.Close()
+
+	var log bytes.Buffer
+	client := &http.Client{Transport: newDebugTransport(&log, http.DefaultTransport, "sk-secret-key-1234")}
+
+	req, _ := http.NewRequest("POST", srv.URL+"/v1/chat/completions", strings.NewReader(`{"model":"x","key":"sk-secret-key-1234"}`))
+	req.Header.Set("Authorization", "Bearer sk-secret-key-1234")
+	res, err := client.Do(req)
+	assert.NoError(t, err)
+
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, "data: one\n\ndata: two\n\n", string(body))
+
+	out := log.String()
+	assert.NotContains(t, out, "sk-secret-key-1234")
+	assert.Contains(t, out, "#1 --> ")
+	assert.Contains(t, out, "POST "+srv.URL+"/v1/chat/completions")
+	assert.Contains(t, out, "Authorization: Bearer sk-s...1234")
+	assert.Contains(t, out, `#1 --> {"model":"x","key":"sk-s...1234"}`)
+	assert.Contains(t, out, "#1 <-- 200 OK")
+	assert.Contains(t, out, "#1 <-- data: one\n#1 <-- \n#1 <-- data: two\n")
+	assert.Contains(t, out, "#1 <-- end of body, 22 bytes")
+}
+
+func TestMaskSecret(t *testing.T) {
+	assert.Equal(t, "****", maskSecret("short"))
+	assert.Equal(t, "abcd...mnop", maskSecret("abcdefghijklmnop"))
+	assert.Equal(t, "Bearer abcd...mnop", maskSecret("Bearer abcdefghijklmnop"))
+}
@@ -0,0 +1,212 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/tools"
+)
+
+// FailoverNotice says that a provider failed and the request is being sent
+// to the next one
+type FailoverNotice struct {
+	From, To string // provider/model
+	Err      error
+}
+
+func (n FailoverNotice) String() string {
+	return fmt.Sprintf("%s failed (%s), switching to %s", n.From, n.Err, n.To)
+}
+
+type failoverKey struct{}
+
+// WithFailoverNotice makes f be called each time a request sent with the
+// context is passed on to a fallback provider
+func WithFailoverNotice(ctx context.Context, f func(FailoverNotice)) context.Context {
+	return context.WithValue(ctx, failoverKey{}, f)
+}
+
+// FailoverClient sends the requests to the first provider, and to the
+// fallbacks in order when it fails or doesn't start answering in time.  The
+// main provider is tried first every time, so it is used again as soon as it
+// is back.  Anything about the model, like the list of models, comes from the
+// main provider.
+type FailoverClient struct {
+	providers []Provider
+	names     []string // provider/model, for the notices
+	models    []string // the models of the fallbacks, the main one is in the config
+	timeout   time.Duration
+}
+
+// NewFailoverClient creates the clients for the provider in the config and
+// its fallbacks
+func NewFailoverClient(cfg *config.Config) (*FailoverClient, error) {
+	main, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &FailoverClient{
+		providers: []Provider{main},
+		names:     []string{cfg.Provider + "/" + cfg.Model},
+		models:    []string{""},
+		timeout:   cfg.FailoverTimeout,
+	}
+
+	for i, f := range cfg.Fallbacks {
+		p, err := newClient(cfg.ForFallback(f))
+		if err != nil {
+			return nil, fmt.Errorf("fallbacks[%d]: %w", i, err)
+		}
+		c.providers = append(c.providers, p)
+		c.names = append(c.names, f.Provider+"/"+f.Model)
+		c.models = append(c.models, f.Model)
+	}
+
+	return c, nil
+}
+
+// contextFor returns the context to send the request to the ith provider
+// with, the fallbacks always use their own model
+func (c *FailoverClient) contextFor(ctx context.Context, i int) context.Context {
+	if i == 0 {
+		return ctx
+	}
+	return WithModel(ctx, c.models[i])
+}
+
+// failover tells whoever sent the request that the ith provider failed
+func (c *FailoverClient) failover(ctx context.Context, i int, err error) {
+	notice := FailoverNotice{From: c.names[i], To: c.names[i+1], Err: err}
+	log.Println("[client]", notice)
+	if f, ok := ctx.Value(failoverKey{}).(func(FailoverNotice)); ok {
+		f(notice)
+	}
+}
+
+func (c *FailoverClient) SendMessage(ctx context.Context, messages []Message) (*Response, error) {
+	var errs []error
+	for i, p := range c.providers {
+		res, err := p.SendMessage(c.contextFor(ctx, i), messages)
+		if err == nil || ctx.Err() != nil {
+			return res, err
+		}
+
+		errs = append(errs, err)
+		if i+1 < len(c.providers) {
+			c.failover(ctx, i, err)
+		}
+	}
+	return nil, fmt.Errorf("all providers failed: %w", errors.Join(errs...))
+}
+
+func (c *FailoverClient) StreamMessage(ctx context.Context, messages []Message) (<-chan MessageChunk, error) {
+	var errs []error
+	for i, p := range c.providers {
+		stream, err := c.startStream(c.contextFor(ctx, i), p, messages)
+		if err == nil || ctx.Err() != nil {
+			return stream, err
+		}
+
+		errs = append(errs, err)
+		if i+1 < len(c.providers) {
+			c.failover(ctx, i, err)
+		}
+	}
+	return nil, fmt.Errorf("all providers failed: %w", errors.Join(errs...))
+}
+
+// startStream starts the stream from the provider, giving up if it doesn't
+// start answering within the timeout.  Once it has started it can take as
+// long as it needs.
+func (c *FailoverClient) startStream(ctx context.Context, p Provider, messages []Message) (<-chan MessageChunk, error) {
+	if c.timeout <= 0 {
+		return p.StreamMessage(ctx, messages)
+	}
+
+	// cancelled by the timer if the provider is too slow to answer, or once
+	// the stream is done
+	sctx, cancel := context.WithCancel(ctx)
+	timer := time.AfterFunc(c.timeout, cancel)
+	stream, err := p.StreamMessage(sctx, messages)
+	if !timer.Stop() && ctx.Err() == nil {
+		return nil, fmt.Errorf("no answer within %s", c.timeout)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	out := make(chan MessageChunk)
+	go func() {
+		defer close(out)
+		defer cancel()
+		for chunk := range stream {
+			// the provider closes the stream once sctx is done
+			select {
+			case out <- chunk:
+			case <-sctx.Done():
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (c *FailoverClient) GetModelInfo() ModelInfo {
+	return c.providers[0].GetModelInfo()
+}
+
+func (c *FailoverClient) ListModels() []string {
+	return c.providers[0].ListModels()
+}
+
+func (c *FailoverClient) ListModelDetails() ([]ModelDetails, error) {
+	return c.providers[0].ListModelDetails()
+}
+
+func (c *FailoverClient) SetTools(tools []tools.Tool) {
+	for _, p := range c.providers {
+		p.SetTools(tools)
+	}
+}
+
+// Inspect shows the request that would be sent to the main provider
+func (c *FailoverClient) Inspect(ctx context.Context, messages []Message) ([]byte, error) {
+	insp, ok := c.providers[0].(Inspector)
+	if !ok {
+		return nil, fmt.Errorf("%s can't show its requests", c.names[0])
+	}
+	return insp.Inspect(ctx, messages)
+}
+
+// Ping checks that one of the providers is up, the main provider's error is
+// returned if none of them are
+func (c *FailoverClient) Ping(ctx context.Context) error {
+	var first error
+	for i, p := range c.providers {
+		hc, ok := p.(HealthChecker)
+		if !ok {
+			return nil
+		}
+		err := hc.Ping(c.contextFor(ctx, i))
+		if err == nil {
+			return nil
+		}
+		if i == 0 {
+			first = err
+		}
+	}
+	return first
+}
+
+// WarmUp loads the model of the main provider
+func (c *FailoverClient) WarmUp(ctx context.Context) error {
+	if w, ok := c.providers[0].(WarmUpper); ok {
+		return w.WarmUp(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailover(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"message":"context too long"}}`))
+	}))
+	defer primary.Close()
+
+	var model string
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openAIRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		model = req.Model
+		w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\ndata: [DONE]\n\n"))
+	}))
+	defer fallback.Close()
+
+	cfg := config.Default()
+	cfg.Provider, cfg.BaseURL, cfg.Model = "custom", primary.URL, "local"
+	cfg.Fallbacks = []config.Fallback{{Provider: "custom", Model: "remote", BaseURL: fallback.URL}}
+	c, err := NewClient(cfg)
+	assert.NoError(t, err)
+
+	var notices []FailoverNotice
+	ctx := WithFailoverNotice(WithModel(context.Background(), "local"), func(n FailoverNotice) { notices = append(notices, n) })
+	stream, err := c.StreamMessage(ctx, []Message{{Role: "user", Content: "hi"}})
+	assert.NoError(t, err)
+
+	var content string
+	for chunk := range stream {
+		content += chunk.Content
+	}
+	assert.Equal(t, "hi", content)
+	assert.Equal(t, "remote", model)
+
+	assert.Len(t, notices, 1)
+	assert.Equal(t, "custom/local", notices[0].From)
+	assert.Equal(t, "custom/remote", notices[0].To)
+	assert.Equal(t, "local", c.GetModelInfo().Name)
+
+	// the error of every provider is given when they all fail
+	cfg.Fallbacks[0].BaseURL = primary.URL
+	c, _ = NewClient(cfg)
+	_, err = c.StreamMessage(context.Background(), []Message{{Role: "user", Content: "hi"}})
+	var apiErr APIError
+	assert.ErrorAs(t, err, &apiErr)
+	assert.ErrorContains(t, err, "all providers failed")
+}
+
+func TestFailoverTimeout(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer slow.Close()
+
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"quick\"}}]}\n\ndata: [DONE]\n\n"))
+	}))
+	defer fast.Close()
+
+	cfg := config.Default()
+	cfg.Provider, cfg.BaseURL, cfg.Model = "custom", slow.URL, "local"
+	cfg.Fallbacks = []config.Fallback{{Provider: "custom", Model: "remote", BaseURL: fast.URL}}
+	cfg.FailoverTimeout = 50 * time.Millisecond
+	c, err := NewClient(cfg)
+	assert.NoError(t, err)
+
+	start := time.Now()
+	stream, err := c.StreamMessage(context.Background(), []Message{{Role: "user", Content: "hi"}})
+	assert.NoError(t, err)
+	for chunk := range stream {
+		if chunk.Type() == ChunkMessage {
+			assert.Equal(t, "quick", chunk.Content)
+		}
+	}
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+// streamProvider only streams, it keeps the context of the last request
+type streamProvider struct {
+	Provider
+	ctx context.Context
+}
+
+func (p *streamProvider) StreamMessage(ctx context.Context, messages []Message) (<-chan MessageChunk, error) {
+	p.ctx = ctx
+	stream := make(chan MessageChunk, 1)
+	stream <- NewChunk(ChunkMessage, "hi")
+	close(stream)
+	return stream, nil
+}
+
+func TestFailoverStreamDone(t *testing.T) {
+	p := &streamProvider{}
+	c := &FailoverClient{providers: []Provider{p}, names: []string{"custom/local"}, models: []string{""}, timeout: time.Minute}
+
+	stream, err := c.StreamMessage(context.Background(), nil)
+	assert.NoError(t, err)
+	for range stream {
+	}
+
+	// the request's context is let go once the stream is done
+	assert.Error(t, p.ctx.Err())
+}
@@ -0,0 +1,159 @@
+package ai
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// HealthChecker is implemented by providers that can check the server is up
+// without generating anything
+type HealthChecker interface {
+	Ping(ctx context.Context) error
+}
+
+// WarmUpper is implemented by providers that can load the model before the
+// first prompt, so the first response isn't slowed down by it
+type WarmUpper interface {
+	WarmUp(ctx context.Context) error
+}
+
+// Ping lists the models on the server, and checks the current one is there
+// if the server gave a list
+func (c *OpenAIClient) Ping(ctx context.Context) error {
+	if c.isAzure() {
+		return c.pingAzure(ctx)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/v1/models", nil)
+	if err != nil {
+		return err
+	}
+	c.setAuth(req)
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("API error (status %d): %s", res.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var models struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&models); err != nil {
+		log.Println("[client] can't read the model list, skipping the model check:", err)
+		return nil
+	}
+
+	if len(models.Data) == 0 {
+		return nil
+	}
+
+	for _, m := range models.Data {
+		if sameModel(m.ID, *c.model) {
+			return nil
+		}
+	}
+
+	return ModelNotFoundError{Model: *c.model}
+}
+
+// ModelNotFoundError is returned when the server doesn't have the model,
+// which happens when it was removed from Ollama
+type ModelNotFoundError struct {
+	Model string
+}
+
+func (e ModelNotFoundError) Error() string {
+	return fmt.Sprintf("model %s is not available on the server", e.Model)
+}
+
+// APIError is a request the provider turned down, Message is the reason it
+// gave for it
+type APIError struct {
+	Status  int
+	Body    string
+	Message string
+}
+
+func (e APIError) Error() string {
+	return fmt.Sprintf("API error (status %d): %s", e.Status, e.Body)
+}
+
+// apiError makes the error for a failed request, a 404 that mentions the
+// model means the server doesn't have it
+func apiError(status int, body []byte, model string) error {
+	if status == http.StatusNotFound && strings.Contains(strings.ToLower(string(body)), "model") {
+		return ModelNotFoundError{Model: model}
+	}
+	return APIError{Status: status, Body: string(body), Message: errorMessage(body)}
+}
+
+// IsUnreachable returns true if the request failed because the provider
+// couldn't be reached, trying again later might work
+func IsUnreachable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var apiErr APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.Status {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// errorMessage finds the message in the body of an error response, the
+// providers put it in different places
+func errorMessage(body []byte) string {
+	var nested struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &nested) == nil && nested.Error.Message != "" {
+		return nested.Error.Message
+	}
+
+	var flat struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &flat) == nil && flat.Error+flat.Message != "" {
+		return cmp.Or(flat.Error, flat.Message)
+	}
+
+	return strings.TrimSpace(string(body))
+}
+
+// sameModel compares model names, an Ollama model without a tag is the
+// :latest one
+func sameModel(a, b string) bool {
+	if !strings.Contains(a, ":") {
+		a += ":latest"
+	}
+	if !strings.Contains(b, ":") {
+		b += ":latest"
+	}
+	return a == b
+}
@@ -0,0 +1,67 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSameModel(t *testing.T) {
+	assert.True(t, sameModel("llama3", "llama3:latest"))
+	assert.True(t, sameModel("llama3:8b", "llama3:8b"))
+	assert.False(t, sameModel("llama3:8b", "llama3"))
+	assert.False(t, sameModel("gpt-4o", "gpt-4o-mini"))
+}
+
+func TestPing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/models", r.URL.Path)
+		w.Write([]byte(`{"data":[{"id":"llama3:latest"},{"id":"qwen2.5-coder:7b"}]}`))
+	}))
+	defer srv.Close()
+
+	c, _ := NewOpenAIClient(&config.Config{Provider: "custom", Model: "llama3", BaseURL: srv.URL})
+	assert.NoError(t, c.Ping(context.Background()))
+
+	c, _ = NewOpenAIClient(&config.Config{Provider: "custom", Model: "mistral", BaseURL: srv.URL})
+	assert.Equal(t, ModelNotFoundError{Model: "mistral"}, c.Ping(context.Background()))
+}
+
+func TestModelNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"model \"mistral\" not found, try pulling it first"}`))
+	}))
+	defer srv.Close()
+
+	c, _ := NewOpenAIClient(&config.Config{Provider: "custom", Model: "mistral", BaseURL: srv.URL})
+	_, err := c.StreamMessage(context.Background(), []Message{{Role: "user", Content: "hi"}})
+
+	var missing ModelNotFoundError
+	assert.True(t, errors.As(err, &missing))
+	assert.Equal(t, "mistral", missing.Model)
+
+	assert.EqualError(t, apiError(http.StatusNotFound, []byte("404 page not found"), "x"), "API error (status 404): 404 page not found")
+
+	var apiErr APIError
+	assert.True(t, errors.As(apiError(http.StatusUnauthorized, []byte(`{"error":{"message":"Incorrect API key provided"}}`), "x"), &apiErr))
+	assert.Equal(t, "Incorrect API key provided", apiErr.Message)
+	assert.Equal(t, "slow down", errorMessage([]byte(`{"error":"slow down"}`)))
+	assert.Equal(t, "Bad Gateway", errorMessage([]byte("Bad Gateway\n")))
+}
+
+func TestIsUnreachable(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	assert.True(t, IsUnreachable(fmt.Errorf("failed to send request: %w", refused)))
+	assert.True(t, IsUnreachable(APIError{Status: http.StatusServiceUnavailable}))
+	assert.False(t, IsUnreachable(APIError{Status: http.StatusUnauthorized}))
+	assert.False(t, IsUnreachable(context.Canceled))
+	assert.False(t, IsUnreachable(nil))
+}
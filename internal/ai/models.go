@@ -0,0 +1,174 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/penguinpowernz/clai/config"
+)
+
+// ModelCapabilities describes what a model can do and what it costs to use
+type ModelCapabilities struct {
+	ContextWindow  int      // max tokens the model can see at once
+	SupportsTools  bool     // whether the model can do function calling
+	SupportsVision bool     // whether the model accepts images
+	InputPrice     float64  // USD per million prompt tokens
+	OutputPrice    float64  // USD per million completion tokens
+	Stop           []string // sequences that end the response
+}
+
+// Cost returns the estimated cost in USD for the given token counts
+func (mc ModelCapabilities) Cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*mc.InputPrice + float64(completionTokens)*mc.OutputPrice) / 1_000_000
+}
+
+// Summary returns a short human readable description of the capabilities
+func (mc ModelCapabilities) Summary() string {
+	var parts []string
+	if mc.ContextWindow > 0 {
+		parts = append(parts, fmt.Sprintf("%dk ctx", mc.ContextWindow/1024))
+	}
+	if mc.SupportsTools {
+		parts = append(parts, "tools")
+	}
+	if mc.SupportsVision {
+		parts = append(parts, "vision")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// defaultContextWindow is used for models we know nothing about
+const defaultContextWindow = 8192
+
+// knownModels maps model name prefixes to their capabilities, the longest
+// matching prefix wins so more specific entries can override families
+var knownModels = map[string]ModelCapabilities{
+	// OpenAI
+	"gpt-4o":        {ContextWindow: 128000, SupportsTools: true, SupportsVision: true, InputPrice: 2.50, OutputPrice: 10.00},
+	"gpt-4o-mini":   {ContextWindow: 128000, SupportsTools: true, SupportsVision: true, InputPrice: 0.15, OutputPrice: 0.60},
+	"gpt-4.1":       {ContextWindow: 1047576, SupportsTools: true, SupportsVision: true, InputPrice: 2.00, OutputPrice: 8.00},
+	"gpt-4.1-mini":  {ContextWindow: 1047576, SupportsTools: true, SupportsVision: true, InputPrice: 0.40, OutputPrice: 1.60},
+	"gpt-4-turbo":   {ContextWindow: 128000, SupportsTools: true, SupportsVision: true, InputPrice: 10.00, OutputPrice: 30.00},
+	"gpt-3.5-turbo": {ContextWindow: 16385, SupportsTools: true, InputPrice: 0.50, OutputPrice: 1.50},
+	"o3":            {ContextWindow: 200000, SupportsTools: true, SupportsVision: true, InputPrice: 2.00, OutputPrice: 8.00},
+	"o4-mini":       {ContextWindow: 200000, SupportsTools: true, SupportsVision: true, InputPrice: 1.10, OutputPrice: 4.40},
+
+	// Anthropic
+	"claude-opus-4":     {ContextWindow: 200000, SupportsTools: true, SupportsVision: true, InputPrice: 15.00, OutputPrice: 75.00},
+	"claude-sonnet-4":   {ContextWindow: 200000, SupportsTools: true, SupportsVision: true, InputPrice: 3.00, OutputPrice: 15.00},
+	"claude-haiku-4":    {ContextWindow: 200000, SupportsTools: true, SupportsVision: true, InputPrice: 1.00, OutputPrice: 5.00},
+	"claude-3-7-sonnet": {ContextWindow: 200000, SupportsTools: true, SupportsVision: true, InputPrice: 3.00, OutputPrice: 15.00},
+	"claude-3-5-sonnet": {ContextWindow: 200000, SupportsTools: true, SupportsVision: true, InputPrice: 3.00, OutputPrice: 15.00},
+	"claude-3-5-haiku":  {ContextWindow: 200000, SupportsTools: true, InputPrice: 0.80, OutputPrice: 4.00},
+
+	// local models served by ollama are free to run
+	"gpt-oss":         {ContextWindow: 131072, SupportsTools: true},
+	"llama3.1":        {ContextWindow: 131072, SupportsTools: true},
+	"llama3.2":        {ContextWindow: 131072, SupportsTools: true},
+	"llama3.2-vision": {ContextWindow: 131072, SupportsVision: true},
+	"llama3":          {ContextWindow: 8192},
+	"qwen3":           {ContextWindow: 40960, SupportsTools: true},
+	"qwen2.5":         {ContextWindow: 32768, SupportsTools: true},
+	"qwen2.5-coder":   {ContextWindow: 32768, SupportsTools: true},
+	"deepseek-r1":     {ContextWindow: 131072, SupportsTools: true},
+	"mistral":         {ContextWindow: 32768, SupportsTools: true},
+	"mistral-nemo":    {ContextWindow: 131072, SupportsTools: true},
+	"gemma3":          {ContextWindow: 131072, SupportsVision: true},
+	"phi4":            {ContextWindow: 16384},
+	"codellama":       {ContextWindow: 16384},
+	"llava":           {ContextWindow: 4096, SupportsVision: true},
+}
+
+// LookupModel returns the capabilities of the given model, taking into account
+// any overrides from the config
+func LookupModel(cfg *config.Config, model string) ModelCapabilities {
+	caps, found := lookupKnownModel(model)
+	if !found {
+		caps = ModelCapabilities{ContextWindow: defaultContextWindow}
+	}
+
+	if cfg == nil {
+		return caps
+	}
+
+	// Ollama only sees as much as the window it loads the model with
+	if cfg.Provider == "ollama" && cfg.NumCtx > 0 {
+		caps.ContextWindow = cfg.NumCtx
+	}
+
+	spec, ok := modelSpec(cfg, model)
+	if !ok {
+		return caps
+	}
+
+	if spec.ContextWindow > 0 {
+		caps.ContextWindow = spec.ContextWindow
+	}
+	if spec.Tools != nil {
+		caps.SupportsTools = *spec.Tools
+	}
+	if spec.Vision != nil {
+		caps.SupportsVision = *spec.Vision
+	}
+	if spec.InputPrice != nil {
+		caps.InputPrice = *spec.InputPrice
+	}
+	if spec.OutputPrice != nil {
+		caps.OutputPrice = *spec.OutputPrice
+	}
+	if spec.Stop != nil {
+		caps.Stop = spec.Stop
+	}
+
+	return caps
+}
+
+// modelSpec returns the overrides in the config for the model
+func modelSpec(cfg *config.Config, model string) (config.ModelSpec, bool) {
+	spec, ok := cfg.Models[model]
+	if !ok {
+		// also allow overrides for the model without the tag (e.g. qwen3 for qwen3:8b)
+		spec, ok = cfg.Models[baseModelName(model)]
+	}
+	return spec, ok
+}
+
+func lookupKnownModel(model string) (ModelCapabilities, bool) {
+	name := strings.ToLower(baseModelName(model))
+
+	var best string
+	for prefix := range knownModels {
+		if strings.HasPrefix(name, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+
+	if best == "" {
+		return ModelCapabilities{}, false
+	}
+
+	return knownModels[best], true
+}
+
+// baseModelName strips the ollama style tag and any namespace from the model
+// name, so "library/qwen3:8b" becomes "qwen3"
+func baseModelName(model string) string {
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	if i := strings.Index(model, ":"); i >= 0 {
+		model = model[:i]
+	}
+	return model
+}
+
+// EstimateTokens gives a rough token count for the given text, about 4
+// characters per token is close enough for budgeting
+func EstimateTokens(s string) int {
+	return EstimateTokensLen(len(s))
+}
+
+// EstimateTokensLen is EstimateTokens for text that is n bytes long
+func EstimateTokensLen(n int) int {
+	return (n + 3) / 4
+}
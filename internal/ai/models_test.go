@@ -0,0 +1,34 @@
+package ai
+
+import (
+	"testing"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupModel(t *testing.T) {
+	caps := LookupModel(nil, "gpt-4o-mini-2024-07-18")
+	assert.Equal(t, 0.15, caps.InputPrice)
+	assert.True(t, caps.SupportsTools)
+
+	caps = LookupModel(nil, "qwen2.5-coder:7b")
+	assert.Equal(t, 32768, caps.ContextWindow)
+
+	caps = LookupModel(nil, "something-unknown:latest")
+	assert.Equal(t, defaultContextWindow, caps.ContextWindow)
+	assert.False(t, caps.SupportsTools)
+
+	no := false
+	price := 1.0
+	cfg := config.Default()
+	cfg.Models = map[string]config.ModelSpec{
+		"qwen3": {ContextWindow: 1000, Tools: &no, InputPrice: &price},
+	}
+
+	caps = LookupModel(cfg, "qwen3:8b")
+	assert.Equal(t, 1000, caps.ContextWindow)
+	assert.False(t, caps.SupportsTools)
+	assert.Equal(t, 1.0, caps.InputPrice)
+	assert.Equal(t, 0.000002, caps.Cost(2, 0))
+}
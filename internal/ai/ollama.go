@@ -0,0 +1,517 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/tools"
+)
+
+// OllamaClient talks to Ollama's own API rather than its OpenAI compatible
+// one, which ignores the options and doesn't say much about the models
+type OllamaClient struct {
+	config     *config.Config
+	httpClient *http.Client
+	baseURL    string
+	apiKey     string
+	model      *string // pointer to model name in the config to allow us to change it for this session
+
+	toolsMu sync.Mutex // the tools can change between requests
+	tools   []tools.Tool
+}
+
+func NewOllamaClient(cfg *config.Config) (*OllamaClient, error) {
+	// the OpenAI compatible API is under /v1, the base URL may still point there
+	baseURL := strings.TrimSuffix(strings.TrimSuffix(cfg.BaseURL, "/"), "/v1")
+
+	return &OllamaClient{
+		config:     cfg,
+		httpClient: newHTTPClient(cfg),
+		baseURL:    baseURL,
+		apiKey:     cfg.APIKey,
+		model:      &cfg.Model,
+	}, nil
+}
+
+func (c *OllamaClient) SendMessage(ctx context.Context, messages []Message) (*Response, error) {
+	reqBody := c.newRequest(ctx, messages, false)
+
+	start := time.Now()
+	resp, err := c.post(ctx, "/api/chat", reqBody, reqBody.Model)
+	if err != nil {
+		reportRequest(reqBody.Model, start, err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result ollamaResponse
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	reportRequest(reqBody.Model, start, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	reportOllamaUsage(reqBody, &result, len(result.Message.Content))
+
+	res := &Response{
+		Content:      result.Message.Content,
+		TokensUsed:   result.PromptEvalCount + result.EvalCount,
+		FinishReason: result.DoneReason,
+	}
+	for _, call := range result.Message.ToolCalls {
+		res.ToolUses = append(res.ToolUses, ToolUse{ID: toolCallID(call.ID), Name: call.Function.Name, Input: call.Function.Arguments})
+	}
+
+	return res, nil
+}
+
+func (c *OllamaClient) StreamMessage(ctx context.Context, messages []Message) (<-chan MessageChunk, error) {
+	reqBody := c.newRequest(ctx, messages, true)
+
+	start := time.Now()
+	resp, err := c.post(ctx, "/api/chat", reqBody, reqBody.Model)
+	if err != nil {
+		reportRequest(reqBody.Model, start, err)
+		return nil, err
+	}
+
+	streamChan := make(chan MessageChunk, streamBuffer)
+
+	go func() {
+		defer close(streamChan)
+		defer resp.Body.Close()
+
+		send := func(chunk MessageChunk) bool {
+			select {
+			case streamChan <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// a stream that was stopped early still used the model, the usage is
+		// always the last chunk
+		var last ollamaResponse
+		var generated int
+		defer func() { send(NewUsageChunk(reportOllamaUsage(reqBody, &last, generated))) }()
+
+		var streamErr error
+		defer func() { reportRequest(reqBody.Model, start, streamErr) }()
+
+		// each line is a JSON object with the next part of the message
+		calledTools := false
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(nil, 10*1024*1024)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+
+			var chunk ollamaResponse
+			if err := json.Unmarshal(line, &chunk); err != nil {
+				log.Printf("[client] Failed to parse chunk: %v\n", err)
+				continue
+			}
+
+			if chunk.Error != "" {
+				log.Println("[client] error in stream:", chunk.Error)
+				streamErr = errors.New(chunk.Error)
+				send(NewChunk(ChunkError, chunk.Error))
+				return
+			}
+
+			msg := chunk.Message
+			generated += len(msg.Content) + len(msg.Thinking)
+
+			if msg.Thinking != "" && !send(NewChunk(ChunkThink, msg.Thinking)) {
+				return
+			}
+
+			if msg.Content != "" && !send(NewChunk(ChunkMessage, msg.Content)) {
+				return
+			}
+
+			for _, call := range msg.ToolCalls {
+				calledTools = true
+				tc := &ToolCall{ID: toolCallID(call.ID), Name: call.Function.Name, Input: json.RawMessage(toolInput(string(call.Function.Arguments)))}
+				generated += len(tc.Name) + len(tc.Input)
+				log.Printf("[client] tool call %s %s", tc.Name, tc.Input)
+				if !send(NewToolCallChunk(tc)) {
+					return
+				}
+			}
+
+			if chunk.Done {
+				last = chunk
+				reason := chunk.DoneReason
+				if calledTools && reason == "stop" {
+					reason = "tool_calls"
+				}
+				send(NewChunk(ChunkFinish, reason))
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil && ctx.Err() == nil {
+			log.Println("[client] stream read error:", err)
+			streamErr = err
+			send(NewChunk(ChunkError, err.Error()))
+		}
+	}()
+
+	return streamChan, nil
+}
+
+// post sends the request to the path, the response is only returned if it
+// was a success
+func (c *OllamaClient) post(ctx context.Context, path string, body any, model string) (*http.Response, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	log.Println("[client] sending request: ", string(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	c.setAuth(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, apiError(resp.StatusCode, body, model)
+	}
+
+	return resp, nil
+}
+
+// setAuth sets the key for when Ollama is behind a proxy that wants one
+func (c *OllamaClient) setAuth(req *http.Request) {
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+}
+
+// newRequest builds the request for the messages, with the system prompt
+// prepended if there is one
+func (c *OllamaClient) newRequest(ctx context.Context, messages []Message, stream bool) ollamaRequest {
+	model := c.modelFor(ctx)
+
+	system := c.config.SystemPrompt
+	if prompt, ok := SystemPromptFrom(ctx); ok {
+		system = prompt
+	}
+
+	var format json.RawMessage
+	if constraint, ok := ConstraintFrom(ctx); ok {
+		if constraint.Grammar != "" {
+			log.Println("[client] Ollama doesn't take grammars, sending the request without it")
+		}
+		format = constraint.ollamaFormat()
+	}
+
+	c.toolsMu.Lock()
+	defer c.toolsMu.Unlock()
+
+	return ollamaRequest{
+		Model:     model,
+		Messages:  convertToOllamaMessages(system, messages),
+		Stream:    stream,
+		Tools:     c.tools,
+		Format:    format,
+		Options:   c.options(model),
+		KeepAlive: c.config.KeepAlive,
+	}
+}
+
+// options are the settings the model is run with, the ones from the model's
+// options in the config go over the rest
+func (c *OllamaClient) options(model string) map[string]any {
+	opts := map[string]any{}
+	temperature, seed := c.config.Sampling()
+	if temperature != nil {
+		opts["temperature"] = *temperature
+	}
+	if seed != nil {
+		opts["seed"] = *seed
+	}
+	if c.config.MaxTokens > 0 {
+		opts["num_predict"] = c.config.MaxTokens
+	}
+
+	caps := LookupModel(c.config, model)
+	if stop := caps.Stop; len(stop) > 0 {
+		opts["stop"] = stop
+	}
+
+	// Ollama's default window is left alone unless one was set, it is what
+	// decides how much memory the model takes
+	spec, _ := modelSpec(c.config, model)
+	if c.config.NumCtx > 0 || spec.ContextWindow > 0 {
+		opts["num_ctx"] = caps.ContextWindow
+	}
+
+	maps.Copy(opts, spec.Options)
+	return opts
+}
+
+// Inspect returns the body of the streaming request that would be sent for
+// the messages
+func (c *OllamaClient) Inspect(ctx context.Context, messages []Message) ([]byte, error) {
+	return json.MarshalIndent(c.newRequest(ctx, messages, true), "", "  ")
+}
+
+func (c *OllamaClient) modelFor(ctx context.Context) string {
+	if model, ok := ModelFrom(ctx); ok {
+		return model
+	}
+	return *c.model
+}
+
+func (c *OllamaClient) SetTools(tools []tools.Tool) {
+	c.toolsMu.Lock()
+	defer c.toolsMu.Unlock()
+	c.tools = tools
+}
+
+func (c *OllamaClient) ListModels() []string {
+	details, err := c.ListModelDetails()
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var models []string
+	for _, model := range details {
+		models = append(models, fmt.Sprintf("%s (%s parameters, %0.1fGB)", model.Name, model.ParameterSize, float64(model.Size)/1024/1024/1024))
+	}
+
+	return models
+}
+
+// ListModelDetails returns the models that have been pulled, along with
+// their size, quantization and when they were last changed
+func (c *OllamaClient) ListModelDetails() ([]ModelDetails, error) {
+	return c.listModels(context.Background())
+}
+
+func (c *OllamaClient) listModels(ctx context.Context) ([]ModelDetails, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	c.setAuth(req)
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return nil, fmt.Errorf("listing models: %w", apiError(res.StatusCode, body, ""))
+	}
+
+	var tags struct {
+		Models []struct {
+			Name       string    `json:"name"`
+			Size       int64     `json:"size"`
+			ModifiedAt time.Time `json:"modified_at"`
+			Details    struct {
+				ParameterSize string `json:"parameter_size"`
+				Quantization  string `json:"quantization_level"`
+			} `json:"details"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("listing models: %w", err)
+	}
+
+	var models []ModelDetails
+	for _, model := range tags.Models {
+		models = append(models, ModelDetails{
+			Name:          model.Name,
+			ParameterSize: model.Details.ParameterSize,
+			Quantization:  model.Details.Quantization,
+			Size:          model.Size,
+			Modified:      model.ModifiedAt,
+		})
+	}
+
+	return models, nil
+}
+
+// Ping lists the models that have been pulled and checks the current one is
+// one of them
+func (c *OllamaClient) Ping(ctx context.Context) error {
+	models, err := c.listModels(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range models {
+		if sameModel(m.Name, *c.model) {
+			return nil
+		}
+	}
+
+	return ModelNotFoundError{Model: *c.model}
+}
+
+// WarmUp asks Ollama to load the model into memory and keep it there for
+// keep_alive
+func (c *OllamaClient) WarmUp(ctx context.Context) error {
+	// a request without messages only loads the model
+	body := ollamaRequest{
+		Model:     *c.model,
+		Messages:  []ollamaMessage{},
+		Options:   c.options(*c.model),
+		KeepAlive: c.config.KeepAlive,
+	}
+
+	res, err := c.post(ctx, "/api/chat", body, *c.model)
+	if err != nil {
+		return fmt.Errorf("failed to load the model: %w", err)
+	}
+	res.Body.Close()
+
+	return nil
+}
+
+func (c *OllamaClient) GetModelInfo() ModelInfo {
+	return ModelInfo{
+		Name:              *c.model,
+		Provider:          c.config.Provider,
+		MaxTokens:         c.config.MaxTokens,
+		SupportsStreaming: true,
+		ModelCapabilities: LookupModel(c.config, *c.model),
+	}
+}
+
+// toolCallID returns the ID the provider gave the tool call, older Ollama
+// versions and some OpenAI compatible servers don't give one so one is made up
+// to tie the result to the call
+func toolCallID(id string) string {
+	if id != "" {
+		return id
+	}
+	return "call_" + strings.ReplaceAll(uuid.New().String(), "-", "")[:16]
+}
+
+// convertToOllamaMessages turns the messages into Ollama's, tool calls are
+// sent as tool_calls and their results name the tool they came from
+func convertToOllamaMessages(system string, messages []Message) []ollamaMessage {
+	var result []ollamaMessage
+	if system != "" {
+		result = append(result, ollamaMessage{Role: "system", Content: system})
+	}
+
+	names := map[string]string{} // the tools called, by the ID of the call
+	for _, msg := range messages {
+		switch {
+		case msg.Role == "assistant" && msg.IsToolCall():
+			var calls []ollamaToolCall
+			for _, tc := range msg.Calls() {
+				input, err := json.Marshal(tc.Input)
+				if err != nil {
+					input = nil
+				}
+				names[tc.ID] = tc.Name
+
+				call := ollamaToolCall{ID: tc.ID}
+				call.Function.Name = tc.Name
+				call.Function.Arguments = json.RawMessage(toolInput(string(input)))
+				calls = append(calls, call)
+			}
+
+			// the calls from one response go in one message
+			if n := len(result); n > 0 && result[n-1].Role == "assistant" && len(result[n-1].ToolCalls) > 0 {
+				result[n-1].ToolCalls = append(result[n-1].ToolCalls, calls...)
+				continue
+			}
+			result = append(result, ollamaMessage{Role: "assistant", ToolCalls: calls})
+
+		case msg.Role == "tool":
+			result = append(result, ollamaMessage{Role: "tool", Content: msg.Content, ToolName: names[msg.ToolCallID]})
+
+		default:
+			result = append(result, ollamaMessage{Role: msg.Role, Content: msg.Content})
+		}
+	}
+
+	return result
+}
+
+// reportOllamaUsage passes on the token counts Ollama gave at the end of the
+// response, or an estimate from the request and the number of bytes
+// generated if it didn't get that far
+func reportOllamaUsage(req ollamaRequest, res *ollamaResponse, generated int) Usage {
+	if res.PromptEvalCount+res.EvalCount > 0 {
+		return Usage{PromptTokens: res.PromptEvalCount, CompletionTokens: res.EvalCount}.report(req.Model)
+	}
+
+	var prompt int
+	for _, msg := range req.Messages {
+		prompt += EstimateTokens(msg.Content)
+	}
+	return Usage{PromptTokens: prompt, CompletionTokens: EstimateTokensLen(generated), Estimated: true}.report(req.Model)
+}
+
+// Ollama API types
+type ollamaRequest struct {
+	Model     string          `json:"model"`
+	Messages  []ollamaMessage `json:"messages"`
+	Stream    bool            `json:"stream"`
+	Tools     []tools.Tool    `json:"tools,omitempty"`
+	Format    json.RawMessage `json:"format,omitempty"` // "json" or a JSON schema
+	Options   map[string]any  `json:"options,omitempty"`
+	KeepAlive string          `json:"keep_alive,omitempty"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Thinking  string           `json:"thinking,omitempty"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type ollamaToolCall struct {
+	ID       string `json:"id,omitempty"`
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaResponse struct {
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
@@ -0,0 +1,104 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOllamaStream(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/chat", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+
+		w.Write([]byte(`{"message":{"role":"assistant","content":"","thinking":"hmm"},"done":false}` + "\n" +
+			`{"message":{"role":"assistant","content":"Looking"},"done":false}` + "\n" +
+			`{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"read_file","arguments":{"path":"main.go"}}}]},"done":false}` + "\n" +
+			`{"message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":12,"eval_count":30}` + "\n"))
+	}))
+	defer srv.Close()
+
+	cfg := config.Default()
+	cfg.BaseURL, cfg.Model, cfg.SystemPrompt = srv.URL+"/v1", "qwen3:8b", "be brief"
+	cfg.KeepAlive, cfg.NumCtx = "30m", 16384
+	cfg.Models = map[string]config.ModelSpec{"qwen3": {Options: map[string]any{"top_k": 20}}}
+	c, err := NewClient(cfg)
+	assert.NoError(t, err)
+
+	stream, err := c.StreamMessage(context.Background(), []Message{
+		{Role: "user", Content: "what's in go.mod?"},
+		{Role: "assistant", ToolCallID: "call_1", ToolCall: &ToolUse{ID: "call_1", Name: "read_file", Input: map[string]any{"path": "go.mod"}}},
+		{Role: "tool", ToolCallID: "call_1", Content: "module x"},
+		{Role: "user", Content: "and main.go?"},
+	})
+	assert.NoError(t, err)
+
+	var chunks []MessageChunk
+	for chunk := range stream {
+		chunks = append(chunks, chunk)
+	}
+
+	assert.Len(t, chunks, 5)
+	assert.Equal(t, NewChunk(ChunkThink, "hmm"), chunks[0])
+	assert.Equal(t, NewChunk(ChunkMessage, "Looking"), chunks[1])
+	assert.Equal(t, "read_file", chunks[2].ToolCall.Name)
+	assert.JSONEq(t, `{"path":"main.go"}`, string(chunks[2].ToolCall.Input))
+	assert.NotEmpty(t, chunks[2].ToolCall.ID)
+	assert.Equal(t, NewChunk(ChunkFinish, "tool_calls"), chunks[3])
+	assert.Equal(t, NewUsageChunk(Usage{PromptTokens: 12, CompletionTokens: 30}), chunks[4])
+
+	assert.Equal(t, "30m", got["keep_alive"])
+	assert.Equal(t, map[string]any{"temperature": 0.7, "num_predict": 4096.0, "num_ctx": 16384.0, "top_k": 20.0}, got["options"])
+
+	messages := got["messages"].([]any)
+	assert.Len(t, messages, 5)
+	assert.Equal(t, map[string]any{"role": "system", "content": "be brief"}, messages[0])
+	assert.Equal(t, "read_file", messages[2].(map[string]any)["tool_calls"].([]any)[0].(map[string]any)["function"].(map[string]any)["name"])
+	assert.Equal(t, map[string]any{"role": "tool", "content": "module x", "tool_name": "read_file"}, messages[3])
+
+	// clai fits the conversation into the window Ollama loads the model with
+	assert.Equal(t, 16384, c.GetModelInfo().ContextWindow)
+}
+
+func TestOllamaModels(t *testing.T) {
+	var warmed map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			w.Write([]byte(`{"models":[{"name":"llama3:latest","modified_at":"2025-05-01T10:00:00Z","size":4661224676,"details":{"parameter_size":"8.0B","quantization_level":"Q4_0"}}]}`))
+		case "/api/chat":
+			json.NewDecoder(r.Body).Decode(&warmed)
+			w.Write([]byte(`{"done":true,"done_reason":"load"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c, _ := NewOllamaClient(&config.Config{Provider: "ollama", Model: "llama3", BaseURL: srv.URL, KeepAlive: "30m"})
+	models, err := c.ListModelDetails()
+	assert.NoError(t, err)
+	assert.Equal(t, []ModelDetails{{
+		Name:          "llama3:latest",
+		ParameterSize: "8.0B",
+		Quantization:  "Q4_0",
+		Size:          4661224676,
+		Modified:      time.Date(2025, 5, 1, 10, 0, 0, 0, time.UTC),
+	}}, models)
+
+	assert.NoError(t, c.Ping(context.Background()))
+	assert.NoError(t, c.WarmUp(context.Background()))
+	assert.Equal(t, "llama3", warmed["model"])
+	assert.Equal(t, "30m", warmed["keep_alive"])
+	assert.Empty(t, warmed["messages"])
+
+	c, _ = NewOllamaClient(&config.Config{Provider: "ollama", Model: "mistral", BaseURL: srv.URL})
+	assert.Equal(t, ModelNotFoundError{Model: "mistral"}, c.Ping(context.Background()))
+}
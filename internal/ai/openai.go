@@ -1,27 +1,37 @@
 package ai
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/penguinpowernz/clai/config"
 	"github.com/penguinpowernz/clai/internal/tools"
 )
 
+// streamBuffer is how many chunks can be waiting to be read from a stream,
+// the reader merges them if it falls behind so it doesn't need to be big
+const streamBuffer = 64
+
 type OpenAIClient struct {
 	config     *config.Config
 	httpClient *http.Client
 	baseURL    string
 	apiKey     string
-	model      *string // pointer to model name in the config to allow us to change it for this session
-	tools      []tools.Tool
+	model      *string          // pointer to model name in the config to allow us to change it for this session
+	adapter    *responseAdapter // reads the responses of servers that don't quite speak the API, nil for none
+
+	toolsMu sync.Mutex // the tools can change between requests
+	tools   []tools.Tool
 }
 
 func NewOpenAIClient(cfg *config.Config) (*OpenAIClient, error) {
@@ -32,66 +42,140 @@ func NewOpenAIClient(cfg *config.Config) (*OpenAIClient, error) {
 
 	return &OpenAIClient{
 		config:     cfg,
-		httpClient: &http.Client{},
-		baseURL:    strings.TrimSuffix(cfg.BaseURL, "/"),
+		httpClient: newHTTPClient(cfg),
+		baseURL:    strings.TrimSuffix(strings.TrimSuffix(cfg.BaseURL, "/"), "/openai"),
 		apiKey:     cfg.APIKey,
 		model:      &cfg.Model,
+		adapter:    newResponseAdapter(cfg),
 	}, nil
 }
 
 func (c *OpenAIClient) SendMessage(ctx context.Context, messages []Message) (*Response, error) {
-	// Prepend system prompt if it exists
-	allMessages := c.prepareMessages(messages)
-
-	reqBody := openAIRequest{
-		Model:       *c.model,
-		Messages:    allMessages,
-		Temperature: c.config.Temperature,
-		Stream:      false,
-		Tools:       c.tools,
-	}
-
-	reqBody.Options.MaxTokens = c.config.MaxTokens
+	reqBody := c.newRequest(ctx, messages, false)
 
+	start := time.Now()
 	respBody, err := c.makeRequest(ctx, reqBody)
+	reportRequest(reqBody.Model, start, err)
 	if err != nil {
 		return nil, err
 	}
 
+	if len(respBody.Choices) == 0 {
+		return nil, fmt.Errorf("no choices in the response")
+	}
+	reportUsage(reqBody.Model, &respBody.Usage, reqBody.Messages, len(respBody.Choices[0].Message.Content))
+
 	data, _ := json.MarshalIndent(respBody, "", "  ")
 	log.Println("[client] request payload:", string(data))
 
 	return &Response{
-		Content:      respBody.Choices[0].Message.Content,
+		Content:      c.adapter.Content(respBody.Choices[0].Message.Content),
 		TokensUsed:   respBody.Usage.TotalTokens,
 		FinishReason: respBody.Choices[0].FinishReason,
 	}, nil
 }
 
+// modelFor returns the model to use for the request
+// newRequest builds the request for the messages, with the system prompt
+// prepended if there is one
+func (c *OpenAIClient) newRequest(ctx context.Context, messages []Message, stream bool) openAIRequest {
+	model := c.modelFor(ctx)
+	c.toolsMu.Lock()
+	defer c.toolsMu.Unlock()
+	reqBody := openAIRequest{
+		Model:    model,
+		Messages: c.prepareMessages(ctx, messages),
+		Stream:   stream,
+		Tools:    c.tools,
+		Stop:     LookupModel(c.config, model).Stop,
+	}
+	reqBody.Temperature, reqBody.Seed = c.config.Sampling()
+
+	// some OpenAI compatible servers refuse requests with stream_options, the
+	// usage is estimated when it isn't sent
+	if stream && (c.config.Provider == "openai" || c.config.StreamUsage) {
+		reqBody.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	}
+
+	if constraint, ok := ConstraintFrom(ctx); ok {
+		reqBody.ResponseFormat = constraint.openAIResponseFormat()
+		// the OpenAI API refuses requests with fields it doesn't know
+		if c.config.Provider == "custom" {
+			reqBody.Grammar = constraint.Grammar
+		}
+	}
+
+	reqBody.Options.MaxTokens = c.config.MaxTokens
+	return reqBody
+}
+
+// Inspect returns the body of the streaming request that would be sent for
+// the messages
+func (c *OpenAIClient) Inspect(ctx context.Context, messages []Message) ([]byte, error) {
+	return json.MarshalIndent(c.newRequest(ctx, messages, true), "", "  ")
+}
+
+func (c *OpenAIClient) modelFor(ctx context.Context) string {
+	if model, ok := ModelFrom(ctx); ok {
+		return model
+	}
+	return *c.model
+}
+
 func (c *OpenAIClient) SetTools(tools []tools.Tool) {
+	c.toolsMu.Lock()
+	defer c.toolsMu.Unlock()
 	c.tools = tools
 }
 
 func (c *OpenAIClient) ListModels() []string {
+	details, err := c.ListModelDetails()
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var models []string
+	for _, model := range details {
+		models = append(models, fmt.Sprintf("%s (%s parameters, %0.1fGB)", model.Name, model.ParameterSize, float64(model.Size)/1024/1024/1024))
+	}
+
+	return models
+}
+
+// ListModelDetails returns the models on the server along with their size
+// and quantization
+func (c *OpenAIClient) ListModelDetails() ([]ModelDetails, error) {
+	if c.isAzure() {
+		return nil, errNoAzureModels
+	}
+
 	res, err := http.Get(c.baseURL + "/api/tags")
 	if err != nil {
-		return []string{}
+		return nil, err
 	}
 
 	defer res.Body.Close()
 
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("listing models: %s", res.Status)
+	}
+
 	var modelsRes openAIModelResponse
 	if err := json.NewDecoder(res.Body).Decode(&modelsRes); err != nil {
-
-		return []string{err.Error(), res.Status}
+		return nil, fmt.Errorf("listing models: %w", err)
 	}
 
-	var models []string
+	var models []ModelDetails
 	for _, model := range modelsRes.Models {
-		models = append(models, fmt.Sprintf("%s (%s parameters, %0.1fGB)", model.Name, model.Details.ParameterSize, model.Size/1024/1024))
+		models = append(models, ModelDetails{
+			Name:          model.Name,
+			ParameterSize: model.Details.ParameterSize,
+			Quantization:  model.Details.Quantization,
+			Size:          int64(model.Size),
+		})
 	}
 
-	return models
+	return models, nil
 }
 
 type openAIModelResponse struct {
@@ -107,110 +191,153 @@ type openAIModelResponse struct {
 }
 
 func (c *OpenAIClient) StreamMessage(ctx context.Context, messages []Message) (<-chan MessageChunk, error) {
-	// Prepend system prompt if it exists
-	allMessages := c.prepareMessages(messages)
-
-	reqBody := openAIRequest{
-		Model:       *c.model,
-		Messages:    allMessages,
-		Temperature: c.config.Temperature,
-		Stream:      true,
-		Tools:       c.tools,
-	}
-
-	reqBody.Options.MaxTokens = c.config.MaxTokens
+	reqBody := c.newRequest(ctx, messages, true)
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	log.Println("[client] sending request: ", string(jsonData))
-	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/v1/chat/completions", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.chatURL(reqBody.Model), bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	if c.apiKey != "" {
-		req.Header.Set("Authorization", "Bearer "+c.apiKey)
-	}
+	c.setAuth(req)
 
+	start := time.Now()
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		err = fmt.Errorf("failed to send request: %w", err)
+		reportRequest(reqBody.Model, start, err)
+		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		err := apiError(resp.StatusCode, body, reqBody.Model)
+		reportRequest(reqBody.Model, start, err)
+		return nil, err
 	}
 
-	streamChan := make(chan MessageChunk, 10)
+	streamChan := make(chan MessageChunk, streamBuffer)
 
 	go func() {
 		defer close(streamChan)
 		defer resp.Body.Close()
 
-		reader := bufio.NewReader(resp.Body)
-		for {
-			line, err := reader.ReadBytes('\n')
-			if err != nil {
-				if err != io.EOF {
-					if c.config.Verbose {
-						fmt.Printf("Stream read error: %v\n", err)
-					}
-				}
-				return
+		send := func(chunk MessageChunk) bool {
+			select {
+			case streamChan <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
 			}
+		}
 
-			line = bytes.TrimSpace(line)
-			if len(line) == 0 {
-				continue
+		// a stream that was stopped early is still paid for, the usage is
+		// always the last chunk
+		var usage *openAIUsage
+		var generated int
+		defer func() { send(NewUsageChunk(reportUsage(reqBody.Model, usage, reqBody.Messages, generated))) }()
+
+		var streamErr error
+		defer func() { reportRequest(reqBody.Model, start, streamErr) }()
+
+		var calls toolCallBuffer
+		sendCalls := func(done []*ToolCall) bool {
+			for _, call := range done {
+				if !send(NewToolCallChunk(call)) {
+					return false
+				}
 			}
-			// log.Println("[client] line:", string(line))
+			return true
+		}
 
-			// SSE format: "data: {...}"
-			if !bytes.HasPrefix(line, []byte("data: ")) {
-				continue
+		reader := newSSEReader(resp.Body)
+		for {
+			ev, err := reader.Next()
+			if err != nil {
+				if err == io.EOF {
+					// not every server sends [DONE]
+					sendCalls(calls.flush())
+				} else if ctx.Err() == nil {
+					log.Println("[client] stream read error:", err)
+					streamErr = err
+					send(NewChunk(ChunkError, err.Error()))
+				}
+				return
 			}
 
-			data := bytes.TrimPrefix(line, []byte("data: "))
+			data := c.adapter.Data(ev.Data)
 
 			// Check for end of stream
-			if string(data) == "[DONE]" {
+			if strings.TrimSpace(data) == "[DONE]" {
+				sendCalls(calls.flush())
 				return
 			}
 
 			var chunk openAIStreamChunk
-			if err := json.Unmarshal(data, &chunk); err != nil {
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
 				log.Printf("[client] Failed to parse chunk: %v\n", err)
 				continue
 			}
 
-			if len(chunk.Choices) > 0 && len(chunk.Choices[0].Delta.ToolCalls) > 0 {
-				log.Printf("[client] processing tool calls %+v", chunk.Choices[0].Delta.ToolCalls)
+			// errors can come as an error event or an error object in the data
+			if ev.Event == "error" || chunk.Error != nil {
+				msg := ev.Data
+				if chunk.Error != nil && chunk.Error.Message != "" {
+					msg = chunk.Error.Message
+				}
+				log.Println("[client] error in stream:", msg)
+				streamErr = errors.New(msg)
+				send(NewChunk(ChunkError, msg))
+				return
+			}
+
+			c.adapter.Chunk(data, &chunk)
+
+			// the last chunk may only have the usage, with no choices
+			if chunk.Usage != nil {
+				log.Printf("[client] usage: %d prompt tokens, %d completion tokens", chunk.Usage.PromptTokens, chunk.Usage.CompletionTokens)
+				usage = chunk.Usage
+			}
+
+			if len(chunk.Choices) > 0 {
+				delta := chunk.Choices[0].Delta
+				generated += len(delta.Content) + len(delta.Reasoning)
+				for _, call := range delta.ToolCalls {
+					generated += len(call.Function.Name) + len(call.Function.Arguments)
+				}
+			}
+
+			if len(chunk.Choices) > 0 {
 				for _, call := range chunk.Choices[0].Delta.ToolCalls {
-					select {
-					case streamChan <- MessageChunk{typ: ChunkToolCall, ToolCall: c.parseToolCall(call)}:
-					case <-ctx.Done():
+					if !sendCalls(calls.add(call)) {
 						return
 					}
 				}
 			}
 
 			if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
-				select {
-				case streamChan <- MessageChunk{typ: ChunkMessage, Content: chunk.Choices[0].Delta.Content}:
-				case <-ctx.Done():
+				if !send(MessageChunk{typ: ChunkMessage, Content: chunk.Choices[0].Delta.Content}) {
 					return
 				}
 			}
 
 			if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Reasoning != "" {
-				select {
-				case streamChan <- MessageChunk{typ: ChunkThink, Content: chunk.Choices[0].Delta.Reasoning}:
-				case <-ctx.Done():
+				if !send(MessageChunk{typ: ChunkThink, Content: chunk.Choices[0].Delta.Reasoning}) {
+					return
+				}
+			}
+
+			if len(chunk.Choices) > 0 && chunk.Choices[0].FinishReason != "" {
+				if !sendCalls(calls.flush()) {
+					return
+				}
+				if !send(MessageChunk{typ: ChunkFinish, Content: chunk.Choices[0].FinishReason}) {
 					return
 				}
 			}
@@ -220,15 +347,61 @@ func (c *OpenAIClient) StreamMessage(ctx context.Context, messages []Message) (<
 	return streamChan, nil
 }
 
-func (c *OpenAIClient) parseToolCall(call openAIToolCall) *ToolCall {
-	tc := &ToolCall{
-		ID:   call.ID,
-		Name: call.Function.Name,
+// toolCallBuffer puts the tool calls of a stream together, the arguments
+// come in fragments over many chunks and are only complete when the call at
+// the index changes or the response finishes
+type toolCallBuffer struct {
+	calls map[int]*ToolCall
+	args  map[int]*strings.Builder
+	order []int
+}
+
+// add adds the fragment to its call, returning the call that was at the
+// index if this is a new one, for servers that send every call at index 0
+func (b *toolCallBuffer) add(frag openAIToolCall) []*ToolCall {
+	var done []*ToolCall
+	call, ok := b.calls[frag.Index]
+	if ok && frag.ID != "" && call.ID != "" && frag.ID != call.ID {
+		done = append(done, b.take(frag.Index))
+		ok = false
+	}
+
+	if !ok {
+		if b.calls == nil {
+			b.calls, b.args = map[int]*ToolCall{}, map[int]*strings.Builder{}
+		}
+		call = &ToolCall{}
+		b.calls[frag.Index] = call
+		b.args[frag.Index] = &strings.Builder{}
+		b.order = append(b.order, frag.Index)
 	}
-	log.Printf("[client] ARGS %s", string(call.Function.Arguments))
-	err := json.Unmarshal([]byte(call.Function.Arguments), &tc.Input)
-	log.Printf("[client] ARGS2 %s %+v", err, tc.Input)
-	return tc
+
+	if frag.ID != "" {
+		call.ID = frag.ID
+	}
+	call.Name += frag.Function.Name
+	b.args[frag.Index].WriteString(frag.Function.Arguments)
+	return done
+}
+
+// flush returns the calls that are waiting, in the order they started
+func (b *toolCallBuffer) flush() []*ToolCall {
+	var done []*ToolCall
+	for len(b.order) > 0 {
+		done = append(done, b.take(b.order[0]))
+	}
+	return done
+}
+
+func (b *toolCallBuffer) take(index int) *ToolCall {
+	call := b.calls[index]
+	call.ID = toolCallID(call.ID)
+	call.Input = json.RawMessage(toolInput(b.args[index].String()))
+	delete(b.calls, index)
+	delete(b.args, index)
+	b.order = slices.DeleteFunc(b.order, func(i int) bool { return i == index })
+	log.Printf("[client] tool call %s %s", call.Name, call.Input)
+	return call
 }
 
 func (c *OpenAIClient) GetModelInfo() ModelInfo {
@@ -237,6 +410,7 @@ func (c *OpenAIClient) GetModelInfo() ModelInfo {
 		Provider:          c.config.Provider,
 		MaxTokens:         c.config.MaxTokens,
 		SupportsStreaming: true,
+		ModelCapabilities: LookupModel(c.config, *c.model),
 	}
 }
 
@@ -246,15 +420,13 @@ func (c *OpenAIClient) makeRequest(ctx context.Context, reqBody openAIRequest) (
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/v1/chat/completions", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.chatURL(reqBody.Model), bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	if c.apiKey != "" {
-		req.Header.Set("Authorization", "Bearer "+c.apiKey)
-	}
+	c.setAuth(req)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -264,7 +436,7 @@ func (c *OpenAIClient) makeRequest(ctx context.Context, reqBody openAIRequest) (
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return nil, apiError(resp.StatusCode, body, reqBody.Model)
 	}
 
 	var result openAIResponse
@@ -275,26 +447,78 @@ func (c *OpenAIClient) makeRequest(ctx context.Context, reqBody openAIRequest) (
 	return &result, nil
 }
 
+// convertToOpenAIMessages turns the messages into OpenAI's.  The tool calls
+// from a response go in one assistant message as tool_calls, and each is
+// followed by a tool message with its result.  The API turns down calls
+// without a result so the ones that weren't run are given one, and results
+// whose call isn't there any more are sent as text.
 func convertToOpenAIMessages(messages []Message) []openAIMessage {
-	result := make([]openAIMessage, len(messages))
-	for i, msg := range messages {
-		result[i] = openAIMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
+	var result []openAIMessage
+	var pending []string // the calls waiting for a result
+
+	answer := func() {
+		for _, id := range pending {
+			result = append(result, openAIMessage{Role: "tool", Content: toolDenied, ToolCallID: id})
+		}
+		pending = nil
+	}
+
+	for _, msg := range messages {
+		switch {
+		case msg.Role == "assistant" && msg.IsToolCall():
+			var calls []openAIToolCall
+			for _, tc := range msg.Calls() {
+				input, err := json.Marshal(tc.Input)
+				if err != nil {
+					input = nil
+				}
+				call := openAIToolCall{ID: tc.ID, Type: "function"}
+				call.Function.Name = tc.Name
+				call.Function.Arguments = toolInput(string(input))
+				calls = append(calls, call)
+				pending = append(pending, call.ID)
+			}
+
+			// the calls from one response go in one message, with its text
+			if n := len(result); n > 0 && result[n-1].Role == "assistant" {
+				result[n-1].ToolCalls = append(result[n-1].ToolCalls, calls...)
+				continue
+			}
+			result = append(result, openAIMessage{Role: "assistant", ToolCalls: calls})
+
+		case msg.ToolCallID != "" && slices.Contains(pending, msg.ToolCallID):
+			// the result of the call, or why it wasn't run
+			pending = slices.DeleteFunc(pending, func(id string) bool { return id == msg.ToolCallID })
+			result = append(result, openAIMessage{Role: "tool", Content: msg.Content, ToolCallID: msg.ToolCallID})
+
+		case msg.Role == "tool":
+			answer()
+			result = append(result, openAIMessage{Role: "user", Content: msg.Content})
+
+		default:
+			answer()
+			result = append(result, openAIMessage{Role: msg.Role, Content: msg.Content})
 		}
 	}
+	answer()
+
 	return result
 }
 
 // prepareMessages prepends system prompt if it exists
-func (c *OpenAIClient) prepareMessages(messages []Message) []openAIMessage {
+func (c *OpenAIClient) prepareMessages(ctx context.Context, messages []Message) []openAIMessage {
 	var allMessages []Message
 
+	system := c.config.SystemPrompt
+	if prompt, ok := SystemPromptFrom(ctx); ok {
+		system = prompt
+	}
+
 	// Add system prompt if it exists
-	if c.config.SystemPrompt != "" {
+	if system != "" {
 		allMessages = append(allMessages, Message{
 			Role:    "system",
-			Content: c.config.SystemPrompt,
+			Content: system,
 		})
 	}
 
@@ -309,15 +533,40 @@ type openAIRequest struct {
 	Options  struct {
 		MaxTokens int `json:"num_ctx,omitempty"`
 	} `json:"options,omitempty"`
-	Temperature float64      `json:"temperature,omitempty"`
+	Temperature *float64     `json:"temperature,omitempty"`
+	Seed        *int         `json:"seed,omitempty"`
 	Stream      bool         `json:"stream"`
 	Tools       []tools.Tool `json:"tools,omitempty"`
 	ToolChoice  string       `json:"tool_choice,omitempty"`
+	Stop        []string     `json:"stop,omitempty"`
+
+	// asks for the usage in a last chunk of the stream
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+
+	// constrained output
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+	Grammar        string                `json:"grammar,omitempty"` // llama.cpp
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIResponseFormat struct {
+	Type       string            `json:"type"` // "json_object" or "json_schema"
+	JSONSchema *openAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+type openAIJSONSchema struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
 }
 
 type openAIMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
 }
 
 type openAIResponse struct {
@@ -347,6 +596,13 @@ type openAIStreamChunk struct {
 	Created int64                `json:"created"`
 	Model   string               `json:"model"`
 	Choices []openAIStreamChoice `json:"choices"`
+	Usage   *openAIUsage         `json:"usage,omitempty"`
+	Error   *openAIError         `json:"error,omitempty"`
+}
+
+type openAIError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
 }
 
 type openAIStreamChoice struct {
@@ -363,7 +619,9 @@ type openAIStreamDelta struct {
 }
 
 type openAIToolCall struct {
+	Index    int    `json:"index,omitempty"` // only in the stream
 	ID       string `json:"id"`
+	Type     string `json:"type,omitempty"`
 	Function struct {
 		Name      string `json:"name"`
 		Arguments string `json:"arguments"`
@@ -0,0 +1,176 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAIStreamToolCalls(t *testing.T) {
+	events := []string{
+		`{"choices":[{"delta":{"content":"Reading both"}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_a","type":"function","function":{"name":"read_file","arguments":""}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"pa"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_b","type":"function","function":{"name":"read_file","arguments":"{\"path\":"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"th\":\"a.go\"}"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":1,"function":{"arguments":"\"b.go\"}"}}]}}]}`,
+		`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+		`{"choices":[],"usage":{"prompt_tokens":40,"completion_tokens":25,"total_tokens":65}}`,
+		`[DONE]`,
+	}
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.Write([]byte("data: " + strings.Join(events, "\n\ndata: ") + "\n\n"))
+	}))
+	defer srv.Close()
+
+	cfg := config.Default()
+	cfg.Provider, cfg.BaseURL, cfg.Model = "custom", srv.URL, "gpt-4o"
+	cfg.StreamUsage = true
+	c, err := NewClient(cfg)
+	assert.NoError(t, err)
+
+	stream, err := c.StreamMessage(context.Background(), []Message{{Role: "user", Content: "read a.go and b.go"}})
+	assert.NoError(t, err)
+
+	var chunks []MessageChunk
+	for chunk := range stream {
+		chunks = append(chunks, chunk)
+	}
+
+	// the calls only come once their arguments are complete
+	assert.Equal(t, []MessageChunk{
+		NewChunk(ChunkMessage, "Reading both"),
+		NewToolCallChunk(&ToolCall{ID: "call_a", Name: "read_file", Input: []byte(`{"path":"a.go"}`)}),
+		NewToolCallChunk(&ToolCall{ID: "call_b", Name: "read_file", Input: []byte(`{"path":"b.go"}`)}),
+		NewChunk(ChunkFinish, "tool_calls"),
+		NewUsageChunk(Usage{PromptTokens: 40, CompletionTokens: 25}),
+	}, chunks)
+
+	// the usage is only sent when it is asked for
+	assert.Equal(t, map[string]any{"include_usage": true}, got["stream_options"])
+}
+
+func TestOpenAIStreamWithoutUsage(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"hello there\"},\"finish_reason\":\"stop\"}]}\n\ndata: [DONE]\n\n"))
+	}))
+	defer srv.Close()
+
+	cfg := config.Default()
+	cfg.Provider, cfg.BaseURL, cfg.Model, cfg.SystemPrompt = "custom", srv.URL, "gpt-4o", ""
+	c, err := NewClient(cfg)
+	assert.NoError(t, err)
+
+	stream, err := c.StreamMessage(context.Background(), []Message{{Role: "user", Content: "say hello there"}})
+	assert.NoError(t, err)
+
+	var last MessageChunk
+	for chunk := range stream {
+		last = chunk
+	}
+
+	// the usage isn't asked for, so it is estimated
+	assert.NotContains(t, got, "stream_options")
+	assert.Equal(t, NewUsageChunk(Usage{PromptTokens: EstimateTokens("say hello there"), CompletionTokens: EstimateTokens("hello there"), Estimated: true}), last)
+}
+
+func TestToolCallBuffer(t *testing.T) {
+	frag := func(index int, id, name, args string) openAIToolCall {
+		var call openAIToolCall
+		call.Index, call.ID, call.Function.Name, call.Function.Arguments = index, id, name, args
+		return call
+	}
+
+	// some servers send each whole call at index 0
+	var b toolCallBuffer
+	assert.Empty(t, b.add(frag(0, "call_a", "grep", `{"pattern":"x"}`)))
+	done := b.add(frag(0, "call_b", "find", `{}`))
+	if assert.Len(t, done, 1) {
+		assert.Equal(t, "call_a", done[0].ID)
+	}
+
+	// and some don't give them IDs
+	assert.Empty(t, b.add(frag(1, "", "mkdir", `{"path":`)))
+	done = b.flush()
+	if assert.Len(t, done, 2) {
+		assert.Equal(t, "find", done[0].Name)
+		assert.Equal(t, "mkdir", done[1].Name)
+		assert.NotEmpty(t, done[1].ID)
+		assert.JSONEq(t, `{}`, string(done[1].Input), "cut off arguments are left empty")
+	}
+	assert.Empty(t, b.flush())
+}
+
+func TestOpenAIDeterministic(t *testing.T) {
+	cfg := config.Default()
+	cfg.Provider, cfg.BaseURL, cfg.Model = "custom", "http://localhost:1/v1", "gpt-4o"
+	cfg.Deterministic = true
+	c, err := NewClient(cfg)
+	assert.NoError(t, err)
+
+	body, err := c.(Inspector).Inspect(context.Background(), []Message{{Role: "user", Content: "hi"}})
+	assert.NoError(t, err)
+
+	var got map[string]any
+	assert.NoError(t, json.Unmarshal(body, &got))
+	assert.Equal(t, 0.0, got["temperature"], "a temperature of 0 is sent")
+	assert.Equal(t, float64(config.DeterministicSeed), got["seed"])
+}
+
+func TestOpenAIToolMessages(t *testing.T) {
+	cfg := config.Default()
+	cfg.Provider, cfg.BaseURL, cfg.Model, cfg.SystemPrompt = "custom", "http://localhost:1/v1", "gpt-4o", ""
+	c, err := NewClient(cfg)
+	assert.NoError(t, err)
+
+	body, err := c.(Inspector).Inspect(context.Background(), []Message{
+		{Role: "user", Content: "read a.go and b.go"},
+		{Role: "assistant", Content: "Reading both"},
+		{Role: "assistant", Content: "Request to use tool: `read_file`", ToolCallID: "call_a", ToolCall: &ToolUse{ID: "call_a", Name: "read_file", Input: map[string]any{"path": "a.go"}}},
+		{Role: "assistant", Content: "Request to use tool: `read_file`", ToolCallID: "call_b", ToolCall: &ToolUse{ID: "call_b", Name: "read_file", Input: map[string]any{"path": "b.go"}}},
+		{Role: "tool", Content: "package a", ToolCallID: "call_a"},
+		{Role: "user", Content: "Tool not found", ToolCallID: "call_b"},
+		{Role: "user", Content: "thanks"},
+	})
+	assert.NoError(t, err)
+
+	var got struct {
+		Messages json.RawMessage `json:"messages"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &got))
+	assert.JSONEq(t, `[
+		{"role":"user","content":"read a.go and b.go"},
+		{"role":"assistant","content":"Reading both","tool_calls":[
+			{"id":"call_a","type":"function","function":{"name":"read_file","arguments":"{\"path\":\"a.go\"}"}},
+			{"id":"call_b","type":"function","function":{"name":"read_file","arguments":"{\"path\":\"b.go\"}"}}
+		]},
+		{"role":"tool","content":"package a","tool_call_id":"call_a"},
+		{"role":"tool","content":"Tool not found","tool_call_id":"call_b"},
+		{"role":"user","content":"thanks"}
+	]`, string(got.Messages))
+
+	// a call the user didn't allow still gets a result
+	body, err = c.(Inspector).Inspect(context.Background(), []Message{
+		{Role: "user", Content: "delete it"},
+		{Role: "assistant", ToolCall: &ToolUse{ID: "call_c", Name: "delete_file", Input: map[string]any{}}},
+		{Role: "user", Content: "no"},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(body, &got))
+	assert.JSONEq(t, `[
+		{"role":"user","content":"delete it"},
+		{"role":"assistant","content":"","tool_calls":[{"id":"call_c","type":"function","function":{"name":"delete_file","arguments":"{}"}}]},
+		{"role":"tool","content":"The user didn't allow the tool to be run.","tool_call_id":"call_c"},
+		{"role":"user","content":"no"}
+	]`, string(got.Messages))
+}
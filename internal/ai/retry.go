@@ -0,0 +1,132 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/penguinpowernz/clai/config"
+)
+
+// RetryNotice says that a request to the provider failed and is being tried
+// again after Delay
+type RetryNotice struct {
+	Attempt     int // the try that is about to be made, from 2
+	MaxAttempts int
+	Delay       time.Duration
+	Err         error
+}
+
+func (n RetryNotice) String() string {
+	return fmt.Sprintf("%s, trying again in %s (%d/%d)", n.Err, n.Delay.Round(100*time.Millisecond), n.Attempt, n.MaxAttempts)
+}
+
+type retryKey struct{}
+
+// WithRetryNotice makes f be called each time a request sent with the
+// context is retried, so the user knows why the response is slow
+func WithRetryNotice(ctx context.Context, f func(RetryNotice)) context.Context {
+	return context.WithValue(ctx, retryKey{}, f)
+}
+
+// retryTransport sends requests again when the provider failed them in a
+// way that might go away: rate limits, server errors and dropped
+// connections.  Once the response has been handed over it is up to the
+// caller, so a stream that breaks part way through isn't retried.
+type retryTransport struct {
+	next  http.RoundTripper
+	retry config.Retry
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		res, err := t.next.RoundTrip(req)
+		if attempt >= t.retry.MaxAttempts || !retryable(res, err) || (req.Body != nil && req.GetBody == nil) {
+			return res, err
+		}
+
+		delay := t.delay(attempt, res)
+		if err == nil {
+			err = fmt.Errorf("the provider answered %s", res.Status)
+			io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+			res.Body.Close()
+		}
+
+		notice := RetryNotice{Attempt: attempt + 1, MaxAttempts: t.retry.MaxAttempts, Delay: delay, Err: err}
+		log.Printf("[client] request to %s failed: %s", req.URL.Host, notice)
+		if f, ok := req.Context().Value(retryKey{}).(func(RetryNotice)); ok {
+			f(notice)
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// retryable returns true if the request failed in a way that trying again
+// might get past
+func retryable(res *http.Response, err error) bool {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+		var netErr net.Error
+		return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET)
+	}
+
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// delay returns how long to wait after the attempt failed, doubling the
+// backoff each time.  A Retry-After from the provider is used when it gives
+// one, as long as it isn't over the max backoff.
+func (t *retryTransport) delay(attempt int, res *http.Response) time.Duration {
+	if res != nil {
+		if secs, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			return t.capped(time.Duration(secs) * time.Second)
+		}
+	}
+
+	d := t.retry.Backoff << (attempt - 1)
+	if d < t.retry.Backoff {
+		d = t.retry.MaxBackoff // overflowed
+	}
+	d = t.capped(d)
+
+	if t.retry.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * t.retry.Jitter * float64(d))
+	}
+	return d
+}
+
+// capped keeps the delay to the max backoff, 0 is no max
+func (t *retryTransport) capped(d time.Duration) time.Duration {
+	if t.retry.MaxBackoff > 0 && d > t.retry.MaxBackoff {
+		return t.retry.MaxBackoff
+	}
+	return d
+}
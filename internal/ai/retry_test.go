@@ -0,0 +1,78 @@
+package ai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetry(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}]}`))
+	}))
+	defer srv.Close()
+
+	retry := config.Retry{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+	c, _ := NewOpenAIClient(&config.Config{Provider: "custom", Model: "llama3", BaseURL: srv.URL, Retry: retry})
+
+	var notices []RetryNotice
+	ctx := WithRetryNotice(context.Background(), func(n RetryNotice) { notices = append(notices, n) })
+	res, err := c.SendMessage(ctx, []Message{{Role: "user", Content: "hello"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "hi", res.Content)
+
+	// the same request is sent each time
+	assert.Len(t, bodies, 3)
+	assert.Equal(t, bodies[0], bodies[2])
+
+	assert.Len(t, notices, 2)
+	assert.Equal(t, 2, notices[0].Attempt)
+	assert.Equal(t, 3, notices[1].MaxAttempts)
+	assert.EqualError(t, notices[0].Err, "the provider answered 503 Service Unavailable")
+
+	// the last failure is returned once the attempts run out
+	bodies = nil
+	retry.MaxAttempts = 2
+	c, _ = NewOpenAIClient(&config.Config{Provider: "custom", Model: "llama3", BaseURL: srv.URL, Retry: retry})
+	_, err = c.SendMessage(context.Background(), []Message{{Role: "user", Content: "hello"}})
+	assert.ErrorContains(t, err, "status 503")
+	assert.Len(t, bodies, 2)
+}
+
+func TestRetryDelay(t *testing.T) {
+	tr := &retryTransport{retry: config.Retry{Backoff: time.Second, MaxBackoff: 5 * time.Second}}
+	assert.Equal(t, time.Second, tr.delay(1, nil))
+	assert.Equal(t, 4*time.Second, tr.delay(3, nil))
+	assert.Equal(t, 5*time.Second, tr.delay(4, nil))
+	assert.Equal(t, 5*time.Second, tr.delay(80, nil))
+
+	res := &http.Response{Header: http.Header{"Retry-After": {"2"}}}
+	assert.Equal(t, 2*time.Second, tr.delay(1, res))
+
+	tr.retry.Jitter = 0.5
+	for range 20 {
+		d := tr.delay(2, nil)
+		assert.True(t, d >= time.Second && d <= 3*time.Second, d)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	assert.True(t, retryable(&http.Response{StatusCode: http.StatusTooManyRequests}, nil))
+	assert.True(t, retryable(&http.Response{StatusCode: http.StatusBadGateway}, nil))
+	assert.False(t, retryable(&http.Response{StatusCode: http.StatusBadRequest}, nil))
+	assert.True(t, retryable(nil, io.ErrUnexpectedEOF))
+	assert.False(t, retryable(nil, context.Canceled))
+}
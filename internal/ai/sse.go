@@ -0,0 +1,128 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+)
+
+// maxSSELine is the longest line the SSE reader accepts, big tool call
+// arguments can come in a single data line
+const maxSSELine = 16 * 1024 * 1024
+
+// sseEvent is a single server-sent event
+type sseEvent struct {
+	Event string // the event type, "message" if the server didn't give one
+	Data  string // the data lines joined with newlines
+	ID    string
+}
+
+// sseReader reads server-sent events as described in the HTML spec, which
+// is what OpenAI compatible servers use to stream responses
+// (https://html.spec.whatwg.org/multipage/server-sent-events.html).
+// Lines can end in CRLF, LF or CR, lines starting with a colon are comments
+// (used as keep-alives) and an event can have many data lines.
+type sseReader struct {
+	scanner *bufio.Scanner
+}
+
+func newSSEReader(r io.Reader) *sseReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSSELine)
+	scanner.Split(scanSSELines)
+	return &sseReader{scanner: scanner}
+}
+
+// Next returns the next event, or io.EOF when the stream is done.  An event
+// left unfinished at the end of the stream is still returned as some servers
+// close the connection without the blank line after the last event.
+func (r *sseReader) Next() (sseEvent, error) {
+	var ev sseEvent
+	var data strings.Builder
+	var hasData bool
+
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+
+		// a blank line dispatches the event
+		if line == "" {
+			if !hasData {
+				ev = sseEvent{}
+				continue
+			}
+			return finishSSEEvent(ev, data.String()), nil
+		}
+
+		// a comment
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "event":
+			ev.Event = value
+		case "id":
+			ev.ID = value
+		}
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return sseEvent{}, err
+	}
+
+	if hasData {
+		return finishSSEEvent(ev, data.String()), nil
+	}
+
+	return sseEvent{}, io.EOF
+}
+
+func finishSSEEvent(ev sseEvent, data string) sseEvent {
+	ev.Data = data
+	if ev.Event == "" {
+		ev.Event = "message"
+	}
+	return ev
+}
+
+// scanSSELines is a bufio.SplitFunc for lines ending in CRLF, LF or CR
+func scanSSELines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+
+		// a CR, which may be the start of a CRLF that hasn't all arrived
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		}
+		if atEOF {
+			return i + 1, data[:i], nil
+		}
+		return 0, nil, nil
+	}
+
+	if atEOF {
+		return len(data), data, nil
+	}
+
+	// ask for more data
+	return 0, nil, nil
+}
@@ -0,0 +1,117 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func readSSE(t *testing.T, r io.Reader) []sseEvent {
+	var events []sseEvent
+	reader := newSSEReader(r)
+	for {
+		ev, err := reader.Next()
+		if err == io.EOF {
+			return events
+		}
+		if !assert.NoError(t, err) {
+			return events
+		}
+		events = append(events, ev)
+	}
+}
+
+func TestSSEReader(t *testing.T) {
+	stream := ": keep-alive\r\n\r\n" +
+		"data: {\"a\":1}\r\n\r\n" +
+		"event: error\ndata: first\ndata: second\n\n" +
+		"id: 7\rdata:no space\r\r" +
+		"retry: 1000\n\n" +
+		"data: [DONE]"
+
+	events := readSSE(t, strings.NewReader(stream))
+	assert.Equal(t, []sseEvent{
+		{Event: "message", Data: `{"a":1}`},
+		{Event: "error", Data: "first\nsecond"},
+		{Event: "message", Data: "no space", ID: "7"},
+		{Event: "message", Data: "[DONE]"},
+	}, events)
+}
+
+// events split across reads, including between the CR and LF
+func TestSSEReaderSplitReads(t *testing.T) {
+	stream := "data: hello\r\n\r\ndata: world\r\n\r\n"
+	events := readSSE(t, iotest.OneByteReader(strings.NewReader(stream)))
+	assert.Equal(t, []sseEvent{
+		{Event: "message", Data: "hello"},
+		{Event: "message", Data: "world"},
+	}, events)
+}
+
+func TestSSEReaderLongLine(t *testing.T) {
+	long := strings.Repeat("x", 200*1024)
+	events := readSSE(t, strings.NewReader("data: "+long+"\n\n"))
+	assert.Len(t, events, 1)
+	assert.Equal(t, long, events[0].Data)
+}
+
+func TestStreamMessageParsesEvents(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": ping\r\n\r\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\r\n\r\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"lo\"},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":2}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	client, err := NewOpenAIClient(&config.Config{Provider: "custom", Model: "x", BaseURL: srv.URL})
+	assert.NoError(t, err)
+
+	ch, err := client.StreamMessage(context.Background(), nil)
+	assert.NoError(t, err)
+
+	var chunks []MessageChunk
+	for c := range ch {
+		chunks = append(chunks, c)
+	}
+
+	assert.Equal(t, []MessageChunk{
+		NewChunk(ChunkMessage, "Hel"),
+		NewChunk(ChunkMessage, "lo"),
+		NewChunk(ChunkFinish, "stop"),
+		NewUsageChunk(Usage{PromptTokens: 5, CompletionTokens: 2}),
+	}, chunks)
+}
+
+func TestStreamMessageError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\n")
+		fmt.Fprint(w, "event: error\ndata: {\"error\":{\"message\":\"model overloaded\"}}\n\n")
+	}))
+	defer srv.Close()
+
+	client, _ := NewOpenAIClient(&config.Config{Provider: "custom", Model: "x", BaseURL: srv.URL})
+	ch, err := client.StreamMessage(context.Background(), nil)
+	assert.NoError(t, err)
+
+	var chunks []MessageChunk
+	for c := range ch {
+		chunks = append(chunks, c)
+	}
+
+	assert.Equal(t, []MessageChunk{
+		NewChunk(ChunkMessage, "Hi"),
+		NewChunk(ChunkError, "model overloaded"),
+		NewUsageChunk(Usage{CompletionTokens: 1, Estimated: true}),
+	}, chunks)
+}
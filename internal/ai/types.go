@@ -5,16 +5,44 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/penguinpowernz/clai/internal/tools"
 )
 
+// Redacted takes the place of text that was removed with /redact
+const Redacted = "[redacted]"
+
 // Message represents a single message in the conversation
 type Message struct {
-	Role       string   `json:"role"`                   // "user", "assistant", or "system"
-	Content    string   `json:"content"`                // The message content
-	ToolCallID string   `json:"tool_call_id,omitempty"` // For tool result messages
-	ToolCall   *ToolUse `json:"tool_call,omitempty"`    // When assistant uses a tool
+	Role       string    `json:"role"`                   // "user", "assistant", or "system"
+	Content    string    `json:"content"`                // The message content
+	ToolCallID string    `json:"tool_call_id,omitempty"` // For tool result messages
+	ToolCall   *ToolUse  `json:"tool_call,omitempty"`    // When assistant uses a tool, in older histories
+	ToolCalls  []ToolUse `json:"tool_calls,omitempty"`   // The tools the assistant called in one response
+
+	Interrupted bool `json:"interrupted,omitempty"` // The user stopped the generation part way through
+	Truncated   bool `json:"truncated,omitempty"`   // The generation stopped at the length limit
+
+	// Metadata, this is not sent to the LLM
+	Time   time.Time `json:"time,omitzero"`    // When the message was added
+	Model  string    `json:"model,omitempty"`  // The model that generated the message
+	Tokens int       `json:"tokens,omitempty"` // Rough token count of the content
+	ID     string    `json:"id,omitempty"`     // Identifies the message, the UI's copy of it has the same one
+}
+
+// Calls returns the tools the message calls, older histories have one call
+// per message in ToolCall
+func (m Message) Calls() []ToolUse {
+	if m.ToolCall != nil {
+		return append([]ToolUse{*m.ToolCall}, m.ToolCalls...)
+	}
+	return m.ToolCalls
+}
+
+// IsToolCall returns true if the assistant called tools in the message
+func (m Message) IsToolCall() bool {
+	return m.ToolCall != nil || len(m.ToolCalls) > 0
 }
 
 // ToolUse represents a tool invocation by the AI
@@ -46,22 +74,60 @@ type Provider interface {
 	// ListModels returns a list of available models
 	ListModels() []string
 
+	// ListModelDetails returns the available models with what is known
+	// about them
+	ListModelDetails() ([]ModelDetails, error)
+
 	// SetTools sets the tools available to the AI
 	SetTools(tools []tools.Tool)
 }
 
+// Inspector is implemented by providers that can show the request they would
+// send for the messages, without sending it
+type Inspector interface {
+	Inspect(ctx context.Context, messages []Message) ([]byte, error)
+}
+
 // ModelInfo contains metadata about the AI model
 type ModelInfo struct {
 	Name              string
 	Provider          string
 	MaxTokens         int
 	SupportsStreaming bool
+	ModelCapabilities
+}
+
+// ModelDetails describes a model available from the provider, the fields
+// are empty when the provider doesn't say
+type ModelDetails struct {
+	Name          string
+	ParameterSize string    // e.g. 8.0B
+	Quantization  string    // e.g. Q4_K_M
+	Size          int64     // bytes on disk
+	Modified      time.Time // when it was last pulled or changed
 }
 
 type MessageChunk struct {
 	typ      string
 	Content  string
 	ToolCall *ToolCall
+	Usage    *Usage
+}
+
+// NewChunk creates a new chunk of the given type
+func NewChunk(typ, content string) MessageChunk {
+	return MessageChunk{typ: typ, Content: content}
+}
+
+// NewUsageChunk creates the chunk sent at the end of a stream with the tokens
+// it used
+func NewUsageChunk(usage Usage) MessageChunk {
+	return MessageChunk{typ: ChunkUsage, Usage: &usage}
+}
+
+// NewToolCallChunk creates a chunk for a call to a tool
+func NewToolCallChunk(call *ToolCall) MessageChunk {
+	return MessageChunk{typ: ChunkToolCall, ToolCall: call}
 }
 
 func (m MessageChunk) Type() string {
@@ -90,4 +156,7 @@ const (
 	ChunkMessage  = "message"
 	ChunkToolCall = "tool_call"
 	ChunkThink    = "think"
+	ChunkFinish   = "finish" // the content is the reason the generation stopped
+	ChunkError    = "error"  // the content is the error the provider sent mid-stream
+	ChunkUsage    = "usage"  // the last chunk, with the tokens the request used
 )
@@ -0,0 +1,73 @@
+package ai
+
+import "time"
+
+// UsageFunc is told how many tokens each request used, estimated is set when
+// the provider didn't say so the counts were worked out from the text
+type UsageFunc func(model string, promptTokens, completionTokens int, estimated bool)
+
+var usageFunc UsageFunc
+
+// SetUsageFunc sets the func that is told about the usage of every request
+func SetUsageFunc(f UsageFunc) {
+	usageFunc = f
+}
+
+// RequestFunc is told about every request to the provider, how long it took
+// to be answered in full and why it failed if it did
+type RequestFunc func(model string, took time.Duration, err error)
+
+var requestFunc RequestFunc
+
+// SetRequestFunc sets the func that is told about every request
+func SetRequestFunc(f RequestFunc) {
+	requestFunc = f
+}
+
+func reportRequest(model string, start time.Time, err error) {
+	if requestFunc != nil {
+		requestFunc(model, time.Since(start), err)
+	}
+}
+
+// Usage is how many tokens were used by a request, or added up over many
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	Estimated        bool // the provider didn't say so some were worked out from the text
+}
+
+// Add adds the usage of another request to it
+func (u *Usage) Add(o Usage) {
+	u.PromptTokens += o.PromptTokens
+	u.CompletionTokens += o.CompletionTokens
+	u.Estimated = u.Estimated || o.Estimated
+}
+
+// Total returns the prompt and completion tokens together
+func (u Usage) Total() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// report passes the usage on to the usage func, and returns it so the streams
+// can send it on in a chunk
+func (u Usage) report(model string) Usage {
+	if usageFunc != nil {
+		usageFunc(model, u.PromptTokens, u.CompletionTokens, u.Estimated)
+	}
+	return u
+}
+
+// reportUsage passes on the usage the provider gave, or an estimate from the
+// messages sent and the number of bytes generated if it gave none
+func reportUsage(model string, usage *openAIUsage, messages []openAIMessage, generated int) Usage {
+	if usage != nil && usage.PromptTokens+usage.CompletionTokens > 0 {
+		return Usage{PromptTokens: usage.PromptTokens, CompletionTokens: usage.CompletionTokens}.report(model)
+	}
+
+	var prompt int
+	for _, msg := range messages {
+		prompt += EstimateTokens(msg.Content)
+	}
+	return Usage{PromptTokens: prompt, CompletionTokens: EstimateTokensLen(generated), Estimated: true}.report(model)
+}
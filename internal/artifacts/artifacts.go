@@ -0,0 +1,112 @@
+// Package artifacts saves the binary files that tools and models produce,
+// like generated images, PDFs and plots, so they can be opened from the
+// transcript instead of filling the context with base64
+package artifacts
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Artifact is a file saved from the output of a tool or model
+type Artifact struct {
+	Path string
+	MIME string
+	Size int
+}
+
+// IsImage is true for the artifacts that can be previewed
+func (a Artifact) IsImage() bool {
+	return strings.HasPrefix(a.MIME, "image/") && a.MIME != "image/svg+xml"
+}
+
+// extensions are the types of file that are saved, by their MIME type
+var extensions = map[string]string{
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"image/svg+xml":   ".svg",
+	"application/pdf": ".pdf",
+}
+
+var dataURI = regexp.MustCompile(`data:(image/(?:png|jpeg|gif|webp|svg\+xml)|application/pdf);base64,([A-Za-z0-9+/]+={0,2})`)
+
+// Dir is where the artifacts of a session are saved
+func Dir(sessionDir, id string) string {
+	return filepath.Join(sessionDir, id, "artifacts")
+}
+
+// Extract saves the base64 data URIs in the text to the dir and replaces
+// them with the paths of the files.  Raw binary output, like a plugin
+// writing a PNG to stdout, is saved whole and replaced with a note.  The text
+// is returned as it was if there was nothing to save or it couldn't be.
+func Extract(text, dir string) (string, []Artifact, error) {
+	if data := []byte(text); !utf8.Valid(data) {
+		mime := http.DetectContentType(data)
+		a, err := save(dir, data, mime)
+		if err != nil {
+			return text, nil, err
+		}
+		return fmt.Sprintf("The output was a %s file of %d bytes, it was saved to %s", mime, a.Size, a.Path), []Artifact{a}, nil
+	}
+
+	var found []Artifact
+	var firstErr error
+	out := dataURI.ReplaceAllStringFunc(text, func(uri string) string {
+		sub := dataURI.FindStringSubmatch(uri)
+		data, err := base64.StdEncoding.DecodeString(sub[2])
+		if err != nil {
+			return uri
+		}
+
+		a, err := save(dir, data, sub[1])
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return uri
+		}
+		found = append(found, a)
+		return a.Path
+	})
+	return out, found, firstErr
+}
+
+// save writes the data to a file named after its hash, so the same file
+// isn't saved twice
+func save(dir string, data []byte, mime string) (Artifact, error) {
+	ext, ok := extensions[mime]
+	if !ok {
+		ext = ".bin"
+	}
+
+	sum := sha256.Sum256(data)
+	a := Artifact{
+		Path: filepath.Join(dir, hex.EncodeToString(sum[:6])+ext),
+		MIME: mime,
+		Size: len(data),
+	}
+	if _, err := os.Stat(a.Path); err == nil {
+		return a, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return a, err
+	}
+	return a, os.WriteFile(a.Path, data, 0644)
+}
+
+// Link makes the text a link to the file in terminals that support OSC 8
+// hyperlinks, the others show the text
+func Link(path, text string) string {
+	return "\x1b]8;;file://" + path + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
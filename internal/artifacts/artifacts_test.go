@@ -0,0 +1,48 @@
+package artifacts
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtract(t *testing.T) {
+	dir := t.TempDir()
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	text := "Here is the plot: data:image/png;base64," + base64.StdEncoding.EncodeToString(png) + " enjoy"
+	out, found, err := Extract(text, dir)
+	assert.NoError(t, err)
+	if assert.Len(t, found, 1) {
+		assert.Equal(t, "image/png", found[0].MIME)
+		assert.Equal(t, ".png", filepath.Ext(found[0].Path))
+		assert.True(t, found[0].IsImage())
+		assert.Equal(t, "Here is the plot: "+found[0].Path+" enjoy", out)
+
+		data, err := os.ReadFile(found[0].Path)
+		assert.NoError(t, err)
+		assert.Equal(t, png, data)
+	}
+
+	// the same file again is the same artifact
+	_, again, err := Extract(text, dir)
+	assert.NoError(t, err)
+	assert.Equal(t, found, again)
+
+	// raw binary output is saved whole
+	out, found, err = Extract(string(png), dir)
+	assert.NoError(t, err)
+	if assert.Len(t, found, 1) {
+		assert.Equal(t, "image/png", found[0].MIME)
+		assert.Contains(t, out, found[0].Path)
+	}
+
+	// plain text is left alone
+	out, found, err = Extract("just some text", dir)
+	assert.NoError(t, err)
+	assert.Empty(t, found)
+	assert.Equal(t, "just some text", out)
+}
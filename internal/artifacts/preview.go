@@ -0,0 +1,89 @@
+package artifacts
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+)
+
+// Protocol returns the inline image protocol the terminal supports, "kitty",
+// "iterm2" or nothing.  tmux doesn't pass the images through so there is no
+// preview in it.
+func Protocol() string {
+	switch {
+	case os.Getenv("TMUX") != "":
+		return ""
+	case os.Getenv("KITTY_WINDOW_ID") != "" || os.Getenv("TERM") == "xterm-kitty" || os.Getenv("TERM_PROGRAM") == "ghostty":
+		return "kitty"
+	case os.Getenv("TERM_PROGRAM") == "iTerm.app" || os.Getenv("LC_TERMINAL") == "iTerm2" || os.Getenv("TERM_PROGRAM") == "WezTerm":
+		return "iterm2"
+	}
+	return ""
+}
+
+// kittyChunk is the most base64 kitty takes in one escape sequence
+const kittyChunk = 4096
+
+// Preview writes the image to the terminal with the protocol, the other
+// kinds of artifact can't be previewed
+func Preview(w io.Writer, a Artifact, protocol string) error {
+	if !a.IsImage() {
+		return fmt.Errorf("%s files can't be previewed", a.MIME)
+	}
+
+	data, err := os.ReadFile(a.Path)
+	if err != nil {
+		return err
+	}
+
+	switch protocol {
+	case "iterm2":
+		_, err = fmt.Fprintf(w, "\x1b]1337;File=inline=1;size=%d;preserveAspectRatio=1:%s\a\n", len(data), base64.StdEncoding.EncodeToString(data))
+		return err
+
+	case "kitty":
+		// kitty only takes PNGs without help
+		if a.MIME != "image/png" {
+			img, _, err := image.Decode(bytes.NewReader(data))
+			if err != nil {
+				return fmt.Errorf("failed to decode %s: %w", a.Path, err)
+			}
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, img); err != nil {
+				return err
+			}
+			data = buf.Bytes()
+		}
+		return writeKitty(w, base64.StdEncoding.EncodeToString(data))
+	}
+
+	return fmt.Errorf("the terminal can't show images")
+}
+
+func writeKitty(w io.Writer, payload string) error {
+	var b strings.Builder
+	for first := true; first || payload != ""; first = false {
+		chunk := payload[:min(kittyChunk, len(payload))]
+		payload = payload[len(chunk):]
+
+		more := 0
+		if payload != "" {
+			more = 1
+		}
+		if first {
+			fmt.Fprintf(&b, "\x1b_Ga=T,f=100,m=%d;%s\x1b\\", more, chunk)
+		} else {
+			fmt.Fprintf(&b, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+		}
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
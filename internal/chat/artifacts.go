@@ -0,0 +1,49 @@
+package chat
+
+import (
+	"log"
+	"slices"
+
+	"github.com/penguinpowernz/clai/internal/artifacts"
+	"github.com/penguinpowernz/clai/internal/ui"
+)
+
+// saveArtifacts saves the files in the output of a tool or the model to the
+// session dir, the output has their paths instead.  Nothing is saved if the
+// session isn't kept.
+func (s *Session) saveArtifacts(text string) (string, []artifacts.Artifact) {
+	if s.config.SessionDir == "" || !s.config.SaveHistory {
+		return text, nil
+	}
+
+	out, found, err := artifacts.Extract(text, artifacts.Dir(s.config.SessionDir, s.id))
+	if err != nil {
+		log.Println("[session] failed to save an artifact:", err)
+	}
+	return out, found
+}
+
+// addArtifacts remembers the new files for /preview and shows them in the UI
+func (s *Session) addArtifacts(found []artifacts.Artifact) {
+	if len(found) == 0 {
+		return
+	}
+
+	s.artifactsMu.Lock()
+	for _, a := range found {
+		if !slices.Contains(s.artifacts, a) {
+			s.artifacts = append(s.artifacts, a)
+		}
+	}
+	s.artifactsMu.Unlock()
+
+	s.events <- ui.EventArtifacts(found)
+}
+
+// Artifacts returns the files saved from the output of the tools and the
+// model in this session
+func (s *Session) Artifacts() []artifacts.Artifact {
+	s.artifactsMu.Lock()
+	defer s.artifactsMu.Unlock()
+	return slices.Clone(s.artifacts)
+}
@@ -0,0 +1,83 @@
+package chat
+
+import (
+	"errors"
+	"log"
+
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/history"
+	"github.com/penguinpowernz/clai/internal/ui"
+)
+
+var errNoHistory = errors.New("the history isn't being saved, so the session can't be branched")
+
+// Branch copies the conversation so far to a new session branched from this
+// one, the conversation carries on in the branch and the session it was
+// branched from is kept as it was
+func (s *Session) Branch(name string) (string, error) {
+	if s.history == nil || !s.config.SaveHistory {
+		return "", errNoHistory
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.saveHistory()
+	id := history.NewID()
+	if err := s.history.Branch(id, name); err != nil {
+		return "", err
+	}
+
+	log.Printf("[session] branched %s off %s", id, s.id)
+	s.id = id
+	return id, nil
+}
+
+// SwitchBranch carries on the conversation in another branch of the
+// session, or any other saved session
+func (s *Session) SwitchBranch(id string) error {
+	if s.history == nil || !s.config.SaveHistory {
+		return errNoHistory
+	}
+
+	s.mu.Lock()
+	h, err := s.history.Switch(id)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+
+	log.Printf("[session] switched from %s to %s", s.id, id)
+	s.id = id
+	s.messages = append(make([]ai.Message, 0, len(h.Context)), h.Context...)
+	s.variants = nil
+	s.variant = 0
+	s.autoContinued = 0
+	s.mu.Unlock()
+
+	s.titleMu.Lock()
+	s.title, s.summary = h.Title, h.Summary
+	s.titled = h.Title != ""
+	s.titleMu.Unlock()
+
+	s.events <- ui.EventBranchSwitched{ID: id, Transcript: h.UI}
+	if h.Title != "" {
+		s.events <- ui.EventTitle{Title: h.Title, Summary: h.Summary}
+	}
+	return nil
+}
+
+// Branches returns the tree of branches the session is in
+func (s *Session) Branches() (*history.Node, error) {
+	entries, err := history.ListDir(s.config.SessionDir)
+	if err != nil {
+		return nil, err
+	}
+
+	id := s.ID()
+	if family := history.Family(history.Tree(entries), id); family != nil {
+		return family, nil
+	}
+	// nothing has been saved yet
+	return &history.Node{Entry: history.Entry{ID: id}}, nil
+}
@@ -0,0 +1,133 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/ui"
+	"github.com/penguinpowernz/clai/internal/usage"
+)
+
+// errOverBudget is returned when a request isn't sent because the budget has
+// been spent
+var errOverBudget = fmt.Errorf("the budget has been spent")
+
+// SetBudget sets the spending limits, paid requests past them are held back
+// until the user approves them
+func (s *Session) SetBudget(b *usage.Budget) {
+	s.budget = b
+}
+
+// SetTally sets where the requests of the session are counted, for /cost
+func (s *Session) SetTally(t *usage.Tally) {
+	s.tally = t
+}
+
+// Requests returns the tokens and cost of each request the session has made,
+// including the background ones, oldest first
+func (s *Session) Requests() []usage.Record {
+	if s.tally == nil {
+		return nil
+	}
+	return s.tally.Records()
+}
+
+// paid checks if requests to the model cost anything
+func (s *Session) paid(model string) bool {
+	return ai.LookupModel(s.config, model).Cost(1, 1) > 0
+}
+
+// overBudget checks if a request to the model should be held back
+func (s *Session) overBudget(model string) bool {
+	return s.budget != nil && s.paid(model) && s.budget.Blocked()
+}
+
+// holdForBudget tells the UI that the request wasn't sent because the budget
+// was spent, the turn is over until the user approves it.  The admin's cap
+// can't be approved.
+func (s *Session) holdForBudget() {
+	if st, capped := s.budget.Capped(); capped {
+		log.Printf("[session] holding back the request, the admin's $%.2f %s cap has been reached", st.Limit, st.Kind)
+		ev := budgetEvent(st)
+		ev.Held = true
+		s.events <- ev
+		s.events <- ui.EventSystemMsg(fmt.Sprintf("The %s spending cap of $%.2f set by the admin has been reached ($%.2f), the prompt was not sent.  It can't be approved with /budget ok.", st.Kind, st.Limit, st.Spent))
+		s.events <- ui.EventTurnDone{}
+		return
+	}
+
+	st := s.budget.Status()
+	log.Printf("[session] holding back the request, $%.4f of the $%.2f %s budget has been spent", st.Spent, st.Limit, st.Kind)
+
+	s.heldForBudget = true
+	ev := budgetEvent(st)
+	ev.Held = true
+	s.events <- ev
+	s.events <- ui.EventSystemMsg(fmt.Sprintf("The %s budget of $%.2f has been spent ($%.2f), the prompt was not sent.  Use /budget ok to send it and allow more paid requests this session.", st.Kind, st.Limit, st.Spent))
+	s.events <- ui.EventTurnDone{}
+}
+
+// reportBudget tells the UI how much of the budget has been spent
+func (s *Session) reportBudget() {
+	if s.budget == nil {
+		return
+	}
+	if st := s.budget.Status(); st.Limit > 0 {
+		s.events <- budgetEvent(st)
+	}
+}
+
+func budgetEvent(st usage.BudgetStatus) ui.EventBudget {
+	return ui.EventBudget{
+		Kind:     st.Kind,
+		Spent:    st.Spent,
+		Limit:    st.Limit,
+		Warning:  st.Warning(),
+		Exceeded: st.Exceeded(),
+	}
+}
+
+// Budget describes the spending against the limits
+func (s *Session) Budget() string {
+	if s.budget == nil {
+		return "There is no budget, set max_session_cost or max_daily_cost to have one"
+	}
+
+	st := s.budget.Status()
+	if st.Limit == 0 {
+		return "There is no budget, set max_session_cost or max_daily_cost to have one"
+	}
+	return fmt.Sprintf("$%.4f of the $%.2f %s budget has been spent", st.Spent, st.Limit, st.Kind)
+}
+
+// Cost is what the session has spent so far
+func (s *Session) Cost() float64 {
+	if s.budget == nil {
+		return 0
+	}
+	return s.budget.SessionCost()
+}
+
+// ApproveBudget allows paid requests past the budget for the rest of the
+// session, a prompt that was held back is sent
+func (s *Session) ApproveBudget(ctx context.Context) error {
+	if s.budget == nil {
+		return fmt.Errorf("there is no budget")
+	}
+	if st, capped := s.budget.Capped(); capped {
+		return fmt.Errorf("the %s spending cap of $%.2f set by the admin has been reached", st.Kind, st.Limit)
+	}
+	s.budget.Approve()
+
+	s.mu.Lock()
+	held := s.heldForBudget
+	s.heldForBudget = false
+	s.mu.Unlock()
+
+	if held {
+		go s.sendFullContext(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package chat
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/penguinpowernz/clai/internal/ai"
+)
+
+// chunkQueue holds the chunks from the provider until the stream gets to
+// them, adjacent text chunks are merged so it stays small however far behind
+// the stream is
+type chunkQueue struct {
+	mu     sync.Mutex
+	chunks []ai.MessageChunk
+	closed bool
+	last   time.Time     // when the provider last sent something
+	ready  chan struct{} // signalled when there is something to take
+}
+
+func newChunkQueue() *chunkQueue {
+	return &chunkQueue{ready: make(chan struct{}, 1), last: time.Now()}
+}
+
+// fill moves the chunks from the provider into the queue until the provider
+// closes the channel or the context is done
+func (q *chunkQueue) fill(ctx context.Context, src <-chan ai.MessageChunk) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case chunk, ok := <-src:
+			if !ok {
+				q.close()
+				return
+			}
+			q.push(chunk)
+		}
+	}
+}
+
+func (q *chunkQueue) push(chunk ai.MessageChunk) {
+	q.mu.Lock()
+	q.last = time.Now()
+	if n := len(q.chunks); n > 0 && mergeable(q.chunks[n-1], chunk) {
+		q.chunks[n-1] = ai.NewChunk(chunk.Type(), q.chunks[n-1].Content+chunk.Content)
+	} else {
+		q.chunks = append(q.chunks, chunk)
+	}
+	q.mu.Unlock()
+	q.signal()
+}
+
+func (q *chunkQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.signal()
+}
+
+func (q *chunkQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default: // already signalled
+	}
+}
+
+// take empties the queue, closed is true once the provider is done and
+// there is nothing more to come
+func (q *chunkQueue) take() (chunks []ai.MessageChunk, closed bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	chunks, q.chunks = q.chunks, nil
+	return chunks, q.closed
+}
+
+// idle returns how long it has been since the provider sent anything
+func (q *chunkQueue) idle() time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return time.Since(q.last)
+}
+
+// mergeable returns true if the chunks are both text of the same type
+func mergeable(a, b ai.MessageChunk) bool {
+	if a.Type() != b.Type() {
+		return false
+	}
+	return a.Type() == ai.ChunkMessage || a.Type() == ai.ChunkThink
+}
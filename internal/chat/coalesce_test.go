@@ -0,0 +1,70 @@
+package chat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkQueueMerges(t *testing.T) {
+	q := newChunkQueue()
+	q.push(ai.NewChunk(ai.ChunkThink, "hmm "))
+	q.push(ai.NewChunk(ai.ChunkThink, "ok"))
+	q.push(ai.NewChunk(ai.ChunkMessage, "Hello"))
+	q.push(ai.NewChunk(ai.ChunkMessage, ", world"))
+	q.push(ai.MessageChunk{ToolCall: &ai.ToolCall{Name: "grep"}})
+	q.push(ai.NewChunk(ai.ChunkFinish, "stop"))
+	q.push(ai.NewChunk(ai.ChunkFinish, "stop"))
+
+	chunks, closed := q.take()
+	assert.False(t, closed)
+	assert.Len(t, chunks, 5)
+	assert.Equal(t, "hmm ok", chunks[0].Content)
+	assert.Equal(t, ai.ChunkMessage, chunks[1].Type())
+	assert.Equal(t, "Hello, world", chunks[1].Content)
+	assert.NotNil(t, chunks[2].ToolCall)
+
+	chunks, _ = q.take()
+	assert.Empty(t, chunks)
+}
+
+func TestChunkQueueFill(t *testing.T) {
+	src := make(chan ai.MessageChunk, 3)
+	src <- ai.NewChunk(ai.ChunkMessage, "a")
+	src <- ai.NewChunk(ai.ChunkMessage, "b")
+	close(src)
+
+	q := newChunkQueue()
+	q.fill(context.Background(), src)
+
+	chunks, closed := q.take()
+	assert.True(t, closed)
+	assert.Len(t, chunks, 1)
+	assert.Equal(t, "ab", chunks[0].Content)
+}
+
+// a provider that is faster than the consumer has its text merged rather
+// than waiting for the consumer
+func TestStreamDoesNotBlockProvider(t *testing.T) {
+	chunks := make([]ai.MessageChunk, 0, 1000)
+	for range 1000 {
+		chunks = append(chunks, ai.NewChunk(ai.ChunkMessage, "x"))
+	}
+	client := &fakeProvider{responses: [][]ai.MessageChunk{chunks}}
+
+	s := NewStream(client)
+	calls := 0
+	s.OnChunk(func(ai.MessageChunk) {
+		calls++
+		if calls == 1 {
+			time.Sleep(50 * time.Millisecond) // stall on the first chunk
+		}
+	})
+
+	assert.NoError(t, s.Start(context.Background(), nil))
+	assert.Len(t, s.Content(), 1000)
+	assert.Less(t, calls, 1000)
+}
@@ -0,0 +1,49 @@
+package chat
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/penguinpowernz/clai/internal/files"
+	"github.com/penguinpowernz/clai/internal/symbols"
+)
+
+// completeSymbol completes the identifier at the end of the line with the
+// names from the project's ctags index, and from the files in the context or
+// mentioned in the line, each option is the whole line
+func (s *Session) completeSymbol(line string) []string {
+	prefix := symbols.Prefix(line)
+	if prefix == "" {
+		return nil
+	}
+
+	var sources [][]string
+	if fn := symbols.FindTags(s.workingDir); fn != "" {
+		names, err := symbols.ReadTags(fn)
+		if err != nil {
+			log.Println("[session] failed to read the tags:", err)
+		}
+		sources = append(sources, names)
+	}
+
+	for _, f := range s.files.GetFiles() {
+		sources = append(sources, symbols.FromText(f.Content))
+	}
+
+	for _, m := range reTaggedFilename.FindAllString(line, -1) {
+		fn := strings.TrimPrefix(m, "@")
+		if files.CheckAccess(*s.config, s.workingDir, fn) != nil {
+			continue
+		}
+		if data, err := fileReader(filepath.Join(s.workingDir, fn)); err == nil {
+			sources = append(sources, symbols.FromText(string(data)))
+		}
+	}
+
+	var options []string
+	for _, name := range symbols.Complete(prefix, sources...) {
+		options = append(options, line[:len(line)-len(prefix)]+name)
+	}
+	return options
+}
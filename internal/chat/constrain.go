@@ -0,0 +1,32 @@
+package chat
+
+import (
+	"context"
+
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/ui"
+)
+
+// constrain holds the response to the request sent with the context to the
+// format set with constrain or /constrain, background requests like the
+// title are left alone.  If the schema or grammar can't be read, or the
+// provider can't take it, the user is told and the request is sent without it.
+func (s *Session) constrain(ctx context.Context) context.Context {
+	c := s.config.Constrain
+	if !c.IsSet() {
+		return ctx
+	}
+
+	if err := c.Check(s.config.Provider); err != nil {
+		s.events <- ui.EventSystemMsg("Not constraining the response: " + err.Error())
+		return ctx
+	}
+
+	schema, grammar, err := c.Load(s.workingDir)
+	if err != nil {
+		s.events <- ui.EventSystemMsg("Not constraining the response: " + err.Error())
+		return ctx
+	}
+
+	return ai.WithConstraint(ctx, ai.Constraint{JSON: c.JSON, Schema: schema, Grammar: grammar})
+}
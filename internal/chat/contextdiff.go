@@ -0,0 +1,193 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/penguinpowernz/clai/internal/ai"
+)
+
+// sentContext is what was sent to the provider in a request
+type sentContext struct {
+	model    string
+	system   string
+	messages []ai.Message
+}
+
+func (c sentContext) tokens() int {
+	n := ai.EstimateTokens(c.system)
+	for _, m := range c.messages {
+		n += messageTokens(m)
+	}
+	return n
+}
+
+// setSent remembers what was sent in the last request, for /context diff
+func (s *Session) setSent(model string, msgs []ai.Message) {
+	s.sentMu.Lock()
+	defer s.sentMu.Unlock()
+	s.sent = &sentContext{model: model, system: s.SystemPrompt(), messages: msgs}
+}
+
+// nextContext is what would be sent if a request was made now
+func (s *Session) nextContext() sentContext {
+	return sentContext{model: s.model(), system: s.SystemPrompt(), messages: s.contextMessages()}
+}
+
+// NextContext describes what will be sent to the provider in the next request
+func (s *Session) NextContext() string {
+	next := s.nextContext()
+	trimmed := len(s.messages) - len(next.messages)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "The next request to %s has ~%d tokens:\n", next.model, next.tokens())
+	fmt.Fprintf(&b, "  system prompt (~%d tokens)\n", ai.EstimateTokens(next.system))
+	for _, m := range next.messages {
+		b.WriteString("  " + describeMessage(m) + "\n")
+	}
+	if trimmed > 0 {
+		fmt.Fprintf(&b, "%d older messages are left out to fit the context window\n", trimmed)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// ContextDiff describes what the next request will add to and remove from
+// what was sent in the last one, to see why the model forgot something
+func (s *Session) ContextDiff() string {
+	s.sentMu.Lock()
+	last := s.sent
+	s.sentMu.Unlock()
+
+	if last == nil {
+		return "Nothing has been sent yet, /context shows what the first request will have"
+	}
+
+	next := s.nextContext()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Compared to the last request (~%d tokens), the next one (~%d tokens) has:\n", last.tokens(), next.tokens())
+
+	if last.model != next.model {
+		fmt.Fprintf(&b, "  model %s instead of %s\n", next.model, last.model)
+	}
+
+	if last.system == next.system {
+		b.WriteString("  the same system prompt\n")
+	} else {
+		b.WriteString("  a different system prompt:\n")
+		for _, line := range diff(strings.Split(last.system, "\n"), strings.Split(next.system, "\n")) {
+			if line[0] != ' ' {
+				b.WriteString("    " + line + "\n")
+			}
+		}
+	}
+
+	// messages that aren't in the next request but are still in the session
+	// were trimmed to fit, the rest were cleared or redacted
+	kept := map[string]bool{}
+	for _, m := range s.messages {
+		kept[messageKey(m)] = true
+	}
+
+	var lastKeys, nextKeys []string
+	for _, m := range last.messages {
+		lastKeys = append(lastKeys, messageKey(m))
+	}
+	for _, m := range next.messages {
+		nextKeys = append(nextKeys, messageKey(m))
+	}
+
+	var added, removed, same int
+	li, ni := 0, 0
+	for _, line := range diff(lastKeys, nextKeys) {
+		switch line[0] {
+		case '-':
+			m := last.messages[li]
+			li++
+			removed++
+			why := "removed"
+			if kept[messageKey(m)] {
+				why = "trimmed to fit the context window"
+			}
+			fmt.Fprintf(&b, "  - %s, %s\n", describeMessage(m), why)
+		case '+':
+			added++
+			fmt.Fprintf(&b, "  + %s\n", describeMessage(next.messages[ni]))
+			ni++
+		default:
+			li++
+			ni++
+			same++
+		}
+	}
+
+	fmt.Fprintf(&b, "Messages kept: %d, added: %d, removed: %d", same, added, removed)
+	return b.String()
+}
+
+// messageKey is what is sent to the provider for the message
+func messageKey(m ai.Message) string {
+	tc, _ := json.Marshal(m.Calls())
+	return m.Role + "\x00" + m.ToolCallID + "\x00" + string(tc) + "\x00" + m.Content
+}
+
+// describeMessage gives the role, size and start of a message on one line
+func describeMessage(m ai.Message) string {
+	text := m.Content
+	if m.IsToolCall() {
+		var calls []string
+		for _, tc := range m.Calls() {
+			args, _ := json.Marshal(tc.Input)
+			calls = append(calls, tc.Name+" "+string(args))
+		}
+		text = strings.Join(calls, ", ")
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	if len([]rune(text)) > 60 {
+		text = string([]rune(text)[:60]) + "…"
+	}
+	return fmt.Sprintf("%s (~%d tokens): %s", m.Role, ai.EstimateTokens(m.Content), text)
+}
+
+// diff compares two lists with the longest common subsequence, returning
+// each item prefixed by "-" if it was removed, "+" if it was added, or " "
+func diff(a, b []string) []string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out []string
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, " "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, "-"+a[i])
+			i++
+		default:
+			out = append(out, "+"+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out = append(out, "-"+a[i])
+	}
+	for ; j < len(b); j++ {
+		out = append(out, "+"+b[j])
+	}
+	return out
+}
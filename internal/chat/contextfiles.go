@@ -0,0 +1,56 @@
+package chat
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/ui"
+)
+
+// withFiles puts the files added with /add in front of the last prompt, as
+// many as fit in the budget of tokens.  They are only added to what is sent
+// so the history doesn't fill up with copies of them, and the model sees
+// them as they are now.
+func (s *Session) withFiles(messages []ai.Message, budget int) []ai.Message {
+	if s.files == nil || s.files.GetFileCount() == 0 {
+		return messages
+	}
+
+	i := len(messages) - 1
+	for ; i >= 0; i-- {
+		if isPrompt(messages[i]) {
+			break
+		}
+	}
+	if i < 0 {
+		return messages
+	}
+
+	prompt, omitted := s.files.BuildPrompt(messages[i].Content, max(budget, 1))
+	s.noteOmitted(omitted)
+
+	messages = slices.Clone(messages)
+	messages[i].Content = prompt
+	return messages
+}
+
+// noteOmitted tells the user which files were left out of the context, when
+// that changes
+func (s *Session) noteOmitted(omitted []string) {
+	list := strings.Join(omitted, ", ")
+
+	s.omittedMu.Lock()
+	changed := list != s.omitted
+	s.omitted = list
+	s.omittedMu.Unlock()
+
+	if !changed || list == "" {
+		return
+	}
+
+	log.Println("[session] files left out of the context:", list)
+	s.events <- ui.EventSystemMsg(fmt.Sprintf("Left %s out of the context to keep within the token budget, the AI was told it can read them with read_file", list))
+}
@@ -0,0 +1,71 @@
+package chat
+
+import (
+	"io"
+
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/history"
+	"github.com/penguinpowernz/clai/internal/tools"
+)
+
+// ToolRegistry is where the session gets the tools it offers the AI, and
+// runs the calls to them
+type ToolRegistry interface {
+	Tools() []tools.Tool
+	Execute(call tools.ToolUse, workingDir string, live io.Writer) tools.ToolResult
+}
+
+// toolDescriber is a ToolRegistry that can say what a call will do before
+// it is run, and which files it changed
+type toolDescriber interface {
+	Describe(call tools.ToolUse, workingDir string) tools.Details
+	Changes(call tools.ToolUse, workingDir string) []string
+}
+
+// SetToolRegistry replaces the tools the session offers the AI
+func (s *Session) SetToolRegistry(r ToolRegistry) {
+	s.registry = r
+	s.client.SetTools(r.Tools())
+}
+
+// ToolRegistry returns the tools of the session
+func (s *Session) ToolRegistry() ToolRegistry {
+	return s.registry
+}
+
+func (s *Session) describeTool(call tools.ToolUse) tools.Details {
+	if d, ok := s.registry.(toolDescriber); ok {
+		return d.Describe(call, s.workingDir)
+	}
+	return tools.Details{Args: string(call.Input)}
+}
+
+func (s *Session) toolChanges(call tools.ToolUse) []string {
+	if d, ok := s.registry.(toolDescriber); ok {
+		return d.Changes(call, s.workingDir)
+	}
+	return nil
+}
+
+// Store is where the messages of the session are saved
+type Store interface {
+	// Save saves the messages of the "context" sent to the AI
+	Save(what string, messages []ai.Message) error
+	SaveTitle(title, summary string) error
+}
+
+// SetStore sets where the messages are saved when there is no history file,
+// like when the session is embedded in another program
+func (s *Session) SetStore(st Store) {
+	s.store = st
+}
+
+// SetHistory sets where the history of the session is saved, it should be
+// shared with the UI
+func (s *Session) SetHistory(h *history.Store) {
+	s.history = h
+	s.store = nil
+	if h != nil {
+		s.store = h
+	}
+}
@@ -0,0 +1,62 @@
+package chat
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/ui"
+)
+
+// maxFollowUps is how many follow-up prompts are suggested
+const maxFollowUps = 3
+
+const followUpPrompt = `Suggest up to 3 short follow-up prompts that I might send you next, based on
+the conversation so far.  Write them as I would write them, one per line, with no
+numbering, quotes or anything else.`
+
+var reListMarker = regexp.MustCompile(`^\s*(?:[-*•]|\d+[.)])\s*`)
+
+// suggestFollowUps asks the LLM for some prompts the user might send next and
+// sends them to the UI as quick-picks
+func (s *Session) suggestFollowUps(ctx context.Context, messages []ai.Message) {
+	messages = append(messages, ai.Message{Role: "user", Content: followUpPrompt})
+
+	res, err := s.client.SendMessage(s.requestContext(ctx, s.backgroundModel()), messages)
+	if err != nil {
+		log.Println("[session] failed to get follow-up suggestions:", err)
+		return
+	}
+
+	var content strings.Builder
+	ts := newTagScanner(s.config.ThinkTags...)
+	for _, seg := range append(ts.Feed(res.Content), ts.Flush()...) {
+		if !seg.think {
+			content.WriteString(seg.text)
+		}
+	}
+
+	if followUps := parseFollowUps(content.String()); len(followUps) > 0 {
+		s.events <- ui.EventFollowUps(followUps)
+	}
+}
+
+// parseFollowUps gets the suggested prompts from the response, one per line
+func parseFollowUps(content string) []string {
+	var out []string
+	for _, line := range strings.Split(content, "\n") {
+		line = reListMarker.ReplaceAllString(line, "")
+		line = strings.Trim(strings.TrimSpace(line), `"`)
+		if line == "" {
+			continue
+		}
+
+		out = append(out, line)
+		if len(out) == maxFollowUps {
+			break
+		}
+	}
+	return out
+}
@@ -0,0 +1,14 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFollowUps(t *testing.T) {
+	out := parseFollowUps("1. Add tests for it\n\n2) \"Refactor the parser\"\n- explain the regex\n* one too many")
+	assert.Equal(t, []string{"Add tests for it", "Refactor the parser", "explain the regex"}, out)
+
+	assert.Empty(t, parseFollowUps("\n \n"))
+}
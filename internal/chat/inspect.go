@@ -0,0 +1,79 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/penguinpowernz/clai/internal/ai"
+)
+
+// Inspect returns the body of the request that sending the prompt would make,
+// without sending it, and roughly how many tokens each part of it has.  An
+// empty prompt gives the request for the conversation as it is.
+func (s *Session) Inspect(ctx context.Context, prompt string) (payload []byte, tokens string, err error) {
+	insp, ok := s.client.(ai.Inspector)
+	if !ok {
+		return nil, "", fmt.Errorf("the %s provider can't show its requests", s.config.Provider)
+	}
+
+	model := s.model()
+	msgs := slices.Clone(s.messages)
+	if prompt != "" {
+		model = s.config.Model
+		if s.useCheapModel(prompt) {
+			model = s.config.CheapModel
+		}
+		msgs = append(msgs, ai.Message{Role: "user", Content: enhanceMessage(s.config, s.workingDir, prompt)})
+	}
+
+	payload, err = insp.Inspect(s.constrain(s.requestContext(ctx, model)), s.fitContext(model, msgs))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to build the request: %w", err)
+	}
+
+	return payload, countSections(payload), nil
+}
+
+// countSections estimates the tokens in each field of the request, and for
+// the messages in each role
+func countSections(payload []byte) string {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return ""
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return len(fields[names[i]]) > len(fields[names[j]]) })
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "~%d tokens in total\n", ai.EstimateTokensLen(len(payload)))
+	for _, name := range names {
+		fmt.Fprintf(&b, "  %-12s ~%d tokens", name, ai.EstimateTokensLen(len(fields[name])))
+
+		var msgs []ai.Message
+		if name == "messages" && json.Unmarshal(fields[name], &msgs) == nil {
+			var roles []string
+			byRole := map[string]int{}
+			for _, m := range msgs {
+				if _, ok := byRole[m.Role]; !ok {
+					roles = append(roles, m.Role)
+				}
+				byRole[m.Role] += ai.EstimateTokens(m.Content)
+			}
+			for i, role := range roles {
+				roles[i] = fmt.Sprintf("%s ~%d", role, byRole[role])
+			}
+			fmt.Fprintf(&b, " (%d: %s)", len(msgs), strings.Join(roles, ", "))
+		}
+		b.WriteString("\n")
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,68 @@
+package chat
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/penguinpowernz/clai/internal/ui"
+)
+
+// liveOutputInterval is how often the output of a running tool is passed on
+// to the UI, so a chatty tool doesn't flood it
+const liveOutputInterval = 200 * time.Millisecond
+
+// liveOutput collects the output of a running tool and sends what has come
+// in to the UI every liveOutputInterval
+type liveOutput struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	events chan any
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+func newLiveOutput(events chan any) *liveOutput {
+	l := &liveOutput{events: events, stop: make(chan struct{}), done: make(chan struct{})}
+	go l.run()
+	return l
+}
+
+func (l *liveOutput) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.Write(p)
+}
+
+func (l *liveOutput) run() {
+	defer close(l.done)
+	t := time.NewTicker(liveOutputInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			l.flush()
+		case <-l.stop:
+			l.flush()
+			return
+		}
+	}
+}
+
+func (l *liveOutput) flush() {
+	l.mu.Lock()
+	out := l.buf.String()
+	l.buf.Reset()
+	l.mu.Unlock()
+
+	if out != "" {
+		l.events <- ui.EventToolProgress(out)
+	}
+}
+
+// Close sends the last of the output, it must be called when the tool is done
+func (l *liveOutput) Close() {
+	close(l.stop)
+	<-l.done
+}
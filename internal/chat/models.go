@@ -0,0 +1,77 @@
+package chat
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// maxRecentModels is how many of the recently used models are remembered
+const maxRecentModels = 5
+
+// SetModel changes the model for the rest of the session, it is put at the
+// front of the recent models
+func (s *Session) SetModel(model string) {
+	s.config.Model = model
+	s.addRecentModel(model)
+}
+
+// RecentModels returns the models used most recently, the latest first
+func (s *Session) RecentModels() []string {
+	return s.recentModels
+}
+
+// SetRecentModels sets the file the recently used models are kept in, so
+// they are remembered between sessions
+func (s *Session) SetRecentModels(fn string) {
+	s.recentFile = fn
+
+	data, err := os.ReadFile(fn)
+	if err != nil && !os.IsNotExist(err) {
+		log.Println("[session] failed to read the recent models:", err)
+	}
+	s.recentModels = strings.Fields(string(data))
+
+	s.addRecentModel(s.config.Model)
+}
+
+// CycleModel switches to the next of the recent models, the order isn't
+// changed so cycling visits each of them in turn
+func (s *Session) CycleModel() (string, error) {
+	if len(s.recentModels) < 2 {
+		return "", fmt.Errorf("no other models have been used yet, pick one with /models")
+	}
+
+	next := s.recentModels[0]
+	for i, model := range s.recentModels {
+		if model == s.config.Model {
+			next = s.recentModels[(i+1)%len(s.recentModels)]
+			break
+		}
+	}
+
+	s.config.Model = next
+	return next, nil
+}
+
+func (s *Session) addRecentModel(model string) {
+	if model == "" {
+		return
+	}
+
+	recent := []string{model}
+	for _, m := range s.recentModels {
+		if m != model && len(recent) < maxRecentModels {
+			recent = append(recent, m)
+		}
+	}
+	s.recentModels = recent
+
+	if s.recentFile == "" {
+		return
+	}
+	if err := os.WriteFile(s.recentFile, []byte(strings.Join(recent, "\n")+"\n"), 0644); err != nil {
+		log.Println("[session] failed to save the recent models:", err)
+	}
+}
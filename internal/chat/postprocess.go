@@ -0,0 +1,74 @@
+package chat
+
+import (
+	"log"
+	"regexp"
+	"strings"
+)
+
+// postProcess runs the response through the post_process steps, in the order
+// they are given, before it is shown and saved
+func (s *Session) postProcess(text string) string {
+	for _, step := range s.config.PostProcess {
+		switch step {
+		case "strip_think":
+			text = stripThink(text, s.config.ThinkTags...)
+		case "fences":
+			text = normalizeFences(text)
+		case "relative_paths":
+			text = relativePaths(text, s.workingDir)
+		case "replace":
+			for _, r := range s.config.Replacements {
+				re, err := regexp.Compile(r.Pattern)
+				if err != nil {
+					log.Printf("[session] ignoring replacements pattern %q: %s", r.Pattern, err)
+					continue
+				}
+				text = re.ReplaceAllString(text, r.With)
+			}
+		}
+	}
+	return text
+}
+
+// stripThink removes the reasoning the stream didn't catch: blocks in the
+// think tags, and everything before a closing tag that was never opened, as
+// some chat templates put the opening tag in the prompt
+func stripThink(text string, tags ...string) string {
+	for _, tag := range tags {
+		open, closing := "<"+tag+">", "</"+tag+">"
+		for {
+			end := strings.Index(text, closing)
+			if end < 0 {
+				break
+			}
+			start := strings.LastIndex(text[:end], open)
+			if start < 0 {
+				start = 0
+			}
+			text = text[:start] + text[end+len(closing):]
+		}
+	}
+	return strings.TrimSpace(text)
+}
+
+// reFence matches the line that opens or closes a code block, with the fence
+// and what comes after it
+var reFence = regexp.MustCompile("(?m)^([ \t]*)(```|~~~)[ \t]*([^\\s`~]*)[ \t]*$")
+
+// normalizeFences turns ~~~ fences into backtick ones and tidies the language
+// after them, so "~~~ Go" becomes "```go"
+func normalizeFences(text string) string {
+	return reFence.ReplaceAllStringFunc(text, func(line string) string {
+		m := reFence.FindStringSubmatch(line)
+		return m[1] + "```" + strings.ToLower(m[3])
+	})
+}
+
+// relativePaths makes the paths in the working dir relative to it
+func relativePaths(text, wd string) string {
+	if wd == "" || wd == "/" {
+		return text
+	}
+	return strings.ReplaceAll(text, strings.TrimSuffix(wd, "/")+"/", "")
+}
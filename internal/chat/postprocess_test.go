@@ -0,0 +1,39 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripThink(t *testing.T) {
+	tags := config.Default().ThinkTags
+	assert.Equal(t, "the answer", stripThink("<think>hmm</think>the answer", tags...))
+	assert.Equal(t, "the answer", stripThink("hmm, let me see</think>\n\nthe answer", tags...))
+	assert.Equal(t, "a b", stripThink("a <plan>x</plan>b", "plan"))
+	assert.Equal(t, "no reasoning", stripThink("no reasoning", tags...))
+	assert.Equal(t, "<think>hmm</think>the answer", stripThink("<think>hmm</think>the answer"))
+}
+
+func TestNormalizeFences(t *testing.T) {
+	in := "Run:\n~~~ Bash\nls\n~~~\n\n  ```Go  \nfunc main() {}\n  ```\nuse ``` inline"
+	want := "Run:\n```bash\nls\n```\n\n  ```go\nfunc main() {}\n  ```\nuse ``` inline"
+	assert.Equal(t, want, normalizeFences(in))
+}
+
+func TestPostProcess(t *testing.T) {
+	s := &Session{workingDir: "/home/me/src/app", config: &config.Config{
+		PostProcess:  []string{"relative_paths", "replace"},
+		Replacements: []config.Replacement{{Pattern: `\bcolour\b`, With: "color"}},
+	}}
+
+	got := s.postProcess("Change the colour in /home/me/src/app/ui/theme.go, not /etc/app.conf")
+	assert.Equal(t, "Change the color in ui/theme.go, not /etc/app.conf", got)
+
+	// the steps run in the order given
+	s.config.Replacements = []config.Replacement{{Pattern: `^ui/`, With: "web/"}}
+	assert.Equal(t, "web/theme.go", s.postProcess("/home/me/src/app/ui/theme.go"))
+	s.config.PostProcess = []string{"replace", "relative_paths"}
+	assert.Equal(t, "ui/theme.go", s.postProcess("/home/me/src/app/ui/theme.go"))
+}
@@ -0,0 +1,71 @@
+package chat
+
+import (
+	"context"
+	"log"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/prompt"
+)
+
+// PromptLayers returns the layers the system prompt is made from, in order
+func (s *Session) PromptLayers() []prompt.Layer {
+	layers := []prompt.Layer{{Name: "default", Text: config.DefaultSystemPrompt()}}
+
+	if p := s.config.SystemPrompt; p != "" && p != config.DefaultSystemPrompt() {
+		layers = append(layers, prompt.Layer{Name: "config", Text: p, Replaces: true})
+	}
+
+	fn, text, err := prompt.FindProject(s.workingDir, s.config.ProjectPrompt)
+	if err != nil {
+		log.Println("[session] failed to read the project prompt:", err)
+	}
+	if fn != "" {
+		layers = append(layers, prompt.Layer{Name: "project", Source: fn, Text: text})
+	}
+
+	s.promptMu.Lock()
+	defer s.promptMu.Unlock()
+	layers = append(layers, s.packPrompts...)
+
+	if p, ok := s.config.Personas[s.config.Persona]; ok {
+		layers = append(layers, prompt.Layer{Name: "persona", Source: s.config.Persona, Text: p})
+	}
+
+	if p := s.config.Style.Prompt(); p != "" {
+		layers = append(layers, prompt.Layer{Name: "style", Text: p})
+	}
+
+	if s.sessionPrompt != "" {
+		layers = append(layers, prompt.Layer{Name: "session", Text: s.sessionPrompt, Replaces: true})
+	}
+
+	return layers
+}
+
+// SystemPrompt returns the system prompt that is sent with each request
+func (s *Session) SystemPrompt() string {
+	return prompt.Compose(s.PromptLayers())
+}
+
+// SetSystemPrompt replaces the system prompt for the rest of the session, an
+// empty prompt goes back to the one made from the other layers
+func (s *Session) SetSystemPrompt(text string) {
+	s.promptMu.Lock()
+	defer s.promptMu.Unlock()
+	s.sessionPrompt = text
+}
+
+// requestContext sets the model and system prompt for a request
+func (s *Session) requestContext(ctx context.Context, model string) context.Context {
+	return ai.WithSystemPrompt(ai.WithModel(ctx, model), s.SystemPrompt())
+}
+
+// SetPackPrompts adds the prompts of the team's packs to the system prompt,
+// after the project prompt
+func (s *Session) SetPackPrompts(layers []prompt.Layer) {
+	s.promptMu.Lock()
+	defer s.promptMu.Unlock()
+	s.packPrompts = layers
+}
@@ -0,0 +1,57 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/ui"
+)
+
+// reconnectDelays are how long to wait between the tries to reach the
+// provider when it is down, the last is used for the rest of the tries
+var reconnectDelays = []time.Duration{time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second}
+
+// waitToReconnect waits before trying to reach the provider again, it returns
+// false if the user gave up waiting
+func (s *Session) waitToReconnect(ctx context.Context, delay time.Duration) bool {
+	wctx, cancel := context.WithTimeout(ctx, delay)
+	defer cancel()
+
+	s.reconnectMu.Lock()
+	s.cancelReconnect = cancel
+	s.reconnectMu.Unlock()
+
+	<-wctx.Done()
+
+	s.reconnectMu.Lock()
+	s.cancelReconnect = nil
+	s.reconnectMu.Unlock()
+
+	return errors.Is(wctx.Err(), context.DeadlineExceeded)
+}
+
+// stopReconnecting gives up trying to reach the provider, it returns false if
+// it wasn't being tried
+func (s *Session) stopReconnecting() bool {
+	s.reconnectMu.Lock()
+	defer s.reconnectMu.Unlock()
+
+	if s.cancelReconnect == nil {
+		return false
+	}
+	s.cancelReconnect()
+	return true
+}
+
+// notifyProvider tells the user when a request sent with the context failed
+// and is being tried again, see the retry config, or sent to a fallback
+func (s *Session) notifyProvider(ctx context.Context) context.Context {
+	ctx = ai.WithRetryNotice(ctx, func(n ai.RetryNotice) {
+		s.events <- ui.EventSystemMsg("The request to the provider failed: " + n.String())
+	})
+	return ai.WithFailoverNotice(ctx, func(n ai.FailoverNotice) {
+		s.events <- ui.EventSystemMsg("The provider " + n.String())
+	})
+}
@@ -0,0 +1,77 @@
+package chat
+
+import (
+	"encoding/json"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/penguinpowernz/clai/internal/ai"
+)
+
+// redactedInput takes the place of the input of a tool call that was redacted
+var redactedInput = json.RawMessage(`{"input":"` + ai.Redacted + `"}`)
+
+// Redact masks the messages with the IDs, including the responses kept by
+// /retry, so they are gone from the history and aren't sent to the AI again.
+// The ID of a tool call masks its input and its output.
+func (s *Session) Redact(ids []string) {
+	s.mu.Lock()
+	n := redactMessages(s.messages, ids)
+	for _, v := range s.variants {
+		n += redactMessages(v, ids)
+	}
+	s.mu.Unlock()
+
+	log.Printf("[session] redacted %d messages", n)
+	s.saveHistory()
+}
+
+// redactMessages masks the messages with the IDs, the tool calls with them
+// and their results, returning how many messages were changed
+func redactMessages(msgs []ai.Message, ids []string) int {
+	// the messages that didn't get an ID can't be picked out
+	ids = slices.DeleteFunc(slices.Clone(ids), func(id string) bool { return id == "" })
+
+	var n int
+	for i := range msgs {
+		m := &msgs[i]
+		changed := false
+
+		if slices.Contains(ids, m.ID) || slices.Contains(ids, m.ToolCallID) {
+			m.Content = ai.Redacted
+			changed = true
+		}
+
+		if m.ToolCall != nil && slices.Contains(ids, m.ToolCall.ID) {
+			m.Content = redactInput(m.Content, m.ToolCall)
+			changed = true
+		}
+		for j := range m.ToolCalls {
+			if slices.Contains(ids, m.ToolCalls[j].ID) {
+				m.Content = redactInput(m.Content, &m.ToolCalls[j])
+				changed = true
+			}
+		}
+
+		if changed {
+			m.Tokens = ai.EstimateTokens(m.Content)
+			n++
+		}
+	}
+	return n
+}
+
+// redactInput masks the input of the tool call, and where it is in the
+// content of the message that made the call
+func redactInput(content string, tc *ai.ToolUse) string {
+	input, ok := tc.Input.(json.RawMessage)
+	if !ok {
+		input, _ = json.Marshal(tc.Input)
+	}
+	if len(input) > 2 {
+		content = strings.ReplaceAll(content, string(input), ai.Redacted)
+	}
+	tc.Input = redactedInput
+	return content
+}
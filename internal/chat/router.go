@@ -0,0 +1,53 @@
+package chat
+
+import (
+	"regexp"
+	"strings"
+)
+
+// maxTrivialPrompt is the longest prompt that can be sent to the cheap model
+const maxTrivialPrompt = 280
+
+// reCodeWork matches prompts that ask for code to be read or changed, which
+// always go to the main model
+var reCodeWork = regexp.MustCompile(`(?i)\b(fix|refactor|implement|write|rewrite|change|edit|add|remove|delete|rename|update|create|apply|debug|review|test|build|run|install|generate|migrate|patch|commit)\b`)
+
+// reFileRef matches mentions of files, like @main.go or internal/chat/session.go
+var reFileRef = regexp.MustCompile(`@\S+|\b[\w./-]+\.[a-z]{1,5}\b|\w/\w`)
+
+// trivialPrompt guesses if the prompt can be answered by the cheap model, a
+// short question that doesn't mention code or files
+func trivialPrompt(prompt string) bool {
+	prompt = strings.TrimSpace(prompt)
+	switch {
+	case prompt == "", len(prompt) > maxTrivialPrompt:
+		return false
+	case strings.Contains(prompt, "```"), strings.Contains(prompt, "\n"):
+		return false
+	case reCodeWork.MatchString(prompt), reFileRef.MatchString(prompt):
+		return false
+	}
+	return true
+}
+
+// useCheapModel decides if the prompt can be answered by the cheap model
+func (s *Session) useCheapModel(prompt string) bool {
+	return s.config.CheapModel != "" && trivialPrompt(prompt)
+}
+
+// model is the model answering the current prompt
+func (s *Session) model() string {
+	if s.cheapTurn && s.config.CheapModel != "" {
+		return s.config.CheapModel
+	}
+	return s.config.Model
+}
+
+// backgroundModel is the model for requests the user didn't ask for, like
+// suggesting follow-ups
+func (s *Session) backgroundModel() string {
+	if s.config.CheapModel != "" {
+		return s.config.CheapModel
+	}
+	return s.config.Model
+}
@@ -0,0 +1,19 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrivialPrompt(t *testing.T) {
+	assert.True(t, trivialPrompt("what is a goroutine?"))
+	assert.True(t, trivialPrompt("thanks, that makes sense"))
+
+	assert.False(t, trivialPrompt(""))
+	assert.False(t, trivialPrompt("fix the failing test"))
+	assert.False(t, trivialPrompt("what does @main.go do?"))
+	assert.False(t, trivialPrompt("explain internal/chat/session.go"))
+	assert.False(t, trivialPrompt("what is wrong with this?\n```go\nx := 1\n```"))
+	assert.False(t, trivialPrompt(string(make([]byte, maxTrivialPrompt+1))))
+}
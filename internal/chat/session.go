@@ -2,18 +2,27 @@ package chat
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/penguinpowernz/clai/config"
 	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/artifacts"
 	"github.com/penguinpowernz/clai/internal/commands"
 	"github.com/penguinpowernz/clai/internal/files"
 	"github.com/penguinpowernz/clai/internal/history"
+	"github.com/penguinpowernz/clai/internal/prompt"
 	"github.com/penguinpowernz/clai/internal/tools"
 	"github.com/penguinpowernz/clai/internal/ui"
+	"github.com/penguinpowernz/clai/internal/usage"
 )
 
 // UIObserver defines the interface for UI elements to receive updates from the session
@@ -28,14 +37,84 @@ type Session struct {
 	client     ai.Provider
 	messages   []ai.Message
 	files      *files.Context
+	rootDir    string // the directory the session started in, /cd can't leave it
 	workingDir string
-	tools      []tools.Tool
+	registry   ToolRegistry // the tools the AI can use, they can change between requests
 	mu         sync.Mutex
 	currStrm   *Stream
 
+	// responses to the last prompt made by /retry, only the one in use is in
+	// the messages
+	variants [][]ai.Message
+	variant  int
+
+	// continuing is set when the next stream carries on from where the last
+	// response was cut off, autoContinued counts how many times that was done
+	// without asking
+	continuing    bool
+	autoContinued int
+
+	// cheapTurn is set when the router sent the current prompt to the cheap
+	// model
+	cheapTurn bool
+
+	// spending limits, heldForBudget is set when a request was held back as
+	// the budget has been spent
+	budget        *usage.Budget
+	heldForBudget bool
+	tally         *usage.Tally
+
+	// the tokens used by the responses, as the providers counted them
+	usageMu   sync.Mutex
+	usage     ai.Usage
+	lastUsage ai.Usage
+	requests  int
+
+	// the files the tools have written, in the order they were first changed
+	changed []string
+
+	// the files saved from the output of the tools and the model
+	artifactsMu sync.Mutex
+	artifacts   []artifacts.Artifact
+
+	// what was sent in the last request, for /context diff
+	sentMu sync.Mutex
+	sent   *sentContext
+
+	// the files added with /add that were last left out of the context
+	omittedMu sync.Mutex
+	omitted   string
+
+	// the suggested files the user didn't want added, see suggestFiles
+	suggestMu sync.Mutex
+	declined  map[string]bool
+
+	// the title and summary made after the first few exchanges
+	titled         bool
+	titleMu        sync.Mutex
+	title, summary string
+
+	// the models used most recently, the latest first, and the file they are
+	// remembered in
+	recentModels []string
+	recentFile   string
+
+	// the system prompt set with /system, it replaces the other layers, and
+	// the prompts from the team's packs
+	promptMu      sync.Mutex
+	sessionPrompt string
+	packPrompts   []prompt.Layer
+
+	// stops waiting to try to reach the provider again, nil when not waiting
+	reconnectMu     sync.Mutex
+	cancelReconnect context.CancelFunc
+
 	permitToolCall chan bool
 	permittedTools map[string]bool
-	toolCalls      chan *ai.ToolCall
+	toolCalls      chan []*ai.ToolCall
+
+	history *history.Store // where the history is saved, nil to not save it
+	store   Store          // the history, or what an embedding program saves to
 
 	events   chan any // events going out to the UI
 	uievents chan any // events coming in from the UI
@@ -46,6 +125,16 @@ func (s *Session) AddObserver(observer UIObserver) {
 	observer.Observe(s.events)
 }
 
+// ID returns the session ID
+func (s *Session) ID() string {
+	return s.id
+}
+
+// WorkingDir returns the directory tools and file mentions are relative to
+func (s *Session) WorkingDir() string {
+	return s.workingDir
+}
+
 func (s *Session) Export() []ai.Message {
 	return s.messages
 }
@@ -61,8 +150,12 @@ func (s *Session) GetClient() ai.Provider {
 
 func NewSession(cfg *config.Config, client ai.Provider, id string) *Session {
 	wd, _ := os.Getwd()
-	tt := tools.GetAvailableTools()
-	client.SetTools(tt)
+	if root, err := filepath.EvalSymlinks(wd); err == nil {
+		wd = root
+	}
+
+	registry := tools.NewRegistry(cfg)
+	client.SetTools(registry.Tools())
 
 	pt := make(map[string]bool)
 	for _, t := range cfg.PermittedTools {
@@ -75,26 +168,99 @@ func NewSession(cfg *config.Config, client ai.Provider, id string) *Session {
 		client:         client,
 		messages:       make([]ai.Message, 0),
 		files:          files.NewContext(cfg),
+		rootDir:        wd,
 		workingDir:     wd,
-		tools:          tt,
-		events:         make(chan any, 2),
-		uievents:       make(chan any, 2),
+		registry:       registry,
+		events:         make(chan any, ui.EventBuffer),
+		uievents:       make(chan any, ui.EventBuffer),
 		mu:             sync.Mutex{},
 		permittedTools: pt,
 		permitToolCall: make(chan bool, 2),
-		toolCalls:      make(chan *ai.ToolCall, 2),
+		toolCalls:      make(chan []*ai.ToolCall, 2),
 	}
 }
 
 func (s *Session) AddMessage(message ai.Message) {
+	if message.ID == "" {
+		message.ID = uuid.New().String()
+	}
+	if message.Time.IsZero() {
+		message.Time = time.Now()
+	}
+	if message.Tokens == 0 {
+		message.Tokens = ai.EstimateTokens(message.Content)
+	}
+	if message.Role == "assistant" && message.Model == "" {
+		message.Model = s.model()
+	}
+
 	s.messages = append(s.messages, message)
-	if s.config.SaveHistory {
-		if err := history.SaveHistory("context", s.messages); err != nil {
+	s.saveHistory()
+}
+
+// History returns where the history of the session is saved
+func (s *Session) History() *history.Store {
+	return s.history
+}
+
+// HistoryPath returns the file the history is saved in, or nothing if it
+// isn't being saved
+func (s *Session) HistoryPath() string {
+	if s.history == nil || !s.config.SaveHistory {
+		return ""
+	}
+	return s.history.Path()
+}
+
+func (s *Session) saveHistory() {
+	if s.store != nil && s.config.SaveHistory {
+		if err := s.store.Save("context", s.messages); err != nil {
 			log.Println("[session] failed to save history:", err)
 		}
 	}
 }
 
+// healthTimeout is how long the provider has to answer the health check,
+// loading the model can take a lot longer so it has no timeout
+const healthTimeout = 10 * time.Second
+
+// CheckHealth checks that the provider is up and loads the model if warm_up
+// is on, the UI is told how it is going so the user knows why the first
+// response might be slow
+func (s *Session) CheckHealth(ctx context.Context) {
+	hc, ok := s.client.(ai.HealthChecker)
+	if !ok {
+		return
+	}
+
+	s.events <- ui.EventHealth{State: ui.HealthChecking}
+
+	pctx, cancel := context.WithTimeout(ctx, healthTimeout)
+	err := hc.Ping(pctx)
+	cancel()
+	if s.modelMissing(err) {
+		return
+	}
+	if err != nil {
+		log.Println("[session] provider health check failed:", err)
+		s.events <- ui.EventHealth{State: ui.HealthDown, Err: err}
+		return
+	}
+
+	if w, ok := s.client.(ai.WarmUpper); ok && s.config.WarmUp {
+		s.events <- ui.EventHealth{State: ui.HealthWarming}
+		start := time.Now()
+		if err := w.WarmUp(ctx); err != nil {
+			log.Println("[session] failed to warm up the model:", err)
+			s.events <- ui.EventHealth{State: ui.HealthDown, Err: err}
+			return
+		}
+		log.Printf("[session] model %s loaded in %s", s.config.Model, time.Since(start).Round(time.Millisecond))
+	}
+
+	s.events <- ui.EventHealth{State: ui.HealthReady}
+}
+
 // InteractiveMode starts the bubbletea REPL
 func (s *Session) InteractiveMode(ctx context.Context) error {
 	for {
@@ -102,8 +268,8 @@ func (s *Session) InteractiveMode(ctx context.Context) error {
 		case <-ctx.Done():
 			return nil
 
-		case tc := <-s.toolCalls:
-			go s.handleToolCall(ctx, tc)
+		case calls := <-s.toolCalls:
+			go s.handleToolCalls(ctx, calls)
 
 		case ev := <-s.uievents:
 			log.Println("[session] got UI event")
@@ -113,39 +279,188 @@ func (s *Session) InteractiveMode(ctx context.Context) error {
 	}
 }
 
-func (s *Session) handleToolCall(ctx context.Context, tc *ai.ToolCall) {
-	log.Print("[session] handling tool call for tool: ", tc.Name)
+// handleToolCalls runs the tool calls from a response one at a time, or
+// together with parallel_tools, and then sends their output back to the AI
+// together.  If one is denied it and the rest are skipped and the turn ends.
+func (s *Session) handleToolCalls(ctx context.Context, calls []*ai.ToolCall) {
+	queue := newToolQueue(s.events, calls)
 
-	if !tools.IsValid(s.tools, tc.Name) {
-		log.Println("[session] Tool not found:", tc.Name)
-		s.AddMessage(ai.Message{
-			Role:       "user",
-			Content:    "Tool not found: `" + tc.Name + "`, available tools are: " + strings.Join(tools.GetNames(s.tools), ", "),
-			ToolCallID: tc.ID,
-		})
-		if err := s.sendFullContext(ctx); err != nil {
-			log.Println("[session] failed to send full context:", err)
+	if s.config.ParallelTools && len(calls) > 1 {
+		s.handleParallelToolCalls(ctx, calls, queue)
+		return
+	}
+
+	for i, tc := range calls {
+		if !s.handleToolCall(tc, queue, i) {
+			queue.skip(i)
+			s.events <- ui.EventTurnDone{}
+			return
+		}
+	}
+
+	if err := s.sendFullContext(ctx); err != nil {
+		log.Println("[session] failed to send full context:", err)
+	}
+}
+
+// handleParallelToolCalls asks for permission to run the tool calls one at a
+// time, then runs the allowed ones at the same time.  Their output is added
+// in the order the calls were made so the context reads the same as if they
+// were run one by one.  The calls before a denied one are still run.
+func (s *Session) handleParallelToolCalls(ctx context.Context, calls []*ai.ToolCall, queue *toolQueue) {
+	var allowed []int
+	denied := false
+	for i, tc := range calls {
+		if !s.toolExists(tc) {
+			queue.set(i, ui.ToolDone)
+			continue
+		}
+		if !s.approveToolCall(tc, queue, i) {
+			queue.skip(i)
+			denied = true
+			break
 		}
+		allowed = append(allowed, i)
+	}
+
+	results := make([]tools.ToolResult, len(calls))
+	var wg sync.WaitGroup
+	for _, i := range allowed {
+		queue.set(i, ui.ToolRunning)
+		wg.Add(1)
+		go func(tc *ai.ToolCall) {
+			defer wg.Done()
+			// the live output of tools running together can't be told apart
+			results[i] = s.registry.Execute(tools.ToolUse(*tc), s.workingDir, nil)
+		}(calls[i])
+	}
+	wg.Wait()
+	log.Printf("[session] ran %d tool calls in parallel", len(allowed))
+
+	for _, i := range allowed {
+		s.events <- ui.EventRunningTool(*calls[i])
+		s.addToolResult(calls[i], results[i])
+		queue.set(i, ui.ToolDone)
+	}
+
+	if denied {
+		s.events <- ui.EventTurnDone{}
 		return
 	}
 
-	// Check if the tool is permitted, otherwise request permission from UI
+	if err := s.sendFullContext(ctx); err != nil {
+		log.Println("[session] failed to send full context:", err)
+	}
+}
+
+// handleToolCall runs the ith tool call in the queue if it is permitted, it
+// returns false if the user denied it
+func (s *Session) handleToolCall(tc *ai.ToolCall, queue *toolQueue, i int) bool {
+	log.Print("[session] handling tool call for tool: ", tc.Name)
+
+	if !s.toolExists(tc) {
+		queue.set(i, ui.ToolDone)
+		return true
+	}
+
+	if !s.approveToolCall(tc, queue, i) {
+		return false
+	}
+
+	queue.set(i, ui.ToolRunning)
+	s.events <- ui.EventRunningTool(*tc)
+	result := s.executeTool(tc)
+	s.addToolResult(tc, result)
+	queue.set(i, ui.ToolDone)
+	return true
+}
+
+// toolExists checks the AI called a tool it has, if not it is told which
+// tools it can use
+func (s *Session) toolExists(tc *ai.ToolCall) bool {
+	available := s.registry.Tools()
+	if tools.IsValid(available, tc.Name) {
+		return true
+	}
+
+	log.Println("[session] Tool not found:", tc.Name)
+	s.AddMessage(ai.Message{
+		Role:       "user",
+		Content:    "Tool not found: `" + tc.Name + "`, available tools are: " + strings.Join(tools.GetNames(available), ", "),
+		ToolCallID: tc.ID,
+	})
+	return false
+}
+
+// approveToolCall asks the UI for permission to run the tool if it isn't
+// permitted already, it returns false if the user denied it
+func (s *Session) approveToolCall(tc *ai.ToolCall, queue *toolQueue, i int) bool {
 	if _, permitted := s.permittedTools[tc.Name]; !permitted {
 		log.Println("[session] Requesting permission for tool:", tc.Name)
+		queue.set(i, ui.ToolAwaitingApproval)
+		s.events <- ui.EventToolDetails(s.describeTool(tools.ToolUse(*tc)))
 		s.events <- ui.EventToolCall(*tc)
 		log.Println("[session] Waiting for tool call permission...")
 		if ok := <-s.permitToolCall; !ok {
 			log.Println("[session] Permission denied by UI to call tool:", tc.Name)
-			return
+			return false
 		}
 	}
 
-	s.events <- ui.EventRunningTool(*tc)
 	log.Println("[session] Permission granted to call tool:", tc.Name)
-	output := s.executeTool(tc)
-	s.events <- ui.EventRunningToolDone("")
+	return true
+}
+
+// addToolResult shows the result of the tool call and adds its output to the
+// conversation
+func (s *Session) addToolResult(tc *ai.ToolCall, result tools.ToolResult) {
+	s.events <- ui.EventRunningToolDone{Failed: result.IsError, Code: result.Code}
+	if !result.IsError {
+		s.addChanges(s.toolChanges(tools.ToolUse(*tc)))
+	}
+	output, found := s.saveArtifacts(result.Content)
 	s.events <- ui.EventToolOutput(output)
-	s.respondWithToolOutput(ctx, tc.ID, output)
+	s.addArtifacts(found)
+	s.addToolOutput(tc.ID, output)
+}
+
+// pickModel sends the models on the server to the UI for the user to pick one
+func (s *Session) pickModel() {
+	models, err := s.client.ListModelDetails()
+	if err != nil {
+		s.events <- ui.EventSystemMsg("Failed to list the models: " + err.Error())
+		return
+	}
+
+	var options []ui.ModelOption
+	for _, model := range models {
+		caps := ai.LookupModel(s.config, model.Name)
+		options = append(options, ui.ModelOption{
+			Name:          model.Name,
+			ParameterSize: model.ParameterSize,
+			Quantization:  model.Quantization,
+			ContextWindow: caps.ContextWindow,
+			Tools:         caps.SupportsTools,
+			Size:          model.Size,
+			Modified:      model.Modified,
+			Current:       model.Name == s.config.Model,
+		})
+	}
+	s.events <- ui.EventModelSelection(options)
+}
+
+// modelMissing checks if the error is because the server doesn't have the
+// model, if so the user is asked to pick another one
+func (s *Session) modelMissing(err error) bool {
+	var missing ai.ModelNotFoundError
+	if !errors.As(err, &missing) || missing.Model != s.config.Model {
+		return false
+	}
+
+	log.Println("[session] the model is missing:", err)
+	s.events <- ui.EventHealth{State: ui.HealthNoModel, Err: err}
+	s.pickModel()
+	return true
 }
 
 func (s *Session) handleCommand(ctx context.Context, cmd string) {
@@ -153,25 +468,11 @@ func (s *Session) handleCommand(ctx context.Context, cmd string) {
 
 	// if we get the models command, prepare a list of models to send to the user for selection
 	if strings.HasPrefix(cmd, "/models") {
-		models := s.client.ListModels()
-		for i, name := range models {
-			name = strings.Split(name, " ")[0]
-			models[i] = name
-
-			if name == s.config.Model {
-				models[i] = "*" + name
-			}
-		}
-		s.events <- ui.EventModelSelection(models)
+		s.pickModel()
 		return
 	}
 
-	res, err := commands.DefaultRegistry.Execute(ctx, cmd, &commands.Environment{
-		Session:    s,
-		Files:      s.files,
-		Config:     s.config,
-		WorkingDir: s.workingDir,
-	})
+	res, err := commands.DefaultRegistry.Execute(ctx, cmd, s.commandEnv())
 
 	if err != nil {
 		log.Println("[session] failed to execute command:", err)
@@ -181,10 +482,20 @@ func (s *Session) handleCommand(ctx context.Context, cmd string) {
 	s.events <- ui.EventSlashCommand(*res)
 }
 
-func (s Session) Context() (system any, input []any, output []any) {
+// commandEnv is what the slash commands can see of the session
+func (s *Session) commandEnv() *commands.Environment {
+	return &commands.Environment{
+		Session:    s,
+		Files:      s.files,
+		Config:     s.config,
+		WorkingDir: s.workingDir,
+	}
+}
+
+func (s *Session) Context() (system any, input []any, output []any) {
 	system = map[string]any{
 		"role":    "system",
-		"content": s.config.SystemPrompt,
+		"content": s.SystemPrompt(),
 	}
 
 	for _, msg := range s.messages {
@@ -205,12 +516,41 @@ func (s *Session) handleUIEvent(ctx context.Context, ev any) {
 		if string(msg)[0] == '/' {
 			s.handleCommand(ctx, string(msg))
 		} else {
-			s.SendMessage(ctx, string(msg))
+			// send in the background so we can still receive a cancel
+			go s.SendMessage(ctx, string(msg))
 		}
 
+	case ui.EventComplete:
+		// listing the models can take a while
+		env := s.commandEnv()
+		go func() {
+			options := commands.DefaultRegistry.Complete(ctx, string(msg), env)
+			s.events <- ui.EventCompletions{Line: string(msg), Options: options}
+		}()
+
+	case ui.EventSuggestFiles:
+		go func() {
+			s.events <- ui.EventFileSuggestions{Prompt: string(msg), Files: s.suggestFiles(string(msg))}
+		}()
+
+	case ui.EventSuggestionsAnswered:
+		s.addSuggestedFiles(msg)
+
+	case ui.EventCompleteSymbol:
+		go func() {
+			options := s.completeSymbol(string(msg))
+			s.events <- ui.EventCompletions{Line: string(msg), Options: options}
+		}()
+
 	case ui.EventCancelStream:
+		if s.stopReconnecting() {
+			return
+		}
+		if s.currStrm == nil {
+			return
+		}
 		log.Println("[session] Canceling stream...")
-		s.currStrm.Close()
+		s.currStrm.Cancel()
 		s.currStrm.Wait()
 		log.Println("[session] stream cancelled")
 		s.events <- ui.EventStreamCancelled{}
@@ -231,24 +571,79 @@ func (s *Session) handleUIEvent(ctx context.Context, ev any) {
 		s.permitToolCall <- false // tell the stream loop to continue
 		log.Printf("[session] told stream loop to continue")
 
+	case ui.EventRedact:
+		s.Redact(msg)
+
+	case ui.EventToolState:
+		if err := s.SetToolState(msg.Name, msg.State); err != nil {
+			s.events <- ui.EventSystemMsg(err.Error())
+		}
+
+	case ui.EventSwitchBranch:
+		if err := s.SwitchBranch(string(msg)); err != nil {
+			s.events <- ui.EventSystemMsg("Failed to switch branches: " + err.Error())
+		}
+
+	case ui.EventSelectVariant:
+		if err := s.SelectVariant(int(msg)); err != nil {
+			log.Println("[session] failed to select variant:", err)
+		}
+
 	case ui.EventModelSelected:
-		model := string(msg)
-		if !strings.Contains(model, "*") {
-			s.config.Model = model
+		if model := string(msg); model != s.config.Model {
+			s.SetModel(model)
 			s.events <- ui.EventSystemMsg("Model changed to " + model)
+			go s.CheckHealth(ctx)
+		}
+
+	case ui.EventCycleModel:
+		model, err := s.CycleModel()
+		if err != nil {
+			s.events <- ui.EventSystemMsg(err.Error())
+			return
 		}
+		log.Println("[session] switched to model", model)
+		s.events <- ui.EventModelChanged(model)
 
 	default:
 		log.Printf("[session] Unknown UI event: %T %+v", ev, ev)
 	}
 }
 
-func (s *Session) executeTool(tool *ai.ToolCall) string {
-	result := tools.ExecuteTool(s.config, tools.ToolUse(*tool), s.workingDir)
-	return result.Content
+func (s *Session) executeTool(tool *ai.ToolCall) tools.ToolResult {
+	live := newLiveOutput(s.events)
+	result := s.registry.Execute(tools.ToolUse(*tool), s.workingDir, live)
+	live.Close()
+	return result
+}
+
+func (s *Session) addChanges(paths []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range paths {
+		if !slices.Contains(s.changed, p) {
+			s.changed = append(s.changed, p)
+		}
+	}
+}
+
+// FilesChanged returns the files the tools have written in this session,
+// relative to the dir it started in where they can be
+func (s *Session) FilesChanged() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files := make([]string, len(s.changed))
+	for i, p := range s.changed {
+		if rel, err := filepath.Rel(s.rootDir, p); err == nil && !strings.HasPrefix(rel, "..") {
+			p = rel
+		}
+		files[i] = p
+	}
+	return files
 }
 
-func (s *Session) respondWithToolOutput(ctx context.Context, toolUseID string, output string) {
+func (s *Session) addToolOutput(toolUseID string, output string) {
 	log.Printf("[session] responding to tool call: %s with output %s", toolUseID, output)
 
 	s.AddMessage(ai.Message{
@@ -256,24 +651,185 @@ func (s *Session) respondWithToolOutput(ctx context.Context, toolUseID string, o
 		Content:    output,
 		ToolCallID: toolUseID,
 	})
-
-	s.sendFullContext(ctx)
 }
 
 func (s *Session) Observe(events chan any) {
 	s.uievents = events
 }
 
+// ChangeDir changes the working directory used by tools and file mentions,
+// the new directory must be inside the directory the session started in
+func (s *Session) ChangeDir(path string) (string, error) {
+	if path == "" || path == "~" {
+		path = s.rootDir
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.workingDir, path)
+	}
+
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("no such directory")
+	}
+
+	rel, err := filepath.Rel(s.rootDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("can't leave %s", s.rootDir)
+	}
+
+	if rel != "." && (files.IsExcluded(*s.config, s.rootDir, path) || !files.LeadsToScope(*s.config, s.rootDir, path)) {
+		return "", fmt.Errorf("directory matches exclude pattern")
+	}
+
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("not a directory")
+	}
+
+	s.workingDir = path
+	s.events <- ui.EventWorkingDir(rel)
+	return rel, nil
+}
+
+// Retry discards the last response from the LLM, including any partial
+// response from a cancelled stream, and asks for a new one
+func (s *Session) Retry(ctx context.Context) error {
+	s.mu.Lock()
+	n := s.lastPrompt() + 1
+	dropped := len(s.messages) - n
+	if n > 0 {
+		s.storeVariant()
+		s.variants = append(s.variants, nil)
+		s.variant = len(s.variants) - 1
+		s.messages = s.messages[:n]
+		s.autoContinued = 0
+		s.saveHistory()
+	}
+	s.mu.Unlock()
+
+	if n == 0 {
+		return fmt.Errorf("there is no message to retry")
+	}
+
+	log.Printf("[session] discarded %d messages for retry, %d variants", dropped, len(s.variants)-1)
+	s.events <- ui.EventRetry{}
+	go s.sendFullContext(ctx)
+	return nil
+}
+
+// continuePrompt is sent after a response that was cut off to get the rest
+const continuePrompt = "Your last response was cut off. Continue exactly where it stopped, without repeating anything or adding any introduction."
+
+// Continue asks the LLM to carry on from where the last response was cut
+// off, the rest is added to the same message
+func (s *Session) Continue(ctx context.Context) error {
+	s.mu.Lock()
+	s.autoContinued = 0
+	s.mu.Unlock()
+	return s.continueResponse(ctx)
+}
+
+func (s *Session) continueResponse(ctx context.Context) error {
+	s.mu.Lock()
+	n := len(s.messages) - 1
+	ok := n >= 0 && s.messages[n].Role == "assistant" && !s.messages[n].IsToolCall()
+	s.continuing = ok
+	s.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("the last message is not a response")
+	}
+
+	log.Println("[session] continuing the last response")
+	s.events <- ui.EventContinue{}
+	go s.sendFullContext(ctx)
+	return nil
+}
+
+// SelectVariant replaces the response to the last prompt with another one
+// made by /retry, the others are left out of the context
+func (s *Session) SelectVariant(i int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i < 0 || i >= len(s.variants) {
+		return fmt.Errorf("there is no variant %d", i)
+	}
+
+	s.storeVariant()
+	s.messages = append(s.messages[:s.lastPrompt()+1], s.variants[i]...)
+	s.variant = i
+	s.saveHistory()
+	log.Printf("[session] selected variant %d of %d", i+1, len(s.variants))
+	return nil
+}
+
+// storeVariant saves the current response to the last prompt as a variant
+func (s *Session) storeVariant() {
+	response := append([]ai.Message{}, s.messages[s.lastPrompt()+1:]...)
+	if len(s.variants) == 0 {
+		s.variants = [][]ai.Message{response}
+		s.variant = 0
+		return
+	}
+	s.variants[s.variant] = response
+}
+
+// lastPrompt returns the index of the last message the user typed, or -1,
+// the replies to tool calls that are sent as the user are skipped
+func (s *Session) lastPrompt() int {
+	n := len(s.messages) - 1
+	for n >= 0 && !isPrompt(s.messages[n]) {
+		n--
+	}
+	return n
+}
+
 // SendMessage add a new user message to the conversation and then sends the
 // fulll context to the LLM
 func (s *Session) SendMessage(ctx context.Context, message string) error {
-	message = enhanceMessage(s.config, message)
+	return s.send(ctx, message, s.useCheapModel(message))
+}
+
+// Escalate sends the prompt to the main model even if the router would have
+// picked the cheap one, without a prompt the last one is retried with it
+func (s *Session) Escalate(ctx context.Context, prompt string) error {
+	if prompt == "" {
+		s.mu.Lock()
+		s.cheapTurn = false
+		s.mu.Unlock()
+		return s.Retry(ctx)
+	}
+
+	go s.send(ctx, prompt, false)
+	return nil
+}
+
+func (s *Session) send(ctx context.Context, message string, cheap bool) error {
+	if s.config.SuggestFiles == "auto" {
+		s.autoAddFiles(message)
+	}
+	message = enhanceMessage(s.config, s.workingDir, message)
+
+	// a new prompt accepts the current response
+	s.mu.Lock()
+	s.variants = nil
+	s.variant = 0
+	s.autoContinued = 0
+	s.cheapTurn = cheap
+	s.mu.Unlock()
+
+	if cheap {
+		log.Println("[session] routing the prompt to the cheap model", s.config.CheapModel)
+		s.events <- ui.EventSystemMsg(fmt.Sprintf("Answering with %s, use /model! to ask %s instead", s.config.CheapModel, s.config.Model))
+	}
 
 	// Add user message to conversation
 	s.AddMessage(ai.Message{
 		Role:    "user",
 		Content: message,
 	})
+	s.events <- ui.EventMessageID{Role: "user", ID: s.messages[len(s.messages)-1].ID}
 
 	return s.sendFullContext(ctx)
 }
@@ -287,50 +843,195 @@ func (s *Session) handleStreamChunk(chunk ai.MessageChunk) {
 	}
 }
 
+// contextMessages returns the messages to send to the LLM, trimmed to fit in
+// the context window of the current model
+func (s *Session) contextMessages() []ai.Message {
+	return s.fitContext(s.model(), s.messages)
+}
+
+// fitContext adds the files in the context to the messages and trims them to
+// fit in the context window of the model
+func (s *Session) fitContext(model string, messages []ai.Message) []ai.Message {
+	caps := ai.LookupModel(s.config, model)
+	budget := caps.ContextWindow - ai.EstimateTokens(s.SystemPrompt())
+	messages = s.withFiles(messages, (budget-s.config.MaxTokens)/2)
+	msgs := trimMessages(messages, budget)
+	if len(msgs) < len(messages) {
+		log.Printf("[session] trimmed %d messages to fit context window of %d tokens", len(messages)-len(msgs), caps.ContextWindow)
+	}
+	return msgs
+}
+
 // sendFullContext sends a full conversation context to the LLM, using streaming
 func (s *Session) sendFullContext(ctx context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if s.overBudget(s.model()) {
+		s.holdForBudget()
+		return errOverBudget
+	}
+
+	continuing := s.continuing
+	s.continuing = false
+
+	msgs := s.contextMessages()
+	if continuing {
+		msgs = append(msgs, ai.Message{Role: "user", Content: continuePrompt})
+	}
+
+	// tools may have been enabled or disabled since the last request
+	s.client.SetTools(s.registry.Tools())
+
 	strm := NewStream(s.client)
 	s.currStrm = strm
 	strm.OnChunk(s.handleStreamChunk)
+	strm.SetThinkTags(s.config.ThinkTags...)
+	strm.SetIdleTimeout(s.config.StallTimeout)
+	strm.SetBannedOutput(s.config.BannedOutput...)
 
 	strm.OnStart(func() {
 		log.Println("[session] stream started")
 		s.events <- ui.EventStreamStarted("")
 	})
 
+	strm.OnError(func(err error) {
+		s.events <- ui.EventStreamErr(err)
+	})
+
 	strm.OnEnd(func(msg string) {
 		log.Println("[session] stream ended")
+		msg, found := s.saveArtifacts(s.postProcess(msg))
 		s.events <- ui.EventStreamEnded(msg)
+		s.addArtifacts(found)
 	})
 
 	log.Println("[session] starting stream")
-	strm.Start(ctx, s.messages)
+	s.setSent(s.model(), msgs)
+	// each attempt gets a request context of its own
+	start := func() error {
+		return strm.Start(s.constrain(s.notifyProvider(s.requestContext(ctx, s.model()))), msgs)
+	}
+	err := start()
+
+	// the prompt is held until the provider can be reached again
+	for attempt := 0; ai.IsUnreachable(err) && !strm.Cancelled(); attempt++ {
+		delay := reconnectDelays[min(attempt, len(reconnectDelays)-1)]
+		log.Printf("[session] can't reach the provider, trying again in %s: %v", delay, err)
+		s.events <- ui.EventReconnecting{Attempt: attempt + 1, Delay: delay, Err: err}
+
+		if !s.waitToReconnect(ctx, delay) {
+			log.Println("[session] stopped trying to reach the provider")
+			s.events <- ui.EventStreamCancelled{}
+			s.events <- ui.EventTurnDone{}
+			return err
+		}
+		err = start()
+	}
+
+	if err != nil {
+		log.Println("[session] failed to start stream:", err)
+		s.events <- ui.EventStreamErr(err)
+		s.events <- ui.EventTurnDone{}
+		s.modelMissing(err)
+		return err
+	}
 
 	strm.Wait()
 	log.Println("[session] stream is done")
+	s.addUsage(strm.Usage())
+	s.reportBudget()
+
+	// the files were saved when the stream ended
+	content, _ := s.saveArtifacts(s.postProcess(strm.Content()))
 
-	if strm.Content() != "" {
+	switch {
+	case content != "" && continuing:
+		log.Println("[session] stream ended with content, adding it to the last response")
+		last := &s.messages[len(s.messages)-1]
+		last.Content += content
+		last.Tokens = ai.EstimateTokens(last.Content)
+		last.Interrupted = strm.Cancelled() || strm.Stalled() || strm.Banned() != ""
+		last.Truncated = strm.Truncated()
+		s.saveHistory()
+		s.events <- ui.EventMessageID{Role: "assistant", ID: last.ID}
+
+	case content != "":
 		log.Println("[session] stream ended with content, updating conversation")
 
-		// Add assistant message
+		// Add assistant message, a partial message from a cancelled stream is
+		// kept in the context until it is discarded with /retry
 		s.AddMessage(ai.Message{
-			Role:    "assistant",
-			Content: strm.Content(),
+			Role:        "assistant",
+			Content:     content,
+			Interrupted: strm.Cancelled() || strm.Stalled() || strm.Banned() != "",
+			Truncated:   strm.Truncated(),
 		})
+		s.events <- ui.EventMessageID{Role: "assistant", ID: s.messages[len(s.messages)-1].ID}
+	}
+
+	if strm.Stalled() {
+		s.events <- ui.EventStreamStalled(s.config.StallTimeout)
+	}
+
+	if strm.Banned() != "" {
+		s.events <- ui.EventStreamBanned(strm.Banned())
 	}
 
-	if tc := strm.ToolCall(); tc != nil {
+	if strm.Truncated() && len(strm.ToolCalls()) == 0 && !strm.Cancelled() {
+		tokens := ai.EstimateTokens(strm.Reasoning() + strm.Content())
+		log.Printf("[session] response was truncated after ~%d tokens", tokens)
+
+		if s.autoContinued < s.config.AutoContinue {
+			s.autoContinued++
+			s.events <- ui.EventSystemMsg(fmt.Sprintf("The response was cut off at the length limit after ~%d tokens, continuing it (%d/%d)", tokens, s.autoContinued, s.config.AutoContinue))
+			go s.continueResponse(ctx)
+			return nil
+		}
+
+		s.events <- ui.EventSystemMsg(fmt.Sprintf("WARNING: the response was cut off at the length limit after ~%d tokens (max_tokens is %d), use /continue to get the rest or raise max_tokens", tokens, s.config.MaxTokens))
+	}
+
+	if calls := strm.ToolCalls(); len(calls) > 0 {
+		// the calls from the response go in one message, as the providers
+		// want them answered together
+		var requests []string
+		var uses []ai.ToolUse
+		for _, tc := range calls {
+			requests = append(requests, "Request to use tool: `"+tc.Name+"` with args: `"+string(tc.Input)+"`")
+			uses = append(uses, ai.ToolUse{ID: tc.ID, Name: tc.Name, Input: tc.Input})
+		}
 		s.AddMessage(ai.Message{
-			Role:       "assistant",
-			Content:    "Request to use tool: `" + tc.Name + "` with args: `" + string(tc.Input) + "`",
-			ToolCallID: tc.ID,
+			Role:      "assistant",
+			Content:   strings.Join(requests, "\n"),
+			ToolCalls: uses,
 		})
 
-		log.Println("[session] stream ended with tool call, passing it off")
-		s.toolCalls <- tc
+		// working with tools is for the main model
+		if s.cheapTurn {
+			log.Println("[session] the cheap model wants to use a tool, escalating to", s.config.Model)
+			s.cheapTurn = false
+		}
+
+		log.Println("[session] stream ended with tool calls, passing them off")
+		s.toolCalls <- calls
+		return nil
+	}
+
+	// nothing more to do until the user sends another message
+	s.events <- ui.EventTurnDone{}
+
+	// background requests are never worth going over the budget for
+	if s.overBudget(s.backgroundModel()) {
+		return nil
+	}
+
+	if s.config.FollowUps && !strm.Cancelled() && !strm.Stalled() && strm.Banned() == "" {
+		go s.suggestFollowUps(ctx, s.contextMessages())
+	}
+
+	if s.needsTitle() {
+		go s.generateTitle(ctx, s.contextMessages())
 	}
 
 	return nil
@@ -0,0 +1,410 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/commands"
+	"github.com/penguinpowernz/clai/internal/tools"
+	"github.com/penguinpowernz/clai/internal/ui"
+	"github.com/penguinpowernz/clai/internal/usage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectVariant(t *testing.T) {
+	s := &Session{config: &config.Config{}}
+	s.messages = []ai.Message{
+		{Role: "user", Content: "hi"},
+		{Role: "assistant", Content: "first"},
+	}
+
+	// what Retry does before asking for a new response
+	s.storeVariant()
+	s.variants = append(s.variants, nil)
+	s.variant = 1
+	s.messages = s.messages[:1]
+
+	s.messages = append(s.messages, ai.Message{Role: "assistant", Content: "second"})
+
+	assert.NoError(t, s.SelectVariant(0))
+	assert.Equal(t, []ai.Message{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "first"}}, s.messages)
+
+	assert.NoError(t, s.SelectVariant(1))
+	assert.Len(t, s.messages, 2)
+	assert.Equal(t, "second", s.messages[1].Content)
+
+	assert.Error(t, s.SelectVariant(2))
+}
+
+func TestRetryAfterToolTurn(t *testing.T) {
+	client := &fakeProvider{responses: [][]ai.MessageChunk{{ai.NewChunk(ai.ChunkMessage, "again")}}}
+	s := NewSession(&config.Config{Model: "x"}, client, "test")
+	s.messages = []ai.Message{
+		{Role: "user", Content: "read a.go"},
+		{Role: "assistant", Content: "Request to use tool: `read`", ToolCalls: []ai.ToolUse{{ID: "a", Name: "read"}}},
+		{Role: "user", Content: "Tool not found", ToolCallID: "a"},
+		{Role: "assistant", Content: "sorry"},
+	}
+
+	assert.NoError(t, s.Retry(context.Background()))
+	for ev := range s.events {
+		if _, ok := ev.(ui.EventTurnDone); ok {
+			break
+		}
+	}
+
+	// the whole tool turn is retried from the prompt, not the tool's reply
+	assert.Len(t, client.sent, 1)
+	assert.Equal(t, []ai.Message{{Role: "user", Content: "read a.go"}}, client.sent[0])
+	assert.Len(t, s.messages, 2)
+	assert.Equal(t, "again", s.messages[1].Content)
+}
+
+// fakeProvider streams the canned responses in order, each one is a list of
+// chunks
+type fakeProvider struct {
+	responses [][]ai.MessageChunk
+	sent      [][]ai.Message
+	models    []string // the model each stream was asked for
+}
+
+func (f *fakeProvider) StreamMessage(ctx context.Context, msgs []ai.Message) (<-chan ai.MessageChunk, error) {
+	f.sent = append(f.sent, msgs)
+	model, _ := ai.ModelFrom(ctx)
+	f.models = append(f.models, model)
+	ch := make(chan ai.MessageChunk, 10)
+	if len(f.responses) > 0 {
+		ch = make(chan ai.MessageChunk, len(f.responses[0]))
+		for _, c := range f.responses[0] {
+			ch <- c
+		}
+		f.responses = f.responses[1:]
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (f *fakeProvider) SendMessage(ctx context.Context, msgs []ai.Message) (*ai.Response, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+func (f *fakeProvider) GetModelInfo() ai.ModelInfo { return ai.ModelInfo{} }
+func (f *fakeProvider) ListModels() []string       { return nil }
+func (f *fakeProvider) ListModelDetails() ([]ai.ModelDetails, error) {
+	return nil, nil
+}
+func (f *fakeProvider) SetTools(tools []tools.Tool) {}
+
+func TestAutoContinue(t *testing.T) {
+	client := &fakeProvider{responses: [][]ai.MessageChunk{
+		{ai.NewChunk(ai.ChunkMessage, "part one, "), ai.NewChunk(ai.ChunkFinish, "length")},
+		{ai.NewChunk(ai.ChunkMessage, "part two"), ai.NewChunk(ai.ChunkFinish, "stop")},
+	}}
+
+	s := NewSession(&config.Config{Model: "x", MaxTokens: 10, AutoContinue: 1}, client, "test")
+	go s.SendMessage(context.Background(), "hi")
+
+	var continued bool
+	for ev := range s.events {
+		if _, ok := ev.(ui.EventContinue); ok {
+			continued = true
+		}
+		if _, ok := ev.(ui.EventTurnDone); ok {
+			break
+		}
+	}
+
+	assert.True(t, continued)
+	assert.Len(t, s.messages, 2)
+	assert.Equal(t, "part one, part two", s.messages[1].Content)
+	assert.False(t, s.messages[1].Truncated)
+
+	// the continuation is asked for after the partial response
+	assert.Len(t, client.sent, 2)
+	last := client.sent[1][len(client.sent[1])-1]
+	assert.Equal(t, continuePrompt, last.Content)
+}
+
+func TestContinueNeedsResponse(t *testing.T) {
+	s := NewSession(&config.Config{Model: "x"}, &fakeProvider{}, "test")
+	assert.Error(t, s.Continue(context.Background()))
+
+	s.messages = []ai.Message{{Role: "user", Content: "hi"}}
+	assert.Error(t, s.Continue(context.Background()))
+}
+
+func TestRouting(t *testing.T) {
+	client := &fakeProvider{responses: [][]ai.MessageChunk{
+		{ai.NewChunk(ai.ChunkMessage, "UTC+0")},
+		{ai.NewChunk(ai.ChunkMessage, "fixed")},
+	}}
+	s := NewSession(&config.Config{Model: "big", CheapModel: "small"}, client, "test")
+
+	for _, prompt := range []string{"what offset is UTC?", "fix the bug in main.go"} {
+		go s.SendMessage(context.Background(), prompt)
+		for ev := range s.events {
+			if _, ok := ev.(ui.EventTurnDone); ok {
+				break
+			}
+		}
+	}
+
+	assert.Equal(t, []string{"small", "big"}, client.models)
+	assert.Equal(t, "small", s.messages[1].Model)
+	assert.Equal(t, "big", s.messages[3].Model)
+}
+
+func TestBudgetHoldsPaidRequests(t *testing.T) {
+	client := &fakeProvider{responses: [][]ai.MessageChunk{{ai.NewChunk(ai.ChunkMessage, "hi")}}}
+	s := NewSession(&config.Config{Model: "gpt-4o"}, client, "test")
+
+	budget := usage.NewBudget(1, 0, 0)
+	budget.Add(1)
+	s.SetBudget(budget)
+
+	go s.SendMessage(context.Background(), "hello")
+
+	var held bool
+	for ev := range s.events {
+		if ev, ok := ev.(ui.EventBudget); ok {
+			held = ev.Held
+		}
+		if _, ok := ev.(ui.EventTurnDone); ok {
+			break
+		}
+	}
+	assert.True(t, held)
+	assert.Empty(t, client.sent)
+
+	assert.NoError(t, s.ApproveBudget(context.Background()))
+	for ev := range s.events {
+		if _, ok := ev.(ui.EventTurnDone); ok {
+			break
+		}
+	}
+	assert.Len(t, client.sent, 1)
+	assert.Equal(t, "hi", s.messages[1].Content)
+}
+
+func TestBudgetCapCantBeApproved(t *testing.T) {
+	client := &fakeProvider{}
+	s := NewSession(&config.Config{Model: "gpt-4o"}, client, "test")
+
+	budget := usage.NewBudget(0, 0, 0)
+	budget.Cap(1, 0)
+	budget.Add(1)
+	s.SetBudget(budget)
+
+	go s.SendMessage(context.Background(), "hello")
+
+	var msg string
+	for ev := range s.events {
+		if ev, ok := ev.(ui.EventSystemMsg); ok {
+			msg = string(ev)
+		}
+		if _, ok := ev.(ui.EventTurnDone); ok {
+			break
+		}
+	}
+	assert.Contains(t, msg, "set by the admin has been reached")
+	assert.Empty(t, client.sent)
+	assert.ErrorContains(t, s.ApproveBudget(context.Background()), "set by the admin")
+}
+
+func TestCycleModel(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "recent_models")
+	s := NewSession(&config.Config{Model: "local"}, &fakeProvider{}, "test")
+	s.SetRecentModels(fn)
+
+	_, err := s.CycleModel()
+	assert.Error(t, err)
+
+	s.SetModel("remote")
+	s.SetModel("other")
+	assert.Equal(t, []string{"other", "remote", "local"}, s.RecentModels())
+
+	for _, want := range []string{"remote", "local", "other"} {
+		model, err := s.CycleModel()
+		assert.NoError(t, err)
+		assert.Equal(t, want, model)
+	}
+
+	// remembered by the next session
+	s = NewSession(&config.Config{Model: "local"}, &fakeProvider{}, "test")
+	s.SetRecentModels(fn)
+	assert.Equal(t, []string{"local", "other", "remote"}, s.RecentModels())
+}
+
+func TestRedact(t *testing.T) {
+	s := NewSession(&config.Config{Model: "x"}, &fakeProvider{}, "test")
+	s.messages = []ai.Message{
+		{Role: "user", Content: "yes", ID: "u1"},
+		{Role: "assistant", Content: "yes, AKIA1234 is wrong", ID: "a1"},
+		{Role: "user", Content: "ok", ID: "u2"},
+		{Role: "assistant", Content: "Request to use tool: `aws` with args: `{\"key\":\"AKIA1234\"}`", ID: "a2", ToolCalls: []ai.ToolUse{
+			{ID: "c1", Name: "aws", Input: json.RawMessage(`{"key":"AKIA1234"}`)},
+		}},
+		{Role: "tool", Content: "AKIA1234 works", ToolCallID: "c1", ID: "t1"},
+	}
+	s.variants = [][]ai.Message{{{Role: "assistant", Content: "yes", ID: "v1"}}, nil}
+
+	// only the message is masked, not the same text in others
+	s.Redact([]string{"u1"})
+	assert.Equal(t, "[redacted]", s.messages[0].Content)
+	assert.Equal(t, "yes, AKIA1234 is wrong", s.messages[1].Content)
+	assert.Equal(t, "yes", s.variants[0][0].Content)
+
+	// a tool call masks its input and output, messages without an ID are left
+	s.Redact([]string{"c1", ""})
+	assert.Equal(t, "ok", s.messages[2].Content)
+	assert.Equal(t, "Request to use tool: `aws` with args: `[redacted]`", s.messages[3].Content)
+	assert.Equal(t, redactedInput, s.messages[3].ToolCalls[0].Input)
+	assert.Equal(t, "[redacted]", s.messages[4].Content)
+
+	s.Redact([]string{"v1"})
+	assert.Equal(t, "[redacted]", s.variants[0][0].Content)
+}
+
+func TestContextDiff(t *testing.T) {
+	cfg := &config.Config{Model: "x", Models: map[string]config.ModelSpec{}}
+	s := NewSession(cfg, &fakeProvider{}, "test")
+	assert.Contains(t, s.ContextDiff(), "Nothing has been sent yet")
+
+	s.messages = []ai.Message{
+		{Role: "user", Content: "the config is " + strings.Repeat("x", 400)},
+		{Role: "assistant", Content: "noted"},
+	}
+	s.setSent("x", s.messages)
+
+	// the big message no longer fits once the next prompt is added, and its
+	// response goes with it
+	cfg.Models["x"] = config.ModelSpec{ContextWindow: ai.EstimateTokens(s.SystemPrompt()) + 50}
+	s.messages = append(s.messages, ai.Message{Role: "user", Content: "what was in the config?"})
+
+	d := s.ContextDiff()
+	assert.Contains(t, d, "the same system prompt")
+	assert.Contains(t, d, "  - user (~104 tokens): the config is xxx")
+	assert.Contains(t, d, "trimmed to fit the context window")
+	assert.Contains(t, d, "  + user (~6 tokens): what was in the config?")
+	assert.Contains(t, d, "  - assistant (~2 tokens): noted, trimmed to fit the context window")
+	assert.Contains(t, d, "Messages kept: 0, added: 1, removed: 2")
+}
+
+func TestStyleLayer(t *testing.T) {
+	s := NewSession(&config.Config{Model: "x"}, &fakeProvider{}, "test")
+	assert.NotContains(t, s.SystemPrompt(), "How to write your responses")
+
+	s.config.Style = config.Style{Language: "Japanese", NoEmoji: true}
+	layers := s.PromptLayers()
+	assert.Equal(t, "style", layers[len(layers)-1].Name)
+	assert.Equal(t, "How to write your responses:\n- Always answer in Japanese, whatever language the question is asked in. Code, identifiers and commands stay as they are.\n- Don't use emoji.", layers[len(layers)-1].Text)
+}
+
+func TestToolStates(t *testing.T) {
+	cfg := &config.Config{Model: "x", PermittedTools: []string{"grep"}}
+	s := NewSession(cfg, &fakeProvider{}, "test")
+
+	state := func(name string) string {
+		for _, o := range s.ToolOptions() {
+			if o.Name == name {
+				return o.State
+			}
+		}
+		return ""
+	}
+	assert.Equal(t, commands.ToolAlways, state("grep"))
+	assert.Equal(t, commands.ToolAsk, state("write_file"))
+
+	assert.NoError(t, s.SetToolState("write_file", commands.ToolSession))
+	assert.Equal(t, commands.ToolSession, state("write_file"))
+
+	assert.NoError(t, s.SetToolState("grep", commands.ToolDisabled))
+	assert.Equal(t, commands.ToolDisabled, state("grep"))
+	assert.NotContains(t, cfg.PermittedTools, "grep")
+	assert.NotContains(t, tools.GetNames(s.registry.Tools()), "grep")
+
+	assert.Error(t, s.SetToolState("nope", commands.ToolAsk))
+}
+
+// barrierRegistry only lets a call finish once n calls are running together
+type barrierRegistry struct {
+	n       sync.WaitGroup
+	mu      sync.Mutex
+	stalled bool
+}
+
+func (r *barrierRegistry) Tools() []tools.Tool {
+	return []tools.Tool{{Type: "function", Function: &tools.FunctionSchema{Name: "read_file"}}}
+}
+
+func (r *barrierRegistry) Execute(call tools.ToolUse, workingDir string, live io.Writer) tools.ToolResult {
+	r.n.Done()
+	done := make(chan struct{})
+	go func() { r.n.Wait(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		r.mu.Lock()
+		r.stalled = true
+		r.mu.Unlock()
+	}
+	return tools.ToolResult{ToolUseID: call.ID, Content: "read " + string(call.Input)}
+}
+
+func TestParallelTools(t *testing.T) {
+	client := &fakeProvider{responses: [][]ai.MessageChunk{
+		{
+			ai.NewToolCallChunk(&ai.ToolCall{ID: "a", Name: "read_file", Input: []byte(`"a.go"`)}),
+			ai.NewToolCallChunk(&ai.ToolCall{ID: "b", Name: "read_file", Input: []byte(`"b.go"`)}),
+		},
+		{ai.NewChunk(ai.ChunkMessage, "both read")},
+	}}
+
+	cfg := &config.Config{Model: "x", PermittedTools: []string{"read_file"}, ParallelTools: true}
+	s := NewSession(cfg, client, "test")
+	registry := &barrierRegistry{}
+	registry.n.Add(2)
+	s.SetToolRegistry(registry)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.InteractiveMode(ctx)
+	go s.SendMessage(ctx, "read a.go and b.go")
+
+	for ev := range s.events {
+		if _, ok := ev.(ui.EventTurnDone); ok {
+			break
+		}
+	}
+
+	assert.False(t, registry.stalled, "the calls were not run at the same time")
+
+	// the calls are one assistant message and the results follow it in the
+	// order of the calls
+	assert.Len(t, client.sent, 2)
+	var order []string
+	for _, msg := range client.sent[1] {
+		switch {
+		case msg.IsToolCall():
+			var ids []string
+			for _, tc := range msg.Calls() {
+				ids = append(ids, tc.ID)
+			}
+			order = append(order, msg.Role+" calls "+strings.Join(ids, ","))
+		case msg.ToolCallID != "":
+			order = append(order, msg.Role+" "+msg.ToolCallID+": "+msg.Content)
+		default:
+			order = append(order, msg.Role)
+		}
+	}
+	assert.Equal(t, []string{"user", "assistant calls a,b", `tool a: read "a.go"`, `tool b: read "b.go"`}, order)
+	assert.Equal(t, "both read", s.messages[len(s.messages)-1].Content)
+}
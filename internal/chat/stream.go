@@ -2,8 +2,11 @@ package chat
 
 import (
 	"context"
+	"errors"
 	"log"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/penguinpowernz/clai/internal/ai"
 )
@@ -20,10 +23,24 @@ type Stream struct {
 	onEnd   func(string)
 	onErr   func(error)
 
+	// separates reasoning wrapped in tags from the content
+	scanner *tagScanner
+
+	// the stream is stopped if the provider sends nothing for this long
+	idleTimeout time.Duration
+
+	// the stream is stopped if the content matches one of these
+	bannedOutput []*regexp.Regexp
+
 	// artifacts
-	content   strings.Builder
-	reasoning strings.Builder
-	toolCall  *ai.ToolCall
+	content      strings.Builder
+	reasoning    strings.Builder
+	toolCalls    []*ai.ToolCall
+	cancelled    bool
+	stalled      bool
+	banned       string // the pattern that stopped the stream
+	finishReason string
+	usage        *ai.Usage
 }
 
 func NewStream(client ai.Provider) *Stream {
@@ -33,6 +50,7 @@ func NewStream(client ai.Provider) *Stream {
 		onStart:   func() {},
 		onEnd:     func(string) {},
 		onErr:     func(error) {},
+		scanner:   newTagScanner(),
 		content:   strings.Builder{},
 		reasoning: strings.Builder{},
 	}
@@ -54,7 +72,45 @@ func (s *Stream) OnChunk(f func(ai.MessageChunk)) {
 	s.onChunk = f
 }
 
+// SetIdleTimeout stops the stream if the provider goes quiet for longer than
+// d in the middle of it, 0 waits forever
+func (s *Stream) SetIdleTimeout(d time.Duration) {
+	s.idleTimeout = d
+}
+
+// SetBannedOutput stops the stream when the content matches one of the
+// patterns, the content is cut off where the match starts
+func (s *Stream) SetBannedOutput(patterns ...string) {
+	s.bannedOutput = nil
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			log.Printf("[stream] ignoring banned_output pattern %q: %s", p, err)
+			continue
+		}
+		s.bannedOutput = append(s.bannedOutput, re)
+	}
+}
+
+// SetThinkTags sets the names of the tags that models wrap their reasoning in,
+// with none the reasoning is left in the content
+func (s *Stream) SetThinkTags(tags ...string) {
+	s.scanner = newTagScanner(tags...)
+}
+
+// Start streams the response to the messages, calling the callbacks as the
+// chunks arrive.  It returns when the response is done or the stream is closed.
+//
+// The provider never waits on the callbacks: its chunks are moved into a
+// queue as soon as they arrive, and when the callbacks (and so the UI) are
+// slower than the provider, adjacent text chunks are merged in the queue
+// while they wait.  Nothing is dropped, and closing the stream cancels the
+// context given to the provider so its read loop exits rather than blocking.
 func (s *Stream) Start(ctx context.Context, cctx []ai.Message) (err error) {
+	// this ctx will let us cancel, or be cancelled, it also stops the provider
+	ctx, s.cancel = context.WithCancel(ctx)
+	defer s.cancel()
+
 	s.stream, err = s.client.StreamMessage(ctx, cctx)
 	if err != nil {
 		return err
@@ -65,9 +121,36 @@ func (s *Stream) Start(ctx context.Context, cctx []ai.Message) (err error) {
 	s.waiter, done = context.WithCancel(context.Background())
 	defer done()
 
-	// this ctx will let us cancel, or be cancelled
-	ctx, s.cancel = context.WithCancel(ctx)
-	defer s.cancel()
+	q := newChunkQueue()
+	go q.fill(ctx, s.stream)
+
+	// the watchdog checks when the provider last sent something rather than
+	// being reset on every chunk, as the loop can be held up by a slow UI
+	if s.idleTimeout > 0 {
+		timer := time.NewTimer(s.idleTimeout)
+		defer timer.Stop()
+
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-timer.C:
+				}
+
+				idle := q.idle()
+				if idle < s.idleTimeout {
+					timer.Reset(s.idleTimeout - idle)
+					continue
+				}
+
+				log.Printf("[stream] nothing received for %s, stopping", idle.Round(time.Second))
+				s.stalled = true
+				s.Close()
+				return
+			}
+		}()
+	}
 
 	s.onStart()
 	log.Println("[stream] starting loop")
@@ -76,30 +159,25 @@ func (s *Stream) Start(ctx context.Context, cctx []ai.Message) (err error) {
 		case <-ctx.Done():
 			log.Println("[stream] context done")
 			break // this will break out of the loop thanks to the ctx.Err() check
-		case chunk, ok := <-s.stream:
-			if !ok {
+		case <-q.ready:
+			chunks, closed := q.take()
+			for _, chunk := range chunks {
+				if ctx.Err() != nil {
+					break
+				}
+				s.handleChunk(chunk)
+			}
+
+			if closed {
 				log.Println("[stream] stream channel closed")
 				s.Close()
-				break
-			}
-			switch chunk.Type() {
-			case ai.ChunkToolCall:
-				s.toolCall = chunk.ToolCall
-				log.Println("[stream] tool called, closing")
-				s.Close() // we bail after a single tool call
-			case ai.ChunkMessage:
-				// log.Println("[stream] message received")
-				s.content.WriteString(chunk.Content)
-			case ai.ChunkThink:
-				// log.Println("[stream] thinking")
-				s.reasoning.WriteString(chunk.Content)
 			}
-
-			s.onChunk(chunk)
 		}
 	}
 	log.Println("[stream] loop is done")
 
+	s.handleSegments(s.scanner.Flush())
+
 	s.onEnd(s.content.String())
 
 	done()
@@ -107,13 +185,110 @@ func (s *Stream) Start(ctx context.Context, cctx []ai.Message) (err error) {
 	return nil
 }
 
+func (s *Stream) handleChunk(chunk ai.MessageChunk) {
+	switch chunk.Type() {
+	case ai.ChunkToolCall:
+		// the stream carries on so that parallel tool calls are all collected
+		s.toolCalls = append(s.toolCalls, chunk.ToolCall)
+		log.Println("[stream] tool called:", chunk.ToolCall.Name)
+	case ai.ChunkMessage:
+		s.handleSegments(s.scanner.Feed(chunk.Content))
+		return
+	case ai.ChunkThink:
+		s.reasoning.WriteString(chunk.Content)
+	case ai.ChunkFinish:
+		log.Println("[stream] finished because:", chunk.Content)
+		s.finishReason = chunk.Content
+		return
+	case ai.ChunkUsage:
+		s.usage = chunk.Usage
+		return
+	case ai.ChunkError:
+		log.Println("[stream] provider error:", chunk.Content)
+		s.onErr(errors.New(chunk.Content))
+		return
+	}
+
+	s.onChunk(chunk)
+}
+
+// handleSegments splits the scanned segments back into message and think chunks
+func (s *Stream) handleSegments(segs []segment) {
+	for _, seg := range segs {
+		if seg.think {
+			s.reasoning.WriteString(seg.text)
+			s.onChunk(ai.NewChunk(ai.ChunkThink, seg.text))
+			continue
+		}
+
+		if s.banned != "" {
+			continue
+		}
+
+		s.content.WriteString(seg.text)
+		if s.checkBanned() {
+			continue
+		}
+		s.onChunk(ai.NewChunk(ai.ChunkMessage, seg.text))
+	}
+}
+
+// checkBanned stops the stream if the content matches a banned pattern,
+// keeping the content up to where the match starts
+func (s *Stream) checkBanned() bool {
+	content := s.content.String()
+	for _, re := range s.bannedOutput {
+		loc := re.FindStringIndex(content)
+		if loc == nil {
+			continue
+		}
+
+		log.Printf("[stream] the content matched the banned pattern %q, stopping", re.String())
+		s.banned = re.String()
+		s.content.Reset()
+		s.content.WriteString(content[:loc[0]])
+		s.Close()
+		return true
+	}
+	return false
+}
+
 func (s *Stream) Close() {
 	log.Println("[stream] closing")
-	s.cancel()
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+// Cancel stops the stream at the request of the user, any content received so
+// far is kept
+func (s *Stream) Cancel() {
+	s.cancelled = true
+	s.Close()
+}
+
+// Stalled returns true if the stream was stopped because the provider went
+// quiet for longer than the idle timeout
+func (s *Stream) Stalled() bool {
+	return s.stalled
+}
+
+// Banned returns the banned pattern that the content matched, if it was
+// stopped because of one
+func (s *Stream) Banned() string {
+	return s.banned
+}
+
+// Cancelled returns true if the user stopped the stream before it finished
+func (s *Stream) Cancelled() bool {
+	return s.cancelled
 }
 
 func (s *Stream) Wait() {
 	log.Println("[stream] waiting")
+	if s.waiter == nil {
+		return // never started
+	}
 	<-s.waiter.Done()
 }
 
@@ -121,11 +296,29 @@ func (s *Stream) Reasoning() string {
 	return s.reasoning.String()
 }
 
-// ToolCall returns the tool call that happened in this stream, it may be nil
-func (s *Stream) ToolCall() *ai.ToolCall {
-	return s.toolCall
+// ToolCalls returns the tool calls that happened in this stream, in the order
+// they were made
+func (s *Stream) ToolCalls() []*ai.ToolCall {
+	return s.toolCalls
 }
 
 func (s *Stream) Content() string {
 	return s.content.String()
 }
+
+// FinishReason returns why the model stopped generating, e.g. "stop" or
+// "length", it is empty if the provider didn't say
+func (s *Stream) FinishReason() string {
+	return s.finishReason
+}
+
+// Usage returns the tokens the request used, nil if the provider's stream
+// didn't say
+func (s *Stream) Usage() *ai.Usage {
+	return s.usage
+}
+
+// Truncated returns true if the model stopped because it hit the token limit
+func (s *Stream) Truncated() bool {
+	return s.finishReason == "length"
+}
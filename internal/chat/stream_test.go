@@ -0,0 +1,95 @@
+package chat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/stretchr/testify/assert"
+)
+
+// stallingProvider sends the start of a response and then goes quiet until
+// it is cancelled
+type stallingProvider struct {
+	fakeProvider
+}
+
+func (p *stallingProvider) StreamMessage(ctx context.Context, msgs []ai.Message) (<-chan ai.MessageChunk, error) {
+	ch := make(chan ai.MessageChunk, 1)
+	ch <- ai.NewChunk(ai.ChunkMessage, "The answer is")
+	go func() {
+		<-ctx.Done()
+		close(ch)
+	}()
+	return ch, nil
+}
+
+func TestStreamStalls(t *testing.T) {
+	s := NewStream(&stallingProvider{})
+	s.SetIdleTimeout(50 * time.Millisecond)
+
+	start := time.Now()
+	assert.NoError(t, s.Start(context.Background(), nil))
+	assert.Less(t, time.Since(start), time.Second)
+
+	assert.True(t, s.Stalled())
+	assert.False(t, s.Cancelled())
+	assert.Equal(t, "The answer is", s.Content())
+}
+
+func TestStreamWithoutIdleTimeout(t *testing.T) {
+	s := NewStream(&stallingProvider{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	assert.NoError(t, s.Start(ctx, nil))
+	assert.False(t, s.Stalled())
+}
+
+func toolCallChunk(name string) ai.MessageChunk {
+	c := ai.NewChunk(ai.ChunkToolCall, "")
+	c.ToolCall = &ai.ToolCall{ID: name, Name: name}
+	return c
+}
+
+func TestStreamCollectsToolCalls(t *testing.T) {
+	s := NewStream(&fakeProvider{responses: [][]ai.MessageChunk{{
+		toolCallChunk("read_file"),
+		toolCallChunk("grep"),
+		ai.NewChunk(ai.ChunkFinish, "tool_calls"),
+	}}})
+
+	assert.NoError(t, s.Start(context.Background(), nil))
+	assert.Len(t, s.ToolCalls(), 2)
+	assert.Equal(t, "grep", s.ToolCalls()[1].Name)
+}
+
+func TestStreamBannedOutput(t *testing.T) {
+	s := NewStream(&fakeProvider{responses: [][]ai.MessageChunk{{
+		ai.NewChunk(ai.ChunkMessage, "Let me check. <tool_"),
+		ai.NewChunk(ai.ChunkMessage, "call>{\"name\": \"read_file\"}"),
+		ai.NewChunk(ai.ChunkMessage, " and more rambling"),
+	}}})
+	s.SetBannedOutput(`<tool_call>`)
+
+	assert.NoError(t, s.Start(context.Background(), nil))
+	assert.Equal(t, "<tool_call>", s.Banned())
+	assert.Equal(t, "Let me check. ", s.Content())
+}
+
+func TestStreamUsage(t *testing.T) {
+	s := NewStream(&fakeProvider{responses: [][]ai.MessageChunk{{
+		ai.NewChunk(ai.ChunkMessage, "Hi"),
+		ai.NewChunk(ai.ChunkFinish, "stop"),
+		ai.NewUsageChunk(ai.Usage{PromptTokens: 120, CompletionTokens: 3}),
+	}}})
+
+	var chunks []ai.MessageChunk
+	s.OnChunk(func(c ai.MessageChunk) { chunks = append(chunks, c) })
+
+	assert.NoError(t, s.Start(context.Background(), nil))
+	assert.Equal(t, &ai.Usage{PromptTokens: 120, CompletionTokens: 3}, s.Usage())
+	assert.Equal(t, []ai.MessageChunk{ai.NewChunk(ai.ChunkMessage, "Hi")}, chunks, "the usage isn't shown")
+}
@@ -0,0 +1,203 @@
+package chat
+
+import (
+	"cmp"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/penguinpowernz/clai/internal/files"
+	"github.com/penguinpowernz/clai/internal/symbols"
+	"github.com/penguinpowernz/clai/internal/ui"
+)
+
+// maxSuggestions is how many files are suggested for a prompt
+const maxSuggestions = 3
+
+// maxSuggestWalk is how many files of the project are looked at, so a huge
+// tree doesn't hold up the prompt
+const maxSuggestWalk = 20000
+
+var reWord = regexp.MustCompile(`[A-Za-z0-9_][A-Za-z0-9_./-]*[A-Za-z0-9_]`)
+
+// suggestFiles returns the files of the project the prompt looks to be about,
+// the best match first.  The files are matched by their path, name or name
+// without the extension, and by the functions and types of the ctags index
+// that the prompt mentions.  Files in the context, mentioned with @ or
+// declined before aren't suggested.
+func (s *Session) suggestFiles(prompt string) []string {
+	if prompt == "" || prompt[0] == '/' {
+		return nil
+	}
+
+	skip := map[string]bool{}
+	for _, f := range s.files.GetFiles() {
+		if rel, err := filepath.Rel(s.workingDir, f.Path); err == nil {
+			skip[rel] = true
+		}
+	}
+	for _, m := range reTaggedFilename.FindAllString(prompt, -1) {
+		skip[filepath.Clean(strings.TrimPrefix(m, "@"))] = true
+	}
+	s.suggestMu.Lock()
+	for fn := range s.declined {
+		skip[fn] = true
+	}
+	s.suggestMu.Unlock()
+
+	words := map[string]bool{}
+	for _, w := range reWord.FindAllString(prompt, -1) {
+		words[strings.ToLower(w)] = true
+	}
+
+	score := map[string]int{}
+	for fn, n := range s.symbolMatches(prompt) {
+		score[fn] += n
+	}
+
+	count := 0
+	filepath.WalkDir(s.workingDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || count >= maxSuggestWalk {
+			return filepath.SkipDir
+		}
+		rel, _ := filepath.Rel(s.workingDir, path)
+		if rel == "." {
+			return nil
+		}
+		if files.IsHidden(*s.config, s.workingDir, rel) || files.IsExcluded(*s.config, s.workingDir, rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if !files.LeadsToScope(*s.config, s.workingDir, rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		count++
+		if n := matchPath(prompt, words, rel); n > 0 && files.InScope(*s.config, s.workingDir, rel) {
+			score[rel] += n
+		}
+		return nil
+	})
+
+	var found []string
+	for fn, n := range score {
+		// a directory alone isn't enough to go on
+		if n >= 2 && !skip[fn] {
+			found = append(found, fn)
+		}
+	}
+	slices.SortFunc(found, func(a, b string) int {
+		return cmp.Or(
+			cmp.Compare(score[b], score[a]),
+			cmp.Compare(len(a), len(b)),
+			strings.Compare(a, b),
+		)
+	})
+	return found[:min(len(found), maxSuggestions)]
+}
+
+// matchPath scores how clearly the prompt is about the file, by its path,
+// its name, its name without the extension and its directories
+func matchPath(prompt string, words map[string]bool, rel string) int {
+	name := filepath.Base(rel)
+	stem := strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))
+
+	n := 0
+	switch {
+	case strings.Contains(prompt, rel) && strings.Contains(rel, "/"):
+		n = 5
+	case words[strings.ToLower(name)]:
+		n = 3
+	case len(stem) >= 3 && words[stem]:
+		n = 2
+	}
+
+	for _, dir := range strings.Split(filepath.Dir(rel), string(filepath.Separator)) {
+		if len(dir) >= 3 && words[strings.ToLower(dir)] {
+			n++
+		}
+	}
+	return n
+}
+
+// symbolMatches scores the files by the names from the ctags index that the
+// prompt mentions, only names that look like code are used so that a plain
+// word doesn't match everything it names
+func (s *Session) symbolMatches(prompt string) map[string]int {
+	fn := symbols.FindTags(s.workingDir)
+	if fn == "" {
+		return nil
+	}
+	defs, err := symbols.TagFiles(fn)
+	if err != nil {
+		log.Println("[session] failed to read the tags:", err)
+		return nil
+	}
+
+	score := map[string]int{}
+	for _, name := range symbols.FromText(prompt) {
+		files := defs[name]
+		if !looksLikeCode(name) || len(files) == 0 || len(files) > maxSuggestions {
+			continue
+		}
+		for _, f := range files {
+			if rel, err := filepath.Rel(s.workingDir, filepath.Join(filepath.Dir(fn), f)); err == nil && !strings.HasPrefix(rel, "..") {
+				score[rel] += 2
+			}
+		}
+	}
+	return score
+}
+
+// looksLikeCode is true for names like parseArgs, ParseArgs and parse_args
+func looksLikeCode(name string) bool {
+	return strings.Contains(name, "_") || strings.ToLower(name[1:]) != name[1:]
+}
+
+// autoAddFiles adds the file that best matches the prompt to the context,
+// for when the suggest_files config is auto
+func (s *Session) autoAddFiles(prompt string) {
+	found := s.suggestFiles(prompt)
+	if len(found) == 0 {
+		return
+	}
+
+	if err := s.files.AddFile(filepath.Join(s.workingDir, found[0])); err != nil {
+		log.Printf("[session] failed to add the suggested file %s: %s", found[0], err)
+		s.declineFiles(found[:1])
+		return
+	}
+	log.Println("[session] added the suggested file", found[0])
+	s.events <- ui.EventSystemMsg(fmt.Sprintf("Added %s to the context as the prompt looks to be about it, use /remove to take it out", found[0]))
+}
+
+// addSuggestedFiles adds the suggested files the user chose to the context,
+// the ones they declined aren't suggested again
+func (s *Session) addSuggestedFiles(ev ui.EventSuggestionsAnswered) {
+	for _, fn := range ev.Added {
+		if err := s.files.AddFile(filepath.Join(s.workingDir, fn)); err != nil {
+			s.events <- ui.EventSystemMsg(fmt.Sprintf("Couldn't add %s: %s", fn, err))
+		}
+	}
+	s.declineFiles(ev.Declined)
+}
+
+func (s *Session) declineFiles(files []string) {
+	s.suggestMu.Lock()
+	defer s.suggestMu.Unlock()
+	if s.declined == nil {
+		s.declined = map[string]bool{}
+	}
+	for _, fn := range files {
+		s.declined[fn] = true
+	}
+}
@@ -0,0 +1,36 @@
+package chat
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/ui"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuggestFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, fn := range []string{"internal/chat/session.go", "internal/store/session.go", "internal/ui/chat.go", "vendor/chat/session.go", "README.md"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(fn)), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, fn), []byte("package x\n"), 0644))
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "tags"), []byte(
+		"handleSubmit\tinternal/ui/chat.go\t/^func handleSubmit() {$/;\"\tf\n"), 0644))
+
+	t.Chdir(dir)
+	cfg := config.Default()
+	s := NewSession(cfg, &fakeProvider{}, "test")
+
+	// the directory makes one session better than the other, vendor is excluded
+	assert.Equal(t, []string{"internal/chat/session.go", "internal/ui/chat.go", "internal/store/session.go"}, s.suggestFiles("why does the chat session hang?"))
+	assert.Equal(t, []string{"internal/ui/chat.go"}, s.suggestFiles("handleSubmit drops the prompt"))
+	assert.Empty(t, s.suggestFiles("what does @internal/ui/chat.go do in handleSubmit"))
+	assert.Empty(t, s.suggestFiles("/add session"))
+
+	// files in the context and declined ones aren't suggested again
+	assert.NoError(t, s.files.AddFile(filepath.Join(dir, "internal/chat/session.go")))
+	s.addSuggestedFiles(ui.EventSuggestionsAnswered{Declined: []string{"internal/store/session.go"}})
+	assert.Equal(t, []string{"internal/ui/chat.go"}, s.suggestFiles("why does the chat session hang?"))
+}
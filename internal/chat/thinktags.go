@@ -0,0 +1,107 @@
+package chat
+
+import "strings"
+
+// segment is a piece of streamed text that is either reasoning or normal content
+type segment struct {
+	think bool
+	text  string
+}
+
+// tagScanner separates reasoning from content in a stream of text chunks, tags
+// may be split across any number of chunks so text that could be the start of
+// a tag is held back until the next chunk arrives
+type tagScanner struct {
+	tags []string
+	open string // the tag we are currently inside of, empty if none
+	buf  string // text held back because it may be the start of a tag
+}
+
+// newTagScanner looks for the tags, with none all the text is content
+func newTagScanner(tags ...string) *tagScanner {
+	return &tagScanner{tags: tags}
+}
+
+// Feed adds the chunk to the scanner and returns the segments that can be
+// emitted so far
+func (ts *tagScanner) Feed(chunk string) []segment {
+	data := ts.buf + chunk
+	ts.buf = ""
+
+	var out []segment
+	for data != "" {
+		if ts.open != "" {
+			closing := "</" + ts.open + ">"
+			if i := strings.Index(data, closing); i >= 0 {
+				out = appendSegment(out, true, data[:i])
+				data = data[i+len(closing):]
+				ts.open = ""
+				continue
+			}
+
+			keep := partialSuffix(data, closing)
+			out = appendSegment(out, true, data[:len(data)-keep])
+			ts.buf = data[len(data)-keep:]
+			return out
+		}
+
+		idx, tag := ts.findOpening(data)
+		if idx >= 0 {
+			out = appendSegment(out, false, data[:idx])
+			data = data[idx+len(tag)+2:]
+			ts.open = tag
+			continue
+		}
+
+		keep := 0
+		for _, tag := range ts.tags {
+			keep = max(keep, partialSuffix(data, "<"+tag+">"))
+		}
+		out = appendSegment(out, false, data[:len(data)-keep])
+		ts.buf = data[len(data)-keep:]
+		return out
+	}
+
+	return out
+}
+
+// Flush returns any text that was held back, it should be called when the
+// stream ends
+func (ts *tagScanner) Flush() []segment {
+	if ts.buf == "" {
+		return nil
+	}
+	out := []segment{{think: ts.open != "", text: ts.buf}}
+	ts.buf = ""
+	return out
+}
+
+// findOpening returns the index and name of the first opening tag in the data
+func (ts *tagScanner) findOpening(data string) (int, string) {
+	idx, name := -1, ""
+	for _, tag := range ts.tags {
+		i := strings.Index(data, "<"+tag+">")
+		if i >= 0 && (idx < 0 || i < idx) {
+			idx, name = i, tag
+		}
+	}
+	return idx, name
+}
+
+// partialSuffix returns the length of the longest suffix of data that is a
+// proper prefix of tag
+func partialSuffix(data, tag string) int {
+	for n := min(len(tag)-1, len(data)); n > 0; n-- {
+		if strings.HasSuffix(data, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+func appendSegment(segs []segment, think bool, text string) []segment {
+	if text == "" {
+		return segs
+	}
+	return append(segs, segment{think: think, text: text})
+}
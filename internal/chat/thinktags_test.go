@@ -0,0 +1,55 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func scanAll(ts *tagScanner, chunks ...string) (think, content string) {
+	var segs []segment
+	for _, c := range chunks {
+		segs = append(segs, ts.Feed(c)...)
+	}
+	segs = append(segs, ts.Flush()...)
+
+	for _, seg := range segs {
+		if seg.think {
+			think += seg.text
+		} else {
+			content += seg.text
+		}
+	}
+	return
+}
+
+func TestTagScanner(t *testing.T) {
+	tags := config.Default().ThinkTags
+
+	think, content := scanAll(newTagScanner(tags...), "<think>", "hmm", "</think>", "Hello")
+	assert.Equal(t, "hmm", think)
+	assert.Equal(t, "Hello", content)
+
+	think, content = scanAll(newTagScanner(tags...), "<th", "ink>let me", " see</th", "ink>\n\nHi <b>there</b>")
+	assert.Equal(t, "let me see", think)
+	assert.Equal(t, "\n\nHi <b>there</b>", content)
+
+	think, content = scanAll(newTagScanner(tags...), "a <reas", "oning>x</reasoning> b <thought>y</thought>")
+	assert.Equal(t, "xy", think)
+	assert.Equal(t, "a  b ", content)
+
+	// a partial tag at the end of the stream is just content
+	think, content = scanAll(newTagScanner(tags...), "x <thi")
+	assert.Equal(t, "", think)
+	assert.Equal(t, "x <thi", content)
+
+	think, content = scanAll(newTagScanner("scratchpad"), "<think>no</think><scratchpad>yes</scratchpad>")
+	assert.Equal(t, "yes", think)
+	assert.Equal(t, "<think>no</think>", content)
+
+	// think_tags: [] turns it off
+	think, content = scanAll(newTagScanner(), "<think>hmm</think>Hello")
+	assert.Equal(t, "", think)
+	assert.Equal(t, "<think>hmm</think>Hello", content)
+}
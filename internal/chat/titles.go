@@ -0,0 +1,118 @@
+package chat
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/ui"
+)
+
+// titleAfter is how many responses there are before the session gets a title
+const titleAfter = 2
+
+// maxTitle is the longest title that is kept
+const maxTitle = 60
+
+const titlePrompt = `Give this conversation a short title of no more than 6 words on the first line,
+then summarise it in 2 short lines.  Reply with only the title and the summary, no
+labels, quotes or markdown.`
+
+// Title returns the title and summary of the session, they are empty until
+// there have been a few exchanges
+func (s *Session) Title() (title, summary string) {
+	s.titleMu.Lock()
+	defer s.titleMu.Unlock()
+	return s.title, s.summary
+}
+
+// needsTitle checks if it is time to ask for a title, it is only asked for
+// once per session
+func (s *Session) needsTitle() bool {
+	if !s.config.AutoTitle || s.titled {
+		return false
+	}
+
+	var responses int
+	for _, msg := range s.messages {
+		if msg.Role == "assistant" && !msg.IsToolCall() {
+			responses++
+		}
+	}
+	if responses < titleAfter {
+		return false
+	}
+
+	s.titled = true
+	return true
+}
+
+// generateTitle asks the background model for a title and summary of the
+// conversation, the UI is told about them and they are saved in the history
+func (s *Session) generateTitle(ctx context.Context, messages []ai.Message) {
+	messages = append(messages, ai.Message{Role: "user", Content: titlePrompt})
+
+	res, err := s.client.SendMessage(s.requestContext(ctx, s.backgroundModel()), messages)
+	if err != nil {
+		log.Println("[session] failed to generate a title:", err)
+		return
+	}
+
+	var content strings.Builder
+	ts := newTagScanner(s.config.ThinkTags...)
+	for _, seg := range append(ts.Feed(res.Content), ts.Flush()...) {
+		if !seg.think {
+			content.WriteString(seg.text)
+		}
+	}
+
+	title, summary := parseTitle(content.String())
+	if title == "" {
+		log.Println("[session] got no title from:", res.Content)
+		return
+	}
+	log.Printf("[session] titled the session %q", title)
+
+	s.titleMu.Lock()
+	s.title, s.summary = title, summary
+	s.titleMu.Unlock()
+
+	if s.store != nil && s.config.SaveHistory {
+		if err := s.store.SaveTitle(title, summary); err != nil {
+			log.Println("[session] failed to save the title:", err)
+		}
+	}
+
+	s.events <- ui.EventTitle{Title: title, Summary: summary}
+}
+
+// parseTitle gets the title from the first line of the response and the
+// summary from the rest
+func parseTitle(content string) (title, summary string) {
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.Trim(strings.TrimLeft(line, "#-"), " \t\"*")
+		for _, label := range []string{"title:", "summary:"} {
+			if strings.HasPrefix(strings.ToLower(line), label) {
+				line = strings.Trim(line[len(label):], " \t\"*")
+			}
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return "", ""
+	}
+
+	title = strings.TrimSuffix(lines[0], ".")
+	if r := []rune(title); len(r) > maxTitle {
+		title = strings.TrimSpace(string(r[:maxTitle])) + "..."
+	}
+
+	if len(lines) > 3 {
+		lines = lines[:3]
+	}
+	return title, strings.Join(lines[1:], "\n")
+}
@@ -0,0 +1,20 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTitle(t *testing.T) {
+	title, summary := parseTitle("Fixing the flaky watcher test.\nThe watcher test failed at random.\nA debounce race was found and fixed.\n")
+	assert.Equal(t, "Fixing the flaky watcher test", title)
+	assert.Equal(t, "The watcher test failed at random.\nA debounce race was found and fixed.", summary)
+
+	title, summary = parseTitle("**Title:** \"Go generics\"\n\nSummary: Talked about type parameters.")
+	assert.Equal(t, "Go generics", title)
+	assert.Equal(t, "Talked about type parameters.", summary)
+
+	title, _ = parseTitle("\n\n")
+	assert.Empty(t, title)
+}
@@ -0,0 +1,34 @@
+package chat
+
+import (
+	"log"
+
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/ui"
+)
+
+// addUsage adds the tokens a stream used to the session's totals and tells
+// the UI, the provider doesn't always say if the stream broke early
+func (s *Session) addUsage(u *ai.Usage) {
+	if u == nil {
+		return
+	}
+
+	s.usageMu.Lock()
+	s.usage.Add(*u)
+	s.lastUsage = *u
+	s.requests++
+	total := s.usage
+	s.usageMu.Unlock()
+
+	log.Printf("[session] the request used %d prompt and %d completion tokens, %d in the session", u.PromptTokens, u.CompletionTokens, total.Total())
+	s.events <- ui.EventUsage{Last: *u, Total: total, Cost: s.Cost()}
+}
+
+// TokenUsage returns the tokens used by the session's responses so far, as
+// the provider counted them, and by the last one
+func (s *Session) TokenUsage() (total, last ai.Usage, requests int) {
+	s.usageMu.Lock()
+	defer s.usageMu.Unlock()
+	return s.usage, s.lastUsage, s.requests
+}
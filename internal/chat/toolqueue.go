@@ -0,0 +1,43 @@
+package chat
+
+import (
+	"slices"
+
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/ui"
+)
+
+// toolQueue keeps the UI up to date with how far along the tool calls from a
+// response are, it is only shown when there is more than one of them
+type toolQueue struct {
+	events chan any
+	items  ui.EventToolQueue
+}
+
+func newToolQueue(events chan any, calls []*ai.ToolCall) *toolQueue {
+	q := &toolQueue{events: events}
+	for _, tc := range calls {
+		q.items = append(q.items, ui.ToolQueueItem{Name: tc.Name, Status: ui.ToolQueued})
+	}
+	q.send()
+	return q
+}
+
+func (q *toolQueue) set(i int, status ui.ToolStatus) {
+	q.items[i].Status = status
+	q.send()
+}
+
+// skip marks the calls from the ith on as skipped
+func (q *toolQueue) skip(i int) {
+	for ; i < len(q.items); i++ {
+		q.items[i].Status = ui.ToolSkipped
+	}
+	q.send()
+}
+
+func (q *toolQueue) send() {
+	if len(q.items) > 1 {
+		q.events <- slices.Clone(q.items)
+	}
+}
@@ -0,0 +1,97 @@
+package chat
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/commands"
+	"github.com/penguinpowernz/clai/internal/tools"
+)
+
+// toolSwitcher is a ToolRegistry whose tools can be turned on and off
+type toolSwitcher interface {
+	All() []tools.Tool
+	Enabled(name string) bool
+	SetEnabled(name string, enabled bool) error
+	Changed() map[string]bool
+}
+
+var errFixedTools = errors.New("the tools of this session can't be changed")
+
+// ToolOptions lists the tools the policy allows with their permission state,
+// for /tools
+func (s *Session) ToolOptions() []commands.ToolOption {
+	sw, ok := s.registry.(toolSwitcher)
+	if !ok {
+		return nil
+	}
+
+	var options []commands.ToolOption
+	for _, t := range tools.Allowed(*s.config, sw.All()) {
+		o := commands.ToolOption{Name: t.Function.Name, Source: t.Source(), State: commands.ToolAsk}
+		switch {
+		case !sw.Enabled(o.Name):
+			o.State = commands.ToolDisabled
+		case slices.Contains(s.config.PermittedTools, o.Name):
+			o.State = commands.ToolAlways
+		case s.permittedTools[o.Name]:
+			o.State = commands.ToolSession
+		}
+		options = append(options, o)
+	}
+	return options
+}
+
+// SetToolState changes when the tool can be used, "always" adds it to
+// permitted_tools for this session, /tools save keeps it
+func (s *Session) SetToolState(name, state string) error {
+	sw, ok := s.registry.(toolSwitcher)
+	if !ok {
+		return errFixedTools
+	}
+	if err := sw.SetEnabled(name, state != commands.ToolDisabled); err != nil {
+		return err
+	}
+
+	s.config.PermittedTools = slices.DeleteFunc(s.config.PermittedTools, func(n string) bool { return n == name })
+	delete(s.permittedTools, name)
+	switch state {
+	case commands.ToolAlways:
+		s.config.PermittedTools = append(s.config.PermittedTools, name)
+		s.permittedTools[name] = true
+	case commands.ToolSession:
+		s.permittedTools[name] = true
+	}
+	return nil
+}
+
+// SaveTools saves permitted_tools, and the tools that were enabled or
+// disabled in this session, to the config file
+func (s *Session) SaveTools() error {
+	sw, ok := s.registry.(toolSwitcher)
+	if !ok {
+		return errFixedTools
+	}
+
+	values := map[string]any{"permitted_tools": s.config.PermittedTools}
+
+	// the lists in the file, the project may have added to them
+	disabled := config.List("disabled_tools")
+	enabled := config.List("enabled_tools")
+	for name, on := range sw.Changed() {
+		disabled = slices.DeleteFunc(disabled, func(n string) bool { return n == name })
+		switch {
+		case !on:
+			disabled = append(disabled, name)
+		case len(enabled) > 0 && !slices.Contains(enabled, name):
+			enabled = append(enabled, name)
+		}
+	}
+	values["disabled_tools"] = disabled
+	if len(enabled) > 0 {
+		values["enabled_tools"] = enabled
+	}
+
+	return config.SetValues(values)
+}
@@ -1,36 +1,120 @@
 package chat
 
 import (
+	"context"
+	"encoding/json"
 	"log"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/files"
+	"github.com/penguinpowernz/clai/internal/forge"
 )
 
 var reTaggedFilename = regexp.MustCompile(`(@[./a-zA-Z0-9_-]+)`)
+var reIssueMention = regexp.MustCompile(`(?:^|\s)#(\d+)\b`)
 
 var fileReader = os.ReadFile
 
-func enhanceMessage(config *config.Config, message string) string {
+// issueFetcher gets issues for #123 mentions, giving up if the forge is slow
+var issueFetcher = func(cfg config.Config, workingDir string, number int) (*forge.Issue, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return forge.FetchIssue(ctx, cfg, workingDir, number)
+}
+
+func enhanceMessage(config *config.Config, workingDir, message string) string {
 	if strings.Contains(message, "@") {
-		matches := reTaggedFilename.FindStringSubmatch(message)
-		if len(matches) > 0 {
-			for _, fn := range matches {
-				_fn := fn
-				fn := strings.TrimPrefix(fn, "@")
-				data, err := fileReader(fn)
-				if err != nil {
-					log.Println("[session.enhance] failed to read file:", fn, err)
+		seen := map[string]bool{}
+		for _, tag := range reTaggedFilename.FindAllString(message, -1) {
+			fn := strings.TrimPrefix(tag, "@")
+			if seen[fn] {
+				continue
+			}
+			seen[fn] = true
+
+			if config != nil {
+				if err := files.CheckAccess(*config, workingDir, fn); err != nil {
+					log.Println("[session.enhance] can't include", fn+":", err)
 					continue
 				}
+			}
 
-				message = strings.ReplaceAll(message, _fn, fn)
-				message += "\n\nYou can see the content of " + _fn + " here:\n```\n" + string(data) + "\n```\n"
+			data, err := fileReader(filepath.Join(workingDir, fn))
+			if err != nil {
+				log.Println("[session.enhance] failed to read file:", fn, err)
+				continue
 			}
+
+			message = strings.ReplaceAll(message, tag, fn)
+
+			// big files are shown as an outline for the AI to read the parts it needs
+			if config != nil && config.MaxFileTokens > 0 && ai.EstimateTokens(string(data)) > config.MaxFileTokens {
+				message += "\n\n" + files.Include(fn, files.DetectLanguage(fn, data), string(data), config.MaxFileTokens) + "\n"
+				continue
+			}
+			message += "\n\nYou can see the content of " + fn + " here:\n```\n" + string(data) + "\n```\n"
+		}
+	}
+
+	if config != nil && strings.Contains(message, "#") {
+		seen := map[int]bool{}
+		for _, m := range reIssueMention.FindAllStringSubmatch(message, -1) {
+			number, _ := strconv.Atoi(m[1])
+			if seen[number] {
+				continue
+			}
+			seen[number] = true
+
+			issue, err := issueFetcher(*config, workingDir, number)
+			if err != nil {
+				log.Println("[session.enhance] failed to fetch issue:", number, err)
+				continue
+			}
+
+			message += "\n\nYou can see issue #" + m[1] + " here:\n\n" + issue.Markdown()
 		}
 	}
 
 	return message
 }
+
+// trimMessages drops the oldest turns until the conversation fits within the
+// given token budget.  A turn is a prompt and the responses, tool calls and
+// tool output that follow it, so a tool result is never sent without the
+// call it answers.  The latest turn is always kept.
+func trimMessages(messages []ai.Message, budget int) []ai.Message {
+	total, start := 0, len(messages)
+	for i := len(messages) - 1; i >= 0; i-- {
+		total += messageTokens(messages[i])
+		if total > budget && start < len(messages) {
+			return messages[start:]
+		}
+		if isPrompt(messages[i]) {
+			start = i
+		}
+	}
+	return messages
+}
+
+// isPrompt checks if the message is something the user typed, rather than the
+// output of a tool sent back for them
+func isPrompt(m ai.Message) bool {
+	return m.Role == "user" && m.ToolCallID == ""
+}
+
+// messageTokens estimates the tokens in a message, including the tools it calls
+func messageTokens(m ai.Message) int {
+	n := ai.EstimateTokens(m.Content)
+	for _, tc := range m.Calls() {
+		input, _ := json.Marshal(tc.Input)
+		n += ai.EstimateTokens(tc.Name + string(input))
+	}
+	return n
+}
@@ -1,8 +1,13 @@
 package chat
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
 
+	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/forge"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -13,11 +18,99 @@ func TestEnhanceMessage(t *testing.T) {
 		return []byte("TEST DATA"), nil
 	}
 
-	message := enhanceMessage(nil, "I want you to read @test.txt and look for mentions of horse")
+	message := enhanceMessage(nil, "", "I want you to read @test.txt and look for mentions of horse")
 	assert.Contains(t, message, "You can see the content of test.txt here:\n```\nTEST DATA\n```\n")
 	assert.Equal(t, "test.txt", fn)
 
-	message = enhanceMessage(nil, "I want you to read @cmd/test/main.go and look for mentions of cat")
+	message = enhanceMessage(nil, "", "I want you to read @cmd/test/main.go and look for mentions of cat")
 	assert.Contains(t, message, "You can see the content of cmd/test/main.go here:\n```\nTEST DATA\n```\n")
 	assert.Equal(t, "cmd/test/main.go", fn)
+
+	enhanceMessage(nil, "/src/project/pkg", "what does @main.go do?")
+	assert.Equal(t, "/src/project/pkg/main.go", fn)
+
+	// big files are given as an outline
+	fileReader = func(filename string) ([]byte, error) {
+		return []byte("package main\n\nfunc main() {\n" + strings.Repeat("\tprintln(\"hi\")\n", 100) + "}\n"), nil
+	}
+	cfg := config.Default()
+	cfg.MaxFileTokens = 100
+	message = enhanceMessage(cfg, "", "what does @main.go do? look at @main.go")
+	assert.Equal(t, 1, strings.Count(message, "main.go is 104 lines"))
+	assert.Contains(t, message, "3  func main() {")
+	assert.NotContains(t, message, "println")
+
+	// mentions keep to the same rules as the tools
+	var read []string
+	fileReader = func(filename string) ([]byte, error) {
+		read = append(read, filename)
+		return []byte("TEST DATA"), nil
+	}
+	for _, mention := range []string{"@../../etc/passwd", "@vendor/lib.go", "@.env"} {
+		message = enhanceMessage(cfg, "/src/project", "what is in "+mention)
+		assert.NotContains(t, message, "You can see", mention)
+	}
+	assert.Empty(t, read)
+}
+
+func TestEnhanceMessageIssues(t *testing.T) {
+	var fetched []int
+	issueFetcher = func(cfg config.Config, workingDir string, number int) (*forge.Issue, error) {
+		fetched = append(fetched, number)
+		return &forge.Issue{Number: number, Title: "the bug"}, nil
+	}
+
+	message := enhanceMessage(config.Default(), "", "fix #123, it's like #123 and #45 but not a#6")
+	assert.Equal(t, []int{123, 45}, fetched)
+	assert.Contains(t, message, "You can see issue #123 here:\n\n## Issue #123: the bug")
+
+	fetched = nil
+	enhanceMessage(nil, "", "fix #123")
+	assert.Empty(t, fetched)
+}
+
+func TestTrimMessages(t *testing.T) {
+	msgs := []ai.Message{
+		{Role: "user", Content: strings.Repeat("a", 400)},
+		{Role: "assistant", Content: strings.Repeat("b", 400)},
+		{Role: "user", Content: strings.Repeat("c", 400)},
+	}
+
+	assert.Len(t, trimMessages(msgs, 1000), 3)
+	assert.Equal(t, msgs[2:], trimMessages(msgs, 150))
+
+	// a response isn't kept without its prompt
+	assert.Equal(t, msgs[2:], trimMessages(msgs, 250))
+
+	// the latest turn is kept even when it is over budget
+	assert.Equal(t, msgs[2:], trimMessages(msgs, 10))
+}
+
+func TestTrimMessagesToolTurns(t *testing.T) {
+	input := json.RawMessage(`{"path":"` + strings.Repeat("d", 400) + `"}`)
+	msgs := []ai.Message{
+		{Role: "user", Content: "read the file"},
+		{Role: "assistant", Content: "", ToolCalls: []ai.ToolUse{{ID: "a", Name: "read_file", Input: input}}},
+		{Role: "tool", Content: strings.Repeat("e", 400), ToolCallID: "a"},
+		{Role: "assistant", Content: "it is full of e"},
+		{Role: "user", Content: "thanks"},
+	}
+
+	// the cut falls between the tool result and its call, the whole turn goes
+	assert.Equal(t, msgs[4:], trimMessages(msgs, 150))
+
+	// the tool call's input counts towards the budget
+	assert.Equal(t, msgs[4:], trimMessages(msgs, 200))
+	assert.Equal(t, msgs, trimMessages(msgs, 300))
+
+	// a turn in the middle of a tool exchange is kept whole
+	assert.Equal(t, msgs[:3], trimMessages(msgs[:3], 10))
+}
+
+func TestCountSections(t *testing.T) {
+	payload := `{"model":"qwen3","messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hello there"}],"stream":true}`
+	assert.Equal(t, `~31 tokens in total
+  messages     ~20 tokens (2: system ~2, user ~3)
+  model        ~2 tokens
+  stream       ~1 tokens`, countSections([]byte(payload)))
 }
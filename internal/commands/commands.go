@@ -5,12 +5,25 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/penguinpowernz/clai/config"
 	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/artifacts"
 	"github.com/penguinpowernz/clai/internal/files"
+	"github.com/penguinpowernz/clai/internal/forge"
+	"github.com/penguinpowernz/clai/internal/history"
+	"github.com/penguinpowernz/clai/internal/packs"
+	"github.com/penguinpowernz/clai/internal/prompt"
+	"github.com/penguinpowernz/clai/internal/share"
+	"github.com/penguinpowernz/clai/internal/tools"
+	"github.com/penguinpowernz/clai/internal/usage"
 	"github.com/pkoukk/tiktoken-go"
 )
 
@@ -26,9 +39,58 @@ type Session interface {
 	GetClient() ai.Provider
 	ClearMessages()
 	Context() (any, []any, []any)
+	NextContext() string
+	ContextDiff() string
+	Inspect(ctx context.Context, prompt string) ([]byte, string, error)
 	Export() []ai.Message
+	Retry(ctx context.Context) error
+	Continue(ctx context.Context) error
+	Escalate(ctx context.Context, prompt string) error
+	Title() (title, summary string)
+	Budget() string
+	ApproveBudget(ctx context.Context) error
+	PromptLayers() []prompt.Layer
+	SystemPrompt() string
+	SetSystemPrompt(text string)
+	ChangeDir(path string) (string, error)
+	SetModel(model string)
+	AddMessage(message ai.Message)
+	ToolOptions() []ToolOption
+	SetToolState(name, state string) error
+	SaveTools() error
+	Artifacts() []artifacts.Artifact
+	ID() string
+	Branch(name string) (string, error)
+	Branches() (*history.Node, error)
+	TokenUsage() (total, last ai.Usage, requests int)
+	Requests() []usage.Record
 }
 
+// BranchOption is a session in the tree of branches shown by /branches
+type BranchOption struct {
+	ID      string
+	Line    string // the session drawn in the tree
+	Current bool   // the conversation is carrying on in it
+}
+
+// ToolOption is a tool listed by /tools with its permission state
+type ToolOption struct {
+	Name   string
+	Source string // "built-in" or the path of the plugin
+	State  string // one of ToolStates
+}
+
+// The permission states of a tool, ToolStates has them in the order /tools
+// changes a tool through them
+const (
+	ToolAsk      = "ask"      // the user is asked before it is run
+	ToolSession  = "session"  // run without asking for the rest of the session
+	ToolAlways   = "always"   // in permitted_tools
+	ToolDisabled = "disabled" // the AI isn't told about it
+)
+
+var ToolStates = []string{ToolAsk, ToolSession, ToolAlways, ToolDisabled}
+
 // Command represents a slash command
 type Command struct {
 	Name        string
@@ -36,6 +98,7 @@ type Command struct {
 	Description string
 	Usage       string
 	Handler     HandlerFunc
+	Complete    CompleteFunc // Completes the args when Tab is pressed, nil for none
 }
 
 // HandlerFunc is the function signature for command handlers
@@ -55,6 +118,24 @@ type Result struct {
 	ShouldExit   bool   // Whether to exit the application
 	ClearInput   bool   // Whether to clear the input field
 	AddToHistory bool   // Whether to add to conversation history
+	Copy         *int   // Copy this message to the clipboard, 0 for the last response
+	Redact       []int  // Mask these messages, numbered like Copy, in the transcript, history and context
+	Edit         *Edit  // Open some text in the editor
+
+	Tools   []ToolOption        // Show the tools in a list where their states can be changed
+	Preview *artifacts.Artifact // Show this image in the terminal
+
+	Branches     []BranchOption // Show the tree of branches in a list to switch to one
+	SwitchBranch string         // Carry on the conversation in this branch of the session
+
+	ToggleMultiline bool // Switch Enter between sending the prompt and starting a new line
+}
+
+// Edit is text for the UI to open in the editor, Done is called with the
+// edited text and returns the message to show
+type Edit struct {
+	Text string
+	Done func(text string) string
 }
 
 // Registry manages all available commands
@@ -85,6 +166,80 @@ func NewRegistry() *Registry {
 		Handler:     clearHandler,
 	})
 
+	r.Register(&Command{
+		Name:        "retry",
+		Aliases:     []string{"r"},
+		Description: "Discard the last response and generate a new one",
+		Usage:       "/retry",
+		Handler:     retryHandler,
+	})
+
+	r.Register(&Command{
+		Name:        "continue",
+		Description: "Carry on from where the last response was cut off",
+		Usage:       "/continue",
+		Handler:     continueHandler,
+	})
+
+	r.Register(&Command{
+		Name:        "multiline",
+		Aliases:     []string{"ml"},
+		Description: "Make Enter start a new line, and Ctrl+D send, or switch back",
+		Usage:       "/multiline",
+		Handler:     multilineHandler,
+	})
+
+	r.Register(&Command{
+		Name:        "cd",
+		Description: "Change the working directory for tools and file mentions",
+		Usage:       "/cd [path]",
+		Handler:     cdHandler,
+		Complete:    completePaths(true),
+	})
+
+	r.Register(&Command{
+		Name:        "copy",
+		Description: "Copy a message to the clipboard, the last response by default",
+		Usage:       "/copy [n]",
+		Handler:     copyHandler,
+	})
+
+	r.Register(&Command{
+		Name:        "preview",
+		Description: "Show an image saved in this session, the last one by default",
+		Usage:       "/preview [n]",
+		Handler:     previewHandler,
+	})
+
+	r.Register(&Command{
+		Name:        "branch",
+		Description: "Carry on the conversation in a new branch, keeping the session as it is",
+		Usage:       "/branch [name]",
+		Handler:     branchHandler,
+	})
+
+	r.Register(&Command{
+		Name:        "branches",
+		Description: "Show the tree of branches of the session, or switch to one",
+		Usage:       "/branches [session]",
+		Handler:     branchesHandler,
+		Complete:    completeBranches,
+	})
+
+	r.Register(&Command{
+		Name:        "redact",
+		Description: "Mask a message, or a range of them, in the history and what is sent to the AI (clai.log and the session's log keep it)",
+		Usage:       "/redact <n>[-m]",
+		Handler:     redactHandler,
+	})
+
+	r.Register(&Command{
+		Name:        "issue",
+		Description: "Add a GitHub/GitLab issue and its comments to the context",
+		Usage:       "/issue <number>",
+		Handler:     issueHandler,
+	})
+
 	r.Register(&Command{
 		Name:        "thinking",
 		Aliases:     []string{"think"},
@@ -93,6 +248,45 @@ func NewRegistry() *Registry {
 		Handler:     thinkingHandler,
 	})
 
+	r.Register(&Command{
+		Name:        "meta",
+		Description: "Toggle showing the time, model and tokens of each message",
+		Usage:       "/meta",
+		Handler:     metaHandler,
+	})
+
+	r.Register(&Command{
+		Name:        "style",
+		Description: "Show or change how the responses are written for this session",
+		Usage:       "/style [language <name|off>|emoji|concise|comments|off]",
+		Handler:     styleHandler,
+		Complete:    completeArgs(1, "language", "emoji", "concise", "comments", "off"),
+	})
+
+	r.Register(&Command{
+		Name:        "deterministic",
+		Description: "Turn on temperature 0 and a fixed seed so the responses can be reproduced",
+		Usage:       "/deterministic [on|off]",
+		Handler:     deterministicHandler,
+		Complete:    completeArgs(1, "on", "off"),
+	})
+
+	r.Register(&Command{
+		Name:        "constrain",
+		Description: "Show or change the format the responses are held to for this session (Ollama, llama.cpp)",
+		Usage:       "/constrain [json|schema <file|schema>|grammar <file|grammar>|off]",
+		Handler:     constrainHandler,
+		Complete:    completeConstrain,
+	})
+
+	r.Register(&Command{
+		Name:        "tools",
+		Description: "List the tools and change when they can be used",
+		Usage:       "/tools [<tool> ask|session|always|disabled|save]",
+		Handler:     toolsHandler,
+		Complete:    completeTools,
+	})
+
 	r.Register(&Command{
 		Name:        "exit",
 		Aliases:     []string{"quit", "q"},
@@ -101,29 +295,29 @@ func NewRegistry() *Registry {
 		Handler:     exitHandler,
 	})
 
-	// r.Register(&Command{
-	// 	Name:        "add",
-	// 	Aliases:     []string{"load"},
-	// 	Description: "Add file(s) to context",
-	// 	Usage:       "/add <file1> [file2] ...",
-	// 	Handler:     addFileHandler,
-	// })
+	r.Register(&Command{
+		Name:        "add",
+		Aliases:     []string{"load"},
+		Description: "Add file(s) to the context sent with each prompt",
+		Usage:       "/add <file1> [file2] ...",
+		Handler:     addFileHandler,
+	})
 
-	// r.Register(&Command{
-	// 	Name:        "remove",
-	// 	Aliases:     []string{"rm"},
-	// 	Description: "Remove file(s) from context",
-	// 	Usage:       "/remove <file1> [file2] ...",
-	// 	Handler:     removeFileHandler,
-	// })
+	r.Register(&Command{
+		Name:        "remove",
+		Aliases:     []string{"rm"},
+		Description: "Remove file(s) from context",
+		Usage:       "/remove <file1> [file2] ...",
+		Handler:     removeFileHandler,
+	})
 
-	// r.Register(&Command{
-	// 	Name:        "files",
-	// 	Aliases:     []string{"ls"},
-	// 	Description: "List files in context",
-	// 	Usage:       "/files",
-	// 	Handler:     listFilesHandler,
-	// })
+	r.Register(&Command{
+		Name:        "files",
+		Aliases:     []string{"ls"},
+		Description: "List files in context",
+		Usage:       "/files",
+		Handler:     listFilesHandler,
+	})
 
 	r.Register(&Command{
 		Name:        "model",
@@ -131,6 +325,14 @@ func NewRegistry() *Registry {
 		Description: "Show or change the AI model",
 		Usage:       "/model [model-name]",
 		Handler:     modelHandler,
+		Complete:    completeModels,
+	})
+
+	r.Register(&Command{
+		Name:        "model!",
+		Description: "Send the prompt to the main model instead of cheap_model, or retry the last one with it",
+		Usage:       "/model! [prompt]",
+		Handler:     escalateHandler,
 	})
 
 	r.Register(&Command{
@@ -148,12 +350,43 @@ func NewRegistry() *Registry {
 		Handler:     tokensHandler,
 	})
 
+	r.Register(&Command{
+		Name:        "context",
+		Description: "Show what the next request will send, or what changed since the last one with diff",
+		Usage:       "/context [diff]",
+		Handler:     contextHandler,
+		Complete:    completeArgs(1, "diff"),
+	})
+
+	r.Register(&Command{
+		Name:        "inspect",
+		Description: "Show the request that sending the prompt would make, without sending it",
+		Usage:       "/inspect [prompt]",
+		Handler:     inspectHandler,
+	})
+
+	r.Register(&Command{
+		Name:        "cost",
+		Description: "Show what the session has spent, by model and for the last requests",
+		Usage:       "/cost",
+		Handler:     costHandler,
+	})
+
+	r.Register(&Command{
+		Name:        "budget",
+		Description: "Show the spending against the budget, or allow going over it with ok",
+		Usage:       "/budget [ok]",
+		Handler:     budgetHandler,
+		Complete:    completeArgs(1, "ok"),
+	})
+
 	r.Register(&Command{
 		Name:        "system",
 		Aliases:     []string{"sys"},
 		Description: "Show or update system prompt",
-		Usage:       "/system [new prompt]",
+		Usage:       "/system [--show-effective|--reset|edit [--project|--global]|new prompt]",
 		Handler:     systemPromptHandler,
+		Complete:    completeSystem,
 	})
 
 	r.Register(&Command{
@@ -162,6 +395,14 @@ func NewRegistry() *Registry {
 		Description: "Export the conversation to a file",
 		Usage:       "/export <filename>",
 		Handler:     exportHandler,
+		Complete:    completePaths(false),
+	})
+
+	r.Register(&Command{
+		Name:        "share",
+		Description: "Give a read-only link to the session that others can watch live",
+		Usage:       "/share [stop]",
+		Handler:     shareHandler,
 	})
 
 	r.Register(&Command{
@@ -170,17 +411,77 @@ func NewRegistry() *Registry {
 		Description: "Show or update configuration",
 		Usage:       "/config [key] [value]",
 		Handler:     configHandler,
+		Complete:    completeConfigKeys,
 	})
 
 	return r
 }
 
-// Register adds a command to the registry
-func (r *Registry) Register(cmd *Command) {
+// Register adds a command to the registry, it returns an error if the name or
+// any of the aliases are already taken
+func (r *Registry) Register(cmd *Command) error {
+	for _, name := range append([]string{cmd.Name}, cmd.Aliases...) {
+		if existing, ok := r.commands[name]; ok {
+			return fmt.Errorf("/%s is already used by the /%s command", name, existing.Name)
+		}
+	}
+
 	r.commands[cmd.Name] = cmd
 	for _, alias := range cmd.Aliases {
 		r.commands[alias] = cmd
 	}
+	return nil
+}
+
+// RegisterPluginCommands adds the slash commands provided by plugins to the
+// registry, returning an error for each command that could not be registered
+func (r *Registry) RegisterPluginCommands(cmds []tools.PluginCommand) []error {
+	var errs []error
+	for _, pc := range cmds {
+		usage := pc.Usage
+		if usage == "" {
+			usage = "/" + pc.Name
+		}
+
+		err := r.Register(&Command{
+			Name:        pc.Name,
+			Aliases:     pc.Aliases,
+			Description: pc.Description,
+			Usage:       usage,
+			Handler:     pluginCommandHandler(pc),
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("plugin %s: %w", pc.Plugin(), err))
+		}
+	}
+	return errs
+}
+
+// RegisterPackCommands adds the slash commands from the team's packs to the
+// registry, returning an error for each command that could not be registered
+func (r *Registry) RegisterPackCommands(cmds []packs.Command) []error {
+	var errs []error
+	for _, pc := range cmds {
+		usage := pc.Usage
+		if usage == "" {
+			usage = "/" + pc.Name
+		}
+		desc := pc.Description
+		if desc == "" {
+			desc = "From the " + pc.Pack + " pack"
+		}
+
+		err := r.Register(&Command{
+			Name:        pc.Name,
+			Description: desc,
+			Usage:       usage,
+			Handler:     packCommandHandler(pc),
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("pack %s: %w", pc.Pack, err))
+		}
+	}
+	return errs
 }
 
 // Get retrieves a command by name or alias
@@ -292,6 +593,232 @@ func clearHandler(ctx context.Context, args []string, env *Environment) (*Result
 	}, nil
 }
 
+func retryHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
+	if err := env.Session.Retry(ctx); err != nil {
+		return &Result{
+			Message:    fmt.Sprintf("Can't retry: %v", err),
+			ClearInput: true,
+		}, nil
+	}
+
+	return &Result{
+		Message:    "Discarded the last response, retrying...",
+		ClearInput: true,
+	}, nil
+}
+
+func continueHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
+	if err := env.Session.Continue(ctx); err != nil {
+		return &Result{
+			Message:    fmt.Sprintf("Can't continue: %v", err),
+			ClearInput: true,
+		}, nil
+	}
+
+	return &Result{
+		Message:    "Continuing the last response...",
+		ClearInput: true,
+	}, nil
+}
+
+func cdHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
+	path := ""
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	rel, err := env.Session.ChangeDir(path)
+	if err != nil {
+		return &Result{
+			Message:    fmt.Sprintf("Can't change directory: %v", err),
+			ClearInput: true,
+		}, nil
+	}
+
+	return &Result{
+		Message:    fmt.Sprintf("Working directory is now %s", rel),
+		ClearInput: true,
+	}, nil
+}
+
+func copyHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
+	n := 0
+	if len(args) > 0 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+			return &Result{
+				Message:    "Usage: /copy [n], where n is the message number shown in selection mode (Ctrl+S)",
+				ClearInput: true,
+			}, nil
+		}
+	}
+
+	return &Result{
+		Copy:       &n,
+		ClearInput: true,
+	}, nil
+}
+
+func previewHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
+	var images []artifacts.Artifact
+	for _, a := range env.Session.Artifacts() {
+		if a.IsImage() {
+			images = append(images, a)
+		}
+	}
+	if len(images) == 0 {
+		return &Result{Message: "No images have been saved in this session", ClearInput: true}, nil
+	}
+
+	n := len(images)
+	if len(args) > 0 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil || n < 1 || n > len(images) {
+			return &Result{
+				Message:    fmt.Sprintf("Usage: /preview [n], where n is 1 to %d for the images saved in this session", len(images)),
+				ClearInput: true,
+			}, nil
+		}
+	}
+
+	a := images[n-1]
+	return &Result{
+		Message:    fmt.Sprintf("Image %d of %d: %s", n, len(images), a.Path),
+		Preview:    &a,
+		ClearInput: true,
+	}, nil
+}
+
+func branchHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
+	from := env.Session.ID()
+	id, err := env.Session.Branch(strings.Join(args, " "))
+	if err != nil {
+		return &Result{Message: fmt.Sprintf("Failed to branch: %v", err), ClearInput: true}, nil
+	}
+
+	return &Result{
+		Message:    fmt.Sprintf("Branched %s off %s, the conversation carries on in the branch, /branches shows them all", id, from),
+		ClearInput: true,
+	}, nil
+}
+
+func branchesHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
+	tree, err := env.Session.Branches()
+	if err != nil {
+		return &Result{Message: fmt.Sprintf("Failed to list the branches: %v", err), ClearInput: true}, nil
+	}
+
+	if len(args) == 1 {
+		if tree.Find(args[0]) == nil {
+			return &Result{Message: fmt.Sprintf("%s isn't a branch of this session, see /branches", args[0]), ClearInput: true}, nil
+		}
+		return &Result{SwitchBranch: args[0], ClearInput: true}, nil
+	}
+
+	if len(tree.Children) == 0 {
+		return &Result{Message: "This session has no branches, /branch makes one", ClearInput: true}, nil
+	}
+
+	options := BranchOptions(tree, env.Session.ID())
+	var b strings.Builder
+	b.WriteString("Branches:\n")
+	for _, o := range options {
+		mark := " "
+		if o.Current {
+			mark = "*"
+		}
+		fmt.Fprintf(&b, "%s %s\n", mark, o.Line)
+	}
+	return &Result{Message: b.String(), Branches: options, ClearInput: true}, nil
+}
+
+// BranchOptions draws the sessions in the tree, current is the one the
+// conversation is carrying on in
+func BranchOptions(tree *history.Node, current string) []BranchOption {
+	var options []BranchOption
+	tree.Walk(func(n *history.Node, prefix string) {
+		line := prefix + n.ID
+		if label := n.Label(); label != "" {
+			line += "  " + label
+		}
+		options = append(options, BranchOption{ID: n.ID, Line: line, Current: n.ID == current})
+	})
+	return options
+}
+
+func redactHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
+	usage := &Result{
+		Message:    "Usage: /redact <n>[-m], where n is the message number shown in selection mode (Ctrl+S)",
+		ClearInput: true,
+	}
+	if len(args) != 1 {
+		return usage, nil
+	}
+
+	from, to, isRange := strings.Cut(args[0], "-")
+	first, err := strconv.Atoi(from)
+	if err != nil || first < 1 {
+		return usage, nil
+	}
+	last := first
+	if isRange {
+		if last, err = strconv.Atoi(to); err != nil || last < first {
+			return usage, nil
+		}
+	}
+
+	var ns []int
+	for n := first; n <= last; n++ {
+		ns = append(ns, n)
+	}
+	return &Result{
+		Redact:     ns,
+		ClearInput: true,
+	}, nil
+}
+
+func multilineHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
+	return &Result{
+		ToggleMultiline: true,
+		ClearInput:      true,
+	}, nil
+}
+
+func issueHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
+	if len(args) == 0 {
+		return &Result{
+			Message:    "Usage: /issue <number>",
+			ClearInput: true,
+		}, nil
+	}
+
+	number, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+	if err != nil {
+		return &Result{
+			Message:    fmt.Sprintf("Invalid issue number: %s", args[0]),
+			ClearInput: true,
+		}, nil
+	}
+
+	issue, err := forge.FetchIssue(ctx, *env.Config, env.WorkingDir, number)
+	if err != nil {
+		return &Result{
+			Message:    fmt.Sprintf("Failed to fetch issue #%d: %v", number, err),
+			ClearInput: true,
+		}, nil
+	}
+
+	env.Session.AddMessage(ai.Message{
+		Role:    "user",
+		Content: "Here is an issue for context:\n\n" + issue.Markdown(),
+	})
+
+	return &Result{
+		Message:    fmt.Sprintf("Added issue #%d to the context: %s (%d comments)", issue.Number, issue.Title, len(issue.Comments)),
+		ClearInput: true,
+	}, nil
+}
+
 func exitHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
 	return &Result{
 		Message:    "Goodbye!",
@@ -388,15 +915,15 @@ func modelHandler(ctx context.Context, args []string, env *Environment) (*Result
 	if len(args) == 0 {
 		info := env.Session.GetClient().GetModelInfo()
 		return &Result{
-			Message: fmt.Sprintf("Current model: %s (%s)\nMax tokens: %d\nUse /model <model> to change models",
-				info.Name, info.Provider, info.MaxTokens),
+			Message: fmt.Sprintf("Current model: %s (%s)\nMax tokens: %d\nContext window: %d\nTools: %t\nVision: %t\nUse /model <model> to change models",
+				info.Name, info.Provider, info.MaxTokens, info.ContextWindow, info.SupportsTools, info.SupportsVision),
 			ClearInput: true,
 		}, nil
 	}
 
 	if len(args) > 0 {
 		model := args[0]
-		env.Config.Model = model
+		env.Session.SetModel(model)
 		return &Result{
 			Message:    fmt.Sprintf("Model changed to %s for this session", model),
 			ClearInput: true,
@@ -410,6 +937,119 @@ func modelHandler(ctx context.Context, args []string, env *Environment) (*Result
 	}, nil
 }
 
+func escalateHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
+	prompt := strings.Join(args, " ")
+	if err := env.Session.Escalate(ctx, prompt); err != nil {
+		return &Result{
+			Message:    fmt.Sprintf("Can't retry: %v", err),
+			ClearInput: true,
+		}, nil
+	}
+
+	msg := fmt.Sprintf("Sending the prompt to %s...", env.Config.Model)
+	if prompt == "" {
+		msg = fmt.Sprintf("Retrying the last prompt with %s...", env.Config.Model)
+	}
+	return &Result{
+		Message:    msg,
+		ClearInput: true,
+	}, nil
+}
+
+// costRecent is how many of the last requests /cost shows
+const costRecent = 5
+
+func costHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
+	var b strings.Builder
+
+	caps := ai.LookupModel(env.Config, env.Config.Model)
+	price := fmt.Sprintf("%s costs $%.2f per million prompt tokens and $%.2f per million completion tokens", env.Config.Model, caps.InputPrice, caps.OutputPrice)
+	if caps.Cost(1, 1) == 0 {
+		price = env.Config.Model + " has no price, set input_price and output_price for it under models if it isn't free"
+	}
+
+	records := env.Session.Requests()
+	if len(records) == 0 {
+		return &Result{Message: "Nothing has been spent yet, " + price, ClearInput: true}, nil
+	}
+
+	// totalled by model, in the order they were first used
+	var total usage.Row
+	var models []*usage.Row
+	byModel := map[string]*usage.Row{}
+	for _, r := range records {
+		row, ok := byModel[r.Model]
+		if !ok {
+			row = &usage.Row{Model: r.Model}
+			byModel[r.Model] = row
+			models = append(models, row)
+		}
+		for _, row := range []*usage.Row{row, &total} {
+			row.Requests++
+			row.Prompt += r.Prompt
+			row.Completion += r.Completion
+			row.Cost += r.Cost
+			row.Estimated = row.Estimated || r.Estimated
+		}
+	}
+
+	tokens := func(n int, estimated bool) string {
+		if estimated {
+			return "~" + strconv.Itoa(n)
+		}
+		return strconv.Itoa(n)
+	}
+
+	fmt.Fprintf(&b, "This session has spent $%.4f on %d requests:\n\n", total.Cost, total.Requests)
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	for _, row := range models {
+		fmt.Fprintf(tw, "  %s\t%d requests\t%s in\t%s out\t$%.4f\n", row.Model, row.Requests, tokens(row.Prompt, row.Estimated), tokens(row.Completion, row.Estimated), row.Cost)
+	}
+	tw.Flush()
+
+	b.WriteString("\nThe last requests:\n\n")
+	tw = tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	for _, r := range records[max(0, len(records)-costRecent):] {
+		fmt.Fprintf(tw, "  %s\t%s\t%s in\t%s out\t$%.4f\n", r.Time.Format(time.TimeOnly), r.Model, tokens(r.Prompt, r.Estimated), tokens(r.Completion, r.Estimated), r.Cost)
+	}
+	tw.Flush()
+
+	b.WriteString("\n" + price)
+	if total.Estimated {
+		b.WriteString("\n(token counts with a ~ were estimated as the provider didn't give them)")
+	}
+
+	return &Result{Message: b.String(), ClearInput: true}, nil
+}
+
+func budgetHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
+	if len(args) == 0 {
+		return &Result{
+			Message:    env.Session.Budget(),
+			ClearInput: true,
+		}, nil
+	}
+
+	if args[0] != "ok" {
+		return &Result{
+			Message:    "Usage: /budget [ok]",
+			ClearInput: true,
+		}, nil
+	}
+
+	if err := env.Session.ApproveBudget(ctx); err != nil {
+		return &Result{
+			Message:    fmt.Sprintf("Can't approve: %v", err),
+			ClearInput: true,
+		}, nil
+	}
+
+	return &Result{
+		Message:    "Paid requests are allowed past the budget for the rest of the session",
+		ClearInput: true,
+	}, nil
+}
+
 func modelsHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
 	// List available models
 
@@ -428,46 +1068,54 @@ func modelsHandler(ctx context.Context, args []string, env *Environment) (*Resul
 }
 
 func tokensHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
-	// This would track token usage across the session
-	// For now, just show a placeholder
-
-	enc, err := tiktoken.GetEncoding("cl100k_base")
-	if err != nil {
-		return nil, err
+	total, last, requests := env.Session.TokenUsage()
+	if requests == 0 {
+		return &Result{Message: "Nothing has been sent yet, the tokens are counted as the responses come in", ClearInput: true}, nil
 	}
 
-	dump := func(v any) string { d, _ := json.Marshal(v); return string(d) }
+	style := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("205"))
 
-	sys, in, out := env.Session.Context()
+	msg := fmt.Sprintf(`  %s:     %7d tokens
+  %s: %7d tokens
+  %s:      %7d tokens over %d responses
+  %s:    %7d tokens in the last response
+  %s:        %7d tokens
+`,
+		style.Render("Prompt"), total.PromptTokens,
+		style.Render("Completion"), total.CompletionTokens,
+		style.Render("Total"), total.Total(), requests,
+		style.Render("Context"), last.Total(),
+		style.Render("Max"), env.Session.GetClient().GetModelInfo().MaxTokens,
+	)
+	if total.Estimated {
+		msg += "  (the provider didn't give the counts for some responses, they were estimated)\n"
+	}
 
-	system := len(enc.Encode(dump(sys), nil, nil))
-	input := len(enc.Encode(dump(in), nil, nil))
-	output := len(enc.Encode(dump(out), nil, nil))
-	total := system + input + output
+	return &Result{Message: msg, ClearInput: true}, nil
+}
 
-	style := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("205"))
+func contextHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
+	switch {
+	case len(args) == 0:
+		return &Result{Message: env.Session.NextContext(), ClearInput: true}, nil
+	case args[0] == "diff":
+		return &Result{Message: env.Session.ContextDiff(), ClearInput: true}, nil
+	}
+	return &Result{Message: "Usage: /context [diff]", ClearInput: true}, nil
+}
 
-	return &Result{
-		Message: fmt.Sprintf(`  %s: %5d tokens
-  %s:  %5d tokens
-  %s: %5d tokens
-  %s:  %5d tokens
-  %s:  %5d tokens
-	`,
-			style.Render("System"), system,
-			style.Render("Input"), input,
-			style.Render("Output"), output,
-			style.Render("Total"), total,
-			style.Render("Max"), env.Session.GetClient().GetModelInfo().MaxTokens,
-		),
-		ClearInput: true,
-	}, nil
+func inspectHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
+	payload, tokens, err := env.Session.Inspect(ctx, strings.Join(args, " "))
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Message: string(payload) + "\n\n" + tokens, ClearInput: true}, nil
 }
 
 func systemPromptHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
 	// Show current system prompt
 	if len(args) == 0 {
-		prompt := env.Config.SystemPrompt
+		prompt := env.Session.SystemPrompt()
 		if prompt == "" {
 			prompt = "(no system prompt set)"
 		}
@@ -477,9 +1125,22 @@ func systemPromptHandler(ctx context.Context, args []string, env *Environment) (
 		}, nil
 	}
 
+	switch args[0] {
+	case "--show-effective":
+		return showEffectivePrompt(env)
+	case "edit":
+		return editSystemPrompt(args[1:], env)
+	case "--reset":
+		env.Session.SetSystemPrompt("")
+		return &Result{
+			Message:    "System prompt reset to the one from the config and project",
+			ClearInput: true,
+		}, nil
+	}
+
 	// Update system prompt
 	newPrompt := strings.Join(args, " ")
-	env.Config.SystemPrompt = newPrompt
+	env.Session.SetSystemPrompt(newPrompt)
 
 	return &Result{
 		Message:    "System prompt updated for this session",
@@ -487,6 +1148,124 @@ func systemPromptHandler(ctx context.Context, args []string, env *Environment) (
 	}, nil
 }
 
+// editSystemPrompt opens the system prompt in the editor, by default the
+// result replaces it for the session, --project and --global edit and save
+// the prompt in the project file or the config instead
+func editSystemPrompt(args []string, env *Environment) (*Result, error) {
+	where := ""
+	if len(args) > 0 {
+		where = args[0]
+	}
+
+	switch where {
+	case "":
+		return &Result{
+			ClearInput: true,
+			Edit: &Edit{
+				Text: env.Session.SystemPrompt(),
+				Done: func(text string) string {
+					env.Session.SetSystemPrompt(strings.TrimSpace(text))
+					return "System prompt updated for this session"
+				},
+			},
+		}, nil
+
+	case "--project":
+		if env.Config.ProjectPrompt == "" {
+			return &Result{
+				Message:    "There is no project prompt, set project_prompt in the config",
+				ClearInput: true,
+			}, nil
+		}
+
+		fn, text := filepath.Join(env.WorkingDir, env.Config.ProjectPrompt), ""
+		for _, l := range env.Session.PromptLayers() {
+			if l.Name == "project" {
+				fn, text = l.Source, l.Text
+			}
+		}
+
+		return &Result{
+			ClearInput: true,
+			Edit: &Edit{
+				Text: text,
+				Done: func(text string) string {
+					if err := os.WriteFile(fn, []byte(text), 0644); err != nil {
+						return fmt.Sprintf("Failed to save the project prompt: %v", err)
+					}
+					return fmt.Sprintf("Saved the project prompt to %s", fn)
+				},
+			},
+		}, nil
+
+	case "--global":
+		text := env.Config.SystemPrompt
+		if text == "" {
+			text = config.DefaultSystemPrompt()
+		}
+
+		return &Result{
+			ClearInput: true,
+			Edit: &Edit{
+				Text: text,
+				Done: func(text string) string {
+					text = strings.TrimSpace(text)
+					env.Config.SystemPrompt = text
+					if err := config.Set("system_prompt", text); err != nil {
+						return fmt.Sprintf("Failed to save the system prompt: %v", err)
+					}
+					return "Saved the system prompt to the config"
+				},
+			},
+		}, nil
+	}
+
+	return &Result{
+		Message:    "Usage: /system edit [--project|--global]",
+		ClearInput: true,
+	}, nil
+}
+
+// showEffectivePrompt lists the layers of the system prompt with their token
+// counts, followed by the prompt they make
+func showEffectivePrompt(env *Environment) (*Result, error) {
+	enc, err := tiktoken.GetEncoding("cl100k_base")
+	if err != nil {
+		return nil, err
+	}
+
+	layers := env.Session.PromptLayers()
+	used := map[string]bool{}
+	for _, l := range prompt.Effective(layers) {
+		used[l.Name] = true
+	}
+
+	style := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("205"))
+
+	var sb strings.Builder
+	sb.WriteString("System prompt layers:\n")
+	for _, l := range layers {
+		name := l.Name
+		if l.Source != "" {
+			name += " (" + l.Source + ")"
+		}
+		note := ""
+		if !used[l.Name] {
+			note = " (replaced)"
+		}
+		sb.WriteString(fmt.Sprintf("  %s: %5d tokens%s\n", style.Render(name), len(enc.Encode(l.Text, nil, nil)), note))
+	}
+
+	effective := env.Session.SystemPrompt()
+	sb.WriteString(fmt.Sprintf("  %s: %5d tokens\n\n", style.Render("Total"), len(enc.Encode(effective, nil, nil))))
+	sb.WriteString(effective)
+
+	return &Result{
+		Message:    sb.String(),
+		ClearInput: true,
+	}, nil
+}
+
 func exportHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
 	if len(args) == 0 {
 		return &Result{
@@ -509,7 +1288,14 @@ func exportHandler(ctx context.Context, args []string, env *Environment) (*Resul
 		}, nil
 	}
 
-	if err := json.NewEncoder(w).Encode(messages); err != nil {
+	title, summary := env.Session.Title()
+	export := struct {
+		Title    string       `json:"title,omitempty"`
+		Summary  string       `json:"summary,omitempty"`
+		Messages []ai.Message `json:"messages"`
+	}{title, summary, messages}
+
+	if err := json.NewEncoder(w).Encode(export); err != nil {
 		return &Result{
 			Message:    fmt.Sprintf("Failed to export: %v", err),
 			ClearInput: true,
@@ -522,6 +1308,55 @@ func exportHandler(ctx context.Context, args []string, env *Environment) (*Resul
 	}, nil
 }
 
+// shared is the server of the session while it is being shared
+var shared *share.Server
+
+func shareHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
+	if len(args) > 0 && args[0] == "stop" {
+		if shared == nil {
+			return &Result{Message: "The session isn't being shared", ClearInput: true}, nil
+		}
+		shared.Close()
+		shared = nil
+		return &Result{Message: "Stopped sharing the session", ClearInput: true}, nil
+	}
+
+	if env.Config.Share.PasteURL != "" {
+		link, err := share.Paste(env.Config.Share.PasteURL, env.Session)
+		if err != nil {
+			return &Result{
+				Message:    fmt.Sprintf("Failed to share: %v", err),
+				ClearInput: true,
+			}, nil
+		}
+		return &Result{
+			Message:    fmt.Sprintf("Shared a snapshot of the session at %s", link),
+			ClearInput: true,
+		}, nil
+	}
+
+	if shared == nil {
+		addr := env.Config.Share.Addr
+		if addr == "" {
+			addr = ":0"
+		}
+
+		srv, err := share.Listen(addr, env.Session)
+		if err != nil {
+			return &Result{
+				Message:    fmt.Sprintf("Failed to share: %v", err),
+				ClearInput: true,
+			}, nil
+		}
+		shared = srv
+	}
+
+	return &Result{
+		Message:    fmt.Sprintf("Anyone with this link can watch the session until you /share stop or exit:\n%s", shared.URL()),
+		ClearInput: true,
+	}, nil
+}
+
 func configHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
 	if len(args) == 0 {
 		// print entire config
@@ -565,6 +1400,204 @@ func thinkingHandler(ctx context.Context, args []string, env *Environment) (*Res
 	}, nil
 }
 
+func metaHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
+	var msg = "Enabled showing message metadata"
+	if env.Config.ShowMetadata {
+		msg = "Disabled showing message metadata"
+	}
+
+	env.Config.ShowMetadata = !env.Config.ShowMetadata
+
+	return &Result{
+		Message:    msg,
+		ClearInput: true,
+	}, nil
+}
+
+func styleHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
+	st := &env.Config.Style
+	usage := &Result{
+		Message:    "Usage: /style [language <name|off>|emoji|concise|comments|off]",
+		ClearInput: true,
+	}
+
+	if len(args) > 0 {
+		switch args[0] {
+		case "language":
+			if len(args) < 2 {
+				return usage, nil
+			}
+			st.Language = strings.Join(args[1:], " ")
+			if st.Language == "off" {
+				st.Language = ""
+			}
+		case "emoji":
+			st.NoEmoji = !st.NoEmoji
+		case "concise":
+			st.Concise = !st.Concise
+		case "comments":
+			st.CodeComments = !st.CodeComments
+		case "off":
+			*st = config.Style{}
+		default:
+			return usage, nil
+		}
+	}
+
+	onOff := func(on bool) string {
+		if on {
+			return "on"
+		}
+		return "off"
+	}
+	language := st.Language
+	if language == "" {
+		language = "any"
+	}
+
+	return &Result{
+		Message: fmt.Sprintf("Style: language %s, emoji %s, concise %s, code comments %s",
+			language, onOff(!st.NoEmoji), onOff(st.Concise), onOff(st.CodeComments)),
+		ClearInput: true,
+	}, nil
+}
+
+func deterministicHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
+	switch {
+	case len(args) == 0:
+		env.Config.Deterministic = !env.Config.Deterministic
+	case args[0] == "on":
+		env.Config.Deterministic = true
+	case args[0] == "off":
+		env.Config.Deterministic = false
+	default:
+		return &Result{Message: "Usage: /deterministic [on|off]", ClearInput: true}, nil
+	}
+
+	if !env.Config.Deterministic {
+		return &Result{Message: fmt.Sprintf("Deterministic mode is off, the temperature is back to %g", env.Config.Temperature), ClearInput: true}, nil
+	}
+
+	_, seed := env.Config.Sampling()
+	msg := fmt.Sprintf("Deterministic mode is on, the temperature is 0 and the seed is %d", *seed)
+	if env.Config.Provider == "anthropic" {
+		msg += "\nWARNING: the Anthropic API doesn't take a seed, so the responses can still vary a little"
+	}
+	return &Result{Message: msg, ClearInput: true}, nil
+}
+
+func constrainHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
+	usage := &Result{
+		Message:    "Usage: /constrain [json|schema <file|schema>|grammar <file|grammar>|off]",
+		ClearInput: true,
+	}
+
+	c := env.Config.Constrain
+	if len(args) > 0 {
+		switch {
+		case args[0] == "json":
+			c = config.Constrain{JSON: true}
+		case args[0] == "schema" && len(args) > 1:
+			c = config.Constrain{Schema: strings.Join(args[1:], " ")}
+		case args[0] == "grammar" && len(args) > 1:
+			c = config.Constrain{Grammar: strings.Join(args[1:], " ")}
+		case args[0] == "off":
+			c = config.Constrain{}
+		default:
+			return usage, nil
+		}
+
+		if err := c.Check(env.Config.Provider); err != nil {
+			return &Result{Message: "Can't constrain the responses: " + err.Error(), ClearInput: true}, nil
+		}
+		if _, _, err := c.Load(env.WorkingDir); err != nil {
+			return &Result{Message: "Can't constrain the responses: " + err.Error(), ClearInput: true}, nil
+		}
+		env.Config.Constrain = c
+	}
+
+	msg := "Responses are held to: " + c.String()
+	switch {
+	case !c.IsSet():
+	case env.Config.Provider == "anthropic":
+		msg += "\nWARNING: the Anthropic API doesn't take constraints, it is ignored"
+	case env.Config.Provider != "custom" && c.Grammar != "":
+		msg += "\nWARNING: only llama.cpp's server takes grammars, the " + env.Config.Provider + " provider ignores it, use a schema instead"
+	}
+
+	return &Result{Message: msg, ClearInput: true}, nil
+}
+
+func toolsHandler(ctx context.Context, args []string, env *Environment) (*Result, error) {
+	switch {
+	case len(args) == 1 && args[0] == "save":
+		if err := env.Session.SaveTools(); err != nil {
+			return &Result{Message: fmt.Sprintf("Failed to save the tools: %v", err), ClearInput: true}, nil
+		}
+		return &Result{Message: "Saved permitted_tools and disabled_tools to the config", ClearInput: true}, nil
+
+	case len(args) == 2:
+		if !slices.Contains(ToolStates, args[1]) {
+			break
+		}
+		if err := env.Session.SetToolState(args[0], args[1]); err != nil {
+			return &Result{Message: err.Error(), ClearInput: true}, nil
+		}
+		return &Result{Message: fmt.Sprintf("%s is now %s", args[0], args[1]), ClearInput: true}, nil
+
+	case len(args) == 0:
+		options := env.Session.ToolOptions()
+		width := 0
+		for _, o := range options {
+			width = max(width, len(o.Name))
+		}
+
+		var b strings.Builder
+		b.WriteString("Tools:\n")
+		for _, o := range options {
+			fmt.Fprintf(&b, "  %-*s  %-8s  %s\n", width, o.Name, o.State, o.Source)
+		}
+		return &Result{Message: b.String(), Tools: options, ClearInput: true}, nil
+	}
+
+	return &Result{
+		Message:    "Usage: /tools [<tool> ask|session|always|disabled|save]",
+		ClearInput: true,
+	}, nil
+}
+
+func pluginCommandHandler(pc tools.PluginCommand) HandlerFunc {
+	return func(ctx context.Context, args []string, env *Environment) (*Result, error) {
+		out, err := pc.Run(ctx, *env.Config, args, env.WorkingDir)
+		if err != nil {
+			out = strings.TrimSpace(out + "\n" + fmt.Sprintf("/%s failed: %v", pc.Name, err))
+		}
+
+		return &Result{
+			Message:    out,
+			ClearInput: true,
+		}, nil
+	}
+}
+
+// packCommandHandler sends the command's prompt to the main model, the
+// team's workflows shouldn't be answered by the cheap one
+func packCommandHandler(pc packs.Command) HandlerFunc {
+	return func(ctx context.Context, args []string, env *Environment) (*Result, error) {
+		if err := env.Session.Escalate(ctx, pc.Prompt(args)); err != nil {
+			return &Result{
+				Message:    fmt.Sprintf("/%s failed: %v", pc.Name, err),
+				ClearInput: true,
+			}, nil
+		}
+
+		return &Result{
+			Message:    fmt.Sprintf("Sending the /%s prompt from the %s pack...", pc.Name, pc.Pack),
+			ClearInput: true,
+		}, nil
+	}
+}
+
 func safeFilename(fn, cwd string) string {
 	fn = strings.ReplaceAll(fn, "../", "/")
 	fn = strings.ReplaceAll(fn, "./", "/")
@@ -0,0 +1,182 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/history"
+)
+
+// CompleteFunc returns the values that the last of the args could be, the
+// last arg is what has been typed of it so far and may be empty
+type CompleteFunc func(ctx context.Context, args []string, env *Environment) []string
+
+// Complete returns the command lines that the line could be completed to,
+// the command name is completed first and then its args
+func (r *Registry) Complete(ctx context.Context, line string, env *Environment) []string {
+	if !strings.HasPrefix(line, "/") || strings.Contains(line, "\n") {
+		return nil
+	}
+
+	fields := strings.Fields(line[1:])
+	if len(fields) == 0 || strings.HasSuffix(line, " ") {
+		fields = append(fields, "")
+	}
+
+	if len(fields) == 1 {
+		var matches []string
+		for _, cmd := range r.List() {
+			if strings.HasPrefix(cmd.Name, fields[0]) {
+				matches = append(matches, "/"+cmd.Name+" ")
+			}
+		}
+		sort.Strings(matches)
+		return matches
+	}
+
+	cmd, ok := r.Get(fields[0])
+	if !ok || cmd.Complete == nil {
+		return nil
+	}
+
+	args := fields[1:]
+	typed := args[len(args)-1]
+	head := "/" + strings.Join(fields[:len(fields)-1], " ") + " "
+
+	var matches []string
+	for _, c := range cmd.Complete(ctx, args, env) {
+		if !strings.HasPrefix(c, typed) {
+			continue
+		}
+		// directories are left open so the next tab goes into them
+		if !strings.HasSuffix(c, "/") {
+			c += " "
+		}
+		matches = append(matches, head+c)
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// completeArgs completes the nth arg from a fixed list, the others aren't
+// completed
+func completeArgs(n int, options ...string) CompleteFunc {
+	return func(ctx context.Context, args []string, env *Environment) []string {
+		if len(args) != n {
+			return nil
+		}
+		return options
+	}
+}
+
+func completeModels(ctx context.Context, args []string, env *Environment) []string {
+	if len(args) != 1 {
+		return nil
+	}
+	return env.Session.GetClient().ListModels()
+}
+
+func completeTools(ctx context.Context, args []string, env *Environment) []string {
+	switch len(args) {
+	case 1:
+		options := []string{"save"}
+		for _, o := range env.Session.ToolOptions() {
+			options = append(options, o.Name)
+		}
+		return options
+	case 2:
+		if args[0] != "save" {
+			return ToolStates
+		}
+	}
+	return nil
+}
+
+func completeBranches(ctx context.Context, args []string, env *Environment) []string {
+	if len(args) != 1 {
+		return nil
+	}
+	tree, err := env.Session.Branches()
+	if err != nil {
+		return nil
+	}
+
+	var ids []string
+	tree.Walk(func(n *history.Node, _ string) {
+		if n.ID != env.Session.ID() {
+			ids = append(ids, n.ID)
+		}
+	})
+	return ids
+}
+
+func completeConfigKeys(ctx context.Context, args []string, env *Environment) []string {
+	if len(args) != 1 {
+		return nil
+	}
+	return config.Keys()
+}
+
+func completeSystem(ctx context.Context, args []string, env *Environment) []string {
+	switch {
+	case len(args) == 1:
+		return []string{"--show-effective", "--reset", "edit"}
+	case len(args) == 2 && args[0] == "edit":
+		return []string{"--project", "--global"}
+	}
+	return nil
+}
+
+func completeConstrain(ctx context.Context, args []string, env *Environment) []string {
+	switch {
+	case len(args) == 1:
+		return []string{"json", "schema", "grammar", "off"}
+	case len(args) == 2 && (args[0] == "schema" || args[0] == "grammar"):
+		return completePaths(false)(ctx, args, env)
+	}
+	return nil
+}
+
+// completePaths completes the last arg with the files in the working dir,
+// or just the directories
+func completePaths(dirsOnly bool) CompleteFunc {
+	return func(ctx context.Context, args []string, env *Environment) []string {
+		typed := args[len(args)-1]
+		dir, base := filepath.Split(typed)
+
+		search := dir
+		if !filepath.IsAbs(dir) {
+			search = filepath.Join(env.WorkingDir, dir)
+		}
+		entries, err := os.ReadDir(search)
+		if err != nil {
+			return nil
+		}
+
+		var paths []string
+		for _, e := range entries {
+			// hidden files only when asked for
+			if strings.HasPrefix(e.Name(), ".") && !strings.HasPrefix(base, ".") {
+				continue
+			}
+			isDir := e.IsDir()
+			if e.Type()&os.ModeSymlink != 0 {
+				if fi, err := os.Stat(filepath.Join(search, e.Name())); err == nil {
+					isDir = fi.IsDir()
+				}
+			}
+
+			switch {
+			case isDir:
+				paths = append(paths, dir+e.Name()+"/")
+			case !dirsOnly:
+				paths = append(paths, dir+e.Name())
+			}
+		}
+		return paths
+	}
+}
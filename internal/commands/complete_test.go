@@ -0,0 +1,31 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComplete(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "src", "app"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "src", "main.go"), nil, 0644))
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, ".git"), 0755))
+
+	r := NewRegistry()
+	env := &Environment{WorkingDir: dir}
+	ctx := context.Background()
+
+	assert.Equal(t, []string{"/model ", "/model! ", "/models "}, r.Complete(ctx, "/mod", env))
+	assert.Equal(t, []string{"/budget ok "}, r.Complete(ctx, "/budget ", env))
+	assert.Equal(t, []string{"/sys edit --global ", "/sys edit --project "}, r.Complete(ctx, "/sys edit -", env))
+	assert.Equal(t, []string{"/config plugin_limits.timeout "}, r.Complete(ctx, "/config plugin_limits.ti", env))
+	assert.Equal(t, []string{"/cd src/"}, r.Complete(ctx, "/cd ", env))
+	assert.Equal(t, []string{"/cd src/app/"}, r.Complete(ctx, "/cd src/", env))
+	assert.Equal(t, []string{"/export src/app/", "/export src/main.go "}, r.Complete(ctx, "/export src/", env))
+	assert.Empty(t, r.Complete(ctx, "/copy ", env))
+	assert.Empty(t, r.Complete(ctx, "hello", env))
+}
@@ -0,0 +1,81 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+)
+
+// Attach shows the UI of the session at the socket on the terminal, until
+// it quits or the daemon stops.  The name is who the other terminals are
+// told is attaching.
+func Attach(path, name string, in, out *os.File) error {
+	w, h, err := term.GetSize(out.Fd())
+	if err != nil {
+		return fmt.Errorf("attaching needs a terminal: %w", err)
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	hello := Hello{
+		Op:        OpAttach,
+		User:      name,
+		Width:     w,
+		Height:    h,
+		Term:      os.Getenv("TERM"),
+		ColorTerm: os.Getenv("COLORTERM"),
+		Dark:      lipgloss.HasDarkBackground(),
+	}
+	if err := json.NewEncoder(conn).Encode(hello); err != nil {
+		return err
+	}
+
+	state, err := term.MakeRaw(in.Fd())
+	if err != nil {
+		return err
+	}
+	defer term.Restore(in.Fd(), state)
+
+	// the frames are written from the resizes and the keys
+	var mu sync.Mutex
+	send := func(kind byte, payload []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return writeFrame(conn, kind, payload)
+	}
+
+	stopResize := notifyResize(func() {
+		if w, h, err := term.GetSize(out.Fd()); err == nil {
+			send(frameResize, resizeFrame(Size{Width: w, Height: h}))
+		}
+	})
+	defer stopResize()
+
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := in.Read(buf)
+			if n > 0 {
+				if err := send(frameData, buf[:n]); err != nil {
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	_, err = io.Copy(out, conn)
+	return err
+}
@@ -0,0 +1,162 @@
+// Package daemon keeps a session running in the background so terminals can
+// attach to it over a unix socket.  The chat UI of each terminal runs in the
+// daemon, the client only passes the keys and the screen between it and the
+// terminal.
+package daemon
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/muesli/termenv"
+)
+
+// The things a client can ask the daemon for in its Hello
+const (
+	OpAttach = "attach" // show the UI on the client's terminal
+	OpInfo   = "info"   // what session the daemon is running
+	OpStop   = "stop"   // end the session and stop the daemon
+)
+
+// Hello is the first line a client sends, the terminal it describes is
+// what the UI is drawn for
+type Hello struct {
+	Op        string `json:"op"`
+	User      string `json:"user,omitempty"` // who is attaching, shown to the others
+	Width     int    `json:"width,omitempty"`
+	Height    int    `json:"height,omitempty"`
+	Term      string `json:"term,omitempty"`
+	ColorTerm string `json:"colorterm,omitempty"`
+	Dark      bool   `json:"dark,omitempty"` // the terminal has a dark background
+}
+
+// ColorProfile is the colors the client's terminal can show
+func (h Hello) ColorProfile() termenv.Profile {
+	switch {
+	case h.ColorTerm == "truecolor" || h.ColorTerm == "24bit":
+		return termenv.TrueColor
+	case strings.Contains(h.Term, "256color"):
+		return termenv.ANSI256
+	case h.Term == "" || h.Term == "dumb":
+		return termenv.Ascii
+	}
+	return termenv.ANSI
+}
+
+// Info is what the daemon answers OpInfo and OpStop with
+type Info struct {
+	ID      string    `json:"id"`
+	Dir     string    `json:"dir"`
+	Title   string    `json:"title,omitempty"`
+	Clients int       `json:"clients"`
+	Driver  string    `json:"driver,omitempty"` // who is driving the session
+	Started time.Time `json:"started"`
+}
+
+// Size is the size of the client's terminal
+type Size struct {
+	Width, Height int
+}
+
+// the frames the client sends after the Hello
+const (
+	frameData   = 'd' // what was typed
+	frameResize = 'r' // the terminal was resized, a uint16 width and height
+)
+
+// maxFrame is the most a frame can carry
+const maxFrame = 1<<16 - 1
+
+// Dir is where the sockets of the daemons are
+func Dir(stateDir string) string {
+	return filepath.Join(stateDir, "daemon")
+}
+
+// Socket is the socket of the daemon running the session
+func Socket(stateDir, id string) string {
+	return filepath.Join(Dir(stateDir), id+".sock")
+}
+
+// Running returns the sessions in the state dir that have a daemon running
+// them, sockets left behind by daemons that died are removed
+func Running(stateDir string) ([]Info, error) {
+	socks, err := filepath.Glob(filepath.Join(Dir(stateDir), "*.sock"))
+	if err != nil {
+		return nil, err
+	}
+
+	var running []Info
+	for _, sock := range socks {
+		info, err := Query(sock, OpInfo)
+		if err != nil {
+			removeStale(sock)
+			continue
+		}
+		running = append(running, info)
+	}
+	return running, nil
+}
+
+// Query asks the daemon at the socket for its info, OpStop stops it too
+func Query(path, op string) (Info, error) {
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err != nil {
+		return Info{}, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if err := json.NewEncoder(conn).Encode(Hello{Op: op}); err != nil {
+		return Info{}, err
+	}
+
+	var info Info
+	err = json.NewDecoder(conn).Decode(&info)
+	return info, err
+}
+
+func writeFrame(w io.Writer, kind byte, payload []byte) error {
+	if len(payload) > maxFrame {
+		return fmt.Errorf("frame of %d bytes is too big", len(payload))
+	}
+	frame := make([]byte, 3+len(payload))
+	frame[0] = kind
+	binary.BigEndian.PutUint16(frame[1:], uint16(len(payload)))
+	copy(frame[3:], payload)
+	_, err := w.Write(frame)
+	return err
+}
+
+func readFrame(r *bufio.Reader) (byte, []byte, error) {
+	var head [3]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(head[1:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return head[0], payload, nil
+}
+
+func resizeFrame(s Size) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint16(b, uint16(s.Width))
+	binary.BigEndian.PutUint16(b[2:], uint16(s.Height))
+	return b
+}
+
+func parseResize(b []byte) (Size, error) {
+	if len(b) != 4 {
+		return Size{}, errors.New("bad resize frame")
+	}
+	return Size{Width: int(binary.BigEndian.Uint16(b)), Height: int(binary.BigEndian.Uint16(b[2:]))}, nil
+}
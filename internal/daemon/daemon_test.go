@@ -0,0 +1,209 @@
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/muesli/termenv"
+	"github.com/penguinpowernz/clai/internal/ui"
+	"github.com/stretchr/testify/assert"
+)
+
+// observer is a UI or session that the hub gives a channel to
+type observer struct{ ch chan any }
+
+func (o *observer) Observe(events chan any) { o.ch = events }
+
+func receive(t *testing.T, ch chan any) any {
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("nothing was received")
+		return nil
+	}
+}
+
+func TestHub(t *testing.T) {
+	exited := make(chan struct{})
+	hub := NewHub(func() { close(exited) })
+
+	session := &observer{}
+	hub.AddObserver(session)
+	events := make(chan any)
+	hub.Observe(events)
+
+	a, b := hub.Join("alice"), hub.Join("alice")
+	uiA, uiB := &observer{}, &observer{}
+	a.AddObserver(uiA)
+	b.AddObserver(uiB)
+	outA := make(chan any)
+	a.Observe(outA)
+	assert.Equal(t, 2, hub.Clients())
+
+	// the first to join drives
+	assert.Equal(t, ui.EventRole{Driving: true, Driver: "alice"}, receive(t, uiA.ch))
+	assert.Equal(t, ui.EventSystemMsg("alice (2) attached to the session"), receive(t, uiA.ch))
+	assert.Equal(t, ui.EventRole{Driving: false, Driver: "alice"}, receive(t, uiB.ch))
+
+	// the session's events go to both
+	events <- ui.EventStreamChunk("hi")
+	assert.Equal(t, ui.EventStreamChunk("hi"), receive(t, uiA.ch))
+	assert.Equal(t, ui.EventStreamChunk("hi"), receive(t, uiB.ch))
+
+	// a prompt from one goes to the session and is shown in the other
+	outA <- ui.EventUserPrompt("hello")
+	assert.Equal(t, ui.EventUserPrompt("hello"), receive(t, session.ch))
+	assert.Equal(t, ui.EventPeerPrompt("hello"), receive(t, uiB.ch))
+
+	b.Leave()
+	assert.Equal(t, 1, hub.Clients())
+	assert.Equal(t, ui.EventSystemMsg("alice (2) detached from the session"), receive(t, uiA.ch))
+
+	events <- ui.EventSlashCommand{ShouldExit: true}
+	assert.Equal(t, ui.EventSlashCommand{ShouldExit: true}, receive(t, uiA.ch))
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Fatal("the hub didn't say the session ended")
+	}
+}
+
+func TestHubTakeover(t *testing.T) {
+	hub := NewHub(nil)
+	session := &observer{}
+	hub.AddObserver(session)
+
+	join := func(name string) (*Client, *observer, chan any) {
+		c := hub.Join(name)
+		o, out := &observer{}, make(chan any)
+		c.AddObserver(o)
+		c.Observe(out)
+		return c, o, out
+	}
+	a, uiA, outA := join("alice")
+	_, uiB, outB := join("bob")
+	receive(t, uiA.ch) // alice drives
+	receive(t, uiA.ch) // bob attached
+	assert.Equal(t, ui.EventRole{Driving: false, Driver: "alice"}, receive(t, uiB.ch))
+	assert.Equal(t, "alice", hub.Driver())
+
+	// bob can't send prompts while alice drives
+	outB <- ui.EventUserPrompt("rm -rf")
+	outA <- ui.EventUserPrompt("hello")
+	assert.Equal(t, ui.EventUserPrompt("hello"), receive(t, session.ch))
+	assert.Equal(t, ui.EventPeerPrompt("hello"), receive(t, uiB.ch))
+
+	// alice says no
+	outB <- ui.EventAskTakeover{}
+	assert.Equal(t, ui.EventTakeoverAsked("bob"), receive(t, uiA.ch))
+	outA <- ui.EventTakeoverAnswer(false)
+	assert.Equal(t, ui.EventSystemMsg("alice kept driving the session"), receive(t, uiB.ch))
+
+	// then yes
+	outB <- ui.EventAskTakeover{}
+	assert.Equal(t, ui.EventTakeoverAsked("bob"), receive(t, uiA.ch))
+	outA <- ui.EventTakeoverAnswer(true)
+	assert.Equal(t, ui.EventRole{Driving: false, Driver: "bob"}, receive(t, uiA.ch))
+	assert.Equal(t, ui.EventRole{Driving: true, Driver: "bob"}, receive(t, uiB.ch))
+	assert.Equal(t, "bob", hub.Driver())
+
+	// alice asks for it back, bob doesn't answer
+	hub.wait = 10 * time.Millisecond
+	outA <- ui.EventAskTakeover{}
+	assert.Equal(t, ui.EventTakeoverAsked("alice"), receive(t, uiB.ch))
+	assert.Equal(t, ui.EventRole{Driving: true, Driver: "alice"}, receive(t, uiA.ch))
+	assert.Equal(t, ui.EventRole{Driving: false, Driver: "alice"}, receive(t, uiB.ch))
+
+	// the driver leaving hands the session over to who is left
+	a.Leave()
+	assert.Equal(t, ui.EventSystemMsg("alice detached from the session"), receive(t, uiB.ch))
+	assert.Equal(t, ui.EventRole{Driving: true, Driver: "bob"}, receive(t, uiB.ch))
+	outB <- ui.EventUserPrompt("hi")
+	assert.Equal(t, ui.EventUserPrompt("hi"), receive(t, session.ch))
+
+	select {
+	case ev := <-session.ch:
+		t.Fatalf("the session got %#v", ev)
+	default:
+	}
+}
+
+type testHandler struct {
+	stopped bool
+	typed   chan string
+}
+
+func (h *testHandler) Info() Info { return Info{ID: "abc123", Dir: "/src"} }
+func (h *testHandler) Stop()      { h.stopped = true }
+
+func (h *testHandler) Attach(t *Terminal) error {
+	buf := make([]byte, 16)
+	n, _ := t.Read(buf)
+	h.typed <- fmt.Sprintf("%dx%d %s", t.Width, t.Height, buf[:n])
+	_, err := t.Write([]byte("bye"))
+	return err
+}
+
+func TestServer(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "abc123.sock")
+	srv, err := Listen(sock)
+	assert.NoError(t, err)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h := &testHandler{typed: make(chan string, 1)}
+	go srv.Serve(ctx, h)
+
+	info, err := Query(sock, OpInfo)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc123", info.ID)
+
+	running, err := Running(filepath.Dir(filepath.Dir(sock)))
+	assert.NoError(t, err)
+	assert.Empty(t, running, "the socket isn't in the daemon dir")
+
+	conn, err := net.Dial("unix", sock)
+	assert.NoError(t, err)
+	json.NewEncoder(conn).Encode(Hello{Op: OpAttach, Width: 80, Height: 24})
+	writeFrame(conn, frameData, []byte("ls\r"))
+	assert.Equal(t, "80x24 ls\r", <-h.typed)
+	out, _ := io.ReadAll(conn)
+	assert.Equal(t, "bye", string(out))
+	conn.Close()
+
+	_, err = Query(sock, OpStop)
+	assert.NoError(t, err)
+	assert.True(t, h.stopped)
+}
+
+func TestFrames(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, writeFrame(&buf, frameData, []byte("ls\r")))
+	assert.NoError(t, writeFrame(&buf, frameResize, resizeFrame(Size{Width: 120, Height: 40})))
+
+	r := bufio.NewReader(&buf)
+	kind, payload, err := readFrame(r)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(frameData), kind)
+	assert.Equal(t, "ls\r", string(payload))
+
+	kind, payload, err = readFrame(r)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(frameResize), kind)
+	size, err := parseResize(payload)
+	assert.NoError(t, err)
+	assert.Equal(t, Size{Width: 120, Height: 40}, size)
+
+	assert.Equal(t, termenv.Ascii, Hello{Term: "dumb"}.ColorProfile())
+	assert.Equal(t, termenv.ANSI256, Hello{Term: "xterm-256color"}.ColorProfile())
+}
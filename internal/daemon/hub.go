@@ -0,0 +1,347 @@
+package daemon
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/penguinpowernz/clai/internal/i18n"
+	"github.com/penguinpowernz/clai/internal/ui"
+)
+
+// takeoverWait is how long the driver has to answer a request to take over
+// before the session is handed over anyway, so a driver who walked away
+// doesn't lock everyone out
+const takeoverWait = 30 * time.Second
+
+// Hub lets the UIs of several terminals share the session, the session's
+// events go to all of them.  Only one of them drives the session, what the
+// others send is dropped until the driver hands it over to them.  Prompts
+// and answers to tool requests are passed on to the other UIs so they show
+// the same conversation.
+type Hub struct {
+	mu      sync.Mutex
+	out     chan any
+	clients map[*Client]bool
+	joined  int
+	onExit  func()
+
+	driver   *Client
+	asking   *Client // asked the driver to hand the session over
+	askTimer *time.Timer
+	wait     time.Duration // how long the driver has to answer
+}
+
+// NewHub creates a hub, onExit is called when the session is ended with
+// /exit
+func NewHub(onExit func()) *Hub {
+	return &Hub{
+		out:     make(chan any, ui.EventBuffer),
+		clients: make(map[*Client]bool),
+		onExit:  onExit,
+		wait:    takeoverWait,
+	}
+}
+
+// AddObserver gives the session what the UIs send
+func (h *Hub) AddObserver(observer ui.UIObserver) {
+	observer.Observe(h.out)
+}
+
+// Observe passes the session's events on to the UIs
+func (h *Hub) Observe(events chan any) {
+	go func() {
+		for ev := range events {
+			h.Broadcast(ev)
+			if res, ok := ev.(ui.EventSlashCommand); ok && res.ShouldExit && h.onExit != nil {
+				h.onExit()
+			}
+		}
+	}()
+}
+
+// Broadcast sends the event to all the UIs
+func (h *Hub) Broadcast(ev any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		c.push(ev)
+	}
+}
+
+// Clients is how many UIs are attached
+func (h *Hub) Clients() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+// Driver is the name of the UI driving the session
+func (h *Hub) Driver() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.driver == nil {
+		return ""
+	}
+	return h.driver.name
+}
+
+// Join adds the UI of the named user to the hub, it must Leave when it
+// quits.  The first UI to join drives the session, the rest watch.
+func (h *Hub) Join(name string) *Client {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.joined++
+	c := &Client{
+		hub:   h,
+		name:  h.uniqueName(name),
+		seq:   h.joined,
+		in:    make(chan any),
+		ready: make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+	go c.deliver()
+
+	h.clients[c] = true
+	if h.driver == nil {
+		h.driver = c
+	}
+	c.push(ui.EventRole{Driving: h.driver == c, Driver: h.driver.name})
+	h.tellOthers(c, ui.EventSystemMsg(i18n.T("chat.peer_joined", c.name)))
+	log.Printf("[daemon] %s joined, %s is driving", c.name, h.driver.name)
+	return c
+}
+
+// uniqueName tells apart the UIs of the same user
+func (h *Hub) uniqueName(name string) string {
+	if name == "" {
+		name = fmt.Sprintf("terminal %d", h.joined)
+	}
+	for c := range h.clients {
+		if c.name == name {
+			return fmt.Sprintf("%s (%d)", name, h.joined)
+		}
+	}
+	return name
+}
+
+// send passes what a UI sent to the session, and to the other UIs if they
+// need to know about it
+func (h *Hub) send(from *Client, ev any) {
+	switch ev := ev.(type) {
+	case ui.EventAskTakeover:
+		h.askTakeover(from)
+		return
+	case ui.EventTakeoverAnswer:
+		h.answerTakeover(from, bool(ev))
+		return
+	}
+
+	h.mu.Lock()
+	driving := h.driver == from
+	h.mu.Unlock()
+	if !driving {
+		log.Printf("[daemon] dropped %T from %s, they aren't driving", ev, from.name)
+		return
+	}
+
+	h.out <- ev
+
+	var peer any
+	switch ev := ev.(type) {
+	case ui.EventUserPrompt:
+		peer = ui.EventPeerPrompt(ev)
+	case ui.EventPermitToolUse:
+		peer = ui.EventPeerAnswered(ev)
+	case ui.EventPermitToolUseThisSession:
+		peer = ui.EventPeerAnswered(ev)
+	case ui.EventCancelToolUse:
+		peer = ui.EventPeerAnswered(ev)
+	default:
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.tellOthers(from, peer)
+}
+
+// askTakeover asks the driver to hand the session over to the UI, if they
+// don't answer in time it is handed over anyway
+func (h *Hub) askTakeover(from *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch {
+	case h.driver == from || !h.clients[from]:
+		return
+	case h.asking != nil && h.asking != from:
+		from.push(ui.EventSystemMsg(i18n.T("chat.takeover_busy", h.asking.name)))
+		return
+	case h.asking == from:
+		return
+	}
+
+	h.asking = from
+	h.driver.push(ui.EventTakeoverAsked(from.name))
+	h.askTimer = time.AfterFunc(h.wait, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if h.asking == from {
+			log.Printf("[daemon] %s didn't answer, handing over to %s", h.driver.name, from.name)
+			h.handOver(from)
+		}
+	})
+}
+
+// answerTakeover hands the session over to the UI that asked for it if the
+// driver said so
+func (h *Hub) answerTakeover(from *Client, allow bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.driver != from || h.asking == nil {
+		return
+	}
+	if allow {
+		h.handOver(h.asking)
+		return
+	}
+
+	h.asking.push(ui.EventSystemMsg(i18n.T("chat.takeover_denied", h.driver.name)))
+	h.stopAsking()
+}
+
+// handOver makes the UI the driver and tells them all who it is, the hub
+// must be locked
+func (h *Hub) handOver(to *Client) {
+	h.stopAsking()
+	h.driver = to
+	for c := range h.clients {
+		c.push(ui.EventRole{Driving: c == to, Driver: to.name})
+	}
+}
+
+// stopAsking forgets the request to take over, the hub must be locked
+func (h *Hub) stopAsking() {
+	h.asking = nil
+	if h.askTimer != nil {
+		h.askTimer.Stop()
+		h.askTimer = nil
+	}
+}
+
+// tellOthers sends the event to all the UIs but one, the hub must be locked
+func (h *Hub) tellOthers(from *Client, ev any) {
+	for c := range h.clients {
+		if c != from {
+			c.push(ev)
+		}
+	}
+}
+
+// Client is a UI in the hub.  Its events are queued so that a UI that isn't
+// listening yet, or is slow to redraw, doesn't hold up the others.
+type Client struct {
+	hub    *Hub
+	name   string
+	seq    int // the order it joined in
+	in     chan any
+	mu     sync.Mutex
+	queue  []any
+	ready  chan struct{}
+	done   chan struct{}
+	leaves sync.Once
+}
+
+// AddObserver gives the UI the session's events
+func (c *Client) AddObserver(observer ui.UIObserver) {
+	observer.Observe(c.in)
+}
+
+// Observe passes what the UI sends to the session
+func (c *Client) Observe(events chan any) {
+	go func() {
+		for {
+			select {
+			case ev := <-events:
+				c.hub.send(c, ev)
+			case <-c.done:
+				return
+			}
+		}
+	}()
+}
+
+// Leave removes the UI from the hub, if it was driving the UI that has been
+// attached the longest takes over
+func (c *Client) Leave() {
+	c.leaves.Do(func() {
+		h := c.hub
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		delete(h.clients, c)
+		close(c.done)
+		h.tellOthers(c, ui.EventSystemMsg(i18n.T("chat.peer_left", c.name)))
+
+		switch {
+		case h.driver == c:
+			h.driver = nil
+			var next *Client
+			for other := range h.clients {
+				if next == nil || other.seq < next.seq {
+					next = other
+				}
+			}
+			if next == nil {
+				h.stopAsking()
+				return
+			}
+			log.Printf("[daemon] %s left, handing over to %s", c.name, next.name)
+			h.handOver(next)
+
+		case h.asking == c:
+			h.stopAsking()
+			h.driver.push(ui.EventTakeoverAsked(""))
+		}
+	})
+}
+
+func (c *Client) push(ev any) {
+	c.mu.Lock()
+	c.queue = append(c.queue, ev)
+	c.mu.Unlock()
+
+	select {
+	case c.ready <- struct{}{}:
+	default:
+	}
+}
+
+// deliver sends the queued events to the UI in order
+func (c *Client) deliver() {
+	for {
+		c.mu.Lock()
+		if len(c.queue) == 0 {
+			c.mu.Unlock()
+			select {
+			case <-c.ready:
+				continue
+			case <-c.done:
+				return
+			}
+		}
+		ev := c.queue[0]
+		c.queue = c.queue[1:]
+		c.mu.Unlock()
+
+		select {
+		case c.in <- ev:
+		case <-c.done:
+			return
+		}
+	}
+}
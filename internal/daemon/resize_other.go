@@ -0,0 +1,9 @@
+//go:build !unix
+
+package daemon
+
+// notifyResize does nothing where there is no SIGWINCH, the UI keeps the
+// size the terminal had when it was attached
+func notifyResize(resized func()) func() {
+	return func() {}
+}
@@ -0,0 +1,26 @@
+//go:build unix
+
+package daemon
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyResize calls resized when the terminal changes size, until the
+// returned func is called
+func notifyResize(resized func()) func() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGWINCH)
+	go func() {
+		for range sig {
+			resized()
+		}
+	}()
+
+	return func() {
+		signal.Stop(sig)
+		close(sig)
+	}
+}
@@ -0,0 +1,193 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Handler is the session the daemon is running
+type Handler interface {
+	Info() Info
+	// Attach shows the UI on the terminal until it quits or the terminal
+	// goes away
+	Attach(t *Terminal) error
+	Stop()
+}
+
+// Server accepts the clients of the daemon on a unix socket
+type Server struct {
+	path string
+	ln   net.Listener
+	wg   sync.WaitGroup
+}
+
+// Listen starts listening on the unix socket at path, only the user can
+// connect to it
+func Listen(path string) (*Server, error) {
+	// clean up a socket left behind by a daemon that died
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is already in use", path)
+		}
+		os.Remove(path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	return &Server{path: path, ln: ln}, nil
+}
+
+// Serve handles the clients until the context is done, the terminals that
+// are still attached are waited for
+func (s *Server) Serve(ctx context.Context, h Handler) error {
+	go func() {
+		<-ctx.Done()
+		s.ln.Close()
+	}()
+
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				s.wg.Wait()
+				return nil
+			}
+			return err
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			if err := serveConn(conn, h); err != nil && !errors.Is(err, io.EOF) {
+				log.Println("[daemon] client failed:", err)
+			}
+		}()
+	}
+}
+
+// Close stops listening and removes the socket
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	os.Remove(s.path)
+	return err
+}
+
+func serveConn(conn net.Conn, h Handler) error {
+	r := bufio.NewReader(conn)
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return err
+	}
+
+	var hello Hello
+	if err := json.Unmarshal(line, &hello); err != nil {
+		return fmt.Errorf("bad hello: %w", err)
+	}
+
+	switch hello.Op {
+	case OpInfo:
+		return json.NewEncoder(conn).Encode(h.Info())
+
+	case OpStop:
+		info := h.Info()
+		h.Stop()
+		return json.NewEncoder(conn).Encode(info)
+
+	case OpAttach:
+		log.Printf("[daemon] attaching a %dx%d terminal for %q", hello.Width, hello.Height, hello.User)
+		t := newTerminal(conn, hello)
+		go t.readFrames(r)
+		err := h.Attach(t)
+		log.Println("[daemon] detached a terminal")
+		return err
+	}
+
+	return fmt.Errorf("unknown op %q", hello.Op)
+}
+
+// Terminal is a client's terminal as the UI sees it, what is read from it
+// is what was typed
+type Terminal struct {
+	Hello
+
+	conn  net.Conn
+	in    *io.PipeReader
+	typed *io.PipeWriter
+	sizes chan Size
+}
+
+func newTerminal(conn net.Conn, hello Hello) *Terminal {
+	pr, pw := io.Pipe()
+	return &Terminal{
+		Hello: hello,
+		conn:  conn,
+		in:    pr,
+		typed: pw,
+		sizes: make(chan Size, 1),
+	}
+}
+
+func (t *Terminal) Read(p []byte) (int, error)  { return t.in.Read(p) }
+func (t *Terminal) Write(p []byte) (int, error) { return t.conn.Write(p) }
+
+// Sizes are the sizes the terminal is resized to, it is closed when the
+// client goes away
+func (t *Terminal) Sizes() <-chan Size {
+	return t.sizes
+}
+
+func (t *Terminal) readFrames(r *bufio.Reader) {
+	defer close(t.sizes)
+	defer t.typed.Close()
+
+	for {
+		kind, payload, err := readFrame(r)
+		if err != nil {
+			return
+		}
+
+		switch kind {
+		case frameData:
+			if _, err := t.typed.Write(payload); err != nil {
+				return
+			}
+		case frameResize:
+			size, err := parseResize(payload)
+			if err != nil {
+				log.Println("[daemon]", err)
+				continue
+			}
+			t.sizes <- size
+		}
+	}
+}
+
+// removeStale removes a socket nothing is listening on
+func removeStale(path string) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return
+	}
+	os.Remove(path)
+}
@@ -0,0 +1,262 @@
+// Package editor serves a JSON-RPC API on a unix socket so that editor
+// plugins can talk to a running clai session
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/files"
+)
+
+// Session is the part of the chat session that the editor can see
+type Session interface {
+	ID() string
+	Title() (title, summary string)
+	Export() []ai.Message
+	HistoryPath() string
+	WorkingDir() string
+}
+
+// Server accepts editor connections on a unix socket
+type Server struct {
+	path string
+	ln   net.Listener
+	rpc  *rpc.Server
+}
+
+// Listen starts listening on the unix socket at path, prompts from the editor
+// are passed to send so they can be shown in the UI
+func Listen(path string, cfg *config.Config, sess Session, send func(prompt string)) (*Server, error) {
+	// clean up a socket left behind by a previous run
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is already in use", path)
+		}
+		os.Remove(path)
+	}
+
+	// the socket gives access to the session, so only the user can use it
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("Editor", &Service{cfg: cfg, sess: sess, send: send}); err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	return &Server{path: path, ln: ln, rpc: srv}, nil
+}
+
+// Serve handles connections until the server is closed
+func (s *Server) Serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Println("[editor] failed to accept connection:", err)
+			}
+			return
+		}
+
+		log.Println("[editor] editor connected")
+		go s.rpc.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
+
+// Close stops the server and removes the socket
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	os.Remove(s.path)
+	return err
+}
+
+// Service holds the methods that editors can call, they are called as
+// "Editor.<Method>" using JSON-RPC 1.0
+type Service struct {
+	cfg  *config.Config
+	sess Session
+	send func(prompt string)
+}
+
+// SelectionArgs is a selection in the editor, lines are 1 based and inclusive
+type SelectionArgs struct {
+	File      string `json:"file"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Text      string `json:"text"`
+	Prompt    string `json:"prompt"`
+}
+
+// SendSelection sends a prompt about the selected text to the session, the
+// file is mentioned so its content is added to the context
+func (s *Service) SendSelection(args *SelectionArgs, reply *bool) error {
+	if strings.TrimSpace(args.Prompt) == "" {
+		return fmt.Errorf("prompt is required")
+	}
+
+	prompt := args.Prompt
+	if args.File != "" {
+		where := args.File
+		if rel, err := s.relPath(args.File); err == nil {
+			where = "@" + rel
+		}
+		prompt += fmt.Sprintf("\n\nThis is about lines %d-%d of %s", args.StartLine, args.EndLine, where)
+	}
+
+	if args.Text != "" {
+		prompt += ":\n```\n" + strings.TrimRight(args.Text, "\n") + "\n```"
+	}
+
+	s.send(prompt)
+	*reply = true
+	return nil
+}
+
+// EditArgs says where to put the code from the last response, when the lines
+// are not given the whole file is replaced
+type EditArgs struct {
+	File      string `json:"file"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	DryRun    bool   `json:"dry_run"`
+}
+
+// EditReply is the content of the file after the edit
+type EditReply struct {
+	Content string `json:"content"`
+}
+
+var reCodeBlock = regexp.MustCompile("(?s)```[^\n]*\n(.*?)```")
+
+// ApplyEdits replaces the lines in the file with the last code block from the
+// latest response, with dry_run the file isn't written so the editor can
+// apply the edit itself
+func (s *Service) ApplyEdits(args *EditArgs, reply *EditReply) error {
+	code, err := s.lastCodeBlock()
+	if err != nil {
+		return err
+	}
+
+	rel, err := s.relPath(args.File)
+	if err != nil {
+		return err
+	}
+
+	fn := filepath.Join(s.sess.WorkingDir(), rel)
+	if err := files.CheckAccess(*s.cfg, s.sess.WorkingDir(), rel); err != nil {
+		return fmt.Errorf("%s: %w", rel, err)
+	}
+
+	content := code
+	if args.StartLine > 0 {
+		data, err := os.ReadFile(fn)
+		if err != nil {
+			return err
+		}
+
+		content, err = replaceLines(string(data), args.StartLine, args.EndLine, code)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !args.DryRun {
+		log.Println("[editor] applying edit to", fn)
+		if err := os.WriteFile(fn, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+
+	reply.Content = content
+	return nil
+}
+
+// TranscriptReply is the conversation so far
+type TranscriptReply struct {
+	SessionID string       `json:"session_id"`
+	Title     string       `json:"title,omitempty"`
+	Summary   string       `json:"summary,omitempty"`
+	Path      string       `json:"path"`
+	Messages  []ai.Message `json:"messages"`
+}
+
+// OpenTranscript returns the conversation and the file it is saved in
+func (s *Service) OpenTranscript(args *struct{}, reply *TranscriptReply) error {
+	reply.SessionID = s.sess.ID()
+	reply.Title, reply.Summary = s.sess.Title()
+	reply.Messages = s.sess.Export()
+	reply.Path = s.sess.HistoryPath()
+	return nil
+}
+
+// relPath makes the path relative to the working dir, paths outside of it
+// aren't allowed
+func (s *Service) relPath(path string) (string, error) {
+	wd := s.sess.WorkingDir()
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(wd, path)
+	}
+
+	rel, err := filepath.Rel(wd, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("%s is outside of the working directory %s", path, wd)
+	}
+	return rel, nil
+}
+
+func (s *Service) lastCodeBlock() (string, error) {
+	msgs := s.sess.Export()
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role != "assistant" {
+			continue
+		}
+
+		blocks := reCodeBlock.FindAllStringSubmatch(msgs[i].Content, -1)
+		if len(blocks) == 0 {
+			return "", fmt.Errorf("the last response has no code in it")
+		}
+		return blocks[len(blocks)-1][1], nil
+	}
+
+	return "", fmt.Errorf("there is no response yet")
+}
+
+// replaceLines replaces the lines from start to end (1 based, inclusive)
+// with the text
+func replaceLines(content string, start, end int, text string) (string, error) {
+	lines := strings.SplitAfter(content, "\n")
+	if end < start {
+		end = start
+	}
+	if start < 1 || end > len(lines) {
+		return "", fmt.Errorf("lines %d-%d are out of range", start, end)
+	}
+
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+
+	return strings.Join(lines[:start-1], "") + text + strings.Join(lines[end:], ""), nil
+}
@@ -0,0 +1,84 @@
+package editor
+
+import (
+	"net/rpc/jsonrpc"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/stretchr/testify/assert"
+)
+
+type testSession struct {
+	wd       string
+	messages []ai.Message
+}
+
+func (s testSession) ID() string              { return "abc123" }
+func (s testSession) Export() []ai.Message    { return s.messages }
+func (s testSession) WorkingDir() string      { return s.wd }
+func (s testSession) Title() (string, string) { return "", "" }
+func (s testSession) HistoryPath() string     { return "" }
+
+func TestReplaceLines(t *testing.T) {
+	out, err := replaceLines("a\nb\nc\nd\n", 2, 3, "x")
+	assert.NoError(t, err)
+	assert.Equal(t, "a\nx\nd\n", out)
+
+	out, err = replaceLines("a\nb\n", 1, 0, "x\ny\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "x\ny\nb\n", out)
+
+	_, err = replaceLines("a\nb\n", 2, 9, "x")
+	assert.Error(t, err)
+}
+
+func TestServer(t *testing.T) {
+	wd := t.TempDir()
+	os.WriteFile(filepath.Join(wd, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644)
+
+	sess := testSession{wd: wd, messages: []ai.Message{
+		{Role: "user", Content: "fix it"},
+		{Role: "assistant", Content: "Here:\n```go\nfunc main() { println(1) }\n```\n"},
+	}}
+
+	var sent string
+	sock := filepath.Join(wd, "clai.sock")
+	srv, err := Listen(sock, config.Default(), sess, func(prompt string) { sent = prompt })
+	assert.NoError(t, err)
+	defer srv.Close()
+	go srv.Serve()
+
+	info, err := os.Stat(sock)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	client, err := jsonrpc.Dial("unix", sock)
+	assert.NoError(t, err)
+	defer client.Close()
+
+	var ok bool
+	err = client.Call("Editor.SendSelection", SelectionArgs{File: filepath.Join(wd, "main.go"), StartLine: 3, EndLine: 3, Text: "func main() {}", Prompt: "add a print"}, &ok)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Contains(t, sent, "lines 3-3 of @main.go")
+
+	var edit EditReply
+	err = client.Call("Editor.ApplyEdits", EditArgs{File: "main.go", StartLine: 3, EndLine: 3}, &edit)
+	assert.NoError(t, err)
+	assert.Equal(t, "package main\n\nfunc main() { println(1) }\n", edit.Content)
+
+	data, _ := os.ReadFile(filepath.Join(wd, "main.go"))
+	assert.Equal(t, edit.Content, string(data))
+
+	err = client.Call("Editor.ApplyEdits", EditArgs{File: "../outside.go"}, &edit)
+	assert.Error(t, err)
+
+	var transcript TranscriptReply
+	err = client.Call("Editor.OpenTranscript", struct{}{}, &transcript)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc123", transcript.SessionID)
+	assert.Len(t, transcript.Messages, 2)
+}
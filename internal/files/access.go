@@ -0,0 +1,96 @@
+package files
+
+import (
+	"errors"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/penguinpowernz/clai/config"
+)
+
+// The reasons a file can't be read into the conversation, by a tool, /add or
+// an @ mention
+var (
+	ErrOutside    = errors.New("access denied: path outside working directory")
+	ErrOutOfScope = errors.New("access denied: path outside of scope")
+	ErrExcluded   = errors.New("file matches exclude pattern")
+	ErrSensitive  = errors.New("the file may hold secrets, set allow_sensitive in the project's .clai.yml to use it")
+)
+
+// CheckAccess returns why the file can't be read into the conversation, nil
+// if it can.  Every way of reading files uses it, so they all keep to the
+// same rules.  Hidden files can still be read when they are asked for by name.
+func CheckAccess(cfg config.Config, workingDir, path string) error {
+	switch {
+	case !Confined(workingDir, path):
+		return ErrOutside
+	case !InScope(cfg, workingDir, path):
+		return ErrOutOfScope
+	case IsExcluded(cfg, workingDir, path):
+		return ErrExcluded
+	case IsSensitive(cfg, workingDir, path):
+		return ErrSensitive
+	}
+	return nil
+}
+
+// Confined reports whether the path is the working dir or beneath it, a
+// relative path is resolved against the working dir
+func Confined(workingDir, path string) bool {
+	rel, err := filepath.Rel(absPath(workingDir, "."), absPath(workingDir, path))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// IsExcluded reports whether the path matches one of the exclude patterns.  A
+// pattern with a / in the middle is matched against the start of the path
+// from the working dir, the others against each part of it, so vendor/ leaves
+// out everything in a vendor dir and *.log every log.  Paths outside of the
+// working dir are always excluded.
+func IsExcluded(cfg config.Config, workingDir, p string) bool {
+	if !Confined(workingDir, p) {
+		return true
+	}
+
+	rel, _ := filepath.Rel(absPath(workingDir, "."), absPath(workingDir, p))
+	rel = filepath.ToSlash(rel)
+	parts := strings.Split(rel, "/")
+	for _, pattern := range cfg.ExcludePatterns {
+		pattern = strings.Trim(filepath.ToSlash(pattern), "/")
+		if strings.Contains(pattern, "/") {
+			if matched, _ := path.Match(pattern, rel); matched || strings.HasPrefix(rel, pattern+"/") {
+				return true
+			}
+			continue
+		}
+		for _, part := range parts {
+			if matched, _ := path.Match(pattern, part); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsHidden reports whether the path is a dotfile or inside a dot directory
+// beneath the working dir, unless the config includes hidden files.  Files
+// that may hold secrets are always hidden.
+func IsHidden(cfg config.Config, workingDir, path string) bool {
+	if IsSensitive(cfg, workingDir, path) {
+		return true
+	}
+	if cfg.IncludeHidden {
+		return false
+	}
+
+	rel, err := filepath.Rel(absPath(workingDir, "."), absPath(workingDir, path))
+	if err != nil {
+		return false
+	}
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		if len(part) > 1 && part[0] == '.' && part != ".." {
+			return true
+		}
+	}
+	return false
+}
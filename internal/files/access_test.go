@@ -0,0 +1,63 @@
+package files
+
+import (
+	"testing"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsExcluded(t *testing.T) {
+	cfg := config.Default()
+	cfg.ExcludePatterns = []string{
+		"node_modules/",
+		".git/",
+		"*.log",
+		"*.tmp",
+		"vendor/",
+		"dist/",
+		"build/",
+		"docs/generated",
+	}
+
+	assert.False(t, IsExcluded(*cfg, "/repo", "internal/tool/tools.go"))
+	assert.False(t, IsExcluded(*cfg, "/repo", "main.go"))
+	assert.True(t, IsExcluded(*cfg, "/repo", "vendor/modules.txt"))
+	assert.True(t, IsExcluded(*cfg, "/repo", "test.log"))
+	assert.True(t, IsExcluded(*cfg, "/repo", "logs/test.log"))
+	assert.True(t, IsExcluded(*cfg, "/repo", "docs/generated/api.md"))
+	assert.True(t, IsExcluded(*cfg, "/repo", "/repo/.git/config"))
+	assert.True(t, IsExcluded(*cfg, "/repo", "/etc/passwd"))
+	assert.True(t, IsExcluded(*cfg, "/repo", "../etc/passwd"))
+
+	// patterns match whole parts of the path, not any text in it
+	assert.False(t, IsExcluded(*cfg, "/repo", ".github/workflows/ci.yml"))
+	assert.False(t, IsExcluded(*cfg, "/repo", "internal/vendorlib/x.go"))
+	assert.False(t, IsExcluded(*cfg, "/repo", "docs/guide.md"))
+	assert.False(t, IsExcluded(*cfg, "/home/build/repo", "/home/build/repo/main.go"))
+}
+
+func TestIsHidden(t *testing.T) {
+	cfg := config.Default()
+	assert.False(t, IsHidden(*cfg, "/src/.work/app", "main.go"))
+	assert.False(t, IsHidden(*cfg, "/src/.work/app", "./cmd/main.go"))
+	assert.True(t, IsHidden(*cfg, "/src/.work/app", ".env"))
+	assert.True(t, IsHidden(*cfg, "/src/.work/app", "/src/.work/app/.github/workflows/ci.yml"))
+	assert.True(t, IsHidden(*cfg, ".", "./.git"))
+
+	cfg.IncludeHidden = true
+	assert.False(t, IsHidden(*cfg, ".", ".editorconfig"))
+	assert.True(t, IsHidden(*cfg, ".", ".env"))
+}
+
+func TestCheckAccess(t *testing.T) {
+	cfg := config.Default()
+	cfg.Scope = []string{"/repo/api"}
+
+	assert.NoError(t, CheckAccess(*cfg, "/repo", "api/main.go"))
+	assert.NoError(t, CheckAccess(*cfg, "/repo", "api/.golangci.yml"), "hidden files can be read by name")
+	assert.Equal(t, ErrOutside, CheckAccess(*cfg, "/repo", "../etc/passwd"))
+	assert.Equal(t, ErrOutOfScope, CheckAccess(*cfg, "/repo", "web/main.go"))
+	assert.Equal(t, ErrExcluded, CheckAccess(*cfg, "/repo", "api/vendor/x.go"))
+	assert.Equal(t, ErrSensitive, CheckAccess(*cfg, "/repo", "api/.env"))
+}
@@ -1,14 +1,21 @@
 package files
 
 import (
+	"cmp"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/penguinpowernz/clai/config"
 )
 
+// includeOverhead is about how many tokens the header and fence of a file
+// take, so an outline can be cut short enough to fit with them
+const includeOverhead = 64
+
 // Context manages file context for AI requests
 type Context struct {
 	config     *config.Config
@@ -16,6 +23,7 @@ type Context struct {
 	files      map[string]*File
 	gitRepo    string
 	totalSize  int64
+	added      int // how many files have been added, to tell which were added last
 }
 
 // File represents a single file in context
@@ -25,6 +33,8 @@ type File struct {
 	Size         int64
 	Language     string
 	LastModified int64
+
+	added int // when it was added, the higher the later
 }
 
 // NewContext creates a new file context manager
@@ -46,6 +56,14 @@ func (c *Context) AddFile(path string) error {
 		return fmt.Errorf("failed to resolve path: %w", err)
 	}
 
+	if err := CheckAccess(*c.config, c.workingDir, absPath); err != nil {
+		return err
+	}
+
+	if IsHidden(*c.config, c.workingDir, absPath) {
+		return fmt.Errorf("file is hidden, set include_hidden to add it")
+	}
+
 	// Check if file exists
 	info, err := os.Stat(absPath)
 	if err != nil {
@@ -57,11 +75,6 @@ func (c *Context) AddFile(path string) error {
 		return fmt.Errorf("file too large: %d bytes (max: %d)", info.Size(), c.config.MaxFileSize)
 	}
 
-	// Check if excluded
-	if c.isExcluded(absPath) {
-		return fmt.Errorf("file matches exclude pattern")
-	}
-
 	// Read file
 	content, err := os.ReadFile(absPath)
 	if err != nil {
@@ -69,14 +82,21 @@ func (c *Context) AddFile(path string) error {
 	}
 
 	// Detect language
-	lang := detectLanguage(absPath)
+	lang := DetectLanguage(absPath, content)
+
+	// adding it again updates it
+	if old, ok := c.files[absPath]; ok {
+		c.totalSize -= old.Size
+	}
 
+	c.added++
 	c.files[absPath] = &File{
 		Path:         absPath,
 		Content:      string(content),
 		Size:         info.Size(),
 		Language:     lang,
 		LastModified: info.ModTime().Unix(),
+		added:        c.added,
 	}
 
 	c.totalSize += info.Size()
@@ -102,27 +122,97 @@ func (c *Context) GetFiles() []*File {
 	return files
 }
 
-// BuildPrompt builds a prompt with file context
-func (c *Context) BuildPrompt(userMessage string) string {
+// BuildPrompt builds a prompt with the files in the context that fit in the
+// budget of tokens, along with the message.  The files mentioned in the
+// message go first and then the ones changed or added most recently, a file
+// that doesn't fit whole is shown as an outline if that fits.  The files that
+// were left out are returned and the model is told about them.  A budget of
+// 0 includes every file.
+func (c *Context) BuildPrompt(userMessage string, budget int) (string, []string) {
+	if len(c.files) == 0 {
+		return userMessage, nil
+	}
+
+	remaining := budget - estimateTokens(userMessage)
 	var sb strings.Builder
+	var omitted []string
 
-	// Add file context
-	if len(c.files) > 0 {
-		sb.WriteString("Here are the relevant files:\n\n")
+	for _, file := range c.rank(userMessage) {
+		relPath := c.relPath(file.Path)
+
+		limit := c.config.MaxFileTokens
+		if budget > 0 && (limit <= 0 || remaining < limit) {
+			limit = max(remaining, 1)
+		}
 
-		for _, file := range c.files {
-			relPath, _ := filepath.Rel(c.workingDir, file.Path)
-			sb.WriteString(fmt.Sprintf("--- %s ---\n", relPath))
-			sb.WriteString(fmt.Sprintf("```%s\n", file.Language))
-			sb.WriteString(file.Content)
-			sb.WriteString("\n```\n\n")
+		part := fmt.Sprintf("--- %s ---\n", relPath) + Include(relPath, file.Language, file.Content, limit) + "\n\n"
+		if budget > 0 && estimateTokens(part) > remaining {
+			// make room for the header of the outline
+			part = fmt.Sprintf("--- %s ---\n", relPath) + Include(relPath, file.Language, file.Content, max(limit-includeOverhead, 1)) + "\n\n"
 		}
+		if budget > 0 && estimateTokens(part) > remaining {
+			omitted = append(omitted, relPath)
+			continue
+		}
+
+		sb.WriteString(part)
+		remaining -= estimateTokens(part)
+	}
+
+	var prompt strings.Builder
+	if sb.Len() > 0 {
+		prompt.WriteString("Here are the relevant files:\n\n")
+		prompt.WriteString(sb.String())
+	}
+	if len(omitted) > 0 {
+		prompt.WriteString(fmt.Sprintf("These files were added to the context but left out to keep within the token budget, use read_file to read them if they are needed: %s\n\n", strings.Join(omitted, ", ")))
+	}
+
+	prompt.WriteString(userMessage)
+	return prompt.String(), omitted
+}
+
+// rank orders the files by how relevant they are to the message, those it
+// mentions first and then the ones changed or added most recently
+func (c *Context) rank(message string) []*File {
+	files := c.GetFiles()
+	score := make(map[*File]int, len(files))
+	for _, f := range files {
+		score[f] = mentions(message, c.relPath(f.Path))
 	}
 
-	// Add user message
-	sb.WriteString(userMessage)
+	slices.SortFunc(files, func(a, b *File) int {
+		return cmp.Or(
+			cmp.Compare(score[b], score[a]),
+			cmp.Compare(b.LastModified, a.LastModified),
+			cmp.Compare(b.added, a.added),
+		)
+	})
+	return files
+}
+
+// mentions scores how clearly the message mentions the file, by its path,
+// its name or its name without the extension
+func mentions(message, path string) int {
+	name := filepath.Base(path)
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+
+	switch {
+	case strings.Contains(message, path):
+		return 3
+	case strings.Contains(message, name):
+		return 2
+	case len(stem) >= 3 && regexp.MustCompile(`\b`+regexp.QuoteMeta(stem)+`\b`).MatchString(message):
+		return 1
+	}
+	return 0
+}
 
-	return sb.String()
+func (c *Context) relPath(path string) string {
+	if rel, err := filepath.Rel(c.workingDir, path); err == nil {
+		return rel
+	}
+	return path
 }
 
 // Clear removes all files from context
@@ -140,55 +230,3 @@ func (c *Context) GetTotalSize() int64 {
 func (c *Context) GetFileCount() int {
 	return len(c.files)
 }
-
-// isExcluded checks if a path matches exclude patterns
-func (c *Context) isExcluded(path string) bool {
-	for _, pattern := range c.config.ExcludePatterns {
-		matched, _ := filepath.Match(pattern, filepath.Base(path))
-		if matched {
-			return true
-		}
-		// Also check if path contains pattern (for directories)
-		if strings.Contains(path, strings.TrimSuffix(pattern, "/")) {
-			return true
-		}
-	}
-	return false
-}
-
-// detectLanguage detects the programming language from file extension
-func detectLanguage(path string) string {
-	ext := strings.ToLower(filepath.Ext(path))
-
-	langMap := map[string]string{
-		".go":   "go",
-		".py":   "python",
-		".js":   "javascript",
-		".ts":   "typescript",
-		".jsx":  "javascript",
-		".tsx":  "typescript",
-		".java": "java",
-		".c":    "c",
-		".cpp":  "cpp",
-		".h":    "c",
-		".hpp":  "cpp",
-		".rs":   "rust",
-		".rb":   "ruby",
-		".php":  "php",
-		".sh":   "bash",
-		".yml":  "yaml",
-		".yaml": "yaml",
-		".json": "json",
-		".xml":  "xml",
-		".html": "html",
-		".css":  "css",
-		".sql":  "sql",
-		".md":   "markdown",
-	}
-
-	if lang, exists := langMap[ext]; exists {
-		return lang
-	}
-
-	return "text"
-}
@@ -0,0 +1,53 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildPrompt(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string, age time.Duration) {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		mtime := time.Now().Add(-age)
+		assert.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+	write("old.go", "package old\n", time.Hour)
+	write("new.go", "package new\n", time.Minute)
+	write("big.go", "package big\n\nfunc Big() {\n"+strings.Repeat("\tprintln(\"big\")\n", 200)+"}\n", 2*time.Hour)
+
+	cfg := config.Default()
+	cfg.MaxFileTokens = 0
+	c := NewContext(cfg)
+	c.workingDir = dir
+	for _, name := range []string{"old.go", "new.go", "big.go"} {
+		assert.NoError(t, c.AddFile(filepath.Join(dir, name)))
+	}
+
+	// everything fits without a budget, the newest first
+	prompt, omitted := c.BuildPrompt("hi", 0)
+	assert.Empty(t, omitted)
+	assert.Less(t, strings.Index(prompt, "--- new.go"), strings.Index(prompt, "--- old.go"))
+	assert.Contains(t, prompt, "println")
+	assert.True(t, strings.HasSuffix(prompt, "\n\nhi"))
+
+	// a mentioned file goes first, the big one is only given as an outline
+	prompt, omitted = c.BuildPrompt("what does old do?", 150)
+	assert.Empty(t, omitted)
+	assert.Less(t, strings.Index(prompt, "--- old.go"), strings.Index(prompt, "--- new.go"))
+	assert.Contains(t, prompt, "big.go is 204 lines")
+	assert.NotContains(t, prompt, "println")
+
+	// the ones that don't fit are left out and the model is told
+	prompt, omitted = c.BuildPrompt("look at big.go", 20)
+	assert.Equal(t, []string{"big.go", "old.go"}, omitted)
+	assert.Contains(t, prompt, "--- new.go")
+	assert.Contains(t, prompt, "left out to keep within the token budget, use read_file to read them if they are needed: big.go, old.go")
+}
@@ -0,0 +1,326 @@
+package files
+
+import (
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// sniffSize is how much of the start of a file is looked at to tell what
+// language it is in
+const sniffSize = 8 << 10
+
+// languageNames are the languages of files known by their name
+var languageNames = map[string]string{
+	"makefile":       "makefile",
+	"gnumakefile":    "makefile",
+	"dockerfile":     "dockerfile",
+	"containerfile":  "dockerfile",
+	"jenkinsfile":    "groovy",
+	"vagrantfile":    "ruby",
+	"rakefile":       "ruby",
+	"gemfile":        "ruby",
+	"podfile":        "ruby",
+	"brewfile":       "ruby",
+	"cmakelists.txt": "cmake",
+	"pkgbuild":       "bash",
+	"justfile":       "just",
+	".bashrc":        "bash",
+	".bash_profile":  "bash",
+	".bash_aliases":  "bash",
+	".profile":       "sh",
+	".zshrc":         "zsh",
+	".zprofile":      "zsh",
+	".gitconfig":     "ini",
+	".editorconfig":  "ini",
+	"go.mod":         "go.mod",
+	"go.sum":         "text",
+}
+
+// languageExts are the languages of files known by their extension
+var languageExts = map[string]string{
+	".go":         "go",
+	".py":         "python",
+	".pyi":        "python",
+	".js":         "javascript",
+	".mjs":        "javascript",
+	".cjs":        "javascript",
+	".ts":         "typescript",
+	".jsx":        "javascript",
+	".tsx":        "typescript",
+	".java":       "java",
+	".kt":         "kotlin",
+	".kts":        "kotlin",
+	".scala":      "scala",
+	".groovy":     "groovy",
+	".gradle":     "groovy",
+	".c":          "c",
+	".cpp":        "cpp",
+	".cc":         "cpp",
+	".cxx":        "cpp",
+	".h":          "c",
+	".hpp":        "cpp",
+	".hh":         "cpp",
+	".cs":         "csharp",
+	".swift":      "swift",
+	".m":          "objectivec",
+	".mm":         "objectivec",
+	".rs":         "rust",
+	".zig":        "zig",
+	".rb":         "ruby",
+	".php":        "php",
+	".pl":         "perl",
+	".pm":         "perl",
+	".lua":        "lua",
+	".r":          "r",
+	".jl":         "julia",
+	".ex":         "elixir",
+	".exs":        "elixir",
+	".erl":        "erlang",
+	".hs":         "haskell",
+	".ml":         "ocaml",
+	".clj":        "clojure",
+	".dart":       "dart",
+	".sh":         "bash",
+	".bash":       "bash",
+	".zsh":        "zsh",
+	".fish":       "fish",
+	".ps1":        "powershell",
+	".bat":        "batch",
+	".cmd":        "batch",
+	".mk":         "makefile",
+	".cmake":      "cmake",
+	".dockerfile": "dockerfile",
+	".tf":         "hcl",
+	".hcl":        "hcl",
+	".nix":        "nix",
+	".proto":      "protobuf",
+	".graphql":    "graphql",
+	".yml":        "yaml",
+	".yaml":       "yaml",
+	".json":       "json",
+	".toml":       "toml",
+	".ini":        "ini",
+	".xml":        "xml",
+	".html":       "html",
+	".htm":        "html",
+	".vue":        "vue",
+	".svelte":     "svelte",
+	".css":        "css",
+	".scss":       "scss",
+	".less":       "less",
+	".sql":        "sql",
+	".md":         "markdown",
+	".diff":       "diff",
+	".patch":      "diff",
+	".csv":        "csv",
+	".txt":        "text",
+}
+
+// interpreters are the languages of scripts run by the interpreter named
+// in their shebang
+var interpreters = map[string]string{
+	"sh":        "sh",
+	"dash":      "sh",
+	"ash":       "sh",
+	"bash":      "bash",
+	"ksh":       "bash",
+	"zsh":       "zsh",
+	"fish":      "fish",
+	"python":    "python",
+	"node":      "javascript",
+	"nodejs":    "javascript",
+	"deno":      "typescript",
+	"bun":       "javascript",
+	"ruby":      "ruby",
+	"perl":      "perl",
+	"php":       "php",
+	"lua":       "lua",
+	"rscript":   "r",
+	"pwsh":      "powershell",
+	"tclsh":     "tcl",
+	"awk":       "awk",
+	"gawk":      "awk",
+	"make":      "makefile",
+	"osascript": "applescript",
+}
+
+// modeNames are the names vim and emacs give some languages that are
+// spelled differently in fences
+var modeNames = map[string]string{
+	"py":           "python",
+	"js":           "javascript",
+	"ts":           "typescript",
+	"rb":           "ruby",
+	"yml":          "yaml",
+	"md":           "markdown",
+	"make":         "makefile",
+	"shell":        "sh",
+	"shell-script": "sh",
+	"c++":          "cpp",
+	"sh-mode":      "sh",
+	"conf":         "ini",
+}
+
+var (
+	reVimModeline   = regexp.MustCompile(`(?:^|\s)(?:vim?|ex):.*?\b(?:ft|filetype|syntax)=([\w+-]+)`)
+	reEmacsModeline = regexp.MustCompile(`-\*-\s*(?:(?:.*;)?\s*mode:\s*([\w+-]+)|([\w+-]+))\s*(?:;.*)?-\*-`)
+	reVersion       = regexp.MustCompile(`[\d.]+$`)
+	reMakeRule      = regexp.MustCompile(`(?m)^[\w./%$(){}-]+\s*:[^=]*\n\t`)
+	reDockerfile    = regexp.MustCompile(`(?mi)^FROM\s+\S+`)
+	reDockerInstr   = regexp.MustCompile(`(?m)^(RUN|CMD|COPY|ENTRYPOINT|WORKDIR|ENV)\s`)
+	reCpp           = regexp.MustCompile(`(?m)^\s*(class\s+\w+|namespace\s+\w+|template\s*<|#include\s*<(iostream|string|vector|memory)>)|std::`)
+)
+
+// DetectLanguage tells what language a file is in for the fence it is shown
+// in, from a modeline in the content, the name of the file, a shebang, its
+// extension, and then what the content looks like.  It is "text" when it
+// can't tell.
+func DetectLanguage(path string, content []byte) string {
+	// a vim modeline can be at the end
+	tail := content
+	if len(content) > sniffSize {
+		content, tail = content[:sniffSize], content[len(content)-sniffSize:]
+	}
+
+	if lang := modeline(content, tail); lang != "" {
+		return lang
+	}
+
+	name := strings.ToLower(filepath.Base(path))
+	if lang, ok := languageNames[name]; ok {
+		return lang
+	}
+	if strings.HasPrefix(name, "dockerfile.") || strings.HasPrefix(name, "containerfile.") {
+		return "dockerfile"
+	}
+	if strings.HasPrefix(name, "makefile.") {
+		return "makefile"
+	}
+
+	if lang := shebang(content); lang != "" {
+		return lang
+	}
+
+	ext := strings.ToLower(filepath.Ext(name))
+	if lang, ok := languageExts[ext]; ok {
+		// a .h file is as likely to be C++ as C
+		if ext == ".h" && reCpp.Match(content) {
+			return "cpp"
+		}
+		return lang
+	}
+
+	if ext == "" {
+		if lang := sniff(content); lang != "" {
+			return lang
+		}
+	}
+	return "text"
+}
+
+// modeline finds a vim modeline in the first or last lines, or an emacs one
+// in the first two
+func modeline(head, tail []byte) string {
+	first := strings.SplitN(string(head), "\n", 6)
+	for _, line := range first[:min(len(first), 2)] {
+		if m := reEmacsModeline.FindStringSubmatch(line); m != nil {
+			return modeName(m[1] + m[2])
+		}
+	}
+
+	last := strings.Split(strings.TrimRight(string(tail), "\n"), "\n")
+	edges := slices.Concat(first[:min(len(first), 5)], last[max(0, len(last)-5):])
+	for _, line := range edges {
+		if m := reVimModeline.FindStringSubmatch(line); m != nil {
+			return modeName(m[1])
+		}
+	}
+	return ""
+}
+
+func modeName(name string) string {
+	name = strings.ToLower(name)
+	if lang, ok := modeNames[name]; ok {
+		return lang
+	}
+	return name
+}
+
+// shebang finds the language of the interpreter the script is run with,
+// like #!/bin/bash or #!/usr/bin/env -S python3 -u
+func shebang(content []byte) string {
+	if !bytes.HasPrefix(content, []byte("#!")) {
+		return ""
+	}
+	line, _, _ := bytes.Cut(content[2:], []byte("\n"))
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return ""
+	}
+
+	interp := filepath.Base(fields[0])
+	if interp == "env" {
+		interp = ""
+		for _, f := range fields[1:] {
+			// skip env's own flags and any variables it sets
+			if strings.HasPrefix(f, "-") || strings.Contains(f, "=") {
+				continue
+			}
+			interp = filepath.Base(f)
+			break
+		}
+	}
+
+	interp = strings.ToLower(reVersion.ReplaceAllString(interp, ""))
+	return interpreters[interp]
+}
+
+// sniff guesses the language from what the start of a file without an
+// extension looks like
+func sniff(content []byte) string {
+	text := bytes.TrimSpace(content)
+	switch {
+	case len(text) == 0:
+		return ""
+	case bytes.HasPrefix(text, []byte("<?php")):
+		return "php"
+	case bytes.HasPrefix(text, []byte("<?xml")):
+		return "xml"
+	case hasPrefixFold(text, "<!doctype html") || hasPrefixFold(text, "<html"):
+		return "html"
+	case reDockerfile.Match(text) && reDockerInstr.Match(text):
+		return "dockerfile"
+	case reMakeRule.Match(content):
+		return "makefile"
+	case bytes.HasPrefix(text, []byte("package ")) && bytes.Contains(text, []byte("\nfunc ")):
+		return "go"
+	case bytes.HasPrefix(text, []byte("diff --git ")) || bytes.HasPrefix(text, []byte("--- ")) && bytes.Contains(text, []byte("\n+++ ")):
+		return "diff"
+	}
+	return ""
+}
+
+func hasPrefixFold(b []byte, prefix string) bool {
+	return len(b) >= len(prefix) && strings.EqualFold(string(b[:len(prefix)]), prefix)
+}
+
+// Fence puts the content in a markdown code fence for the language, the
+// fence is made longer than any run of backticks in the content so it can't
+// be closed early
+func Fence(lang, content string) string {
+	ticks, run := 3, 0
+	for _, r := range content {
+		if r != '`' {
+			run = 0
+			continue
+		}
+		run++
+		ticks = max(ticks, run+1)
+	}
+
+	fence := strings.Repeat("`", ticks)
+	return fence + lang + "\n" + strings.TrimSuffix(content, "\n") + "\n" + fence
+}
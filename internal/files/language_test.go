@@ -0,0 +1,45 @@
+package files
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		path, content, want string
+	}{
+		{"main.go", "package main", "go"},
+		{"Makefile", "all:\n\tgo build", "makefile"},
+		{"src/Dockerfile.dev", "FROM alpine", "dockerfile"},
+		{"Containerfile", "FROM alpine", "dockerfile"},
+		{"bin/deploy", "#!/bin/bash\nset -e", "bash"},
+		{"bin/tool", "#!/usr/bin/env -S python3.11 -u\nprint(1)", "python"},
+		{"run.sh", "#!/bin/zsh\necho", "zsh"},
+		{"script", "#!/usr/bin/env node\nconsole.log(1)", "javascript"},
+		{"notes", "# -*- mode: ruby -*-\nputs 1", "ruby"},
+		{"config.txt", "x = 1\n# vim: set ft=toml :", "toml"},
+		{"lib.h", "namespace foo {\nclass Bar {};\n}", "cpp"},
+		{"lib.h", "int foo(void);", "c"},
+		{"build", "FROM golang\nRUN go build", "dockerfile"},
+		{"rules", "build: deps\n\tgo build\n", "makefile"},
+		{"page", "<!DOCTYPE html>\n<html>", "html"},
+		{"README", "Just some words", "text"},
+		{"empty", "", "text"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, DetectLanguage(tt.path, []byte(tt.content)), tt.path)
+	}
+
+	// the modeline at the end of a big file is found
+	big := strings.Repeat("x = 1\n", 4000) + "# vim: ft=python"
+	assert.Equal(t, "python", DetectLanguage("big", []byte(big)))
+}
+
+func TestFence(t *testing.T) {
+	assert.Equal(t, "```go\npackage main\n```", Fence("go", "package main\n"))
+	assert.Equal(t, "````markdown\n```sh\nls\n```\n````", Fence("markdown", "```sh\nls\n```"))
+}
@@ -0,0 +1,142 @@
+package files
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxOutlineLine is how much of a long line is kept in an outline
+const maxOutlineLine = 160
+
+// outlinePatterns match the lines that make up the structure of a file in
+// each language, its imports and the declarations of types and functions
+var outlinePatterns = map[string]*regexp.Regexp{
+	"go":         regexp.MustCompile(`^(package|import|func|type)\s|^\s+"[^"]+"$|^\s+\w+\s+"[^"]+"$|^(var|const)\s+\w`),
+	"python":     regexp.MustCompile(`^\s*(import|from)\s|^\s*(async\s+)?def\s|^\s*class\s|^@`),
+	"javascript": regexp.MustCompile(`^\s*import\s|require\(|^\s*(export\s+)?(default\s+)?(async\s+)?function\b|^\s*(export\s+)?(default\s+)?(abstract\s+)?class\s|^\s*(export\s+)?(interface|type|enum)\s+\w|^(export\s+)?(const|let)\s+\w+\s*=\s*(async\s+)?(\(|function)|^\s+(static\s+)?(async\s+)?(get\s+|set\s+)?#?\w+\s*\([^)]*\)\s*(:\s*[^{]+)?\{$`),
+	"java":       regexp.MustCompile(`^\s*(package|import|using|namespace)\s|^\s*((public|private|protected|internal|static|final|abstract|sealed|override|open|data|suspend|inline|async|partial)\s+)*(class|interface|enum|record|object|struct|fun|def|func)\s|^\s*(public|private|protected|internal)\s[^=;]*\([^;]*$`),
+	"rust":       regexp.MustCompile(`^\s*(pub(\([^)]*\))?\s+)?(use|mod|fn|async fn|struct|enum|trait|impl|type|const|static|macro_rules!)\b|^\s*impl\b`),
+	"c":          regexp.MustCompile(`^#\s*(include|define)\s|^(typedef|struct|enum|union|class|namespace|template)\b|^[A-Za-z_][\w\s\*&:<>,~]*\([^;]*$`),
+	"ruby":       regexp.MustCompile(`^\s*(require|require_relative|include|extend)\s|^\s*(class|module|def)\s|^\s*attr_(reader|writer|accessor)\s`),
+	"php":        regexp.MustCompile(`^\s*(namespace|use|require|require_once|include)\s|^\s*((abstract|final|public|private|protected|static)\s+)*(function|class|interface|trait|enum)\s`),
+	"sh":         regexp.MustCompile(`^\s*(source|\.)\s|^\s*(function\s+)?[\w-]+\s*\(\)|^\s*function\s+[\w-]+`),
+	"markdown":   regexp.MustCompile(`^#{1,6}\s`),
+	"yaml":       regexp.MustCompile(`^[\w"'.-][^:#]*:(\s|$)`),
+	"makefile":   regexp.MustCompile(`^(include|-include)\s|^[\w./%$(){}-]+\s*:([^=]|$)`),
+	"sql":        regexp.MustCompile(`(?i)^\s*(create|alter)\s`),
+}
+
+// outlineAliases are languages that are outlined like another one
+var outlineAliases = map[string]string{
+	"typescript": "javascript",
+	"vue":        "javascript",
+	"svelte":     "javascript",
+	"kotlin":     "java",
+	"scala":      "java",
+	"groovy":     "java",
+	"csharp":     "java",
+	"swift":      "java",
+	"dart":       "java",
+	"cpp":        "c",
+	"objectivec": "c",
+	"bash":       "sh",
+	"zsh":        "sh",
+	"toml":       "yaml",
+	"ini":        "yaml",
+}
+
+// reGenericOutline is used for the languages there is no pattern for
+var reGenericOutline = regexp.MustCompile(`^\s*(import|include|require|use|from|package|module|namespace)\s|^\s*((pub|export|public|private|static|async)\s+)*(func|function|fn|def|sub|proc|class|struct|interface|trait|enum|type)\s+\w`)
+
+// Outline lists the imports and the declarations of types and functions in
+// the content with their line numbers, to show the structure of a file that
+// is too big to show whole
+func Outline(lang, content string) string {
+	if alias, ok := outlineAliases[lang]; ok {
+		lang = alias
+	}
+	re, ok := outlinePatterns[lang]
+	if !ok {
+		re = reGenericOutline
+	}
+
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	width := len(fmt.Sprint(len(lines)))
+
+	var sb strings.Builder
+	for i, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if !re.MatchString(line) {
+			continue
+		}
+		if len(line) > maxOutlineLine {
+			line = line[:maxOutlineLine] + "…"
+		}
+		fmt.Fprintf(&sb, "%*d  %s\n", width, i+1, line)
+	}
+	return sb.String()
+}
+
+// estimateTokens is the same rough 4 characters per token the AI package
+// budgets with
+func estimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// Include shows the content of a file for the AI in a fence, if it is over
+// the budget of tokens the outline is shown instead, with how to read the
+// parts that are needed.  A budget of 0 always shows it whole.
+func Include(path, lang, content string, budget int) string {
+	if budget <= 0 || estimateTokens(content) <= budget {
+		return Fence(lang, content)
+	}
+
+	lines := strings.Count(strings.TrimSuffix(content, "\n"), "\n") + 1
+	outline := Outline(lang, content)
+
+	// even the outline can be too big
+	if estimateTokens(outline) > budget {
+		cut := strings.LastIndex(outline[:budget*4], "\n") + 1
+		outline = outline[:cut] + fmt.Sprintf("… the outline stops at %d of %d lines\n", outlineLine(outline[:cut]), lines)
+	}
+
+	return fmt.Sprintf("%s is %d lines (~%d tokens), too big to show whole, this is an outline of it with line numbers. "+
+		"Use read_file with start_line and end_line to read the parts you need.\n", path, lines, estimateTokens(content)) +
+		Fence(lang, outline)
+}
+
+// outlineLine is the line number of the last line in the outline
+func outlineLine(outline string) int {
+	last := strings.TrimSuffix(outline, "\n")
+	last = last[strings.LastIndex(last, "\n")+1:]
+
+	var n int
+	fmt.Sscan(strings.TrimSpace(last), &n)
+	return n
+}
+
+// Lines returns lines start to end of the content, counting from 1, stopping
+// early if they go over the budget of tokens.  The last line returned is
+// given so the caller can say where to read on from.
+func Lines(content string, start, end, budget int) (string, int) {
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	start = max(start, 1)
+	if end <= 0 || end > len(lines) {
+		end = len(lines)
+	}
+	if start > end {
+		return "", start - 1
+	}
+
+	var sb strings.Builder
+	for n := start; n <= end; n++ {
+		line := lines[n-1] + "\n"
+		// the first line is always given so the reading can't get stuck
+		if budget > 0 && n > start && estimateTokens(sb.String())+estimateTokens(line) > budget {
+			return sb.String(), n - 1
+		}
+		sb.WriteString(line)
+	}
+	return sb.String(), end
+}
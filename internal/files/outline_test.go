@@ -0,0 +1,73 @@
+package files
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const outlineSrc = `package main
+
+import (
+	"fmt"
+	str "strings"
+)
+
+// Server serves things
+type Server struct {
+	name string
+}
+
+func (s *Server) Start() error {
+	fmt.Println("starting", s.name)
+	return nil
+}
+
+func main() {
+	_ = str.ToUpper("x")
+}
+`
+
+func TestOutline(t *testing.T) {
+	assert.Equal(t, ` 1  package main
+ 3  import (
+ 4  	"fmt"
+ 5  	str "strings"
+ 9  type Server struct {
+13  func (s *Server) Start() error {
+18  func main() {
+`, Outline("go", outlineSrc))
+
+	py := "import os\n\nclass A:\n    x = 1\n\n    def run(self):\n        pass\n"
+	assert.Equal(t, "1  import os\n3  class A:\n6      def run(self):\n", Outline("python", py))
+}
+
+func TestInclude(t *testing.T) {
+	assert.Equal(t, Fence("go", outlineSrc), Include("main.go", "go", outlineSrc, 0))
+	assert.Equal(t, Fence("go", outlineSrc), Include("main.go", "go", outlineSrc, 1000))
+
+	out := Include("main.go", "go", outlineSrc, 40)
+	assert.True(t, strings.HasPrefix(out, "main.go is 20 lines (~57 tokens), too big to show whole"))
+	assert.Contains(t, out, "13  func (s *Server) Start() error {")
+	assert.NotContains(t, out, "fmt.Println")
+
+	// the outline is cut short when it is over the budget too
+	out = Include("main.go", "go", outlineSrc, 10)
+	assert.Contains(t, out, "the outline stops at 3 of 20 lines")
+	assert.NotContains(t, out, "func main")
+}
+
+func TestLines(t *testing.T) {
+	lines, last := Lines(outlineSrc, 13, 16, 0)
+	assert.Equal(t, "func (s *Server) Start() error {\n\tfmt.Println(\"starting\", s.name)\n\treturn nil\n}\n", lines)
+	assert.Equal(t, 16, last)
+
+	// stops at the budget, but always gives the first line
+	lines, last = Lines(outlineSrc, 13, 0, 18)
+	assert.Equal(t, 14, last)
+	assert.Equal(t, "func (s *Server) Start() error {\n\tfmt.Println(\"starting\", s.name)\n", lines)
+
+	_, last = Lines(outlineSrc, 18, 100, 0)
+	assert.Equal(t, 20, last)
+}
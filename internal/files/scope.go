@@ -0,0 +1,70 @@
+package files
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/penguinpowernz/clai/config"
+)
+
+// InScope reports whether the path is inside one of the directories in the
+// scope config, a relative path is resolved against the working dir.  When no
+// scope is configured everything is in scope.
+func InScope(cfg config.Config, workingDir, path string) bool {
+	if len(cfg.Scope) == 0 {
+		return true
+	}
+
+	abs := absPath(workingDir, path)
+	for _, scope := range cfg.Scope {
+		if abs == scope || strings.HasPrefix(abs, scope+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// LeadsToScope reports whether the path is in scope, or is a parent directory
+// of something in scope and so needs to be visible to navigate to it
+func LeadsToScope(cfg config.Config, workingDir, path string) bool {
+	if InScope(cfg, workingDir, path) {
+		return true
+	}
+
+	abs := absPath(workingDir, path)
+	for _, scope := range cfg.Scope {
+		if abs == "/" || strings.HasPrefix(scope, abs+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopedPaths returns the paths to search when searching the given path, if
+// the path is a parent of the scope only the scoped directories beneath it are
+// returned (relative to the working dir)
+func ScopedPaths(cfg config.Config, workingDir, path string) []string {
+	if InScope(cfg, workingDir, path) {
+		return []string{path}
+	}
+
+	abs := absPath(workingDir, path)
+	var paths []string
+	for _, scope := range cfg.Scope {
+		if abs == "/" || strings.HasPrefix(scope, abs+"/") {
+			rel, err := filepath.Rel(workingDir, scope)
+			if err != nil {
+				continue
+			}
+			paths = append(paths, rel)
+		}
+	}
+	return paths
+}
+
+func absPath(workingDir, path string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(workingDir, path)
+	}
+	return filepath.Clean(path)
+}
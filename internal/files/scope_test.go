@@ -0,0 +1,31 @@
+package files
+
+import (
+	"testing"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScope(t *testing.T) {
+	cfg := config.Default()
+	assert.True(t, InScope(*cfg, "/repo", "anything/at/all.go"))
+
+	cfg.Scope = []string{"/repo/services/api", "/repo/libs/auth"}
+
+	assert.True(t, InScope(*cfg, "/repo", "services/api/main.go"))
+	assert.True(t, InScope(*cfg, "/repo/services", "api"))
+	assert.True(t, InScope(*cfg, "/repo", "/repo/libs/auth"))
+	assert.False(t, InScope(*cfg, "/repo", "services/apiv2/main.go"))
+	assert.False(t, InScope(*cfg, "/repo", "services"))
+	assert.False(t, InScope(*cfg, "/repo", "services/api/../web/main.go"))
+
+	assert.True(t, LeadsToScope(*cfg, "/repo", "."))
+	assert.True(t, LeadsToScope(*cfg, "/repo", "services"))
+	assert.False(t, LeadsToScope(*cfg, "/repo", "services/web"))
+
+	assert.Equal(t, []string{"services/api", "libs/auth"}, ScopedPaths(*cfg, "/repo", "."))
+	assert.Equal(t, []string{"api"}, ScopedPaths(*cfg, "/repo/services", "."))
+	assert.Equal(t, []string{"services/api/x"}, ScopedPaths(*cfg, "/repo", "services/api/x"))
+	assert.Empty(t, ScopedPaths(*cfg, "/repo", "docs"))
+}
@@ -0,0 +1,63 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/penguinpowernz/clai/config"
+)
+
+// sensitivePatterns are the files that are kept from the AI whatever the
+// config says, patterns with a / are matched against the end of the path and
+// the rest against the name
+var sensitivePatterns = []string{
+	".env", ".env.*",
+	"*.pem", "*.key", "*.p12", "*.pfx",
+	"id_rsa", "id_dsa", "id_ecdsa", "id_ed25519",
+	".aws/credentials", ".netrc", ".pgpass", ".pypirc", ".git-credentials",
+}
+
+// templateSuffixes are on the copies of sensitive files that are checked in
+// with the secrets left out, like .env.example
+var templateSuffixes = []string{".example", ".sample", ".template", ".dist"}
+
+// reNpmAuth finds the auth settings of an .npmrc, without them it only has
+// the registries
+var reNpmAuth = regexp.MustCompile(`(?m)^\s*(//\S*:)?_(auth|authToken|password)\s*=`)
+
+// IsSensitive reports whether the file is one that holds secrets, like .env,
+// a private key or cloud credentials, unless the project allows them
+func IsSensitive(cfg config.Config, workingDir, path string) bool {
+	if cfg.AllowSensitive {
+		return false
+	}
+	path = absPath(workingDir, path)
+
+	name := filepath.Base(path)
+	for _, suffix := range templateSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return false
+		}
+	}
+
+	if name == ".npmrc" {
+		data, err := os.ReadFile(path)
+		return err != nil || reNpmAuth.Match(data)
+	}
+
+	slashed := filepath.ToSlash(path)
+	for _, pattern := range sensitivePatterns {
+		if strings.Contains(pattern, "/") {
+			if slashed == pattern || strings.HasSuffix(slashed, "/"+pattern) {
+				return true
+			}
+			continue
+		}
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsSensitive(t *testing.T) {
+	cfg := config.Default()
+	for _, fn := range []string{".env", "app/.env.production", "certs/server.pem", "home/.ssh/id_rsa", "/home/me/.aws/credentials"} {
+		assert.True(t, IsSensitive(*cfg, "/repo", fn), fn)
+	}
+	for _, fn := range []string{".env.example", "main.go", "id_rsa.pub", "credentials", "docs/keys.md"} {
+		assert.False(t, IsSensitive(*cfg, "/repo", fn), fn)
+	}
+
+	// an .npmrc only holds secrets when it has a token in it
+	dir := t.TempDir()
+	npmrc := filepath.Join(dir, ".npmrc")
+	assert.NoError(t, os.WriteFile(npmrc, []byte("registry=https://npm.example.com/\n"), 0644))
+	assert.False(t, IsSensitive(*cfg, dir, ".npmrc"))
+	assert.NoError(t, os.WriteFile(npmrc, []byte("//npm.example.com/:_authToken=abc123\n"), 0644))
+	assert.True(t, IsSensitive(*cfg, dir, ".npmrc"))
+
+	cfg.AllowSensitive = true
+	assert.False(t, IsSensitive(*cfg, "/repo", ".env"))
+	assert.False(t, IsSensitive(*cfg, dir, ".npmrc"))
+}
@@ -0,0 +1,260 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/penguinpowernz/clai/config"
+)
+
+// Issue is an issue from GitHub or GitLab
+type Issue struct {
+	Number   int
+	Title    string
+	Body     string
+	State    string
+	Author   string
+	URL      string
+	Comments []Comment
+}
+
+// Comment is a comment on an issue
+type Comment struct {
+	Author string
+	Body   string
+}
+
+// Markdown formats the issue to add to the context
+func (i Issue) Markdown() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "## Issue #%d: %s\n\n", i.Number, i.Title)
+	fmt.Fprintf(&sb, "%s, opened by %s: %s\n\n", i.State, i.Author, i.URL)
+	sb.WriteString(strings.TrimSpace(i.Body) + "\n")
+
+	for _, c := range i.Comments {
+		fmt.Fprintf(&sb, "\n### Comment by %s\n\n%s\n", c.Author, strings.TrimSpace(c.Body))
+	}
+
+	return sb.String()
+}
+
+// Remote is a repo on a forge
+type Remote struct {
+	Kind string // "github" or "gitlab"
+	Host string
+	Path string // owner/repo, or group/subgroup/repo for GitLab
+}
+
+// ParseRemote works out the forge from a git remote URL, either the scp style
+// git@host:owner/repo.git or a URL like https://host/owner/repo
+func ParseRemote(cfg config.Config, remote string) (Remote, error) {
+	remote = strings.TrimSpace(remote)
+
+	var host, path string
+	if u, err := url.Parse(remote); err == nil && u.Host != "" {
+		host, path = u.Hostname(), u.Path
+	} else if at, rest, ok := strings.Cut(remote, ":"); ok {
+		_, host, _ = strings.Cut(at, "@")
+		if host == "" {
+			host = at
+		}
+		path = rest
+	} else {
+		return Remote{}, fmt.Errorf("can't parse remote %q", remote)
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if strings.Count(path, "/") < 1 {
+		return Remote{}, fmt.Errorf("can't find the repo in remote %q", remote)
+	}
+
+	r := Remote{Host: host, Path: path}
+	switch {
+	case host == "github.com":
+		r.Kind = "github"
+	case host == "gitlab.com" || slices.Contains(cfg.Forge.GitLabHosts, host):
+		r.Kind = "gitlab"
+	default:
+		return Remote{}, fmt.Errorf("%s is not a known forge, add it to forge.gitlab_hosts if it is a GitLab server", host)
+	}
+
+	return r, nil
+}
+
+// Detect finds the forge from the origin remote of the git repo in the dir
+func Detect(cfg config.Config, dir string) (Remote, error) {
+	out, err := exec.Command("git", "-C", dir, "remote", "get-url", "origin").Output()
+	if err != nil {
+		return Remote{}, fmt.Errorf("no origin remote found: %w", err)
+	}
+	return ParseRemote(cfg, string(out))
+}
+
+// FetchIssue gets the issue with its comments from the forge that the repo
+// in the dir is hosted on
+func FetchIssue(ctx context.Context, cfg config.Config, dir string, number int) (*Issue, error) {
+	r, err := Detect(cfg, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	switch r.Kind {
+	case "github":
+		return fetchGitHubIssue(ctx, githubToken(cfg), r, number)
+	default:
+		return fetchGitLabIssue(ctx, gitlabToken(cfg), r, number)
+	}
+}
+
+func fetchGitHubIssue(ctx context.Context, token string, r Remote, number int) (*Issue, error) {
+	var issue struct {
+		Number  int    `json:"number"`
+		Title   string `json:"title"`
+		Body    string `json:"body"`
+		State   string `json:"state"`
+		HTMLURL string `json:"html_url"`
+		User    struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	}
+
+	header := http.Header{"Accept": {"application/vnd.github+json"}}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+
+	base := fmt.Sprintf("https://api.github.com/repos/%s/issues/%d", r.Path, number)
+	if err := getJSON(ctx, base, header, &issue); err != nil {
+		return nil, err
+	}
+
+	var comments []struct {
+		Body string `json:"body"`
+		User struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	}
+	if err := getJSON(ctx, base+"/comments?per_page=100", header, &comments); err != nil {
+		return nil, err
+	}
+
+	out := &Issue{
+		Number: issue.Number,
+		Title:  issue.Title,
+		Body:   issue.Body,
+		State:  issue.State,
+		Author: issue.User.Login,
+		URL:    issue.HTMLURL,
+	}
+	for _, c := range comments {
+		out.Comments = append(out.Comments, Comment{Author: c.User.Login, Body: c.Body})
+	}
+
+	return out, nil
+}
+
+func fetchGitLabIssue(ctx context.Context, token string, r Remote, number int) (*Issue, error) {
+	type user struct {
+		Username string `json:"username"`
+	}
+
+	var issue struct {
+		IID         int    `json:"iid"`
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		State       string `json:"state"`
+		WebURL      string `json:"web_url"`
+		Author      user   `json:"author"`
+	}
+
+	header := http.Header{}
+	if token != "" {
+		header.Set("PRIVATE-TOKEN", token)
+	}
+
+	base := fmt.Sprintf("https://%s/api/v4/projects/%s/issues/%d", r.Host, url.PathEscape(r.Path), number)
+	if err := getJSON(ctx, base, header, &issue); err != nil {
+		return nil, err
+	}
+
+	var notes []struct {
+		Body   string `json:"body"`
+		System bool   `json:"system"`
+		Author user   `json:"author"`
+	}
+	if err := getJSON(ctx, base+"/notes?sort=asc&per_page=100", header, &notes); err != nil {
+		return nil, err
+	}
+
+	out := &Issue{
+		Number: issue.IID,
+		Title:  issue.Title,
+		Body:   issue.Description,
+		State:  issue.State,
+		Author: issue.Author.Username,
+		URL:    issue.WebURL,
+	}
+	for _, n := range notes {
+		// skip the "changed the label" type notes
+		if n.System {
+			continue
+		}
+		out.Comments = append(out.Comments, Comment{Author: n.Author.Username, Body: n.Body})
+	}
+
+	return out, nil
+}
+
+func getJSON(ctx context.Context, u string, header http.Header, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header = header
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", req.URL.Host, res.Status)
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// githubToken finds a token in the config, the environment or from the gh CLI
+// which keeps it in the system keyring
+func githubToken(cfg config.Config) string {
+	if cfg.Forge.GitHubToken != "" {
+		return cfg.Forge.GitHubToken
+	}
+
+	for _, name := range []string{"GITHUB_TOKEN", "GH_TOKEN"} {
+		if token := os.Getenv(name); token != "" {
+			return token
+		}
+	}
+
+	if out, err := exec.Command("gh", "auth", "token").Output(); err == nil {
+		return strings.TrimSpace(string(out))
+	}
+
+	return ""
+}
+
+func gitlabToken(cfg config.Config) string {
+	if cfg.Forge.GitLabToken != "" {
+		return cfg.Forge.GitLabToken
+	}
+	return os.Getenv("GITLAB_TOKEN")
+}
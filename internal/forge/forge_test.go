@@ -0,0 +1,30 @@
+package forge
+
+import (
+	"testing"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRemote(t *testing.T) {
+	cfg := config.Config{Forge: config.Forge{GitLabHosts: []string{"git.example.com"}}}
+
+	r, err := ParseRemote(cfg, "git@github.com:penguinpowernz/clai.git\n")
+	assert.NoError(t, err)
+	assert.Equal(t, Remote{Kind: "github", Host: "github.com", Path: "penguinpowernz/clai"}, r)
+
+	r, err = ParseRemote(cfg, "https://github.com/penguinpowernz/clai")
+	assert.NoError(t, err)
+	assert.Equal(t, "penguinpowernz/clai", r.Path)
+
+	r, err = ParseRemote(cfg, "ssh://git@git.example.com:2222/group/sub/repo.git")
+	assert.NoError(t, err)
+	assert.Equal(t, Remote{Kind: "gitlab", Host: "git.example.com", Path: "group/sub/repo"}, r)
+
+	_, err = ParseRemote(cfg, "https://bitbucket.org/owner/repo.git")
+	assert.Error(t, err)
+
+	_, err = ParseRemote(cfg, "/some/local/path")
+	assert.Error(t, err)
+}
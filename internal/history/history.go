@@ -2,61 +2,174 @@ package history
 
 import (
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/ghodss/yaml"
+	"github.com/google/uuid"
 	"github.com/penguinpowernz/clai/config"
 	"github.com/penguinpowernz/clai/internal/ai"
 )
 
-var (
-	cfg config.Config
-	id  string
-	mu  sync.Mutex
-)
-
-func SetConfig(c config.Config) { cfg = c }
-func SetSessionID(s string)     { id = s }
-
 type History struct {
+	Title   string       `yaml:"title,omitempty"`
+	Summary string       `yaml:"summary,omitempty"`
+	Log     string       `yaml:"log,omitempty"`    // the log file of the session
+	Parent  string       `yaml:"parent,omitempty"` // the session this one was branched from
+	Branch  string       `yaml:"branch,omitempty"` // the name it was given when it was branched
 	Context []ai.Message `yaml:"context"`
 	UI      []ai.Message `yaml:"ui"`
 }
 
-func SaveHistory(what string, messages []ai.Message) error {
-	mu.Lock()
-	defer mu.Unlock()
+// Store saves the history of a session to a file in the session dir, the
+// session and the UI share one so their writes don't clobber each other
+type Store struct {
+	mu  sync.Mutex
+	dir string
+	id  string
+	log string
+}
+
+// NewStore creates a store for the session with the given ID
+func NewStore(dir, id string) *Store {
+	return &Store{dir: dir, id: id}
+}
+
+// NewID makes the ID of a new session
+func NewID() string {
+	return uuid.New().String()[:6]
+}
+
+// ID returns the session the history is saved for
+func (s *Store) ID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.id
+}
+
+// Path returns the file the history is saved in
+func (s *Store) Path() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.path()
+}
+
+func (s *Store) path() string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s.yml", s.id))
+}
+
+// SetLog sets the log file of the session, it is saved along with the history
+func (s *Store) SetLog(fn string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.log = fn
+}
+
+// Save saves the messages of the "context" sent to the AI, or those shown in
+// the "ui"
+func (s *Store) Save(what string, messages []ai.Message) error {
+	return s.update(func(history *History) {
+		switch what {
+		case "context":
+			history.Context = messages
+		case "ui":
+			history.UI = messages
+		}
+	})
+}
+
+// SaveTitle saves the title and summary of the session
+func (s *Store) SaveTitle(title, summary string) error {
+	return s.update(func(history *History) {
+		history.Title = title
+		history.Summary = summary
+	})
+}
+
+// Load reads the saved history, it is empty if nothing was saved yet
+func (s *Store) Load() (History, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return load(s.path())
+}
+
+// update changes the saved history
+func (s *Store) update(change func(*History)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	history, err := LoadHistory()
+	history, err := load(s.path())
 	if err != nil {
 		return err
 	}
 
-	outfile := filepath.Join(cfg.SessionDir, fmt.Sprintf("%s.yml", id))
+	change(&history)
+	return s.write(s.path(), history)
+}
 
-	switch what {
-	case "context":
-		history.Context = messages
-	case "ui":
-		history.UI = messages
-	}
+// Branch copies the saved history to a new session branched from this one
+// with the given name, the history is saved to the new session from then on
+func (s *Store) Branch(id, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	data, err := yaml.Marshal(history)
+	history, err := load(s.path())
 	if err != nil {
 		return err
 	}
+	history.Parent = s.id
+	history.Branch = name
 
-	if err := os.WriteFile(outfile, data, 0644); err != nil {
+	fn := filepath.Join(s.dir, id+".yml")
+	if _, err := os.Stat(fn); err == nil {
+		return fmt.Errorf("session %s already exists", id)
+	}
+	if err := s.write(fn, history); err != nil {
 		return err
 	}
 
+	s.id = id
 	return nil
 }
 
-func LoadHistory() (History, error) {
-	fn := filepath.Join(cfg.SessionDir, fmt.Sprintf("%s.yml", id))
+// Switch returns the saved history of another session, it is saved to that
+// session from then on
+func (s *Store) Switch(id string) (History, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fn := filepath.Join(s.dir, id+".yml")
+	if _, err := os.Stat(fn); err != nil {
+		return History{}, fmt.Errorf("no session %s", id)
+	}
+	history, err := load(fn)
+	if err != nil {
+		return History{}, err
+	}
+
+	s.id = id
+	return history, nil
+}
+
+func (s *Store) write(fn string, history History) error {
+	if s.log != "" {
+		history.Log = s.log
+	}
+
+	data, err := yaml.Marshal(history)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(fn, data, 0644)
+}
+
+func load(fn string) (History, error) {
 	if _, err := os.Stat(fn); os.IsNotExist(err) {
 		return History{}, nil
 	}
@@ -73,3 +186,86 @@ func LoadHistory() (History, error) {
 
 	return history, nil
 }
+
+// Entry describes a saved session
+type Entry struct {
+	ID       string
+	Modified time.Time
+	Title    string
+	Summary  string
+	Messages int
+	Parent   string // the session it was branched from
+	Branch   string // the name it was given when it was branched
+}
+
+// ListDir returns the sessions saved in the dir, the most recent first
+func ListDir(dir string) ([]Entry, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.yml"))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, fn := range files {
+		info, err := os.Stat(fn)
+		if err != nil {
+			continue
+		}
+
+		h, err := load(fn)
+		if err != nil {
+			log.Printf("[history] skipping %s: %s", fn, err)
+			continue
+		}
+
+		entries = append(entries, Entry{
+			ID:       strings.TrimSuffix(filepath.Base(fn), ".yml"),
+			Modified: info.ModTime(),
+			Title:    h.Title,
+			Summary:  h.Summary,
+			Messages: len(h.Context),
+			Parent:   h.Parent,
+			Branch:   h.Branch,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Modified.After(entries[j].Modified) })
+	return entries, nil
+}
+
+// the store used by the package level functions below, they are kept for
+// code that hasn't been given a Store yet
+var std = &Store{}
+
+// Default returns the store used by the package level functions
+func Default() *Store { return std }
+
+func SetConfig(c config.Config) {
+	std.mu.Lock()
+	defer std.mu.Unlock()
+	std.dir = c.SessionDir
+}
+
+func SetSessionID(id string) {
+	std.mu.Lock()
+	defer std.mu.Unlock()
+	std.id = id
+}
+
+// Path returns the file the history of the current session is saved in
+func Path() string { return std.Path() }
+
+func SaveHistory(what string, messages []ai.Message) error { return std.Save(what, messages) }
+
+// SaveTitle saves the title and summary of the current session
+func SaveTitle(title, summary string) error { return std.SaveTitle(title, summary) }
+
+func LoadHistory() (History, error) { return std.Load() }
+
+// List returns the saved sessions, the most recent first
+func List() ([]Entry, error) {
+	std.mu.Lock()
+	dir := std.dir
+	std.mu.Unlock()
+	return ListDir(dir)
+}
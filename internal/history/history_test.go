@@ -0,0 +1,75 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir, "abc")
+	s.SetLog("/logs/abc.log")
+
+	h, err := s.Load()
+	assert.NoError(t, err)
+	assert.Empty(t, h.Context)
+
+	assert.NoError(t, s.Save("context", []ai.Message{{Role: "user", Content: "hi"}}))
+	assert.NoError(t, s.Save("ui", []ai.Message{{Role: "user", Content: "hi"}, {Role: "system", Content: "note"}}))
+	assert.NoError(t, s.SaveTitle("Greetings", "saying hi"))
+
+	h, err = s.Load()
+	assert.NoError(t, err)
+	assert.Len(t, h.Context, 1)
+	assert.Len(t, h.UI, 2)
+	assert.Equal(t, "Greetings", h.Title)
+	assert.Equal(t, "/logs/abc.log", h.Log)
+
+	// another session in the same dir doesn't see it
+	other, err := NewStore(dir, "def").Load()
+	assert.NoError(t, err)
+	assert.Empty(t, other.Context)
+
+	entries, err := ListDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "abc", entries[0].ID)
+	assert.Equal(t, 1, entries[0].Messages)
+}
+
+func TestBranch(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir, "abc")
+	assert.NoError(t, s.Save("context", []ai.Message{{Role: "user", Content: "hi"}}))
+	assert.NoError(t, s.SaveTitle("Greetings", "saying hi"))
+
+	assert.NoError(t, s.Branch("def", "formal"))
+	assert.Equal(t, "def", s.ID())
+	assert.NoError(t, s.Save("context", []ai.Message{{Role: "user", Content: "hi"}, {Role: "user", Content: "good day"}}))
+	assert.Error(t, s.Branch("abc", "again"), "the session already exists")
+
+	// the session it was branched from is kept as it was
+	h, err := s.Switch("abc")
+	assert.NoError(t, err)
+	assert.Len(t, h.Context, 1)
+	assert.NoError(t, s.Branch("ghi", ""))
+	_, err = s.Switch("nope")
+	assert.Error(t, err)
+
+	entries, err := ListDir(dir)
+	assert.NoError(t, err)
+	roots := Tree(entries)
+	assert.Len(t, roots, 1)
+
+	var lines []string
+	Family(roots, "ghi").Walk(func(n *Node, prefix string) {
+		lines = append(lines, prefix+n.ID+" "+n.Label())
+	})
+	assert.Len(t, lines, 3)
+	assert.Equal(t, "abc Greetings", lines[0])
+	assert.Contains(t, lines[1]+lines[2], "── def [formal] Greetings")
+	assert.Contains(t, lines[2], "└── ")
+	assert.Nil(t, Family(roots, "xyz"))
+}
@@ -0,0 +1,88 @@
+package history
+
+import (
+	"slices"
+	"strings"
+)
+
+// Node is a session in the tree of the sessions branched from each other
+type Node struct {
+	Entry
+	Children []*Node
+}
+
+// Tree arranges the sessions by the session they were branched from.  The
+// roots are the sessions that weren't branched, or whose parent is gone,
+// the most recent first, and the branches of each are oldest first.
+func Tree(entries []Entry) []*Node {
+	nodes := make(map[string]*Node, len(entries))
+	for _, e := range entries {
+		nodes[e.ID] = &Node{Entry: e}
+	}
+
+	var roots []*Node
+	for _, e := range entries {
+		n := nodes[e.ID]
+		if parent, ok := nodes[e.Parent]; ok && e.Parent != e.ID {
+			// the entries are the most recent first
+			parent.Children = slices.Insert(parent.Children, 0, n)
+			continue
+		}
+		roots = append(roots, n)
+	}
+	return roots
+}
+
+// Family returns the root of the tree the session is in, or nil if it isn't
+// in any of them
+func Family(roots []*Node, id string) *Node {
+	for _, root := range roots {
+		if root.Find(id) != nil {
+			return root
+		}
+	}
+	return nil
+}
+
+// Find returns the session in the tree
+func (n *Node) Find(id string) *Node {
+	if n.ID == id {
+		return n
+	}
+	for _, c := range n.Children {
+		if found := c.Find(id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// Label is the name of the branch and the title of the session
+func (n *Node) Label() string {
+	var parts []string
+	if n.Branch != "" {
+		parts = append(parts, "["+n.Branch+"]")
+	}
+	if n.Title != "" {
+		parts = append(parts, n.Title)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Walk calls fn for each session in the tree, parents before their
+// branches, with the lines to draw before it to show where it branched
+func (n *Node) Walk(fn func(n *Node, prefix string)) {
+	fn(n, "")
+	n.walk("", fn)
+}
+
+func (n *Node) walk(indent string, fn func(n *Node, prefix string)) {
+	for i, c := range n.Children {
+		branch, next := "├── ", "│   "
+		if i == len(n.Children)-1 {
+			branch, next = "└── ", "    "
+		}
+		fn(c, indent+branch)
+		c.walk(indent+next, fn)
+	}
+}
@@ -0,0 +1,507 @@
+package i18n
+
+// en is the source catalog, every key used in the UI must be in it
+var en = map[string]string{
+	"status.typing":             "Typing...",
+	"status.thinking":           "Thinking...",
+	"status.running_tool":       "Running tool...",
+	"status.ready":              "Ready",
+	"status.tool_permission":    "Tool Permission Required",
+	"status.select":             "Select",
+	"status.selection_required": "Selection Required",
+	"status.connecting":         "Connecting...",
+	"status.loading_model":      "Loading model...",
+	"status.provider_down":      "Provider unreachable",
+	"status.request_failed":     "Request failed",
+	"status.reconnecting":       "Can't reach the provider, try %d, trying again in %s (ESC to give up)",
+	"status.no_model":           "Model not found",
+	"status.tokens":             "~%d tokens",
+	"status.tokens_max":         "~%d/%d tokens (%d%%)",
+	"status.usage":              "%s tokens this session",
+	"status.usage_cost":         "%s tokens, $%.4f this session",
+	"status.budget_session":     "$%.2f of $%.2f session budget",
+	"status.budget_daily":       "$%.2f of $%.2f daily budget",
+	"status.secret":             "Secret found",
+	"status.suggest":            "Add files?",
+	"status.read_only":          "%s is driving",
+	"status.takeover":           "Takeover",
+
+	"help.prompt":           "ENTER: Send • Ctrl+C: Quit • ESC: Stop AI • Ctrl+S: Select • Ctrl+T: Model",
+	"help.prompt_multiline": "ENTER: New line • Ctrl+D: Send • Ctrl+C: Quit • ESC: Stop AI • /multiline: Back to ENTER sending",
+	"help.list":             "↑/↓: Navigate • ENTER: Select • Ctrl+C: Quit",
+	"help.permission":       "↑/↓: Navigate • ENTER: Select • D: Details • Ctrl+C: Quit",
+	"help.picker":           "Type to filter • ↑/↓: Navigate • ENTER: Select • ESC: Cancel",
+	"help.tools":            "↑/↓: Navigate • SPACE/ENTER: Change when the tool can be used • ESC: Done",
+	"help.branches":         "↑/↓: Navigate • ENTER: Switch to the branch • ESC: Cancel",
+	"help.select":           "↑/↓: Select message • ENTER: Copy • >: Quote • SPACE: Fold/unfold tool • ESC: Done • select text with the mouse to copy it",
+	"help.secret":           "R: Redact and send • S: Send anyway • C/ESC: Cancel and edit",
+	"help.suggest":          "Y: Add it • N: Leave it out • ESC: Send without the rest",
+	"help.read_only":        "ENTER: Ask to drive • Ctrl+S: Select • Ctrl+C: Detach",
+	"help.takeover":         "Y: Hand over • N/ESC: Keep driving",
+
+	"permission.title":   "Tool Permission",
+	"permission.tool":    "Tool: %s",
+	"permission.once":    "Allow to run this time only",
+	"permission.session": "Allow, and don't ask again this session",
+	"permission.deny":    "Don't allow to run the tool, give the prompt back",
+
+	"permission.args":       "Args:",
+	"permission.paths":      "Paths:",
+	"permission.where":      "Runs in:",
+	"permission.env":        "Environment:",
+	"permission.effects":    "Changes:",
+	"permission.more_lines": "  ... %d more lines",
+
+	"queue.title":    "Tool calls (%d/%d done)",
+	"queue.queued":   "queued",
+	"queue.approval": "waiting for approval",
+	"queue.running":  "running",
+	"queue.done":     "done",
+	"queue.skipped":  "skipped",
+
+	"prompt.attachment":      "[📎 paste %d: %d lines]",
+	"prompt.attachment_help": "Backspace: Remove",
+
+	"prompt.placeholder": "Type your message...",
+	"list.select_model":  "Select the model to use",
+	"list.tools":         "Tools (ask, session, always or disabled)",
+	"list.branches":      "Branches (* is the one the conversation is in)",
+	"picker.filter":      "Filter:",
+	"picker.no_matches":  "No models match",
+	"picker.model":       "MODEL",
+	"picker.params":      "PARAMS",
+	"picker.quant":       "QUANT",
+	"picker.size":        "SIZE",
+	"picker.modified":    "MODIFIED",
+	"picker.context":     "CONTEXT",
+	"picker.tools":       "tools",
+	"picker.current":     "in use",
+	"error":              "Error: %v",
+
+	"chat.interrupted":      " [interrupted, /retry to discard]",
+	"chat.provider_down":    "Can't reach the AI provider: %v",
+	"chat.no_model":         "%v, pick another model to use",
+	"chat.stalled":          "The AI sent nothing for %s so the response was stopped, use /retry to try again",
+	"chat.banned":           "The response was stopped as it matched the banned_output pattern %s, use /retry to try again",
+	"chat.skipped_busy":     "Skipped prompt as the AI is busy: %s",
+	"chat.running_tool":     "Running tool: %s with args: %s",
+	"chat.tool_output":      "Tool output:",
+	"chat.tool_request":     "I need to use the tool \"%s\" with args %s",
+	"chat.tool_running":     "running",
+	"chat.tool_ok":          "ok",
+	"chat.tool_failed":      "failed",
+	"chat.no_copy":          "There is no message %d to copy",
+	"chat.copy_failed":      "ERROR: failed to copy: %v",
+	"chat.copied":           "Copied message %d to the clipboard (%d chars)",
+	"chat.no_redact":        "There is no message %d to redact",
+	"chat.redacted":         "Redacted message %s from the transcript, history and context, clai.log and the session's log still have it",
+	"chat.secret_found":     "The prompt looks like it has a secret in it: %s",
+	"chat.secret_dropped":   "The prompt wasn't sent: %s",
+	"chat.suggest_file":     "Did you mean to include %s? [y/n]",
+	"chat.driving":          "You are driving the session",
+	"chat.watching":         "%s is driving the session, this terminal is read-only, press ENTER to ask to take over",
+	"chat.takeover_asked":   "Asked %s to hand the session over",
+	"chat.takeover_request": "%s wants to drive the session",
+	"chat.takeover_busy":    "%s has already asked to drive the session",
+	"chat.takeover_denied":  "%s kept driving the session",
+	"chat.peer_joined":      "%s attached to the session",
+	"chat.peer_left":        "%s detached from the session",
+	"chat.edit_failed":      "ERROR: failed to edit: %v",
+	"preview.return":        "Press ENTER to go back to the chat",
+	"preview.failed":        "ERROR: failed to preview: %v",
+	"preview.unsupported":   "This terminal can't show images, use kitty or iTerm2 or open the file",
+	"chat.edit_unchanged":   "Nothing was changed",
+	"chat.multiline_on":     "Multi-line mode: Enter starts a new line and Ctrl+D sends, /multiline switches back",
+	"chat.branch_switched":  "Switched to branch %s, the conversation carries on in it",
+	"chat.multiline_off":    "Enter sends the prompt again, Alt+Enter or Ctrl+J starts a new line",
+	"chat.tokens":           "~%d tokens",
+	"chat.error_message":    "ERROR: %v",
+	"chat.config_edited":    "Saved %s, restart clai to use the changes",
+
+	"chat.tool_error.not_found":         "failed: not found",
+	"chat.tool_error.permission_denied": "failed: permission denied",
+	"chat.tool_error.timeout":           "failed: timed out",
+	"chat.tool_error.too_large":         "failed: too large",
+
+	"banner.status":     "%d: %s",
+	"banner.auth":       "The provider turned down the API key, check api_key in the config",
+	"banner.rate_limit": "Too many requests, wait a bit before retrying or switch to another model",
+	"banner.server":     "The provider is having problems, retry in a while or switch to another model",
+	"banner.network":    "Can't reach %s, check that it is running and base_url is right",
+	"banner.actions":    "r: Retry • m: Switch model • c: Open config • ESC: Dismiss",
+
+	"a11y.ready":            "clai is ready. Type a message and press enter, /help lists the commands and /exit quits.",
+	"a11y.skipped_busy":     "Skipped prompt as the assistant is busy: %s",
+	"a11y.permission_keys":  "Type y to allow once, s to allow for the session, or n to deny",
+	"a11y.permission_ask":   "The assistant wants to use the tool %s with args %s",
+	"a11y.allow_it":         "Allow it? Type y to allow once, s to allow for the session, or n to deny",
+	"a11y.tool_denied":      "Tool %s denied",
+	"a11y.reconnecting":     "Can't reach the provider, trying again in %s, type /stop to give up",
+	"a11y.no_choice":        "There is no choice %d",
+	"a11y.still_responding": "The assistant is still responding, wait for it to finish or type /stop",
+	"a11y.thinking":         "Assistant is thinking",
+	"a11y.responding":       "Assistant is responding:",
+	"a11y.stopped":          "Response stopped",
+	"a11y.running_tool":     "Running tool %s",
+	"a11y.tool_finished":    "Tool %s finished",
+	"a11y.goodbye":          "Goodbye",
+	"a11y.retrying":         "Retrying",
+	"a11y.continuing":       "Continuing the last response",
+	"a11y.working_dir":      "Working directory is now %s",
+	"a11y.models":           "Available models, type a number to pick one:",
+	"a11y.follow_ups":       "Suggested follow-ups, type a number to send one:",
+	"a11y.loading_model":    "Loading the model",
+	"a11y.model_loaded":     "The model is loaded",
+	"a11y.budget_session":   "Warning, $%.2f of the $%.2f session budget has been spent",
+	"a11y.budget_daily":     "Warning, $%.2f of the $%.2f daily budget has been spent",
+	"a11y.turn_done":        "Ready for your message",
+	"a11y.no_copy":          "There is no message %d to copy",
+	"a11y.copy_failed":      "Failed to copy: %v",
+	"a11y.no_editor":        "The editor can't be opened with the accessible UI",
+	"a11y.artifact":         "Saved a %s file to %s",
+	"a11y.no_multiline":     "Multi-line mode isn't available in the accessible UI, each line is sent when you press enter",
+	"a11y.copied":           "Copied message %d to the clipboard",
+	"a11y.secret_keys":      "Type r to redact the secret and send, s to send anyway, or c to cancel",
+	"a11y.not_sent":         "The prompt wasn't sent",
+
+	// the answers to the tool permission question in the accessible UI
+	"a11y.answer_once":    "y",
+	"a11y.answer_session": "s",
+	"a11y.answer_deny":    "n",
+}
+
+var de = map[string]string{
+	"status.typing":             "Schreibt...",
+	"status.thinking":           "Denkt nach...",
+	"status.running_tool":       "Führt Werkzeug aus...",
+	"status.ready":              "Bereit",
+	"status.tool_permission":    "Werkzeug-Freigabe erforderlich",
+	"status.select":             "Auswahl",
+	"status.selection_required": "Auswahl erforderlich",
+	"status.connecting":         "Verbinde...",
+	"status.loading_model":      "Lade Modell...",
+	"status.provider_down":      "Anbieter nicht erreichbar",
+	"status.request_failed":     "Anfrage fehlgeschlagen",
+	"status.reconnecting":       "Anbieter nicht erreichbar, Versuch %d, nächster Versuch in %s (ESC zum Aufgeben)",
+	"status.no_model":           "Modell nicht gefunden",
+	"status.tokens":             "~%d Tokens",
+	"status.tokens_max":         "~%d/%d Tokens (%d%%)",
+	"status.usage":              "%s Tokens in dieser Sitzung",
+	"status.usage_cost":         "%s Tokens, $%.4f in dieser Sitzung",
+	"status.budget_session":     "$%.2f von $%.2f Sitzungsbudget",
+	"status.budget_daily":       "$%.2f von $%.2f Tagesbudget",
+	"status.secret":             "Geheimnis gefunden",
+	"status.suggest":            "Dateien hinzufügen?",
+	"status.read_only":          "%s steuert",
+	"status.takeover":           "Übernahme",
+
+	"help.prompt":           "ENTER: Senden • Strg+C: Beenden • ESC: KI stoppen • Strg+S: Auswählen • Strg+T: Modell",
+	"help.prompt_multiline": "ENTER: Neue Zeile • Strg+D: Senden • Strg+C: Beenden • ESC: KI stoppen • /multiline: ENTER sendet wieder",
+	"help.list":             "↑/↓: Navigieren • ENTER: Auswählen • Strg+C: Beenden",
+	"help.permission":       "↑/↓: Navigieren • ENTER: Auswählen • D: Details • Strg+C: Beenden",
+	"help.picker":           "Tippen zum Filtern • ↑/↓: Navigieren • ENTER: Auswählen • ESC: Abbrechen",
+	"help.tools":            "↑/↓: Navigieren • LEERTASTE/ENTER: Ändern, wann das Werkzeug benutzt werden darf • ESC: Fertig",
+	"help.branches":         "↑/↓: Navigieren • ENTER: Zum Zweig wechseln • ESC: Abbrechen",
+	"help.select":           "↑/↓: Nachricht wählen • ENTER: Kopieren • >: Zitieren • LEERTASTE: Werkzeug auf-/zuklappen • ESC: Fertig • Text mit der Maus markieren, um ihn zu kopieren",
+	"help.secret":           "R: Schwärzen und senden • S: Trotzdem senden • C/ESC: Abbrechen und bearbeiten",
+	"help.suggest":          "Y: Hinzufügen • N: Weglassen • ESC: Ohne die übrigen senden",
+	"help.read_only":        "ENTER: Steuerung anfragen • Strg+S: Auswählen • Strg+C: Trennen",
+	"help.takeover":         "Y: Übergeben • N/ESC: Weiter steuern",
+
+	"permission.title":   "Werkzeug-Freigabe",
+	"permission.tool":    "Werkzeug: %s",
+	"permission.once":    "Nur dieses Mal erlauben",
+	"permission.session": "Erlauben und in dieser Sitzung nicht mehr fragen",
+	"permission.deny":    "Nicht erlauben, zurück zur Eingabe",
+
+	"permission.args":       "Argumente:",
+	"permission.paths":      "Pfade:",
+	"permission.where":      "Läuft in:",
+	"permission.env":        "Umgebung:",
+	"permission.effects":    "Änderungen:",
+	"permission.more_lines": "  ... %d weitere Zeilen",
+
+	"queue.title":    "Werkzeugaufrufe (%d/%d erledigt)",
+	"queue.queued":   "wartet",
+	"queue.approval": "wartet auf Freigabe",
+	"queue.running":  "läuft",
+	"queue.done":     "erledigt",
+	"queue.skipped":  "übersprungen",
+
+	"prompt.attachment":      "[📎 Einfügung %d: %d Zeilen]",
+	"prompt.attachment_help": "Rücktaste: Entfernen",
+
+	"prompt.placeholder": "Nachricht eingeben...",
+	"list.select_model":  "Modell auswählen",
+	"list.tools":         "Werkzeuge (ask = fragen, session = diese Sitzung, always = immer, disabled = aus)",
+	"list.branches":      "Zweige (* ist der, in dem das Gespräch gerade ist)",
+	"picker.filter":      "Filter:",
+	"picker.no_matches":  "Keine passenden Modelle",
+	"picker.model":       "MODELL",
+	"picker.params":      "PARAMETER",
+	"picker.quant":       "QUANT",
+	"picker.size":        "GRÖSSE",
+	"picker.modified":    "GEÄNDERT",
+	"picker.context":     "KONTEXT",
+	"picker.tools":       "Werkzeuge",
+	"picker.current":     "aktiv",
+	"error":              "Fehler: %v",
+
+	"chat.interrupted":      " [unterbrochen, /retry zum Verwerfen]",
+	"chat.provider_down":    "Der KI-Anbieter ist nicht erreichbar: %v",
+	"chat.no_model":         "%v, wähle ein anderes Modell",
+	"chat.stalled":          "Die KI hat %s lang nichts gesendet, die Antwort wurde abgebrochen, /retry versucht es erneut",
+	"chat.banned":           "Die Antwort wurde abgebrochen, da sie das banned_output-Muster %s enthielt, /retry versucht es erneut",
+	"chat.skipped_busy":     "Eingabe übersprungen, die KI ist beschäftigt: %s",
+	"chat.running_tool":     "Führe Werkzeug %s aus mit den Argumenten: %s",
+	"chat.tool_output":      "Ausgabe des Werkzeugs:",
+	"chat.tool_request":     "Ich muss das Werkzeug \"%s\" mit den Argumenten %s verwenden",
+	"chat.tool_running":     "läuft",
+	"chat.tool_ok":          "ok",
+	"chat.tool_failed":      "fehlgeschlagen",
+	"chat.no_copy":          "Es gibt keine Nachricht %d zum Kopieren",
+	"chat.copy_failed":      "FEHLER: Kopieren fehlgeschlagen: %v",
+	"chat.copied":           "Nachricht %d in die Zwischenablage kopiert (%d Zeichen)",
+	"chat.no_redact":        "Es gibt keine Nachricht %d zum Schwärzen",
+	"chat.redacted":         "Nachricht %s in Verlauf, Historie und Kontext geschwärzt, clai.log und das Log der Sitzung enthalten sie noch",
+	"chat.secret_found":     "Die Eingabe scheint ein Geheimnis zu enthalten: %s",
+	"chat.secret_dropped":   "Die Eingabe wurde nicht gesendet: %s",
+	"chat.suggest_file":     "Wolltest du %s einbeziehen? [y/n]",
+	"chat.driving":          "Du steuerst die Sitzung",
+	"chat.watching":         "%s steuert die Sitzung, dieses Terminal kann nur zusehen, ENTER drücken, um die Steuerung anzufragen",
+	"chat.takeover_asked":   "%s wurde gebeten, die Sitzung zu übergeben",
+	"chat.takeover_request": "%s möchte die Sitzung steuern",
+	"chat.takeover_busy":    "%s hat bereits angefragt, die Sitzung zu steuern",
+	"chat.takeover_denied":  "%s steuert die Sitzung weiter",
+	"chat.peer_joined":      "%s hat sich mit der Sitzung verbunden",
+	"chat.peer_left":        "%s hat sich von der Sitzung getrennt",
+	"chat.edit_failed":      "FEHLER: Bearbeiten fehlgeschlagen: %v",
+	"preview.return":        "ENTER drücken, um zum Chat zurückzukehren",
+	"preview.failed":        "FEHLER: Vorschau fehlgeschlagen: %v",
+	"preview.unsupported":   "Dieses Terminal kann keine Bilder anzeigen, kitty oder iTerm2 verwenden oder die Datei öffnen",
+	"chat.edit_unchanged":   "Nichts wurde geändert",
+	"chat.multiline_on":     "Mehrzeilenmodus: Enter beginnt eine neue Zeile und Strg+D sendet, /multiline schaltet zurück",
+	"chat.branch_switched":  "Zu Zweig %s gewechselt, das Gespräch geht dort weiter",
+	"chat.multiline_off":    "Enter sendet die Nachricht wieder, Alt+Enter oder Strg+J beginnt eine neue Zeile",
+	"chat.tokens":           "~%d Tokens",
+	"chat.error_message":    "FEHLER: %v",
+	"chat.config_edited":    "%s gespeichert, starte clai neu, um die Änderungen zu verwenden",
+
+	"chat.tool_error.not_found":         "fehlgeschlagen: nicht gefunden",
+	"chat.tool_error.permission_denied": "fehlgeschlagen: keine Berechtigung",
+	"chat.tool_error.timeout":           "fehlgeschlagen: Zeitüberschreitung",
+	"chat.tool_error.too_large":         "fehlgeschlagen: zu groß",
+
+	"banner.status":     "%d: %s",
+	"banner.auth":       "Der Anbieter hat den API-Schlüssel abgelehnt, prüfe api_key in der Konfiguration",
+	"banner.rate_limit": "Zu viele Anfragen, warte etwas vor dem Wiederholen oder wechsle das Modell",
+	"banner.server":     "Der Anbieter hat Probleme, versuche es später noch einmal oder wechsle das Modell",
+	"banner.network":    "%s ist nicht erreichbar, prüfe, ob er läuft und base_url stimmt",
+	"banner.actions":    "r: Wiederholen • m: Modell wechseln • c: Konfiguration öffnen • ESC: Schließen",
+
+	"a11y.ready":            "clai ist bereit. Nachricht eingeben und Enter drücken, /help zeigt die Befehle und /exit beendet.",
+	"a11y.skipped_busy":     "Eingabe übersprungen, der Assistent ist beschäftigt: %s",
+	"a11y.permission_keys":  "j erlaubt einmal, s für die Sitzung, n lehnt ab",
+	"a11y.permission_ask":   "Der Assistent möchte das Werkzeug %s mit den Argumenten %s verwenden",
+	"a11y.allow_it":         "Erlauben? j erlaubt einmal, s für die Sitzung, n lehnt ab",
+	"a11y.tool_denied":      "Werkzeug %s abgelehnt",
+	"a11y.reconnecting":     "Anbieter nicht erreichbar, nächster Versuch in %s, /stop gibt auf",
+	"a11y.no_choice":        "Es gibt keine Auswahl %d",
+	"a11y.still_responding": "Der Assistent antwortet noch, warte bis er fertig ist oder gib /stop ein",
+	"a11y.thinking":         "Der Assistent denkt nach",
+	"a11y.responding":       "Der Assistent antwortet:",
+	"a11y.stopped":          "Antwort gestoppt",
+	"a11y.running_tool":     "Führe Werkzeug %s aus",
+	"a11y.tool_finished":    "Werkzeug %s fertig",
+	"a11y.goodbye":          "Auf Wiedersehen",
+	"a11y.retrying":         "Neuer Versuch",
+	"a11y.continuing":       "Setze die letzte Antwort fort",
+	"a11y.working_dir":      "Das Arbeitsverzeichnis ist jetzt %s",
+	"a11y.models":           "Verfügbare Modelle, gib eine Nummer ein, um eines zu wählen:",
+	"a11y.follow_ups":       "Vorgeschlagene Folgefragen, gib eine Nummer ein, um eine zu senden:",
+	"a11y.loading_model":    "Das Modell wird geladen",
+	"a11y.model_loaded":     "Das Modell ist geladen",
+	"a11y.budget_session":   "Achtung, $%.2f des Sitzungsbudgets von $%.2f sind verbraucht",
+	"a11y.budget_daily":     "Achtung, $%.2f des Tagesbudgets von $%.2f sind verbraucht",
+	"a11y.turn_done":        "Bereit für deine Nachricht",
+	"a11y.no_copy":          "Es gibt keine Nachricht %d zum Kopieren",
+	"a11y.copy_failed":      "Kopieren fehlgeschlagen: %v",
+	"a11y.no_editor":        "Der Editor kann in der barrierefreien Oberfläche nicht geöffnet werden",
+	"a11y.artifact":         "Eine %s-Datei wurde unter %s gespeichert",
+	"a11y.no_multiline":     "Der Mehrzeilenmodus ist in der barrierefreien Oberfläche nicht verfügbar, jede Zeile wird mit Enter gesendet",
+	"a11y.copied":           "Nachricht %d in die Zwischenablage kopiert",
+	"a11y.secret_keys":      "r schwärzt das Geheimnis und sendet, s sendet trotzdem, c bricht ab",
+	"a11y.not_sent":         "Die Eingabe wurde nicht gesendet",
+
+	"a11y.answer_once":    "j",
+	"a11y.answer_session": "s",
+	"a11y.answer_deny":    "n",
+}
+
+var es = map[string]string{
+	"status.typing":             "Escribiendo...",
+	"status.thinking":           "Pensando...",
+	"status.running_tool":       "Ejecutando herramienta...",
+	"status.ready":              "Listo",
+	"status.tool_permission":    "Se requiere permiso para la herramienta",
+	"status.select":             "Seleccionar",
+	"status.selection_required": "Selección requerida",
+	"status.connecting":         "Conectando...",
+	"status.loading_model":      "Cargando modelo...",
+	"status.provider_down":      "Proveedor inaccesible",
+	"status.request_failed":     "La petición falló",
+	"status.reconnecting":       "No se puede conectar con el proveedor, intento %d, reintentando en %s (ESC para abandonar)",
+	"status.no_model":           "Modelo no encontrado",
+	"status.tokens":             "~%d tokens",
+	"status.tokens_max":         "~%d/%d tokens (%d%%)",
+	"status.usage":              "%s tokens en esta sesión",
+	"status.usage_cost":         "%s tokens, $%.4f en esta sesión",
+	"status.budget_session":     "$%.2f de $%.2f del presupuesto de la sesión",
+	"status.budget_daily":       "$%.2f de $%.2f del presupuesto diario",
+	"status.secret":             "Secreto encontrado",
+	"status.suggest":            "¿Añadir archivos?",
+	"status.read_only":          "%s tiene el control",
+	"status.takeover":           "Relevo",
+
+	"help.prompt":           "ENTER: Enviar • Ctrl+C: Salir • ESC: Detener IA • Ctrl+S: Seleccionar • Ctrl+T: Modelo",
+	"help.prompt_multiline": "ENTER: Nueva línea • Ctrl+D: Enviar • Ctrl+C: Salir • ESC: Detener IA • /multiline: ENTER vuelve a enviar",
+	"help.list":             "↑/↓: Navegar • ENTER: Seleccionar • Ctrl+C: Salir",
+	"help.permission":       "↑/↓: Navegar • ENTER: Seleccionar • D: Detalles • Ctrl+C: Salir",
+	"help.picker":           "Escribe para filtrar • ↑/↓: Navegar • ENTER: Seleccionar • ESC: Cancelar",
+	"help.tools":            "↑/↓: Navegar • ESPACIO/ENTER: Cambiar cuándo se puede usar la herramienta • ESC: Listo",
+	"help.branches":         "↑/↓: Navegar • ENTER: Cambiar a la rama • ESC: Cancelar",
+	"help.select":           "↑/↓: Elegir mensaje • ENTER: Copiar • >: Citar • ESPACIO: Plegar/desplegar herramienta • ESC: Listo • selecciona texto con el ratón para copiarlo",
+	"help.secret":           "R: Ocultar y enviar • S: Enviar igualmente • C/ESC: Cancelar y editar",
+	"help.suggest":          "Y: Añadirlo • N: Dejarlo fuera • ESC: Enviar sin el resto",
+	"help.read_only":        "ENTER: Pedir el control • Ctrl+S: Seleccionar • Ctrl+C: Desconectar",
+	"help.takeover":         "Y: Ceder • N/ESC: Mantener el control",
+
+	"permission.title":   "Permiso de herramienta",
+	"permission.tool":    "Herramienta: %s",
+	"permission.once":    "Permitir solo esta vez",
+	"permission.session": "Permitir y no volver a preguntar en esta sesión",
+	"permission.deny":    "No permitir, volver al mensaje",
+
+	"permission.args":       "Argumentos:",
+	"permission.paths":      "Rutas:",
+	"permission.where":      "Se ejecuta en:",
+	"permission.env":        "Entorno:",
+	"permission.effects":    "Cambios:",
+	"permission.more_lines": "  ... %d líneas más",
+
+	"queue.title":    "Llamadas a herramientas (%d/%d hechas)",
+	"queue.queued":   "en cola",
+	"queue.approval": "esperando permiso",
+	"queue.running":  "ejecutándose",
+	"queue.done":     "hecha",
+	"queue.skipped":  "omitida",
+
+	"prompt.attachment":      "[📎 pegado %d: %d líneas]",
+	"prompt.attachment_help": "Retroceso: Quitar",
+
+	"prompt.placeholder": "Escribe tu mensaje...",
+	"list.select_model":  "Selecciona el modelo a usar",
+	"list.tools":         "Herramientas (ask = preguntar, session = esta sesión, always = siempre, disabled = desactivada)",
+	"list.branches":      "Ramas (* es la rama en la que está la conversación)",
+	"picker.filter":      "Filtro:",
+	"picker.no_matches":  "Ningún modelo coincide",
+	"picker.model":       "MODELO",
+	"picker.params":      "PARÁMETROS",
+	"picker.quant":       "CUANT",
+	"picker.size":        "TAMAÑO",
+	"picker.modified":    "MODIFICADO",
+	"picker.context":     "CONTEXTO",
+	"picker.tools":       "herramientas",
+	"picker.current":     "en uso",
+	"error":              "Error: %v",
+
+	"chat.interrupted":      " [interrumpido, /retry para descartar]",
+	"chat.provider_down":    "No se puede contactar con el proveedor de IA: %v",
+	"chat.no_model":         "%v, elige otro modelo",
+	"chat.stalled":          "La IA no envió nada durante %s y se detuvo la respuesta, usa /retry para intentarlo de nuevo",
+	"chat.banned":           "Se detuvo la respuesta porque coincidía con el patrón de banned_output %s, usa /retry para intentarlo de nuevo",
+	"chat.skipped_busy":     "Mensaje omitido, la IA está ocupada: %s",
+	"chat.running_tool":     "Ejecutando la herramienta %s con los argumentos: %s",
+	"chat.tool_output":      "Salida de la herramienta:",
+	"chat.tool_request":     "Necesito usar la herramienta \"%s\" con los argumentos %s",
+	"chat.tool_running":     "en curso",
+	"chat.tool_ok":          "ok",
+	"chat.tool_failed":      "falló",
+	"chat.no_copy":          "No hay ningún mensaje %d para copiar",
+	"chat.copy_failed":      "ERROR: no se pudo copiar: %v",
+	"chat.copied":           "Mensaje %d copiado al portapapeles (%d caracteres)",
+	"chat.no_redact":        "No hay ningún mensaje %d para ocultar",
+	"chat.redacted":         "Mensaje %s ocultado en la transcripción, el historial y el contexto, clai.log y el log de la sesión todavía lo tienen",
+	"chat.secret_found":     "El mensaje parece contener un secreto: %s",
+	"chat.secret_dropped":   "El mensaje no se envió: %s",
+	"chat.suggest_file":     "¿Querías incluir %s? [y/n]",
+	"chat.driving":          "Tienes el control de la sesión",
+	"chat.watching":         "%s tiene el control de la sesión, esta terminal es de solo lectura, pulsa ENTER para pedir el control",
+	"chat.takeover_asked":   "Se pidió a %s que ceda la sesión",
+	"chat.takeover_request": "%s quiere tomar el control de la sesión",
+	"chat.takeover_busy":    "%s ya pidió el control de la sesión",
+	"chat.takeover_denied":  "%s mantiene el control de la sesión",
+	"chat.peer_joined":      "%s se conectó a la sesión",
+	"chat.peer_left":        "%s se desconectó de la sesión",
+	"chat.edit_failed":      "ERROR: no se pudo editar: %v",
+	"preview.return":        "Pulsa ENTER para volver al chat",
+	"preview.failed":        "ERROR: no se pudo mostrar la vista previa: %v",
+	"preview.unsupported":   "Este terminal no puede mostrar imágenes, usa kitty o iTerm2 o abre el archivo",
+	"chat.edit_unchanged":   "No se cambió nada",
+	"chat.multiline_on":     "Modo multilínea: Enter empieza una línea nueva y Ctrl+D envía, /multiline vuelve al modo normal",
+	"chat.branch_switched":  "Se cambió a la rama %s, la conversación sigue en ella",
+	"chat.multiline_off":    "Enter vuelve a enviar el mensaje, Alt+Enter o Ctrl+J empieza una línea nueva",
+	"chat.tokens":           "~%d tokens",
+	"chat.error_message":    "ERROR: %v",
+	"chat.config_edited":    "Guardado %s, reinicia clai para usar los cambios",
+
+	"chat.tool_error.not_found":         "falló: no encontrado",
+	"chat.tool_error.permission_denied": "falló: permiso denegado",
+	"chat.tool_error.timeout":           "falló: tiempo agotado",
+	"chat.tool_error.too_large":         "falló: demasiado grande",
+
+	"banner.status":     "%d: %s",
+	"banner.auth":       "El proveedor rechazó la clave de API, revisa api_key en la configuración",
+	"banner.rate_limit": "Demasiadas peticiones, espera un poco antes de reintentar o cambia de modelo",
+	"banner.server":     "El proveedor tiene problemas, reintenta más tarde o cambia de modelo",
+	"banner.network":    "No se puede conectar con %s, comprueba que está en marcha y que base_url es correcto",
+	"banner.actions":    "r: Reintentar • m: Cambiar modelo • c: Abrir configuración • ESC: Cerrar",
+
+	"a11y.ready":            "clai está listo. Escribe un mensaje y pulsa enter, /help muestra los comandos y /exit sale.",
+	"a11y.skipped_busy":     "Mensaje omitido, el asistente está ocupado: %s",
+	"a11y.permission_keys":  "Escribe s para permitir una vez, p para toda la sesión o n para denegar",
+	"a11y.permission_ask":   "El asistente quiere usar la herramienta %s con los argumentos %s",
+	"a11y.allow_it":         "¿Permitirlo? Escribe s para permitir una vez, p para toda la sesión o n para denegar",
+	"a11y.tool_denied":      "Herramienta %s denegada",
+	"a11y.reconnecting":     "No se puede conectar con el proveedor, reintentando en %s, escribe /stop para abandonar",
+	"a11y.no_choice":        "No existe la opción %d",
+	"a11y.still_responding": "El asistente sigue respondiendo, espera a que termine o escribe /stop",
+	"a11y.thinking":         "El asistente está pensando",
+	"a11y.responding":       "El asistente responde:",
+	"a11y.stopped":          "Respuesta detenida",
+	"a11y.running_tool":     "Ejecutando la herramienta %s",
+	"a11y.tool_finished":    "La herramienta %s terminó",
+	"a11y.goodbye":          "Adiós",
+	"a11y.retrying":         "Reintentando",
+	"a11y.continuing":       "Continuando la última respuesta",
+	"a11y.working_dir":      "El directorio de trabajo ahora es %s",
+	"a11y.models":           "Modelos disponibles, escribe un número para elegir uno:",
+	"a11y.follow_ups":       "Preguntas sugeridas, escribe un número para enviar una:",
+	"a11y.loading_model":    "Cargando el modelo",
+	"a11y.model_loaded":     "El modelo está cargado",
+	"a11y.budget_session":   "Atención, se han gastado $%.2f del presupuesto de la sesión de $%.2f",
+	"a11y.budget_daily":     "Atención, se han gastado $%.2f del presupuesto diario de $%.2f",
+	"a11y.turn_done":        "Listo para tu mensaje",
+	"a11y.no_copy":          "No hay ningún mensaje %d para copiar",
+	"a11y.copy_failed":      "No se pudo copiar: %v",
+	"a11y.no_editor":        "El editor no se puede abrir con la interfaz accesible",
+	"a11y.artifact":         "Se guardó un archivo %s en %s",
+	"a11y.no_multiline":     "El modo multilínea no está disponible en la interfaz accesible, cada línea se envía al pulsar enter",
+	"a11y.copied":           "Mensaje %d copiado al portapapeles",
+	"a11y.secret_keys":      "Escribe r para ocultar el secreto y enviar, s para enviar igualmente o c para cancelar",
+	"a11y.not_sent":         "El mensaje no se envió",
+
+	"a11y.answer_once":    "s",
+	"a11y.answer_session": "p",
+	"a11y.answer_deny":    "n",
+}
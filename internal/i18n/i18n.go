@@ -0,0 +1,144 @@
+// Package i18n holds the strings shown in the UI so they can be translated,
+// the locale is picked from the config or the environment
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Fallback is the locale used for strings missing from the current catalog
+const Fallback = "en"
+
+var (
+	mu      sync.RWMutex
+	locale  = Fallback
+	catalog = map[string]map[string]string{
+		"en": en,
+		"de": de,
+		"es": es,
+	}
+)
+
+// Detect works out the locale to use, the configured one wins over the
+// LC_ALL, LC_MESSAGES and LANG environment variables
+func Detect(configured string) string {
+	if configured != "" {
+		return Normalize(configured)
+	}
+
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			return Normalize(v)
+		}
+	}
+
+	return Fallback
+}
+
+// Normalize turns locales like de_DE.UTF-8 or pt-BR into de-de and pt-br,
+// the C and POSIX locales are treated as English
+func Normalize(loc string) string {
+	loc, _, _ = strings.Cut(loc, ".")
+	loc, _, _ = strings.Cut(loc, "@")
+	loc = strings.ToLower(strings.ReplaceAll(loc, "_", "-"))
+	if loc == "" || loc == "c" || loc == "posix" {
+		return Fallback
+	}
+	return loc
+}
+
+// SetLocale sets the locale to translate to, a region that has no catalog
+// falls back to its language (de-at to de) and then to English.  The locale
+// that was chosen is returned.
+func SetLocale(loc string) string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	loc = Normalize(loc)
+	lang, _, _ := strings.Cut(loc, "-")
+	for _, l := range []string{loc, lang} {
+		if _, ok := catalog[l]; ok {
+			locale = l
+			return l
+		}
+	}
+
+	log.Printf("[i18n] no catalog for locale %s, using %s", loc, Fallback)
+	locale = Fallback
+	return Fallback
+}
+
+// Locale returns the current locale
+func Locale() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return locale
+}
+
+// LoadDir loads the catalogs from the JSON files in the dir, named after their
+// locale like fr.json or pt-br.json.  They hold the keys to translate and are
+// merged over the built-in catalogs, so they can also change the English
+// strings.  A missing dir is not an error.
+func LoadDir(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, fn := range files {
+		data, err := os.ReadFile(fn)
+		if err != nil {
+			return err
+		}
+
+		var msgs map[string]string
+		if err := json.Unmarshal(data, &msgs); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", fn, err)
+		}
+
+		Add(Normalize(strings.TrimSuffix(filepath.Base(fn), ".json")), msgs)
+	}
+
+	return nil
+}
+
+// Add merges the messages into the catalog for the locale
+func Add(loc string, msgs map[string]string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if catalog[loc] == nil {
+		catalog[loc] = map[string]string{}
+	}
+	for k, v := range msgs {
+		catalog[loc][k] = v
+	}
+}
+
+// T translates the message with the key, formatting it with the args like
+// fmt.Sprintf.  Keys missing from the current locale use the English string,
+// and the key itself is returned if it isn't known at all.
+func T(key string, args ...any) string {
+	mu.RLock()
+	msg, ok := catalog[locale][key]
+	if !ok {
+		msg, ok = catalog[Fallback][key]
+	}
+	mu.RUnlock()
+
+	if !ok {
+		log.Printf("[i18n] missing message %s", key)
+		msg = key
+	}
+
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
@@ -0,0 +1,79 @@
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "de-de", Normalize("de_DE.UTF-8"))
+	assert.Equal(t, "pt-br", Normalize("pt-BR"))
+	assert.Equal(t, "sr-rs", Normalize("sr_RS@latin"))
+	assert.Equal(t, "en", Normalize("C"))
+	assert.Equal(t, "en", Normalize("POSIX"))
+	assert.Equal(t, "en", Normalize(""))
+}
+
+func TestDetect(t *testing.T) {
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "")
+	t.Setenv("LANG", "es_ES.UTF-8")
+
+	assert.Equal(t, "es-es", Detect(""))
+	assert.Equal(t, "de", Detect("de"))
+
+	t.Setenv("LC_ALL", "de_AT.UTF-8")
+	assert.Equal(t, "de-at", Detect(""))
+}
+
+func TestSetLocale(t *testing.T) {
+	defer SetLocale(Fallback)
+
+	assert.Equal(t, "de", SetLocale("de_AT.UTF-8"))
+	assert.Equal(t, "Bereit", T("status.ready"))
+	assert.Equal(t, "Werkzeug: grep", T("permission.tool", "grep"))
+
+	assert.Equal(t, "en", SetLocale("xx_YY"))
+	assert.Equal(t, "Ready", T("status.ready"))
+}
+
+func TestTFallsBack(t *testing.T) {
+	defer SetLocale(Fallback)
+
+	Add("xx", map[string]string{"status.ready": "Xready"})
+	SetLocale("xx")
+	assert.Equal(t, "Xready", T("status.ready"))
+	assert.Equal(t, "Thinking...", T("status.thinking"))
+	assert.Equal(t, "no.such.key", T("no.such.key"))
+}
+
+func TestLoadDir(t *testing.T) {
+	defer SetLocale(Fallback)
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "fr.json"), []byte(`{"status.ready": "Prêt"}`), 0644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte(`not a catalog`), 0644)
+
+	assert.NoError(t, LoadDir(dir))
+	assert.Equal(t, "fr", SetLocale("fr_FR.UTF-8"))
+	assert.Equal(t, "Prêt", T("status.ready"))
+
+	assert.NoError(t, LoadDir(filepath.Join(dir, "missing")))
+
+	os.WriteFile(filepath.Join(dir, "bad.json"), []byte(`{`), 0644)
+	assert.Error(t, LoadDir(dir))
+}
+
+func TestCatalogsAreComplete(t *testing.T) {
+	for _, loc := range []string{"de", "es"} {
+		for key := range en {
+			assert.Contains(t, catalog[loc], key, "%s is missing from %s", key, loc)
+		}
+		for key := range catalog[loc] {
+			assert.Contains(t, en, key, "%s in %s is not in en", key, loc)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Package logs writes the clai log, rotating it once it gets too big
+package logs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// File is a log file that is rotated once it grows past the max size, the
+// old logs are kept as fn.1 (the newest) to fn.<keep>
+type File struct {
+	mu      sync.Mutex
+	fn      string
+	maxSize int64
+	keep    int
+	f       *os.File
+	size    int64
+}
+
+// Open opens the log for appending, a maxSize of 0 never rotates it
+func Open(fn string, maxSize int64, keep int) (*File, error) {
+	l := &File{fn: fn, maxSize: maxSize, keep: keep}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *File) open() error {
+	f, err := os.OpenFile(l.fn, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f, l.size = f, fi.Size()
+	return nil
+}
+
+func (l *File) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(p)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return 0, fmt.Errorf("failed to rotate log: %w", err)
+		}
+	}
+
+	n, err := l.f.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the old logs along, dropping the oldest, and starts a new one
+func (l *File) rotate() error {
+	l.f.Close()
+
+	os.Remove(Rotated(l.fn, l.keep))
+	for i := l.keep - 1; i > 0; i-- {
+		os.Rename(Rotated(l.fn, i), Rotated(l.fn, i+1))
+	}
+	if l.keep > 0 {
+		os.Rename(l.fn, Rotated(l.fn, 1))
+	} else {
+		os.Remove(l.fn)
+	}
+
+	return l.open()
+}
+
+func (l *File) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
+
+// Rotated is the name of the nth old log
+func Rotated(fn string, n int) string {
+	return fmt.Sprintf("%s.%d", fn, n)
+}
+
+// Clear removes the log and the old logs, returning how many were removed
+func Clear(fn string) (int, error) {
+	old, _ := filepath.Glob(fn + ".*")
+	removed := 0
+	for _, f := range append([]string{fn}, old...) {
+		if f != fn {
+			if _, err := strconv.Atoi(strings.TrimPrefix(f, fn+".")); err != nil {
+				continue
+			}
+		}
+		err := os.Remove(f)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Tail returns the last n lines of the log
+func Tail(fn string, n int) ([]string, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// read backwards a block at a time until there are enough lines
+	const block = 32 * 1024
+	var data []byte
+	for off := fi.Size(); off > 0 && bytes.Count(data, []byte("\n")) <= n; {
+		size := min(off, block)
+		off -= size
+		buf := make([]byte, size)
+		if _, err := f.ReadAt(buf, off); err != nil && err != io.EOF {
+			return nil, err
+		}
+		data = append(buf, data...)
+	}
+
+	if len(data) == 0 {
+		return nil, nil
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	return lines[max(0, len(lines)-n):], nil
+}
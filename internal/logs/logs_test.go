@@ -0,0 +1,43 @@
+package logs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFile(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "clai.log")
+	l, err := Open(fn, 20, 2)
+	assert.NoError(t, err)
+
+	for i := 1; i <= 8; i++ {
+		fmt.Fprintf(l, "line %d\n", i)
+	}
+	assert.NoError(t, l.Close())
+
+	// 7 bytes a line, so 2 fit in each file and the oldest are dropped
+	for name, want := range map[string]string{fn: "line 7\nline 8\n", Rotated(fn, 1): "line 5\nline 6\n", Rotated(fn, 2): "line 3\nline 4\n"} {
+		data, err := os.ReadFile(name)
+		assert.NoError(t, err)
+		assert.Equal(t, want, string(data))
+	}
+	assert.NoFileExists(t, Rotated(fn, 3))
+
+	lines, err := Tail(fn, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"line 8"}, lines)
+
+	lines, err = Tail(fn, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"line 7", "line 8"}, lines)
+
+	assert.NoError(t, os.WriteFile(fn+".bak", nil, 0644))
+	removed, err := Clear(fn)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, removed)
+	assert.FileExists(t, fn+".bak")
+}
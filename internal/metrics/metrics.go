@@ -0,0 +1,163 @@
+// Package metrics counts the requests, tool runs and tokens of a session and
+// serves them in the Prometheus text format, for keeping an eye on clai when
+// it is shared or left running
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds in seconds of the request latency
+// histogram, responses from models can take minutes
+var latencyBuckets = []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// histogram counts the observations that fall in each bucket
+type histogram struct {
+	counts []uint64 // one per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) observe(v float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(latencyBuckets))
+	}
+	for i, le := range latencyBuckets {
+		if v <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// Metrics holds the counts, the labels of each series are joined into the key
+type Metrics struct {
+	mu       sync.Mutex
+	requests map[[3]string]uint64     // provider, model, status
+	latency  map[[2]string]*histogram // provider, model
+	tools    map[[2]string]uint64     // tool, status
+	tokens   map[[2]string]uint64     // model, type
+}
+
+// New creates empty metrics
+func New() *Metrics {
+	return &Metrics{
+		requests: map[[3]string]uint64{},
+		latency:  map[[2]string]*histogram{},
+		tools:    map[[2]string]uint64{},
+		tokens:   map[[2]string]uint64{},
+	}
+}
+
+// Request counts a request to the provider and how long it took
+func (m *Metrics) Request(provider, model string, took time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[[3]string{provider, model, status(err)}]++
+
+	h := m.latency[[2]string{provider, model}]
+	if h == nil {
+		h = &histogram{}
+		m.latency[[2]string{provider, model}] = h
+	}
+	h.observe(took.Seconds())
+}
+
+// ToolRun counts a run of a tool
+func (m *Metrics) ToolRun(tool string, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st := "ok"
+	if failed {
+		st = "error"
+	}
+	m.tools[[2]string{tool, st}]++
+}
+
+// Tokens counts the tokens used by a request
+func (m *Metrics) Tokens(model string, prompt, completion int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tokens[[2]string{model, "prompt"}] += uint64(prompt)
+	m.tokens[[2]string{model, "completion"}] += uint64(completion)
+}
+
+func status(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// WriteTo writes the metrics in the Prometheus text format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+
+	b.WriteString("# HELP clai_requests_total Requests sent to the provider.\n")
+	b.WriteString("# TYPE clai_requests_total counter\n")
+	for _, k := range sortedKeys(m.requests) {
+		fmt.Fprintf(&b, "clai_requests_total{provider=%q,model=%q,status=%q} %d\n", k[0], k[1], k[2], m.requests[k])
+	}
+
+	b.WriteString("# HELP clai_request_duration_seconds How long requests to the provider took, until the whole response was streamed.\n")
+	b.WriteString("# TYPE clai_request_duration_seconds histogram\n")
+	for _, k := range sortedKeys(m.latency) {
+		h := m.latency[k]
+		var cum uint64
+		for i, le := range latencyBuckets {
+			cum += h.counts[i]
+			fmt.Fprintf(&b, "clai_request_duration_seconds_bucket{provider=%q,model=%q,le=\"%g\"} %d\n", k[0], k[1], le, cum)
+		}
+		fmt.Fprintf(&b, "clai_request_duration_seconds_bucket{provider=%q,model=%q,le=\"+Inf\"} %d\n", k[0], k[1], h.count)
+		fmt.Fprintf(&b, "clai_request_duration_seconds_sum{provider=%q,model=%q} %g\n", k[0], k[1], h.sum)
+		fmt.Fprintf(&b, "clai_request_duration_seconds_count{provider=%q,model=%q} %d\n", k[0], k[1], h.count)
+	}
+
+	b.WriteString("# HELP clai_tool_runs_total Tool calls that were run.\n")
+	b.WriteString("# TYPE clai_tool_runs_total counter\n")
+	for _, k := range sortedKeys(m.tools) {
+		fmt.Fprintf(&b, "clai_tool_runs_total{tool=%q,status=%q} %d\n", k[0], k[1], m.tools[k])
+	}
+
+	b.WriteString("# HELP clai_tokens_total Tokens used, estimated when the provider doesn't say.\n")
+	b.WriteString("# TYPE clai_tokens_total counter\n")
+	for _, k := range sortedKeys(m.tokens) {
+		fmt.Fprintf(&b, "clai_tokens_total{model=%q,type=%q} %d\n", k[0], k[1], m.tokens[k])
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Handler serves the metrics
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.WriteTo(w)
+	})
+}
+
+func sortedKeys[K [2]string | [3]string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+	})
+	return keys
+}
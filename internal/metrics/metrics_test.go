@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteTo(t *testing.T) {
+	m := New()
+	m.Request("ollama", "qwen3", 2*time.Second, nil)
+	m.Request("ollama", "qwen3", 40*time.Second, errors.New("stalled"))
+	m.ToolRun("read_file", false)
+	m.ToolRun("write_file", true)
+	m.Tokens("qwen3", 100, 20)
+	m.Tokens("qwen3", 50, 5)
+
+	var b strings.Builder
+	_, err := m.WriteTo(&b)
+	assert.NoError(t, err)
+	out := b.String()
+
+	for _, line := range []string{
+		`clai_requests_total{provider="ollama",model="qwen3",status="ok"} 1`,
+		`clai_requests_total{provider="ollama",model="qwen3",status="error"} 1`,
+		`clai_request_duration_seconds_bucket{provider="ollama",model="qwen3",le="2.5"} 1`,
+		`clai_request_duration_seconds_bucket{provider="ollama",model="qwen3",le="60"} 2`,
+		`clai_request_duration_seconds_bucket{provider="ollama",model="qwen3",le="+Inf"} 2`,
+		`clai_request_duration_seconds_sum{provider="ollama",model="qwen3"} 42`,
+		`clai_tool_runs_total{tool="write_file",status="error"} 1`,
+		`clai_tokens_total{model="qwen3",type="prompt"} 150`,
+		`clai_tokens_total{model="qwen3",type="completion"} 25`,
+	} {
+		assert.Contains(t, out, line+"\n")
+	}
+}
@@ -0,0 +1,86 @@
+// Package notify reports how a headless run went to a webhook, so long jobs
+// can be left running
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxTaskLen is how much of the task is put in the text of the message
+const maxTaskLen = 200
+
+// Summary is what is posted to the webhook, the text is there so that Slack
+// (and the chat tools that copy its webhooks) show it as the message
+type Summary struct {
+	Text         string   `json:"text"`
+	Session      string   `json:"session"`
+	Task         string   `json:"task"`
+	Status       string   `json:"status"` // "ok" or "failed"
+	Error        string   `json:"error,omitempty"`
+	FilesChanged []string `json:"files_changed"`
+	Cost         float64  `json:"cost"`     // USD
+	Duration     float64  `json:"duration"` // seconds
+}
+
+// NewSummary summarizes the run, err is why it failed if it did
+func NewSummary(session, task string, files []string, cost float64, took time.Duration, err error) Summary {
+	s := Summary{
+		Session:      session,
+		Task:         task,
+		Status:       "ok",
+		FilesChanged: files,
+		Cost:         cost,
+		Duration:     took.Seconds(),
+	}
+	if s.FilesChanged == nil {
+		s.FilesChanged = []string{}
+	}
+
+	short := []rune(strings.Join(strings.Fields(task), " "))
+	if len(short) > maxTaskLen {
+		short = append(short[:maxTaskLen], '…')
+	}
+
+	var b strings.Builder
+	if err != nil {
+		s.Status = "failed"
+		s.Error = err.Error()
+		fmt.Fprintf(&b, "❌ clai failed: %s\n%s\n", string(short), err)
+	} else {
+		fmt.Fprintf(&b, "✅ clai finished: %s\n", string(short))
+	}
+	if len(files) > 0 {
+		fmt.Fprintf(&b, "Files changed: %s\n", strings.Join(files, ", "))
+	}
+	fmt.Fprintf(&b, "Took %s, cost $%.4f", took.Round(time.Second), cost)
+	s.Text = b.String()
+
+	return s
+}
+
+// Send posts the summary to the webhook
+func Send(url string, s Summary) error {
+	body, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	c := http.Client{Timeout: 30 * time.Second}
+	resp, err := c.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook answered %s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSend(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer srv.Close()
+
+	s := NewSummary("abc123", "add a --json flag", []string{"main.go"}, 0.0123, 90*time.Second, nil)
+	assert.NoError(t, Send(srv.URL, s))
+	assert.Equal(t, "ok", got["status"])
+	assert.Equal(t, []any{"main.go"}, got["files_changed"])
+	assert.Equal(t, 90.0, got["duration"])
+	assert.Equal(t, "✅ clai finished: add a --json flag\nFiles changed: main.go\nTook 1m30s, cost $0.0123", got["text"])
+
+	s = NewSummary("abc123", "add a --json flag", nil, 0, time.Second, errors.New("the budget has been spent"))
+	assert.Equal(t, "failed", s.Status)
+	assert.Equal(t, "the budget has been spent", s.Error)
+	assert.Equal(t, []string{}, s.FilesChanged)
+}
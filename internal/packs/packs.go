@@ -0,0 +1,284 @@
+// Package packs loads the slash commands, personas and prompts that a team
+// shares in git repos, the repos are listed in the project's .clai.yml and
+// only fetched by `clai packs update`
+package packs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/penguinpowernz/clai/internal/prompt"
+)
+
+// ProjectFile is the file in the project that lists the packs
+const ProjectFile = ".clai.yml"
+
+// Pack is a git repo of commands, personas and prompts
+type Pack struct {
+	URL string `json:"url"`
+	Ref string `json:"ref,omitempty"` // tag, branch or commit to pin the pack to, the default branch if empty
+}
+
+// Name is the pack's name, the last part of its URL
+func (p Pack) Name() string {
+	return strings.TrimSuffix(filepath.Base(strings.TrimRight(p.URL, "/")), ".git")
+}
+
+// dir is where the pack is checked out
+func (p Pack) dir(dataDir string) string {
+	return filepath.Join(dataDir, "packs", reUnsafe.ReplaceAllString(strings.TrimSuffix(p.URL, ".git"), "_"))
+}
+
+var reUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Project is what the project's .clai.yml says
+type Project struct {
+	Packs []Pack `json:"packs"`
+
+	// the tools the AI is told about in this project, on top of the config
+	EnabledTools  []string `json:"enabled_tools,omitempty"`
+	DisabledTools []string `json:"disabled_tools,omitempty"`
+
+	// let the tools see .env, private keys and credentials in this project
+	AllowSensitive bool `json:"allow_sensitive,omitempty"`
+}
+
+// FindProject reads the .clai.yml in dir or the dirs above it up to the root
+// of the repo, the path is empty if there isn't one
+func FindProject(dir string) (string, Project, error) {
+	var p Project
+	fn, text, err := prompt.FindProject(dir, ProjectFile)
+	if err != nil || fn == "" {
+		return fn, p, err
+	}
+
+	if err := yaml.Unmarshal([]byte(text), &p); err != nil {
+		return fn, p, fmt.Errorf("failed to read %s: %w", fn, err)
+	}
+	return fn, p, nil
+}
+
+// Tools returns the enabled and disabled tools of the config once the
+// project's are applied.  The project can only take tools away, so its
+// enabled_tools are the ones that are also in the config's, if it has any.
+func (p Project) Tools(enabled, disabled []string) ([]string, []string) {
+	switch {
+	case p.EnabledTools == nil:
+	case enabled == nil:
+		enabled = p.EnabledTools
+	default:
+		// empty rather than nil when there are none, so no tools are enabled
+		both := []string{}
+		for _, name := range p.EnabledTools {
+			if slices.Contains(enabled, name) {
+				both = append(both, name)
+			}
+		}
+		enabled = both
+	}
+	return enabled, append(disabled, p.DisabledTools...)
+}
+
+// Command is a slash command from a pack, the template is sent as the prompt
+type Command struct {
+	Name        string
+	Description string
+	Usage       string
+	Template    string
+	Pack        string
+}
+
+// Prompt fills in the args where the template has {{args}}, or adds them to
+// the end if it doesn't
+func (c Command) Prompt(args []string) string {
+	joined := strings.Join(args, " ")
+	if strings.Contains(c.Template, "{{args}}") {
+		return strings.TrimSpace(strings.ReplaceAll(c.Template, "{{args}}", joined))
+	}
+	if joined == "" {
+		return strings.TrimSpace(c.Template)
+	}
+	return strings.TrimSpace(c.Template) + "\n\n" + joined
+}
+
+// Loaded is what was found in a pack
+type Loaded struct {
+	Pack
+	Commands []Command         // from commands/<name>.md
+	Personas map[string]string // from personas/<name>.md
+	Prompt   string            // from prompt.md, added to the system prompt
+}
+
+// Layer is the pack's part of the system prompt
+func (l Loaded) Layer() prompt.Layer {
+	return prompt.Layer{Name: "pack", Source: l.Name(), Text: l.Prompt}
+}
+
+// Load reads the packs that have been fetched, a pack that hasn't been, or is
+// checked out at a different ref than it is pinned to, is an error telling
+// the user to update
+func Load(dataDir string, packs []Pack) ([]Loaded, []error) {
+	var loaded []Loaded
+	var errs []error
+
+	for _, p := range packs {
+		dir := p.dir(dataDir)
+		if _, err := os.Stat(dir); err != nil {
+			errs = append(errs, fmt.Errorf("pack %s hasn't been fetched, run `clai packs update`", p.URL))
+			continue
+		}
+		if ref := checkedOut(dir); ref != p.Ref {
+			errs = append(errs, fmt.Errorf("pack %s is pinned to %q but %q is checked out, run `clai packs update`", p.URL, p.Ref, ref))
+		}
+
+		l, err := load(p, dir)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("pack %s: %w", p.URL, err))
+			continue
+		}
+		loaded = append(loaded, l)
+	}
+
+	return loaded, errs
+}
+
+func load(p Pack, dir string) (Loaded, error) {
+	l := Loaded{Pack: p, Personas: map[string]string{}}
+
+	files, err := filepath.Glob(filepath.Join(dir, "commands", "*.md"))
+	if err != nil {
+		return l, err
+	}
+	sort.Strings(files)
+	for _, fn := range files {
+		data, err := os.ReadFile(fn)
+		if err != nil {
+			return l, err
+		}
+		cmd, err := parseCommand(data)
+		if err != nil {
+			return l, fmt.Errorf("%s: %w", filepath.Base(fn), err)
+		}
+		cmd.Name = strings.TrimSuffix(filepath.Base(fn), ".md")
+		cmd.Pack = p.Name()
+		l.Commands = append(l.Commands, cmd)
+	}
+
+	files, err = filepath.Glob(filepath.Join(dir, "personas", "*.md"))
+	if err != nil {
+		return l, err
+	}
+	for _, fn := range files {
+		data, err := os.ReadFile(fn)
+		if err != nil {
+			return l, err
+		}
+		l.Personas[strings.TrimSuffix(filepath.Base(fn), ".md")] = string(data)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "prompt.md"))
+	if err != nil && !os.IsNotExist(err) {
+		return l, err
+	}
+	l.Prompt = string(data)
+
+	return l, nil
+}
+
+// parseCommand reads the command's template, the description and usage can
+// be given in front matter:
+//
+//	---
+//	description: Write the release notes since the last tag
+//	usage: /release-notes [version]
+//	---
+func parseCommand(data []byte) (Command, error) {
+	var c Command
+
+	text := string(data)
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		front, body, found := strings.Cut(rest, "\n---\n")
+		if !found {
+			return c, fmt.Errorf("the front matter isn't closed with ---")
+		}
+
+		var meta struct {
+			Description string `json:"description"`
+			Usage       string `json:"usage"`
+		}
+		if err := yaml.Unmarshal([]byte(front), &meta); err != nil {
+			return c, err
+		}
+		c.Description = meta.Description
+		c.Usage = meta.Usage
+		text = body
+	}
+
+	c.Template = text
+	return c, nil
+}
+
+// refFile records the ref the pack was checked out at
+const refFile = ".clai-ref"
+
+func checkedOut(dir string) string {
+	data, _ := os.ReadFile(filepath.Join(dir, refFile))
+	return strings.TrimSpace(string(data))
+}
+
+// Update fetches the pack and checks out the ref it is pinned to, it returns
+// the commit that was checked out
+func Update(ctx context.Context, dataDir string, p Pack) (string, error) {
+	dir := p.dir(dataDir)
+
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+			return "", err
+		}
+		if _, err := git(ctx, "", "clone", "--quiet", "--no-checkout", p.URL, dir); err != nil {
+			return "", err
+		}
+	}
+
+	ref := p.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if _, err := git(ctx, dir, "fetch", "--quiet", "--tags", "origin", ref); err != nil {
+		return "", err
+	}
+	if _, err := git(ctx, dir, "checkout", "--quiet", "--force", "--detach", "FETCH_HEAD"); err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, refFile), []byte(p.Ref+"\n"), 0644); err != nil {
+		return "", err
+	}
+
+	return git(ctx, dir, "rev-parse", "--short", "HEAD")
+}
+
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	sub := args[0]
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", sub, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,88 @@
+package packs
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandPrompt(t *testing.T) {
+	c := Command{Template: "Write the release notes for {{args}}.\n"}
+	assert.Equal(t, "Write the release notes for v1.2.", c.Prompt([]string{"v1.2"}))
+
+	c = Command{Template: "Review the staged changes for security problems.\n"}
+	assert.Equal(t, "Review the staged changes for security problems.", c.Prompt(nil))
+	assert.Equal(t, "Review the staged changes for security problems.\n\nonly auth.go", c.Prompt([]string{"only", "auth.go"}))
+}
+
+func TestProjectTools(t *testing.T) {
+	p := Project{EnabledTools: []string{"read_file", "grep", "write_file"}, DisabledTools: []string{"grep"}}
+
+	// the project can't give the AI tools the config doesn't
+	enabled, disabled := p.Tools([]string{"read_file", "grep", "list_files"}, []string{"mkdir"})
+	assert.Equal(t, []string{"read_file", "grep"}, enabled)
+	assert.Equal(t, []string{"mkdir", "grep"}, disabled)
+
+	enabled, _ = p.Tools([]string{"list_files"}, nil)
+	assert.NotNil(t, enabled)
+	assert.Empty(t, enabled)
+
+	// with no enabled_tools in the config all tools are enabled
+	enabled, _ = p.Tools(nil, nil)
+	assert.Equal(t, p.EnabledTools, enabled)
+
+	enabled, _ = Project{}.Tools([]string{"grep"}, nil)
+	assert.Equal(t, []string{"grep"}, enabled)
+}
+
+func TestUpdateAndLoad(t *testing.T) {
+	repo := t.TempDir()
+	writeFile(t, filepath.Join(repo, "commands", "release-notes.md"), "---\ndescription: Write the release notes\nusage: /release-notes <version>\n---\nWrite the release notes for {{args}}.\n")
+	writeFile(t, filepath.Join(repo, "personas", "reviewer.md"), "You review code.")
+	writeFile(t, filepath.Join(repo, "prompt.md"), "Follow the team style guide.")
+	run(t, repo, "init", "--quiet")
+	run(t, repo, "add", ".")
+	run(t, repo, "-c", "user.name=t", "-c", "user.email=t@example.com", "commit", "--quiet", "-m", "pack")
+	run(t, repo, "tag", "v1")
+
+	data := t.TempDir()
+	pack := Pack{URL: repo, Ref: "v1"}
+
+	_, errs := Load(data, []Pack{pack})
+	assert.Len(t, errs, 1, "it hasn't been fetched")
+
+	_, err := Update(context.Background(), data, pack)
+	assert.NoError(t, err)
+
+	loaded, errs := Load(data, []Pack{pack})
+	assert.Empty(t, errs)
+	assert.Len(t, loaded, 1)
+	l := loaded[0]
+	assert.Equal(t, []Command{{
+		Name:        "release-notes",
+		Description: "Write the release notes",
+		Usage:       "/release-notes <version>",
+		Template:    "Write the release notes for {{args}}.\n",
+		Pack:        filepath.Base(repo),
+	}}, l.Commands)
+	assert.Equal(t, map[string]string{"reviewer": "You review code."}, l.Personas)
+	assert.Equal(t, "Follow the team style guide.", l.Layer().Text)
+
+	// moving the pin needs an update
+	_, errs = Load(data, []Pack{{URL: repo, Ref: "v2"}})
+	assert.Len(t, errs, 1)
+}
+
+func writeFile(t *testing.T, fn, text string) {
+	assert.NoError(t, os.MkdirAll(filepath.Dir(fn), 0755))
+	assert.NoError(t, os.WriteFile(fn, []byte(text), 0644))
+}
+
+func run(t *testing.T, dir string, args ...string) {
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	assert.NoError(t, err, string(out))
+}
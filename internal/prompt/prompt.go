@@ -0,0 +1,80 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Layer is one part of the system prompt, the prompt is made by joining the
+// layers in order
+type Layer struct {
+	Name     string // default, config, project, pack, persona, style or session
+	Source   string // where the text came from, e.g. the file or persona name
+	Text     string
+	Replaces bool // drops the layers before it instead of adding to them
+}
+
+// Effective returns the layers that end up in the prompt, those before the
+// last layer that replaces the others are dropped
+func Effective(layers []Layer) []Layer {
+	start := 0
+	for i, l := range layers {
+		if l.Replaces {
+			start = i
+		}
+	}
+
+	var used []Layer
+	for _, l := range layers[start:] {
+		if strings.TrimSpace(l.Text) != "" {
+			used = append(used, l)
+		}
+	}
+	return used
+}
+
+// Compose joins the layers into the system prompt
+func Compose(layers []Layer) string {
+	var parts []string
+	for _, l := range Effective(layers) {
+		parts = append(parts, strings.TrimSpace(l.Text))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// FindProject looks for the project prompt file in dir and the dirs above it
+// up to the root of the git repo, only dir is checked when it isn't in a
+// repo, the path is empty if there isn't one
+func FindProject(dir, name string) (path string, text string, err error) {
+	if name == "" {
+		return "", "", nil
+	}
+
+	var dirs []string
+	for d := dir; ; {
+		dirs = append(dirs, d)
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			break
+		}
+
+		parent := filepath.Dir(d)
+		if parent == d {
+			dirs = dirs[:1]
+			break
+		}
+		d = parent
+	}
+
+	for _, d := range dirs {
+		fn := filepath.Join(d, name)
+		data, err := os.ReadFile(fn)
+		if err == nil {
+			return fn, string(data), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", "", err
+		}
+	}
+	return "", "", nil
+}
@@ -0,0 +1,45 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompose(t *testing.T) {
+	layers := []Layer{
+		{Name: "default", Text: "built in"},
+		{Name: "config", Text: "mine", Replaces: true},
+		{Name: "project", Text: "  use tabs\n"},
+		{Name: "persona", Text: ""},
+	}
+	assert.Equal(t, "mine\n\nuse tabs", Compose(layers))
+	assert.Len(t, Effective(layers), 2)
+
+	layers = append(layers, Layer{Name: "session", Text: "only this", Replaces: true})
+	assert.Equal(t, "only this", Compose(layers))
+}
+
+func TestFindProject(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "a", "b")
+	assert.NoError(t, os.MkdirAll(sub, 0755))
+
+	// outside of a repo only the dir itself is checked
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "CLAI.md"), []byte("be brief"), 0644))
+	fn, _, err := FindProject(sub, "CLAI.md")
+	assert.NoError(t, err)
+	assert.Empty(t, fn)
+
+	assert.NoError(t, os.Mkdir(filepath.Join(root, ".git"), 0755))
+	fn, text, err := FindProject(sub, "CLAI.md")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "CLAI.md"), fn)
+	assert.Equal(t, "be brief", text)
+
+	fn, _, err = FindProject(sub, "")
+	assert.NoError(t, err)
+	assert.Empty(t, fn)
+}
@@ -0,0 +1,217 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// macros are the cron shorthands and what they are in systemd
+var macros = map[string]string{
+	"@hourly":   "hourly",
+	"@daily":    "daily",
+	"@midnight": "daily",
+	"@weekly":   "weekly",
+	"@monthly":  "monthly",
+	"@yearly":   "yearly",
+	"@annually": "yearly",
+}
+
+// cronField is the range of one of the five fields of a cron expression, and
+// the names it can use instead of numbers
+type cronField struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of the month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"", "jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "day of the week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat", "sun"}},
+}
+
+// weekdays are the days of the week as systemd names them, by their cron number
+var weekdays = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+// ParseCron checks a cron expression, five fields or one of the @ shorthands
+func ParseCron(expr string) error {
+	_, err := parseCron(expr)
+	return err
+}
+
+// parseCron splits the expression into its fields with names replaced by
+// numbers
+func parseCron(expr string) ([]string, error) {
+	if _, ok := macros[expr]; ok {
+		return nil, nil
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid cron expression %q, it needs 5 fields: minute hour day-of-month month day-of-week", expr)
+	}
+
+	for i, f := range fields {
+		var parts []string
+		for _, part := range strings.Split(f, ",") {
+			p, err := cronFields[i].parse(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q in %q: %w", cronFields[i].name, part, expr, err)
+			}
+			parts = append(parts, p)
+		}
+		fields[i] = strings.Join(parts, ",")
+	}
+	return fields, nil
+}
+
+// parse checks one part of a list in the field, like *, 5, 1-5 or */15
+func (f cronField) parse(part string) (string, error) {
+	rng, step, hasStep := strings.Cut(part, "/")
+	if hasStep {
+		if n, err := strconv.Atoi(step); err != nil || n < 1 {
+			return "", fmt.Errorf("bad step %q", step)
+		}
+	}
+	if rng == "*" {
+		return part, nil
+	}
+
+	from, to, isRange := strings.Cut(rng, "-")
+	a, err := f.value(from)
+	if err != nil {
+		return "", err
+	}
+	rng = strconv.Itoa(a)
+	if isRange {
+		b, err := f.value(to)
+		if err != nil {
+			return "", err
+		}
+		if b < a {
+			return "", fmt.Errorf("%d is before %d", b, a)
+		}
+		rng += "-" + strconv.Itoa(b)
+	}
+
+	if hasStep {
+		return rng + "/" + step, nil
+	}
+	return rng, nil
+}
+
+// value is the number of the value in the field, which can be a name
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if name != "" && strings.EqualFold(s, name) {
+			return i, nil
+		}
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("it must be from %d to %d", f.min, f.max)
+	}
+	return n, nil
+}
+
+// OnCalendar converts a cron expression to a systemd calendar event.  cron
+// runs when either the day of the month or the day of the week matches if
+// both are set, which systemd can't do, and systemd can only step through a
+// whole field.
+func OnCalendar(expr string) (string, error) {
+	if m, ok := macros[expr]; ok {
+		return m, nil
+	}
+
+	fields, err := parseCron(expr)
+	if err != nil {
+		return "", err
+	}
+	minute, hour, dom, month, dow := fields[0], fields[1], fields[2], fields[3], fields[4]
+	if dom != "*" && dow != "*" {
+		return "", fmt.Errorf("systemd can't run on either the day of the month or the day of the week, set only one of them in %q", expr)
+	}
+
+	var b strings.Builder
+	if dow != "*" {
+		days, err := calendarDays(dow)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(days + " ")
+	}
+
+	parts := []string{}
+	for i, f := range []string{month, dom, hour, minute} {
+		first := 1
+		if i >= 2 {
+			first = 0
+		}
+		p, err := calendarField(f, first, i >= 2)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, p)
+	}
+	fmt.Fprintf(&b, "*-%s-%s %s:%s:00", parts[0], parts[1], parts[2], parts[3])
+	return b.String(), nil
+}
+
+// calendarField converts a cron field to systemd, first is where */n starts
+func calendarField(f string, first int, pad bool) (string, error) {
+	if f == "*" {
+		return "*", nil
+	}
+
+	var parts []string
+	for _, part := range strings.Split(f, ",") {
+		rng, step, hasStep := strings.Cut(part, "/")
+		from, to, isRange := strings.Cut(rng, "-")
+		switch {
+		case hasStep && isRange:
+			return "", fmt.Errorf("systemd can't step through the range %q", part)
+		case hasStep && from == "*":
+			from = strconv.Itoa(first)
+		}
+
+		p := padded(from, pad)
+		if isRange {
+			p += ".." + padded(to, pad)
+		}
+		if hasStep {
+			p += "/" + step
+		}
+		parts = append(parts, p)
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// calendarDays converts the day of the week field to systemd's names
+func calendarDays(f string) (string, error) {
+	var parts []string
+	for _, part := range strings.Split(f, ",") {
+		if strings.Contains(part, "/") {
+			return "", fmt.Errorf("systemd can't step through the days of the week in %q", part)
+		}
+		from, to, isRange := strings.Cut(part, "-")
+		a, _ := strconv.Atoi(from)
+		p := weekdays[a]
+		if isRange {
+			b, _ := strconv.Atoi(to)
+			p += ".." + weekdays[b]
+		}
+		parts = append(parts, p)
+	}
+	return strings.Join(parts, ","), nil
+}
+
+func padded(n string, pad bool) string {
+	if pad && len(n) == 1 {
+		return "0" + n
+	}
+	return n
+}
@@ -0,0 +1,100 @@
+// Package schedule keeps the prompts that are run headless on a cron
+// schedule, they are run by crontab entries or systemd user timers that call
+// clai schedule run
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// The timers that can run a schedule
+const (
+	Cron    = "cron"
+	Systemd = "systemd"
+)
+
+// Schedule is a prompt that is run on a cron schedule
+type Schedule struct {
+	Name   string `json:"name"`
+	Prompt string `json:"prompt"`
+	Cron   string `json:"cron"`
+	Dir    string `json:"dir"`   // the working dir of the runs
+	Timer  string `json:"timer"` // Cron or Systemd
+}
+
+var reName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Validate checks the name and cron expression of the schedule
+func (s Schedule) Validate() error {
+	if !reName.MatchString(s.Name) {
+		return fmt.Errorf("invalid name %q, use lowercase letters, numbers, - and _", s.Name)
+	}
+	if strings.TrimSpace(s.Prompt) == "" {
+		return errors.New("the prompt is empty")
+	}
+	return ParseCron(s.Cron)
+}
+
+// Name makes a name for the schedule from the first few words of the prompt
+func Name(prompt string) string {
+	var words []string
+	for _, w := range strings.Fields(strings.ToLower(prompt)) {
+		w = strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, w)
+		if w != "" {
+			words = append(words, w)
+		}
+		if len(words) == 4 {
+			break
+		}
+	}
+	if len(words) == 0 {
+		return "schedule"
+	}
+	return strings.Join(words, "-")
+}
+
+// Load reads the schedules from the file, there are none if it doesn't exist
+func Load(fn string) ([]Schedule, error) {
+	data, err := os.ReadFile(fn)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Schedule
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	return list, nil
+}
+
+// Save writes the schedules to the file
+func Save(fn string, list []Schedule) error {
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(fn, data, 0644)
+}
+
+// Find returns the index of the named schedule, or -1
+func Find(list []Schedule, name string) int {
+	return slices.IndexFunc(list, func(s Schedule) bool { return s.Name == name })
+}
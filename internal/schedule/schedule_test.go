@@ -0,0 +1,49 @@
+package schedule
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnCalendar(t *testing.T) {
+	for expr, want := range map[string]string{
+		"0 9 * * *":         "*-*-* 09:00:00",
+		"*/15 9-17 * * 1-5": "Mon..Fri *-*-* 09..17:00/15:00",
+		"30 6 1 jan,jul *":  "*-1,7-1 06:30:00",
+		"0 0 * * sun":       "Sun *-*-* 00:00:00",
+		"@weekly":           "weekly",
+	} {
+		got, err := OnCalendar(expr)
+		assert.NoError(t, err, expr)
+		assert.Equal(t, want, got, expr)
+	}
+
+	for _, expr := range []string{"0 9 * *", "60 9 * * *", "0 9 1 * 1", "0 9 * * */2", "0 5-1 * * *"} {
+		_, err := OnCalendar(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestCrontab(t *testing.T) {
+	s := Schedule{Name: "commits", Prompt: "summarize", Cron: "0 9 * * *", Dir: "/home/me/it's here"}
+	line := CrontabLine(s, "/usr/bin/clai")
+	assert.Equal(t, `0 9 * * * cd '/home/me/it'\''s here' && '/usr/bin/clai' schedule run commits # clai schedule commits`, line)
+
+	crontab := "MAILTO=me\n@reboot backup\n"
+	crontab = UpdateCrontab(crontab, "commits", line)
+	assert.Equal(t, "MAILTO=me\n@reboot backup\n"+line+"\n", crontab)
+
+	// replaced, not added again
+	assert.Equal(t, crontab, UpdateCrontab(crontab, "commits", line))
+
+	assert.Equal(t, "MAILTO=me\n@reboot backup\n", UpdateCrontab(crontab, "commits", ""))
+	assert.Equal(t, "", UpdateCrontab("", "commits", ""))
+}
+
+func TestValidate(t *testing.T) {
+	assert.Equal(t, "summarize-yesterdays-commits", Name("Summarize yesterday's commits"))
+	assert.NoError(t, Schedule{Name: "commits", Prompt: "hi", Cron: "@daily"}.Validate())
+	assert.Error(t, Schedule{Name: "Bad Name", Prompt: "hi", Cron: "@daily"}.Validate())
+	assert.Error(t, Schedule{Name: "commits", Prompt: "hi", Cron: "daily"}.Validate())
+}
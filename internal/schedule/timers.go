@@ -0,0 +1,176 @@
+package schedule
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// marker identifies the crontab entries and systemd units of a schedule, so
+// they can be replaced or removed
+const marker = "# clai schedule "
+
+// Install adds the timer that runs the schedule with the clai binary, a
+// timer the schedule already had is replaced
+func Install(s Schedule, bin string) error {
+	if s.Timer == Systemd {
+		return installSystemd(s, bin)
+	}
+	return installCron(s, bin)
+}
+
+// Uninstall removes the timer of the schedule
+func Uninstall(s Schedule) error {
+	if s.Timer == Systemd {
+		return uninstallSystemd(s)
+	}
+	return installCron(s, "")
+}
+
+// quote quotes the string for the shell
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// CrontabLine is the crontab entry that runs the schedule, % is a newline in
+// crontab so it's escaped
+func CrontabLine(s Schedule, bin string) string {
+	cmd := fmt.Sprintf("cd %s && %s schedule run %s", quote(s.Dir), quote(bin), s.Name)
+	return fmt.Sprintf("%s %s %s%s", s.Cron, strings.ReplaceAll(cmd, "%", `\%`), marker, s.Name)
+}
+
+// UpdateCrontab replaces the entry of the named schedule in the crontab with
+// the line, an empty line removes it
+func UpdateCrontab(crontab, name, line string) string {
+	var lines []string
+	if crontab = strings.TrimRight(crontab, "\n"); crontab != "" {
+		for _, l := range strings.Split(crontab, "\n") {
+			if !strings.HasSuffix(l, marker+name) {
+				lines = append(lines, l)
+			}
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// installCron puts the entry for the schedule in the user's crontab, without
+// a bin the entry is removed
+func installCron(s Schedule, bin string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("crontab", "-l")
+	cmd.Stderr = &stderr
+	current, err := cmd.Output()
+	if err != nil && !strings.Contains(stderr.String(), "no crontab") {
+		return fmt.Errorf("crontab -l: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	line := ""
+	if bin != "" {
+		line = CrontabLine(s, bin)
+	}
+
+	cmd = exec.Command("crontab", "-")
+	cmd.Stdin = strings.NewReader(UpdateCrontab(string(current), s.Name, line))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("crontab: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// unitDir is where the systemd user units are kept
+func unitDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "systemd", "user")
+	}
+	return filepath.Join(os.Getenv("HOME"), ".config", "systemd", "user")
+}
+
+// Unit is the name of the systemd units of the schedule, without the
+// .service or .timer
+func Unit(s Schedule) string {
+	return "clai-schedule-" + s.Name
+}
+
+// SystemdUnits are the service and timer that run the schedule
+func SystemdUnits(s Schedule, bin string) (service, timer string, err error) {
+	calendar, err := OnCalendar(s.Cron)
+	if err != nil {
+		return "", "", err
+	}
+
+	service = fmt.Sprintf(`%s%s
+[Unit]
+Description=clai schedule %s
+
+[Service]
+Type=oneshot
+WorkingDirectory=%s
+ExecStart=%s schedule run %s
+`, marker, s.Name, s.Name, s.Dir, bin, s.Name)
+
+	timer = fmt.Sprintf(`%s%s
+[Unit]
+Description=clai schedule %s (%s)
+
+[Timer]
+OnCalendar=%s
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`, marker, s.Name, s.Name, s.Cron, calendar)
+
+	return service, timer, nil
+}
+
+func installSystemd(s Schedule, bin string) error {
+	service, timer, err := SystemdUnits(s, bin)
+	if err != nil {
+		return err
+	}
+
+	dir := unitDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, Unit(s)+".service"), []byte(service), 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, Unit(s)+".timer"), []byte(timer), 0644); err != nil {
+		return err
+	}
+
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	return systemctl("enable", "--now", Unit(s)+".timer")
+}
+
+func uninstallSystemd(s Schedule) error {
+	// the units might have been removed by hand already
+	systemctl("disable", "--now", Unit(s)+".timer")
+
+	for _, ext := range []string{".service", ".timer"} {
+		if err := os.Remove(filepath.Join(unitDir(), Unit(s)+ext)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return systemctl("daemon-reload")
+}
+
+func systemctl(args ...string) error {
+	out, err := exec.Command("systemctl", append([]string{"--user"}, args...)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl --user %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
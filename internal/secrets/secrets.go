@@ -0,0 +1,82 @@
+// Package secrets finds the obvious secrets in text, like private keys and
+// API tokens, so they can be caught before they are sent to the AI
+package secrets
+
+import (
+	"regexp"
+	"sort"
+)
+
+// Match is a secret found in the text, Start and End are byte offsets
+type Match struct {
+	Kind       string
+	Start, End int
+}
+
+type pattern struct {
+	kind string
+	re   *regexp.Regexp
+	sub  int // the submatch that is the secret, 0 for all of it
+}
+
+// patterns only look for secrets that have a recognisable shape, to keep
+// false alarms rare
+var patterns = []pattern{
+	{"private key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY( BLOCK)?-----[\s\S]*?(-----END [A-Z ]*PRIVATE KEY( BLOCK)?-----|$)`), 0},
+	{"AWS access key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`), 0},
+	{"AWS secret key", regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})\b`), 1},
+	{"GitHub token", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{60,})\b`), 0},
+	{"GitLab token", regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}\b`), 0},
+	{"OpenAI key", regexp.MustCompile(`\bsk-(proj-)?[A-Za-z0-9_-]{32,}\b`), 0},
+	{"Anthropic key", regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_-]{32,}\b`), 0},
+	{"Slack token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`), 0},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`), 0},
+}
+
+// Find returns the secrets in the text in the order they appear
+func Find(text string) []Match {
+	var found []Match
+	for _, p := range patterns {
+		for _, loc := range p.re.FindAllStringSubmatchIndex(text, -1) {
+			start, end := loc[2*p.sub], loc[2*p.sub+1]
+			if overlaps(found, start, end) {
+				continue
+			}
+			found = append(found, Match{Kind: p.kind, Start: start, End: end})
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].Start < found[j].Start })
+	return found
+}
+
+func overlaps(found []Match, start, end int) bool {
+	for _, m := range found {
+		if start < m.End && end > m.Start {
+			return true
+		}
+	}
+	return false
+}
+
+// Kinds returns the different kinds of secrets found, in order
+func Kinds(found []Match) []string {
+	var kinds []string
+	seen := map[string]bool{}
+	for _, m := range found {
+		if !seen[m.Kind] {
+			seen[m.Kind] = true
+			kinds = append(kinds, m.Kind)
+		}
+	}
+	return kinds
+}
+
+// Redact replaces the secrets in the text with the replacement
+func Redact(text string, found []Match, replacement string) string {
+	for i := len(found) - 1; i >= 0; i-- {
+		m := found[i]
+		text = text[:m.Start] + replacement + text[m.End:]
+	}
+	return text
+}
@@ -0,0 +1,70 @@
+package share
+
+// page shows the session and fetches it again every couple of seconds so it
+// can be watched as it goes, the messages are added as text so nothing in
+// them is run
+const page = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>clai session</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; padding: 0 1em; color: #222; }
+.msg { margin: 1em 0; padding: .5em 1em; border-left: 4px solid #ccc; }
+.user { border-color: #3a7bd5; }
+.assistant { border-color: #2e9e5b; }
+.tool { border-color: #c98a1b; }
+.role { font-size: .8em; color: #777; text-transform: uppercase; }
+pre { white-space: pre-wrap; word-wrap: break-word; font-family: monospace; margin: .3em 0 0; }
+#status { font-size: .8em; color: #777; }
+</style>
+</head>
+<body>
+<h1 id="title">clai session</h1>
+<p id="summary"></p>
+<div id="messages"></div>
+<p id="status">read-only, updates live</p>
+<script>
+let shown = "";
+
+async function update() {
+  try {
+    const resp = await fetch("messages", {cache: "no-store"});
+    if (!resp.ok) throw new Error(resp.statusText);
+    const t = await resp.json();
+    const text = JSON.stringify(t);
+    if (text === shown) return;
+    shown = text;
+
+    document.getElementById("title").textContent = t.title || "clai session";
+    document.getElementById("summary").textContent = t.summary || "";
+
+    const list = document.getElementById("messages");
+    const atEnd = window.innerHeight + window.scrollY >= document.body.offsetHeight - 50;
+    list.replaceChildren();
+    for (const m of t.messages || []) {
+      if (m.role === "system" || !m.content) continue;
+      const div = document.createElement("div");
+      div.className = "msg " + m.role;
+      const role = document.createElement("div");
+      role.className = "role";
+      role.textContent = m.role;
+      const pre = document.createElement("pre");
+      pre.textContent = m.content;
+      div.append(role, pre);
+      list.append(div);
+    }
+    if (atEnd) window.scrollTo(0, document.body.scrollHeight);
+    document.getElementById("status").textContent = "read-only, updates live";
+  } catch (e) {
+    document.getElementById("status").textContent = "the session can't be reached, it may have ended";
+  }
+}
+
+update();
+setInterval(update, 2000);
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,170 @@
+// Package share lets other people watch a session, either live over HTTP or
+// as a snapshot sent to a paste service
+package share
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/penguinpowernz/clai/internal/ai"
+)
+
+// Session is the part of the chat session that is shared
+type Session interface {
+	Title() (title, summary string)
+	Export() []ai.Message
+}
+
+// Server serves the session read-only, the token in the URL is what keeps
+// people who weren't sent the link out
+type Server struct {
+	ln    net.Listener
+	srv   *http.Server
+	token string
+}
+
+// Listen starts serving the session on addr, e.g. ":0" for any free port
+func Listen(addr string, sess Session) (*Server, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(b)
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		ln:    ln,
+		srv:   &http.Server{Handler: newHandler(token, sess), ReadHeaderTimeout: 10 * time.Second},
+		token: token,
+	}
+
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Println("[share] server stopped:", err)
+		}
+	}()
+
+	return s, nil
+}
+
+// URL is the link to send to people, when listening on all interfaces the
+// first non loopback address of this machine is used
+func (s *Server) URL() string {
+	addr := s.ln.Addr().(*net.TCPAddr)
+	host := addr.IP.String()
+	if addr.IP.IsUnspecified() {
+		host = localIP()
+	}
+	return fmt.Sprintf("http://%s/%s/", net.JoinHostPort(host, fmt.Sprint(addr.Port)), s.token)
+}
+
+// Close stops serving the session
+func (s *Server) Close() error {
+	return s.srv.Close()
+}
+
+func localIP() string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "localhost"
+	}
+	for _, a := range addrs {
+		if ip, ok := a.(*net.IPNet); ok && !ip.IP.IsLoopback() && ip.IP.To4() != nil {
+			return ip.IP.String()
+		}
+	}
+	return "localhost"
+}
+
+// transcript is what the page fetches to show the session
+type transcript struct {
+	Title    string       `json:"title,omitempty"`
+	Summary  string       `json:"summary,omitempty"`
+	Messages []ai.Message `json:"messages"`
+}
+
+func newHandler(token string, sess Session) http.Handler {
+	prefix := "/" + token + "/"
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "the session is read-only", http.StatusMethodNotAllowed)
+			return
+		}
+
+		switch r.URL.Path {
+		case prefix:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			io.WriteString(w, page)
+
+		case prefix + "messages":
+			title, summary := sess.Title()
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Cache-Control", "no-store")
+			json.NewEncoder(w).Encode(transcript{title, summary, sess.Export()})
+
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// Markdown writes the session out as markdown, for pasting
+func Markdown(title, summary string, msgs []ai.Message) string {
+	var b strings.Builder
+	if title != "" {
+		fmt.Fprintf(&b, "# %s\n\n", title)
+	}
+	if summary != "" {
+		fmt.Fprintf(&b, "%s\n\n", summary)
+	}
+
+	for _, msg := range msgs {
+		if msg.Role == "system" || strings.TrimSpace(msg.Content) == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "## %s\n\n%s\n\n", msg.Role, strings.TrimSpace(msg.Content))
+	}
+	return b.String()
+}
+
+// Paste sends the session to a paste service that takes the text as the body
+// of a POST and answers with the link to it, like paste.rs
+func Paste(url string, sess Session) (string, error) {
+	title, summary := sess.Title()
+	body := Markdown(title, summary, sess.Export())
+
+	c := http.Client{Timeout: 30 * time.Second}
+	resp, err := c.Post(url, "text/plain; charset=utf-8", strings.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("paste service answered %s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+
+	link := strings.TrimSpace(string(b))
+	if !strings.HasPrefix(link, "http") {
+		return "", fmt.Errorf("paste service didn't answer with a link: %q", link)
+	}
+	return link, nil
+}
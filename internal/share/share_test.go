@@ -0,0 +1,64 @@
+package share
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSession []ai.Message
+
+func (f fakeSession) Title() (string, string) { return "Fixing the build", "" }
+func (f fakeSession) Export() []ai.Message    { return f }
+
+func TestHandler(t *testing.T) {
+	sess := fakeSession{{Role: "user", Content: "why does it fail?"}, {Role: "assistant", Content: "a missing import"}}
+	srv := httptest.NewServer(newHandler("abc", sess))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/abc/")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	resp, err = http.Get(srv.URL + "/abc/messages")
+	assert.NoError(t, err)
+	var got transcript
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	resp.Body.Close()
+	assert.Equal(t, "Fixing the build", got.Title)
+	assert.Equal(t, []ai.Message(sess), got.Messages)
+
+	// the token has to be right
+	resp, err = http.Get(srv.URL + "/abd/messages")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp.Body.Close()
+
+	// and nothing can be changed
+	resp, err = http.Post(srv.URL+"/abc/messages", "application/json", strings.NewReader("{}"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	resp.Body.Close()
+}
+
+func TestPaste(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		io.WriteString(w, "https://paste.example.com/x1\n")
+	}))
+	defer srv.Close()
+
+	link, err := Paste(srv.URL, fakeSession{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "hello"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://paste.example.com/x1", link)
+	assert.Equal(t, "# Fixing the build\n\n## user\n\nhi\n\n## assistant\n\nhello\n\n", body)
+}
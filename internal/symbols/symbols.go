@@ -0,0 +1,157 @@
+// Package symbols finds the names of the functions, types and variables of a
+// project, from its ctags index or the files being talked about, so they can
+// be completed in the prompt
+package symbols
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TagsFiles are the names of the ctags index files that are looked for
+var TagsFiles = []string{"tags", ".tags"}
+
+var identRe = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// minLength is the length of the shortest name worth completing
+const minLength = 3
+
+// FindTags looks for a ctags index in dir and the dirs above it up to the
+// root of the git repo, the path is empty if there isn't one
+func FindTags(dir string) string {
+	for d := dir; ; {
+		for _, name := range TagsFiles {
+			fn := filepath.Join(d, name)
+			if info, err := os.Stat(fn); err == nil && !info.IsDir() {
+				return fn
+			}
+		}
+
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			return ""
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return ""
+		}
+		d = parent
+	}
+}
+
+type tags struct {
+	modified time.Time
+	names    []string
+	files    map[string][]string
+}
+
+// the indexes that were read, they are read again when they change
+var (
+	cacheMu sync.Mutex
+	cache   = map[string]tags{}
+)
+
+// ReadTags returns the names in a ctags index
+func ReadTags(fn string) ([]string, error) {
+	t, err := readTags(fn)
+	return t.names, err
+}
+
+// TagFiles returns the files each name in a ctags index is defined in, the
+// paths are relative to the directory of the index
+func TagFiles(fn string) (map[string][]string, error) {
+	t, err := readTags(fn)
+	return t.files, err
+}
+
+func readTags(fn string) (tags, error) {
+	info, err := os.Stat(fn)
+	if err != nil {
+		return tags{}, err
+	}
+
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if t, ok := cache[fn]; ok && t.modified.Equal(info.ModTime()) {
+		return t, nil
+	}
+
+	f, err := os.Open(fn)
+	if err != nil {
+		return tags{}, err
+	}
+	defer f.Close()
+
+	t := tags{modified: info.ModTime(), files: map[string][]string{}}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "!_TAG_") {
+			continue
+		}
+		name, rest, _ := strings.Cut(line, "\t")
+		if len(name) < minLength {
+			continue
+		}
+		if _, seen := t.files[name]; !seen {
+			t.names = append(t.names, name)
+		}
+		file, _, _ := strings.Cut(rest, "\t")
+		if file != "" && !slices.Contains(t.files[name], file) {
+			t.files[name] = append(t.files[name], file)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return tags{}, err
+	}
+
+	cache[fn] = t
+	return t, nil
+}
+
+// FromText returns the identifiers in the text, like the content of a file
+func FromText(text string) []string {
+	return identRe.FindAllString(text, -1)
+}
+
+// Prefix returns the identifier being typed at the end of the line
+func Prefix(line string) string {
+	i := len(line)
+	for i > 0 && isIdent(line[i-1]) {
+		i--
+	}
+	return line[i:]
+}
+
+func isIdent(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// Complete returns the names from the sources that start with the prefix,
+// sorted and without duplicates
+func Complete(prefix string, sources ...[]string) []string {
+	if prefix == "" {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	var matches []string
+	for _, names := range sources {
+		for _, name := range names {
+			if len(name) >= minLength && len(name) > len(prefix) && strings.HasPrefix(name, prefix) && !seen[name] {
+				seen[name] = true
+				matches = append(matches, name)
+			}
+		}
+	}
+
+	sort.Strings(matches)
+	return matches
+}
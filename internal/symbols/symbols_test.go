@@ -0,0 +1,35 @@
+package symbols
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComplete(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, ".git"), 0755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "cmd", "app"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "tags"), []byte(
+		"!_TAG_FILE_FORMAT\t2\t/extended format/\n"+
+			"ParseConfig\tconfig/config.go\t/^func ParseConfig(fn string) (*Config, error) {$/;\"\tf\n"+
+			"ParseArgs\tcmd/app/main.go\t/^func ParseArgs() {$/;\"\tf\n"+
+			"Parser\tparse/parse.go\t/^type Parser struct {$/;\"\tt\n"), 0644))
+
+	fn := FindTags(filepath.Join(dir, "cmd", "app"))
+	assert.Equal(t, filepath.Join(dir, "tags"), fn)
+
+	names, err := ReadTags(fn)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Pars", Prefix("why does `Pars"))
+	assert.Equal(t, []string{"ParseArgs", "ParseConfig", "ParseOptions", "Parser"},
+		Complete("Pars", names, FromText("func ParseOptions() { Parser{} }")))
+	assert.Empty(t, Complete("", names))
+
+	files, err := TagFiles(fn)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"config/config.go"}, files["ParseConfig"])
+}
@@ -0,0 +1,75 @@
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/penguinpowernz/clai/config"
+)
+
+// defaultSandboxImage is used when the sandbox is enabled but no image is set
+const defaultSandboxImage = "alpine:latest"
+
+// command creates the command for a tool to run.  When a sandbox is configured
+// the command runs in a throwaway container that only has the working dir
+// bind mounted (at the same path, so paths don't need translating) and no
+// network access.
+func command(cfg config.Config, workingDir string, name string, args ...string) *exec.Cmd {
+	if cfg.Sandbox == "" {
+		cmd := exec.Command(name, args...)
+		cmd.Dir = workingDir
+		return cmd
+	}
+
+	image := cfg.SandboxImage
+	if image == "" {
+		image = defaultSandboxImage
+	}
+
+	absWorking, err := filepath.Abs(workingDir)
+	if err != nil {
+		absWorking = workingDir
+	}
+
+	cargs := []string{
+		"run", "--rm", "-i",
+		"--network", "none",
+		"-v", absWorking + ":" + absWorking,
+		"-w", absWorking,
+	}
+
+	// make sure files are created with the right owner
+	switch cfg.Sandbox {
+	case "podman":
+		cargs = append(cargs, "--userns", "keep-id")
+	default:
+		cargs = append(cargs, "--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
+	}
+
+	cargs = append(cargs, image, name)
+	cargs = append(cargs, args...)
+	return exec.Command(cfg.Sandbox, cargs...)
+}
+
+// sandboxWriteFile writes the file from inside the sandbox container
+func sandboxWriteFile(cfg config.Config, workingDir, path string, data []byte) error {
+	cmd := command(cfg, workingDir, "sh", "-c", `mkdir -p "$(dirname "$1")" && cat > "$1"`, "sh", path)
+	cmd.Stdin = bytes.NewReader(data)
+	return runSandboxed(cmd)
+}
+
+// sandboxMkdir creates the directory from inside the sandbox container
+func sandboxMkdir(cfg config.Config, workingDir, path string) error {
+	return runSandboxed(command(cfg, workingDir, "mkdir", "-p", path))
+}
+
+func runSandboxed(cmd *exec.Cmd) error {
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommand(t *testing.T) {
+	cfg := config.Default()
+
+	cmd := command(*cfg, "/src/project", "grep", "foo")
+	assert.Equal(t, []string{"grep", "foo"}, cmd.Args)
+
+	cfg.Sandbox = "docker"
+	cmd = command(*cfg, "/src/project", "grep", "foo")
+	assert.Equal(t, []string{
+		"docker", "run", "--rm", "-i",
+		"--network", "none",
+		"-v", "/src/project:/src/project",
+		"-w", "/src/project",
+		"--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()),
+		"alpine:latest", "grep", "foo",
+	}, cmd.Args)
+
+	cfg.Sandbox = "podman"
+	cfg.SandboxImage = "golang:1.24"
+	cmd = command(*cfg, "/src/project", "ls")
+	assert.Equal(t, "podman", cmd.Args[0])
+	assert.Equal(t, []string{"--userns", "keep-id", "golang:1.24", "ls"}, cmd.Args[len(cmd.Args)-4:])
+}
@@ -0,0 +1,153 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/penguinpowernz/clai/config"
+)
+
+// Details says what a tool call will do, so the user can check it before
+// allowing it
+type Details struct {
+	Args    string   // the args as they will be used, with the defaults filled in
+	Paths   []string // the paths in the args made absolute
+	Where   string   // what runs the tool
+	Env     []string // the names of the environment variables the tool gets
+	Effects []string // what the call changes, e.g. "creates /src/main.go (120 bytes)"
+}
+
+// effectsFunc works out what a tool call changes, without changing it
+type effectsFunc func(cfg config.Config, input json.RawMessage, workingDir string) []string
+
+// Describe works out the details of the tool call
+func (r *Registry) Describe(call ToolUse, workingDir string) Details {
+	var d Details
+	cfg := *r.cfg
+
+	t, found := Tools(r.All()).find(call.Name)
+	if !found {
+		d.Args = string(call.Input)
+		return d
+	}
+
+	input, err := applyDefaults(t.Function.Parameters, call.Input)
+	if err != nil {
+		input = call.Input
+	}
+
+	var b bytes.Buffer
+	if err := json.Indent(&b, input, "", "  "); err == nil {
+		d.Args = b.String()
+	} else {
+		d.Args = string(input)
+	}
+
+	d.Paths = argPaths(input, workingDir)
+
+	switch {
+	case t.plugin != "":
+		d.Where = describePlugin(cfg.PluginLimits, t.plugin)
+		for _, v := range restrictedEnv(cfg.PluginLimits.Env) {
+			d.Env = append(d.Env, strings.SplitN(v, "=", 2)[0])
+		}
+		d.Effects = []string{"unknown, the plugin can do anything it is allowed to"}
+
+	case cfg.Sandbox != "":
+		image := cfg.SandboxImage
+		if image == "" {
+			image = defaultSandboxImage
+		}
+		d.Where = fmt.Sprintf("a %s container from %s, with only %s mounted and no network", cfg.Sandbox, image, absPath(workingDir, "."))
+
+	default:
+		d.Where = "clai, on this machine"
+	}
+
+	if t.effects != nil {
+		d.Effects = t.effects(cfg, input, workingDir)
+	} else if t.plugin == "" {
+		d.Effects = []string{"none, it only reads"}
+	}
+
+	return d
+}
+
+// Changes returns the files a call to a tool that writes them changed, nil for
+// tools that only read
+func (r *Registry) Changes(call ToolUse, workingDir string) []string {
+	t, found := Tools(r.All()).find(call.Name)
+	if !found || t.effects == nil {
+		return nil
+	}
+
+	input, err := applyDefaults(t.Function.Parameters, call.Input)
+	if err != nil {
+		input = call.Input
+	}
+	return argPaths(input, workingDir)
+}
+
+// argPaths returns the args that look like paths, made absolute
+func argPaths(input json.RawMessage, workingDir string) []string {
+	var args map[string]any
+	if err := json.Unmarshal(input, &args); err != nil {
+		return nil
+	}
+
+	var paths []string
+	for name, v := range args {
+		if !strings.Contains(name, "path") && !strings.Contains(name, "file") && !strings.Contains(name, "dir") {
+			continue
+		}
+		switch v := v.(type) {
+		case string:
+			paths = append(paths, absPath(workingDir, v))
+		case []any:
+			for _, p := range v {
+				if p, ok := p.(string); ok {
+					paths = append(paths, absPath(workingDir, p))
+				}
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func describePlugin(limits config.PluginLimits, fn string) string {
+	var l []string
+	if limits.Timeout > 0 {
+		l = append(l, "at most "+limits.Timeout.String())
+	}
+	if limits.CPUSeconds > 0 {
+		l = append(l, fmt.Sprintf("%d CPU seconds", limits.CPUSeconds))
+	}
+	if limits.MemoryMB > 0 {
+		l = append(l, fmt.Sprintf("%d MB of memory", limits.MemoryMB))
+	}
+	if limits.DenyNetwork {
+		l = append(l, "no network")
+	}
+
+	if len(l) == 0 {
+		return "the plugin " + fn + ", with no limits"
+	}
+	return "the plugin " + fn + ", with " + strings.Join(l, ", ")
+}
+
+// fileEffect says whether writing size bytes to fn creates or overwrites it
+func fileEffect(fn string, size int) string {
+	fi, err := os.Stat(fn)
+	switch {
+	case err != nil:
+		return fmt.Sprintf("creates %s (%d bytes)", fn, size)
+	case fi.IsDir():
+		return fmt.Sprintf("fails, %s is a directory", fn)
+	}
+	return fmt.Sprintf("overwrites %s (%d bytes with %d bytes)", fn, fi.Size(), size)
+}
@@ -0,0 +1,36 @@
+package tools
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribe(t *testing.T) {
+	cfg := config.Default()
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "old.txt"), []byte("12345"), 0644))
+	r := NewRegistry(cfg)
+
+	d := r.Describe(ToolUse{Name: "write_file", Input: json.RawMessage(`{"path":"new.txt","content":"hi"}`)}, dir)
+	assert.Equal(t, []string{filepath.Join(dir, "new.txt")}, d.Paths)
+	assert.Equal(t, []string{"creates " + filepath.Join(dir, "new.txt") + " (2 bytes)"}, d.Effects)
+	assert.Contains(t, d.Args, "\n  \"content\": \"hi\"")
+	assert.Equal(t, "clai, on this machine", d.Where)
+
+	d = r.Describe(ToolUse{Name: "write_file", Input: json.RawMessage(`{"path":"old.txt","content":"hi"}`)}, dir)
+	assert.Equal(t, []string{"overwrites " + filepath.Join(dir, "old.txt") + " (5 bytes with 2 bytes)"}, d.Effects)
+
+	d = r.Describe(ToolUse{Name: "mkdir", Input: json.RawMessage(`{"path":"."}`)}, dir)
+	assert.Equal(t, []string{"none, " + dir + " already exists"}, d.Effects)
+
+	cfg.Sandbox = "podman"
+	d = r.Describe(ToolUse{Name: "read_file", Input: json.RawMessage(`{"path":"old.txt"}`)}, dir)
+	assert.Equal(t, []string{"none, it only reads"}, d.Effects)
+	assert.Contains(t, d.Where, "podman container from "+defaultSandboxImage)
+	assert.Empty(t, d.Env)
+}
@@ -4,13 +4,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"os/exec"
 
 	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/files"
 )
 
-func init() { DefaultTools = append(DefaultTools, _diff) }
-
 var _diff = Tool{
 	exec: diff,
 	Type: "function",
@@ -51,16 +49,22 @@ func diff(cfg config.Config, input json.RawMessage, workingDir string) (string,
 	d.File1 = Sanitize(d.File1)
 	d.File2 = Sanitize(d.File2)
 
-	if IsExcluded(cfg, d.File1) {
-		return "ERROR: the requested path for file1 does not exist", nil
+	if files.IsExcluded(cfg, workingDir, d.File1) {
+		return "", notFound(d.File1)
+	}
+
+	if files.IsExcluded(cfg, workingDir, d.File2) {
+		return "", notFound(d.File2)
 	}
 
-	if IsExcluded(cfg, d.File2) {
-		return "ERROR: the requested path for file2 does not exist", nil
+	for _, fn := range []string{d.File1, d.File2} {
+		if !files.InScope(cfg, workingDir, fn) {
+			return "", denied(fn, files.ErrOutOfScope)
+		}
 	}
 
 	cmdStr := fmt.Sprintf("diff %s %s %s", d.Args, d.File1, d.File2)
-	cmd := exec.Command("sh", "-c", cmdStr)
+	cmd := command(cfg, workingDir, "sh", "-c", cmdStr)
 	var sout, serr bytes.Buffer
 	cmd.Stdout = &sout
 	cmd.Stderr = &serr
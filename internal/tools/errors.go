@@ -0,0 +1,73 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+)
+
+// ErrorCode says why a tool failed, so the AI can decide what to try next and
+// the UI can show it
+type ErrorCode string
+
+const (
+	ErrNotFound         ErrorCode = "not_found"
+	ErrPermissionDenied ErrorCode = "permission_denied"
+	ErrTimeout          ErrorCode = "timeout"
+	ErrTooLarge         ErrorCode = "too_large"
+)
+
+// Error is a tool failure with a code and the details the AI needs to work
+// around it, like the path or the limit that was hit
+type Error struct {
+	Code ErrorCode
+	Msg  string
+	Meta map[string]any
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+// notFound is the error for a path that doesn't exist, or that the tools
+// aren't allowed to see
+func notFound(path string) *Error {
+	return &Error{Code: ErrNotFound, Msg: "the requested path does not exist", Meta: map[string]any{"path": path}}
+}
+
+// denied is the error for a path the tools may not touch, the reason is one
+// of the errors of files.CheckAccess
+func denied(path string, reason error) *Error {
+	return &Error{Code: ErrPermissionDenied, Msg: reason.Error(), Meta: map[string]any{"path": path}}
+}
+
+// errorDetails returns the code and metadata of the error, the code of plain
+// errors is worked out where it can be
+func errorDetails(err error) (ErrorCode, map[string]any) {
+	var te *Error
+	switch {
+	case errors.As(err, &te):
+		return te.Code, te.Meta
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrNotFound, nil
+	case errors.Is(err, fs.ErrPermission):
+		return ErrPermissionDenied, nil
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrTimeout, nil
+	}
+	return "", nil
+}
+
+// errorContent is how a failure is given to the AI, the code and metadata
+// are on a line of JSON after the message so it can branch on them
+func errorContent(err error, code ErrorCode, meta map[string]any) string {
+	if code == "" {
+		return fmt.Sprintf("Error: %v", err)
+	}
+
+	details := map[string]any{"code": code}
+	maps.Copy(details, meta)
+	return fmt.Sprintf("Error [%s]: %v\n%s", code, err, jsonDump(details))
+}
@@ -0,0 +1,48 @@
+package tools
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToolErrors(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "big.txt"), []byte("one\ntwo\nthree\n"), 0644))
+
+	cfg := config.Default()
+	cfg.MaxFileSize = 8
+	r := NewRegistry(cfg)
+	run := func(name, input string) ToolResult {
+		return r.Execute(ToolUse{ID: "call_1", Name: name, Input: json.RawMessage(input)}, dir, nil)
+	}
+
+	res := run("read_file", `{"path": "missing.go"}`)
+	assert.True(t, res.IsError)
+	assert.Equal(t, ErrNotFound, res.Code)
+
+	res = run("read_file", `{"path": "../etc/passwd"}`)
+	assert.Equal(t, ErrPermissionDenied, res.Code)
+	assert.Equal(t, map[string]any{"path": "../etc/passwd"}, res.Meta)
+
+	// the model gets the code and the details as JSON it can branch on
+	res = run("read_file", `{"path": "big.txt"}`)
+	assert.Equal(t, ErrTooLarge, res.Code)
+	assert.Equal(t, `Error [too_large]: file too large: 14 bytes (max: 8), read it with start_line and end_line
+{"code":"too_large","max":8,"path":"big.txt","size":14}`, res.Content)
+	assert.False(t, run("read_file", `{"path": "big.txt", "start_line": 2, "end_line": 2}`).IsError)
+
+	res = run("nope", `{}`)
+	assert.Equal(t, ErrNotFound, res.Code)
+	assert.Equal(t, "Unknown tool: nope", res.Content)
+
+	// errors without a known cause don't get a code
+	res = run("read_file", `{"path": 1}`)
+	assert.True(t, res.IsError)
+	assert.Empty(t, res.Code)
+	assert.Contains(t, res.Content, "Error: ")
+}
@@ -3,13 +3,11 @@ package tools
 import (
 	"bytes"
 	"encoding/json"
-	"os/exec"
 
 	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/files"
 )
 
-func init() { DefaultTools = append(DefaultTools, _filetype) }
-
 var _filetype = Tool{
 	exec: filetype,
 	Type: "function",
@@ -39,11 +37,15 @@ func filetype(cfg config.Config, input json.RawMessage, workingDir string) (stri
 
 	d.Path = Sanitize(d.Path)
 
-	if IsExcluded(cfg, d.Path) {
-		return "ERROR: the requested path does not exist", nil
+	if files.IsExcluded(cfg, workingDir, d.Path) {
+		return "", notFound(d.Path)
+	}
+
+	if !files.InScope(cfg, workingDir, d.Path) {
+		return "", denied(d.Path, files.ErrOutOfScope)
 	}
 
-	cmd := exec.Command("file", d.Path)
+	cmd := command(cfg, workingDir, "file", d.Path)
 	var sout, serr bytes.Buffer
 	cmd.Stdout = &sout
 	cmd.Stderr = &serr
@@ -4,14 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"os/exec"
 	"strings"
 
 	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/files"
 )
 
-func init() { DefaultTools = append(DefaultTools, _find) }
-
 var _find = Tool{
 	exec: find,
 	Type: "function",
@@ -44,16 +42,21 @@ func find(cfg config.Config, input json.RawMessage, workingDir string) (string,
 		return "", err
 	}
 
-	if IsExcluded(cfg, d.Path) {
-		return "ERROR: the requested path does not exist", nil
+	if files.IsExcluded(cfg, workingDir, d.Path) || files.IsHidden(cfg, workingDir, d.Path) {
+		return "", notFound(d.Path)
+	}
+
+	paths := files.ScopedPaths(cfg, workingDir, d.Path)
+	if len(paths) == 0 {
+		return "", notFound(d.Path)
 	}
 
-	cmdStr := fmt.Sprintf("find %s %s", d.Path, d.RawArgs)
+	cmdStr := fmt.Sprintf("find %s %s", strings.Join(paths, " "), d.RawArgs)
 	if strings.Contains(cmdStr, "-exec") {
-		return "ERROR: exec is not allowed", nil
+		return "", &Error{Code: ErrPermissionDenied, Msg: "exec is not allowed"}
 	}
 
-	cmd := exec.Command("sh", "-c", cmdStr)
+	cmd := command(cfg, workingDir, "sh", "-c", cmdStr)
 
 	var sout, serr bytes.Buffer
 
@@ -67,7 +70,7 @@ func find(cfg config.Config, input json.RawMessage, workingDir string) (string,
 	okLines := []string{}
 	for _, line := range strings.Split(sout.String(), "\n") {
 		line = strings.TrimSpace(line)
-		if line != "" && !IsExcluded(cfg, line) {
+		if line != "" && !files.IsExcluded(cfg, workingDir, line) && !files.IsHidden(cfg, workingDir, line) && files.InScope(cfg, workingDir, line) {
 			okLines = append(okLines, line)
 		}
 	}
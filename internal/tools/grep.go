@@ -3,14 +3,13 @@ package tools
 import (
 	"bytes"
 	"encoding/json"
-	"os/exec"
 	"path/filepath"
+	"strings"
 
 	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/files"
 )
 
-func init() { DefaultTools = append(DefaultTools, _grep) }
-
 var _grep = Tool{
 	exec: grep,
 	Type: "function",
@@ -27,20 +26,25 @@ var _grep = Tool{
 				"path": {
 					Type:        "string",
 					Description: "The path to search",
+					Default:     ".",
 				},
 				"regex": {
 					Type:        "boolean",
 					Description: "Whether to treat the pattern as PCRE",
+					Default:     false,
 				},
 				"recursive": {
 					Type:        "boolean",
-					Description: "Whether to search recursively (default: false)",
+					Description: "Whether to search recursively",
+					Default:     false,
 				},
 				"case_insensitive": {
 					Type:        "boolean",
-					Description: "Whether to match case sensitively (default: false)",
+					Description: "Whether to ignore case when matching",
+					Default:     false,
 				},
 			},
+			Required: []string{"pattern"},
 		},
 	},
 }
@@ -62,17 +66,16 @@ func grep(cfg config.Config, input json.RawMessage, workingDir string) (string,
 		params.Path = "."
 	}
 
-	if IsExcluded(cfg, params.Path) {
-		return "ERROR: the requested path does not exist", nil
-	}
-
-	if !Exists(params.Path) {
-		return "ERROR: the requested path does not exist", nil
+	if files.IsExcluded(cfg, workingDir, params.Path) || files.IsHidden(cfg, workingDir, params.Path) {
+		return "", notFound(params.Path)
 	}
 
 	targetPath := filepath.Join(workingDir, params.Path)
+	if !Exists(targetPath) {
+		return "", notFound(params.Path)
+	}
 
-	cmd := exec.Command("grep")
+	cmd := command(cfg, workingDir, "grep")
 	bufo := bytes.NewBuffer(nil)
 	bufe := bytes.NewBuffer(nil)
 	cmd.Stdout = bufo
@@ -90,13 +93,39 @@ func grep(cfg config.Config, input json.RawMessage, workingDir string) (string,
 		cmd.Args = append(cmd.Args, "-P")
 	}
 
-	cmd.Args = append(cmd.Args, targetPath)
+	targets := files.ScopedPaths(cfg, workingDir, targetPath)
+	if len(targets) == 0 {
+		return "", notFound(params.Path)
+	}
+	cmd.Args = append(cmd.Args, targets...)
 
 	_ = cmd.Run()
 
+	stdout := bufo.String()
+	if params.Recursive {
+		stdout = withoutHidden(cfg, workingDir, stdout)
+	}
+
 	return jsonDump(map[string]any{
-		"stdout":     bufo.String(),
+		"stdout":     stdout,
 		"stderr":     bufe.String(),
 		"exitstatus": cmd.ProcessState.ExitCode(),
 	}), nil
 }
+
+// withoutHidden drops the matches grep found in hidden files, each line of
+// its output starts with the file it is from
+func withoutHidden(cfg config.Config, workingDir, output string) string {
+	if cfg.IncludeHidden {
+		return output
+	}
+
+	var lines []string
+	for _, line := range strings.SplitAfter(output, "\n") {
+		if fn, _, ok := strings.Cut(line, ":"); ok && files.IsHidden(cfg, workingDir, fn) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "")
+}
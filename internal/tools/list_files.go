@@ -8,10 +8,9 @@ import (
 	"strings"
 
 	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/files"
 )
 
-func init() { DefaultTools = append(DefaultTools, _listFiles) }
-
 var _listFiles = Tool{
 	exec: listFiles,
 	Type: "function",
@@ -28,6 +27,7 @@ var _listFiles = Tool{
 				"recursive": {
 					Type:        "boolean",
 					Description: "Whether to list files recursively in subdirectories.",
+					Default:     false,
 				},
 			},
 			Required: []string{"path"},
@@ -46,32 +46,35 @@ func listFiles(cfg config.Config, input json.RawMessage, workingDir string) (str
 
 	targetPath := filepath.Join(workingDir, params.Path)
 
-	if IsExcluded(cfg, targetPath) {
-		return "ERROR: the requested path does not exist", nil
+	if files.IsExcluded(cfg, workingDir, params.Path) || files.IsHidden(cfg, workingDir, targetPath) || !files.LeadsToScope(cfg, workingDir, targetPath) {
+		return "", notFound(params.Path)
 	}
 
-	var files []string
+	var listed []string
 	if params.Recursive {
 		err := filepath.Walk(targetPath, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
 			}
+			if path == targetPath {
+				return nil
+			}
 			relPath, _ := filepath.Rel(workingDir, path)
 
 			fileType := "file"
 			if info.IsDir() {
-				if IsExcluded(cfg, path) {
+				if files.IsExcluded(cfg, workingDir, relPath) || files.IsHidden(cfg, workingDir, path) || !files.LeadsToScope(cfg, workingDir, path) {
 					return filepath.SkipDir
 				}
 
 				fileType = "directory"
 			}
 
-			if IsExcluded(cfg, path) {
+			if files.IsExcluded(cfg, workingDir, relPath) || files.IsHidden(cfg, workingDir, path) || !files.LeadsToScope(cfg, workingDir, path) {
 				return nil
 			}
 
-			files = append(files, fmt.Sprintf("%s (%s, %d bytes)", relPath, fileType, info.Size()))
+			listed = append(listed, fmt.Sprintf("%s (%s, %d bytes)", relPath, fileType, info.Size()))
 			return nil
 		})
 		if err != nil {
@@ -92,13 +95,14 @@ func listFiles(cfg config.Config, input json.RawMessage, workingDir string) (str
 				fileType = "directory"
 			}
 
-			if IsExcluded(cfg, entry.Name()) {
+			entryPath := filepath.Join(targetPath, entry.Name())
+			if files.IsExcluded(cfg, workingDir, entryPath) || files.IsHidden(cfg, workingDir, entryPath) || !files.LeadsToScope(cfg, workingDir, entryPath) {
 				continue
 			}
 
-			files = append(files, fmt.Sprintf("%s (%s, %d bytes)", entry.Name(), fileType, info.Size()))
+			listed = append(listed, fmt.Sprintf("%s (%s, %d bytes)", entry.Name(), fileType, info.Size()))
 		}
 	}
 
-	return strings.Join(files, "\n"), nil
+	return strings.Join(listed, "\n"), nil
 }
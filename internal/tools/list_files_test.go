@@ -0,0 +1,40 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListFilesHidden(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, ".config", "app"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".config", "app", "ci.yml"), nil, 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".editorconfig"), nil, 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), nil, 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), nil, 0644))
+
+	cfg := config.Default()
+	out, err := listFiles(*cfg, []byte(`{"path": ".", "recursive": true}`), dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "main.go (file, 0 bytes)", out)
+
+	_, err = listFiles(*cfg, []byte(`{"path": ".config"}`), dir)
+	assert.Equal(t, notFound(".config"), err)
+
+	cfg.IncludeHidden = true
+	out, err = listFiles(*cfg, []byte(`{"path": "."}`), dir)
+	assert.NoError(t, err)
+	assert.Contains(t, out, ".editorconfig (file")
+	assert.Contains(t, out, ".config (directory")
+	assert.NotContains(t, out, ".env", "files with secrets stay hidden")
+
+	cfg.AllowSensitive = true
+	out, _ = listFiles(*cfg, []byte(`{"path": "."}`), dir)
+	assert.Contains(t, out, ".env (file")
+
+	assert.Equal(t, "main.go:1:x\n", withoutHidden(*config.Default(), dir, ".env:1:x\nmain.go:1:x\n.github/ci.yml:2:y\n"))
+}
@@ -6,13 +6,13 @@ import (
 	"path/filepath"
 
 	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/files"
 )
 
-func init() { DefaultTools = append(DefaultTools, _mkdir) }
-
 var _mkdir = Tool{
-	exec: mkdir,
-	Type: "function",
+	exec:    mkdir,
+	effects: mkdirEffects,
+	Type:    "function",
 	Function: &FunctionSchema{
 		Name:        "mkdir",
 		Description: "Create a directory, -p is used by default",
@@ -39,17 +39,39 @@ func mkdir(cfg config.Config, input json.RawMessage, workingDir string) (string,
 
 	d.Path = Sanitize(d.Path)
 
-	if IsExcluded(cfg, d.Path) {
-		return "ERROR: the requested path does not exist", nil
+	if files.IsExcluded(cfg, workingDir, d.Path) {
+		return "", notFound(d.Path)
 	}
 
 	targetPath := filepath.Join(workingDir, d.Path)
-	err := os.MkdirAll(targetPath, 0755)
+	if !files.InScope(cfg, workingDir, targetPath) {
+		return "", denied(d.Path, files.ErrOutOfScope)
+	}
+
+	var err error
+	if cfg.Sandbox != "" {
+		err = sandboxMkdir(cfg, workingDir, targetPath)
+	} else {
+		err = os.MkdirAll(targetPath, 0755)
+	}
 
-	reply := "directory was created"
 	if err != nil {
-		reply = "ERROR: " + err.Error()
+		return "", err
 	}
+	return "directory was created", nil
+}
 
-	return reply, nil
+func mkdirEffects(cfg config.Config, input json.RawMessage, workingDir string) []string {
+	d := struct {
+		Path string `json:"path"`
+	}{}
+	if err := json.Unmarshal(input, &d); err != nil {
+		return nil
+	}
+
+	target := absPath(workingDir, Sanitize(d.Path))
+	if _, err := os.Stat(target); err == nil {
+		return []string{"none, " + target + " already exists"}
+	}
+	return []string{"creates the directory " + target}
 }
@@ -2,20 +2,130 @@ package tools
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/penguinpowernz/clai/config"
 )
 
-func PluginTools(cfg config.Config) []Tool {
+// pluginDefinition is what a plugin outputs when called with --openai, it is
+// an OpenAI tool schema with some optional extras for clai
+type pluginDefinition struct {
+	Tool
+
+	// Override allows the plugin to replace a built-in tool of the same name
+	Override bool `json:"override,omitempty"`
+
+	// Commands are slash commands the plugin provides
+	Commands []PluginCommand `json:"commands,omitempty"`
+}
+
+// PluginCommand is a slash command provided by a plugin, when the command is
+// used the plugin is called with `--command <name>`
+type PluginCommand struct {
+	Name        string   `json:"name"`
+	Aliases     []string `json:"aliases,omitempty"`
+	Description string   `json:"description"`
+	Usage       string   `json:"usage,omitempty"`
+	plugin      string
+}
+
+// Plugin returns the path to the plugin that provides the command
+func (pc PluginCommand) Plugin() string {
+	return pc.plugin
+}
+
+// Run calls the plugin to execute the command, returning whatever the plugin
+// wrote to stdout and stderr
+func (pc PluginCommand) Run(ctx context.Context, cfg config.Config, args []string, workingDir string) (string, error) {
+	cmd, cancel, err := sandboxCommand(cfg.PluginLimits, pc.plugin, "--command", pc.Name)
+	if err != nil {
+		return "", err
+	}
+	defer cancel()
+
+	// stop the plugin if the session is cancelled
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	buf := bytes.NewBuffer(nil)
+	json.NewEncoder(buf).Encode(map[string]any{
+		"command": pc.Name,
+		"args":    args,
+		"cwd":     workingDir,
+		"config":  pluginConfig(cfg),
+	})
+
+	out := bytes.NewBuffer(nil)
+	cmd.Dir = workingDir
+	cmd.Stdin = buf
+	cmd.Stdout = out
+	cmd.Stderr = out
+	err = cmd.Run()
+
+	return out.String(), err
+}
+
+// PluginCommands returns the slash commands provided by the registered plugins
+func (r *Registry) PluginCommands() []PluginCommand {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.commands)
+}
+
+// RegisterPlugins loads the tools from the plugin directory and adds them
+// after the built-ins.  A plugin tool with the same name as an existing tool is
+// skipped unless it sets "override": true to replace a built-in, plugins can
+// be namespaced with the plugin_prefix config to avoid collisions.  An error
+// is returned for every plugin that could not be registered.
+func (r *Registry) RegisterPlugins() []error {
+	cfg := *r.cfg
+	defs, errs := loadPlugins(cfg)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, def := range defs {
+		r.commands = append(r.commands, def.Commands...)
+
+		// the plugin may only provide commands
+		if def.Function == nil {
+			continue
+		}
+
+		def.Function.Name = cfg.PluginPrefix + def.Function.Name
+		name := def.Function.Name
+
+		i := Tools(r.tools).index(name)
+		switch {
+		case i < 0:
+			r.tools = append(r.tools, def.Tool)
+
+		case def.Override && r.tools[i].plugin == "":
+			log.Printf("[tools] plugin %s overrides built-in tool %q", def.plugin, name)
+			r.tools[i] = def.Tool
+
+		default:
+			errs = append(errs, fmt.Errorf("plugin %s: tool %q conflicts with the %s tool, set plugin_prefix or \"override\": true to replace a built-in", def.plugin, name, r.tools[i].Source()))
+		}
+	}
+
+	return errs
+}
+
+func loadPlugins(cfg config.Config) ([]pluginDefinition, []error) {
 	dir := strings.ReplaceAll(cfg.PluginDir, "~", os.Getenv("HOME"))
 	files, _ := os.ReadDir(dir)
-	out := []Tool{}
+	out := []pluginDefinition{}
+	errs := []error{}
 
 	for _, file := range files {
 		if file.IsDir() {
@@ -25,37 +135,79 @@ func PluginTools(cfg config.Config) []Tool {
 		// TODO: check executable permissions
 
 		fn := filepath.Join(dir, file.Name())
-		def, err := loadToolDefinition(fn)
+		def, err := loadToolDefinition(fn, cfg.PluginLimits)
 		if err != nil {
 			log.Printf("[tools] failed to load tool definition for %s: %s", fn, err)
+			errs = append(errs, fmt.Errorf("plugin %s: failed to load tool definition: %w", fn, err))
 			continue
 		}
 
 		out = append(out, def)
 	}
 
-	return out
+	return out, errs
 }
 
-func loadToolDefinition(fn string) (Tool, error) {
-	cmd := exec.Command(fn, "--openai")
+func loadToolDefinition(fn string, limits config.PluginLimits) (pluginDefinition, error) {
+	cmd, cancel, err := sandboxCommand(limits, fn, "--openai")
+	if err != nil {
+		return pluginDefinition{}, err
+	}
+	defer cancel()
+
 	data, err := cmd.Output()
 	if err != nil {
-		return Tool{}, err
+		return pluginDefinition{}, err
 	}
-	var tool Tool
-	err = json.Unmarshal(data, &tool)
+	var def pluginDefinition
+	err = json.Unmarshal(data, &def)
 	if err != nil {
-		return Tool{}, err
+		return pluginDefinition{}, err
+	}
+
+	if def.Function == nil && len(def.Commands) == 0 {
+		return pluginDefinition{}, fmt.Errorf("tool definition has no function or commands")
 	}
 
-	tool.exec = pluginExecutor(fn)
-	return tool, nil
+	if def.Function != nil && def.Function.Name == "" {
+		return pluginDefinition{}, fmt.Errorf("tool definition has no function name")
+	}
+
+	for i := range def.Commands {
+		if def.Commands[i].Name == "" {
+			return pluginDefinition{}, fmt.Errorf("command definition has no name")
+		}
+		def.Commands[i].plugin = fn
+	}
+
+	live := pluginExecutor(fn)
+	def.live = live
+	def.exec = func(cfg config.Config, input json.RawMessage, workingDir string) (string, error) {
+		return live(cfg, input, workingDir, io.Discard)
+	}
+	def.plugin = fn
+	return def, nil
 }
 
-func pluginExecutor(fn string) toolExecutor {
-	return toolExecutor(func(cfg config.Config, input json.RawMessage, workingDir string) (string, error) {
-		cmd := exec.Command(fn)
+// pluginConfig is the config that is sent to plugins, without the API keys
+// and the webhook so a plugin can't use them
+func pluginConfig(cfg config.Config) config.Config {
+	cfg.APIKey = ""
+	cfg.WebhookURL = ""
+	cfg.Fallbacks = slices.Clone(cfg.Fallbacks)
+	for i := range cfg.Fallbacks {
+		cfg.Fallbacks[i].APIKey = ""
+	}
+	return cfg
+}
+
+func pluginExecutor(fn string) liveExecutor {
+	return liveExecutor(func(cfg config.Config, input json.RawMessage, workingDir string, live io.Writer) (string, error) {
+		cmd, cancel, err := sandboxCommand(cfg.PluginLimits, fn)
+		if err != nil {
+			return "", err
+		}
+		defer cancel()
 
 		buf := bytes.NewBuffer(nil)
 
@@ -63,15 +215,23 @@ func pluginExecutor(fn string) toolExecutor {
 		json.NewEncoder(buf).Encode(map[string]any{
 			"input":  string(input),
 			"cwd":    workingDir,
-			"config": cfg,
+			"config": pluginConfig(cfg),
 		})
 
 		out := bytes.NewBuffer(nil)
 
+		// the same writer for both so they share a pipe and stay in order
+		w := io.MultiWriter(out, live)
 		cmd.Stdin = buf
-		cmd.Stdout = out
-		cmd.Stderr = out
-		err := cmd.Run()
+		cmd.Stdout = w
+		cmd.Stderr = w
+		start := time.Now()
+		err = cmd.Run()
+
+		// the plugin was killed for running past plugin_limits.timeout
+		if timeout := cfg.PluginLimits.Timeout; err != nil && timeout > 0 && time.Since(start) >= timeout {
+			err = &Error{Code: ErrTimeout, Msg: "the plugin ran for longer than " + timeout.String(), Meta: map[string]any{"timeout": timeout.String()}}
+		}
 
 		return out.String(), err
 	})
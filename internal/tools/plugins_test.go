@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func writePlugin(t *testing.T, dir, name, def string) {
+	script := "#!/bin/sh\ncat <<'EOF'\n" + def + "\nEOF\n"
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegisterPlugins(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "a", `{"type":"function","function":{"name":"grep","description":"clashes"}}`)
+	writePlugin(t, dir, "b", `{"type":"function","function":{"name":"read_file","description":"mine"},"override":true}`)
+	writePlugin(t, dir, "c", `{"type":"function","function":{"name":"deploy","description":"new"}}`)
+
+	cfg := config.Default()
+	cfg.PluginDir = dir
+	r := &Registry{cfg: cfg, tools: []Tool{_grep, _readFile}, enabled: map[string]bool{}}
+	errs := r.RegisterPlugins()
+
+	if assert.Len(t, errs, 1) {
+		assert.Contains(t, errs[0].Error(), `"grep" conflicts with the built-in tool`)
+	}
+	assert.Equal(t, []string{"grep", "read_file", "deploy"}, GetNames(r.All()))
+
+	tool, _ := r.Find("read_file")
+	assert.Equal(t, filepath.Join(dir, "b"), tool.Source())
+
+	// with a prefix nothing clashes
+	r = &Registry{cfg: cfg, tools: []Tool{_grep, _readFile}, enabled: map[string]bool{}}
+	cfg.PluginPrefix = "team_"
+	assert.Empty(t, r.RegisterPlugins())
+	assert.Equal(t, []string{"grep", "read_file", "team_grep", "team_read_file", "team_deploy"}, GetNames(r.All()))
+}
+
+func TestPluginLiveOutput(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "deploy", `{"type":"function","function":{"name":"deploy","description":"new"}}`)
+
+	cfg := config.Default()
+	cfg.PluginDir = dir
+	r := NewRegistry(cfg)
+	assert.Empty(t, r.RegisterPlugins())
+
+	var live bytes.Buffer
+	res := r.Execute(ToolUse{Name: "deploy", Input: []byte(`{}`)}, dir, &live)
+	assert.False(t, res.IsError)
+	assert.Contains(t, res.Content, `"name":"deploy"`)
+	assert.Equal(t, res.Content, live.String())
+}
+
+func TestPluginCommands(t *testing.T) {
+	dir := t.TempDir()
+	script := `#!/bin/sh
+if [ "$1" = "--openai" ]; then
+  echo '{"commands":[{"name":"deploy-preview","description":"Deploy a preview"}]}'
+  exit 0
+fi
+echo "running $2"
+`
+	if err := os.WriteFile(filepath.Join(dir, "deploy"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default()
+	cfg.PluginDir = dir
+	r := NewRegistry(cfg)
+	assert.Empty(t, r.RegisterPlugins())
+	assert.Len(t, r.All(), len(Builtins))
+
+	cmds := r.PluginCommands()
+	if assert.Len(t, cmds, 1) {
+		out, err := cmds[0].Run(context.Background(), *cfg, nil, dir)
+		assert.NoError(t, err)
+		assert.Equal(t, "running deploy-preview\n", out)
+	}
+}
+
+func TestPluginTimeout(t *testing.T) {
+	dir := t.TempDir()
+	script := "#!/bin/sh\n[ \"$1\" = --openai ] && exec echo '{\"type\":\"function\",\"function\":{\"name\":\"slow\"}}'\nexec sleep 5\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "slow"), []byte(script), 0755))
+
+	cfg := config.Default()
+	cfg.PluginDir = dir
+	cfg.PluginLimits.Timeout = 100 * time.Millisecond
+	r := NewRegistry(cfg)
+	assert.Empty(t, r.RegisterPlugins())
+
+	res := r.Execute(ToolUse{Name: "slow", Input: []byte(`{}`)}, dir, nil)
+	assert.True(t, res.IsError)
+	assert.Equal(t, ErrTimeout, res.Code)
+	assert.Equal(t, map[string]any{"timeout": "100ms"}, res.Meta)
+}
+
+func TestPluginPayloadHasNoKeys(t *testing.T) {
+	dir := t.TempDir()
+	script := "#!/bin/sh\n[ \"$1\" = --openai ] && exec echo '{\"type\":\"function\",\"function\":{\"name\":\"echo\"}}'\nexec cat\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "echo"), []byte(script), 0755))
+
+	cfg := config.Default()
+	cfg.PluginDir = dir
+	cfg.APIKey = "sk-main-key"
+	cfg.Fallbacks = []config.Fallback{{Provider: "openai", Model: "gpt-4o-mini", APIKey: "sk-fallback-key"}}
+	r := NewRegistry(cfg)
+	assert.Empty(t, r.RegisterPlugins())
+
+	res := r.Execute(ToolUse{Name: "echo", Input: []byte(`{}`)}, dir, nil)
+	assert.False(t, res.IsError)
+	assert.Contains(t, res.Content, `"Model":"gpt-4o-mini"`)
+	assert.NotContains(t, res.Content, "sk-main-key")
+	assert.NotContains(t, res.Content, "sk-fallback-key")
+	assert.Equal(t, "sk-fallback-key", cfg.Fallbacks[0].APIKey, "the config is left alone")
+}
@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"cmp"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -8,16 +9,18 @@ import (
 	"strings"
 
 	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/files"
 )
 
-func init() { DefaultTools = append(DefaultTools, _readFile) }
+// firstLine is the lowest line that can be read
+var firstLine = 1.0
 
 var _readFile = Tool{
 	exec: readFile,
 	Type: "function",
 	Function: &FunctionSchema{
 		Name:        "read_file",
-		Description: "Read the contents of a file. Returns the file content as a string.",
+		Description: "Read the contents of a file. Returns the file content as a string, or an outline with line numbers if the file is too big, then read the parts you need with start_line and end_line.",
 		Parameters: &JSONSchema{
 			Type: "object",
 			Properties: map[string]Property{
@@ -25,6 +28,16 @@ var _readFile = Tool{
 					Type:        "string",
 					Description: "The path to the file to read.",
 				},
+				"start_line": {
+					Type:        "integer",
+					Description: "The first line to read, counting from 1.",
+					Minimum:     &firstLine,
+				},
+				"end_line": {
+					Type:        "integer",
+					Description: "The last line to read, defaults to the end of the file.",
+					Minimum:     &firstLine,
+				},
 			},
 			Required: []string{"path"},
 		},
@@ -33,32 +46,29 @@ var _readFile = Tool{
 
 func readFile(cfg config.Config, input json.RawMessage, workingDir string) (string, error) {
 	var params struct {
-		Path string `json:"path"`
+		Path      string `json:"path"`
+		StartLine int    `json:"start_line"`
+		EndLine   int    `json:"end_line"`
 	}
 	if err := json.Unmarshal(input, &params); err != nil {
 		return "", err
 	}
 
 	targetPath := filepath.Join(workingDir, params.Path)
-
-	// Security check: ensure path is within working directory
-	absTarget, err := filepath.Abs(targetPath)
-	if err != nil {
-		return "", err
+	if err := files.CheckAccess(cfg, workingDir, targetPath); err != nil {
+		return "", denied(params.Path, err)
 	}
-	absWorking, err := filepath.Abs(workingDir)
+
+	info, err := os.Stat(targetPath)
 	if err != nil {
 		return "", err
 	}
-	if !strings.HasPrefix(absTarget, absWorking) {
-		return "", fmt.Errorf("access denied: path outside working directory")
-	}
-
-	// check the file is not excluded
-	for _, pattern := range cfg.ExcludePatterns {
-		matched, _ := filepath.Match(pattern, filepath.Base(targetPath))
-		if matched {
-			return "", fmt.Errorf("file matches exclude pattern")
+	// a big file can still be read a range of lines at a time
+	if cfg.MaxFileSize > 0 && info.Size() > cfg.MaxFileSize && params.StartLine == 0 && params.EndLine == 0 {
+		return "", &Error{
+			Code: ErrTooLarge,
+			Msg:  fmt.Sprintf("file too large: %d bytes (max: %d), read it with start_line and end_line", info.Size(), cfg.MaxFileSize),
+			Meta: map[string]any{"path": params.Path, "size": info.Size(), "max": cfg.MaxFileSize},
 		}
 	}
 
@@ -67,7 +77,23 @@ func readFile(cfg config.Config, input json.RawMessage, workingDir string) (stri
 		return "", err
 	}
 
+	lang := files.DetectLanguage(targetPath, content)
 	targetPath = strings.Replace(targetPath, workingDir, "", 1)
-	out := "// " + targetPath + "\n" + string(content)
+
+	if params.StartLine == 0 && params.EndLine == 0 {
+		return "// " + targetPath + "\n" + files.Include(params.Path, lang, string(content), cfg.MaxFileTokens), nil
+	}
+
+	total := strings.Count(strings.TrimSuffix(string(content), "\n"), "\n") + 1
+	start := max(params.StartLine, 1)
+	if start > total {
+		return "", fmt.Errorf("start_line %d is past the end of the file, it has %d lines", start, total)
+	}
+
+	lines, last := files.Lines(string(content), start, params.EndLine, cfg.MaxFileTokens)
+	out := fmt.Sprintf("// %s lines %d-%d of %d\n", targetPath, start, last, total) + files.Fence(lang, lines)
+	if end := cmp.Or(params.EndLine, total); last < min(end, total) {
+		out += fmt.Sprintf("\nStopped at line %d to keep within max_file_tokens, read on from line %d.", last, last+1)
+	}
 	return out, nil
 }
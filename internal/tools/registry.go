@@ -0,0 +1,176 @@
+package tools
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/penguinpowernz/clai/config"
+)
+
+// Builtins are the tools built into clai, in the order they are offered
+var Builtins = []Tool{
+	_diff,
+	_filetype,
+	_find,
+	_grep,
+	_listFiles,
+	_mkdir,
+	_readFile,
+	_searchFile,
+	_searchFiles,
+	_writeFile,
+}
+
+// Registry holds the tools of a session, the built-ins first and then the
+// plugins in the order they were registered.  Disabled tools, by the config
+// or for the session, are not offered to the AI or run.
+type Registry struct {
+	mu       sync.Mutex
+	cfg      *config.Config
+	tools    []Tool
+	enabled  map[string]bool // changed for the session, over the config
+	commands []PluginCommand
+}
+
+// NewRegistry creates a registry with the built-in tools
+func NewRegistry(cfg *config.Config) *Registry {
+	return &Registry{
+		cfg:     cfg,
+		tools:   slices.Clone(Builtins),
+		enabled: map[string]bool{},
+	}
+}
+
+// Register adds a tool, it is an error to register two with the same name
+func (r *Registry) Register(t Tool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, found := Tools(r.tools).find(t.Function.Name); found {
+		return fmt.Errorf("%s tool %q is already registered", existing.Source(), t.Function.Name)
+	}
+	r.tools = append(r.tools, t)
+	return nil
+}
+
+// All returns every registered tool, even those that are disabled or that the
+// policy doesn't allow
+func (r *Registry) All() []Tool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.tools)
+}
+
+// Tools returns the tools that are offered to the AI, those that are enabled
+// and allowed by the policy
+func (r *Registry) Tools() []Tool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var tt []Tool
+	for _, t := range Allowed(*r.cfg, r.tools) {
+		if r.isEnabled(t.Function.Name) {
+			tt = append(tt, t)
+		}
+	}
+	return tt
+}
+
+// Find returns the tool with the name if it is offered to the AI
+func (r *Registry) Find(name string) (Tool, bool) {
+	t, found := Tools(r.Tools()).find(name)
+	if !found {
+		return Tool{}, false
+	}
+	return *t, true
+}
+
+// SetEnabled enables or disables the tool for the session, whatever the
+// config says
+func (r *Registry) SetEnabled(name string, enabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, found := Tools(r.tools).find(name); !found {
+		return fmt.Errorf("there is no tool called %q", name)
+	}
+	r.enabled[name] = enabled
+	return nil
+}
+
+// Enabled returns false if the tool was disabled by the config or for the
+// session
+func (r *Registry) Enabled(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.isEnabled(name)
+}
+
+// Changed returns the tools that were enabled or disabled for the session
+func (r *Registry) Changed() map[string]bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return maps.Clone(r.enabled)
+}
+
+func (r *Registry) isEnabled(name string) bool {
+	if enabled, ok := r.enabled[name]; ok {
+		return enabled
+	}
+	return r.cfg.ToolEnabled(name)
+}
+
+// Execute runs the call, showing the output of a long running tool while it
+// runs.  Tools that can give their output as it comes (plugins) write it to
+// live too, it can be nil.  The result still has all of the output.
+func (r *Registry) Execute(call ToolUse, workingDir string, live io.Writer) ToolResult {
+	result := ToolResult{
+		ToolUseID: call.ID,
+	}
+
+	x, found := r.Find(call.Name)
+	if !found {
+		result.Content = fmt.Sprintf("Unknown tool: %s", call.Name)
+		result.IsError = true
+		result.Code, result.Meta = ErrNotFound, map[string]any{"tool": call.Name}
+		return result
+	}
+
+	if x.exec == nil {
+		result.Content = fmt.Sprintf("cannot execute tool: %s", call.Name)
+		result.IsError = true
+		return result
+	}
+
+	input, err := applyDefaults(x.Function.Parameters, call.Input)
+	if err != nil {
+		result.Content = fmt.Sprintf("Error: invalid arguments: %v", err)
+		result.IsError = true
+		return result
+	}
+
+	var content string
+	if live != nil && x.live != nil {
+		content, err = x.live(*r.cfg, input, workingDir, live)
+	} else {
+		content, err = x.exec(*r.cfg, input, workingDir)
+	}
+	if err != nil {
+		result.Code, result.Meta = errorDetails(err)
+		result.Content = errorContent(err, result.Code, result.Meta)
+		result.IsError = true
+	} else {
+		result.Content = content
+	}
+
+	log.Println("[tools] tool output:", result.Content)
+	if runFunc != nil {
+		runFunc(call.Name, result.IsError)
+	}
+
+	return result
+}
@@ -0,0 +1,84 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/penguinpowernz/clai/config"
+)
+
+// pluginEnv are the environment variables plugins always get, anything else
+// has to be allowed with plugin_limits.env
+var pluginEnv = []string{"PATH", "HOME", "USER", "LANG", "TERM", "TMPDIR"}
+
+// sandboxCommand builds a command to run a plugin with the configured resource
+// limits, restricted environment and optionally without network access.  The
+// returned cancel func must be called when the command is done.
+func sandboxCommand(limits config.PluginLimits, fn string, args ...string) (*exec.Cmd, context.CancelFunc, error) {
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if limits.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+	}
+
+	name, args, err := sandboxArgs(limits, fn, args)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = restrictedEnv(limits.Env)
+	cmd.WaitDelay = time.Second // don't hang if the plugin left children holding stdout
+	return cmd, cancel, nil
+}
+
+// sandboxArgs wraps the plugin in a shell that applies the rlimits, and in a
+// new network namespace if network access is denied
+func sandboxArgs(limits config.PluginLimits, fn string, args []string) (string, []string, error) {
+	if runtime.GOOS == "windows" {
+		if limits.DenyNetwork || limits.CPUSeconds > 0 || limits.MemoryMB > 0 {
+			return "", nil, fmt.Errorf("plugin limits are not supported on %s", runtime.GOOS)
+		}
+		return fn, args, nil
+	}
+
+	script := ""
+	if limits.CPUSeconds > 0 {
+		script += fmt.Sprintf("ulimit -t %d || exit 126; ", limits.CPUSeconds)
+	}
+	if limits.MemoryMB > 0 {
+		script += fmt.Sprintf("ulimit -v %d || exit 126; ", limits.MemoryMB*1024)
+	}
+
+	cmdline := []string{fn}
+	if script != "" {
+		cmdline = append([]string{"sh", "-c", script + `exec "$@"`, "sh"}, cmdline...)
+	}
+
+	if limits.DenyNetwork {
+		unshare, err := exec.LookPath("unshare")
+		if err != nil {
+			return "", nil, fmt.Errorf("deny_network requires the unshare command: %w", err)
+		}
+		cmdline = append([]string{unshare, "--map-root-user", "--net", "--"}, cmdline...)
+	}
+
+	cmdline = append(cmdline, args...)
+	return cmdline[0], cmdline[1:], nil
+}
+
+// restrictedEnv returns the environment to give plugins, only the basic
+// variables and those that are explicitly allowed are passed through
+func restrictedEnv(allowed []string) []string {
+	var env []string
+	for _, name := range append(pluginEnv, allowed...) {
+		if val, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+val)
+		}
+	}
+	return env
+}
@@ -0,0 +1,42 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSandboxCommand(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "plugin")
+	script := "#!/bin/sh\nif [ \"$1\" = sleep ]; then sleep 5; fi\necho \"secret=$CLAI_TEST_SECRET allowed=$CLAI_TEST_ALLOWED cpu=$(ulimit -t)\"\n"
+	if err := os.WriteFile(fn, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("CLAI_TEST_SECRET", "s3cr3t")
+	t.Setenv("CLAI_TEST_ALLOWED", "yes")
+
+	limits := config.PluginLimits{CPUSeconds: 7, Env: []string{"CLAI_TEST_ALLOWED"}}
+	cmd, cancel, err := sandboxCommand(limits, fn)
+	assert.NoError(t, err)
+	defer cancel()
+
+	out, err := cmd.Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "secret= allowed=yes cpu=7", strings.TrimSpace(string(out)))
+
+	limits = config.PluginLimits{Timeout: 100 * time.Millisecond}
+	cmd, cancel, err = sandboxCommand(limits, fn, "sleep")
+	assert.NoError(t, err)
+	defer cancel()
+
+	start := time.Now()
+	assert.Error(t, cmd.Run())
+	assert.Less(t, time.Since(start), 3*time.Second)
+}
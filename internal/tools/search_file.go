@@ -3,35 +3,31 @@ package tools
 import (
 	"bytes"
 	"encoding/json"
-	"fmt"
-	"os/exec"
 	"path/filepath"
-	"strings"
 
 	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/files"
 )
 
-func init() { DefaultTools = append(DefaultTools, _searchFile) }
-
 var _searchFile = Tool{
 	exec: searchFile,
 	Type: "function",
 	Function: &FunctionSchema{
-		Name:        "search_files",
-		Description: "Search for files matching a pattern (glob) in a directory.",
+		Name:        "search_file",
+		Description: "Search for lines matching a pattern inside a single file.",
 		Parameters: &JSONSchema{
 			Type: "object",
 			Properties: map[string]Property{
 				"pattern": {
 					Type:        "string",
-					Description: "Glob pattern to match (e.g., '*.go', 'src/**/*.js')",
+					Description: "The pattern to search for (basic grep syntax)",
 				},
 				"path": {
 					Type:        "string",
-					Description: "Directory to search in. Defaults to current directory.",
+					Description: "The path to the file to search in.",
 				},
 			},
-			Required: []string{"pattern"},
+			Required: []string{"pattern", "path"},
 		},
 	},
 }
@@ -45,37 +41,12 @@ func searchFile(cfg config.Config, input json.RawMessage, workingDir string) (st
 		return "", err
 	}
 
-	// SEC: strip path traversal
-	absTarget, err := filepath.Abs(params.Path)
-	if err != nil {
-		return "", err
-	}
-	absWorking, err := filepath.Abs(workingDir)
-	if err != nil {
-		return "", err
-	}
-	if !strings.HasPrefix(absTarget, absWorking) {
-		return "", fmt.Errorf("access denied: path outside working directory")
-	}
-
-	targetPath := filepath.Join(absWorking, params.Path)
-
-	// check the file is not excluded
-	for _, pattern := range cfg.ExcludePatterns {
-		matched, _ := filepath.Match(pattern, filepath.Base(targetPath))
-		if matched {
-			return "", fmt.Errorf("file matches exclude pattern")
-		}
-	}
-	// check the file is not excluded
-	for _, pattern := range cfg.ExcludePatterns {
-		matched, _ := filepath.Match(pattern, filepath.Base(params.Path))
-		if matched {
-			return "", fmt.Errorf("file matches exclude pattern")
-		}
+	targetPath := filepath.Join(workingDir, params.Path)
+	if err := files.CheckAccess(cfg, workingDir, targetPath); err != nil {
+		return "", denied(params.Path, err)
 	}
 
-	cmd := exec.Command("grep", params.Pattern, targetPath)
+	cmd := command(cfg, workingDir, "grep", params.Pattern, targetPath)
 	buf := &bytes.Buffer{}
 	cmd.Stderr = buf
 	cmd.Stdout = buf
@@ -8,10 +8,9 @@ import (
 	"strings"
 
 	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/files"
 )
 
-func init() { DefaultTools = append(DefaultTools, _searchFiles) }
-
 var _searchFiles = Tool{
 	exec: searchFiles,
 	Type: "function",
@@ -48,15 +47,14 @@ func searchFiles(cfg config.Config, input json.RawMessage, workingDir string) (s
 		params.Path = "."
 	}
 
-	if IsExcluded(cfg, params.Path) {
-		return "ERROR: the requested path does not exist", nil
-	}
-
-	if !Exists(params.Path) {
-		return "ERROR: the requested path does not exist", nil
+	if files.IsExcluded(cfg, workingDir, params.Path) || files.IsHidden(cfg, workingDir, params.Path) {
+		return "", notFound(params.Path)
 	}
 
 	targetPath := filepath.Join(workingDir, params.Path)
+	if !Exists(targetPath) {
+		return "", notFound(params.Path)
+	}
 	pattern := filepath.Join(targetPath, params.Pattern)
 
 	matches, err := filepath.Glob(pattern)
@@ -68,7 +66,7 @@ func searchFiles(cfg config.Config, input json.RawMessage, workingDir string) (s
 	for _, match := range matches {
 		relPath, _ := filepath.Rel(workingDir, match)
 
-		if IsExcluded(cfg, match) {
+		if files.IsExcluded(cfg, workingDir, match) || files.IsHidden(cfg, workingDir, match) || !files.InScope(cfg, workingDir, match) {
 			continue
 		}
 
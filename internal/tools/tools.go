@@ -2,30 +2,46 @@ package tools
 
 import (
 	"encoding/json"
-	"fmt"
-	"log"
+	"io"
+	"slices"
 
 	"github.com/penguinpowernz/clai/config"
 )
 
-var DefaultTools = []Tool{}
-
 // Tool is one entry in the `tools` array that you send to /chat/completions.
 type Tool struct {
 	Type     string          `json:"type"` // "function" (currently the only supported value)
 	Function *FunctionSchema `json:"function,omitempty"`
 	exec     toolExecutor
+	live     liveExecutor // runs the tool showing its output as it comes, nil if it can't
+	effects  effectsFunc  // what a call would change, nil for tools that only read
+	plugin   string       // path to the plugin that provides the tool, empty for built-ins
+}
+
+// Source returns where the tool came from, either "built-in" or the path of the plugin
+func (t Tool) Source() string {
+	if t.plugin == "" {
+		return "built-in"
+	}
+	return t.plugin
 }
 
 type Tools []Tool
 
 func (ts Tools) find(name string) (*Tool, bool) {
-	for _, t := range ts {
+	if i := ts.index(name); i >= 0 {
+		return &ts[i], true
+	}
+	return nil, false
+}
+
+func (ts Tools) index(name string) int {
+	for i, t := range ts {
 		if t.Function.Name == name {
-			return &t, true
+			return i
 		}
 	}
-	return nil, false
+	return -1
 }
 
 type FunctionSchema struct {
@@ -38,13 +54,29 @@ type JSONSchema struct {
 	Type       string              `json:"type"` // usually "object"
 	Properties map[string]Property `json:"properties,omitempty"`
 	Required   []string            `json:"required,omitempty"`
-	// You can add more JSON‑Schema fields here if you need them
-	// e.g. `Enum`, `Format`, `Items`, etc.
 }
 
+// Property describes a single parameter, it can be nested to describe arrays
+// (using Items) and objects (using Properties and Required)
 type Property struct {
-	Type        string `json:"type"` // usually "string"
-	Description string `json:"description"`
+	Type        string `json:"type"` // "string", "boolean", "integer", "number", "array" or "object"
+	Description string `json:"description,omitempty"`
+
+	Enum    []any `json:"enum,omitempty"`    // the only values that are allowed
+	Default any   `json:"default,omitempty"` // used when the model omits the parameter
+
+	// for arrays
+	Items    *Property `json:"items,omitempty"`
+	MinItems *int      `json:"minItems,omitempty"`
+	MaxItems *int      `json:"maxItems,omitempty"`
+
+	// for objects
+	Properties map[string]Property `json:"properties,omitempty"`
+	Required   []string            `json:"required,omitempty"`
+
+	// for numbers and integers
+	Minimum *float64 `json:"minimum,omitempty"`
+	Maximum *float64 `json:"maximum,omitempty"`
 }
 
 // ToolUse represents when the AI wants to use a tool
@@ -59,46 +91,74 @@ type ToolResult struct {
 	ToolUseID string `json:"tool_use_id"`
 	Content   string `json:"content"`
 	IsError   bool   `json:"is_error,omitempty"`
+
+	// why the tool failed and the details of it, when that is known
+	Code ErrorCode      `json:"code,omitempty"`
+	Meta map[string]any `json:"meta,omitempty"`
 }
 
-// GetAvailableTools returns all tools the AI can use
-func GetAvailableTools() []Tool {
-	return DefaultTools
+// Allowed returns the tools that the policy allows, all of them if it
+// doesn't limit them
+func Allowed(cfg config.Config, tt []Tool) []Tool {
+	if cfg.AllowedTools == nil {
+		return tt
+	}
+	var allowed []Tool
+	for _, t := range tt {
+		if slices.Contains(cfg.AllowedTools, t.Function.Name) {
+			allowed = append(allowed, t)
+		}
+	}
+	return allowed
 }
 
 type toolExecutor func(cfg config.Config, toolUse json.RawMessage, workingDir string) (string, error)
 
-// ExecuteTool executes a tool and returns the result
-func ExecuteTool(cfg *config.Config, toolCall ToolUse, workingDir string) ToolResult {
-	result := ToolResult{
-		ToolUseID: toolCall.ID,
-	}
+// liveExecutor is a toolExecutor that also writes the output to live as the
+// tool makes it
+type liveExecutor func(cfg config.Config, toolUse json.RawMessage, workingDir string, live io.Writer) (string, error)
+
+// RunFunc is told about every tool that is run and whether it failed
+type RunFunc func(name string, failed bool)
+
+var runFunc RunFunc
 
-	var tool toolExecutor
+// SetRunFunc sets the func that is told about every tool that is run
+func SetRunFunc(f RunFunc) {
+	runFunc = f
+}
 
-	x, found := Tools(DefaultTools).find(toolCall.Name)
-	if !found {
-		result.Content = fmt.Sprintf("Unknown tool: %s", toolCall.Name)
-		result.IsError = true
-		return result
+// applyDefaults fills in the default values for any top level parameters the
+// model left out of the input
+func applyDefaults(schema *JSONSchema, input json.RawMessage) (json.RawMessage, error) {
+	if schema == nil {
+		return input, nil
 	}
-	tool = x.exec
 
-	if tool == nil {
-		result.Content = fmt.Sprintf("cannot execute tool: %s", toolCall.Name)
-		result.IsError = true
-		return result
+	hasDefaults := false
+	for _, prop := range schema.Properties {
+		if prop.Default != nil {
+			hasDefaults = true
+			break
+		}
 	}
 
-	content, err := tool(*cfg, toolCall.Input, workingDir)
-	if err != nil {
-		result.Content = fmt.Sprintf("Error: %v", err)
-		result.IsError = true
-	} else {
-		result.Content = content
+	if !hasDefaults {
+		return input, nil
 	}
 
-	log.Println("[tools] tool output:", result.Content)
+	params := map[string]any{}
+	if len(input) > 0 {
+		if err := json.Unmarshal(input, &params); err != nil {
+			return nil, err
+		}
+	}
+
+	for name, prop := range schema.Properties {
+		if _, set := params[name]; !set && prop.Default != nil {
+			params[name] = prop.Default
+		}
+	}
 
-	return result
+	return json.Marshal(params)
 }
@@ -0,0 +1,96 @@
+package tools
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyDefaults(t *testing.T) {
+	schema := &JSONSchema{
+		Type: "object",
+		Properties: map[string]Property{
+			"path":      {Type: "string", Default: "."},
+			"recursive": {Type: "boolean", Default: false},
+			"pattern":   {Type: "string"},
+		},
+	}
+
+	out, err := applyDefaults(schema, json.RawMessage(`{"pattern":"foo","path":"src"}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"pattern":"foo","path":"src","recursive":false}`, string(out))
+
+	out, err = applyDefaults(schema, nil)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"path":".","recursive":false}`, string(out))
+
+	_, err = applyDefaults(schema, json.RawMessage(`not json`))
+	assert.Error(t, err)
+}
+
+func TestPropertySchema(t *testing.T) {
+	one := 1
+	zero := 0.0
+	prop := Property{
+		Type: "array",
+		Items: &Property{
+			Type: "object",
+			Properties: map[string]Property{
+				"level": {Type: "string", Enum: []any{"info", "warn"}},
+				"count": {Type: "integer", Minimum: &zero},
+			},
+			Required: []string{"level"},
+		},
+		MinItems: &one,
+	}
+
+	data, _ := json.Marshal(prop)
+	assert.JSONEq(t, `{
+		"type": "array",
+		"minItems": 1,
+		"items": {
+			"type": "object",
+			"properties": {
+				"level": {"type": "string", "enum": ["info", "warn"]},
+				"count": {"type": "integer", "minimum": 0}
+			},
+			"required": ["level"]
+		}
+	}`, string(data))
+}
+
+func TestRegistry(t *testing.T) {
+	cfg := &config.Config{}
+	r := NewRegistry(cfg)
+	assert.Equal(t, GetNames(Builtins), GetNames(r.Tools()))
+	assert.Error(t, r.Register(_grep))
+
+	assert.NoError(t, r.SetEnabled("write_file", false))
+	assert.False(t, r.Enabled("write_file"))
+	assert.NotContains(t, GetNames(r.Tools()), "write_file")
+	assert.Contains(t, GetNames(r.All()), "write_file")
+	assert.True(t, r.Execute(ToolUse{Name: "write_file", Input: json.RawMessage(`{}`)}, t.TempDir(), nil).IsError)
+
+	assert.NoError(t, r.SetEnabled("write_file", true))
+	assert.Contains(t, GetNames(r.Tools()), "write_file")
+	assert.Error(t, r.SetEnabled("nope", false))
+
+	// another session has its own
+	assert.NoError(t, r.SetEnabled("grep", false))
+	assert.Contains(t, GetNames(NewRegistry(cfg).Tools()), "grep")
+
+	cfg.AllowedTools = []string{"grep", "read_file"}
+	assert.Equal(t, []string{"read_file"}, GetNames(r.Tools()))
+	cfg.AllowedTools = nil
+
+	// the config can disable them too, the session can still turn them on
+	cfg.EnabledTools = []string{"grep", "read_file", "write_file"}
+	cfg.DisabledTools = []string{"write_file"}
+	r = NewRegistry(cfg)
+	assert.Equal(t, []string{"grep", "read_file"}, GetNames(r.Tools()))
+	assert.NoError(t, r.SetEnabled("write_file", true))
+	assert.NoError(t, r.SetEnabled("mkdir", true))
+	assert.Equal(t, []string{"grep", "mkdir", "read_file", "write_file"}, GetNames(r.Tools()))
+}
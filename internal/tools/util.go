@@ -2,11 +2,11 @@ package tools
 
 import (
 	"encoding/json"
-	"fmt"
 	"os"
 	"path/filepath"
 
 	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/files"
 )
 
 func IsValid(tools []Tool, toolName string) bool {
@@ -33,13 +33,13 @@ func Exists(fn string) bool {
 
 func PrepareFilePath(cfg config.Config, workingDir string, fn string) (string, error) {
 	fn = Sanitize(fn)
-	if IsExcluded(cfg, fn) {
-		return "", fmt.Errorf("file matches exclude pattern")
+	if files.IsExcluded(cfg, workingDir, fn) {
+		return "", denied(fn, files.ErrExcluded)
 	}
 
 	path := filepath.Join(workingDir, fn)
 	if !Exists(path) {
-		return "", fmt.Errorf("file not found")
+		return "", &Error{Code: ErrNotFound, Msg: "file not found", Meta: map[string]any{"path": fn}}
 	}
 
 	return path, nil
@@ -49,3 +49,10 @@ func jsonDump(v any) string {
 	b, _ := json.Marshal(v)
 	return string(b)
 }
+
+func absPath(workingDir, path string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(workingDir, path)
+	}
+	return filepath.Clean(path)
+}
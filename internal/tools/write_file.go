@@ -5,16 +5,15 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/files"
 )
 
-func init() { DefaultTools = append(DefaultTools, _writeFile) }
-
 var _writeFile = Tool{
-	exec: writeFile,
-	Type: "function",
+	exec:    writeFile,
+	effects: writeFileEffects,
+	Type:    "function",
 	Function: &FunctionSchema{
 		Name:        "write_file",
 		Description: "Write content to a file. Creates the file if it doesn't exist, overwrites if it does.",
@@ -51,12 +50,19 @@ func writeFile(cfg config.Config, input json.RawMessage, workingDir string) (str
 	if err != nil {
 		return "", err
 	}
-	absWorking, err := filepath.Abs(workingDir)
-	if err != nil {
-		return "", err
+	if !files.Confined(workingDir, absTarget) {
+		return "", denied(params.Path, files.ErrOutside)
 	}
-	if !strings.HasPrefix(absTarget, absWorking) {
-		return "", fmt.Errorf("access denied: path outside working directory")
+
+	if !files.InScope(cfg, workingDir, absTarget) {
+		return "", denied(params.Path, files.ErrOutOfScope)
+	}
+
+	if cfg.Sandbox != "" {
+		if err := sandboxWriteFile(cfg, workingDir, absTarget, []byte(params.Content)); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Successfully wrote %d bytes to %s", len(params.Content), params.Path), nil
 	}
 
 	// Create parent directories if needed
@@ -70,3 +76,14 @@ func writeFile(cfg config.Config, input json.RawMessage, workingDir string) (str
 
 	return fmt.Sprintf("Successfully wrote %d bytes to %s", len(params.Content), params.Path), nil
 }
+
+func writeFileEffects(cfg config.Config, input json.RawMessage, workingDir string) []string {
+	var params struct {
+		Path    string `json:"path"`
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(input, &params); err != nil {
+		return nil
+	}
+	return []string{fileEffect(absPath(workingDir, params.Path), len(params.Content))}
+}
@@ -0,0 +1,26 @@
+package training
+
+import (
+	"regexp"
+
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/secrets"
+)
+
+var (
+	emailRe = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	ipRe    = regexp.MustCompile(`\b(25[0-5]|2[0-4]\d|1?\d?\d)(\.(25[0-5]|2[0-4]\d|1?\d?\d)){3}\b`)
+	phoneRe = regexp.MustCompile(`\+?\b\d{1,3}[ .-]\(?\d{2,4}\)?[ .-]\d{3,4}[ .-]\d{3,4}\b`)
+	homeRe  = regexp.MustCompile(`(/home/|/Users/|C:\\Users\\)[^/\\\s"'` + "`" + `]+`)
+)
+
+// StripPII replaces secrets, emails, IP addresses and phone numbers, and the
+// user's name in home directories
+func StripPII(text string) string {
+	text = secrets.Redact(text, secrets.Find(text), ai.Redacted)
+	text = emailRe.ReplaceAllString(text, "[email]")
+	text = ipRe.ReplaceAllString(text, "[ip]")
+	text = phoneRe.ReplaceAllString(text, "[phone]")
+	text = homeRe.ReplaceAllString(text, "${1}user")
+	return text
+}
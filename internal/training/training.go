@@ -0,0 +1,188 @@
+// Package training converts saved sessions into the JSONL formats used to
+// fine-tune models, one conversation per line
+package training
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/penguinpowernz/clai/internal/ai"
+)
+
+// Formats are the formats a conversation can be converted to
+var Formats = []string{"jsonl-chat", "sharegpt"}
+
+// toolRequest matches the message the session adds for each tool call
+var toolRequest = regexp.MustCompile("(?s)^Request to use tool: `(.*?)` with args: `(.*)`$")
+
+// turn is a message of the conversation with the tool call parsed out of it
+type turn struct {
+	role    string // system, user, assistant or tool
+	content string
+	id      string // the tool call the turn requests or answers
+	tool    string // the tool that was called, only for tool calls
+	args    string
+}
+
+// Options change how the conversations are converted
+type Options struct {
+	System   string // added to the start of each conversation, if set
+	StripPII bool   // replace emails, IPs, phone numbers, secrets and home dirs
+}
+
+// Convert returns the messages as one line in the format, ok is false if
+// there is no response from the assistant in them to learn from
+func Convert(messages []ai.Message, format string, opts Options) (line []byte, ok bool, err error) {
+	turns := toTurns(messages, opts)
+
+	for _, t := range turns {
+		if t.role == "assistant" {
+			ok = true
+		}
+	}
+	if !ok {
+		return nil, false, nil
+	}
+
+	switch format {
+	case "jsonl-chat":
+		line, err = json.Marshal(openAIChat(turns))
+	case "sharegpt":
+		line, err = json.Marshal(shareGPT(turns))
+	default:
+		return nil, false, fmt.Errorf("unknown format %q, use one of %v", format, Formats)
+	}
+	return line, err == nil, err
+}
+
+func toTurns(messages []ai.Message, opts Options) []turn {
+	var turns []turn
+	if opts.System != "" {
+		turns = append(turns, turn{role: "system", content: opts.System})
+	}
+
+	clean := func(s string) string { return s }
+	if opts.StripPII {
+		clean = StripPII
+	}
+
+	for _, m := range messages {
+		t := turn{role: m.Role, content: clean(m.Content), id: m.ToolCallID}
+
+		switch {
+		case m.Role == "assistant" && m.IsToolCall():
+			for _, tc := range m.Calls() {
+				args, _ := json.Marshal(tc.Input)
+				turns = append(turns, turn{role: "assistant", id: tc.ID, tool: tc.Name, args: clean(string(args))})
+			}
+			continue
+		case m.Role == "assistant" && m.ToolCallID != "":
+			if sub := toolRequest.FindStringSubmatch(m.Content); sub != nil {
+				t.tool, t.args, t.content = sub[1], clean(sub[2]), ""
+			}
+		case m.ToolCallID != "":
+			// "tool not found" is sent back as a user message
+			t.role = "tool"
+		case m.Role != "user" && m.Role != "assistant":
+			continue
+		}
+
+		turns = append(turns, t)
+	}
+	return turns
+}
+
+type chatLine struct {
+	Messages []chatMessage `json:"messages"`
+}
+
+type chatMessage struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content,omitempty"`
+	ToolCalls  []chatCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+type chatCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// openAIChat is the OpenAI chat fine-tuning format, the tool calls made
+// together are put in one assistant message
+func openAIChat(turns []turn) chatLine {
+	var line chatLine
+	for _, t := range turns {
+		if t.tool == "" {
+			line.Messages = append(line.Messages, chatMessage{Role: t.role, Content: t.content, ToolCallID: toolID(t)})
+			continue
+		}
+
+		call := chatCall{ID: t.id, Type: "function"}
+		call.Function.Name, call.Function.Arguments = t.tool, t.args
+
+		last := len(line.Messages) - 1
+		if last >= 0 && line.Messages[last].ToolCalls != nil {
+			line.Messages[last].ToolCalls = append(line.Messages[last].ToolCalls, call)
+			continue
+		}
+		line.Messages = append(line.Messages, chatMessage{Role: "assistant", ToolCalls: []chatCall{call}})
+	}
+	return line
+}
+
+func toolID(t turn) string {
+	if t.role == "tool" {
+		return t.id
+	}
+	return ""
+}
+
+type shareGPTLine struct {
+	System        string          `json:"system,omitempty"`
+	Conversations []shareGPTValue `json:"conversations"`
+}
+
+type shareGPTValue struct {
+	From  string `json:"from"`
+	Value string `json:"value"`
+}
+
+// shareGPT is the ShareGPT format, tool calls are function_call turns with
+// the name and arguments as JSON, and their output is an observation
+func shareGPT(turns []turn) shareGPTLine {
+	var line shareGPTLine
+	for _, t := range turns {
+		v := shareGPTValue{Value: t.content}
+		switch {
+		case t.role == "system":
+			line.System = t.content
+			continue
+		case t.tool != "":
+			call, _ := json.Marshal(map[string]any{"name": t.tool, "arguments": json.RawMessage(validJSON(t.args))})
+			v.From, v.Value = "function_call", string(call)
+		case t.role == "tool":
+			v.From = "observation"
+		case t.role == "user":
+			v.From = "human"
+		default:
+			v.From = "gpt"
+		}
+		line.Conversations = append(line.Conversations, v)
+	}
+	return line
+}
+
+// validJSON returns the args as a JSON string if they aren't valid JSON
+func validJSON(args string) string {
+	if json.Valid([]byte(args)) {
+		return args
+	}
+	s, _ := json.Marshal(args)
+	return string(s)
+}
@@ -0,0 +1,67 @@
+package training
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/stretchr/testify/assert"
+)
+
+var conversation = []ai.Message{
+	{Role: "user", Content: "what is in /home/alice/notes.txt?"},
+	{Role: "assistant", Content: "Request to use tool: `read_file` with args: `{\"path\":\"/home/alice/notes.txt\"}`", ToolCallID: "call_1"},
+	{Role: "tool", Content: "mail bob@example.com from 10.0.0.12", ToolCallID: "call_1"},
+	{Role: "assistant", Content: "It says to mail bob"},
+}
+
+func TestConvert(t *testing.T) {
+	line, ok, err := Convert(conversation, "jsonl-chat", Options{System: "be brief", StripPII: true})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.JSONEq(t, `{"messages":[
+		{"role":"system","content":"be brief"},
+		{"role":"user","content":"what is in /home/user/notes.txt?"},
+		{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"read_file","arguments":"{\"path\":\"/home/user/notes.txt\"}"}}]},
+		{"role":"tool","content":"mail [email] from [ip]","tool_call_id":"call_1"},
+		{"role":"assistant","content":"It says to mail bob"}
+	]}`, string(line))
+
+	line, _, err = Convert(conversation, "sharegpt", Options{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"conversations":[
+		{"from":"human","value":"what is in /home/alice/notes.txt?"},
+		{"from":"function_call","value":"{\"arguments\":{\"path\":\"/home/alice/notes.txt\"},\"name\":\"read_file\"}"},
+		{"from":"observation","value":"mail bob@example.com from 10.0.0.12"},
+		{"from":"gpt","value":"It says to mail bob"}
+	]}`, string(line))
+
+	_, ok, err = Convert(conversation[:1], "jsonl-chat", Options{})
+	assert.NoError(t, err)
+	assert.False(t, ok, "there is no response to learn from")
+}
+
+func TestConvertToolCalls(t *testing.T) {
+	line, ok, err := Convert([]ai.Message{
+		{Role: "user", Content: "read a.go and b.go"},
+		{Role: "assistant", Content: "Request to use tool: ...", ToolCalls: []ai.ToolUse{
+			{ID: "a", Name: "read_file", Input: json.RawMessage(`{"path":"a.go"}`)},
+			{ID: "b", Name: "read_file", Input: json.RawMessage(`{"path":"b.go"}`)},
+		}},
+		{Role: "tool", Content: "package a", ToolCallID: "a"},
+		{Role: "tool", Content: "package b", ToolCallID: "b"},
+		{Role: "assistant", Content: "Both are empty"},
+	}, "jsonl-chat", Options{})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.JSONEq(t, `{"messages":[
+		{"role":"user","content":"read a.go and b.go"},
+		{"role":"assistant","tool_calls":[
+			{"id":"a","type":"function","function":{"name":"read_file","arguments":"{\"path\":\"a.go\"}"}},
+			{"id":"b","type":"function","function":{"name":"read_file","arguments":"{\"path\":\"b.go\"}"}}
+		]},
+		{"role":"tool","content":"package a","tool_call_id":"a"},
+		{"role":"tool","content":"package b","tool_call_id":"b"},
+		{"role":"assistant","content":"Both are empty"}
+	]}`, string(line))
+}
@@ -0,0 +1,456 @@
+package ui
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/commands"
+	"github.com/penguinpowernz/clai/internal/i18n"
+	"github.com/penguinpowernz/clai/internal/secrets"
+)
+
+// Accessible is a line based UI for use with screen readers, nothing is ever
+// redrawn and changes in state are announced as plain lines of text
+type Accessible struct {
+	r        io.Reader
+	w        io.Writer
+	in, out  chan any
+	lines    chan string
+	injected chan string
+
+	busy         bool
+	responding   bool
+	continuing   bool         // the response continues the last message
+	warming      bool         // the model is being loaded
+	budgetWarned bool         // the user was told most of the budget is spent
+	tool         string       // the tool that is running
+	pending      *ai.ToolCall // the tool waiting for permission
+	effects      []string     // what the pending tool will change
+	secret       string       // the prompt that looks like it has a secret in it
+	choices      []string     // the models or follow-ups that can be picked by number
+	pick         func(i int)  // what to do when a choice is picked
+	messages     []ai.Message // user and assistant messages, for /copy
+	response     strings.Builder
+}
+
+// NewAccessible creates the UI, reading prompts from r and writing to w
+func NewAccessible(r io.Reader, w io.Writer) *Accessible {
+	return &Accessible{
+		r:        r,
+		w:        w,
+		out:      make(chan any, EventBuffer),
+		lines:    make(chan string),
+		injected: make(chan string),
+	}
+}
+
+func (a *Accessible) AddObserver(observer UIObserver) {
+	observer.Observe(a.out)
+}
+
+func (a *Accessible) Observe(events chan any) {
+	a.in = events
+}
+
+// Inject sends a prompt that didn't come from the user, it is dropped if the
+// assistant is busy
+func (a *Accessible) Inject(prompt string) {
+	a.injected <- prompt
+}
+
+func (a *Accessible) say(line string) {
+	fmt.Fprintln(a.w, line)
+}
+
+func (a *Accessible) send(ev any) {
+	go func() { a.out <- ev }()
+}
+
+// Run reads prompts and shows the responses until the user exits
+func (a *Accessible) Run(ctx context.Context) error {
+	go func() {
+		scanner := bufio.NewScanner(a.r)
+		for scanner.Scan() {
+			a.lines <- scanner.Text()
+		}
+		close(a.lines)
+	}()
+
+	a.say(i18n.T("a11y.ready"))
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case line, ok := <-a.lines:
+			if !ok {
+				return nil
+			}
+			if a.onLine(strings.TrimSpace(line)) {
+				return nil
+			}
+
+		case prompt := <-a.injected:
+			if a.busy || a.pending != nil || a.secret != "" {
+				a.say(i18n.T("a11y.skipped_busy", prompt))
+				continue
+			}
+			a.say("> " + prompt)
+			if !a.checkSecrets(prompt) {
+				a.prompt(prompt)
+			}
+
+		case ev := <-a.in:
+			if a.onEvent(ev) {
+				return nil
+			}
+		}
+	}
+}
+
+// onLine handles a line typed by the user, returning true to exit
+func (a *Accessible) onLine(line string) bool {
+	if line == "" {
+		return false
+	}
+
+	if a.pending != nil {
+		switch strings.ToLower(line) {
+		case i18n.T("a11y.answer_once"):
+			a.send(EventPermitToolUse(*a.pending))
+		case i18n.T("a11y.answer_session"):
+			a.send(EventPermitToolUseThisSession(*a.pending))
+		case i18n.T("a11y.answer_deny"):
+			a.send(EventCancelToolUse(*a.pending))
+			a.say(i18n.T("a11y.tool_denied", a.pending.Name))
+		default:
+			a.say(i18n.T("a11y.permission_keys"))
+			return false
+		}
+		a.pending = nil
+		return false
+	}
+
+	if a.secret != "" {
+		a.onSecretAnswer(line)
+		return false
+	}
+
+	if n, err := strconv.Atoi(line); err == nil && a.pick != nil && !a.busy {
+		if n < 1 || n > len(a.choices) {
+			a.say(i18n.T("a11y.no_choice", n))
+			return false
+		}
+		pick := a.pick
+		a.pick, a.choices = nil, nil
+		pick(n - 1)
+		return false
+	}
+
+	if a.busy {
+		if line == "/stop" {
+			a.send(EventCancelStream{})
+			return false
+		}
+		a.say(i18n.T("a11y.still_responding"))
+		return false
+	}
+
+	a.pick, a.choices = nil, nil
+	if !a.checkSecrets(line) {
+		a.prompt(line)
+	}
+	return false
+}
+
+// checkSecrets holds the prompt if it looks like it has a secret in it,
+// returning false if it didn't so it can be sent
+func (a *Accessible) checkSecrets(line string) bool {
+	found := secrets.Find(line)
+	if line[0] == '/' || len(found) == 0 {
+		return false
+	}
+
+	a.secret = line
+	a.say(i18n.T("chat.secret_found", strings.Join(secrets.Kinds(found), ", ")))
+	a.say(i18n.T("a11y.secret_keys"))
+	return true
+}
+
+// onSecretAnswer redacts the secrets and sends the prompt, sends it as it is,
+// or drops it
+func (a *Accessible) onSecretAnswer(line string) {
+	switch strings.ToLower(line) {
+	case "r":
+		a.prompt(secrets.Redact(a.secret, secrets.Find(a.secret), ai.Redacted))
+	case "s":
+		a.prompt(a.secret)
+	case "c":
+		a.say(i18n.T("a11y.not_sent"))
+	default:
+		a.say(i18n.T("a11y.secret_keys"))
+		return
+	}
+	a.secret = ""
+}
+
+func (a *Accessible) prompt(line string) {
+	sent := line
+	if line[0] != '/' {
+		sent = withMessageRefs(line, func(n int) (string, bool) {
+			if n < 1 || n > len(a.messages) {
+				return "", false
+			}
+			return a.messages[n-1].Content, true
+		})
+		a.busy = true
+		a.messages = append(a.messages, ai.Message{Role: "user", Content: line})
+	}
+	a.send(EventUserPrompt(sent))
+}
+
+// onEvent handles an event from the session, returning true to exit
+func (a *Accessible) onEvent(ev any) bool {
+	switch msg := ev.(type) {
+	case EventStreamStarted:
+		a.busy = true
+		a.say(i18n.T("a11y.thinking"))
+
+	case EventStreamChunk:
+		if !a.responding {
+			a.responding = true
+			a.response.Reset()
+			a.say(i18n.T("a11y.responding"))
+		}
+		a.response.WriteString(string(msg))
+		fmt.Fprint(a.w, string(msg))
+
+	case EventMessageID:
+		// the last prompt or response is the one the session means
+		for i := len(a.messages) - 1; i >= 0; i-- {
+			if a.messages[i].Role == msg.Role {
+				if a.messages[i].ID == "" {
+					a.messages[i].ID = msg.ID
+				}
+				break
+			}
+		}
+
+	case EventStreamEnded:
+		if a.responding && a.continuing && len(a.messages) > 0 {
+			fmt.Fprintln(a.w)
+			a.messages[len(a.messages)-1].Content += stripThinkBlock(a.response.String())
+		} else if a.responding {
+			fmt.Fprintln(a.w)
+			a.messages = append(a.messages, ai.Message{Role: "assistant", Content: stripThinkBlock(a.response.String())})
+		}
+		a.responding = false
+		a.continuing = false
+
+	case EventStreamStalled:
+		a.responding = false
+		a.say(i18n.T("chat.stalled", time.Duration(msg)))
+
+	case EventStreamBanned:
+		a.responding = false
+		a.say(i18n.T("chat.banned", string(msg)))
+
+	case EventStreamCancelled:
+		a.responding = false
+		a.busy = false
+		a.say(i18n.T("a11y.stopped"))
+
+	case EventStreamErr:
+		a.responding = false
+		a.say(i18n.T("error", msg))
+
+	case EventReconnecting:
+		a.say(i18n.T("a11y.reconnecting", msg.Delay))
+
+	case EventToolDetails:
+		a.effects = msg.Effects
+
+	case EventToolCall:
+		tc := ai.ToolCall(msg)
+		a.pending = &tc
+		a.say(i18n.T("a11y.permission_ask", tc.Name, tc.Input))
+		if len(a.effects) > 0 {
+			a.say(i18n.T("permission.effects") + " " + strings.Join(a.effects, "; "))
+			a.effects = nil
+		}
+		a.say(i18n.T("a11y.allow_it"))
+
+	case EventRunningTool:
+		a.tool = msg.Name
+		a.say(i18n.T("a11y.running_tool", msg.Name))
+
+	case EventRunningToolDone:
+		if msg.Code != "" {
+			a.say(i18n.T("a11y.tool_finished", a.tool) + ", " + i18n.T("chat.tool_error."+string(msg.Code)))
+			break
+		}
+		a.say(i18n.T("a11y.tool_finished", a.tool))
+
+	case EventToolOutput:
+		a.say(i18n.T("chat.tool_output") + "\n" + stripANSI(string(msg)))
+
+	case EventSystemMsg:
+		a.say(stripANSI(string(msg)))
+
+	case EventArtifacts:
+		for _, f := range msg {
+			a.say(i18n.T("a11y.artifact", f.MIME, f.Path))
+		}
+
+	case EventSlashCommand:
+		res := commands.Result(msg)
+		if res.ShouldExit {
+			a.say(i18n.T("a11y.goodbye"))
+			return true
+		}
+		if res.Copy != nil {
+			a.copy(*res.Copy)
+			break
+		}
+		if res.Redact != nil {
+			a.redact(res.Redact)
+			break
+		}
+		if res.Edit != nil {
+			a.say(i18n.T("a11y.no_editor"))
+			break
+		}
+		if res.Preview != nil {
+			a.say(i18n.T("a11y.artifact", res.Preview.MIME, res.Preview.Path))
+			break
+		}
+		if res.ToggleMultiline {
+			a.say(i18n.T("a11y.no_multiline"))
+			break
+		}
+		if res.SwitchBranch != "" {
+			a.send(EventSwitchBranch(res.SwitchBranch))
+			break
+		}
+		a.say(stripANSI(res.Message))
+
+	case EventBranchSwitched:
+		a.say(i18n.T("chat.branch_switched", msg.ID))
+
+	case EventRetry:
+		a.busy = true
+		a.say(i18n.T("a11y.retrying"))
+
+	case EventContinue:
+		a.busy = true
+		a.continuing = true
+		a.say(i18n.T("a11y.continuing"))
+
+	case EventWorkingDir:
+		a.say(i18n.T("a11y.working_dir", string(msg)))
+
+	case EventModelSelection:
+		a.say(i18n.T("a11y.models"))
+		var choices []string
+		for _, o := range msg {
+			choices = append(choices, o.String())
+		}
+		a.offer(choices, func(i int) { a.send(EventModelSelected(msg[i].Name)) })
+
+	case EventFollowUps:
+		if a.busy {
+			break
+		}
+		a.say(i18n.T("a11y.follow_ups"))
+		a.offer(msg, func(i int) { a.prompt(msg[i]) })
+
+	case EventBudget:
+		// only say it when it first gets close, it is sent after every response
+		if msg.Warning && !a.budgetWarned {
+			a.say(i18n.T("a11y.budget_"+msg.Kind, msg.Spent, msg.Limit))
+		}
+		a.budgetWarned = msg.Warning
+
+	case EventHealth:
+		switch msg.State {
+		case HealthWarming:
+			a.warming = true
+			a.say(i18n.T("a11y.loading_model"))
+		case HealthReady:
+			if a.warming {
+				a.say(i18n.T("a11y.model_loaded"))
+			}
+			a.warming = false
+		case HealthNoModel:
+			a.warming = false
+			a.say(i18n.T("chat.no_model", msg.Err))
+		case HealthDown:
+			a.warming = false
+			a.say(i18n.T("chat.provider_down", msg.Err))
+		}
+
+	case EventTurnDone:
+		a.busy = false
+		a.say(i18n.T("a11y.turn_done"))
+
+	default:
+		log.Printf("[accessible] ignoring event %T", ev)
+	}
+
+	return false
+}
+
+// offer lists the choices for the user to pick from by number
+func (a *Accessible) offer(choices []string, pick func(i int)) {
+	for i, c := range choices {
+		fmt.Fprintf(a.w, "%d) %s\n", i+1, c)
+	}
+	a.choices = choices
+	a.pick = pick
+}
+
+// copy copies the nth message to the clipboard, 0 for the last response
+func (a *Accessible) copy(n int) {
+	if n == 0 {
+		n = len(a.messages)
+	}
+	if n < 1 || n > len(a.messages) {
+		a.say(i18n.T("a11y.no_copy", n))
+		return
+	}
+
+	if err := copyToClipboard(os.Stderr, a.messages[n-1].Content); err != nil {
+		a.say(i18n.T("a11y.copy_failed", err))
+		return
+	}
+	a.say(i18n.T("a11y.copied", n))
+}
+
+// redact masks the prompts and responses numbered ns, and has the session
+// mask them in its messages too
+func (a *Accessible) redact(ns []int) {
+	for _, n := range ns {
+		if n < 1 || n > len(a.messages) {
+			a.say(i18n.T("chat.no_redact", n))
+			return
+		}
+	}
+
+	var ids []string
+	for _, n := range ns {
+		ids = append(ids, a.messages[n-1].ID)
+		a.messages[n-1].Content = ai.Redacted
+	}
+	a.send(EventRedact(ids))
+
+	a.say(i18n.T("chat.redacted", numberRange(ns)))
+}
@@ -0,0 +1,57 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/penguinpowernz/clai/internal/i18n"
+)
+
+// attachPaste keeps a paste of more than paste_lines lines out of the prompt,
+// it is added to the message when it is sent.  It returns false if the paste
+// should go in the prompt as usual.
+func (m *ChatModel) attachPaste(text string) bool {
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+	text = strings.Trim(text, "\n")
+	if m.cfg.PasteLines <= 0 || lineCount(text) <= m.cfg.PasteLines {
+		return false
+	}
+
+	m.attachments = append(m.attachments, text)
+	return true
+}
+
+func lineCount(s string) int {
+	return strings.Count(s, "\n") + 1
+}
+
+// withAttachments adds the attachments to the end of the prompt as fenced
+// blocks
+func withAttachments(prompt string, attachments []string) string {
+	var b strings.Builder
+	b.WriteString(prompt)
+	for _, a := range attachments {
+		// the fence has to be longer than any in the paste
+		fence := "```"
+		for strings.Contains(a, fence) {
+			fence += "`"
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString(fence + "\n" + a + "\n" + fence)
+	}
+	return b.String()
+}
+
+// renderAttachments shows a chip for each attachment above the prompt
+func (m ChatModel) renderAttachments() string {
+	if len(m.attachments) == 0 {
+		return ""
+	}
+
+	chips := make([]string, len(m.attachments))
+	for i, a := range m.attachments {
+		chips[i] = systemStyle.Render(i18n.T("prompt.attachment", i+1, lineCount(a)))
+	}
+	return strings.Join(chips, " ") + "  " + helpStyle.Render(i18n.T("prompt.attachment_help")) + "\n"
+}
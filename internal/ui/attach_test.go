@@ -0,0 +1,24 @@
+package ui
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttachPaste(t *testing.T) {
+	cfg := config.Default()
+	cfg.PasteLines = 3
+	m := NewChatModel(context.Background(), cfg)
+
+	assert.False(t, m.attachPaste("one\ntwo\nthree\n"))
+	assert.True(t, m.attachPaste("a\r\nb\r\nc\r\nd\r\n"))
+	assert.True(t, m.attachPaste("```go\nx\n```\ny"))
+	assert.Equal(t, []string{"a\nb\nc\nd", "```go\nx\n```\ny"}, m.attachments)
+
+	assert.Equal(t, "look at this\n\n```\na\nb\nc\nd\n```\n\n````\n```go\nx\n```\ny\n````", withAttachments("look at this", m.attachments))
+	assert.True(t, strings.HasPrefix(withAttachments("", m.attachments), "```\na"))
+}
@@ -0,0 +1,68 @@
+package ui
+
+import (
+	"errors"
+	"net"
+	"net/http"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/i18n"
+)
+
+// describeError gives the reason the provider gave for the request failing,
+// and a hint about what to do about it if there is one
+func (m ChatModel) describeError(err error) (msg, hint string) {
+	var apiErr ai.APIError
+	var netErr net.Error
+
+	switch {
+	case errors.As(err, &apiErr):
+		msg = i18n.T("banner.status", apiErr.Status, apiErr.Message)
+		switch {
+		case apiErr.Status == http.StatusUnauthorized || apiErr.Status == http.StatusForbidden:
+			hint = i18n.T("banner.auth")
+		case apiErr.Status == http.StatusTooManyRequests:
+			hint = i18n.T("banner.rate_limit")
+		case apiErr.Status >= 500:
+			hint = i18n.T("banner.server")
+		}
+
+	case errors.As(err, &netErr):
+		msg = err.Error()
+		hint = i18n.T("banner.network", m.cfg.BaseURL)
+
+	default:
+		msg = err.Error()
+	}
+
+	return msg, hint
+}
+
+// renderErrorBanner shows why the last request failed and what can be done
+// about it, until the next prompt is sent or it is dismissed
+func (m ChatModel) renderErrorBanner() string {
+	if m.lastErr == nil {
+		return ""
+	}
+
+	msg, hint := m.describeError(m.lastErr)
+	banner := errorStyle.Render("✖ "+msg) + "\n"
+	if hint != "" {
+		banner += hint + "\n"
+	}
+	return banner + helpStyle.Render(i18n.T("banner.actions")) + "\n"
+}
+
+// errorAction does what the key pressed on the error banner is for
+func (m *ChatModel) errorAction(key string) (tea.Model, tea.Cmd) {
+	m.lastErr = nil
+
+	switch key {
+	case "r":
+		return m.submit("/retry")
+	case "m":
+		return m.submit("/models")
+	}
+	return m, m.editConfig()
+}
@@ -0,0 +1,32 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/i18n"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribeError(t *testing.T) {
+	cfg := config.Default()
+	cfg.BaseURL = "http://localhost:11434"
+	m := NewChatModel(context.Background(), cfg)
+
+	msg, hint := m.describeError(fmt.Errorf("failed: %w", ai.APIError{Status: 429, Message: "slow down"}))
+	assert.Equal(t, "429: slow down", msg)
+	assert.Equal(t, i18n.T("banner.rate_limit"), hint)
+
+	_, hint = m.describeError(fmt.Errorf("failed to send request: %w", &net.OpError{Op: "dial", Err: fmt.Errorf("connection refused")}))
+	assert.Contains(t, hint, "http://localhost:11434")
+
+	m.onStreamErr(ai.APIError{Status: 401, Message: "bad key"})
+	assert.Contains(t, m.renderErrorBanner(), "401: bad key")
+
+	m.errorAction("r")
+	assert.Empty(t, m.renderErrorBanner())
+}
@@ -0,0 +1,78 @@
+package ui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/penguinpowernz/clai/internal/commands"
+)
+
+// BranchPicker is the tree of branches shown by /branches, picking one
+// carries on the conversation in it
+type BranchPicker struct {
+	title    string
+	options  []commands.BranchOption
+	selected int
+}
+
+// NewBranchPicker creates a picker with the current branch selected
+func NewBranchPicker(title string, options []commands.BranchOption) *BranchPicker {
+	p := &BranchPicker{title: title, options: options}
+	for i, o := range options {
+		if o.Current {
+			p.selected = i
+		}
+	}
+	return p
+}
+
+func (p *BranchPicker) Init() tea.Cmd {
+	return nil
+}
+
+func (p *BranchPicker) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return p, nil
+	}
+
+	switch key.String() {
+	case "up", "k":
+		if p.selected > 0 {
+			p.selected--
+		}
+	case "down", "j":
+		if p.selected < len(p.options)-1 {
+			p.selected++
+		}
+	case "enter":
+		var id string
+		if o := p.options[p.selected]; !o.Current {
+			id = o.ID
+		}
+		return p, func() tea.Msg { return EventListDone{p.title, id} }
+	case "esc", "q":
+		return p, func() tea.Msg { return EventListDone{p.title, ""} }
+	}
+
+	return p, nil
+}
+
+func (p *BranchPicker) View() string {
+	var b strings.Builder
+	b.WriteString("\n" + p.title + ":\n\n")
+
+	for i, o := range p.options {
+		mark := "  "
+		if o.Current {
+			mark = "* "
+		}
+		if i == p.selected {
+			b.WriteString(assistantStyle.Render(">"+mark+o.Line) + "\n")
+			continue
+		}
+		b.WriteString(" " + mark + o.Line + "\n")
+	}
+
+	return b.String()
+}
@@ -3,8 +3,11 @@ package ui
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/spinner"
@@ -12,22 +15,24 @@ import (
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/muesli/reflow/wordwrap"
 	"github.com/penguinpowernz/clai/config"
 	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/artifacts"
 	"github.com/penguinpowernz/clai/internal/history"
-)
-
-const (
-	maxLineLength = 120
-
-	titleSelectModel = "Select the model to use"
+	"github.com/penguinpowernz/clai/internal/i18n"
+	"github.com/penguinpowernz/clai/internal/tools"
 )
 
 type UIObserver interface {
 	Observe(chan any)
 }
 
+// modelFlashTime is how long the model is shown after switching with Ctrl+T
+const modelFlashTime = 2 * time.Second
+
+// eventModelFlashed is sent when the model has been shown for long enough
+type eventModelFlashed string
+
 // ChatModel is the bubbletea model for the REPL
 type ChatModel struct {
 	ctx           context.Context
@@ -38,20 +43,83 @@ type ChatModel struct {
 	typing        bool
 	runningTool   bool
 	thinking      bool
-	inThinkBlock  bool
 	err           error
 	width         int
 	height        int
 	currentStream *strings.Builder
-	in, out       chan any
-	prompt Prompt
+	generated     int // bytes generated for the current response, including reasoning
+
+	// the next stream continues the last response, which starts with the prefix
+	continuing   bool
+	streamPrefix string
+	in, out      chan any
+	prompt       Prompt
+	currList     tea.Model
+	workingDir   string // relative to where the session started
+	health       EventHealth
+	modelFlash   string         // the model that was just switched to, shown for a moment
+	history      *history.Store // where the transcript is saved, shared with the session
+	budget       EventBudget
+	usage        EventUsage
+	wrapped      *wrapCache
+
 	userIsScrolling bool
 
+	// big pastes kept out of the prompt, see attachPaste
+	attachments []string
+
+	// the ways the slash command could be completed, shown while the prompt
+	// is still the completed line
+	completions   []string
+	completedLine string
+
+	// the unsent prompt is saved so it can be restored, see SetDraftFile
+	draftFile string
+	draft     string
+
+	// the terminal when it isn't stdout, see SetOutput
+	output io.Writer
+
+	// suggested prompts to send next, picked with the number keys
+	followUps []string
+
+	// responses to the last prompt made by /retry, and the one being shown
+	variants [][]ai.Message
+	variant  int
+
+	// selection mode for copying messages
+	selecting bool
+	selected  int // the number of the selected message, see copyable()
+
 	// Tool permission selection
 	pendingToolCall       *ai.ToolCall
 	toolPermissionList    list.Model
 	toolPermissionOptions []string
 	selectedOption        int
+	toolDetails           *tools.Details // what the pending tool call will do
+	showToolDetails       bool
+	toolQueue             []ToolQueueItem            // the tool calls from the last response
+	liveMsg               int                        // the block of the running tool, -1 if there isn't one
+	toolBlocks            map[*ai.ToolUse]*toolBlock // the tool blocks, by the ToolCall of their message
+
+	// why the last request failed, shown until the next prompt is sent
+	lastErr error
+
+	// the provider can't be reached, the prompt is sent again when it can
+	reconnecting *EventReconnecting
+
+	// the prompt looks like it has a secret in it, see checkSecrets
+	pendingSecret *pendingSecret
+
+	// the prompt is held while files are suggested for it, see suggestFiles
+	pendingSuggest *pendingSuggest
+
+	// another terminal attached to the session is driving it, see onRole
+	readOnly bool
+	driver   string
+
+	// who asked to drive the session, until the user answers
+	takeoverFrom string
 }
 
 func NewChatModel(ctx context.Context, cfg *config.Config) *ChatModel {
@@ -75,12 +143,14 @@ func NewChatModel(ctx context.Context, cfg *config.Config) *ChatModel {
 		viewport:              vp,
 		messages:              make([]ai.Message, 0),
 		currentStream:         &strings.Builder{},
+		wrapped:               newWrapCache(),
 		in:                    make(chan any),
-		out:                   make(chan any),
+		out:                   make(chan any, EventBuffer),
 		toolPermissionList:    createToolPermissionList(),
-		toolPermissionOptions: []string{optAllowToolThisTime, optAllowToolThisSession, optDisallowTool},
+		toolPermissionOptions: []string{i18n.T("permission.once"), i18n.T("permission.session"), i18n.T("permission.deny")},
 		selectedOption:        0,
-		prompt:             ti,
+		liveMsg:               -1,
+		toolBlocks:            make(map[*ai.ToolUse]*toolBlock),
 	}
 
 	return &model
@@ -90,21 +160,42 @@ func (m *ChatModel) addMessage(role, msg string) {
 	m.messages = append(m.messages, ai.Message{
 		Role:    role,
 		Content: msg,
+		Time:    time.Now(),
+		Tokens:  ai.EstimateTokens(msg),
 	})
 
+	m.redrawMessages()
+	m.saveTranscript()
+}
+
+// redrawMessages shows the messages after they've changed
+func (m *ChatModel) redrawMessages() {
 	m.viewport.SetContent(m.renderMessages())
 
 	if !m.userIsScrolling {
 		m.viewport.GotoBottom()
 	}
+}
 
-	if m.cfg.SaveHistory {
-		if err := history.SaveHistory("ui", m.messages); err != nil {
+func (m *ChatModel) saveTranscript() {
+	if m.history != nil && m.cfg.SaveHistory {
+		if err := m.history.Save("ui", m.messages); err != nil {
 			log.Println("[ui] Error saving history:", err)
 		}
 	}
 }
 
+// SetHistory sets where the transcript is saved, it should be the store the
+// session uses
+func (m *ChatModel) SetHistory(h *history.Store) {
+	m.history = h
+}
+
+// AddSystemMessage shows a message from the system in the transcript
+func (m *ChatModel) AddSystemMessage(msg string) {
+	m.addMessage("system", msg)
+}
+
 func (m *ChatModel) updateMessage(role, chunk string) {
 	m.currentStream.WriteString(chunk)
 
@@ -132,6 +223,12 @@ func (m ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmd, taCmd, spCmd, listCmd, vpCmd tea.Cmd
 	)
 
+	if key, ok := msg.(tea.KeyMsg); ok && key.Paste && m.currList == nil && m.pendingToolCall == nil && m.pendingSecret == nil && m.pendingSuggest == nil && !m.selecting && m.canType() {
+		if m.attachPaste(string(key.Runes)) {
+			return m, nil
+		}
+	}
+
 	if m.currList != nil {
 		m.currList, cmd = m.currList.Update(msg)
 		if cmd != nil {
@@ -141,8 +238,9 @@ func (m ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	}
 
-	// Only update textarea if we're not in tool permission mode
-	if m.pendingToolCall == nil {
+	// Only update textarea if we're not in tool permission, secret, suggestion,
+	// selection or list mode, so what was typed in it is kept
+	if m.pendingToolCall == nil && m.pendingSecret == nil && m.pendingSuggest == nil && !m.selecting && m.currList == nil && m.canType() {
 		m.prompt, taCmd = m.prompt.Update(msg)
 		cmds = append(cmds, taCmd)
 	}
@@ -193,47 +291,194 @@ func (m ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 	case EventModelSelection:
-		l := NewSimpleList(titleSelectModel, msg...)
-		m.currList = l
+		m.currList = NewModelPicker(i18n.T("list.select_model"), msg)
+
+	case EventToolState:
+		cmds = append(cmds, func() tea.Msg { m.out <- msg; return nil })
 
 	case EventListDone:
 		log.Printf("[ui.event] list done %+v", msg)
 		switch msg.title {
-		case titleSelectModel:
-			cmds = append(cmds, func() tea.Msg { m.out <- EventModelSelected(msg.option); return nil })
+		case i18n.T("list.select_model"):
+			if msg.option != "" {
+				cmds = append(cmds, func() tea.Msg { m.out <- EventModelSelected(msg.option); return nil })
+			}
+		case i18n.T("list.branches"):
+			if msg.option != "" {
+				cmds = append(cmds, func() tea.Msg { m.out <- EventSwitchBranch(msg.option); return nil })
+			}
 		}
 		m.currList = nil
-		m.prompt.Reset()
 
 	case EventSlashCommand:
 		return m.handleSlashCommand(msg)
 
 	case EventExit:
+		m.saveDraft()
 		return m, tea.Quit
 
+	case eventSaveDraft:
+		m.saveDraft()
+		cmds = append(cmds, saveDraftLater())
+
+	case eventEdited:
+		m.onEdited(msg)
+
+	case eventConfigEdited:
+		m.onConfigEdited(msg)
+
+	case eventPreviewed:
+		m.onPreviewed(msg)
+
+	case EventModelChanged:
+		m.modelFlash = string(msg)
+		return m, tea.Batch(listen(m), tea.Tick(modelFlashTime, func(time.Time) tea.Msg { return eventModelFlashed(msg) }))
+
+	case EventCompletions:
+		m.onCompletions(msg)
+		return m, listen(m)
+
+	case EventFileSuggestions:
+		return m.onFileSuggestions(msg)
+
+	case eventModelFlashed:
+		if m.modelFlash == string(msg) {
+			m.modelFlash = ""
+		}
+
 	case EventStreamChunk:
 		m.onStreamChunk(string(msg))
 		cmds = append(cmds, listen(m))
 
 	case EventSystemMsg:
 		m.onSystemMessage(string(msg))
+		cmds = append(cmds, listen(m))
 
 	case EventStreamEnded:
 		m.onStreamEnded(string(msg))
-		return m, textinput.Blink
+		return m, tea.Batch(textinput.Blink, listen(m))
+
+	case EventInjectPrompt:
+		return m.onInjectPrompt(string(msg))
+
+	case EventMessageID:
+		m.onMessageID(msg)
+		return m, listen(m)
 
 	case EventStreamThink:
 		m.onStreamThink(string(msg))
 		cmds = append(cmds, listen(m))
 
 	case EventStreamCancelled:
+		m.reconnecting = nil
 		m.onStreamCancelled()
 		return m, listen(m)
 
+	case EventReconnecting:
+		m.reconnecting = &msg
+		return m, listen(m)
+
+	case EventStreamStalled:
+		m.onStreamCancelled()
+		m.addMessage("system", i18n.T("chat.stalled", time.Duration(msg)))
+		return m, listen(m)
+
+	case EventStreamBanned:
+		m.onStreamCancelled()
+		m.addMessage("system", i18n.T("chat.banned", string(msg)))
+		return m, listen(m)
+
+	case EventRetry:
+		m.onRetry()
+		return m, listen(m)
+
+	case EventContinue:
+		m.continuing = true
+		return m, listen(m)
+
+	case EventStreamErr:
+		m.onStreamErr(msg)
+		return m, listen(m)
+
+	case EventTurnDone:
+		// a turn can end without a stream, e.g. when it is held for the budget
+		m.typing = false
+		m.thinking = false
+		m.toolQueue = nil
+		m.reconnecting = nil
+		return m, listen(m)
+
+	case EventBudget:
+		m.budget = msg
+		return m, listen(m)
+
+	case EventUsage:
+		m.usage = msg
+		return m, listen(m)
+
+	case EventFollowUps:
+		// the user may have moved on already
+		if !m.typing && !m.thinking && !m.runningTool {
+			m.followUps = msg
+			m.viewport.SetContent(m.renderMessages())
+			if !m.userIsScrolling {
+				m.viewport.GotoBottom()
+			}
+		}
+		return m, listen(m)
+
+	case EventWorkingDir:
+		m.workingDir = string(msg)
+		return m, listen(m)
+
+	case EventTitle:
+		return m, tea.Batch(tea.SetWindowTitle("clai: "+msg.Title), listen(m))
+
+	case EventAttached:
+		return m, tea.Batch(tea.SetWindowTitle("clai: "+string(msg)), listen(m))
+
+	case EventPeerPrompt:
+		return m.onPeerPrompt(string(msg))
+
+	case EventPeerAnswered:
+		m.onPeerAnswered(msg)
+		return m, listen(m)
+
+	case EventRole:
+		m.onRole(msg)
+		return m, listen(m)
+
+	case EventBranchSwitched:
+		m.onBranchSwitched(msg)
+		return m, listen(m)
+
+	case EventTakeoverAsked:
+		m.onTakeoverAsked(string(msg))
+		return m, listen(m)
+
+	case EventHealth:
+		m.health = msg
+		switch msg.State {
+		case HealthDown:
+			m.addMessage("system", i18n.T("chat.provider_down", msg.Err))
+		case HealthNoModel:
+			m.addMessage("system", i18n.T("chat.no_model", msg.Err))
+		}
+		return m, listen(m)
+
 	case EventStreamStarted:
 		m.onStreamStarted()
 		cmds = append(cmds, listen(m))
 
+	case EventToolQueue:
+		m.toolQueue = msg
+		return m, listen(m)
+
+	case EventToolDetails:
+		d := tools.Details(msg)
+		m.toolDetails = &d
+		return m, listen(m)
+
 	case EventToolCall:
 		m.OnToolCallReceived(msg)
 		return m, listen(m)
@@ -255,18 +500,24 @@ func (m ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		)
 
 	case EventRunningToolDone:
-		m.runningTool = false
-		m.typing = false
-		m.thinking = true
+		m.onRunningToolDone(msg)
 		return m, tea.Batch(
 			listen(m),
 			m.spinner.Tick,
 		)
 
+	case EventToolProgress:
+		m.onToolProgress(string(msg))
+		return m, listen(m)
+
 	case EventToolOutput:
 		m.onToolOutput(string(msg))
 		return m, listen(m)
 
+	case EventArtifacts:
+		m.onArtifacts(msg)
+		return m, listen(m)
+
 	}
 
 	// log.Printf("[ui] Unhandled message: %T", msg)
@@ -275,22 +526,27 @@ func (m ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (m ChatModel) View() string {
 	if m.err != nil {
-		return errorStyle.Render(fmt.Sprintf("Error: %v", m.err))
+		return errorStyle.Render(i18n.T("error", m.err))
 	}
 
 	var status string
 	switch {
+	case m.reconnecting != nil:
+		m.spinner.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+		status = m.spinner.View() + " " + i18n.T("status.reconnecting", m.reconnecting.Attempt, m.reconnecting.Delay)
 	case m.typing:
 		m.spinner.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("201"))
-		status = fmt.Sprintf("%s Typing...", m.spinner.View())
+		status = m.spinner.View() + " " + i18n.T("status.typing") + m.tokenProgress()
 	case m.thinking:
 		m.spinner.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("40"))
-		status = fmt.Sprintf("%s Thinking...", m.spinner.View())
+		status = m.spinner.View() + " " + i18n.T("status.thinking") + m.tokenProgress()
 	case m.runningTool:
 		m.spinner.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("21"))
-		status = fmt.Sprintf("%s Running tool...", m.spinner.View())
+		status = m.spinner.View() + " " + i18n.T("status.running_tool")
+	case m.lastErr != nil:
+		status = errorStyle.Render("⚠ " + i18n.T("status.request_failed"))
 	default:
-		status = "👍 Ready"
+		status = m.healthStatus() + m.tokenUsage()
 	}
 
 	var help string
@@ -298,11 +554,23 @@ func (m ChatModel) View() string {
 	var viewportContent = m.viewport.View()
 
 	switch {
+	case m.takeoverFrom != "":
+		help = helpStyle.Render(i18n.T("help.takeover"))
+		inputArea = errorStyle.Render("🤝 "+i18n.T("chat.takeover_request", m.takeoverFrom)) + "\n" + m.prompt.View()
+		status = "🤝 " + i18n.T("status.takeover")
+	case m.readOnly && !m.selecting:
+		// what the driver is being asked is shown, but only they can answer
+		help = helpStyle.Render(i18n.T("help.read_only"))
+		inputArea = m.prompt.View()
+		if m.pendingToolCall != nil {
+			inputArea = m.renderToolPermissionOptions()
+		}
+		status = "👀 " + i18n.T("status.read_only", m.driver)
 	// If we have a pending tool call, show the permission list instead of textarea
 	case m.pendingToolCall != nil:
-		help = helpStyle.Render("↑/↓: Navigate • ENTER: Select • Ctrl+C: Quit")
+		help = helpStyle.Render(i18n.T("help.permission"))
 		inputArea = m.renderToolPermissionOptions()
-		status = "👮 Tool Permission Required"
+		status = "👮 " + i18n.T("status.tool_permission")
 
 		// Reduce viewport height to make room for the tool permission list
 		// We need extra space for the list (about 5 lines)
@@ -310,15 +578,39 @@ func (m ChatModel) View() string {
 		// tempViewport.SetContent(m.renderMessages())
 		// tempViewport.GotoBottom()
 		// viewportContent = tempViewport.View()
+	case m.pendingSecret != nil:
+		help = helpStyle.Render(i18n.T("help.secret"))
+		inputArea = m.renderSecretWarning() + m.renderAttachments() + m.prompt.View()
+		status = "🔑 " + i18n.T("status.secret")
+	case m.pendingSuggest != nil && len(m.pendingSuggest.files) > 0:
+		help = helpStyle.Render(i18n.T("help.suggest"))
+		inputArea = m.renderSuggestion() + m.renderAttachments() + m.prompt.View()
+		status = "📎 " + i18n.T("status.suggest")
+	case m.selecting:
+		help = helpStyle.Render(i18n.T("help.select"))
+		inputArea = m.prompt.View()
+		status = "📋 " + i18n.T("status.select")
 	case m.currList != nil:
 
-		help = helpStyle.Render("↑/↓: Navigate • ENTER: Select • Ctrl+C: Quit")
+		help = helpStyle.Render(i18n.T("help.list"))
+		switch m.currList.(type) {
+		case *ModelPicker:
+			help = helpStyle.Render(i18n.T("help.picker"))
+		case *ToolPicker:
+			help = helpStyle.Render(i18n.T("help.tools"))
+		case *BranchPicker:
+			help = helpStyle.Render(i18n.T("help.branches"))
+		}
 		inputArea = m.currList.View()
-		status = "Selection Required"
+		status = i18n.T("status.selection_required")
 	default:
-		help = helpStyle.Render("ENTER: Send • Ctrl+C: Quit • ESC: Stop AI")
-		inputArea = m.prompt.View()
+		help = helpStyle.Render(i18n.T("help.prompt"))
+		if m.prompt.Multiline() {
+			help = helpStyle.Render(i18n.T("help.prompt_multiline"))
+		}
+		inputArea = m.renderErrorBanner() + m.renderAttachments() + m.renderCompletions() + m.prompt.View()
 	}
+	inputArea = m.renderToolQueue() + inputArea
 
 	var x []string
 	var nlcount int
@@ -343,6 +635,22 @@ func (m ChatModel) View() string {
 		viewportContent = strings.Repeat("\n", diff) + strings.Join(x, "\n")
 	}
 
+	if len(m.variants) > 1 && m.pendingToolCall == nil && !m.selecting {
+		status += fmt.Sprintf("  🔁 %d/%d ←/→", m.variant+1, len(m.variants))
+	}
+
+	if m.workingDir != "" && m.workingDir != "." {
+		status += "  📁 " + m.workingDir
+	}
+
+	if m.modelFlash != "" {
+		status += "  🤖 " + m.modelFlash
+	}
+
+	if m.budget.Warning {
+		status += "  " + errorStyle.Render("💸 "+i18n.T("status.budget_"+m.budget.Kind, m.budget.Spent, m.budget.Limit))
+	}
+
 	return fmt.Sprintf(
 		"%s\n\n%s\n\n%s",
 		viewportContent,
@@ -352,44 +660,164 @@ func (m ChatModel) View() string {
 }
 
 func (m ChatModel) renderMessages() string {
+	width := m.wrapWidth()
 	if len(m.messages) == 0 {
-		return welcomeMessage()
+		return m.wrapped.wrap(welcomeMessage(), width)
 	}
 
-	var b strings.Builder
-	b.WriteString(welcomeMessage())
+	var out strings.Builder
+	out.WriteString(m.wrapped.wrap(welcomeMessage(), width))
+
+	// each message is wrapped on its own so the wrapping can be cached
+	for i, msg := range m.messages {
+		var b strings.Builder
+		if m.selecting && msg.Role == "assistant" {
+			b.WriteString(m.selectionLabel(i))
+		}
 
-	for _, msg := range m.messages {
 		switch msg.Role {
 		case "user":
 			b.WriteString("\n\n")
+			if m.selecting {
+				b.WriteString(m.selectionLabel(i))
+			}
 			b.WriteString(userStyle.Render("\u2588 "))
 			b.WriteString(msg.Content)
+			if m.cfg.ShowMetadata {
+				b.WriteString("\n" + renderMetadata(msg))
+			}
 			b.WriteString("\n\n")
 		case "assistant", "assistant-streaming":
 			b.WriteString(msg.Content)
 			if msg.Role == "assistant-streaming" {
 				b.WriteString(cursorStyle.Render("▋"))
 			}
+			if msg.Interrupted {
+				b.WriteString(systemStyle.Render(i18n.T("chat.interrupted")))
+			}
+			if m.cfg.ShowMetadata && msg.Role == "assistant" {
+				b.WriteString("\n" + renderMetadata(msg))
+			}
 			b.WriteString("\n")
 		case "system":
 			b.WriteString(systemStyle.Render(msg.Content))
 			b.WriteString("\n\n")
 		case "tool":
-			b.WriteString(toolStyle.Render(msg.Content))
+			if msg.ToolCall != nil {
+				if m.selecting {
+					b.WriteString(m.selectionLabel(i))
+				}
+				b.WriteString(m.renderToolBlock(msg))
+			} else {
+				b.WriteString(toolStyle.Render(msg.Content))
+			}
 			b.WriteString("\n\n")
 		case "slashcmd":
 			b.WriteString(systemStyle.Render(msg.Content))
 			b.WriteString("\n\n")
+		case "artifact":
+			b.WriteString(systemStyle.Render("📎 ") + artifacts.Link(msg.Content, msg.Content))
+			b.WriteString("\n\n")
 		case "thinking":
 			if m.cfg.ShowThinking {
 				b.WriteString(thinkingStyle.Render(msg.Content))
 				b.WriteString("\n\n")
 			}
 		}
+
+		// the streaming message changes with every chunk, no point caching it
+		if msg.Role == "assistant-streaming" || i == len(m.messages)-1 {
+			out.WriteString(wrapText(b.String(), width))
+			continue
+		}
+		out.WriteString(m.wrapped.wrap(b.String(), width))
 	}
 
-	return wordwrap.String(b.String(), min(m.width, maxLineLength))
+	var b strings.Builder
+	for i, f := range m.followUps {
+		if i == 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(helpStyle.Render(fmt.Sprintf("  %d) %s", i+1, f)))
+		b.WriteString("\n")
+	}
+	out.WriteString(wrapText(b.String(), width))
+
+	return out.String()
+}
+
+// wrapWidth is the width to wrap the transcript at, the full width of the
+// terminal unless the wrap_width config is narrower
+func (m ChatModel) wrapWidth() int {
+	if m.cfg.WrapWidth > 0 && m.cfg.WrapWidth < m.width {
+		return m.cfg.WrapWidth
+	}
+	return m.width
+}
+
+// healthStatus shows whether the provider is ready for a prompt
+func (m ChatModel) healthStatus() string {
+	switch m.health.State {
+	case HealthChecking:
+		return "🔌 " + i18n.T("status.connecting")
+	case HealthWarming:
+		return "⏳ " + i18n.T("status.loading_model")
+	case HealthDown:
+		return errorStyle.Render("⚠ " + i18n.T("status.provider_down"))
+	case HealthNoModel:
+		return errorStyle.Render("⚠ " + i18n.T("status.no_model"))
+	}
+	return "👍 " + i18n.T("status.ready")
+}
+
+// tokenUsage shows how many tokens the session's responses have used, as the
+// provider counted them, and what the session has spent
+func (m ChatModel) tokenUsage() string {
+	total := m.usage.Total.Total()
+	if total == 0 {
+		return ""
+	}
+
+	count := strconv.Itoa(total)
+	if m.usage.Total.Estimated {
+		count = "~" + count
+	}
+
+	// local models are free
+	if m.usage.Cost > 0 {
+		return "  " + helpStyle.Render(i18n.T("status.usage_cost", count, m.usage.Cost))
+	}
+	return "  " + helpStyle.Render(i18n.T("status.usage", count))
+}
+
+// tokenProgress shows roughly how many tokens have been generated for the
+// response so far, against max_tokens
+func (m ChatModel) tokenProgress() string {
+	if m.generated == 0 {
+		return ""
+	}
+
+	tokens := ai.EstimateTokensLen(m.generated)
+	if m.cfg.MaxTokens <= 0 {
+		return "  " + helpStyle.Render(i18n.T("status.tokens", tokens))
+	}
+
+	progress := i18n.T("status.tokens_max", tokens, m.cfg.MaxTokens, tokens*100/m.cfg.MaxTokens)
+	if tokens*10 >= m.cfg.MaxTokens*9 {
+		return "  " + errorStyle.Render(progress)
+	}
+	return "  " + helpStyle.Render(progress)
+}
+
+// renderMetadata shows when the message was sent, and for responses which
+// model made it
+func renderMetadata(msg ai.Message) string {
+	parts := []string{msg.Time.Format("15:04:05")}
+	if msg.Model != "" {
+		parts = append(parts, msg.Model)
+	}
+	parts = append(parts, i18n.T("chat.tokens", msg.Tokens))
+	return helpStyle.Render(strings.Join(parts, " · "))
 }
 
 func welcomeMessage() string {
@@ -420,6 +848,7 @@ var (
 	thinkingStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("243"))
 	toolStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("227"))
 	errorStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Bold(true)
+	selectedStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("205")).Bold(true)
 )
 
 func min(a, b int) int {
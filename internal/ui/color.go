@@ -0,0 +1,51 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/ansi"
+	"github.com/muesli/termenv"
+)
+
+// noColor is set when colors are turned off with --no-color or NO_COLOR
+var noColor bool
+
+// SetNoColor turns off colors for all the styles, bold and other attributes
+// are kept.  Output from tools and plugins has any escape sequences removed.
+func SetNoColor(off bool) {
+	noColor = off
+	if off {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+}
+
+// plain removes escape sequences from text that came from outside of clai,
+// when colors are turned off
+func plain(s string) string {
+	if !noColor {
+		return s
+	}
+	return stripANSI(s)
+}
+
+// stripANSI removes ANSI escape sequences from the text
+func stripANSI(s string) string {
+	if !strings.ContainsRune(s, ansi.Marker) {
+		return s
+	}
+
+	var b strings.Builder
+	inEscape := false
+	for _, r := range s {
+		switch {
+		case r == ansi.Marker:
+			inEscape = true
+		case inEscape:
+			inEscape = !ansi.IsTerminator(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
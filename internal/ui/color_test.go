@@ -0,0 +1,13 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripANSI(t *testing.T) {
+	assert.Equal(t, "red and plain", stripANSI("\x1b[31mred\x1b[0m and plain"))
+	assert.Equal(t, "日本語", stripANSI("\x1b[1;38;5;205m日本語\x1b[0m"))
+	assert.Equal(t, "nothing", stripANSI("nothing"))
+}
@@ -0,0 +1,80 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/penguinpowernz/clai/internal/symbols"
+)
+
+// maxCompletions is how many of the completions are shown under the prompt
+const maxCompletions = 12
+
+// complete asks the session how the slash command in the prompt could be
+// completed
+func (m *ChatModel) complete() (tea.Model, tea.Cmd) {
+	line := m.prompt.Value()
+	if !strings.HasPrefix(line, "/") {
+		return m, nil
+	}
+	return m, func() tea.Msg { m.out <- EventComplete(line); return nil }
+}
+
+// completeSymbol asks for the names of the functions, types and variables
+// that the word at the end of the prompt could be
+func (m *ChatModel) completeSymbol() (tea.Model, tea.Cmd) {
+	line := m.prompt.Value()
+	if strings.HasPrefix(line, "/") || symbols.Prefix(line) == "" {
+		return m, nil
+	}
+	return m, func() tea.Msg { m.out <- EventCompleteSymbol(line); return nil }
+}
+
+// onCompletions fills in the prompt as far as all of the completions agree,
+// the completions are shown when there is more than one
+func (m *ChatModel) onCompletions(ev EventCompletions) {
+	m.completions = nil
+	if ev.Line != m.prompt.Value() || len(ev.Options) == 0 {
+		return
+	}
+
+	if prefix := commonPrefix(ev.Options); len(prefix) > len(ev.Line) {
+		m.prompt.SetValue(prefix)
+	}
+	if len(ev.Options) > 1 {
+		m.completions = ev.Options
+		m.completedLine = m.prompt.Value()
+	}
+}
+
+func commonPrefix(options []string) string {
+	prefix := options[0]
+	for _, o := range options[1:] {
+		for !strings.HasPrefix(o, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// renderCompletions shows the word each of the completions would fill in,
+// until something else is typed
+func (m ChatModel) renderCompletions() string {
+	if len(m.completions) == 0 || m.prompt.Value() != m.completedLine {
+		return ""
+	}
+
+	cut := strings.LastIndex(m.completedLine, " ") + 1
+	if !strings.HasPrefix(m.completedLine, "/") {
+		cut = len(m.completedLine) - len(symbols.Prefix(m.completedLine))
+	}
+	var words []string
+	for _, c := range m.completions[:min(len(m.completions), maxCompletions)] {
+		words = append(words, strings.TrimSpace(c[cut:]))
+	}
+	if more := len(m.completions) - maxCompletions; more > 0 {
+		words = append(words, fmt.Sprintf("+%d", more))
+	}
+	return helpStyle.Render(strings.Join(words, "  ")) + "\n"
+}
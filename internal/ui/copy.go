@@ -0,0 +1,198 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aymanbagabas/go-osc52/v2"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/i18n"
+)
+
+// copyable returns the indexes of the messages that can be copied, /copy and
+// the selection mode number the messages in this order starting at 1
+func (m ChatModel) copyable() []int {
+	var idx []int
+	for i, msg := range m.messages {
+		switch msg.Role {
+		case "user", "assistant":
+			if !strings.HasPrefix(msg.Content, "/") {
+				idx = append(idx, i)
+			}
+		case "tool":
+			if msg.ToolCall != nil {
+				idx = append(idx, i)
+			}
+		}
+	}
+	return idx
+}
+
+// copyMessage copies the nth copyable message to the clipboard, 0 copies the
+// last response
+func (m *ChatModel) copyMessage(n int) {
+	idx := m.copyable()
+
+	if n == 0 {
+		for i := len(idx) - 1; i >= 0; i-- {
+			if m.messages[idx[i]].Role == "assistant" {
+				n = i + 1
+				break
+			}
+		}
+	}
+
+	if n < 1 || n > len(idx) {
+		m.addMessage("system", i18n.T("chat.no_copy", n))
+		return
+	}
+
+	text := stripThinkBlock(m.messages[idx[n-1]].Content)
+	if err := copyToClipboard(m.terminal(), text); err != nil {
+		m.addMessage("system", i18n.T("chat.copy_failed", err))
+		return
+	}
+
+	m.addMessage("system", i18n.T("chat.copied", n, len(text)))
+}
+
+// copyToClipboard sets the clipboard with an OSC52 escape sequence written
+// to the terminal, which works over SSH as the terminal does the copying
+func copyToClipboard(w io.Writer, text string) error {
+	seq := osc52.New(text)
+	switch {
+	case os.Getenv("TMUX") != "":
+		seq = seq.Tmux()
+	case strings.HasPrefix(os.Getenv("TERM"), "screen"):
+		seq = seq.Screen()
+	}
+
+	_, err := seq.WriteTo(w)
+	return err
+}
+
+// terminal is where escape sequences that aren't part of the screen are
+// written.  stderr is the terminal too, and it keeps the sequences out of the
+// way of the renderer which writes to stdout.
+func (m ChatModel) terminal() io.Writer {
+	if m.output != nil {
+		return m.output
+	}
+	return os.Stderr
+}
+
+// SetOutput sets the terminal the UI is drawn on when it isn't stdout, like
+// one attached to the daemon
+func (m *ChatModel) SetOutput(w io.Writer) {
+	m.output = w
+}
+
+// handleSelectKey handles the keys in selection mode, where a message can be
+// picked to copy.  The mouse is released in selection mode so text can also
+// be selected with the terminal.
+func (m *ChatModel) handleSelectKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k":
+		if m.selected > 1 {
+			m.selected--
+		}
+
+	case "down", "j":
+		if m.selected < len(m.copyable()) {
+			m.selected++
+		}
+
+	case " ":
+		// unfold the tool block, or fold it again
+		if idx := m.copyable(); m.selected > 0 {
+			m.toggleToolBlock(idx[m.selected-1])
+		}
+
+	case "enter", "y":
+		m.selecting = false
+		m.copyMessage(m.selected)
+		return m, tea.EnableMouseCellMotion
+
+	case ">":
+		return m.quoteMessage(m.selected)
+
+	case "esc", "q", "ctrl+s":
+		m.selecting = false
+		m.viewport.SetContent(m.renderMessages())
+		return m, tea.EnableMouseCellMotion
+
+	case "ctrl+c":
+		m.saveDraft()
+		return m, tea.Quit
+	}
+
+	m.viewport.SetContent(m.renderMessages())
+	return m, nil
+}
+
+// startSelecting enters selection mode with the last message selected
+func (m *ChatModel) startSelecting() (tea.Model, tea.Cmd) {
+	n := len(m.copyable())
+	if n == 0 {
+		return m, nil
+	}
+
+	m.selecting = true
+	m.selected = n
+	m.viewport.SetContent(m.renderMessages())
+	return m, tea.DisableMouse
+}
+
+// selectionLabel is shown before the copyable messages in selection mode
+func (m ChatModel) selectionLabel(i int) string {
+	for n, idx := range m.copyable() {
+		if idx != i {
+			continue
+		}
+
+		if n+1 == m.selected {
+			return selectedStyle.Render(fmt.Sprintf("▶ [%d] ", n+1))
+		}
+		return helpStyle.Render(fmt.Sprintf("  [%d] ", n+1))
+	}
+	return ""
+}
+
+// redactMessages masks the copyable messages numbered ns in the transcript,
+// the returned cmd has the session mask them in its messages too
+func (m *ChatModel) redactMessages(ns []int) tea.Cmd {
+	idx := m.copyable()
+	for _, n := range ns {
+		if n < 1 || n > len(idx) {
+			m.addMessage("system", i18n.T("chat.no_redact", n))
+			return nil
+		}
+	}
+
+	var ids []string
+	for _, n := range ns {
+		msg := &m.messages[idx[n-1]]
+		if b, ok := m.toolBlocks[msg.ToolCall]; ok {
+			ids = append(ids, msg.ToolCall.ID)
+			b.args, b.output = ai.Redacted, ai.Redacted
+		} else {
+			ids = append(ids, msg.ID)
+		}
+		msg.Content = ai.Redacted
+	}
+
+	m.addMessage("system", i18n.T("chat.redacted", numberRange(ns)))
+
+	return func() tea.Msg { m.out <- EventRedact(ids); return nil }
+}
+
+// numberRange shows the message numbers as n or n-m
+func numberRange(ns []int) string {
+	if len(ns) == 1 {
+		return fmt.Sprint(ns[0])
+	}
+	return fmt.Sprintf("%d-%d", ns[0], ns[len(ns)-1])
+}
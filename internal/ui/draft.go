@@ -0,0 +1,55 @@
+package ui
+
+import (
+	"log"
+	"os"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// draftSaveInterval is how often the prompt is saved while it is being typed
+const draftSaveInterval = time.Second
+
+// eventSaveDraft is sent to save the prompt if it has changed
+type eventSaveDraft struct{}
+
+func saveDraftLater() tea.Cmd {
+	return tea.Tick(draftSaveInterval, func(time.Time) tea.Msg { return eventSaveDraft{} })
+}
+
+// SetDraftFile sets the file the unsent prompt is kept in, so it isn't lost
+// if clai is quit or crashes.  A draft left in it is put back in the prompt.
+func (m *ChatModel) SetDraftFile(fn string) {
+	m.draftFile = fn
+
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Println("[ui] failed to read the draft:", err)
+		}
+		return
+	}
+
+	m.draft = string(data)
+	m.prompt.SetValue(m.draft)
+}
+
+// saveDraft writes the prompt to the draft file if it has changed, an empty
+// prompt removes it
+func (m *ChatModel) saveDraft() {
+	if m.draftFile == "" || m.prompt.Value() == m.draft {
+		return
+	}
+	m.draft = m.prompt.Value()
+
+	var err error
+	if m.draft == "" {
+		err = os.Remove(m.draftFile)
+	} else {
+		err = os.WriteFile(m.draftFile, []byte(m.draft), 0600)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		log.Println("[ui] failed to save the draft:", err)
+	}
+}
@@ -0,0 +1,29 @@
+package ui
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDraft(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "draft")
+	assert.NoError(t, os.WriteFile(fn, []byte("half a"), 0600))
+
+	m := NewChatModel(context.Background(), config.Default())
+	m.SetDraftFile(fn)
+	assert.Equal(t, "half a", m.prompt.Value())
+
+	m.prompt.InsertString(" thought")
+	m.saveDraft()
+	data, _ := os.ReadFile(fn)
+	assert.Equal(t, "half a thought", string(data))
+
+	m.prompt.Reset()
+	m.saveDraft()
+	assert.NoFileExists(t, fn)
+}
@@ -0,0 +1,90 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/commands"
+	"github.com/penguinpowernz/clai/internal/i18n"
+)
+
+// eventEdited is sent when the editor opened by editText exits
+type eventEdited struct {
+	edit *commands.Edit
+	text string
+	err  error
+}
+
+// editText opens the text of the edit in the configured editor, the UI is
+// suspended until it exits
+func (m ChatModel) editText(edit *commands.Edit) tea.Cmd {
+	f, err := os.CreateTemp("", "clai-*.md")
+	if err != nil {
+		return func() tea.Msg { return eventEdited{edit: edit, err: err} }
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(edit.Text); err != nil {
+		os.Remove(f.Name())
+		return func() tea.Msg { return eventEdited{edit: edit, err: err} }
+	}
+
+	cmd := m.editorCommand(f.Name())
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		defer os.Remove(f.Name())
+		if err != nil {
+			return eventEdited{edit: edit, err: fmt.Errorf("%s: %w", cmd.Args[0], err)}
+		}
+
+		data, err := os.ReadFile(f.Name())
+		return eventEdited{edit: edit, text: string(data), err: err}
+	})
+}
+
+// editorCommand is the configured editor opening the file
+func (m ChatModel) editorCommand(fn string) *exec.Cmd {
+	args := strings.Fields(m.cfg.Editor)
+	if len(args) == 0 {
+		args = []string{"vim"}
+	}
+	return exec.Command(args[0], append(args[1:], fn)...)
+}
+
+// eventConfigEdited is sent when the editor opened by editConfig exits
+type eventConfigEdited struct{ err error }
+
+// editConfig opens the config file in the editor, the changes are used the
+// next time clai starts
+func (m ChatModel) editConfig() tea.Cmd {
+	cmd := m.editorCommand(config.File())
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		if err != nil {
+			err = fmt.Errorf("%s: %w", cmd.Args[0], err)
+		}
+		return eventConfigEdited{err: err}
+	})
+}
+
+func (m *ChatModel) onConfigEdited(ev eventConfigEdited) {
+	if ev.err != nil {
+		m.addMessage("system", i18n.T("chat.edit_failed", ev.err))
+		return
+	}
+	m.addMessage("system", i18n.T("chat.config_edited", config.File()))
+}
+
+// onEdited passes the edited text back to the command that asked for it
+func (m *ChatModel) onEdited(ev eventEdited) {
+	switch {
+	case ev.err != nil:
+		m.addMessage("system", i18n.T("chat.edit_failed", ev.err))
+	case ev.text == ev.edit.Text:
+		m.addMessage("system", i18n.T("chat.edit_unchanged"))
+	default:
+		m.addMessage("slashcmd", plain(ev.edit.Done(ev.text)))
+	}
+}
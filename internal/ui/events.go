@@ -1,8 +1,59 @@
 package ui
 
 import (
+	"time"
+
 	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/artifacts"
 	"github.com/penguinpowernz/clai/internal/commands"
+	"github.com/penguinpowernz/clai/internal/tools"
+)
+
+// EventBuffer is the size of the channels between the session and the UI.
+// Streamed text is merged while it waits (see chat.Stream) so the buffer only
+// has to soak up bursts of events, like the end of a stream followed by the
+// turn being done, without the sender waiting on a redraw.
+const EventBuffer = 64
+
+// EventTitle is the title and summary the session was given after the first
+// few exchanges
+type EventTitle struct {
+	Title   string
+	Summary string
+}
+
+// EventBudget is how much of the budget has been spent, sent after each
+// response when there is a budget
+type EventBudget struct {
+	Kind     string // "session" or "daily"
+	Spent    float64
+	Limit    float64
+	Warning  bool // most of it has been spent
+	Exceeded bool // paid requests are held back until the user approves them
+	Held     bool // a request was just held back
+}
+
+// EventUsage is the tokens the last response used and the total for the
+// session, as the provider counted them, sent after each response
+type EventUsage struct {
+	Last  ai.Usage
+	Total ai.Usage
+	Cost  float64 // USD spent by the session so far, including background requests
+}
+
+// EventHealth is how the provider is doing, it is checked when the session
+// starts
+type EventHealth struct {
+	State string // one of the Health* states
+	Err   error  // why the provider is down
+}
+
+const (
+	HealthChecking = "checking"
+	HealthWarming  = "warming" // the model is being loaded
+	HealthReady    = "ready"
+	HealthDown     = "down"
+	HealthNoModel  = "no_model" // the server is up but doesn't have the model
 )
 
 type EventSlashCommand commands.Result
@@ -10,21 +61,105 @@ type EventExit struct{}
 type EventClear struct{}
 type EventCancelStream struct{}
 type EventStreamCancelled struct{}
+type EventStreamStalled time.Duration // the provider went quiet for this long and the stream was stopped
+type EventStreamBanned string         // the response matched this banned_output pattern and was stopped
+type EventRetry struct{}
+type EventContinue struct{} // the next stream carries on from the last response
+type EventSelectVariant int // use another of the responses made by /retry
+type EventWorkingDir string
 type EventStreamStarted string
 type EventStreamThink string
 type EventStreamEnded string
 type EventStreamChunk string
 type EventToolCall ai.ToolCall
+type EventToolDetails tools.Details // what the next EventToolCall will do
+type EventToolQueue []ToolQueueItem // the tool calls from a response, sent when there is more than one
 type EventPermitToolUse ai.ToolCall
 type EventPermitToolUseThisSession ai.ToolCall
 type EventCancelToolUse ai.ToolCall
+type EventRedact []string // IDs of the messages to mask in the session, or of the tool calls
 type EventSystemMsg string
 type EventUserPrompt string
+type EventInjectPrompt string // a prompt sent by something other than the user, e.g. the file watcher
 type EventStreamErr error
+type EventFollowUps []string // suggested prompts the user might send next
+type EventTurnDone struct{}  // the AI has finished responding and is waiting for the user
 type EventAssistantMessage string
 type EventRunningTool ai.ToolCall
-type EventRunningToolDone string
+type EventRunningToolDone struct {
+	Failed bool
+	Code   tools.ErrorCode // why it failed, when that is known
+}
 type EventToolOutput string
+type EventArtifacts []artifacts.Artifact // files saved from the output of a tool or the model
+type EventToolProgress string            // output of the running tool, sent as it comes in
 type EventListDone struct{ title, option string }
-type EventModelSelection []string
+type EventModelSelection []ModelOption
 type EventModelSelected string
+type EventCycleModel struct{}      // switch to the next of the recently used models
+type EventModelChanged string      // the model was switched with EventCycleModel
+type EventComplete string          // complete the slash command being typed
+type EventAttached string          // the UI was attached to the session with this ID in the daemon
+type EventPeerPrompt string        // a prompt sent by another terminal attached to the session
+type EventPeerAnswered ai.ToolCall // another terminal attached to the session answered the request to run the tool
+type EventAskTakeover struct{}     // ask the terminal driving the session to hand it over
+type EventTakeoverAsked string     // who asked to drive the session, empty when they gave up asking
+type EventTakeoverAnswer bool      // whether the driver handed the session over
+type EventSwitchBranch string      // carry on the conversation in this branch of the session
+type EventCompleteSymbol string    // complete the name of the function, type or variable being typed
+type EventSuggestFiles string      // find the files the prompt looks to be about, before it is sent
+
+// EventRole says whether the UI drives the session it shares with other
+// terminals, or only watches while someone else drives it
+type EventRole struct {
+	Driving bool
+	Driver  string // who is driving
+}
+
+// EventBranchSwitched is sent when the conversation carries on in another
+// branch, the transcript is what was shown when it was last used
+type EventBranchSwitched struct {
+	ID         string
+	Transcript []ai.Message
+}
+
+// EventMessageID gives the ID the session gave to the last prompt or
+// response, so the UI can refer to it
+type EventMessageID struct {
+	Role string
+	ID   string
+}
+
+// EventToolState changes when a tool can be used, from the /tools list
+type EventToolState struct {
+	Name  string
+	State string // one of commands.ToolStates
+}
+
+// EventReconnecting is sent when the provider can't be reached, the prompt
+// is sent again after Delay
+type EventReconnecting struct {
+	Attempt int
+	Delay   time.Duration
+	Err     error
+}
+
+// EventCompletions are the lines that the slash command in Line could be
+// completed to
+type EventCompletions struct {
+	Line    string
+	Options []string
+}
+
+// EventFileSuggestions are the files of the project the prompt looks to be
+// about, that the user can add to the context before it is sent
+type EventFileSuggestions struct {
+	Prompt string
+	Files  []string
+}
+
+// EventSuggestionsAnswered are the suggested files the user wants added to
+// the context, and the ones they don't so they aren't suggested again
+type EventSuggestionsAnswered struct {
+	Added, Declined []string
+}
@@ -8,6 +8,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/penguinpowernz/clai/internal/ai"
 	"github.com/penguinpowernz/clai/internal/commands"
+	"github.com/penguinpowernz/clai/internal/i18n"
 )
 
 func (m *ChatModel) onSystemMessage(msg string) {
@@ -20,6 +21,10 @@ func (m *ChatModel) onStreamStarted() {
 	log.Println("[ui] STREAM STARTED")
 	m.typing = false
 	m.currentStream.Reset()
+	m.generated = 0
+	m.health = EventHealth{State: HealthReady} // the provider is answering
+	m.toolQueue = nil
+	m.reconnecting = nil
 
 	m.thinking = true
 	m.addMessage("thinking", m.currentStream.String())
@@ -27,27 +32,16 @@ func (m *ChatModel) onStreamStarted() {
 }
 
 func (m *ChatModel) onStreamThink(chunk string) {
-	if chunk == "</think>" || chunk == "<think>" {
-		return
-	}
-
+	m.generated += len(chunk)
 	m.updateMessage("thinking", chunk)
 }
 
 func (m *ChatModel) onStreamChunk(chunk string) {
-	if chunk == "<think>" {
-		m.inThinkBlock = true
-		m.onStreamThink(chunk)
-		return
-	}
-
-	if m.inThinkBlock {
-		m.onStreamThink(chunk)
-		if chunk == "</think>" {
-			m.inThinkBlock = false
-			return
-		}
-		return
+	m.generated += len(chunk)
+	if m.thinking && m.continuing {
+		m.resumeResponse()
+		m.thinking = false
+		m.typing = true
 	}
 
 	if m.thinking {
@@ -65,12 +59,16 @@ func (m *ChatModel) onStreamEnded(finalContent string) {
 	m.typing = false
 	m.thinking = false
 
-	finalContent = stripThinkBlock(finalContent)
+	finalContent = m.streamPrefix + stripThinkBlock(finalContent)
+	m.streamPrefix = ""
+	m.continuing = false
 
 	// Finalize the streaming message
 	if len(m.messages) > 0 && m.messages[len(m.messages)-1].Role == "assistant-streaming" {
 		m.messages[len(m.messages)-1].Role = "assistant"
 		m.messages[len(m.messages)-1].Content = finalContent
+		m.messages[len(m.messages)-1].Model = m.cfg.Model
+		m.messages[len(m.messages)-1].Tokens = ai.EstimateTokens(finalContent)
 	}
 
 	// sometimes the agent will put the tool call inside the chat
@@ -87,6 +85,21 @@ func (m *ChatModel) onStreamEnded(finalContent string) {
 	log.Println("[ui] we ended! final was ", finalContent)
 }
 
+// onMessageID gives the last prompt or response the ID the session gave it,
+// a response that was continued keeps the one it has
+func (m *ChatModel) onMessageID(ev EventMessageID) {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].Role != ev.Role {
+			continue
+		}
+		if m.messages[i].ID == "" {
+			m.messages[i].ID = ev.ID
+			m.saveTranscript()
+		}
+		return
+	}
+}
+
 func (m *ChatModel) onAssistantMessage(msg string) {
 	// Add assistant message to chat messages
 	m.addMessage("assistant", msg)
@@ -96,7 +109,7 @@ func (m *ChatModel) onAssistantMessage(msg string) {
 func (m ChatModel) Init() tea.Cmd {
 	// No need to manually set system message handler anymore
 	m.viewport.SetContent(m.renderMessages())
-	return textinput.Blink
+	return tea.Batch(textinput.Blink, saveDraftLater())
 }
 
 func listen(m ChatModel) tea.Cmd {
@@ -106,8 +119,7 @@ func listen(m ChatModel) tea.Cmd {
 }
 
 func (m ChatModel) handleToolCallResponse() (tea.Model, tea.Cmd) {
-	selectedOption := m.toolPermissionOptions[m.selectedOption]
-	switch selectedOption {
+	switch m.selectedOption {
 	case optAllowToolThisTime:
 		log.Println("[ui] allowing tool use for this time")
 		m.out <- EventPermitToolUse(*m.pendingToolCall)
@@ -134,6 +146,7 @@ func (m ChatModel) handleToolCallResponse() (tea.Model, tea.Cmd) {
 
 	// Reset tool call mode and restore textarea focus
 	m.pendingToolCall = nil
+	m.toolDetails = nil
 	m.selectedOption = 0
 	m.prompt.Focus()
 
@@ -147,22 +160,65 @@ func (m ChatModel) handleSubmit() (tea.Model, tea.Cmd) {
 	}
 
 	// Regular message sending (only when NOT in tool permission mode)
-	if m.typing || m.thinking || m.inThinkBlock {
+	if m.typing || m.thinking {
 		return m, nil
 	}
 
-	userMsg := strings.TrimSpace(m.prompt.Value())
+	userMsg := withAttachments(strings.TrimSpace(m.prompt.Value()), m.attachments)
 	if userMsg == "" {
 		return m, nil
 	}
 
-	// Add user message
-	m.addMessage("user", userMsg)
+	// the prompt is kept until the user says what to do with the secret
+	if m.checkSecrets(userMsg) {
+		return m, nil
+	}
+
+	// and while the files it looks to be about are suggested
+	if held, cmd := m.suggestFiles(userMsg); held {
+		return m, cmd
+	}
 
 	// Clear textarea
 	m.prompt.Reset()
+	m.attachments = nil
+
+	return m.submit(userMsg)
+}
+
+// onInjectPrompt sends a prompt that didn't come from the textarea, it is
+// dropped if the AI is busy so that it doesn't interrupt the current turn
+func (m ChatModel) onInjectPrompt(userMsg string) (tea.Model, tea.Cmd) {
+	if m.typing || m.thinking || m.runningTool || m.pendingToolCall != nil || m.pendingSecret != nil || m.pendingSuggest != nil {
+		m.addMessage("system", i18n.T("chat.skipped_busy", userMsg))
+		return m, listen(m)
+	}
+
+	// held like a typed prompt until the user says what to do with the secret
+	if m.checkInjectedSecrets(userMsg) {
+		return m, listen(m)
+	}
+
+	return m.submit(userMsg)
+}
 
+func (m ChatModel) submit(userMsg string) (tea.Model, tea.Cmd) {
+	m.followUps = nil
+	m.lastErr = nil
+	m.saveDraft()
+
+	// Add user message
+	m.addMessage("user", userMsg)
+
+	sent := userMsg
 	if userMsg[0] != '/' {
+		// the transcript keeps the references, the AI gets what they refer to
+		sent = withMessageRefs(userMsg, m.messageText)
+
+		// a new prompt accepts the current response
+		m.variants = nil
+		m.variant = 0
+
 		m.thinking = true
 	}
 
@@ -170,7 +226,7 @@ func (m ChatModel) handleSubmit() (tea.Model, tea.Cmd) {
 
 	return m, tea.Batch(
 		m.spinner.Tick,
-		func() tea.Msg { m.out <- EventUserPrompt(userMsg); return nil },
+		func() tea.Msg { m.out <- EventUserPrompt(sent); return nil },
 		listen(m),
 	)
 }
@@ -179,10 +235,53 @@ func (m ChatModel) handleSlashCommand(ev EventSlashCommand) (tea.Model, tea.Cmd)
 	res := commands.Result(ev)
 
 	if res.ShouldExit {
+		m.saveDraft()
 		return m, tea.Quit
 	}
 
-	m.addMessage("slashcmd", res.Message)
+	// the session doesn't know what is in the transcript so the UI does the copy
+	if res.Copy != nil {
+		m.copyMessage(*res.Copy)
+		return m, listen(m)
+	}
+
+	if res.Redact != nil {
+		return m, tea.Batch(m.redactMessages(res.Redact), listen(m))
+	}
+
+	if res.Edit != nil {
+		return m, tea.Batch(m.editText(res.Edit), listen(m))
+	}
+
+	if res.Preview != nil {
+		return m, tea.Batch(m.previewArtifact(*res.Preview), listen(m))
+	}
+
+	if res.Tools != nil {
+		m.currList = NewToolPicker(i18n.T("list.tools"), res.Tools)
+		return m, listen(m)
+	}
+
+	if res.Branches != nil {
+		m.currList = NewBranchPicker(i18n.T("list.branches"), res.Branches)
+		return m, listen(m)
+	}
+
+	if res.SwitchBranch != "" {
+		return m, tea.Batch(func() tea.Msg { m.out <- EventSwitchBranch(res.SwitchBranch); return nil }, listen(m))
+	}
+
+	if res.ToggleMultiline {
+		m.prompt.SetMultiline(!m.prompt.Multiline())
+		if m.prompt.Multiline() {
+			m.addMessage("system", i18n.T("chat.multiline_on"))
+		} else {
+			m.addMessage("system", i18n.T("chat.multiline_off"))
+		}
+		return m, listen(m)
+	}
+
+	m.addMessage("slashcmd", plain(res.Message))
 
 	return m, listen(m)
 }
@@ -191,10 +290,113 @@ func (m *ChatModel) onStreamCancelled() {
 	log.Println("[ui] STREAM CANCELLED")
 	m.typing = false
 	m.thinking = false
-	m.inThinkBlock = false
+
+	// keep the partial response, but mark it as interrupted
+	if n := len(m.messages); n > 0 && strings.HasPrefix(m.messages[n-1].Role, "assistant") {
+		m.messages[n-1].Role = "assistant"
+		m.messages[n-1].Interrupted = true
+	}
+
+	m.currentStream.Reset()
+	m.viewport.SetContent(m.renderMessages())
+}
+
+func (m *ChatModel) onStreamErr(err error) {
+	m.typing = false
+	m.thinking = false
+	m.reconnecting = nil
+	m.lastErr = err
+	m.addMessage("system", i18n.T("chat.error_message", err))
+}
+
+// resumeResponse moves the last response to the end of the transcript so the
+// continuation of it can be streamed onto it
+func (m *ChatModel) resumeResponse() {
+	m.continuing = false
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].Role != "assistant" {
+			continue
+		}
+
+		msg := m.messages[i]
+		msg.Role = "assistant-streaming"
+		msg.Interrupted = false
+		m.messages = append(append(m.messages[:i:i], m.messages[i+1:]...), msg)
+
+		m.streamPrefix = msg.Content
+		m.currentStream.Reset()
+		m.currentStream.WriteString(msg.Content)
+		return
+	}
+}
+
+// onRetry removes the last response from the transcript, as the session has
+// discarded it from the context
+func (m *ChatModel) onRetry() {
+	kept, response := m.splitResponse()
+
+	// keep the response as a variant so it can be flipped back to
+	if len(m.variants) == 0 {
+		m.variants = [][]ai.Message{response}
+	} else {
+		m.variants[m.variant] = response
+	}
+	m.variants = append(m.variants, nil)
+	m.variant = len(m.variants) - 1
+
+	m.messages = kept
+	m.viewport.SetContent(m.renderMessages())
+}
+
+// splitResponse splits the messages from the AI responding to the last
+// prompt from the rest of the transcript
+func (m *ChatModel) splitResponse() (kept, response []ai.Message) {
+	last := -1
+	for i, msg := range m.messages {
+		if msg.Role == "user" && !strings.HasPrefix(msg.Content, "/") {
+			last = i
+		}
+	}
+
+	kept = append(kept, m.messages[:last+1]...)
+	for _, msg := range m.messages[last+1:] {
+		switch msg.Role {
+		case "assistant", "assistant-streaming", "thinking", "tool":
+			response = append(response, msg)
+			continue
+		}
+		kept = append(kept, msg)
+	}
+
+	return kept, response
+}
+
+// switchVariant shows another of the responses made by /retry, the session
+// is told to use it as the response in the context
+func (m *ChatModel) switchVariant(delta int) (tea.Model, tea.Cmd) {
+	i := m.variant + delta
+	if i < 0 || i >= len(m.variants) {
+		return m, nil
+	}
+
+	kept, response := m.splitResponse()
+	m.variants[m.variant] = response
+	m.messages = append(kept, m.variants[i]...)
+	m.variant = i
+	m.viewport.SetContent(m.renderMessages())
+
+	return m, func() tea.Msg { m.out <- EventSelectVariant(i); return nil }
 }
 
 func (m *ChatModel) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.takeoverFrom != "" {
+		return m.handleTakeoverKey(msg)
+	}
+
+	if m.readOnly && !m.selecting {
+		return m.handleReadOnlyKey(msg)
+	}
+
 	// Handle arrow key navigation in tool permission mode
 	if m.pendingToolCall != nil {
 		switch msg.Type {
@@ -209,9 +411,49 @@ func (m *ChatModel) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			}
 			return m, nil
 		}
+		if msg.String() == "d" {
+			m.showToolDetails = !m.showToolDetails
+			return m, nil
+		}
+	}
+
+	if m.pendingSecret != nil {
+		return m.handleSecretKey(msg)
+	}
+
+	if m.pendingSuggest != nil {
+		return m.handleSuggestKey(msg)
+	}
+
+	if m.selecting {
+		return m.handleSelectKey(msg)
 	}
 
 	switch msg.String() {
+	case "ctrl+s":
+		return m.startSelecting()
+
+	case "ctrl+t":
+		return m, func() tea.Msg { m.out <- EventCycleModel{}; return nil }
+
+	case "ctrl+@":
+		return m.completeSymbol()
+
+	case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+		// pick a follow-up, unless the user is typing a prompt (the key has
+		// already gone into the prompt by now)
+		if n := int(msg.String()[0] - '0'); n <= len(m.followUps) && m.prompt.Value() == msg.String() {
+			m.prompt.Reset()
+			return m.submit(m.followUps[n-1])
+		}
+
+	case "r", "m", "c":
+		// act on the error banner, unless the user is typing a prompt
+		if m.lastErr != nil && m.prompt.Value() == msg.String() {
+			m.prompt.Reset()
+			return m.errorAction(msg.String())
+		}
+
 	case "q", "d", "u", "j", "k":
 		// Ignore these keys
 		return m, nil
@@ -220,9 +462,10 @@ func (m *ChatModel) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.Type {
 	case tea.KeyEsc:
 		log.Println("[ui] Cancel pushed...")
+		m.lastErr = nil
 
 		return m, func() tea.Msg {
-			if m.thinking || m.inThinkBlock || m.typing {
+			if m.thinking || m.typing {
 				log.Println("[ui] Canceling stream...")
 				m.out <- EventCancelStream{}
 				log.Println("[ui] Cancelled stream...")
@@ -231,13 +474,36 @@ func (m *ChatModel) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 
 	case tea.KeyCtrlC:
+		m.saveDraft()
 		return m, tea.Quit
 
+	case tea.KeyTab:
+		return m.complete()
+
+	case tea.KeyBackspace:
+		// the key has already gone into the prompt, so it was empty
+		if len(m.attachments) > 0 && m.prompt.Value() == "" {
+			m.attachments = m.attachments[:len(m.attachments)-1]
+		}
+
+	case tea.KeyLeft, tea.KeyRight:
+		// flip between the responses from /retry, unless the user is typing
+		if len(m.variants) > 1 && m.prompt.Value() == "" && !m.typing && !m.thinking && !m.runningTool {
+			if msg.Type == tea.KeyLeft {
+				return m.switchVariant(-1)
+			}
+			return m.switchVariant(1)
+		}
+
 	case tea.KeyEnter:
 		if m.pendingToolCall != nil {
 			return m.handleToolCallResponse()
 		}
 
+		// the prompt has already started a new line
+		if msg.Alt || m.prompt.Multiline() {
+			return m, nil
+		}
 		return m.handleSubmit()
 
 	case tea.KeyCtrlD:
@@ -249,4 +515,17 @@ func (m *ChatModel) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 func (m *ChatModel) onClear() {
 	m.messages = make([]ai.Message, 0)
+	m.liveMsg = -1
+}
+
+// onBranchSwitched shows the transcript of the branch the conversation
+// carries on in
+func (m *ChatModel) onBranchSwitched(ev EventBranchSwitched) {
+	m.onClear()
+	m.followUps = nil
+	m.lastErr = nil
+	m.variants = nil
+	m.variant = 0
+	m.SetTranscript(ev.Transcript)
+	m.addMessage("system", i18n.T("chat.branch_switched", ev.ID))
 }
@@ -0,0 +1,124 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/penguinpowernz/clai/internal/artifacts"
+)
+
+// Headless is a UI for running the session without a terminal, the response
+// is written to the writer and tools that need permission are denied as
+// there is nobody to ask
+type Headless struct {
+	w, notes io.Writer
+	in, out  chan any
+}
+
+// NewHeadless creates a headless UI that writes the responses to w, and
+// anything that isn't part of the response (tool use, system messages) to notes
+func NewHeadless(w, notes io.Writer) *Headless {
+	return &Headless{
+		w:     w,
+		notes: notes,
+		out:   make(chan any, EventBuffer),
+	}
+}
+
+func (h *Headless) AddObserver(observer UIObserver) {
+	observer.Observe(h.out)
+}
+
+func (h *Headless) Observe(events chan any) {
+	h.in = events
+}
+
+// Run sends the prompt to the session and writes out the response, it returns
+// when the AI has finished its turn
+func (h *Headless) Run(ctx context.Context, prompt string) error {
+	select {
+	case h.out <- EventUserPrompt(prompt):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	var err error
+	for {
+		var ev any
+		select {
+		case ev = <-h.in:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		switch msg := ev.(type) {
+		case EventStreamChunk:
+			fmt.Fprint(h.w, string(msg))
+
+		case EventStreamEnded:
+			fmt.Fprintln(h.w)
+
+		case EventSystemMsg:
+			fmt.Fprintln(h.notes, string(msg))
+
+		case EventSlashCommand:
+			fmt.Fprintln(h.w, plain(msg.Message))
+			return nil // commands don't start a turn
+
+		case EventToolCall:
+			log.Println("[headless] denying tool call:", msg.Name)
+			fmt.Fprintf(h.notes, "[denied tool call %s, add it to permitted_tools to allow it]\n", msg.Name)
+			h.out <- EventCancelToolUse(msg)
+
+		case EventRunningTool:
+			fmt.Fprintf(h.notes, "[running tool %s]\n", msg.Name)
+
+		case EventArtifacts:
+			h.artifacts(msg)
+
+		case EventReconnecting:
+			fmt.Fprintf(h.notes, "[can't reach the provider, trying again in %s: %v]\n", msg.Delay, msg.Err)
+
+		case EventStreamErr:
+			err = msg
+
+		case EventBudget:
+			if msg.Held {
+				err = fmt.Errorf("the %s budget of $%.2f has been spent", msg.Kind, msg.Limit)
+			}
+
+		case EventStreamBanned:
+			fmt.Fprintf(h.notes, "[the response was stopped as it matched the banned_output pattern %s]\n", string(msg))
+
+		case EventStreamStalled:
+			err = fmt.Errorf("the AI sent nothing for %s so the response was stopped", time.Duration(msg))
+
+		case EventTurnDone:
+			return err
+		}
+	}
+}
+
+// artifacts tells where the files were saved, and shows the images when the
+// notes are going to a terminal that can draw them
+func (h *Headless) artifacts(found []artifacts.Artifact) {
+	protocol := artifacts.Protocol()
+	f, ok := h.notes.(*os.File)
+	if info, err := f.Stat(); !ok || err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		protocol = ""
+	}
+
+	for _, a := range found {
+		fmt.Fprintf(h.notes, "[saved %s to %s]\n", a.MIME, a.Path)
+		if a.IsImage() && protocol != "" {
+			if err := artifacts.Preview(h.notes, a, protocol); err != nil {
+				log.Println("[headless] failed to preview", a.Path+":", err)
+			}
+			fmt.Fprintln(h.notes)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package ui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/i18n"
+)
+
+// SetTranscript shows the messages of a session that was started before the
+// UI was, like one running in the daemon
+func (m *ChatModel) SetTranscript(messages []ai.Message) {
+	m.messages = append(m.messages[:0], messages...)
+	m.redrawMessages()
+}
+
+// onPeerPrompt shows a prompt that another terminal attached to the session
+// sent, as if it was typed in this one
+func (m ChatModel) onPeerPrompt(prompt string) (tea.Model, tea.Cmd) {
+	m.followUps = nil
+	m.lastErr = nil
+	m.addMessage("user", prompt)
+
+	if !strings.HasPrefix(prompt, "/") {
+		m.variants = nil
+		m.variant = 0
+		m.thinking = true
+	}
+	m.currentStream.Reset()
+
+	return m, tea.Batch(m.spinner.Tick, listen(m))
+}
+
+// onPeerAnswered closes the request to run the tool once another terminal
+// attached to the session has answered it
+func (m *ChatModel) onPeerAnswered(call EventPeerAnswered) {
+	if m.pendingToolCall == nil || m.pendingToolCall.ID != call.ID {
+		return
+	}
+
+	m.pendingToolCall = nil
+	m.toolDetails = nil
+	m.selectedOption = 0
+	m.prompt.Focus()
+}
+
+// onRole switches between driving the session and watching another terminal
+// attached to it drive it
+func (m *ChatModel) onRole(role EventRole) {
+	wasReadOnly := m.readOnly
+	m.readOnly = !role.Driving
+	m.driver = role.Driver
+	m.takeoverFrom = ""
+
+	if m.readOnly {
+		// what was being typed is kept for when the session is handed back
+		m.pendingSecret = nil
+		m.pendingSuggest = nil
+		m.prompt.Blur()
+		m.addMessage("system", i18n.T("chat.watching", role.Driver))
+		return
+	}
+
+	if m.pendingToolCall == nil {
+		m.prompt.Focus()
+	}
+	if wasReadOnly {
+		m.addMessage("system", i18n.T("chat.driving"))
+	}
+}
+
+// onTakeoverAsked asks the user to hand the session over, or stops asking
+// when the one who asked went away
+func (m *ChatModel) onTakeoverAsked(from string) {
+	m.takeoverFrom = from
+	if from == "" {
+		if m.pendingToolCall == nil && m.pendingSecret == nil && m.pendingSuggest == nil {
+			m.prompt.Focus()
+		}
+		return
+	}
+	m.prompt.Blur()
+}
+
+// canType is whether what is typed goes into the prompt
+func (m ChatModel) canType() bool {
+	return !m.readOnly && m.takeoverFrom == ""
+}
+
+// handleReadOnlyKey asks the driver to hand the session over
+func (m *ChatModel) handleReadOnlyKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case msg.Type == tea.KeyEnter:
+		m.addMessage("system", i18n.T("chat.takeover_asked", m.driver))
+		return m, func() tea.Msg { m.out <- EventAskTakeover{}; return nil }
+	case msg.String() == "ctrl+s":
+		return m.startSelecting()
+	case msg.Type == tea.KeyCtrlC:
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// handleTakeoverKey answers the request to drive the session
+func (m *ChatModel) handleTakeoverKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var allow bool
+	switch {
+	case msg.String() == "y":
+		allow = true
+	case msg.String() == "n" || msg.Type == tea.KeyEsc:
+	case msg.Type == tea.KeyCtrlC:
+		m.saveDraft()
+		return m, tea.Quit
+	default:
+		return m, nil
+	}
+
+	m.onTakeoverAsked("")
+	return m, func() tea.Msg { m.out <- EventTakeoverAnswer(allow); return nil }
+}
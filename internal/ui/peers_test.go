@@ -0,0 +1,33 @@
+package ui
+
+import (
+	"context"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadOnly(t *testing.T) {
+	m := NewChatModel(context.Background(), config.Default())
+	m.onRole(EventRole{Driving: false, Driver: "alice"})
+	assert.False(t, m.canType())
+	assert.Contains(t, m.View(), "alice")
+
+	// ENTER asks to take over instead of sending a prompt
+	_, cmd := m.handleKeyPress(tea.KeyMsg{Type: tea.KeyEnter})
+	cmd()
+	assert.Equal(t, EventAskTakeover{}, <-m.out)
+
+	m.onRole(EventRole{Driving: true, Driver: "bob"})
+	assert.True(t, m.canType())
+
+	// the driver is asked before anything else
+	m.onTakeoverAsked("alice")
+	assert.False(t, m.canType())
+	_, cmd = m.handleKeyPress(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	cmd()
+	assert.Equal(t, EventTakeoverAnswer(true), <-m.out)
+	assert.True(t, m.canType())
+}
@@ -0,0 +1,231 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/penguinpowernz/clai/internal/i18n"
+)
+
+// ModelOption is a model that can be picked with /models, the fields other
+// than the name are empty when the provider doesn't say
+type ModelOption struct {
+	Name          string
+	ParameterSize string
+	Quantization  string
+	ContextWindow int
+	Tools         bool
+	Size          int64     // bytes on disk
+	Modified      time.Time // when it was last pulled or changed
+	Current       bool      // the model in use
+}
+
+// String describes the model on one line, for the accessible UI
+func (o ModelOption) String() string {
+	parts := []string{o.Name}
+	for _, p := range []string{o.ParameterSize, o.Quantization, diskSize(o.Size), contextSize(o.ContextWindow), modifiedDate(o.Modified)} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if o.Tools {
+		parts = append(parts, i18n.T("picker.tools"))
+	}
+	if o.Current {
+		parts = append(parts, i18n.T("picker.current"))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func contextSize(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%dk", n/1024)
+}
+
+func diskSize(n int64) string {
+	switch {
+	case n <= 0:
+		return ""
+	case n < 1<<30:
+		return fmt.Sprintf("%dMB", n>>20)
+	}
+	return fmt.Sprintf("%.1fGB", float64(n)/(1<<30))
+}
+
+func modifiedDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format("2006-01-02")
+}
+
+// maxPickerRows is how many models the picker shows at once
+const maxPickerRows = 15
+
+// ModelPicker is the list of models shown by /models, typing filters it
+type ModelPicker struct {
+	title    string
+	options  []ModelOption
+	filter   string
+	matches  []int // the options that match the filter, the best first
+	selected int   // index into matches
+}
+
+// NewModelPicker creates a picker with the current model selected
+func NewModelPicker(title string, options []ModelOption) *ModelPicker {
+	p := &ModelPicker{title: title, options: options}
+	p.match()
+	for i, idx := range p.matches {
+		if options[idx].Current {
+			p.selected = i
+		}
+	}
+	return p
+}
+
+func (p *ModelPicker) Init() tea.Cmd {
+	return nil
+}
+
+func (p *ModelPicker) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return p, nil
+	}
+
+	switch key.Type {
+	case tea.KeyUp:
+		if p.selected > 0 {
+			p.selected--
+		}
+	case tea.KeyDown:
+		if p.selected < len(p.matches)-1 {
+			p.selected++
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		p.filter += string(key.Runes)
+		p.match()
+	case tea.KeyBackspace:
+		if r := []rune(p.filter); len(r) > 0 {
+			p.filter = string(r[:len(r)-1])
+			p.match()
+		}
+	case tea.KeyEsc:
+		return p, func() tea.Msg { return EventListDone{p.title, ""} }
+	case tea.KeyEnter:
+		if len(p.matches) == 0 {
+			return p, nil
+		}
+		name := p.options[p.matches[p.selected]].Name
+		return p, func() tea.Msg { return EventListDone{p.title, name} }
+	}
+
+	return p, nil
+}
+
+// match finds the options that match the filter and selects the best one
+func (p *ModelPicker) match() {
+	type scored struct{ idx, score int }
+	var found []scored
+	for i, o := range p.options {
+		if score, ok := fuzzyScore(p.filter, o.Name); ok {
+			found = append(found, scored{i, score})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].score < found[j].score })
+
+	p.matches = p.matches[:0]
+	for _, f := range found {
+		p.matches = append(p.matches, f.idx)
+	}
+	p.selected = 0
+}
+
+// fuzzyScore checks if the letters of the query appear in order in s, the
+// score is lower the closer together they are and the earlier they start
+func fuzzyScore(query, s string) (int, bool) {
+	query, s = strings.ToLower(strings.TrimSpace(query)), strings.ToLower(s)
+	if query == "" {
+		return 0, true
+	}
+	if i := strings.Index(s, query); i >= 0 {
+		return i, true
+	}
+
+	score, last := 0, -1
+	rs := []rune(s)
+	for _, q := range query {
+		found := false
+		for i := last + 1; i < len(rs); i++ {
+			if rs[i] == q {
+				if last >= 0 {
+					score += i - last - 1
+				} else {
+					score += i
+				}
+				last, found = i, true
+				break
+			}
+		}
+		if !found {
+			return 0, false
+		}
+	}
+
+	// a match spread out over the name is worse than any exact substring
+	return len(rs) + score, true
+}
+
+func (p *ModelPicker) View() string {
+	var b strings.Builder
+	b.WriteString("\n" + p.title + ":\n\n")
+	b.WriteString(helpStyle.Render(i18n.T("picker.filter")) + " " + p.filter + cursorStyle.Render("▏") + "\n\n")
+
+	if len(p.matches) == 0 {
+		b.WriteString("  " + helpStyle.Render(i18n.T("picker.no_matches")) + "\n")
+		return b.String()
+	}
+
+	width := len(i18n.T("picker.model"))
+	for _, o := range p.options {
+		width = max(width, len(o.Name))
+	}
+	row := fmt.Sprintf("%%s %%-%ds  %%-8s %%-8s %%7s %%7s  %%-10s  %%s", width)
+
+	b.WriteString(helpStyle.Render(fmt.Sprintf(row, " ", i18n.T("picker.model"), i18n.T("picker.params"), i18n.T("picker.quant"), i18n.T("picker.size"), i18n.T("picker.context"), i18n.T("picker.modified"), i18n.T("picker.tools"))) + "\n")
+
+	// scroll so the selected model is always showing
+	start := max(0, min(p.selected-maxPickerRows/2, len(p.matches)-maxPickerRows))
+	end := min(len(p.matches), start+maxPickerRows)
+
+	for i := start; i < end; i++ {
+		o := p.options[p.matches[i]]
+
+		mark := " "
+		if o.Current {
+			mark = "*"
+		}
+		tools := ""
+		if o.Tools {
+			tools = "✓"
+		}
+
+		line := fmt.Sprintf(row, mark, o.Name, o.ParameterSize, o.Quantization, diskSize(o.Size), contextSize(o.ContextWindow), modifiedDate(o.Modified), tools)
+		if i == p.selected {
+			b.WriteString(assistantStyle.Render(">"+line) + "\n")
+			continue
+		}
+		b.WriteString(" " + line + "\n")
+	}
+
+	if end-start < len(p.matches) {
+		b.WriteString(helpStyle.Render(fmt.Sprintf("  %d/%d", p.selected+1, len(p.matches))) + "\n")
+	}
+
+	return b.String()
+}
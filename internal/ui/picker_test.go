@@ -0,0 +1,34 @@
+package ui
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelPicker(t *testing.T) {
+	p := NewModelPicker("models", []ModelOption{
+		{Name: "llama3.1:8b"},
+		{Name: "qwen2.5-coder:7b", Current: true},
+		{Name: "qwen3:8b"},
+	})
+	assert.Equal(t, 1, p.selected)
+
+	for _, r := range "qn" {
+		p.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	assert.Equal(t, []int{2, 1}, p.matches)
+
+	p.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+	p.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("en3")})
+	assert.Equal(t, []int{2}, p.matches)
+
+	_, cmd := p.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Equal(t, EventListDone{"models", "qwen3:8b"}, cmd())
+
+	p.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("zzz")})
+	assert.Empty(t, p.matches)
+	_, cmd = p.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Nil(t, cmd)
+}
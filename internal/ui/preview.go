@@ -0,0 +1,65 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/penguinpowernz/clai/internal/artifacts"
+	"github.com/penguinpowernz/clai/internal/i18n"
+)
+
+// eventPreviewed is sent when the preview opened by previewArtifact is closed
+type eventPreviewed struct{ err error }
+
+// previewCommand shows an image on the terminal while the UI is suspended, the
+// alt screen can't keep an image in it so it waits for ENTER
+type previewCommand struct {
+	artifact artifacts.Artifact
+	protocol string
+	stdin    io.Reader
+	stdout   io.Writer
+}
+
+func (c *previewCommand) SetStdin(r io.Reader)  { c.stdin = r }
+func (c *previewCommand) SetStdout(w io.Writer) { c.stdout = w }
+func (c *previewCommand) SetStderr(io.Writer)   {}
+
+func (c *previewCommand) Run() error {
+	fmt.Fprintf(c.stdout, "\033[2J\033[H%s\n\n", c.artifact.Path)
+	if err := artifacts.Preview(c.stdout, c.artifact, c.protocol); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "\n\n%s", i18n.T("preview.return"))
+	_, err := bufio.NewReader(c.stdin).ReadString('\n')
+	return err
+}
+
+// previewArtifact shows the image when the terminal can draw it, otherwise
+// the path is shown so it can be opened from there
+func (m *ChatModel) previewArtifact(a artifacts.Artifact) tea.Cmd {
+	protocol := artifacts.Protocol()
+	if protocol == "" {
+		m.addMessage("artifact", a.Path)
+		m.addMessage("system", i18n.T("preview.unsupported"))
+		return nil
+	}
+
+	return tea.Exec(&previewCommand{artifact: a, protocol: protocol}, func(err error) tea.Msg {
+		return eventPreviewed{err: err}
+	})
+}
+
+func (m *ChatModel) onPreviewed(ev eventPreviewed) {
+	if ev.err != nil {
+		m.addMessage("system", i18n.T("preview.failed", ev.err))
+	}
+}
+
+// onArtifacts shows the files saved from the output of a tool or the model
+func (m *ChatModel) onArtifacts(found []artifacts.Artifact) {
+	for _, a := range found {
+		m.addMessage("artifact", a.Path)
+	}
+}
@@ -0,0 +1,77 @@
+package ui
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// reMessageRef matches a reference to a message in the transcript by the
+// number it has in selection mode, #12 on its own is an issue mention
+var reMessageRef = regexp.MustCompile(`\bmsg#(\d+)\b`)
+
+// withMessageRefs adds the messages referenced in the prompt like msg#12 to
+// the end of it, so the AI sees exactly what they said.  message returns the
+// nth message, or false if there isn't one.
+func withMessageRefs(prompt string, message func(n int) (string, bool)) string {
+	var b strings.Builder
+	b.WriteString(prompt)
+
+	seen := map[int]bool{}
+	for _, sub := range reMessageRef.FindAllStringSubmatch(prompt, -1) {
+		n, _ := strconv.Atoi(sub[1])
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+
+		text, ok := message(n)
+		if !ok {
+			continue
+		}
+
+		fence := "```"
+		for strings.Contains(text, fence) {
+			fence += "`"
+		}
+		fmt.Fprintf(&b, "\n\nThis is msg#%d:\n%s\n%s\n%s", n, fence, text, fence)
+	}
+	return b.String()
+}
+
+// messageText returns the nth copyable message as it is referenced or quoted,
+// tool calls have their args and output
+func (m ChatModel) messageText(n int) (string, bool) {
+	idx := m.copyable()
+	if n < 1 || n > len(idx) {
+		return "", false
+	}
+
+	msg := m.messages[idx[n-1]]
+	if b, ok := m.toolBlocks[msg.ToolCall]; ok {
+		return fmt.Sprintf("%s %s\n%s", b.name, b.args, b.output), true
+	}
+	return fmt.Sprintf("%s: %s", msg.Role, stripThinkBlock(msg.Content)), true
+}
+
+// quoteMessage puts the nth message at the start of the prompt as a quote
+// and leaves selection mode, a long message is attached instead
+func (m *ChatModel) quoteMessage(n int) (tea.Model, tea.Cmd) {
+	m.selecting = false
+	m.viewport.SetContent(m.renderMessages())
+
+	text, ok := m.messageText(n)
+	if !ok {
+		return m, tea.EnableMouseCellMotion
+	}
+
+	if !m.attachPaste(text) {
+		quote := "> " + strings.ReplaceAll(strings.TrimSpace(text), "\n", "\n> ")
+		m.prompt.SetValue(quote + "\n\n" + m.prompt.Value())
+	}
+	m.prompt.Focus()
+	return m, tea.EnableMouseCellMotion
+}
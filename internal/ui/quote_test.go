@@ -0,0 +1,23 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithMessageRefs(t *testing.T) {
+	messages := []string{"user: hi", "assistant: ```go\nfunc main() {}\n```"}
+	message := func(n int) (string, bool) {
+		if n < 1 || n > len(messages) {
+			return "", false
+		}
+		return messages[n-1], true
+	}
+
+	assert.Equal(t, "fix #2", withMessageRefs("fix #2", message))
+	assert.Equal(t, "fix msg#9", withMessageRefs("fix msg#9", message))
+	assert.Equal(t,
+		"fix msg#2 and msg#2\n\nThis is msg#2:\n````\nassistant: ```go\nfunc main() {}\n```\n````",
+		withMessageRefs("fix msg#2 and msg#2", message))
+}
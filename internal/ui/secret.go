@@ -0,0 +1,97 @@
+package ui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/i18n"
+	"github.com/penguinpowernz/clai/internal/secrets"
+)
+
+// pendingSecret is a prompt that looks like it has a secret in it, held
+// until the user says what to do with it
+type pendingSecret struct {
+	msg      string
+	found    []secrets.Match
+	injected bool // it didn't come from the textarea, e.g. the editor or the file watcher
+}
+
+// checkSecrets holds the prompt if it looks like it has a secret in it,
+// returning false if it didn't so it can be sent
+func (m *ChatModel) checkSecrets(userMsg string) bool {
+	return m.holdSecrets(userMsg, false)
+}
+
+// checkInjectedSecrets is checkSecrets for a prompt that didn't come from
+// the textarea, the user's draft is left alone whatever they choose
+func (m *ChatModel) checkInjectedSecrets(userMsg string) bool {
+	return m.holdSecrets(userMsg, true)
+}
+
+func (m *ChatModel) holdSecrets(userMsg string, injected bool) bool {
+	if userMsg[0] == '/' {
+		return false
+	}
+
+	found := secrets.Find(userMsg)
+	if len(found) == 0 {
+		return false
+	}
+
+	m.pendingSecret = &pendingSecret{msg: userMsg, found: found, injected: injected}
+	m.prompt.Blur()
+	return true
+}
+
+// handleSecretKey redacts the secrets and sends the prompt, sends it as it
+// is, or gives it back to be edited
+func (m *ChatModel) handleSecretKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := m.pendingSecret
+
+	switch {
+	case msg.String() == "r":
+		return m.sendPendingSecret(secrets.Redact(p.msg, p.found, ai.Redacted))
+	case msg.String() == "s":
+		return m.sendPendingSecret(p.msg)
+	case msg.String() == "c" || msg.Type == tea.KeyEsc:
+		m.pendingSecret = nil
+		m.prompt.Focus()
+		// there is nowhere to give it back to, so it is dropped
+		if p.injected {
+			m.addMessage("system", i18n.T("chat.secret_dropped", p.msg))
+		}
+	case msg.Type == tea.KeyCtrlC:
+		m.saveDraft()
+		return m, tea.Quit
+	}
+
+	return m, nil
+}
+
+func (m *ChatModel) sendPendingSecret(userMsg string) (tea.Model, tea.Cmd) {
+	injected := m.pendingSecret.injected
+	m.pendingSecret = nil
+	if injected {
+		m.prompt.Focus()
+		return m.submit(userMsg)
+	}
+	if held, cmd := m.suggestFiles(userMsg); held {
+		return m, cmd
+	}
+	m.prompt.Reset()
+	m.prompt.Focus()
+	m.attachments = nil
+	return m.submit(userMsg)
+}
+
+// renderSecretWarning says what kinds of secret the prompt looks like it has
+func (m ChatModel) renderSecretWarning() string {
+	kinds := strings.Join(secrets.Kinds(m.pendingSecret.found), ", ")
+	warning := errorStyle.Render("⚠ "+i18n.T("chat.secret_found", kinds)) + "\n"
+	// the textarea has the user's draft, not the prompt being held
+	if m.pendingSecret.injected {
+		warning += m.pendingSecret.msg + "\n"
+	}
+	return warning
+}
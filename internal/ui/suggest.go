@@ -0,0 +1,98 @@
+package ui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/penguinpowernz/clai/internal/i18n"
+)
+
+// pendingSuggest is a prompt held while the session looks for the files it
+// looks to be about and the user says which of them to add
+type pendingSuggest struct {
+	msg      string
+	files    []string // the files left to ask about, nil until the session answers
+	added    []string
+	declined []string
+}
+
+// suggestFiles holds the prompt and asks the session for the files it looks
+// to be about, returning false if the suggest_files config doesn't ask so it
+// can be sent
+func (m *ChatModel) suggestFiles(userMsg string) (bool, tea.Cmd) {
+	if userMsg[0] == '/' || m.cfg == nil || m.cfg.SuggestFiles != "ask" {
+		return false, nil
+	}
+
+	m.pendingSuggest = &pendingSuggest{msg: userMsg}
+	m.prompt.Blur()
+	return true, func() tea.Msg { m.out <- EventSuggestFiles(userMsg); return nil }
+}
+
+// onFileSuggestions asks about the suggested files, or sends the prompt when
+// there aren't any
+func (m ChatModel) onFileSuggestions(ev EventFileSuggestions) (tea.Model, tea.Cmd) {
+	// the answer could be for another terminal attached to the session
+	if m.pendingSuggest == nil || m.pendingSuggest.msg != ev.Prompt || m.pendingSuggest.files != nil {
+		return m, listen(m)
+	}
+
+	if len(ev.Files) == 0 {
+		return m.sendPendingSuggest()
+	}
+	m.pendingSuggest.files = ev.Files
+	return m, listen(m)
+}
+
+// handleSuggestKey adds the file being asked about or leaves it out, Esc
+// leaves out the rest
+func (m ChatModel) handleSuggestKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := m.pendingSuggest
+	if msg.Type == tea.KeyCtrlC {
+		m.saveDraft()
+		return m, tea.Quit
+	}
+	if len(p.files) == 0 {
+		return m, nil
+	}
+
+	switch {
+	case msg.String() == "y":
+		p.added = append(p.added, p.files[0])
+		p.files = p.files[1:]
+	case msg.String() == "n":
+		p.declined = append(p.declined, p.files[0])
+		p.files = p.files[1:]
+	case msg.Type == tea.KeyEsc:
+		p.declined = append(p.declined, p.files...)
+		p.files = p.files[:0]
+	default:
+		return m, nil
+	}
+
+	if len(p.files) > 0 {
+		return m, nil
+	}
+	return m.sendPendingSuggest()
+}
+
+// sendPendingSuggest sends the prompt after the files the user chose are
+// added to the context
+func (m ChatModel) sendPendingSuggest() (tea.Model, tea.Cmd) {
+	p := m.pendingSuggest
+	m.pendingSuggest = nil
+	m.prompt.Reset()
+	m.prompt.Focus()
+	m.attachments = nil
+
+	model, cmd := m.submit(p.msg)
+	if len(p.added) == 0 && len(p.declined) == 0 {
+		return model, cmd
+	}
+
+	answer := EventSuggestionsAnswered{Added: p.added, Declined: p.declined}
+	return model, tea.Sequence(func() tea.Msg { m.out <- answer; return nil }, cmd)
+}
+
+// renderSuggestion asks about the next suggested file
+func (m ChatModel) renderSuggestion() string {
+	return i18n.T("chat.suggest_file", m.pendingSuggest.files[0]) + "\n"
+}
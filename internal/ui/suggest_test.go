@@ -0,0 +1,46 @@
+package ui
+
+import (
+	"context"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/penguinpowernz/clai/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuggestFiles(t *testing.T) {
+	m := NewChatModel(context.Background(), config.Default())
+	m.prompt.SetValue("why does the session hang?")
+
+	// the prompt is held until the session says what it looks to be about
+	model, cmd := m.handleSubmit()
+	cmd()
+	assert.Equal(t, EventSuggestFiles("why does the session hang?"), <-m.out)
+
+	model, _ = model.(ChatModel).onFileSuggestions(EventFileSuggestions{Prompt: "something else", Files: []string{"x.go"}})
+	assert.Nil(t, model.(ChatModel).pendingSuggest.files)
+
+	model, _ = model.(ChatModel).onFileSuggestions(EventFileSuggestions{Prompt: "why does the session hang?", Files: []string{"chat/session.go", "store/session.go"}})
+	assert.Contains(t, model.View(), "Did you mean to include chat/session.go? [y/n]")
+
+	press := func(key string) {
+		cm := model.(ChatModel)
+		model, _ = cm.handleKeyPress(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+	}
+	press("y")
+	assert.Contains(t, model.View(), "Did you mean to include store/session.go? [y/n]")
+
+	p := model.(ChatModel).pendingSuggest
+	press("n")
+	assert.Nil(t, model.(ChatModel).pendingSuggest)
+	assert.Equal(t, []string{"chat/session.go"}, p.added)
+	assert.Equal(t, []string{"store/session.go"}, p.declined)
+	assert.Empty(t, model.(ChatModel).prompt.Value())
+
+	// nothing is held when the config says not to ask
+	m.cfg.SuggestFiles = "off"
+	m.prompt.SetValue("hello")
+	model, _ = m.handleSubmit()
+	assert.Nil(t, model.(ChatModel).pendingSuggest)
+}
@@ -1,22 +1,22 @@
 package ui
 
 import (
-	"math"
 	"strings"
 	"unicode/utf8"
 
 	"github.com/charmbracelet/bubbles/textarea"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/mattn/go-runewidth"
+	"github.com/penguinpowernz/clai/internal/i18n"
 )
 
 type Prompt struct {
 	textarea.Model
-	termW int
-	minH  int
-	maxH  int
-	lastH int
+	termW     int
+	minH      int
+	maxH      int
+	lastH     int
+	multiline bool // Enter starts a new line rather than sending
 }
 
 func NewPrompt() Prompt {
@@ -25,13 +25,15 @@ func NewPrompt() Prompt {
 	// ti.Placeholder = "Type your message..."
 	ti.Focus()
 	ti.Prompt = userStyle.Render("\u2588 ")
-	ti.Placeholder = "Type your message..."
+	ti.Placeholder = i18n.T("prompt.placeholder")
 	ti.CharLimit = 0
 	// ti.Width = 80
 	ti.SetWidth(80)
 	// ti.PromptStyle.Background(lipgloss.Color("235"))
 
 	ti.ShowLineNumbers = false
+	// Ctrl+D sends the prompt
+	ti.KeyMap.DeleteCharacterForward.SetKeys("delete")
 	ti.SetHeight(1)
 
 	ti.FocusedStyle.Base.Background(lipgloss.Color("235"))
@@ -43,12 +45,30 @@ func NewPrompt() Prompt {
 
 	// ti.BlurredStyle.Text = lipgloss.NewStyle().Foreground(lipgloss.Color("34"))
 
-	return Prompt{
+	p := Prompt{
 		Model: ti,
 		minH:  1,
 		maxH:  20,
 		lastH: -1,
 	}
+	p.SetMultiline(false)
+	return p
+}
+
+// SetMultiline switches Enter between sending the prompt and starting a new
+// line, Alt+Enter and Ctrl+J always start a new line
+func (p *Prompt) SetMultiline(on bool) {
+	p.multiline = on
+	keys := []string{"alt+enter", "ctrl+j"}
+	if on {
+		keys = append(keys, "enter")
+	}
+	p.Model.KeyMap.InsertNewline.SetKeys(keys...)
+}
+
+// Multiline is true when Enter starts a new line
+func (p Prompt) Multiline() bool {
+	return p.multiline
 }
 
 func (p Prompt) View() string {
@@ -95,13 +115,7 @@ func wrapLinesCount(s string, width int) int {
 			total += 1
 			continue
 		}
-		// compute display width of the line
-		w := 0
-		for _, r := range l {
-			w += runewidth.RuneWidth(r)
-		}
-		// ceil division
-		total += int(math.Ceil(float64(w) / float64(width)))
+		total += displayLines(l, width)
 	}
 	if total == 0 {
 		return 1
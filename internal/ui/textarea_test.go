@@ -0,0 +1,21 @@
+package ui
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPromptMultiline(t *testing.T) {
+	p := NewPrompt()
+	p.InsertString("a")
+	p, _ = p.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Equal(t, "a", p.Value())
+	p, _ = p.Update(tea.KeyMsg{Type: tea.KeyEnter, Alt: true})
+	assert.Equal(t, "a\n", p.Value())
+
+	p.SetMultiline(true)
+	p, _ = p.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Equal(t, "a\n\n", p.Value())
+}
@@ -0,0 +1,177 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/i18n"
+	"github.com/penguinpowernz/clai/internal/tools"
+)
+
+// liveOutputLines is how many of the last lines of a running tool's output
+// are shown
+const liveOutputLines = 10
+
+// maxBlockArgs is how much of the args are shown on the folded line
+const maxBlockArgs = 60
+
+// toolBlock is a tool call and its output, it is shown in the transcript as
+// one line until it is unfolded
+type toolBlock struct {
+	name, args string
+	start      time.Time
+	took       time.Duration
+	output     string
+	done       bool
+	failed     bool
+	code       tools.ErrorCode
+	expanded   bool
+}
+
+// onRunningTool starts the block for the tool, taking the place of the
+// request for permission to run it
+func (m *ChatModel) onRunningTool(msg EventRunningTool) {
+	m.runningTool = true
+	m.typing = false
+	m.thinking = false
+
+	call := &ai.ToolUse{ID: msg.ID, Name: msg.Name, Input: msg.Input}
+	m.toolBlocks[call] = &toolBlock{name: msg.Name, args: string(msg.Input), start: time.Now()}
+
+	block := ai.Message{
+		Role:     "tool",
+		Content:  i18n.T("chat.running_tool", msg.Name, msg.Input),
+		ToolCall: call,
+		Time:     time.Now(),
+	}
+
+	n := len(m.messages) - 1
+	if n >= 0 && m.messages[n].Role == "assistant" && m.messages[n].Content == i18n.T("chat.tool_request", msg.Name, msg.Input) {
+		m.messages[n] = block
+	} else {
+		m.messages = append(m.messages, block)
+		n++
+	}
+	m.liveMsg = n
+
+	m.redrawMessages()
+	m.saveTranscript()
+}
+
+// runningBlock returns the block of the tool that is running
+func (m *ChatModel) runningBlock() (*toolBlock, *ai.Message) {
+	if m.liveMsg < 0 || m.liveMsg >= len(m.messages) {
+		return nil, nil
+	}
+	msg := &m.messages[m.liveMsg]
+	b, ok := m.toolBlocks[msg.ToolCall]
+	if !ok {
+		return nil, nil
+	}
+	return b, msg
+}
+
+// onToolProgress adds the output of the running tool to its block
+func (m *ChatModel) onToolProgress(output string) {
+	b, msg := m.runningBlock()
+	if b == nil {
+		return
+	}
+
+	b.output += output
+	msg.Content = i18n.T("chat.running_tool", b.name, b.args) + "\n" + b.output
+	m.redrawMessages()
+}
+
+func (m *ChatModel) onRunningToolDone(msg EventRunningToolDone) {
+	m.runningTool = false
+	m.typing = false
+	m.thinking = true
+
+	if b, _ := m.runningBlock(); b != nil {
+		b.done = true
+		b.failed = msg.Failed
+		b.code = msg.Code
+		b.took = time.Since(b.start)
+	}
+}
+
+// onToolOutput puts all of the tool's output in its block, which is folded
+func (m *ChatModel) onToolOutput(output string) {
+	b, msg := m.runningBlock()
+	m.liveMsg = -1
+
+	if b == nil {
+		m.addMessage("tool", i18n.T("chat.tool_output")+"\n"+plain(output))
+		return
+	}
+
+	b.output = output
+	b.done = true
+	msg.Content = i18n.T("chat.running_tool", b.name, b.args) + "\n" + output
+	m.redrawMessages()
+	m.saveTranscript()
+}
+
+// renderToolBlock shows the block as one line with how long the tool took
+// and whether it failed, the output is shown while it runs or when unfolded
+func (m ChatModel) renderToolBlock(msg ai.Message) string {
+	b, ok := m.toolBlocks[msg.ToolCall]
+	if !ok {
+		return toolStyle.Render(msg.Content)
+	}
+
+	args := []rune(strings.Join(strings.Fields(b.args), " "))
+	if len(args) > maxBlockArgs {
+		args = append(args[:maxBlockArgs], '…')
+	}
+
+	var status string
+	switch {
+	case !b.done:
+		status = i18n.T("chat.tool_running")
+	case b.failed && b.code != "":
+		status = fmt.Sprintf("%.1fs · %s", b.took.Seconds(), i18n.T("chat.tool_error."+string(b.code)))
+	case b.failed:
+		status = fmt.Sprintf("%.1fs · %s", b.took.Seconds(), i18n.T("chat.tool_failed"))
+	default:
+		status = fmt.Sprintf("%.1fs · %s", b.took.Seconds(), i18n.T("chat.tool_ok"))
+	}
+
+	fold := "▸"
+	if b.expanded || !b.done {
+		fold = "▾"
+	}
+	line := toolStyle.Render(fmt.Sprintf("%s 🔧 %s %s", fold, b.name, string(args))) + helpStyle.Render(" · "+status)
+
+	switch {
+	case b.expanded:
+		return line + "\n" + quoteLines(plain(b.output), 0)
+	case !b.done && b.output != "":
+		return line + "\n" + quoteLines(plain(b.output), liveOutputLines)
+	}
+	return line
+}
+
+// quoteLines prefixes the lines with "> ", only the last n lines are kept
+// unless n is 0
+func quoteLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if n > 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	for i := range lines {
+		lines[i] = "> " + lines[i]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// toggleToolBlock folds or unfolds the block of the ith message, if it is a
+// tool block
+func (m *ChatModel) toggleToolBlock(i int) {
+	if b, ok := m.toolBlocks[m.messages[i].ToolCall]; ok {
+		b.expanded = !b.expanded
+	}
+}
@@ -0,0 +1,48 @@
+package ui
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/i18n"
+	"github.com/penguinpowernz/clai/internal/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToolBlock(t *testing.T) {
+	m := NewChatModel(context.Background(), config.Default())
+	call := ai.ToolCall{ID: "1", Name: "deploy", Input: json.RawMessage(`{"env":"prod"}`)}
+
+	m.OnToolCallReceived(EventToolCall(call))
+	m.pendingToolCall = nil
+	m.onRunningTool(EventRunningTool(call))
+	assert.Len(t, m.messages, 1, "the block replaces the request")
+
+	m.onToolProgress("building\n")
+	assert.Contains(t, m.renderToolBlock(m.messages[0]), "> building")
+
+	m.onRunningToolDone(EventRunningToolDone{Failed: true})
+	m.onToolOutput("building\ndeployed\n")
+
+	folded := m.renderToolBlock(m.messages[0])
+	assert.Contains(t, folded, `▸ 🔧 deploy {"env":"prod"}`)
+	assert.Contains(t, folded, i18n.T("chat.tool_failed"))
+	assert.NotContains(t, folded, "deployed")
+
+	m.toggleToolBlock(0)
+	assert.Contains(t, m.renderToolBlock(m.messages[0]), "> building\n> deployed")
+	assert.Equal(t, []int{0}, m.copyable())
+}
+
+func TestToolBlockErrorCode(t *testing.T) {
+	m := NewChatModel(context.Background(), config.Default())
+	call := ai.ToolCall{ID: "1", Name: "read_file", Input: json.RawMessage(`{"path":"x.go"}`)}
+
+	m.onRunningTool(EventRunningTool(call))
+	m.onRunningToolDone(EventRunningToolDone{Failed: true, Code: tools.ErrNotFound})
+	m.onToolOutput("Error [not_found]: file not found")
+	assert.Contains(t, m.renderToolBlock(m.messages[0]), i18n.T("chat.tool_error.not_found"))
+}
@@ -0,0 +1,92 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/penguinpowernz/clai/internal/commands"
+)
+
+// ToolPicker is the list of tools shown by /tools, their states are changed
+// in place and the session is told straight away
+type ToolPicker struct {
+	title    string
+	options  []commands.ToolOption
+	selected int
+}
+
+// NewToolPicker creates a picker with the first tool selected
+func NewToolPicker(title string, options []commands.ToolOption) *ToolPicker {
+	return &ToolPicker{title: title, options: options}
+}
+
+func (p *ToolPicker) Init() tea.Cmd {
+	return nil
+}
+
+func (p *ToolPicker) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return p, nil
+	}
+
+	switch key.String() {
+	case "up", "k":
+		if p.selected > 0 {
+			p.selected--
+		}
+	case "down", "j":
+		if p.selected < len(p.options)-1 {
+			p.selected++
+		}
+	case " ", "enter", "right", "l":
+		return p, p.cycle(1)
+	case "left", "h":
+		return p, p.cycle(-1)
+	case "esc", "q":
+		return p, func() tea.Msg { return EventListDone{p.title, ""} }
+	}
+
+	return p, nil
+}
+
+// cycle moves the selected tool to the next or previous state
+func (p *ToolPicker) cycle(step int) tea.Cmd {
+	if len(p.options) == 0 {
+		return nil
+	}
+
+	o := &p.options[p.selected]
+	n := len(commands.ToolStates)
+	i := slices.Index(commands.ToolStates, o.State)
+	o.State = commands.ToolStates[((i+step)%n+n)%n]
+
+	ev := EventToolState{Name: o.Name, State: o.State}
+	return func() tea.Msg { return ev }
+}
+
+func (p *ToolPicker) View() string {
+	var b strings.Builder
+	b.WriteString("\n" + p.title + ":\n\n")
+
+	width := 0
+	for _, o := range p.options {
+		width = max(width, len(o.Name))
+	}
+
+	for i, o := range p.options {
+		line := fmt.Sprintf(" %-*s  %-8s  %s", width, o.Name, o.State, o.Source)
+		if i == p.selected {
+			b.WriteString(assistantStyle.Render(">"+line) + "\n")
+			continue
+		}
+		if o.State == commands.ToolDisabled {
+			line = helpStyle.Render(line)
+		}
+		b.WriteString(" " + line + "\n")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/penguinpowernz/clai/internal/i18n"
+)
+
+// ToolStatus is how far along a tool call in the queue is
+type ToolStatus int
+
+const (
+	ToolQueued ToolStatus = iota
+	ToolAwaitingApproval
+	ToolRunning
+	ToolDone
+	ToolSkipped // denied, or after one that was denied
+)
+
+// ToolQueueItem is a tool call in the queue
+type ToolQueueItem struct {
+	Name   string
+	Status ToolStatus
+}
+
+var toolStatusIcons = map[ToolStatus]string{
+	ToolQueued:           "·",
+	ToolAwaitingApproval: "?",
+	ToolRunning:          "▶",
+	ToolDone:             "✓",
+	ToolSkipped:          "✗",
+}
+
+var toolStatusKeys = map[ToolStatus]string{
+	ToolQueued:           "queue.queued",
+	ToolAwaitingApproval: "queue.approval",
+	ToolRunning:          "queue.running",
+	ToolDone:             "queue.done",
+	ToolSkipped:          "queue.skipped",
+}
+
+// renderToolQueue lists the tool calls from the last response and how far
+// along they are, until the AI responds to them
+func (m ChatModel) renderToolQueue() string {
+	if len(m.toolQueue) == 0 {
+		return ""
+	}
+
+	done := 0
+	width := 0
+	for _, item := range m.toolQueue {
+		if item.Status == ToolDone || item.Status == ToolSkipped {
+			done++
+		}
+		width = max(width, len(item.Name))
+	}
+
+	var b strings.Builder
+	b.WriteString(i18n.T("queue.title", done, len(m.toolQueue)) + "\n")
+	for _, item := range m.toolQueue {
+		b.WriteString("  " + toolStatusIcons[item.Status] + " " + item.Name + strings.Repeat(" ", width-len(item.Name)+2) + i18n.T(toolStatusKeys[item.Status]) + "\n")
+	}
+	return helpStyle.Render(b.String()) + "\n"
+}
@@ -10,19 +10,22 @@ import (
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/i18n"
+	"github.com/penguinpowernz/clai/internal/tools"
 )
 
+// the tool permission options, in the order they are shown
 const (
-	optAllowToolThisTime    = "Allow to run this time only"
-	optAllowToolThisSession = "Allow, and don't ask again this session"
-	optDisallowTool         = "Don't allow to run the tool, give the prompt back"
+	optAllowToolThisTime = iota
+	optAllowToolThisSession
+	optDisallowTool
 )
 
 func createToolPermissionList() list.Model {
 	items := []list.Item{
-		list.Item(permissionItem{title: optAllowToolThisTime, desc: ""}),
-		list.Item(permissionItem{title: optAllowToolThisSession, desc: ""}),
-		list.Item(permissionItem{title: optDisallowTool, desc: ""}),
+		list.Item(permissionItem{title: i18n.T("permission.once"), desc: ""}),
+		list.Item(permissionItem{title: i18n.T("permission.session"), desc: ""}),
+		list.Item(permissionItem{title: i18n.T("permission.deny"), desc: ""}),
 	}
 
 	// Create a simple delegate for single-line items
@@ -32,7 +35,7 @@ func createToolPermissionList() list.Model {
 	delegate.Styles.SelectedTitle = lipgloss.NewStyle().Foreground(lipgloss.Color("200"))
 
 	l := list.New(items, delegate, 0, 0)
-	l.Title = "Tool Permission"
+	l.Title = i18n.T("permission.title")
 	l.SetShowStatusBar(false)
 	l.SetFilteringEnabled(false)
 	l.SetShowHelp(false)
@@ -51,7 +54,10 @@ func (i permissionItem) Description() string { return i.desc }
 func (m ChatModel) renderToolPermissionOptions() string {
 	var b strings.Builder
 
-	b.WriteString(fmt.Sprintf("Tool: %s\n\n", m.pendingToolCall.Name))
+	b.WriteString(i18n.T("permission.tool", m.pendingToolCall.Name) + "\n\n")
+	if m.showToolDetails && m.toolDetails != nil {
+		b.WriteString(renderToolDetails(*m.toolDetails) + "\n")
+	}
 
 	for i, option := range m.toolPermissionOptions {
 		cursor := " "
@@ -64,13 +70,34 @@ func (m ChatModel) renderToolPermissionOptions() string {
 	return b.String()
 }
 
-func (m ChatModel) onRunningTool(msg EventRunningTool) {
-	m.runningTool = true
-	m.typing = false
-	m.thinking = false
+// maxDetailArgLines is how many lines of the args are shown in the details,
+// so a big write_file doesn't push the options off the screen
+const maxDetailArgLines = 20
+
+func renderToolDetails(d tools.Details) string {
+	var b strings.Builder
 
-	m.addMessage("system", fmt.Sprintf("Running tool: %s with args: %s", msg.Name, msg.Input))
+	args := strings.Split(d.Args, "\n")
+	if len(args) > maxDetailArgLines {
+		args = append(args[:maxDetailArgLines], i18n.T("permission.more_lines", len(args)-maxDetailArgLines))
+	}
+	b.WriteString(i18n.T("permission.args") + "\n")
+	for _, l := range args {
+		b.WriteString("  " + l + "\n")
+	}
 
+	if len(d.Paths) > 0 {
+		b.WriteString(i18n.T("permission.paths") + " " + strings.Join(d.Paths, ", ") + "\n")
+	}
+	b.WriteString(i18n.T("permission.where") + " " + d.Where + "\n")
+	if len(d.Env) > 0 {
+		b.WriteString(i18n.T("permission.env") + " " + strings.Join(d.Env, ", ") + "\n")
+	}
+	if len(d.Effects) > 0 {
+		b.WriteString(i18n.T("permission.effects") + " " + strings.Join(d.Effects, "; ") + "\n")
+	}
+
+	return b.String()
 }
 
 var reToolCallCheck = regexp.MustCompile(`^Request to use tool: .* with args: .*`)
@@ -89,19 +116,6 @@ func actuallyAToolCall(finalContent string) (EventToolCall, bool) {
 	return EventToolCall{Name: tool, Input: json.RawMessage(args)}, false
 }
 
-func (m *ChatModel) onToolOutput(output string) {
-	if lines := strings.Split(output, "\n"); len(lines) > 3 {
-		lines = lines[:3]
-		for i := range lines {
-			lines[i] = "> " + lines[i]
-		}
-		output = strings.Join(lines[:3], "\n") + "\n> [...]"
-	}
-
-	// Add tool output to chat messages
-	m.addMessage("tool", "Tool output:\n"+output)
-}
-
 func (m *ChatModel) OnToolCallReceived(toolCall EventToolCall) {
 	m.thinking = false
 	m.typing = false
@@ -113,17 +127,12 @@ func (m *ChatModel) OnToolCallReceived(toolCall EventToolCall) {
 	x := ai.ToolCall(toolCall)
 	m.pendingToolCall = &x
 	m.selectedOption = 0 // Reset to first option
+	m.showToolDetails = false
 
 	// Blur textarea to remove focus
 	m.prompt.Blur()
 
-	// Format tool arguments for display
-	argsStr := ""
-	if len(toolCall.Input) > 0 {
-		argsStr = fmt.Sprintf(" with args: %s", toolCall.Input)
-	}
-
 	// Add a system message about the tool call
-	m.addMessage("assistant", fmt.Sprintf("I need to use the tool \"%s\" with args %s", toolCall.Name, argsStr))
+	m.addMessage("assistant", i18n.T("chat.tool_request", toolCall.Name, toolCall.Input))
 
 }
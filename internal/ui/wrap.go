@@ -0,0 +1,96 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/muesli/reflow/ansi"
+	"github.com/muesli/reflow/wordwrap"
+)
+
+// maxWrapCache is how many wrapped blocks to keep before starting again
+const maxWrapCache = 1000
+
+// wrapCache holds the wrapped blocks of the transcript so that the whole
+// transcript isn't wrapped again every time a chunk is streamed in
+type wrapCache struct {
+	width  int
+	blocks map[string]string
+}
+
+func newWrapCache() *wrapCache {
+	return &wrapCache{blocks: map[string]string{}}
+}
+
+// wrap wraps the block to the width, using the cached result if there is one
+func (c *wrapCache) wrap(block string, width int) string {
+	if width != c.width || len(c.blocks) > maxWrapCache {
+		c.width = width
+		c.blocks = map[string]string{}
+	}
+
+	if out, ok := c.blocks[block]; ok {
+		return out
+	}
+
+	out := wrapText(block, width)
+	c.blocks[block] = out
+	return out
+}
+
+// wrapText wraps on word boundaries, then breaks up anything that is still
+// too wide
+func wrapText(s string, width int) string {
+	if width <= 0 {
+		return s
+	}
+	return hardWrap(wordwrap.String(s, width), width)
+}
+
+// hardWrap breaks lines that are still too wide after word wrapping, like long
+// words or CJK text that has no spaces.  The display width of the runes is
+// used so wide characters aren't split across lines, and ANSI escape
+// sequences take up no space.
+func hardWrap(s string, width int) string {
+	var b strings.Builder
+	col := 0
+	inEscape := false
+
+	for _, r := range s {
+		switch {
+		case r == ansi.Marker:
+			inEscape = true
+		case inEscape:
+			inEscape = !ansi.IsTerminator(r)
+		case r == '\n':
+			col = 0
+		default:
+			rw := runewidth.RuneWidth(r)
+			if col+rw > width {
+				b.WriteRune('\n')
+				col = 0
+			}
+			col += rw
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// displayLines returns how many lines the text takes up when it is wrapped
+// to the width
+func displayLines(line string, width int) int {
+	lines := 1
+	col := 0
+	for _, r := range line {
+		rw := runewidth.RuneWidth(r)
+		if col+rw > width {
+			lines++
+			col = 0
+		}
+		col += rw
+	}
+	return lines
+}
@@ -0,0 +1,29 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHardWrap(t *testing.T) {
+	assert.Equal(t, "abcd\nef", hardWrap("abcdef", 4))
+
+	// wide runes are not split across lines
+	assert.Equal(t, "日本\n語", hardWrap("日本語", 5))
+
+	// escape sequences take up no space
+	assert.Equal(t, "\x1b[1mab\ncd\x1b[0m", hardWrap("\x1b[1mabcd\x1b[0m", 2))
+}
+
+func TestWrapText(t *testing.T) {
+	assert.Equal(t, "hello\nworld", wrapText("hello world", 8))
+	assert.Equal(t, "hello world", wrapText("hello world", 0))
+	assert.Equal(t, "これはテス\nトです", wrapText("これはテストです", 10))
+}
+
+func TestDisplayLines(t *testing.T) {
+	assert.Equal(t, 1, displayLines("", 10))
+	assert.Equal(t, 2, displayLines("日本語", 5))
+	assert.Equal(t, 3, displayLines("abcdefghij", 4))
+}
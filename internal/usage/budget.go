@@ -0,0 +1,156 @@
+package usage
+
+import (
+	"sync"
+	"time"
+)
+
+// warnAt is the fraction of a budget that is spent before warning about it
+const warnAt = 0.8
+
+// Budget keeps track of the spending against the session and daily limits,
+// a limit of 0 means there is none
+type Budget struct {
+	maxSession float64
+	maxDaily   float64
+
+	// the admin's caps from the policy, approving doesn't lift them
+	capSession float64
+	capDaily   float64
+
+	mu       sync.Mutex
+	session  float64
+	daily    float64
+	day      string
+	approved bool
+}
+
+// NewBudget creates a budget with the limits in USD, spentToday is what was
+// already spent today by other sessions
+func NewBudget(maxSession, maxDaily, spentToday float64) *Budget {
+	return &Budget{
+		maxSession: maxSession,
+		maxDaily:   maxDaily,
+		daily:      spentToday,
+		day:        today(),
+	}
+}
+
+func today() string {
+	return time.Now().Format(time.DateOnly)
+}
+
+// SpentToday totals the cost of the records made today
+func SpentToday(records []Record) float64 {
+	var total float64
+	day := today()
+	for _, r := range records {
+		if r.Time.Local().Format(time.DateOnly) == day {
+			total += r.Cost
+		}
+	}
+	return total
+}
+
+// Add counts the cost of a request
+func (b *Budget) Add(cost float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollover()
+	b.session += cost
+	b.daily += cost
+}
+
+// SessionCost is what has been spent in this session
+func (b *Budget) SessionCost() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.session
+}
+
+// rollover starts the daily spending again when the day changes
+func (b *Budget) rollover() {
+	if d := today(); d != b.day {
+		b.day = d
+		b.daily = 0
+	}
+}
+
+// Cap sets the hard limits from the admin's policy in USD, requests past
+// them are blocked even when the user has approved going over their own
+func (b *Budget) Cap(maxSession, maxDaily float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.capSession = maxSession
+	b.capDaily = maxDaily
+}
+
+// Capped returns the spending against the admin's cap that has been reached,
+// if one has
+func (b *Budget) Capped() (BudgetStatus, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollover()
+
+	switch {
+	case b.capSession > 0 && b.session >= b.capSession:
+		return BudgetStatus{Kind: "session", Spent: b.session, Limit: b.capSession}, true
+	case b.capDaily > 0 && b.daily >= b.capDaily:
+		return BudgetStatus{Kind: "daily", Spent: b.daily, Limit: b.capDaily}, true
+	}
+	return BudgetStatus{}, false
+}
+
+// Approve lets requests carry on past the limits for the rest of the session
+func (b *Budget) Approve() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.approved = true
+}
+
+// Blocked is true when a limit has been reached and the user hasn't said to
+// carry on past it, or the admin's cap has been reached
+func (b *Budget) Blocked() bool {
+	if _, capped := b.Capped(); capped {
+		return true
+	}
+
+	b.mu.Lock()
+	approved := b.approved
+	b.mu.Unlock()
+	return !approved && b.Status().Exceeded()
+}
+
+// BudgetStatus is the spending against one of the limits
+type BudgetStatus struct {
+	Kind  string // "session" or "daily"
+	Spent float64
+	Limit float64 // 0 when there are no limits
+}
+
+// Warning is true when most of the budget has been spent
+func (s BudgetStatus) Warning() bool {
+	return s.Limit > 0 && s.Spent >= s.Limit*warnAt
+}
+
+// Exceeded is true when all of the budget has been spent
+func (s BudgetStatus) Exceeded() bool {
+	return s.Limit > 0 && s.Spent >= s.Limit
+}
+
+// Status returns the spending against the limit that is closest to being
+// reached
+func (b *Budget) Status() BudgetStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollover()
+
+	var st BudgetStatus
+	if b.maxSession > 0 {
+		st = BudgetStatus{Kind: "session", Spent: b.session, Limit: b.maxSession}
+	}
+	if b.maxDaily > 0 && (st.Limit == 0 || b.daily/b.maxDaily > st.Spent/st.Limit) {
+		st = BudgetStatus{Kind: "daily", Spent: b.daily, Limit: b.maxDaily}
+	}
+	return st
+}
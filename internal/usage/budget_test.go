@@ -0,0 +1,58 @@
+package usage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBudget(t *testing.T) {
+	b := NewBudget(1, 10, 5)
+	assert.False(t, b.Status().Warning())
+
+	// the session limit is closer to being reached
+	b.Add(0.8)
+	st := b.Status()
+	assert.Equal(t, "session", st.Kind)
+	assert.InDelta(t, 0.8, st.Spent, 0.0001)
+	assert.True(t, st.Warning())
+	assert.False(t, b.Blocked())
+
+	b.Add(0.2)
+	assert.True(t, b.Status().Exceeded())
+	assert.True(t, b.Blocked())
+
+	b.Approve()
+	assert.False(t, b.Blocked())
+}
+
+func TestBudgetDaily(t *testing.T) {
+	b := NewBudget(0, 10, 9)
+	st := b.Status()
+	assert.Equal(t, "daily", st.Kind)
+	assert.True(t, st.Warning())
+
+	b = NewBudget(0, 0, 100)
+	assert.Zero(t, b.Status().Limit)
+	assert.False(t, b.Blocked())
+}
+
+func TestBudgetCap(t *testing.T) {
+	b := NewBudget(1, 0, 3.5)
+	b.Cap(0, 5)
+
+	b.Add(1)
+	assert.True(t, b.Blocked())
+	_, capped := b.Capped()
+	assert.False(t, capped)
+
+	// the user's own limit can be approved, the admin's cap can't
+	b.Approve()
+	assert.False(t, b.Blocked())
+
+	b.Add(0.5)
+	st, capped := b.Capped()
+	assert.True(t, capped)
+	assert.Equal(t, BudgetStatus{Kind: "daily", Spent: 5, Limit: 5}, st)
+	assert.True(t, b.Blocked())
+}
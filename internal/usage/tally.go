@@ -0,0 +1,31 @@
+package usage
+
+import (
+	"sync"
+	"time"
+)
+
+// Tally keeps the records of the requests made by this session in memory,
+// so the spending can be shown without reading the log back
+type Tally struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+// Add counts the request, filling in the time
+func (t *Tally) Add(r Record) {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.records = append(t.records, r)
+}
+
+// Records returns the requests made so far, oldest first
+func (t *Tally) Records() []Record {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Record(nil), t.records...)
+}
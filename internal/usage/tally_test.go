@@ -0,0 +1,24 @@
+package usage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTally(t *testing.T) {
+	var tally Tally
+	assert.Empty(t, tally.Records())
+
+	tally.Add(Record{Model: "gpt-4o", Prompt: 1000, Completion: 100, Cost: 0.0035})
+	tally.Add(Record{Model: "qwen3:8b", Prompt: 2000, Completion: 300, Estimated: true})
+
+	records := tally.Records()
+	assert.Len(t, records, 2)
+	assert.False(t, records[0].Time.IsZero())
+	assert.Equal(t, "qwen3:8b", records[1].Model)
+
+	// changing what was returned doesn't change the tally
+	records[0].Cost = 100
+	assert.Equal(t, 0.0035, tally.Records()[0].Cost)
+}
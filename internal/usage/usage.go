@@ -0,0 +1,166 @@
+// Package usage keeps a record of the tokens used by each request to the AI
+// provider, so the usage and cost can be reported over time
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record is the usage of a single request
+type Record struct {
+	Time       time.Time `json:"time"`
+	Session    string    `json:"session"`
+	Dir        string    `json:"dir"` // the project directory clai was started in
+	Model      string    `json:"model"`
+	Prompt     int       `json:"prompt_tokens"`
+	Completion int       `json:"completion_tokens"`
+	Cost       float64   `json:"cost"`                // USD
+	Estimated  bool      `json:"estimated,omitempty"` // the provider didn't say how many tokens were used
+}
+
+// Log appends records to a file as JSON lines
+type Log struct {
+	path    string
+	session string
+	dir     string
+	mu      sync.Mutex
+}
+
+// NewLog creates a log that writes to the file at path, the records are for
+// the given session and project directory
+func NewLog(path, session, dir string) *Log {
+	return &Log{path: path, session: session, dir: dir}
+}
+
+// Add writes the record to the log, filling in the time, session and dir
+func (l *Log) Add(r Record) error {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	r.Session = l.session
+	r.Dir = l.dir
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// Read returns the records in the file made since the given time, broken
+// lines are skipped
+func Read(path string, since time.Time) ([]Record, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		if r.Time.Before(since) {
+			continue
+		}
+		records = append(records, r)
+	}
+
+	return records, scanner.Err()
+}
+
+// Row is the usage for a day, model and project directory
+type Row struct {
+	Day        string
+	Model      string
+	Dir        string
+	Requests   int
+	Prompt     int
+	Completion int
+	Cost       float64
+	Estimated  bool // some of the token counts are estimates
+}
+
+// Aggregate totals the records by day, model and project directory, sorted
+// by day then model then dir
+func Aggregate(records []Record) []Row {
+	rows := make(map[[3]string]*Row)
+	for _, r := range records {
+		key := [3]string{r.Time.Local().Format(time.DateOnly), r.Model, r.Dir}
+		row, ok := rows[key]
+		if !ok {
+			row = &Row{Day: key[0], Model: key[1], Dir: key[2]}
+			rows[key] = row
+		}
+
+		row.Requests++
+		row.Prompt += r.Prompt
+		row.Completion += r.Completion
+		row.Cost += r.Cost
+		row.Estimated = row.Estimated || r.Estimated
+	}
+
+	out := make([]Row, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, *row)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Day != out[j].Day {
+			return out[i].Day < out[j].Day
+		}
+		if out[i].Model != out[j].Model {
+			return out[i].Model < out[j].Model
+		}
+		return out[i].Dir < out[j].Dir
+	})
+	return out
+}
+
+// ParseSince parses how far back to go, either a duration like 7d, 2w or
+// 12h, or a date like 2024-01-31
+func ParseSince(s string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, s, now.Location()); err == nil {
+		return t, nil
+	}
+
+	if n, err := strconv.Atoi(strings.TrimRight(s, "dw")); err == nil && n >= 0 {
+		switch {
+		case strings.HasSuffix(s, "d"):
+			return now.AddDate(0, 0, -n), nil
+		case strings.HasSuffix(s, "w"):
+			return now.AddDate(0, 0, -7*n), nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("can't understand %q, use something like 7d, 2w, 12h or 2024-01-31", s)
+	}
+	return now.Add(-d), nil
+}
@@ -0,0 +1,65 @@
+package usage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogAndRead(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "usage.jsonl")
+	l := NewLog(fn, "abc123", "/src/clai")
+
+	old := time.Now().AddDate(0, 0, -10)
+	assert.NoError(t, l.Add(Record{Time: old, Model: "gpt-4o", Prompt: 10, Completion: 5}))
+	assert.NoError(t, l.Add(Record{Model: "gpt-4o", Prompt: 100, Completion: 50, Cost: 0.01}))
+
+	records, err := Read(fn, time.Now().AddDate(0, 0, -7))
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "abc123", records[0].Session)
+	assert.Equal(t, "/src/clai", records[0].Dir)
+	assert.Equal(t, 100, records[0].Prompt)
+
+	records, err = Read(filepath.Join(t.TempDir(), "missing.jsonl"), old)
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestAggregate(t *testing.T) {
+	day1 := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+
+	rows := Aggregate([]Record{
+		{Time: day2, Model: "qwen3", Dir: "/a", Prompt: 1, Completion: 1, Estimated: true},
+		{Time: day1, Model: "gpt-4o", Dir: "/a", Prompt: 10, Completion: 5, Cost: 0.5},
+		{Time: day1, Model: "gpt-4o", Dir: "/a", Prompt: 20, Completion: 5, Cost: 0.25},
+		{Time: day1, Model: "gpt-4o", Dir: "/b", Prompt: 1, Completion: 1},
+	})
+
+	assert.Equal(t, []Row{
+		{Day: "2024-01-01", Model: "gpt-4o", Dir: "/a", Requests: 2, Prompt: 30, Completion: 10, Cost: 0.75},
+		{Day: "2024-01-01", Model: "gpt-4o", Dir: "/b", Requests: 1, Prompt: 1, Completion: 1},
+		{Day: "2024-01-02", Model: "qwen3", Dir: "/a", Requests: 1, Prompt: 1, Completion: 1, Estimated: true},
+	}, rows)
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+
+	for in, want := range map[string]time.Time{
+		"7d":         now.AddDate(0, 0, -7),
+		"2w":         now.AddDate(0, 0, -14),
+		"12h":        now.Add(-12 * time.Hour),
+		"2024-03-01": time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		got, err := ParseSince(in, now)
+		assert.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	_, err := ParseSince("last week", now)
+	assert.Error(t, err)
+}
@@ -0,0 +1,144 @@
+package watch
+
+import (
+	"context"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/files"
+)
+
+// Watcher watches a directory tree for changes, skipping anything that is
+// excluded or out of scope in the config
+type Watcher struct {
+	cfg      config.Config
+	root     string
+	debounce time.Duration
+	fsw      *fsnotify.Watcher
+}
+
+// New creates a watcher for every directory under root, changes are batched
+// up until nothing has changed for the debounce duration
+func New(cfg config.Config, root string, debounce time.Duration) (*Watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{cfg: cfg, root: root, debounce: debounce, fsw: fsw}
+	if err := w.addTree(root); err != nil {
+		fsw.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Close stops watching
+func (w *Watcher) Close() error {
+	return w.fsw.Close()
+}
+
+// Run calls fn with the files (relative to the root) that changed, until the
+// context is cancelled.  Changes that happen while fn is running are ignored,
+// so that the watcher doesn't react to edits made by the AI itself.
+func (w *Watcher) Run(ctx context.Context, fn func(files []string)) error {
+	changed := map[string]bool{}
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return nil
+			}
+			log.Println("[watch] error:", err)
+
+		case ev, ok := <-w.fsw.Events:
+			if !ok {
+				return nil
+			}
+
+			rel, skip := w.skip(ev.Name)
+			if skip {
+				continue
+			}
+
+			// new directories need watching too
+			if ev.Has(fsnotify.Create) {
+				if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+					w.addTree(ev.Name)
+				}
+			}
+
+			changed[rel] = true
+			timer.Reset(w.debounce)
+
+		case <-timer.C:
+			files := make([]string, 0, len(changed))
+			for f := range changed {
+				files = append(files, f)
+			}
+			sort.Strings(files)
+
+			log.Println("[watch] files changed:", files)
+			fn(files)
+
+			w.drain()
+			changed = map[string]bool{}
+		}
+	}
+}
+
+// drain throws away any events that are pending
+func (w *Watcher) drain() {
+	for {
+		select {
+		case <-w.fsw.Events:
+		default:
+			return
+		}
+	}
+}
+
+// skip returns the path relative to the root and whether it should be ignored
+func (w *Watcher) skip(path string) (string, bool) {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil || rel == "." {
+		return rel, true
+	}
+
+	return rel, w.ignored(rel) || !files.InScope(w.cfg, w.root, rel)
+}
+
+func (w *Watcher) ignored(rel string) bool {
+	return files.IsHidden(w.cfg, w.root, rel) || files.IsExcluded(w.cfg, w.root, rel)
+}
+
+// addTree adds the directory and all its subdirectories to the watcher
+func (w *Watcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+
+		if rel, _ := filepath.Rel(w.root, path); rel != "." {
+			if w.ignored(rel) || !files.LeadsToScope(w.cfg, w.root, rel) {
+				return filepath.SkipDir
+			}
+		}
+
+		return w.fsw.Add(path)
+	})
+}
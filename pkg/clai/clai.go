@@ -0,0 +1,107 @@
+// Package clai is the conversation engine of clai without the terminal UI, so
+// other Go programs can embed the agent.  A Session sends prompts to a
+// Provider, runs the tools from a ToolRegistry that the AI asks for, and
+// saves its messages to a Store.
+//
+//	cfg := clai.DefaultConfig()
+//	provider, err := clai.NewProvider(cfg)
+//	...
+//	s := clai.NewSession(cfg, provider)
+//	defer s.Close()
+//	response, err := s.Send(ctx, "what does main.go do?")
+package clai
+
+import (
+	"github.com/penguinpowernz/clai/config"
+	"github.com/penguinpowernz/clai/internal/ai"
+	"github.com/penguinpowernz/clai/internal/chat"
+	"github.com/penguinpowernz/clai/internal/tools"
+)
+
+// Config is the same config as clai uses, see config.Load to read the user's
+type Config = config.Config
+
+// DefaultConfig returns the config clai has before the config file is read
+func DefaultConfig() *Config {
+	return config.Default()
+}
+
+// Message is a message of the conversation
+type Message = ai.Message
+
+// Provider is an AI API the session sends its requests to, it can be
+// implemented to use an API that clai doesn't support
+type Provider = ai.Provider
+
+// The types a Provider works with
+type (
+	Response     = ai.Response
+	MessageChunk = ai.MessageChunk
+	ModelInfo    = ai.ModelInfo
+	ModelDetails = ai.ModelDetails
+)
+
+// The types of the chunks a Provider streams
+const (
+	ChunkMessage  = ai.ChunkMessage
+	ChunkToolCall = ai.ChunkToolCall
+	ChunkThink    = ai.ChunkThink
+	ChunkFinish   = ai.ChunkFinish
+	ChunkError    = ai.ChunkError
+)
+
+// NewChunk creates a chunk of a streamed response
+func NewChunk(typ, content string) MessageChunk {
+	return ai.NewChunk(typ, content)
+}
+
+// NewToolCallChunk creates a chunk for a call to a tool
+func NewToolCallChunk(call *ToolCall) MessageChunk {
+	return ai.NewToolCallChunk(call)
+}
+
+// NewProvider creates the provider set in the config
+func NewProvider(cfg *Config) (Provider, error) {
+	return ai.NewClient(cfg)
+}
+
+// ToolCall is a call to a tool the AI asked for
+type ToolCall = ai.ToolCall
+
+// The types of the tools the AI can use
+type (
+	Tool           = tools.Tool
+	FunctionSchema = tools.FunctionSchema
+	JSONSchema     = tools.JSONSchema
+	Property       = tools.Property
+	ToolUse        = tools.ToolUse
+	ToolResult     = tools.ToolResult
+	ToolError      = tools.Error
+	ErrorCode      = tools.ErrorCode
+)
+
+// The codes of a failed ToolResult, so the AI and the UI can tell why it failed
+const (
+	ErrNotFound         = tools.ErrNotFound
+	ErrPermissionDenied = tools.ErrPermissionDenied
+	ErrTimeout          = tools.ErrTimeout
+	ErrTooLarge         = tools.ErrTooLarge
+)
+
+// ToolRegistry is where the session gets the tools it offers the AI, and
+// runs the calls to them.  By default they are clai's built-in tools and
+// plugins, limited by the policy in the config.
+type ToolRegistry = chat.ToolRegistry
+
+// Registry is clai's own ToolRegistry, more tools can be registered on it and
+// they can be enabled or disabled for the session
+type Registry = tools.Registry
+
+// NewRegistry creates a registry with clai's built-in tools, the plugins in
+// plugin_dir can be added with RegisterPlugins
+func NewRegistry(cfg *Config) *Registry {
+	return tools.NewRegistry(cfg)
+}
+
+// Store is where the messages of a session are saved
+type Store = chat.Store
@@ -0,0 +1,226 @@
+package clai
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/penguinpowernz/clai/internal/chat"
+	"github.com/penguinpowernz/clai/internal/ui"
+)
+
+// Session is a conversation with the AI.  It is safe to use from more than
+// one goroutine, but the prompts are answered one at a time.
+type Session struct {
+	engine *chat.Session
+	cancel context.CancelFunc
+
+	in     chan any // events to the engine
+	events chan any // events from the engine
+
+	approve func(ToolCall) bool
+	output  func(string)
+
+	mu        sync.Mutex
+	stopDrain func()
+}
+
+// Option changes how a session is made
+type Option func(*options)
+
+type options struct {
+	id       string
+	tools    ToolRegistry
+	store    Store
+	messages []Message
+	approve  func(ToolCall) bool
+	output   func(string)
+}
+
+// WithID sets the ID of the session, it is random by default
+func WithID(id string) Option {
+	return func(o *options) { o.id = id }
+}
+
+// WithTools sets the tools the AI can use instead of clai's own
+func WithTools(r ToolRegistry) Option {
+	return func(o *options) { o.tools = r }
+}
+
+// WithStore saves the messages of the session to the store
+func WithStore(st Store) Option {
+	return func(o *options) { o.store = st }
+}
+
+// WithMessages starts the session with the messages of an earlier one
+func WithMessages(messages []Message) Option {
+	return func(o *options) { o.messages = messages }
+}
+
+// WithApprover is asked before a tool that isn't in permitted_tools is run,
+// without one those calls are denied as there is nobody to ask
+func WithApprover(approve func(ToolCall) bool) Option {
+	return func(o *options) { o.approve = approve }
+}
+
+// WithOutput is given the response as it is streamed
+func WithOutput(output func(text string)) Option {
+	return func(o *options) { o.output = output }
+}
+
+// NewSession starts a session with the provider, tools are run in the
+// current working directory.  Close it when done.
+func NewSession(cfg *Config, provider Provider, opts ...Option) *Session {
+	o := options{id: uuid.New().String()[:6]}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	engine := chat.NewSession(cfg, provider, o.id)
+	if o.tools != nil {
+		engine.SetToolRegistry(o.tools)
+	}
+	if o.store != nil {
+		engine.SetStore(o.store)
+	}
+	for _, m := range o.messages {
+		engine.AddMessage(m)
+	}
+
+	s := &Session{
+		engine:  engine,
+		in:      make(chan any, ui.EventBuffer),
+		approve: o.approve,
+		output:  o.output,
+	}
+	engine.AddObserver(s)
+	engine.Observe(s.in)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	go engine.InteractiveMode(ctx)
+	s.stopDrain = s.drain()
+
+	return s
+}
+
+// Observe is how the engine hands the session its events
+func (s *Session) Observe(events chan any) {
+	s.events = events
+}
+
+// ID returns the ID of the session
+func (s *Session) ID() string {
+	return s.engine.ID()
+}
+
+// Messages returns the messages of the conversation so far
+func (s *Session) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.engine.Export())
+}
+
+// Close stops the session, it can't be used after that
+func (s *Session) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopDrain()
+	s.stopDrain = func() {}
+	s.cancel()
+}
+
+// drain throws away the events that come between prompts, like the title
+// being made, so the engine doesn't block on them
+func (s *Session) drain() (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-s.events:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done); <-stopped }
+}
+
+// Send sends the prompt and returns the response once the AI has finished
+// its turn, including any tools it used on the way.  Slash commands work
+// like they do in the chat and return what they say.
+func (s *Session) Send(ctx context.Context, prompt string) (string, error) {
+	prompt = strings.TrimSpace(prompt)
+	if prompt == "" {
+		return "", fmt.Errorf("the prompt is empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopDrain()
+	defer func() { s.stopDrain = s.drain() }()
+
+	s.in <- ui.EventUserPrompt(prompt)
+
+	var response strings.Builder
+	var err error
+	for {
+		var ev any
+		select {
+		case ev = <-s.events:
+		case <-ctx.Done():
+			s.in <- ui.EventCancelStream{}
+			return response.String(), ctx.Err()
+		}
+
+		switch msg := ev.(type) {
+		case ui.EventStreamChunk:
+			response.WriteString(string(msg))
+			if s.output != nil {
+				s.output(string(msg))
+			}
+
+		case ui.EventSlashCommand:
+			return msg.Message, nil // commands don't start a turn
+
+		case ui.EventModelSelection:
+			var names []string
+			for _, m := range msg {
+				names = append(names, m.Name)
+			}
+			return strings.Join(names, "\n"), nil
+
+		case ui.EventToolCall:
+			if s.approve != nil && s.approve(ToolCall(msg)) {
+				s.in <- ui.EventPermitToolUse(msg)
+				continue
+			}
+			log.Println("[clai] denying tool call:", msg.Name)
+			s.in <- ui.EventCancelToolUse(msg)
+
+		case ui.EventStreamErr:
+			err = msg
+
+		case ui.EventBudget:
+			if msg.Held {
+				err = fmt.Errorf("the %s budget of $%.2f has been spent", msg.Kind, msg.Limit)
+			}
+
+		case ui.EventStreamBanned:
+			err = fmt.Errorf("the response was stopped as it matched the banned_output pattern %s", string(msg))
+
+		case ui.EventStreamStalled:
+			err = fmt.Errorf("the AI sent nothing for %s so the response was stopped", time.Duration(msg))
+
+		case ui.EventTurnDone:
+			return response.String(), err
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package clai_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/penguinpowernz/clai/pkg/clai"
+	"github.com/stretchr/testify/assert"
+)
+
+// provider streams the canned responses in order
+type provider struct {
+	responses [][]clai.MessageChunk
+}
+
+func (p *provider) StreamMessage(ctx context.Context, msgs []clai.Message) (<-chan clai.MessageChunk, error) {
+	ch := make(chan clai.MessageChunk, 10)
+	if len(p.responses) > 0 {
+		for _, c := range p.responses[0] {
+			ch <- c
+		}
+		p.responses = p.responses[1:]
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (p *provider) SendMessage(ctx context.Context, msgs []clai.Message) (*clai.Response, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+func (p *provider) GetModelInfo() clai.ModelInfo                   { return clai.ModelInfo{} }
+func (p *provider) ListModels() []string                           { return nil }
+func (p *provider) ListModelDetails() ([]clai.ModelDetails, error) { return nil, nil }
+func (p *provider) SetTools(tools []clai.Tool)                     {}
+
+type registry struct{ calls []string }
+
+func (r *registry) Tools() []clai.Tool {
+	return []clai.Tool{{Type: "function", Function: &clai.FunctionSchema{Name: "answer", Description: "gives the answer"}}}
+}
+func (r *registry) Execute(call clai.ToolUse, workingDir string, live io.Writer) clai.ToolResult {
+	r.calls = append(r.calls, call.Name+" "+string(call.Input))
+	return clai.ToolResult{ToolUseID: call.ID, Content: "42"}
+}
+
+type store struct{ saved []clai.Message }
+
+func (s *store) Save(what string, messages []clai.Message) error {
+	s.saved = messages
+	return nil
+}
+func (s *store) SaveTitle(title, summary string) error { return nil }
+
+func TestSession(t *testing.T) {
+	p := &provider{responses: [][]clai.MessageChunk{
+		{clai.NewToolCallChunk(&clai.ToolCall{ID: "1", Name: "answer", Input: json.RawMessage(`{}`)})},
+		{clai.NewChunk(clai.ChunkMessage, "it is "), clai.NewChunk(clai.ChunkMessage, "42")},
+	}}
+	r := &registry{}
+	st := &store{}
+
+	cfg := &clai.Config{Model: "x", SaveHistory: true}
+	var asked []string
+	s := clai.NewSession(cfg, p, clai.WithTools(r), clai.WithStore(st), clai.WithApprover(func(tc clai.ToolCall) bool {
+		asked = append(asked, tc.Name)
+		return true
+	}))
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	response, err := s.Send(ctx, "what is the answer?")
+	assert.NoError(t, err)
+	assert.Equal(t, "it is 42", response)
+	assert.Equal(t, []string{"answer"}, asked)
+	assert.Equal(t, []string{"answer {}"}, r.calls)
+
+	msgs := s.Messages()
+	assert.Len(t, msgs, 4)
+	assert.Equal(t, msgs, st.saved)
+}